@@ -0,0 +1,276 @@
+// cmd/avcimporter/ackdecisions.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/catalog"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/csvinput"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// decisionColumns are the columns of an acknowledgement decision CSV file.
+var decisionColumns = []csvinput.Column{
+	{Name: "purchaseOrderNumber", Required: true},
+	{Name: "itemSequenceNumber", Required: true},
+	{Name: "acknowledgementCode", Required: true},
+	{Name: "acknowledgedQuantity", Required: true},
+	{Name: "unitOfMeasure"},
+	{Name: "scheduledShipDate"},
+	{Name: "scheduledDeliveryDate"},
+	{Name: "rejectionReason"},
+}
+
+// rejectionReasons are the rejection reasons SP‑API accepts.
+var rejectionReasons = []string{"TemporarilyUnavailable", "InvalidProductIdentifier", "ObsoleteProduct"}
+
+/*
+decisionError is a line of a decision file that cannot be acknowledged,
+emitted by acknowledge.
+
+Fields:
+  - Line:   CSV line number.
+  - Detail: What is wrong with the line.
+*/
+type decisionError struct {
+	Line                int    `json:"line"`
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+	ItemSequenceNumber  string `json:"itemSequenceNumber"`
+	Detail              string `json:"detail"`
+}
+
+/*
+readDecisions reads an acknowledgement decision file (name "-" for stdin) and
+builds one acknowledgement per purchase order it names, in file order.
+
+Each row decides a quantity of one order line: Accepted, Backordered or
+Rejected. A line may be split over several rows (e.g. part accepted, part
+backordered), but the decided quantities of every line of a named order
+must add up to its ordered quantity. Scheduled dates
+default to the start of the order's ship and delivery windows for accepted
+quantities. With packs, the accepted quantity of every line must be a whole
+number of cases.
+
+Returns:
+  - The acknowledgements, if every row is valid.
+  - One decisionError per invalid row or incomplete order.
+  - An error if the file cannot be read or its header is invalid.
+*/
+func readDecisions(cfg *config.Config, packs catalog.CasePacks, name string) ([]spapi.OrderAcknowledgement, []decisionError, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	rows, err := csvinput.ReadAll(r, decisionColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type pending struct {
+		order   spapi.PurchaseOrder
+		ack     spapi.OrderAcknowledgement
+		items   map[string]int // index into ack.Items by item sequence number
+		decided map[string]int // decided quantity by item sequence number
+		line    int
+		windows map[string]time.Time
+	}
+	var orders []*pending
+	byPO := map[string]*pending{}
+	missing := map[string]bool{}
+	var invalid []decisionError
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, row := range rows {
+		po, seq := row.Get("purchaseOrderNumber"), row.Get("itemSequenceNumber")
+		fail := func(format string, a ...interface{}) {
+			invalid = append(invalid, decisionError{Line: row.Line, PurchaseOrderNumber: po, ItemSequenceNumber: seq, Detail: fmt.Sprintf(format, a...)})
+		}
+		if row.Err != nil {
+			fail("%v", row.Err)
+			continue
+		}
+		code := ""
+		for _, c := range []string{spapi.AckAccepted, spapi.AckBackordered, spapi.AckRejected} {
+			if strings.EqualFold(row.Get("acknowledgementCode"), c) {
+				code = c
+			}
+		}
+		if code == "" {
+			fail("acknowledgementCode must be Accepted, Backordered or Rejected, got %q", row.Get("acknowledgementCode"))
+			continue
+		}
+		qty, err := row.Int("acknowledgedQuantity")
+		if err != nil {
+			fail("%v", err)
+			continue
+		}
+		if qty <= 0 {
+			fail("acknowledgedQuantity must be positive")
+			continue
+		}
+		reason := row.Get("rejectionReason")
+		if reason != "" {
+			if code != spapi.AckRejected {
+				fail("rejectionReason is only allowed for Rejected")
+				continue
+			}
+			known := false
+			for _, r := range rejectionReasons {
+				if strings.EqualFold(reason, r) {
+					reason, known = r, true
+				}
+			}
+			if !known {
+				fail("rejectionReason must be one of %s, got %q", strings.Join(rejectionReasons, ", "), reason)
+				continue
+			}
+		}
+		var dates [2]*time.Time
+		bad := false
+		for i, col := range []string{"scheduledShipDate", "scheduledDeliveryDate"} {
+			if v := row.Get(col); v != "" {
+				t, err := parseDateFlag(v, false)
+				if err != nil {
+					fail("invalid %s: %v", col, err)
+					bad = true
+					break
+				}
+				dates[i] = &t
+			}
+		}
+		if bad {
+			continue
+		}
+
+		p := byPO[po]
+		if p == nil && !missing[po] {
+			o, err := storage.LoadOrder(cfg.Storage.SavePath, po)
+			switch {
+			case err != nil:
+				missing[po] = true
+			case o.OrderDetails.SellingParty == nil:
+				fail("purchase order has no selling party")
+				continue
+			default:
+				p = &pending{order: o, items: map[string]int{}, decided: map[string]int{}, line: row.Line, windows: map[string]time.Time{}}
+				p.ack = spapi.OrderAcknowledgement{PurchaseOrderNumber: po, SellingParty: *o.OrderDetails.SellingParty, AcknowledgementDate: now}
+				addWindow(p.windows, "shipWindow", o.OrderDetails.ShipWindow)
+				addWindow(p.windows, "deliveryWindow", o.OrderDetails.DeliveryWindow)
+				byPO[po] = p
+				orders = append(orders, p)
+			}
+		}
+		if p == nil {
+			fail("purchase order not found in storage")
+			continue
+		}
+		var ordered *spapi.OrderItem
+		for i := range p.order.OrderDetails.Items {
+			if p.order.OrderDetails.Items[i].ItemSequenceNumber == seq {
+				ordered = &p.order.OrderDetails.Items[i]
+				break
+			}
+		}
+		if ordered == nil {
+			fail("purchase order has no item %s", seq)
+			continue
+		}
+		unit := row.Get("unitOfMeasure")
+		if unit == "" {
+			unit = ordered.OrderedQuantity.UnitOfMeasure
+		}
+		if !strings.EqualFold(unit, ordered.OrderedQuantity.UnitOfMeasure) {
+			fail("unitOfMeasure %s differs from the ordered %s", unit, ordered.OrderedQuantity.UnitOfMeasure)
+			continue
+		}
+		if p.decided[seq]+qty > ordered.OrderedQuantity.Amount {
+			fail("decided quantity %d exceeds the ordered %d", p.decided[seq]+qty, ordered.OrderedQuantity.Amount)
+			continue
+		}
+		p.decided[seq] += qty
+
+		item := spapi.ItemAcknowledgement{
+			AcknowledgementCode:   code,
+			AcknowledgedQuantity:  spapi.ItemQuantity{Amount: qty, UnitOfMeasure: ordered.OrderedQuantity.UnitOfMeasure, UnitSize: ordered.OrderedQuantity.UnitSize},
+			ScheduledShipDate:     dates[0],
+			ScheduledDeliveryDate: dates[1],
+			RejectionReason:       reason,
+		}
+		if code == spapi.AckAccepted {
+			if t, ok := p.windows["shipWindowStart"]; ok && item.ScheduledShipDate == nil {
+				item.ScheduledShipDate = &t
+			}
+			if t, ok := p.windows["deliveryWindowStart"]; ok && item.ScheduledDeliveryDate == nil {
+				item.ScheduledDeliveryDate = &t
+			}
+		}
+		i, ok := p.items[seq]
+		if !ok {
+			i = len(p.ack.Items)
+			p.items[seq] = i
+			p.ack.Items = append(p.ack.Items, spapi.OrderAcknowledgementItem{
+				ItemSequenceNumber:      ordered.ItemSequenceNumber,
+				AmazonProductIdentifier: ordered.AmazonProductIdentifier,
+				VendorProductIdentifier: ordered.VendorProductIdentifier,
+				OrderedQuantity:         ordered.OrderedQuantity,
+				NetCost:                 ordered.NetCost,
+			})
+		}
+		p.ack.Items[i].ItemAcknowledgements = append(p.ack.Items[i].ItemAcknowledgements, item)
+	}
+
+	acks := make([]spapi.OrderAcknowledgement, 0, len(orders))
+	for _, p := range orders {
+		var undecided []string
+		for _, it := range p.order.OrderDetails.Items {
+			if p.decided[it.ItemSequenceNumber] < it.OrderedQuantity.Amount {
+				undecided = append(undecided, fmt.Sprintf("%s (%d of %d)", it.ItemSequenceNumber, p.decided[it.ItemSequenceNumber], it.OrderedQuantity.Amount))
+			}
+		}
+		if len(undecided) > 0 {
+			invalid = append(invalid, decisionError{Line: p.line, PurchaseOrderNumber: p.ack.PurchaseOrderNumber, Detail: "quantity without a decision for item " + strings.Join(undecided, ", ")})
+			continue
+		}
+		acks = append(acks, p.ack)
+	}
+	for _, v := range ackCaseViolations(packs, acks) {
+		invalid = append(invalid, decisionError{Line: byPO[v.PurchaseOrderNumber].line, PurchaseOrderNumber: v.PurchaseOrderNumber, ItemSequenceNumber: v.ItemSequenceNumber, Detail: v.Error()})
+	}
+	if len(invalid) > 0 {
+		sort.SliceStable(invalid, func(i, j int) bool { return invalid[i].Line < invalid[j].Line })
+		return nil, invalid, nil
+	}
+	return acks, nil, nil
+}
+
+// printDecisionErrors renders the invalid lines of a decision file for humans.
+func printDecisionErrors(invalid []decisionError) {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tPO NUMBER\tITEM\tDETAIL")
+	for _, e := range invalid {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", e.Line, e.PurchaseOrderNumber, e.ItemSequenceNumber, e.Detail)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for _, line := range lines[1:] {
+		logger.Log(utils.LevelError, line, "")
+	}
+	logger.Log(utils.LevelError, "Invalid lines: ", fmt.Sprintf("%d (nothing was submitted)", len(invalid)))
+}
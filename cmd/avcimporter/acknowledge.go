@@ -0,0 +1,371 @@
+// cmd/avcimporter/acknowledge.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+ackBatch is the outcome of one submitAcknowledgement request, emitted by
+acknowledge.
+
+Fields:
+  - Batch:         1-based batch number.
+  - Orders:        PO numbers in the batch.
+  - Items:         Item lines in the batch.
+  - Status:        "valid" (dry run), "accepted", "rejected", "submitted", "oversize" or "invalid" (case pack violation).
+  - TransactionID: SP‑API transaction of the submission.
+  - Detail:        SP‑API errors, if any.
+*/
+type ackBatch struct {
+	Batch         int      `json:"batch"`
+	Orders        []string `json:"orders"`
+	Items         int      `json:"items"`
+	Status        string   `json:"status"`
+	TransactionID string   `json:"transactionId,omitempty"`
+	Detail        string   `json:"detail,omitempty"`
+}
+
+/*
+cmdAcknowledge implements `avcimporter acknowledge [PO number...]`: it accepts
+every line of the pending purchase orders in full and submits the
+acknowledgements through the Vendor Orders API, chunked into batches that stay
+within the request limits. A PO is pending while its state is New and no
+acknowledgement of it was sent or accepted; named POs are acknowledged
+regardless of their lifecycle.
+
+With --decisions, the acknowledgements come from a CSV file instead, with one
+row per decided quantity of an order line (columns purchaseOrderNumber,
+itemSequenceNumber, acknowledgementCode, acknowledgedQuantity and optionally
+unitOfMeasure, scheduledShipDate, scheduledDeliveryDate, rejectionReason).
+The file is validated as a whole: if any row is invalid, every invalid row is
+reported and nothing is submitted.
+
+With catalog.casePacks set, accepted quantities must be whole cases of the
+products listed there. A decision file with a violation is rejected as a
+whole; a pending order with one is held back and reported as invalid, with
+the nearest valid quantities, so it can be decided with --decisions.
+
+With windows.leadTimes set, accepting an order whose ship or delivery window
+closes before the warehouse's lead time allows is warned about; the
+acknowledgement is still submitted.
+
+Each batch is a separate transaction recorded on the lifecycle of its POs. A
+failed batch does not stop the others; rerunning the command resubmits only
+the POs still pending.
+
+Flags:
+  - --decisions:  CSV file ("-" for stdin) with per-line decisions.
+  - --dry-run:    Show the batches without submitting.
+  - --wait:       Wait for Amazon to process each batch (default: true).
+  - --timeout:    How long to wait per batch (default: 5m).
+  - --max-orders: Acknowledgements per batch (default: 50).
+  - --max-items:  Item lines per batch (default: 1000).
+  - --max-bytes:  Request body size per batch (default: 1 MiB).
+*/
+func cmdAcknowledge(args []string) int {
+	fs := flag.NewFlagSet("acknowledge", flag.ContinueOnError)
+	decisions := fs.String("decisions", "", "CSV file with per-line acknowledgement decisions (- for stdin)")
+	dryRun := fs.Bool("dry-run", false, "Show the batches without submitting")
+	wait := fs.Bool("wait", true, "Wait for each batch to be processed")
+	timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait per batch")
+	maxOrders := fs.Int("max-orders", spapi.DefaultAckLimits.MaxOrders, "Acknowledgements per batch")
+	maxItems := fs.Int("max-items", spapi.DefaultAckLimits.MaxItems, "Item lines per batch")
+	maxBytes := fs.Int("max-bytes", spapi.DefaultAckLimits.MaxBytes, "Request body size per batch")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *maxOrders <= 0 || *maxItems <= 0 || *maxBytes <= 0 {
+		logger.Log(utils.LevelError, "Invalid limits: ", "--max-orders, --max-items and --max-bytes must be positive")
+		return 2
+	}
+	if *decisions != "" && fs.NArg() > 0 {
+		logger.Log(utils.LevelError, "Invalid arguments: ", "PO numbers cannot be combined with --decisions")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	packs, err := loadCasePacks(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load case packs: ", err.Error())
+		return 1
+	}
+	var acks []spapi.OrderAcknowledgement
+	var held []ackBatch
+	if *decisions != "" {
+		var invalid []decisionError
+		acks, invalid, err = readDecisions(cfg, packs, *decisions)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to read decisions: ", err.Error())
+			return 1
+		}
+		if len(invalid) > 0 {
+			if output.IsJSON() {
+				output.Emit(map[string]interface{}{"command": "acknowledge", "success": false, "batches": []ackBatch{}, "invalid": invalid})
+			} else {
+				printDecisionErrors(invalid)
+			}
+			return 1
+		}
+	} else {
+		acks, err = pendingAcknowledgements(cfg, fs.Args())
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to collect pending orders: ", err.Error())
+			return 1
+		}
+		acks, held = holdCaseViolations(packs, acks)
+	}
+	risks := ackWindowRisks(cfg, acks)
+	if !output.IsJSON() {
+		for _, r := range risks {
+			logger.Log(utils.LevelWarn, "Window at risk: ", r.String())
+		}
+	}
+	chunks, oversize := spapi.ChunkAcknowledgements(acks, spapi.AckLimits{MaxOrders: *maxOrders, MaxItems: *maxItems, MaxBytes: *maxBytes})
+
+	var batches []ackBatch
+	for i, chunk := range chunks {
+		batches = append(batches, newAckBatch(i+1, chunk, "valid"))
+	}
+	for _, a := range oversize {
+		b := newAckBatch(0, []spapi.OrderAcknowledgement{a}, "oversize")
+		b.Detail = "exceeds the batch limits on its own"
+		batches = append(batches, b)
+	}
+	batches = append(batches, held...)
+
+	if !*dryRun && len(chunks) > 0 {
+		closeAudit, err := openAudit(cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to open audit log: ", err.Error())
+			return 1
+		}
+		defer closeAudit()
+		ctx := context.Background()
+		token, err := fetchOAuthToken(ctx, cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to fetch OAuth2 token: ", err.Error())
+			return 1
+		}
+		client := newSPAPIClient(cfg, token)
+		for i, chunk := range chunks {
+			b := &batches[i]
+			b.Status, b.TransactionID, b.Detail = submitAcknowledgements(ctx, client, chunk, *wait, *timeout)
+			trackAcknowledgements(cfg, chunk, *b)
+		}
+	}
+
+	failed := 0
+	for _, b := range batches {
+		if b.Status == "rejected" || b.Status == "oversize" || b.Status == "invalid" {
+			failed++
+		}
+	}
+	if output.IsJSON() {
+		if batches == nil {
+			batches = []ackBatch{}
+		}
+		if risks == nil {
+			risks = []lifecycle.WindowRisk{}
+		}
+		output.Emit(map[string]interface{}{"command": "acknowledge", "success": failed == 0, "batches": batches, "warnings": risks})
+	} else {
+		printAckBatches(batches, failed)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+/*
+pendingAcknowledgements builds a full acceptance for every pending stored
+order, or for the named POs, in storage order (newest first).
+*/
+func pendingAcknowledgements(cfg *config.Config, poNumbers []string) ([]spapi.OrderAcknowledgement, error) {
+	var orders []spapi.PurchaseOrder
+	if len(poNumbers) > 0 {
+		for _, po := range poNumbers {
+			o, err := storage.LoadOrder(cfg.Storage.SavePath, po)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, o)
+		}
+	} else {
+		all, err := storage.LoadOrders(cfg.Storage.SavePath)
+		if err != nil {
+			return nil, err
+		}
+		store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+		for _, o := range all {
+			if o.PurchaseOrderState != "New" {
+				continue
+			}
+			lc, _, err := store.Get(o.PurchaseOrderNumber)
+			if err != nil {
+				return nil, err
+			}
+			if !lc.Satisfied(lifecycle.Acknowledgment) {
+				orders = append(orders, o)
+			}
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	acks := make([]spapi.OrderAcknowledgement, 0, len(orders))
+	for _, o := range orders {
+		d := o.OrderDetails
+		if d.SellingParty == nil {
+			return nil, fmt.Errorf("order %s has no selling party", o.PurchaseOrderNumber)
+		}
+		windows := map[string]time.Time{}
+		addWindow(windows, "shipWindow", d.ShipWindow)
+		addWindow(windows, "deliveryWindow", d.DeliveryWindow)
+		ack := spapi.OrderAcknowledgement{PurchaseOrderNumber: o.PurchaseOrderNumber, SellingParty: *d.SellingParty, AcknowledgementDate: now}
+		for _, it := range d.Items {
+			item := spapi.ItemAcknowledgement{AcknowledgementCode: spapi.AckAccepted, AcknowledgedQuantity: it.OrderedQuantity}
+			if t, ok := windows["shipWindowStart"]; ok {
+				item.ScheduledShipDate = &t
+			}
+			if t, ok := windows["deliveryWindowStart"]; ok {
+				item.ScheduledDeliveryDate = &t
+			}
+			ack.Items = append(ack.Items, spapi.OrderAcknowledgementItem{
+				ItemSequenceNumber:      it.ItemSequenceNumber,
+				AmazonProductIdentifier: it.AmazonProductIdentifier,
+				VendorProductIdentifier: it.VendorProductIdentifier,
+				OrderedQuantity:         it.OrderedQuantity,
+				NetCost:                 it.NetCost,
+				ItemAcknowledgements:    []spapi.ItemAcknowledgement{item},
+			})
+		}
+		acks = append(acks, ack)
+	}
+	return acks, nil
+}
+
+// newAckBatch describes a chunk of acknowledgements.
+func newAckBatch(n int, chunk []spapi.OrderAcknowledgement, status string) ackBatch {
+	b := ackBatch{Batch: n, Orders: []string{}, Status: status}
+	for _, a := range chunk {
+		b.Orders = append(b.Orders, a.PurchaseOrderNumber)
+		b.Items += len(a.Items)
+	}
+	return b
+}
+
+/*
+submitAcknowledgements submits one batch and, with wait, polls its transaction.
+
+Returns:
+  - The batch status: "accepted", "rejected" or "submitted" (not waited for).
+  - The transaction ID ("" if the submission itself failed).
+  - The errors reported by SP‑API, if any.
+*/
+func submitAcknowledgements(ctx context.Context, client *spapi.Client, acks []spapi.OrderAcknowledgement, wait bool, timeout time.Duration) (string, string, string) {
+	id, err := client.SubmitAcknowledgements(ctx, acks)
+	if err != nil {
+		return "rejected", "", err.Error()
+	}
+	if !wait {
+		return "submitted", id, ""
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	status, err := client.WaitForTransaction(waitCtx, id, 5*time.Second)
+	if err != nil {
+		return "submitted", id, err.Error()
+	}
+	if status.Status == spapi.TransactionSuccess {
+		return "accepted", id, ""
+	}
+	msgs := make([]string, len(status.Errors))
+	for i, e := range status.Errors {
+		msgs[i] = e.Error()
+	}
+	return "rejected", id, strings.Join(msgs, "; ")
+}
+
+/*
+trackAcknowledgements records a submitted batch on the lifecycle of each of
+its POs, referencing the batch's transaction, with the units accepted per line.
+*/
+func trackAcknowledgements(cfg *config.Config, acks []spapi.OrderAcknowledgement, b ackBatch) {
+	d := lifecycle.Document{Kind: lifecycle.Acknowledgment, Direction: "outbound", Reference: b.TransactionID, Status: lifecycle.Sent, Detail: b.Detail}
+	switch b.Status {
+	case "accepted":
+		d.Status = lifecycle.Accepted
+	case "rejected":
+		d.Status = lifecycle.Rejected
+	}
+	if d.Reference == "" {
+		d.Reference = fmt.Sprintf("batch %d", b.Batch)
+	}
+	for _, a := range acks {
+		d.Lines = nil
+		for _, it := range a.Items {
+			units := 0
+			for _, ia := range it.ItemAcknowledgements {
+				if ia.AcknowledgementCode == spapi.AckAccepted {
+					q := ia.AcknowledgedQuantity
+					if q.UnitSize == 0 {
+						q.UnitSize = it.OrderedQuantity.UnitSize
+					}
+					units += orderEaches(q)
+				}
+			}
+			d.Lines = append(d.Lines, lifecycle.Line{Item: it.ItemSequenceNumber, ASIN: it.AmazonProductIdentifier, Units: units})
+		}
+		trackDocument(cfg, a.PurchaseOrderNumber, d)
+	}
+}
+
+// printAckBatches renders acknowledge results for humans.
+func printAckBatches(batches []ackBatch, failed int) {
+	if len(batches) == 0 {
+		logger.PrintColored("No pending purchase orders to acknowledge.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BATCH\tORDERS\tITEMS\tSTATUS\tTRANSACTION\tDETAIL")
+	for _, r := range batches {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", r.Batch, strings.Join(r.Orders, ", "), r.Items, r.Status, r.TransactionID, r.Detail)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		switch batches[i].Status {
+		case "rejected", "oversize", "invalid":
+			color = "#FF0000"
+		case "accepted":
+			color = "#32CD32"
+		}
+		logger.PrintColored(line, "", color)
+	}
+	if failed > 0 {
+		logger.Log(utils.LevelError, "Failed batches: ", fmt.Sprintf("%d of %d (rerun to resubmit the pending orders)", failed, len(batches)))
+		return
+	}
+	logger.PrintColored("Batches: ", fmt.Sprint(len(batches)), "#32CD32")
+}
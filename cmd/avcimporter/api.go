@@ -0,0 +1,594 @@
+// cmd/avcimporter/api.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/buildinfo"
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/credentials"
+	"github.com/heinrichb/avcimporter/pkg/dedupe"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/workpool"
+)
+
+/*
+apiSigner signs requests to api.baseUrl with SigV4 when api.sigv4 is enabled
+(see sigv4.Transport); applySigV4 configures it. Responses are requested
+gzip-compressed (see spapi.Gzip).
+*/
+var apiSigner = &sigv4.Transport{Base: &spapi.Gzip{Logger: logger}, Logger: logger}
+
+/*
+apiThrottle paces every LWA and SP‑API request per operation and backs off
+when Amazon throttles (see spapi.Throttle); applyThrottle configures it from
+api.throttle.
+*/
+var apiThrottle = &spapi.Throttle{Base: apiSigner, Logger: logger}
+
+/*
+apiHTTP sends every LWA and SP‑API request through apiThrottle and apiSigner.
+With --capture-http the signer sends through a spapi.Capture recording each
+exchange.
+*/
+var apiHTTP = &http.Client{Transport: apiThrottle}
+
+// awsRegions maps SP‑API regions to the AWS regions their endpoints sign for.
+var awsRegions = map[string]string{"NA": "us-east-1", "EU": "eu-west-1", "FE": "us-west-2"}
+
+/*
+applySigV4 configures apiSigner from cfg.API.SigV4. The signing keys come from
+the named shared credentials profile or the default AWS chain; with a roleArn
+they are only used to assume that role (with the external ID, if set). The
+credentials are retrieved on the first signed request and again shortly before
+they expire, so a daemon keeps signing after the role session's hour is up.
+
+Returns:
+  - An error if signing is enabled but the region cannot be determined.
+*/
+func applySigV4(cfg *config.Config) error {
+	s := cfg.API.SigV4
+	if !s.Enabled {
+		apiSigner.Configure(sigv4.Settings{})
+		return nil
+	}
+	u, err := url.Parse(cfg.API.BaseURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("api.sigv4 needs an absolute api.baseUrl, got %q", cfg.API.BaseURL)
+	}
+	region := s.Region
+	if region == "" {
+		if region = awsRegions[apiRegion(cfg)]; region == "" {
+			return fmt.Errorf("api.sigv4.region is required for %s", u.Host)
+		}
+	}
+	provider := sigv4.Default(s.Profile)
+	if s.RoleARN != "" {
+		provider = &sigv4.AssumeRole{Source: provider, RoleARN: s.RoleARN, ExternalID: s.ExternalID, SessionName: s.SessionName, Region: region}
+	}
+	apiSigner.Configure(sigv4.Settings{Host: u.Host, Region: region, Service: "execute-api", Credentials: &sigv4.Cache{Provider: provider, Logger: logger}})
+	return nil
+}
+
+/*
+applyThrottle configures apiThrottle from cfg.API.Throttle. Operations that
+stay throttled beyond alertAfter are passed to hooks subscribed to "throttled".
+*/
+func applyThrottle(cfg *config.Config) {
+	t := cfg.API.Throttle
+	apiThrottle.Configure(spapi.ThrottleSettings{
+		Rate:          t.Rate,
+		CooldownAfter: t.CooldownAfter,
+		Cooldown:      time.Duration(t.Cooldown),
+		AlertAfter:    time.Duration(t.AlertAfter),
+		OnAlert: func(ctx context.Context, a spapi.ThrottleAlert) {
+			err := runHooks(ctx, hooks.Event{Event: hooks.Throttled, Type: "throttle", Fields: map[string]string{
+				"operation": a.Operation,
+				"since":     a.Since.UTC().Format(time.RFC3339),
+				"responses": strconv.Itoa(a.Responses),
+				"rate":      strconv.FormatFloat(a.Rate, 'f', 3, 64),
+			}})
+			if err != nil {
+				logger.LogContext(ctx, utils.LevelError, "Throttling notification failed: ", err.Error())
+			}
+		},
+	})
+}
+
+/*
+fetchOAuthToken requests an OAuth2 token from Amazon SP‑API. The refresh token
+stored by `reauthorize` (or returned by an earlier refresh) wins over
+api.auth.refreshToken; a new refresh token in the response is stored. A
+refresh token Amazon rejects with invalid_grant yields errInvalidGrant.
+*/
+func fetchOAuthToken(ctx context.Context, cfg *config.Config) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "spapi.token", "url", cfg.API.TokenURL)
+	defer func() { span.End(err) }()
+
+	store := credentials.Open(cfg.Storage.CredentialsPath)
+	refresh, found, err := store.Get(lwaTokenName(cfg))
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		refresh = cfg.API.Auth.RefreshToken
+	}
+	requestBody := map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": refresh,
+		"client_id":     cfg.API.Auth.ClientID,
+		"client_secret": cfg.API.Auth.ClientSecret,
+	}
+
+	jsonData, _ := json.Marshal(requestBody)
+	resp, err := doWithRetry(ctx, cfg, "fetch token", func(ctx context.Context) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, "POST", cfg.API.TokenURL, bytes.NewReader(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	})
+	if err != nil {
+		var status *utils.StatusError
+		if errors.As(err, &status) && strings.Contains(status.Body, "invalid_grant") {
+			return "", fmt.Errorf("%w; run `avcimporter reauthorize` to authorize the app again: %v", errInvalidGrant, err)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	span.SetAttr("http.status_code", resp.StatusCode)
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+		logger.PrintColoredContext(ctx, "OAuth2 Token Response: ", fmt.Sprintf("%v", result), "#00FFFF")
+	}
+
+	if rotated, _ := result["refresh_token"].(string); rotated != "" && rotated != refresh {
+		if err := store.Set(lwaTokenName(cfg), rotated); err != nil {
+			return "", fmt.Errorf("failed to store the new refresh token: %w", err)
+		}
+		logger.PrintColoredContext(ctx, "Stored the rotated LWA refresh token in: ", store.Path(), "#32CD32")
+	}
+	access, _ := result["access_token"].(string)
+	if access == "" {
+		return "", fmt.Errorf("token response has no access_token")
+	}
+	return access, nil
+}
+
+/*
+fetchFromAPI requests data from the configured SP‑API endpoint.
+It uses the `endpointUrl` field in the config to determine which endpoint to call.
+The response body is passed to handle as a stream rather than read into memory,
+so backfills returning tens of megabytes are processed as they arrive.
+
+The parameters of api.query (and --query) are added to every request; query
+takes precedence over them.
+
+With etags the request is conditional: the ETag of the last response to the
+same URL that handle consumed is sent as If-None-Match, and when Amazon
+answers 304 Not Modified handle is not called, as there is nothing new.
+
+Parameters:
+  - ctx:    Context bounding the request.
+  - cfg:    The application configuration, containing API details.
+  - token:  The OAuth2 bearer token for authentication.
+  - query:  Extra query parameters (e.g. createdAfter); may be nil.
+  - etags:  Where ETags are kept (under "etag:<url>"); nil sends unconditional requests.
+  - handle: Consumes the response body.
+
+Returns:
+  - An error if the request fails, returns a non-200 status or handle fails.
+*/
+func fetchFromAPI(ctx context.Context, cfg *config.Config, token string, query url.Values, etags *checkpoint.Store, handle func(io.Reader) error) (err error) {
+	fullURL := cfg.API.BaseURL + cfg.API.EndpointURL
+	if len(cfg.API.Query) > 0 {
+		merged := url.Values{}
+		for k, v := range cfg.API.Query {
+			merged.Set(k, v)
+		}
+		for k, v := range query {
+			merged[k] = v
+		}
+		query = merged
+	}
+	if len(query) > 0 {
+		sep := "?"
+		if strings.Contains(fullURL, "?") {
+			sep = "&"
+		}
+		fullURL += sep + query.Encode()
+	}
+	ctx, span := tracing.Start(ctx, "spapi.request", "url", fullURL)
+	defer func() { span.End(err) }()
+
+	logger.PrintColoredContext(ctx, "Fetching data from: ", fullURL, "#32CD32")
+
+	etagKey := checkpoint.Key("etag", fullURL)
+	var etag string
+	if etags != nil {
+		cp, found, err := etags.Load(etagKey)
+		if err != nil {
+			return err
+		}
+		if found {
+			etag = cp.Value
+		}
+	}
+	resp, err := doWithRetry(ctx, cfg, "fetch data from API", func(ctx context.Context) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		return req
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified {
+		logger.PrintColoredContext(ctx, "Not modified since the last request: ", fullURL, "#00FFFF")
+		return nil
+	}
+
+	body := &countingReader{r: resp.Body}
+	err = handle(body)
+	span.SetAttr("http.response_bytes", body.n)
+	if err != nil {
+		return err
+	}
+	if tag := resp.Header.Get("ETag"); etags != nil && tag != "" && tag != etag {
+		if err := etags.Save(etagKey, tag); err != nil {
+			return err
+		}
+	}
+	if !logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+		logger.PrintColoredContext(ctx, "Data fetched successfully. Use -v for details.", "", "#00FFFF")
+	}
+	return nil
+}
+
+/*
+doWithRetry sends the request built by newReq until it returns 200 OK (or
+304 Not Modified to a conditional request), retrying per the retry.spapi
+policy. A fresh request is built for every attempt so request bodies can be
+replayed.
+
+Returns:
+  - The 200 or 304 response; the caller closes its body.
+  - An error if every attempt fails; unexpected statuses are *utils.StatusError.
+*/
+func doWithRetry(ctx context.Context, cfg *config.Config, op string, newReq func(ctx context.Context) *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	err := utils.Retry(ctx, retryPolicy(cfg.Retry.SPAPI, "spapi"), func(ctx context.Context) error {
+		req := newReq(ctx)
+		req.Header.Set("User-Agent", userAgent(cfg))
+		logger.Debugf(utils.ModuleSPAPI, "Request: ", "%s %s", req.Method, req.URL)
+		r, err := apiHTTP.Do(req)
+		if err != nil {
+			return err
+		}
+		logger.Debugf(utils.ModuleSPAPI, "Response: ", "%s %s", r.Status, r.Header.Get("x-amzn-RequestId"))
+		if r.StatusCode != http.StatusOK && (r.StatusCode != http.StatusNotModified || req.Header.Get("If-None-Match") == "") {
+			body, _ := io.ReadAll(r.Body)
+			r.Body.Close()
+			return &utils.StatusError{Op: op, StatusCode: r.StatusCode, Body: string(body)}
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+/*
+newSPAPIClient returns an SP‑API client for the configured region that
+retries per the retry.spapi policy.
+*/
+func newSPAPIClient(cfg *config.Config, token string) *spapi.Client {
+	return &spapi.Client{BaseURL: cfg.API.BaseURL, Token: token, HTTP: apiHTTP, Retry: retryPolicy(cfg.Retry.SPAPI, "spapi"), UserAgent: userAgent(cfg), Logger: logger}
+}
+
+/*
+userAgent returns the User-Agent sent to SP‑API: api.userAgent if set,
+otherwise built from api.appName, api.appVersion and the build metadata.
+*/
+func userAgent(cfg *config.Config) string {
+	if cfg.API.UserAgent != "" {
+		return cfg.API.UserAgent
+	}
+	return buildinfo.UserAgent(cfg.API.AppName, cfg.API.AppVersion)
+}
+
+/*
+storeOrders decodes purchase orders from an SP‑API response stream and hands
+each to processOrder as soon as it is decoded, on processing.workers workers,
+so only a few orders are held in memory at a time. Each order is written to
+the storage directory (named by the "order" template); versions of one PO are
+processed in the order they arrive. Responses from endpoints that do not
+return purchase orders store nothing.
+
+A version of an order already marked in seen (same PO number and change time,
+see dedupe.OrderKey) is skipped; a changed version is stored and marked. A nil
+seen stores every order. With storage.dedupeChannels a PO already imported
+from an EDI 850 is skipped as well.
+
+With documents.pdf set, the PDF printouts of each stored order are written
+next to it (storeDocuments).
+
+Exports with an orderBy receive the documents of the response once every
+order is processed, sorted (see flushOrdered), including those of the orders
+processed before one failed.
+
+With storage.outputFormat "parquet" the orders stored from the response are
+also written to the normalized Parquet datasets (storage.SaveOrdersParquet)
+once the response is complete, and each file is passed to the hooks as
+"generated" with type "parquet".
+*/
+func storeOrders(ctx context.Context, cfg *config.Config, body io.Reader, seen dedupe.Deduper) (page orderPage, err error) {
+	_, span := tracing.Start(ctx, "storage.write")
+	defer func() {
+		span.SetAttr("orders", page.Stored)
+		span.SetAttr("duplicates", page.Duplicates)
+		span.End(err)
+	}()
+
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return page, err
+	}
+	var channels dedupe.Deduper
+	if cfg.Storage.DedupeChannels {
+		channels = newDeduper(cfg)
+	}
+	switch cfg.Storage.OutputFormat {
+	case "json", "parquet":
+	default:
+		return page, fmt.Errorf("unknown storage.outputFormat %q", cfg.Storage.OutputFormat)
+	}
+	result := output.FromContext(ctx)
+	// Exports with an orderBy hold the documents of the response until all are processed.
+	seqCtx, ordered := hooks.WithSequence(ctx)
+	pool := workpool.New(seqCtx, cfg.Processing.Workers)
+	// mu guards what the workers report: page.Newest, page.Stored and batch,
+	// which is kept in response order by the sequence number of each order.
+	var mu sync.Mutex
+	batch := map[int]spapi.PurchaseOrder{}
+	seq := 0
+	page.Next, err = spapi.StreamPurchaseOrders(body, func(order spapi.PurchaseOrder) error {
+		if c := dedupe.ChangedAt(order); c.After(page.NewestChange) {
+			page.NewestChange = c
+		}
+		key := dedupe.OrderKey(order)
+		if seen != nil {
+			dup, err := seen.Seen(key)
+			if err != nil {
+				return err
+			}
+			if dup {
+				page.Duplicates++
+				return nil
+			}
+		}
+		canonical := model.FromPurchaseOrder(order)
+		if channels != nil {
+			other, err := dedupe.OtherChannel(channels, canonical)
+			if err != nil {
+				return err
+			}
+			if other != "" {
+				page.CrossChannel++
+				if logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+					logger.PrintColoredContext(ctx, "Skipped order imported via "+other+": ", order.PurchaseOrderNumber, "#00FFFF")
+				}
+				return nil
+			}
+		}
+		n := seq
+		seq++
+		return pool.Submit(order.PurchaseOrderNumber, func(ctx context.Context) error {
+			path, err := processOrder(ctx, cfg, namer, order, canonical)
+			if err != nil {
+				return err
+			}
+			result.AddFiles(path)
+			result.AddPONumbers(order.PurchaseOrderNumber)
+			if seen != nil {
+				if err := seen.Mark(key); err != nil {
+					return err
+				}
+			}
+			if channels != nil {
+				if err := channels.Mark(dedupe.ChannelKey(model.SourceAPI, order.PurchaseOrderNumber)); err != nil {
+					return err
+				}
+			}
+			mu.Lock()
+			if cfg.Storage.OutputFormat == "parquet" {
+				batch[n] = order
+			}
+			if d := order.OrderDetails.PurchaseOrderDate; d.After(page.Newest) {
+				page.Newest = d
+			}
+			page.Stored++
+			mu.Unlock()
+			if logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+				logger.PrintColoredContext(ctx, "Stored order: ", order.PurchaseOrderNumber, "#00FFFF")
+			}
+			return nil
+		})
+	})
+	// A failed order stops the stream with workpool.ErrStopped; report the failure itself.
+	if werr := pool.Wait(); werr != nil {
+		err = werr
+	}
+	err = errors.Join(err, flushOrdered(ctx, ordered))
+	if err != nil {
+		return orderPage{}, err
+	}
+	if page.Stored > 0 {
+		logger.PrintColoredContext(ctx, "Stored purchase orders: ", fmt.Sprint(page.Stored), "#32CD32")
+	}
+	if len(batch) > 0 {
+		seqs := make([]int, 0, len(batch))
+		for n := range batch {
+			seqs = append(seqs, n)
+		}
+		sort.Ints(seqs)
+		orders := make([]spapi.PurchaseOrder, len(seqs))
+		for i, n := range seqs {
+			orders[i] = batch[n]
+		}
+		paths, err := storage.SaveOrdersParquet(savePath(ctx, cfg), orders, time.Now(), permissions())
+		result.AddFiles(paths...)
+		if err != nil {
+			return orderPage{}, fmt.Errorf("failed to write parquet output: %w", err)
+		}
+		for _, path := range paths {
+			if logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+				logger.PrintColoredContext(ctx, "Wrote parquet file: ", path, "#00FFFF")
+			}
+			if err := runHooks(ctx, hooks.Event{Event: hooks.Generated, Path: path, Type: "parquet"}); err != nil {
+				return orderPage{}, err
+			}
+		}
+	}
+	if page.Duplicates > 0 && logger.Enabled(utils.ModuleSPAPI, utils.LevelDebug) {
+		logger.PrintColoredContext(ctx, "Skipped already imported orders: ", fmt.Sprint(page.Duplicates), "#00FFFF")
+	}
+	if page.CrossChannel > 0 {
+		logger.LogContext(ctx, utils.LevelWarn, "Skipped orders already imported via EDI: ", fmt.Sprint(page.CrossChannel))
+	}
+	return page, nil
+}
+
+/*
+processOrder stores one purchase order of a response and passes it on: a
+changed version is reported against the one it replaces, the order is given
+to the hooks (and so the exports) as "generated", its documents are stored
+and its lifecycle starts. The steps are retried together under
+processing.retry; the stored version to compare against is read once before.
+
+Returns:
+  - The path of the stored order.
+  - The error of the last attempt if every attempt failed.
+*/
+func processOrder(ctx context.Context, cfg *config.Config, namer *storage.Namer, order spapi.PurchaseOrder, canonical model.Order) (path string, err error) {
+	previous, found, err := storage.StoredOrder(cfg.Storage.SavePath, namer, order.PurchaseOrderNumber)
+	if err != nil {
+		logger.LogContext(ctx, utils.LevelWarn, "Failed to read stored order: ", err.Error())
+	}
+	err = utils.Retry(ctx, retryPolicy(cfg.Processing.Retry, "order "+order.PurchaseOrderNumber), func(ctx context.Context) error {
+		path, err = storage.SaveOrder(savePath(ctx, cfg), namer, order)
+		if err != nil {
+			return fmt.Errorf("failed to store order %s: %w", order.PurchaseOrderNumber, err)
+		}
+		if found {
+			if err := reportOrderChanges(ctx, path, previous, order); err != nil {
+				return err
+			}
+		}
+		if err := runHooks(ctx, hooks.Event{Event: hooks.Generated, Path: path, Type: storage.TypeOrder, Fields: map[string]string{"poNumber": order.PurchaseOrderNumber}, Order: &canonical}); err != nil {
+			return err
+		}
+		if err := storeDocuments(ctx, cfg, namer, canonical); err != nil {
+			return err
+		}
+		trackOrder(cfg, canonical, lifecycle.Document{Kind: lifecycle.PurchaseOrder, Direction: "inbound", Reference: finalPath(ctx, path), Status: lifecycle.Received})
+		return nil
+	})
+	return path, err
+}
+
+/*
+orderPage summarizes one response handled by storeOrders.
+
+Fields:
+  - Newest:       Purchase order date of the newest stored order (zero if none).
+  - NewestChange: Latest change time (dedupe.ChangedAt) of all orders in the response, duplicates included.
+  - Next:         Token of the next page ("" on the last page).
+  - Stored:       Orders written.
+  - Duplicates:   Orders skipped as already imported.
+  - CrossChannel: Orders skipped as already imported from an EDI 850 (storage.dedupeChannels).
+*/
+type orderPage struct {
+	Newest       time.Time
+	NewestChange time.Time
+	Next         string
+	Stored       int
+	Duplicates   int
+	CrossChannel int
+}
+
+// newDeduper opens the deduplication store configured in storage.dedupePath.
+func newDeduper(cfg *config.Config) dedupe.Deduper {
+	return dedupe.NewFile(cfg.Storage.DedupePath, time.Duration(cfg.Storage.DedupeRetention), permissions())
+}
+
+/*
+reportOrderChanges compares a re-fetched order with the version it replaced
+at path and, if they differ, logs every change and runs the hooks subscribed
+to "changed". The event's "changes" field holds the []spapi.Change as JSON.
+*/
+func reportOrderChanges(ctx context.Context, path string, previous, order spapi.PurchaseOrder) error {
+	changes := spapi.Diff(previous, order)
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, c := range changes {
+		logger.LogContext(ctx, utils.LevelWarn, "Order "+order.PurchaseOrderNumber+" changed: ", c.String())
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if !slices.Contains(kinds, c.Kind) {
+			kinds = append(kinds, c.Kind)
+		}
+	}
+	return runHooks(ctx, hooks.Event{Event: hooks.Changed, Path: path, Type: storage.TypeOrder, Fields: map[string]string{
+		"poNumber": order.PurchaseOrderNumber,
+		"kinds":    strings.Join(kinds, ","),
+		"changes":  string(data),
+	}})
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,259 @@
+// cmd/avcimporter/backfill.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/dedupe"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// tokenLifetime is how long an LWA access token is reused before a refresh;
+// tokens are valid for an hour.
+const tokenLifetime = 45 * time.Minute
+
+/*
+backfillWindow is the outcome of one window, emitted by backfill.
+
+Fields:
+  - Stored:    Orders written (new or changed since they were stored).
+  - Unchanged: Orders skipped because this version was already imported.
+  - Pages:     Requests made for the window.
+*/
+type backfillWindow struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Stored    int       `json:"stored"`
+	Unchanged int       `json:"unchanged"`
+	Pages     int       `json:"pages"`
+}
+
+/*
+cmdBackfill implements `avcimporter backfill --from <date> [--to <date>]`: it
+imports the purchase order history of an account by requesting the SP‑API in
+consecutive windows (createdAfter/createdBefore), following every page, and
+pausing between requests to stay under the rate limit. Versions already
+imported (see dedupe.OrderKey) are skipped; the rest are stored and tracked
+like in a normal run.
+
+After each window the progress is saved under the "spapi:<REGION>:backfill"
+checkpoint, so --resume continues an interrupted backfill. At the end the
+regular import checkpoint is advanced to the newest order if it is behind.
+
+Flags:
+  - --from:   First day to import (required).
+  - --to:     Last day to import (default: now).
+  - --window: Length of each window (default: 168h).
+  - --pause:  Delay between requests (default: 2s).
+  - --resume: Start after the last completed window of a previous backfill.
+*/
+func cmdBackfill(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "First day to import (YYYY-MM-DD or RFC 3339)")
+	toFlag := fs.String("to", "", "Last day to import (default: now)")
+	window := fs.Duration("window", 7*24*time.Hour, "Length of each request window")
+	pause := fs.Duration("pause", 2*time.Second, "Delay between requests")
+	resume := fs.Bool("resume", false, "Continue after the last completed window of a previous backfill")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	from, err := parseDateFlag(*fromFlag, false)
+	if err != nil || from.IsZero() || fs.NArg() > 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter backfill --from <date> [--to <date>] [--window 168h] [--pause 2s] [--resume]")
+		return 2
+	}
+	to, err := parseDateFlag(*toFlag, true)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid --to: ", err.Error())
+		return 2
+	}
+	if to.IsZero() || to.After(time.Now()) {
+		to = time.Now()
+	}
+	if !to.After(from) || *window <= 0 {
+		logger.Log(utils.LevelError, "Invalid range: ", "--to must be after --from and --window positive")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	if !strings.HasSuffix(strings.SplitN(cfg.API.EndpointURL, "?", 2)[0], "/purchaseOrders") {
+		logger.Log(utils.LevelError, "Backfill needs the purchase orders endpoint, not: ", cfg.API.EndpointURL)
+		return 1
+	}
+	if err := installHooks(cfg); err != nil {
+		logger.Log(utils.LevelError, "Invalid export: ", err.Error())
+		return 1
+	}
+	closeAudit, err := openAudit(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to open audit log: ", err.Error())
+		return 1
+	}
+	defer closeAudit()
+
+	checkpoints := checkpoint.Open(cfg.Storage.CheckpointPath, permissions())
+	progressKey := checkpoint.Key("spapi", apiRegion(cfg), "backfill")
+	if *resume {
+		cp, ok, err := checkpoints.Load(progressKey)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to read checkpoint: ", err.Error())
+			return 1
+		}
+		if t, err := time.Parse(time.RFC3339, cp.Value); ok && err == nil && t.After(from) && t.Before(to) {
+			from = t
+			logger.PrintColored("Resuming backfill from: ", cp.Value, "#00FFFF")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	windows, newest, err := backfill(ctx, cfg, checkpoints, progressKey, from, to, *window, *pause)
+	if err == nil && !newest.IsZero() {
+		err = advanceAPICheckpoint(checkpoints, apiCheckpointKey(cfg), newest)
+	}
+
+	stored, unchanged := 0, 0
+	for _, w := range windows {
+		stored += w.Stored
+		unchanged += w.Unchanged
+	}
+	if output.IsJSON() {
+		out := map[string]interface{}{"command": "backfill", "success": err == nil, "windows": windows, "stored": stored, "unchanged": unchanged}
+		if err != nil {
+			out["error"] = err.Error()
+		}
+		output.Emit(out)
+	}
+	if err != nil {
+		logger.Log(utils.LevelError, "Backfill failed: ", err.Error()+" (rerun with --resume to continue)")
+		return 1
+	}
+	logger.PrintColored("Backfill complete: ", fmt.Sprintf("%d orders stored, %d unchanged, %d windows", stored, unchanged, len(windows)), "#32CD32")
+	return 0
+}
+
+/*
+backfill imports orders created between from and to in windows of the given
+length, saving progressKey after every completed window.
+
+Returns:
+  - The completed windows.
+  - The purchase order date of the newest stored order.
+  - The first error; windows before it are complete.
+*/
+func backfill(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store, progressKey string, from, to time.Time, window, pause time.Duration) ([]backfillWindow, time.Time, error) {
+	seen, err := seedDeduper(cfg)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var (
+		windows  []backfillWindow
+		newest   time.Time
+		token    string
+		tokenAt  time.Time
+		requests int
+	)
+	for start := from; start.Before(to); {
+		end := start.Add(window)
+		if end.After(to) {
+			end = to
+		}
+		w := backfillWindow{From: start, To: end}
+
+		next := ""
+		for {
+			if requests > 0 {
+				select {
+				case <-ctx.Done():
+					return windows, newest, ctx.Err()
+				case <-time.After(pause):
+				}
+			}
+			if token == "" || time.Since(tokenAt) > tokenLifetime {
+				if token, err = fetchOAuthToken(ctx, cfg); err != nil {
+					return windows, newest, fmt.Errorf("error fetching OAuth2 token: %w", err)
+				}
+				tokenAt = time.Now()
+			}
+			query := url.Values{
+				"createdAfter":  {start.UTC().Format(time.RFC3339)},
+				"createdBefore": {end.UTC().Format(time.RFC3339)},
+			}
+			if cfg.API.Query["limit"] == "" {
+				query.Set("limit", "100")
+			}
+			if next != "" {
+				query.Set("nextToken", next)
+			}
+			requests++
+			w.Pages++
+			err := fetchFromAPI(ctx, cfg, token, query, nil, func(body io.Reader) error {
+				page, err := storeOrders(ctx, cfg, body, seen)
+				if page.Newest.After(newest) {
+					newest = page.Newest
+				}
+				w.Stored += page.Stored
+				w.Unchanged += page.Duplicates
+				next = page.Next
+				return err
+			})
+			if err != nil {
+				return windows, newest, fmt.Errorf("window %s to %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+			}
+			if next == "" {
+				break
+			}
+		}
+
+		windows = append(windows, w)
+		logger.PrintColored(fmt.Sprintf("Window %s to %s: ", start.Format("2006-01-02"), end.Format("2006-01-02")), fmt.Sprintf("%d stored, %d unchanged", w.Stored, w.Unchanged), "#00FFFF")
+		if err := checkpoints.Save(progressKey, end.UTC().Format(time.RFC3339)); err != nil {
+			return windows, newest, err
+		}
+		start = end
+	}
+	return windows, newest, nil
+}
+
+/*
+seedDeduper opens the deduplication store and marks the versions already in
+storage, so orders stored before the store existed are not imported twice.
+*/
+func seedDeduper(cfg *config.Config) (dedupe.Deduper, error) {
+	seen := newDeduper(cfg)
+	existing, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range existing {
+		key := dedupe.OrderKey(o)
+		ok, err := seen.Seen(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if err := seen.Mark(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return seen, nil
+}
@@ -0,0 +1,129 @@
+// cmd/avcimporter/casepack.go
+package main
+
+import (
+	"github.com/heinrichb/avcimporter/pkg/catalog"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// loadCasePacks reads catalog.casePacks, or returns nil if it is not set.
+func loadCasePacks(cfg *config.Config) (catalog.CasePacks, error) {
+	if cfg.Catalog.CasePacks == "" {
+		return nil, nil
+	}
+	return catalog.LoadCasePacks(cfg.Catalog.CasePacks)
+}
+
+/*
+ackCaseViolations checks the accepted quantities of acks against the case
+packs. Backordered and rejected quantities are not shipped and not checked.
+*/
+func ackCaseViolations(packs catalog.CasePacks, acks []spapi.OrderAcknowledgement) []catalog.Violation {
+	var lines []catalog.Line
+	for _, a := range acks {
+		for _, it := range a.Items {
+			for _, ia := range it.ItemAcknowledgements {
+				if ia.AcknowledgementCode != spapi.AckAccepted {
+					continue
+				}
+				q := ia.AcknowledgedQuantity
+				if q.UnitSize == 0 {
+					q.UnitSize = it.OrderedQuantity.UnitSize
+				}
+				lines = append(lines, catalog.Line{
+					PurchaseOrderNumber: a.PurchaseOrderNumber,
+					ItemSequenceNumber:  it.ItemSequenceNumber,
+					VendorSKU:           it.VendorProductIdentifier,
+					ASIN:                it.AmazonProductIdentifier,
+					Units:               orderEaches(q),
+				})
+			}
+		}
+	}
+	return packs.Check(lines)
+}
+
+/*
+holdCaseViolations separates the acknowledgements with a case pack violation
+from those that can be submitted.
+
+Returns:
+  - The acknowledgements without violations.
+  - One "invalid" ackBatch per held acknowledgement, naming its violations.
+*/
+func holdCaseViolations(packs catalog.CasePacks, acks []spapi.OrderAcknowledgement) ([]spapi.OrderAcknowledgement, []ackBatch) {
+	details := map[string]string{}
+	for _, v := range ackCaseViolations(packs, acks) {
+		if details[v.PurchaseOrderNumber] != "" {
+			details[v.PurchaseOrderNumber] += "; "
+		}
+		details[v.PurchaseOrderNumber] += "item " + v.ItemSequenceNumber + ": " + v.Error()
+	}
+	if len(details) == 0 {
+		return acks, nil
+	}
+	var ok []spapi.OrderAcknowledgement
+	var held []ackBatch
+	for _, a := range acks {
+		if d, bad := details[a.PurchaseOrderNumber]; bad {
+			b := newAckBatch(0, []spapi.OrderAcknowledgement{a}, "invalid")
+			b.Detail = d
+			held = append(held, b)
+		} else {
+			ok = append(ok, a)
+		}
+	}
+	return ok, held
+}
+
+/*
+dropCaseViolations checks the shipped quantities of shipments against the
+case packs, adding up the lines of an order line within each shipment. The
+input lines of a violating order line are marked invalid in results and
+removed from their shipment; shipments left empty are dropped.
+*/
+func dropCaseViolations(packs catalog.CasePacks, shipments []*pendingShipment, results []lineResult) []*pendingShipment {
+	var lines []catalog.Line
+	for _, s := range shipments {
+		for _, it := range s.confirmation.ShippedItems {
+			lines = append(lines, catalog.Line{
+				Shipment:            s.confirmation.ShipmentIdentifier,
+				PurchaseOrderNumber: it.ItemDetails.PurchaseOrderNumber,
+				ItemSequenceNumber:  it.ItemSequenceNumber,
+				VendorSKU:           it.VendorProductIdentifier,
+				ASIN:                it.AmazonProductIdentifier,
+				Units:               orderEaches(it.ShippedQuantity),
+			})
+		}
+	}
+	violations := packs.Check(lines)
+	if len(violations) == 0 {
+		return shipments
+	}
+	type key struct{ shipment, po, item string }
+	bad := map[key]catalog.Violation{}
+	for _, v := range violations {
+		bad[key{v.Shipment, v.PurchaseOrderNumber, v.ItemSequenceNumber}] = v
+	}
+
+	kept := shipments[:0]
+	for _, s := range shipments {
+		var items []spapi.ShippedItem
+		var rows []int
+		for j, it := range s.confirmation.ShippedItems {
+			i := s.lines[j]
+			if v, ok := bad[key{s.confirmation.ShipmentIdentifier, it.ItemDetails.PurchaseOrderNumber, it.ItemSequenceNumber}]; ok {
+				results[i].Status, results[i].Detail = "invalid", v.Error()
+				continue
+			}
+			items = append(items, it)
+			rows = append(rows, i)
+		}
+		if len(items) > 0 {
+			s.confirmation.ShippedItems, s.lines = items, rows
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
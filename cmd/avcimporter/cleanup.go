@@ -0,0 +1,102 @@
+// cmd/avcimporter/cleanup.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/lock"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// cleanupInterval is how often the daemon removes leftovers between runs.
+const cleanupInterval = time.Hour
+
+/*
+cleanup removes leftovers of interrupted runs older than cleanup.maxAge:
+partial downloads and temporary files under the storage directories and, with
+the file lock backend, lock files of flows that have not run for that long.
+Each removed file is logged and a summary printed.
+
+Returns:
+  - The removed files; nil when cleanup is disabled or nothing was found.
+*/
+func (a *app) cleanup() []string {
+	cfg := a.holder.Get()
+	if !cfg.Cleanup.Active {
+		return nil
+	}
+	maxAge := time.Duration(cfg.Cleanup.MaxAge)
+
+	// Data directories are cleaned recursively; state files may sit in
+	// directories shared with other data, so only their own level is.
+	dirs := []struct {
+		path      string
+		recursive bool
+	}{
+		{cfg.Storage.SavePath, true},
+		{cfg.Storage.ArchivePath, true},
+		{cfg.Storage.InboundIndex, true},
+		{cfg.Storage.StagingPath, true},
+		{filepath.Dir(cfg.Storage.CheckpointPath), false},
+		{filepath.Dir(cfg.Storage.LifecyclePath), false},
+		{filepath.Dir(cfg.Storage.DedupePath), false},
+		{filepath.Dir(cfg.Storage.HistoryPath), false},
+		{filepath.Dir(cfg.Storage.OutboxPath), false},
+		{filepath.Dir(cfg.Storage.CredentialsPath), false},
+	}
+	seen := map[string]bool{}
+	var temps, locks []string
+	for _, d := range dirs {
+		if d.path == "" || seen[filepath.Clean(d.path)] {
+			continue
+		}
+		seen[filepath.Clean(d.path)] = true
+		removed, err := storage.CleanTemp(d.path, d.recursive, maxAge)
+		if err != nil {
+			logger.Log(utils.LevelWarn, "Cleanup failed: ", err.Error())
+		}
+		for _, path := range removed {
+			// A recursive walk of an earlier directory may already cover later ones.
+			if !seen[path] {
+				seen[path] = true
+				temps = append(temps, path)
+			}
+		}
+	}
+	if files, ok := a.locker.(*lock.FileLocker); ok {
+		removed, err := files.Clean(maxAge)
+		if err != nil {
+			logger.Log(utils.LevelWarn, "Lock cleanup failed: ", err.Error())
+		}
+		locks = removed
+	}
+
+	for _, path := range temps {
+		logger.PrintColored("Removed leftover file: ", path, "#00FFFF")
+	}
+	for _, path := range locks {
+		logger.PrintColored("Removed stale lock: ", path, "#00FFFF")
+	}
+	if len(temps)+len(locks) > 0 {
+		logger.PrintColored("Cleanup finished: ", fmt.Sprintf("%d temporary files, %d stale locks removed", len(temps), len(locks)), "#32CD32")
+	}
+	return append(temps, locks...)
+}
+
+// cleanupPeriodically runs cleanup every cleanupInterval until ctx is cancelled.
+func (a *app) cleanupPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.cleanup()
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// cmd/avcimporter/configcmd.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdConfigSchema implements `avcimporter config-schema`: it prints the JSON
+Schema of the config file, e.g. for editor completion.
+*/
+func cmdConfigSchema(args []string) int {
+	fs := flag.NewFlagSet("config-schema", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to encode schema: ", err.Error())
+		return 1
+	}
+	if _, err := os.Stdout.Write(append(data, '\n')); err != nil {
+		return 1
+	}
+	return 0
+}
+
+/*
+cmdValidateConfig implements `avcimporter validate-config [file]`: it checks
+a config file (default: -config, then configs/default.json) against the
+schema, reporting unknown or misspelled keys and wrongly typed values, and
+then loads it to apply the semantic checks of config.Load.
+
+Returns 0 if the file is valid and 1 if any problem was found.
+*/
+func cmdValidateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter validate-config [file]")
+		return 2
+	}
+	path := configPath
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		path = "configs/default.json"
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read config: ", err.Error())
+		return 1
+	}
+	issues, err := config.Validate(data)
+	// Unknown keys are already among the issues; load leniently so the
+	// semantic checks still run.
+	opts := configOptions()
+	opts.Lenient = true
+	if err != nil {
+		issues = []config.Issue{{Path: "(root)", Message: err.Error()}}
+	} else if _, err := config.Load(path, opts); err != nil {
+		issues = append(issues, config.Issue{Path: "(load)", Message: err.Error()})
+	}
+
+	if output.IsJSON() {
+		if issues == nil {
+			issues = []config.Issue{}
+		}
+		output.Emit(map[string]interface{}{"command": "validate-config", "success": len(issues) == 0, "file": path, "issues": issues})
+	} else {
+		for _, issue := range issues {
+			logger.Log(utils.LevelError, issue.Path+": ", issue.Message)
+		}
+		if len(issues) == 0 {
+			logger.PrintColored("Config is valid: ", path, "#32CD32")
+		}
+	}
+	if len(issues) > 0 {
+		return 1
+	}
+	return 0
+}
+
+/*
+cmdConfigEnv implements `avcimporter config-env`: it lists the environment
+variable of every config key with the key and the value format, so a
+container can be configured without a config file.
+*/
+func cmdConfigEnv(args []string) int {
+	fs := flag.NewFlagSet("config-env", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	vars := config.EnvVars()
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "config-env", "success": true, "variables": vars})
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range vars {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Name, v.Path, v.Type)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+/*
+cmdPrintEffectiveConfig implements --print-effective-config: it loads the
+configuration like a run would (file, profile, AVC_* environment and
+defaults) and prints it as JSON with secrets redacted. Logs go to stderr so
+stdout holds only the configuration.
+*/
+func cmdPrintEffectiveConfig() int {
+	logger.SetOutput(os.Stderr)
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	v, err := config.Redacted(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to encode config: ", err.Error())
+		return 1
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to encode config: ", err.Error())
+		return 1
+	}
+	if _, err := os.Stdout.Write(append(data, '\n')); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,287 @@
+// cmd/avcimporter/control.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/auth"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+reload loads the configuration file again and applies it like a change seen by
+the file watcher. It backs SIGHUP and POST /reload.
+
+Returns:
+  - An error if the file cannot be loaded; the configuration in effect is kept.
+*/
+func (a *app) reload() error {
+	cfg, err := config.Load(configPath, configOptions())
+	if err != nil {
+		logger.Log(utils.LevelError, "Ignoring config reload: ", err.Error())
+		return err
+	}
+	a.applyReload(cfg)
+	return nil
+}
+
+/*
+trigger starts the named flows (every flow when names is empty) immediately,
+outside their schedule. It backs SIGUSR1 and POST /trigger.
+
+Returns:
+  - The names of the triggered flows.
+*/
+func (a *app) trigger(names ...string) []string {
+	triggered := a.sched.Trigger(names...)
+	if len(triggered) > 0 {
+		logger.PrintColored("Triggered run: ", strings.Join(triggered, ", "), "#00FFFF")
+	}
+	return triggered
+}
+
+/*
+newAuthenticator builds the authenticator of server.addr from server.auth.
+Without keys or an OIDC issuer, the endpoints are only served on a loopback
+address, where everyone on the machine is admitted.
+
+Returns:
+  - An error if a role name is unknown, or if server.auth is not configured
+    and server.addr is reachable from other hosts.
+*/
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	ac := cfg.Server.Auth
+	if len(ac.Keys) == 0 && ac.OIDC.Issuer == "" {
+		if !loopbackAddr(cfg.Server.Addr) {
+			return nil, fmt.Errorf("server.addr %s is reachable from other hosts: set server.auth, or bind to a loopback address such as 127.0.0.1%s", cfg.Server.Addr, portOf(cfg.Server.Addr))
+		}
+		return &auth.Authenticator{Anonymous: auth.RoleAdmin}, nil
+	}
+	authn := &auth.Authenticator{}
+	for _, k := range ac.Keys {
+		role, err := auth.ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("server.auth key %s: %w", k.Name, err)
+		}
+		authn.Keys = append(authn.Keys, auth.Key{Name: k.Name, Key: k.Key, Role: role})
+	}
+	if o := ac.OIDC; o.Issuer != "" {
+		authn.OIDC = &auth.OIDC{Issuer: o.Issuer, Audience: o.Audience, RoleClaim: o.RoleClaim, Roles: map[string]auth.Role{}}
+		for v, name := range o.Roles {
+			role, err := auth.ParseRole(name)
+			if err != nil {
+				return nil, fmt.Errorf("server.auth.oidc.roles[%q]: %w", v, err)
+			}
+			authn.OIDC.Roles[v] = role
+		}
+		if o.DefaultRole != "" {
+			role, err := auth.ParseRole(o.DefaultRole)
+			if err != nil {
+				return nil, fmt.Errorf("server.auth.oidc.defaultRole: %w", err)
+			}
+			authn.OIDC.DefaultRole = role
+		}
+	}
+	return authn, nil
+}
+
+// loopbackAddr reports whether the listen address addr only accepts
+// connections from the local machine.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// portOf returns the ":port" part of addr, or "".
+func portOf(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return ":" + port
+	}
+	return ""
+}
+
+/*
+controlHandler serves the health endpoints (see health.Tracker.Handler) and
+the control endpoints, each requiring the given role from callers identified
+by authn:
+  - GET /healthz, /readyz: open, for probes.
+  - GET /status: read.
+  - GET /metrics: run counters in the Prometheus text format; read.
+  - GET/POST /graphql: GraphQL queries over orders, documents, lifecycle status and runs (see graphQLSchema); read.
+  - POST /trigger[?flow=<name>...]: run the given flows, or all, now; 404 if no named flow exists; trigger.
+  - POST /reload: reload the configuration file; 500 if it cannot be loaded; admin.
+  - POST /checkpoints/reset?key=<key>...: delete the given checkpoints so their sources are imported from scratch; admin.
+  - POST /webhooks/shipments: signed shipment callbacks (see shipmentWebhook); authenticated by their signature.
+*/
+func (a *app) controlHandler(authn *auth.Authenticator) http.Handler {
+	mux := http.NewServeMux()
+	health := a.tracker.Handler()
+	mux.Handle("/healthz", health)
+	mux.Handle("/readyz", health)
+	mux.Handle("/", authn.Require(auth.RoleRead, health))
+	mux.Handle("/metrics", authn.Require(auth.RoleRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		a.metrics.WritePrometheus(w)
+	})))
+	mux.Handle("/trigger", authn.Require(auth.RoleTrigger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeControl(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "use POST"})
+			return
+		}
+		names := r.URL.Query()["flow"]
+		triggered := a.trigger(names...)
+		if len(names) > 0 && len(triggered) == 0 {
+			writeControl(w, http.StatusNotFound, map[string]interface{}{"error": "no such flow: " + strings.Join(names, ", ")})
+			return
+		}
+		writeControl(w, http.StatusAccepted, map[string]interface{}{"triggered": triggered})
+	})))
+	mux.HandleFunc("/webhooks/shipments", a.shipmentWebhook)
+	mux.Handle("/graphql", authn.Require(auth.RoleRead, a.graphQLSchema()))
+	mux.Handle("/reload", authn.Require(auth.RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeControl(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "use POST"})
+			return
+		}
+		if err := a.reload(); err != nil {
+			writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		writeControl(w, http.StatusOK, map[string]interface{}{"status": "reloaded"})
+	})))
+	mux.Handle("/checkpoints/reset", authn.Require(auth.RoleAdmin, http.HandlerFunc(a.resetCheckpoints)))
+	return mux
+}
+
+/*
+resetCheckpoints serves POST /checkpoints/reset: every ?key= checkpoint is
+deleted (see checkpoint.Store.Delete), so the next run of its flow imports the
+source from scratch. The caller is logged; the deletions are audited.
+*/
+func (a *app) resetCheckpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeControl(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "use POST"})
+		return
+	}
+	keys := r.URL.Query()["key"]
+	if len(keys) == 0 {
+		writeControl(w, http.StatusBadRequest, map[string]interface{}{"error": "key is required"})
+		return
+	}
+	all, err := a.checkpoints.All()
+	if err != nil {
+		writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, k := range keys {
+		if _, ok := all[k]; !ok {
+			writeControl(w, http.StatusNotFound, map[string]interface{}{"error": "no such checkpoint: " + k})
+			return
+		}
+	}
+	for _, k := range keys {
+		if err := a.checkpoints.Delete(k); err != nil {
+			writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+	caller, _ := auth.FromContext(r.Context())
+	logger.Log(utils.LevelWarn, "Checkpoints reset by "+caller.Name+": ", strings.Join(keys, ", "))
+	writeControl(w, http.StatusOK, map[string]interface{}{"reset": keys})
+}
+
+// writeControl writes v as a JSON response with the given status code.
+func writeControl(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+/*
+serveSocket serves handler on the Unix socket at path until ctx is cancelled
+and removes the socket afterwards. The socket is only accessible to the owner
+(see listenSocket), so it can stay unauthenticated.
+*/
+func serveSocket(ctx context.Context, path string, handler http.Handler) error {
+	ln, err := listenSocket(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	srv := &http.Server{Handler: handler}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+/*
+listenSocket binds a Unix socket at path that only the owner can connect to.
+The socket is bound inside a new directory of mode 0700 next to path, made
+owner-only (0600) and only then renamed to path, so it is never reachable
+with the looser permissions of the umask. A stale socket left behind by an
+earlier process is removed first.
+
+Returns:
+  - An error if path is something other than a socket, another process is
+    still serving on it, or binding fails.
+*/
+func listenSocket(path string) (*net.UnixListener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("another process is serving on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket is renamed below; serveSocket removes it from path.
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
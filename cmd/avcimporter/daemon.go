@@ -0,0 +1,539 @@
+// cmd/avcimporter/daemon.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/auth"
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/health"
+	"github.com/heinrichb/avcimporter/pkg/history"
+	"github.com/heinrichb/avcimporter/pkg/incident"
+	"github.com/heinrichb/avcimporter/pkg/lock"
+	"github.com/heinrichb/avcimporter/pkg/metrics"
+	"github.com/heinrichb/avcimporter/pkg/outbox"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/scheduler"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/webhook"
+)
+
+// defaultJobInterval is used in daemon mode for flows without a configured interval.
+const defaultJobInterval = 15 * time.Minute
+
+// staleIntervals is how many job intervals may pass without a successful run
+// before the flow is reported as stuck by /healthz.
+const staleIntervals = 3
+
+/*
+app bundles the long-lived components shared by the one-shot and daemon modes.
+
+Fields:
+  - holder:  The configuration currently in effect.
+  - profiles: The configuration of each daemon profile (see loadProfiles), guarded by reloadMu.
+  - sched:   The job scheduler running every active flow.
+  - locker:  Cross-instance lock backend, or nil when locking is disabled.
+  - tracker: Per-flow run status served by the health endpoints.
+  - results: Machine-readable results of each flow run for --output json.
+  - checkpoints: Import positions per source, shared by all flows.
+  - metrics: Run metrics served on /metrics and pushed after one-shot runs.
+  - escalator: Consecutive failures per flow, escalated as on-call incidents.
+  - history: Outcome of every flow run, shown by `history`.
+  - reloadMu: Serializes reloads from the file watcher, SIGHUP and POST /reload.
+  - shipments: Shipments accepted by the webhook, kept until submitted (see shipmentWebhook).
+  - submitNow: Wakes submitQueuedShipments when shipments were accepted.
+  - replays: Signatures of accepted webhook callbacks, restored from shipments on start.
+*/
+type app struct {
+	holder      *config.Holder
+	profiles    map[string]*config.Holder
+	sched       *scheduler.Scheduler
+	locker      lock.Locker
+	tracker     *health.Tracker
+	results     *output.Collector
+	checkpoints *checkpoint.Store
+	metrics     *metrics.Registry
+	escalator   *incident.Escalator
+	history     *history.Store
+	reloadMu    sync.Mutex
+	shipments   *outbox.Outbox
+	submitNow   chan struct{}
+	replays     webhook.Replays
+}
+
+/*
+newApp builds the scheduler, lock backend and status tracker for cfg and
+registers a job for every active flow, reporting each run into results.
+*/
+func newApp(cfg *config.Config, results *output.Collector) (*app, error) {
+	locker, err := newLocker(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up locking: %w", err)
+	}
+	a := &app{
+		holder:      config.NewHolder(cfg),
+		sched:       scheduler.New(cfg.Daemon.Workers),
+		locker:      locker,
+		tracker:     health.NewTracker(),
+		results:     results,
+		checkpoints: checkpoint.Open(cfg.Storage.CheckpointPath, permissions()),
+		metrics:     &metrics.Registry{},
+		history:     history.Open(cfg.Storage.HistoryPath, time.Duration(cfg.Storage.HistoryMaxAge), permissions()),
+		shipments:   openShipmentOutbox(cfg),
+		submitNow:   make(chan struct{}, 1),
+	}
+	a.escalator = incident.NewEscalator(a.checkpoints)
+	if err := a.restoreReplays(); err != nil {
+		logger.Log(utils.LevelWarn, "Failed to restore webhook signatures: ", err.Error())
+	}
+	profiles, err := loadProfiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.profiles = make(map[string]*config.Holder, len(profiles))
+	for name, pc := range profiles {
+		a.profiles[name] = config.NewHolder(pc)
+	}
+	a.tracker.SetCheckpoints(a.checkpoints)
+	a.tracker.SetLogger(logger)
+	a.sched.SetLogger(logger)
+	for _, job := range a.buildJobs(cfg) {
+		a.sched.Add(job)
+		a.tracker.Register(job.Name, staleIntervals*job.Interval)
+	}
+	a.sched.Observe(func(r scheduler.Result) {
+		a.tracker.Record(r.Job, r.Finished, r.Err)
+		a.escalate(r)
+	})
+	return a, nil
+}
+
+/*
+runDaemon executes every flow on its schedule until ctx is cancelled, reloading
+the configuration whenever configPath changes or on SIGHUP, running every flow
+at once on SIGUSR1, serving the health and control endpoints (see
+controlHandler) on server.addr, authenticated as server.auth requires (see
+newAuthenticator), and on server.socket, open to whoever can reach it, when
+configured and importing files delivered to
+edi.localInboundDir as they arrive. Pending exports are sent again every
+outbox.sweepInterval, and shipments received by the webhook are submitted as
+they are accepted.
+*/
+func (a *app) runDaemon(ctx context.Context) error {
+	cfg := a.holder.Get()
+	logger.PrintColored("Running in daemon mode with workers: ", fmt.Sprint(cfg.Daemon.Workers), "#00FFFF")
+
+	if configPath == "" {
+		logger.Log(utils.LevelWarn, "Config hot-reload disabled: ", "no config file")
+	} else if err := config.Watch(ctx, configPath, configOptions(), a.applyReload); err != nil {
+		logger.Log(utils.LevelWarn, "Config hot-reload disabled: ", err.Error())
+	}
+	a.handleSignals(ctx)
+
+	if cfg.EDI.LocalInboundDir != "" {
+		importFile := func(ctx context.Context, file string) error {
+			return a.withResult("edi-watch", a.holder, withTrace("edi-watch", func(ctx context.Context) error {
+				return importLocalFile(ctx, a.holder.Get(), a.checkpoints, file)
+			}))(ctx)
+		}
+		go func() {
+			if err := watchLocalInbound(ctx, cfg, importFile); err != nil {
+				logger.Log(utils.LevelError, "Inbound watcher failed: ", err.Error())
+			}
+		}()
+	}
+
+	if cfg.Server.Addr != "" {
+		authn, err := newAuthenticator(cfg)
+		if err != nil {
+			return err
+		}
+		if authn.Anonymous > auth.RoleNone {
+			logger.Log(utils.LevelWarn, "Serving control endpoints without authentication on loopback: ", "set server.auth to require it")
+		}
+		srv := &http.Server{Addr: cfg.Server.Addr, Handler: a.controlHandler(authn)}
+		go func() {
+			logger.PrintColored("Serving health endpoints on: ", cfg.Server.Addr, "#00FFFF")
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Log(utils.LevelError, "Health server failed: ", err.Error())
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+	}
+	if cfg.Server.Socket != "" {
+		go func() {
+			logger.PrintColored("Serving control socket on: ", cfg.Server.Socket, "#00FFFF")
+			if err := serveSocket(ctx, cfg.Server.Socket, a.controlHandler(&auth.Authenticator{Anonymous: auth.RoleAdmin})); err != nil {
+				logger.Log(utils.LevelError, "Control socket failed: ", err.Error())
+			}
+		}()
+	}
+
+	go a.cleanupPeriodically(ctx)
+	go a.sweepOutboxPeriodically(ctx)
+	go a.submitQueuedShipments(ctx)
+
+	a.tracker.SetReady(true)
+	defer a.tracker.SetReady(false)
+	if err := a.sched.Run(ctx); err != nil {
+		return err
+	}
+	// Without scheduled flows Run returns at once; keep watching and serving.
+	<-ctx.Done()
+	return nil
+}
+
+/*
+buildJobs turns each active flow in cfg into a scheduler job, applying the
+matching entry from cfg.Daemon.Jobs. Each run reads the configuration in effect
+from the holder, so reloaded settings and credentials apply from the next cycle.
+When a lock backend is configured each run first takes the flow's lock.
+Every run is traced as a root span named after the flow.
+
+The EDI and SP-API flows of every daemon profile become jobs of their own,
+named "edi@<profile>" and "api@<profile>" and locked under that name, so a
+slow partner mailbox or region does not hold up the others.
+*/
+func (a *app) buildJobs(cfg *config.Config) []scheduler.Job {
+	ttl := time.Duration(cfg.Lock.TTL)
+	var jobs []scheduler.Job
+	if cfg.EDI.Active {
+		jobs = append(jobs, a.ediJob("edi", a.holder, cfg.Daemon.Jobs["edi"], ttl))
+	}
+	if cfg.API.Active {
+		jobs = append(jobs, a.apiJob("api", a.holder, cfg.Daemon.Jobs["api"], ttl))
+	}
+	for _, name := range cfg.Daemon.Profiles {
+		holder := a.profiles[name]
+		if holder == nil {
+			continue
+		}
+		pc := holder.Get()
+		if pc.EDI.Active {
+			jobs = append(jobs, a.ediJob("edi@"+name, holder, profileJob(cfg, "edi", name), ttl))
+		}
+		if pc.API.Active {
+			jobs = append(jobs, a.apiJob("api@"+name, holder, profileJob(cfg, "api", name), ttl))
+		}
+	}
+	if cfg.Reports.Active {
+		jobs = append(jobs, newJob("reports", cfg.Daemon.Jobs["reports"], a.withResult("reports", a.holder, withTrace("reports", withLock(a.locker, "reports", ttl, func(ctx context.Context) error {
+			return runReportsFlow(ctx, a.holder.Get(), a.checkpoints)
+		})))))
+	}
+	if cfg.DataKiosk.Active {
+		jobs = append(jobs, newJob("datakiosk", cfg.Daemon.Jobs["datakiosk"], a.withResult("datakiosk", a.holder, withTrace("datakiosk", withLock(a.locker, "datakiosk", ttl, func(ctx context.Context) error {
+			return runDataKioskFlow(ctx, a.holder.Get(), a.checkpoints)
+		})))))
+	}
+	if cfg.SLA.Active {
+		jobs = append(jobs, newJob("sla", cfg.Daemon.Jobs["sla"], a.withResult("sla", a.holder, withTrace("sla", withLock(a.locker, "sla", ttl, func(ctx context.Context) error {
+			return runSLAFlow(ctx, a.holder.Get())
+		})))))
+	}
+	if cfg.Summary.Active {
+		jobs = append(jobs, newJob("summary", cfg.Daemon.Jobs["summary"], a.withResult("summary", a.holder, withTrace("summary", withLock(a.locker, "summary", ttl, func(ctx context.Context) error {
+			return runSummaryFlow(ctx, a.holder.Get())
+		})))))
+	}
+	return jobs
+}
+
+// ediJob returns the EDI flow job named name, run with the configuration of holder.
+func (a *app) ediJob(name string, holder *config.Holder, jc config.JobConfig, ttl time.Duration) scheduler.Job {
+	return newJob(name, jc, a.withResult(name, holder, withTrace(name, withLock(a.locker, name, ttl, func(ctx context.Context) error {
+		return runEDIFlow(ctx, holder.Get(), a.checkpoints)
+	}))))
+}
+
+// apiJob returns the SP-API flow job named name, run with the configuration of holder.
+func (a *app) apiJob(name string, holder *config.Holder, jc config.JobConfig, ttl time.Duration) scheduler.Job {
+	return newJob(name, jc, a.withResult(name, holder, withTrace(name, withLock(a.locker, name, ttl, func(ctx context.Context) error {
+		return runAPIFlow(ctx, holder.Get(), a.checkpoints)
+	}))))
+}
+
+// profileJob returns the schedule of the flow of a daemon profile: the entry
+// "<flow>@<profile>" of cfg.Daemon.Jobs, or that of the flow itself.
+func profileJob(cfg *config.Config, flow, profile string) config.JobConfig {
+	if jc, ok := cfg.Daemon.Jobs[flow+"@"+profile]; ok {
+		return jc
+	}
+	return cfg.Daemon.Jobs[flow]
+}
+
+/*
+loadProfiles loads the configuration of every profile in cfg.Daemon.Profiles,
+each applied to the configuration file on its own (instead of --profile), with
+the stored SFTP keys and the --query parameters applied as for cfg.
+
+SigV4 signing is set up once, from cfg, so a profile that signs its requests
+must use the same api.sigv4 settings and api.baseUrl.
+
+Returns:
+  - The configuration of each profile by name.
+  - An error if a profile cannot be loaded or signs differently.
+*/
+func loadProfiles(cfg *config.Config) (map[string]*config.Config, error) {
+	profiles := make(map[string]*config.Config, len(cfg.Daemon.Profiles))
+	for _, name := range cfg.Daemon.Profiles {
+		opts := configOptions()
+		opts.Profile = name
+		opts.Logger = nil
+		pc, err := config.Load(configPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load daemon profile %q: %w", name, err)
+		}
+		if err := applySFTPKeys(pc); err != nil {
+			return nil, fmt.Errorf("daemon profile %q: %w", name, err)
+		}
+		if _, err := sftpChallenge(pc); err != nil {
+			return nil, fmt.Errorf("daemon profile %q: %w", name, err)
+		}
+		if pc.API.Active && pc.API.SigV4.Enabled && (pc.API.SigV4 != cfg.API.SigV4 || pc.API.BaseURL != cfg.API.BaseURL) {
+			return nil, fmt.Errorf("daemon profile %q: api.sigv4 and api.baseUrl must match the base configuration to sign requests", name)
+		}
+		applyQuery(pc)
+		profiles[name] = pc
+	}
+	return profiles, nil
+}
+
+/*
+applyReload swaps in a reloaded configuration and reschedules the running jobs.
+Enabling or disabling a flow, resizing the worker pool and changing the lock,
+server, checkpoint, history, audit or encryption settings still require a restart.
+*/
+func (a *app) applyReload(newCfg *config.Config) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	old := a.holder.Get()
+	if old.Lock != newCfg.Lock {
+		logger.Log(utils.LevelWarn, "Lock settings changed; restart to apply.", "")
+		newCfg.Lock = old.Lock
+	}
+	if !reflect.DeepEqual(old.Server, newCfg.Server) {
+		logger.Log(utils.LevelWarn, "Server settings changed; restart to apply.", "")
+		newCfg.Server = old.Server
+	}
+	if old.Storage.CheckpointPath != newCfg.Storage.CheckpointPath {
+		logger.Log(utils.LevelWarn, "Checkpoint path changed; restart to apply.", "")
+		newCfg.Storage.CheckpointPath = old.Storage.CheckpointPath
+	}
+	if old.Storage.HistoryPath != newCfg.Storage.HistoryPath || old.Storage.HistoryMaxAge != newCfg.Storage.HistoryMaxAge {
+		logger.Log(utils.LevelWarn, "History settings changed; restart to apply.", "")
+		newCfg.Storage.HistoryPath, newCfg.Storage.HistoryMaxAge = old.Storage.HistoryPath, old.Storage.HistoryMaxAge
+	}
+	if old.Audit != newCfg.Audit {
+		logger.Log(utils.LevelWarn, "Audit settings changed; restart to apply.", "")
+		newCfg.Audit = old.Audit
+	}
+	if !reflect.DeepEqual(old.Logging, newCfg.Logging) {
+		logger.Log(utils.LevelWarn, "Log sink settings changed; restart to apply.", "")
+		newCfg.Logging = old.Logging
+	}
+	if old.Storage.Encryption != newCfg.Storage.Encryption {
+		logger.Log(utils.LevelWarn, "Encryption settings changed; restart to apply.", "")
+		newCfg.Storage.Encryption = old.Storage.Encryption
+	}
+	if err := applySFTPKeys(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous SFTP keys: ", err.Error())
+		newCfg.EDI.PrivateKeyPath, newCfg.EDI.PreviousKeyPath = old.EDI.PrivateKeyPath, old.EDI.PreviousKeyPath
+		newCfg.EDI.PrivateKey, newCfg.EDI.PrivateKeyPassphrase = old.EDI.PrivateKey, old.EDI.PrivateKeyPassphrase
+	}
+	if _, err := sftpChallenge(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous keyboard-interactive settings: ", err.Error())
+		newCfg.EDI.KeyboardInteractive = old.EDI.KeyboardInteractive
+	}
+	if err := applySigV4(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous SigV4 settings: ", err.Error())
+		newCfg.API.SigV4 = old.API.SigV4
+	}
+	if err := applyPermissions(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous file permissions: ", err.Error())
+		newCfg.Storage.Permissions = old.Storage.Permissions
+	}
+	applyQuery(newCfg)
+	a.holder.Set(newCfg)
+	applyThrottle(newCfg)
+	if err := installHooks(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous hooks and exports: ", err.Error())
+	}
+	logger.PrintColored("Configuration reloaded.", "", "#32CD32")
+
+	if config.CredentialsChanged(old, newCfg) {
+		logger.Log(utils.LevelWarn, "Credentials changed; re-authenticating on the next run.", "")
+	}
+	if old.EDI.Active != newCfg.EDI.Active || old.API.Active != newCfg.API.Active || old.Reports.Active != newCfg.Reports.Active ||
+		old.DataKiosk.Active != newCfg.DataKiosk.Active || old.SLA.Active != newCfg.SLA.Active ||
+		old.Summary.Active != newCfg.Summary.Active {
+		logger.Log(utils.LevelWarn, "Flow activation changed; restart to apply.", "")
+	}
+	if old.EDI.LocalInboundDir != newCfg.EDI.LocalInboundDir || old.EDI.StableFor != newCfg.EDI.StableFor {
+		logger.Log(utils.LevelWarn, "Local inbound directory changed; restart to apply.", "")
+	}
+	if old.Daemon.Workers != newCfg.Daemon.Workers {
+		logger.Log(utils.LevelWarn, "Worker count changed; restart to apply.", "")
+	}
+	if !reflect.DeepEqual(old.Daemon.Profiles, newCfg.Daemon.Profiles) {
+		logger.Log(utils.LevelWarn, "Daemon profiles changed; restart to apply.", "")
+		newCfg.Daemon.Profiles = old.Daemon.Profiles
+	}
+	if profiles, err := loadProfiles(newCfg); err != nil {
+		logger.Log(utils.LevelError, "Keeping the previous daemon profiles: ", err.Error())
+	} else {
+		for name, pc := range profiles {
+			a.profiles[name].Set(pc)
+		}
+	}
+
+	for _, job := range a.buildJobs(newCfg) {
+		if a.sched.Update(job) {
+			a.tracker.Register(job.Name, staleIntervals*job.Interval)
+		}
+	}
+}
+
+// newJob converts a JobConfig into a scheduler.Job.
+func newJob(name string, jc config.JobConfig, run func(ctx context.Context) error) scheduler.Job {
+	interval := time.Duration(jc.Interval)
+	if interval <= 0 {
+		interval = defaultJobInterval
+	}
+	return scheduler.Job{
+		Name:          name,
+		Interval:      interval,
+		MaxConcurrent: jc.MaxConcurrent,
+		Retry:         retryPolicy(jc.Retry, name),
+		Run:           run,
+	}
+}
+
+/*
+newLocker builds the lock backend selected by cfg.Lock.Backend.
+It returns a nil Locker when locking is disabled.
+*/
+func newLocker(cfg *config.Config) (lock.Locker, error) {
+	switch cfg.Lock.Backend {
+	case "":
+		return nil, nil
+	case "file":
+		if cfg.Lock.Dir == "" {
+			return nil, fmt.Errorf("lock.dir is required for the file backend")
+		}
+		return lock.NewFileLocker(cfg.Lock.Dir, permissions())
+	case "redis":
+		if cfg.Lock.RedisAddr == "" {
+			return nil, fmt.Errorf("lock.redisAddr is required for the redis backend")
+		}
+		return lock.NewRedisLocker(cfg.Lock.RedisAddr, cfg.Lock.RedisPassword, "avcimporter:lock:"), nil
+	default:
+		return nil, fmt.Errorf("unknown lock backend %q", cfg.Lock.Backend)
+	}
+}
+
+// withLock wraps run so it only executes while holding the named lock.
+// A lock held by another instance skips the run rather than failing it.
+func withLock(locker lock.Locker, name string, ttl time.Duration, run func(ctx context.Context) error) func(ctx context.Context) error {
+	if locker == nil {
+		return run
+	}
+	return func(ctx context.Context) error {
+		err := lock.Run(ctx, locker, name, ttl, run)
+		if errors.Is(err, lock.ErrLocked) {
+			logger.LogContext(ctx, utils.LevelWarn, "Skipping run, another instance holds the lock: ", name)
+			return nil
+		}
+		return err
+	}
+}
+
+// withTrace wraps run in a root span named "run.<name>".
+func withTrace(name string, run func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx = spapi.WithCaptureRun(ctx, name+"-"+time.Now().UTC().Format("20060102T150405.000"))
+		ctx, span := tracing.Start(ctx, "run."+name, "flow", name)
+		err := run(ctx)
+		span.End(err)
+		return err
+	}
+}
+
+// withResult wraps run so each attempt records a FlowResult in a.results.
+// Flows add files, PO numbers and downloaded artifacts to it through
+// output.FromContext; a run that downloaded anything also leaves a manifest.
+// Log lines printed with the run's context carry the flow's label (see flowLabel).
+func (a *app) withResult(name string, holder *config.Holder, run func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx = utils.WithLogPrefix(ctx, flowLabel(holder.Get(), name))
+		r := &output.FlowResult{Flow: name, StartedAt: time.Now()}
+		err := run(output.WithFlowResult(ctx, r))
+		r.Finish(err)
+		a.recordMetrics(r)
+		a.recordHistory(r)
+		if m := r.BuildManifest(); m != nil {
+			if path, mErr := writeManifest(ctx, holder.Get(), m); mErr != nil {
+				logger.LogContext(ctx, utils.LevelError, "Failed to write manifest: ", mErr.Error())
+			} else {
+				r.SetManifest(path)
+			}
+		}
+		a.results.Add(r)
+		return err
+	}
+}
+
+// flowLabels are the log labels of the flows; the API flow adds its region and
+// the flows of a daemon profile the profile name.
+var flowLabels = map[string]string{
+	"edi":       "EDI",
+	"edi-watch": "EDI watch",
+	"api":       "SP-API",
+	"reports":   "Reports",
+	"datakiosk": "Data Kiosk",
+	"sla":       "SLA",
+	"summary":   "Summary",
+}
+
+// flowLabel returns the log label of the named flow, e.g. "SP-API NA" or
+// "EDI eu" for the job "edi@eu".
+func flowLabel(cfg *config.Config, name string) string {
+	flow, profile, _ := strings.Cut(name, "@")
+	label, ok := flowLabels[flow]
+	if !ok {
+		return name
+	}
+	if profile != "" {
+		return label + " " + profile
+	}
+	if name == "api" {
+		if region := apiRegion(cfg); awsRegions[region] != "" {
+			label += " " + region
+		}
+	}
+	return label
+}
+
+// writeManifest stores m next to the files it lists.
+func writeManifest(ctx context.Context, cfg *config.Config, m *output.Manifest) (string, error) {
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return "", err
+	}
+	return storage.SaveManifest(savePath(ctx, cfg), namer, m)
+}
@@ -0,0 +1,222 @@
+// cmd/avcimporter/doctor.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sftpx"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Outcomes of a doctor check.
+*/
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+/*
+doctorCheck is one line of `avcimporter doctor`.
+
+Fields:
+  - Name:   What was checked, e.g. "sftp login".
+  - Status: checkPass, checkFail or checkSkip.
+  - Detail: The error, or why the check was skipped.
+*/
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+/*
+cmdDoctor implements `avcimporter doctor`: a pre-flight check of everything a
+run needs from the outside world, so a bad credential or permission shows up
+before the first scheduled run instead of in its logs:
+  - storage: every local directory the importer writes to is writable.
+  - lwa token: the refresh token yields an access token.
+  - role: the token is authorized (has the vendor role) for each configured SP‑API endpoint.
+  - aws sigv4: reported as skipped; requests are authorized by the LWA token alone.
+  - sftp: login works, the inbound directory can be listed and the outbound one exists.
+
+Checks of disabled flows are skipped. Nothing is sent to Amazon apart from
+read-only requests, unless --write-probe is given.
+
+Flags:
+  - --write-probe: Also create and remove a file in the SFTP outbound directory.
+  - --timeout:     Limit for all network checks together (default: 1m).
+*/
+func cmdDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	probe := fs.Bool("write-probe", false, "Create and remove a file in the SFTP outbound directory")
+	timeout := fs.Duration("timeout", time.Minute, "Limit for all network checks together")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var checks []doctorCheck
+	checks = append(checks, storageChecks(cfg)...)
+	checks = append(checks, apiChecks(ctx, cfg)...)
+	checks = append(checks, sftpChecks(ctx, cfg, *probe)...)
+
+	failed := 0
+	for _, c := range checks {
+		if c.Status == checkFail {
+			failed++
+		}
+	}
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "doctor", "success": failed == 0, "checks": checks})
+	} else {
+		for _, c := range checks {
+			color := map[string]string{checkPass: "#32CD32", checkFail: "#FF0000", checkSkip: "#FFFF00"}[c.Status]
+			prefix := fmt.Sprintf("[%s] %s", strings.ToUpper(c.Status), c.Name)
+			if c.Detail != "" {
+				prefix += ": "
+			}
+			logger.PrintColored(prefix, c.Detail, color)
+		}
+		if failed > 0 {
+			logger.Log(utils.LevelError, "Failed checks: ", fmt.Sprintf("%d of %d", failed, len(checks)))
+		} else {
+			logger.PrintColored("All checks passed.", "", "#32CD32")
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkResult turns an error into a passed or failed check.
+func checkResult(name string, err error) doctorCheck {
+	if err != nil {
+		return doctorCheck{Name: name, Status: checkFail, Detail: err.Error()}
+	}
+	return doctorCheck{Name: name, Status: checkPass}
+}
+
+/*
+storageChecks verifies each directory written to (storage.savePath, the
+directories of the checkpoint, lifecycle, dedupe and credentials files and the
+archive) by creating and removing a temporary file in it.
+*/
+func storageChecks(cfg *config.Config) []doctorCheck {
+	dirs := []string{cfg.Storage.SavePath, filepath.Dir(cfg.Storage.CheckpointPath), filepath.Dir(cfg.Storage.LifecyclePath), filepath.Dir(cfg.Storage.DedupePath), filepath.Dir(cfg.Storage.CredentialsPath), cfg.Storage.ArchivePath}
+	seen := map[string]bool{}
+	var checks []doctorCheck
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		checks = append(checks, checkResult("storage writable: "+dir, checkWritable(dir)))
+	}
+	return checks
+}
+
+// checkWritable creates dir if needed and writes and removes a file in it.
+func checkWritable(dir string) error {
+	if err := permissions().MkdirAll(dir); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".avcimporter-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+/*
+apiChecks fetches an LWA token and probes one read-only operation per enabled
+SP‑API flow. A 401 or 403 means the refresh token lacks the role the
+operation needs (e.g. the vendor role for the Vendor Orders API).
+*/
+func apiChecks(ctx context.Context, cfg *config.Config) []doctorCheck {
+	type target struct {
+		name  string
+		path  string
+		query url.Values
+	}
+	var targets []target
+	if cfg.API.Active {
+		endpoint, rawQuery, _ := strings.Cut(cfg.API.EndpointURL, "?")
+		query, _ := url.ParseQuery(rawQuery)
+		if strings.HasSuffix(endpoint, "/purchaseOrders") {
+			query.Set("limit", "1")
+		}
+		targets = append(targets, target{"role: api " + endpoint, endpoint, query})
+	}
+	if cfg.Reports.Active && len(cfg.Reports.Requests) > 0 {
+		targets = append(targets, target{"role: reports", "/reports/2021-06-30/reports", url.Values{"reportTypes": {cfg.Reports.Requests[0].ReportType}, "pageSize": {"1"}}})
+	}
+	if cfg.DataKiosk.Active {
+		targets = append(targets, target{"role: data kiosk", "/dataKiosk/2023-11-15/queries", url.Values{"pageSize": {"1"}}})
+	}
+
+	checks := []doctorCheck{{Name: "aws sigv4", Status: checkSkip, Detail: "not used; SP‑API requests are authorized by the LWA token alone"}}
+	if len(targets) == 0 {
+		return append(checks, doctorCheck{Name: "lwa token", Status: checkSkip, Detail: "no SP‑API flow is active"})
+	}
+	token, err := fetchOAuthToken(ctx, cfg)
+	checks = append(checks, checkResult("lwa token", err))
+	client := &spapi.Client{BaseURL: cfg.API.BaseURL, Token: token, HTTP: apiHTTP, UserAgent: userAgent(cfg), Logger: logger}
+	for _, t := range targets {
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: t.name, Status: checkSkip, Detail: "no token"})
+			continue
+		}
+		perr := client.Probe(ctx, t.path, t.query)
+		var status *utils.StatusError
+		if errors.As(perr, &status) && (status.StatusCode == 401 || status.StatusCode == 403) {
+			perr = fmt.Errorf("not authorized (status %d); check the app's roles and re-authorize the refresh token", status.StatusCode)
+		}
+		checks = append(checks, checkResult(t.name, perr))
+	}
+	return checks
+}
+
+// sftpChecks logs in to the EDI SFTP server and checks both directories.
+func sftpChecks(ctx context.Context, cfg *config.Config, probe bool) []doctorCheck {
+	names := []string{"sftp login", "sftp inbound: " + cfg.EDI.InboundDir, "sftp outbound: " + cfg.EDI.OutboundDir}
+	if !cfg.EDI.Active {
+		return []doctorCheck{{Name: names[0], Status: checkSkip, Detail: "edi flow is not active"}}
+	}
+	conn, err := sftpConn(cfg)
+	if err != nil {
+		return []doctorCheck{checkResult(names[0], err)}
+	}
+	// A diagnosis connects once instead of retrying per retry.sftp.
+	conn.Retry = utils.RetryPolicy{}
+	access := sftpx.Check(ctx, conn, sftpx.CheckOptions{InboundDir: cfg.EDI.InboundDir, OutboundDir: cfg.EDI.OutboundDir, Probe: probe})
+	if access.Login != nil {
+		return []doctorCheck{checkResult(names[0], access.Login)}
+	}
+	outbound := checkResult(names[2], access.Outbound)
+	if outbound.Status == checkPass && !probe {
+		outbound.Detail = "exists; use --write-probe to test writing"
+	}
+	return []doctorCheck{checkResult(names[0], nil), checkResult(names[1], access.Inbound), outbound}
+}
@@ -0,0 +1,192 @@
+// cmd/avcimporter/documents.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/documents"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/mail"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+warehouseLines is the part of an order one warehouse ships.
+*/
+type warehouseLines struct {
+	Warehouse string
+	Items     []model.Item
+}
+
+/*
+orderWarehouses splits the lines of an order among the warehouses the routing
+rules assign them, in the order of their first line; lines no rule matches go
+to routing.default or the order's selling party.
+*/
+func orderWarehouses(cfg *config.Config, o model.Order) []warehouseLines {
+	r := router(cfg)
+	if r.Default == "" {
+		r.Default = o.SellingParty
+	}
+	var split []warehouseLines
+	index := map[string]int{}
+	for _, it := range o.Items {
+		w := r.Route(o.ShipToParty, it.VendorSKU, it.ASIN)
+		i, ok := index[w]
+		if !ok {
+			i = len(split)
+			index[w] = i
+			split = append(split, warehouseLines{Warehouse: w})
+		}
+		split[i].Items = append(split[i].Items, it)
+	}
+	return split
+}
+
+/*
+renderedDocument is a PDF rendered for an order.
+*/
+type renderedDocument struct {
+	name      string
+	warehouse string // "" for the purchase order
+	lines     int
+	path      string
+	data      []byte
+}
+
+/*
+storeDocuments renders the PDF printouts of an imported order with
+documents.pdf set: the purchase order and a packing slip per warehouse the
+order is routed to. They are stored under the "document" file name type,
+passed to the hooks as "generated" with type "document" and, with
+documents.email set, emailed to the warehouses once the run is committed.
+
+A document that cannot be rendered or stored is warned about and does not
+fail the import; only a failing hook does.
+*/
+func storeDocuments(ctx context.Context, cfg *config.Config, namer *storage.Namer, o model.Order) error {
+	if !cfg.Documents.PDF {
+		return nil
+	}
+	docs, err := renderDocuments(cfg, o)
+	if err != nil {
+		logger.LogContext(ctx, utils.LevelWarn, "Failed to render documents of "+o.PONumber+": ", err.Error())
+		return nil
+	}
+	result := output.FromContext(ctx)
+	for i, d := range docs {
+		path, err := storage.SaveDocument(savePath(ctx, cfg), namer, storage.NameData{PONumber: o.PONumber, Document: d.name}, func(w io.Writer) error {
+			_, err := w.Write(d.data)
+			return err
+		})
+		if err != nil {
+			logger.LogContext(ctx, utils.LevelWarn, "Failed to store documents of "+o.PONumber+": ", err.Error())
+			return nil
+		}
+		docs[i].path = path
+		result.AddFiles(path)
+		fields := map[string]string{"poNumber": o.PONumber, "document": d.name}
+		if d.warehouse != "" {
+			fields["warehouse"] = d.warehouse
+		}
+		if err := runHooks(ctx, hooks.Event{Event: hooks.Generated, Path: path, Type: storage.TypeDocument, Fields: fields, Order: &o}); err != nil {
+			return err
+		}
+	}
+	if cfg.Documents.Email.Host == "" {
+		return nil
+	}
+	return afterCommit(ctx, "email "+o.PONumber, func(ctx context.Context) error {
+		emailDocuments(ctx, cfg, o, docs)
+		return nil
+	})
+}
+
+/*
+renderDocuments renders the purchase order and the packing slips of an order
+with the configured templates. The purchase order comes first; packing slips
+are named "packing_slip", suffixed with the warehouse when the order is split.
+*/
+func renderDocuments(cfg *config.Config, o model.Order) ([]renderedDocument, error) {
+	po, err := documents.Parse("purchase order", cfg.Documents.PurchaseOrderTemplate, documents.DefaultPurchaseOrder)
+	if err != nil {
+		return nil, err
+	}
+	slip, err := documents.Parse("packing slip", cfg.Documents.PackingSlipTemplate, documents.DefaultPackingSlip)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now()
+
+	var b bytes.Buffer
+	if err := po.RenderPDF(&b, "Purchase order "+o.PONumber, documents.Data{Order: o, Items: o.Items, Generated: now}, loc); err != nil {
+		return nil, err
+	}
+	docs := []renderedDocument{{name: "po", data: b.Bytes()}}
+	split := orderWarehouses(cfg, o)
+	for _, s := range split {
+		name := "packing_slip"
+		if len(split) > 1 {
+			name += "_" + s.Warehouse
+		}
+		var b bytes.Buffer
+		if err := slip.RenderPDF(&b, "Packing slip "+o.PONumber+" "+s.Warehouse, documents.Data{Order: o, Warehouse: s.Warehouse, Items: s.Items, Generated: now}, loc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, renderedDocument{name: name, warehouse: s.Warehouse, lines: len(s.Items), data: b.Bytes()})
+	}
+	return docs, nil
+}
+
+// documentRecipients returns the addresses of a warehouse, if any rule matches.
+func documentRecipients(cfg *config.Config, warehouse string) []string {
+	for _, r := range cfg.Documents.Email.Recipients {
+		if r.Warehouse == "" || r.Warehouse == warehouse {
+			return r.To
+		}
+	}
+	return nil
+}
+
+/*
+emailDocuments sends every warehouse of an order with recipients the purchase
+order and its packing slip. Failures are warned about.
+*/
+func emailDocuments(ctx context.Context, cfg *config.Config, o model.Order, docs []renderedDocument) {
+	e := cfg.Documents.Email
+	server := mail.Server{Host: e.Host, Port: e.Port, Username: e.Username, Password: e.Password}
+	for _, slip := range docs[1:] {
+		to := documentRecipients(cfg, slip.warehouse)
+		if len(to) == 0 {
+			continue
+		}
+		msg := mail.Message{
+			From:    e.From,
+			To:      to,
+			Subject: fmt.Sprintf("Purchase order %s for %s", o.PONumber, slip.warehouse),
+			Body:    fmt.Sprintf("Attached are purchase order %s and the packing slip of the %d lines %s ships to %s.\n", o.PONumber, slip.lines, slip.warehouse, o.ShipToParty),
+		}
+		for _, d := range []renderedDocument{docs[0], slip} {
+			msg.Attachments = append(msg.Attachments, mail.Attachment{Name: filepath.Base(d.path), ContentType: "application/pdf", Data: d.data})
+		}
+		if err := mail.Send(server, msg); err != nil {
+			logger.LogContext(ctx, utils.LevelWarn, "Failed to email documents of "+o.PONumber+": ", err.Error())
+			continue
+		}
+		logger.PrintColoredContext(ctx, "Emailed documents of "+o.PONumber+": ", strings.Join(to, ", "), "#32CD32")
+	}
+}
@@ -0,0 +1,286 @@
+// cmd/avcimporter/edi.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+cmdEDI implements `avcimporter edi <subcommand>`, a set of tools for working
+with raw X12 files.
+
+Subcommands:
+  - inspect:   Pretty-print the segments of a file and check its envelopes.
+  - to-json:   Convert an X12 file to canonical JSON.
+  - from-json: Convert canonical JSON back to X12.
+  - build:     Render a configured template for a stored purchase order or a shipment.
+*/
+func cmdEDI(args []string) int {
+	if len(args) == 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter edi inspect|to-json|from-json <file> | edi build --template <name> [--shipments file] <PO number|shipment ID>")
+		return 2
+	}
+	switch args[0] {
+	case "inspect":
+		return cmdEDIInspect(args[1:])
+	case "to-json":
+		return cmdEDIConvert("to-json", args[1:])
+	case "from-json":
+		return cmdEDIConvert("from-json", args[1:])
+	case "build":
+		return cmdEDIBuild(args[1:])
+	default:
+		logger.Log(utils.LevelError, "Unknown edi command: ", args[0])
+		return 2
+	}
+}
+
+/*
+inspectedSegment is the machine-readable form of a segment emitted by edi inspect.
+*/
+type inspectedSegment struct {
+	Index    int                `json:"index"`
+	ID       string             `json:"id"`
+	Name     string             `json:"name,omitempty"`
+	Elements []inspectedElement `json:"elements"`
+}
+
+/*
+inspectedElement is one element of an inspectedSegment.
+*/
+type inspectedElement struct {
+	Ref   string `json:"ref"`
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+/*
+cmdEDIInspect implements `avcimporter edi inspect <file>`: it splits the file
+on the delimiters declared in its ISA segment, prints every element in an
+aligned table annotated with its meaning and reports envelope mismatches
+(control numbers and counts of ISA/IEA, GS/GE and ST/SE). Files stored
+compressed (storage.compression) are decompressed first.
+
+Returns 1 if the file cannot be read or parsed or its envelopes do not match.
+*/
+func cmdEDIInspect(args []string) int {
+	fs := flag.NewFlagSet("edi inspect", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter edi inspect <file>")
+		return 2
+	}
+
+	data, err := codec.ReadFile(fs.Arg(0))
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read file: ", err.Error())
+		return 1
+	}
+	doc, err := x12.Parse(data)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to parse EDI: ", err.Error())
+		return 1
+	}
+	issues := doc.ValidateEnvelopes()
+
+	segments := make([]inspectedSegment, 0, len(doc.Segments))
+	for _, seg := range doc.Segments {
+		segments = append(segments, inspectSegment(seg))
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{
+			"command":    "edi inspect",
+			"success":    len(issues) == 0,
+			"delimiters": describeDelimiters(doc.Delimiters),
+			"segments":   segments,
+			"issues":     issues,
+		})
+		if len(issues) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	logger.PrintColored("File: ", fs.Arg(0), "#00FFFF")
+	logger.PrintColored("Delimiters: ", describeDelimiters(doc.Delimiters), "#00FFFF")
+
+	flagged := make(map[int]bool, len(issues))
+	for _, issue := range issues {
+		flagged[issue.Segment] = true
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSEGMENT\tELEMENT\tVALUE\tMEANING")
+	// rowsPerSegment records how many table lines each segment produced so
+	// flagged segments can be colored after alignment.
+	rowsPerSegment := make([]int, len(segments))
+	for i, seg := range segments {
+		fmt.Fprintf(tw, "%d\t%s\t\t\t%s\n", seg.Index+1, seg.ID, seg.Name)
+		for _, el := range seg.Elements {
+			fmt.Fprintf(tw, "\t\t%s\t%s\t%s\n", el.Ref, el.Value, el.Name)
+		}
+		rowsPerSegment[i] = 1 + len(seg.Elements)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00BFFF")
+	lines = lines[1:]
+	for i, n := range rowsPerSegment {
+		color := "#FFFFFF"
+		if flagged[segments[i].Index] {
+			color = "#FF0000"
+		}
+		for _, line := range lines[:n] {
+			logger.PrintColored(line, "", color)
+		}
+		lines = lines[n:]
+	}
+
+	logger.PrintColored("Segments: ", fmt.Sprint(len(segments)), "#00FFFF")
+	if len(issues) == 0 {
+		logger.PrintColored("Envelopes OK", "", "#32CD32")
+		return 0
+	}
+	for _, issue := range issues {
+		logger.Log(utils.LevelError, fmt.Sprintf("Segment %d: ", issue.Segment+1), issue.Message)
+	}
+	return 1
+}
+
+/*
+cmdEDIConvert implements `avcimporter edi to-json` and `edi from-json`. Both
+read a file (or stdin for "-") and write the converted document to stdout or
+--out, so documents can be transformed with tools such as jq:
+
+	avcimporter edi to-json po.edi | jq '...' | avcimporter edi from-json - > out.edi
+
+Compressed input (storage.compression) is decompressed first. Element values
+are kept verbatim, so a file converted to JSON and back is byte-for-byte
+identical. from-json warns about envelope mismatches in the
+result but still writes it.
+*/
+func cmdEDIConvert(name string, args []string) int {
+	fs := flag.NewFlagSet("edi "+name, flag.ContinueOnError)
+	out := fs.String("out", "", "Write the result to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter edi "+name+" [--out file] <file|->")
+		return 2
+	}
+	if *out == "" {
+		// The converted document owns stdout; keep messages out of it.
+		logger.SetOutput(os.Stderr)
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err == nil {
+		data, err = codec.Decode(data)
+	}
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read input: ", err.Error())
+		return 1
+	}
+
+	var result []byte
+	if name == "to-json" {
+		doc, err := x12.Parse(data)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to parse EDI: ", err.Error())
+			return 1
+		}
+		if result, err = x12.ToJSON(doc); err != nil {
+			logger.Log(utils.LevelError, "Failed to encode JSON: ", err.Error())
+			return 1
+		}
+		result = append(result, '\n')
+	} else {
+		doc, err := x12.FromJSON(data)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to convert JSON: ", err.Error())
+			return 1
+		}
+		for _, issue := range doc.ValidateEnvelopes() {
+			logger.Log(utils.LevelWarn, fmt.Sprintf("Warning: segment %d: ", issue.Segment+1), issue.Message)
+		}
+		result = doc.Bytes()
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(result)
+	} else {
+		err = permissions().WriteFile(*out, result)
+	}
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to write output: ", err.Error())
+		return 1
+	}
+	if *out != "" {
+		logger.PrintColored("Wrote: ", *out, "#32CD32")
+	}
+	return 0
+}
+
+// inspectSegment annotates a segment and its elements from the X12 dictionary.
+func inspectSegment(seg x12.Segment) inspectedSegment {
+	out := inspectedSegment{Index: seg.Index, ID: seg.ID, Elements: []inspectedElement{}}
+	if info, ok := x12.LookupSegment(seg.ID); ok {
+		out.Name = info.Name
+	}
+	if seg.ID == "ST" {
+		if name := x12.TransactionSetName(seg.Element(1)); name != "" {
+			out.Name += " (" + seg.Element(1) + " " + name + ")"
+		}
+	}
+	for i, value := range seg.Elements {
+		if value == "" {
+			continue
+		}
+		out.Elements = append(out.Elements, inspectedElement{
+			Ref:   fmt.Sprintf("%s%02d", seg.ID, i+1),
+			Value: value,
+			Name:  x12.ElementName(seg.ID, i+1),
+		})
+	}
+	return out
+}
+
+// describeDelimiters renders delimiters for display, naming invisible characters.
+func describeDelimiters(d x12.Delimiters) string {
+	show := func(c byte) string {
+		switch c {
+		case 0:
+			return "none"
+		case '\n':
+			return `\n`
+		case '\r':
+			return `\r`
+		default:
+			return fmt.Sprintf("%q", c)
+		}
+	}
+	return fmt.Sprintf("element %s, repetition %s, component %s, segment %s",
+		show(d.Element), show(d.Repetition), show(d.Component), show(d.Segment))
+}
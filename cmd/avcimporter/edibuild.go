@@ -0,0 +1,199 @@
+// cmd/avcimporter/edibuild.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/edidoc"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sftpx"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdEDIBuild implements `avcimporter edi build --template <name> <PO number>`:
+it renders the configured template (edi.templates) for a stored purchase order
+inside a complete interchange.
+
+With --shipments the argument is a shipment ID instead, and the template (an
+856) is rendered for that shipment of a shipment status file read exactly as
+`sync-status` reads it, so the EDI ship notice and the Vendor Shipments API
+confirmation are mapped from the same shipment lines.
+
+Without --out or --upload the document is previewed on stdout and no control
+number is used up. Otherwise the next outbound control number for the
+receiver is reserved first (checkpoint "edi:<receiver>:outbound"), so a
+number is never sent twice even if writing or uploading fails. Production
+interchanges (edi.usage "P") are only written with --production.
+*/
+func cmdEDIBuild(args []string) int {
+	fs := flag.NewFlagSet("edi build", flag.ContinueOnError)
+	name := fs.String("template", "", "Template to render (a key of edi.templates)")
+	out := fs.String("out", "", "Write the document to this file")
+	upload := fs.Bool("upload", false, "Upload the document to edi.outboundDir")
+	shipments := fs.String("shipments", "", "Build the ship notice of the shipment ID given as argument from this shipment status file (CSV or JSON, as read by sync-status)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || *name == "" {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter edi build --template <name> [--shipments file] [--out file] [--upload] <PO number|shipment ID>")
+		return 2
+	}
+	preview := *out == "" && !*upload
+	if preview {
+		// The document owns stdout; keep messages out of it.
+		logger.SetOutput(os.Stderr)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	tc, ok := cfg.EDI.Templates[*name]
+	if !ok {
+		logger.Log(utils.LevelError, "Unknown EDI template: ", *name)
+		return 2
+	}
+	var order spapi.PurchaseOrder
+	var shipment spapi.ShipmentConfirmation
+	if *shipments != "" {
+		if shipment, err = loadShipment(cfg, *shipments, fs.Arg(0)); err != nil {
+			logger.Log(utils.LevelError, "Failed to load shipment: ", err.Error())
+			return 1
+		}
+	} else if order, err = storage.LoadOrder(cfg.Storage.SavePath, fs.Arg(0)); err != nil {
+		logger.Log(utils.LevelError, "Failed to load order: ", err.Error())
+		return 1
+	}
+
+	checkpoints := checkpoint.Open(cfg.Storage.CheckpointPath, permissions())
+	key := checkpoint.Key("edi", strings.ToLower(tc.ReceiverID), "outbound")
+	cp, _, err := checkpoints.Load(key)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read control number: ", err.Error())
+		return 1
+	}
+	control, err := nextControlNumber(cp.Value)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid checkpoint: ", key+": "+err.Error())
+		return 1
+	}
+
+	at := time.Now()
+	env := edidoc.Envelope(cfg, tc, control, at)
+	var doc []byte
+	if *shipments != "" {
+		doc, err = edidoc.RenderShipment(cfg, *name, shipment, control, at)
+	} else {
+		doc, err = edidoc.Render(cfg, *name, order, control, at)
+	}
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to build document: ", err.Error())
+		return 1
+	}
+	if preview {
+		if _, err := os.Stdout.Write(append(doc, '\n')); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if err := checkGoLive(cfg); err != nil {
+		logger.Log(utils.LevelError, "Refusing to send: ", err.Error())
+		return 1
+	}
+
+	if err := checkpoints.Save(key, control); err != nil {
+		logger.Log(utils.LevelError, "Failed to reserve control number: ", err.Error())
+		return 1
+	}
+	if *out != "" {
+		if err := permissions().WriteFile(*out, doc); err != nil {
+			logger.Log(utils.LevelError, "Failed to write output: ", err.Error())
+			return 1
+		}
+		logger.PrintColored("Wrote: ", *out, "#32CD32")
+	}
+	ref := order.PurchaseOrderNumber
+	if *shipments != "" {
+		ref = shipment.ShipmentIdentifier
+	}
+	fileName := fmt.Sprintf("%s_%s_%s.edi", tc.SetID, ref, control)
+	if *upload {
+		closeAudit, err := openAudit(cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to open audit log: ", err.Error())
+			return 1
+		}
+		defer closeAudit()
+		conn, err := sftpConn(cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Invalid SFTP settings: ", err.Error())
+			return 1
+		}
+		if err := sftpx.Upload(context.Background(), conn, sftpx.UploadOptions{RemoteDir: cfg.EDI.OutboundDir, FileName: fileName, Data: doc}); err != nil {
+			logger.Log(utils.LevelError, "Failed to upload document: ", err.Error())
+			return 1
+		}
+		logger.PrintColored("Uploaded: ", fileName, "#32CD32")
+	}
+	tracked := lifecycle.Document{Kind: lifecycle.SetKind(tc.SetID), SetID: tc.SetID, Direction: "outbound", Interchange: control, Group: env.GroupControl, Set: env.SetControl, Reference: *out, Status: lifecycle.Generated}
+	if *upload {
+		tracked.Reference, tracked.Status = fileName, lifecycle.Sent
+	}
+	if *shipments != "" {
+		tracked.Warehouse = shipment.ShipFromParty.PartyID
+		trackShipmentDocument(cfg, shipment, tracked)
+	} else {
+		trackDocument(cfg, order.PurchaseOrderNumber, tracked)
+	}
+	if output.IsJSON() {
+		result := map[string]interface{}{"command": "edi build", "success": true, "template": *name, "poNumber": order.PurchaseOrderNumber, "controlNumber": control, "out": *out, "uploaded": *upload, "fileName": fileName}
+		if *shipments != "" {
+			delete(result, "poNumber")
+			result["shipmentId"] = shipment.ShipmentIdentifier
+		}
+		output.Emit(result)
+	}
+	return 0
+}
+
+/*
+checkGoLive guards the switch to production: interchanges marked "P" in ISA15
+are only written or uploaded when --production confirms it, so a config or
+profile change alone cannot start sending live documents.
+*/
+func checkGoLive(cfg *config.Config) error {
+	if cfg.EDI.Usage == "P" && !production {
+		return fmt.Errorf("edi.usage is \"P\" (production); pass --production to confirm")
+	}
+	return nil
+}
+
+// nextControlNumber returns the nine-digit interchange control number after
+// last, wrapping from 999999999 back to 1.
+func nextControlNumber(last string) (string, error) {
+	n := 0
+	if last != "" {
+		var err error
+		if n, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+			return "", fmt.Errorf("control number %q is not numeric", last)
+		}
+	}
+	n++
+	if n > 999999999 {
+		n = 1
+	}
+	return fmt.Sprintf("%09d", n), nil
+}
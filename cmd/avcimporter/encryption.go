@@ -0,0 +1,37 @@
+// cmd/avcimporter/encryption.go
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/config"
+)
+
+/*
+applyEncryption installs the key of storage.encryption, read from the config
+or from keyFile, so stored orders and state files are encrypted on write and
+decrypted on read. Without a key, files are written plain.
+
+Returns:
+  - An error if the key file cannot be read or holds no valid key.
+*/
+func applyEncryption(cfg *config.Config) error {
+	text := cfg.Storage.Encryption.Key
+	if f := cfg.Storage.Encryption.KeyFile; f != "" {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read storage.encryption.keyFile: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return codec.SetKey(nil)
+	}
+	key, err := codec.ParseKey(text)
+	if err != nil {
+		return fmt.Errorf("invalid storage.encryption key: %w", err)
+	}
+	return codec.SetKey(key)
+}
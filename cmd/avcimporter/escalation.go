@@ -0,0 +1,65 @@
+// cmd/avcimporter/escalation.go
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/buildinfo"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/incident"
+	"github.com/heinrichb/avcimporter/pkg/scheduler"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// newNotifier returns the on-call service selected by cfg.Escalation, or nil when escalation is disabled.
+func newNotifier(cfg *config.Config) incident.Notifier {
+	e := cfg.Escalation
+	switch e.Provider {
+	case "pagerduty":
+		return &incident.PagerDuty{RoutingKey: e.RoutingKey, URL: e.URL}
+	case "opsgenie":
+		return &incident.Opsgenie{APIKey: e.APIKey, URL: e.URL}
+	}
+	return nil
+}
+
+/*
+escalate passes a finished scheduled run to a.escalator, opening an incident
+once the flow has failed cfg.Escalation.After times in a row and resolving it
+after the next success. Runs cut short by shutdown are not counted. Failures
+to reach the on-call service are logged; they do not fail the run.
+*/
+func (a *app) escalate(r scheduler.Result) {
+	cfg := a.holder.Get()
+	n := newNotifier(cfg)
+	if n == nil || errors.Is(r.Err, context.Canceled) {
+		return
+	}
+	host, _ := os.Hostname()
+	details := map[string]string{
+		"host":     host,
+		"version":  buildinfo.Get().Version,
+		"started":  r.Started.UTC().Format(time.RFC3339),
+		"finished": r.Finished.UTC().Format(time.RFC3339),
+	}
+	if cfg.Profile != "" {
+		details["profile"] = cfg.Profile
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = utils.WithLogPrefix(ctx, flowLabel(cfg, r.Job))
+	outcome, err := a.escalator.Record(ctx, n, cfg.Escalation.After, incident.Run{
+		Flow: r.Job, Finished: r.Finished, Err: r.Err, Source: host, Details: details,
+	})
+	switch {
+	case err != nil:
+		logger.LogContext(ctx, utils.LevelError, "Escalation failed: ", err.Error())
+	case outcome == incident.Triggered:
+		logger.LogContext(ctx, utils.LevelError, "Incident opened in "+cfg.Escalation.Provider+": ", incident.Key(r.Job))
+	case outcome == incident.Resolved:
+		logger.PrintColoredContext(ctx, "Incident resolved in "+cfg.Escalation.Provider+": ", incident.Key(r.Job), "#32CD32")
+	}
+}
@@ -0,0 +1,886 @@
+// cmd/avcimporter/flows.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/dedupe"
+	"github.com/heinrichb/avcimporter/pkg/edidoc"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sftpx"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/staging"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/workpool"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+runEDIFlow downloads inbound files from the EDI SFTP server and splits each
+interchange into its transaction sets. Unless edi.keepRemote is set, a file
+is removed from the server only once it cleared the hooks and was parsed and
+stored; a file that failed stays there and is fetched again by the next run.
+
+With edi.keepRemote the files stay on the server, so the position of the last
+file imported is kept as a checkpoint ("sftp:<host>:<inboundDir>", saved once
+the run is committed) and the next run lists only what follows it (changing
+edi.sortBy calls for deleting that checkpoint); edi.maxFiles spreads a large
+backlog over several runs. The position stops before the first file that
+failed, so it is fetched again together with the files after it; those
+already imported are then skipped if storage.inboundIndex is set.
+*/
+func runEDIFlow(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store) error {
+	conn, err := sftpConn(cfg)
+	if err != nil {
+		return err
+	}
+	opts := sftpx.FetchOptions{RemoteDir: cfg.EDI.InboundDir, LocalDir: savePath(ctx, cfg), Keep: true, Limit: cfg.EDI.MaxFiles, SortBy: cfg.EDI.SortBy, Progress: progress}
+	cursorKey := checkpoint.Key("sftp", cfg.EDI.Host, cfg.EDI.InboundDir)
+	if cfg.EDI.KeepRemote {
+		cp, found, err := checkpoints.Load(cursorKey)
+		if err != nil {
+			return err
+		}
+		if found {
+			opts.After = cp.Value
+		}
+	}
+	downloaded, err := sftpx.Fetch(ctx, conn, opts)
+	// Files fetched before a failure are recorded (and listed in the manifest)
+	// either way; they are still on the server and are fetched again next run.
+	result := output.FromContext(ctx)
+	files := make([]string, 0, len(downloaded))
+	for _, f := range downloaded {
+		logger.PrintColoredContext(ctx, "Downloaded remote file: ", f.LocalPath, "#00FFFF")
+		files = append(files, f.LocalPath)
+		result.AddArtifacts(output.Artifact{
+			Path:         f.LocalPath,
+			RemotePath:   f.RemotePath,
+			Size:         f.Size,
+			SHA256:       f.SHA256,
+			DownloadedAt: f.DownloadedAt,
+		})
+	}
+	result.AddFiles(files...)
+	if err != nil {
+		return fmt.Errorf("SFTP download failed: %w", err)
+	}
+
+	// Files rejected by a hook (e.g. a virus scanner) are kept but not parsed.
+	var errs []error
+	var done []sftpx.File
+	handled := make([]bool, len(downloaded))
+	for i, f := range downloaded {
+		if err := importDownloaded(ctx, cfg, checkpoints, f); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		handled[i] = true
+		done = append(done, f)
+	}
+	// The cursor only passes files imported without a gap, so a failed file
+	// is fetched again next run.
+	if cursor := sftpx.Resume(opts.After, downloaded, handled); cfg.EDI.KeepRemote && cursor != opts.After {
+		store := checkpoints
+		if stage := staging.FromContext(ctx); stage != nil {
+			store = checkpoint.Open(stage.Unstaged(checkpoints.Path()), permissions())
+		}
+		errs = append(errs, afterCommit(ctx, "save SFTP cursor", func(ctx context.Context) error {
+			return store.Save(cursorKey, cursor)
+		}))
+	}
+	if !cfg.EDI.KeepRemote && len(done) > 0 {
+		err := afterCommit(ctx, "remove remote files", func(ctx context.Context) error {
+			removed, err := sftpx.Remove(ctx, conn, done)
+			for _, f := range removed {
+				logger.PrintColoredContext(ctx, "Removed remote file: ", f.RemotePath, "#00FFFF")
+			}
+			if err != nil {
+				return fmt.Errorf("SFTP remove failed: %w", err)
+			}
+			return nil
+		})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+/*
+importDownloaded runs the Downloaded hooks on one fetched file, extracts it
+if edi.decompress is set and parses every interchange it yields.
+
+Returns:
+  - An error if any step failed for the file or one of its parts.
+*/
+func importDownloaded(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store, f sftpx.File) error {
+	if err := runHooks(ctx, hooks.Event{Event: hooks.Downloaded, Path: f.LocalPath, Type: "inbound", RemotePath: f.RemotePath, Size: f.Size, SHA256: f.SHA256}); err != nil {
+		return err
+	}
+	files := []string{f.LocalPath}
+	var errs []error
+	if cfg.EDI.Decompress {
+		files, errs = decompressInbound(ctx, cfg, files)
+	}
+	for _, p := range files {
+		if err := processInterchange(ctx, cfg, checkpoints, p); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(p), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+decompressInbound extracts gzip/zip files among the downloaded ones, moving
+the originals to storage.archivePath. It returns the files to parse; a file
+that cannot be extracted is reported and left where it was.
+*/
+func decompressInbound(ctx context.Context, cfg *config.Config, files []string) ([]string, []error) {
+	var out []string
+	var errs []error
+	for _, f := range files {
+		extracted, err := storage.Decompress(f, stagedPath(ctx, cfg.Storage.ArchivePath), permissions())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(f), err))
+			continue
+		}
+		if len(extracted) != 1 || extracted[0] != f {
+			logger.PrintColoredContext(ctx, "Decompressed inbound file: ", fmt.Sprintf("%s (%d file(s))", f, len(extracted)), "#00FFFF")
+			output.FromContext(ctx).AddFiles(extracted...)
+		}
+		out = append(out, extracted...)
+	}
+	return out, errs
+}
+
+/*
+processInterchange handles one downloaded file. Amazon batches several
+purchase orders into one interchange, so every transaction set of every
+functional group is stored on its own as a standalone interchange. With
+edi.acknowledge set, a 997 acknowledging each set (one AK2/AK5 loop per set)
+is uploaded to the outbound directory; with edi.usage "P" that requires
+--production (see checkGoLive), otherwise the file is not processed at all.
+The interchange control number is then
+recorded as the partner's inbound checkpoint ("edi:<partner>:inbound").
+
+The sets are stored, passed to the hooks and tracked on processing.workers
+workers, each retried under processing.retry; sets of one PO are processed in
+the order they appear. A set still failing fails the file. Exports with an
+orderBy receive the documents of the interchange once every set is
+processed, sorted (see flushOrdered).
+
+Files that are not X12 are left as downloaded and reported as errors; one bad
+file does not stop the others from being processed.
+
+With storage.inboundIndex set, the content hash of the file is claimed in that
+index first; content processed before, by this or another machine sharing the
+directory, is skipped so it never reaches the ERP twice. With
+storage.dedupeChannels, an 850 for a PO already imported from SP‑API is not
+stored either; the 997 still acknowledges it.
+*/
+func processInterchange(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store, file string) (err error) {
+	if cfg.EDI.Acknowledge {
+		if err := checkGoLive(cfg); err != nil {
+			return fmt.Errorf("not acknowledging %s: %w", filepath.Base(file), err)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var outputs []string
+	if cfg.Storage.InboundIndex != "" {
+		index := storage.OpenIndex(cfg.Storage.InboundIndex, permissions())
+		claimed, entry, err := index.Claim(storage.IndexEntry{SHA256: storage.FileSHA256(data), Source: filepath.Base(file)})
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			logger.LogContext(ctx, utils.LevelWarn, "Skipping already processed file: ", fmt.Sprintf("%s (as %s on %s at %s)", filepath.Base(file), entry.Source, entry.Host, entry.ProcessedAt.Format(time.RFC3339)))
+			return nil
+		}
+		defer func() {
+			if err != nil {
+				if rerr := index.Release(entry.SHA256); rerr != nil {
+					logger.LogContext(ctx, utils.LevelWarn, "Failed to release index entry: ", rerr.Error())
+				}
+				return
+			}
+			if stage := staging.FromContext(ctx); stage != nil {
+				stage.OnDiscard(func() error { return index.Release(entry.SHA256) })
+			}
+			err = afterCommit(ctx, "index "+entry.Source, func(ctx context.Context) error {
+				for _, p := range outputs {
+					entry.Outputs = append(entry.Outputs, finalPath(ctx, p))
+				}
+				return index.Complete(entry)
+			})
+		}()
+	}
+	doc, err := x12.Parse(data)
+	if err != nil {
+		return fmt.Errorf("not an X12 interchange: %w", err)
+	}
+
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return err
+	}
+	var channels dedupe.Deduper
+	if cfg.Storage.DedupeChannels {
+		channels = newDeduper(cfg)
+	}
+	isa, _ := doc.Find("ISA")
+	partner := strings.TrimSpace(isa.Element(6))
+	// Amounts are read in the partner's decimal convention, or by their look.
+	var decimal byte
+	if opts, err := cfg.X12Options(partner); err == nil {
+		decimal = opts.Decimal
+	}
+	result := output.FromContext(ctx)
+	sets := doc.Split()
+	// Exports with an orderBy hold the documents of the interchange until all sets are processed.
+	seqCtx, ordered := hooks.WithSequence(ctx)
+	pool := workpool.New(seqCtx, cfg.Processing.Workers)
+	defer func() { err = errors.Join(err, flushOrdered(ctx, ordered)) }()
+	// Each set writes only its own entry, so the workers need no lock.
+	stored := make([]string, len(sets))
+	for i, set := range sets {
+		st, _ := set.Find("ST")
+		var order *model.Order
+		if st.Element(1) == "850" {
+			if o, err := model.FromX12(set, decimal); err != nil {
+				logger.LogContext(ctx, utils.LevelWarn, "Failed to read purchase order: ", err.Error())
+			} else {
+				order = &o
+			}
+		}
+		if channels != nil && order != nil {
+			other, err := dedupe.OtherChannel(channels, *order)
+			if err != nil {
+				pool.Wait()
+				return err
+			}
+			if other != "" {
+				logger.LogContext(ctx, utils.LevelWarn, "Skipped order already imported via "+other+": ", fmt.Sprintf("%s (850 %s)", order.PONumber, st.Element(2)))
+				continue
+			}
+		}
+
+		fields := map[string]string{"setId": st.Element(1), "source": filepath.Base(file)}
+		key := st.Element(1) + " " + st.Element(2)
+		if beg, ok := set.Find("BEG"); ok {
+			fields["poNumber"] = beg.Element(3)
+			key = beg.Element(3)
+		}
+		err := pool.Submit(key, func(ctx context.Context) error {
+			var path string
+			err := utils.Retry(ctx, retryPolicy(cfg.Processing.Retry, "transaction set "+key), func(ctx context.Context) error {
+				var err error
+				path, err = storage.SaveTransactionSet(savePath(ctx, cfg), namer, set)
+				if err != nil {
+					return fmt.Errorf("failed to store transaction set: %w", err)
+				}
+				if err := runHooks(ctx, hooks.Event{Event: hooks.Generated, Path: path, Type: storage.TypeTransactionSet, Fields: fields, Order: order}); err != nil {
+					return err
+				}
+				if order != nil {
+					return storeDocuments(ctx, cfg, namer, *order)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			stored[i] = path
+			result.AddFiles(path)
+			if po, ok := fields["poNumber"]; ok {
+				result.AddPONumbers(po)
+			}
+			trackTransactionSet(cfg, set, order, finalPath(ctx, path))
+			if channels != nil && order != nil {
+				return channels.Mark(dedupe.ChannelKey(model.SourceEDI, order.PONumber))
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+	}
+	// A failed set stops the loop with workpool.ErrStopped; report the failure itself.
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	for _, p := range stored {
+		if p != "" {
+			outputs = append(outputs, p)
+		}
+	}
+	logger.PrintColoredContext(ctx, fmt.Sprintf("Split %s into transaction sets: ", filepath.Base(file)), fmt.Sprint(len(sets)), "#32CD32")
+
+	if cfg.EDI.Acknowledge {
+		ack, err := edidoc.Ack(cfg, data)
+		if err != nil {
+			return err
+		}
+		name, err := namer.Name(storage.TypeAck, storage.NameData{
+			Partner:       partner,
+			Source:        filepath.Base(file),
+			ControlNumber: isa.Element(13),
+		})
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		var groups []string
+		for _, g := range doc.Groups() {
+			groups = append(groups, g.ControlNumber())
+		}
+		err = afterCommit(ctx, "997 "+name, func(ctx context.Context) error {
+			conn, err := sftpConn(cfg)
+			if err != nil {
+				return err
+			}
+			if err := sftpx.Upload(ctx, conn, sftpx.UploadOptions{RemoteDir: cfg.EDI.OutboundDir, FileName: name, Data: ack}); err != nil {
+				return fmt.Errorf("failed to upload 997: %w", err)
+			}
+			logger.PrintColoredContext(ctx, "Uploaded acknowledgment: ", name, "#32CD32")
+			recordAudit(audit.Event{
+				Action:        audit.EDIAcknowledge,
+				Target:        path.Join(strings.TrimPrefix(cfg.EDI.OutboundDir, "/"), name),
+				ControlNumber: isa.Element(13),
+				SHA256:        audit.Sum(ack),
+				Bytes:         int64(len(ack)),
+				Fields:        map[string]string{"partner": partner, "source": filepath.Base(file), "groups": strings.Join(groups, ",")},
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	key := checkpoint.Key("edi", strings.ToLower(partner), "inbound")
+	if err := checkpoints.Save(key, isa.Element(13)); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+newNamer builds the file name templates, compression and permissions configured in
+cfg.Storage.
+*/
+func newNamer(cfg *config.Config) (*storage.Namer, error) {
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, err
+	}
+	namer, err := storage.NewNamer(cfg.Storage.FileName, cfg.Storage.FileNames, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage settings: %w", err)
+	}
+	c, err := codec.Lookup(cfg.Storage.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage settings: %w", err)
+	}
+	namer.SetCompression(c)
+	namer.SetPermissions(permissions())
+	return namer, nil
+}
+
+/*
+parseWindow parses --since and --until relative to now.
+
+Returns:
+  - An error if a flag is invalid, the window is empty or it is combined with --daemon.
+*/
+func parseWindow(now time.Time) (err error) {
+	if windowSince, err = parseSince(since, now); err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	if windowUntil, err = parseSince(until, now); err != nil {
+		return fmt.Errorf("--until: %w", err)
+	}
+	if !windowSince.IsZero() && !windowUntil.IsZero() && !windowUntil.After(windowSince) {
+		return fmt.Errorf("--until must be after --since")
+	}
+	if windowOverridden() && daemon {
+		return fmt.Errorf("--since and --until apply to a single run, not to --daemon")
+	}
+	return nil
+}
+
+// windowOverridden reports whether --since or --until is set.
+func windowOverridden() bool {
+	return !windowSince.IsZero() || !windowUntil.IsZero()
+}
+
+/*
+dataWindow returns the data window of a checkpointed request: from the
+checkpoint (or lookback before now on the first run) to now. --since and
+--until replace either end for a single run.
+
+Parameters:
+  - cp, found: The request's checkpoint, as returned by checkpoint.Store.Load.
+  - lookback:  Start of the first window before now.
+
+Returns:
+  - The window.
+  - Whether the checkpoint may be moved to its end; not when --since or
+    --until chose it, so re-pulling an older window does not rewind the flow.
+  - An error if the checkpoint is not a time.
+*/
+func dataWindow(key string, cp checkpoint.Checkpoint, found bool, lookback time.Duration) (start, end time.Time, save bool, err error) {
+	end = time.Now().UTC().Truncate(time.Second)
+	start = end.Add(-lookback)
+	if found {
+		if start, err = time.Parse(time.RFC3339, cp.Value); err != nil {
+			return start, end, false, fmt.Errorf("invalid checkpoint %s: %w", key, err)
+		}
+	}
+	if !windowSince.IsZero() {
+		start = windowSince.UTC()
+	}
+	if !windowUntil.IsZero() {
+		end = windowUntil.UTC()
+	}
+	return start, end, !windowOverridden(), nil
+}
+
+/*
+runAPIFlow fetches an OAuth2 token, pulls data from the SP‑API endpoint and
+stores any purchase orders it returns.
+
+The purchase order date of the newest stored order is kept as the endpoint's
+checkpoint (e.g. "spapi:NA:purchaseOrders"); later runs against the purchase
+orders endpoint only request orders created after it.
+
+With --since or --until the run requests orders created or changed in that
+window instead (from the checkpoint without --since) and leaves the
+checkpoints and ETags as they are.
+*/
+func runAPIFlow(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store) error {
+	key := apiCheckpointKey(cfg)
+	cp, found, err := checkpoints.Load(key)
+	if err != nil {
+		return err
+	}
+
+	token, err := fetchOAuthToken(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error fetching OAuth2 token: %w", err)
+	}
+	purchaseOrders := strings.HasSuffix(cfg.API.EndpointURL, "/purchaseOrders")
+	if purchaseOrders && windowOverridden() {
+		return fetchOrderWindow(ctx, cfg, token, cp.Value)
+	}
+	var query url.Values
+	if found && purchaseOrders {
+		query = url.Values{"createdAfter": {cp.Value}}
+	}
+	seen := newDeduper(cfg)
+	var page orderPage
+	err = fetchFromAPI(ctx, cfg, token, query, checkpoints, func(body io.Reader) (err error) {
+		page, err = storeOrders(ctx, cfg, body, seen)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching data from API: %w", err)
+	}
+	if found && purchaseOrders {
+		if err := fetchChangedOrders(ctx, cfg, checkpoints, token, cp.Value, seen); err != nil {
+			return err
+		}
+	}
+
+	if page.Newest.IsZero() {
+		return nil
+	}
+	return advanceAPICheckpoint(checkpoints, key, page.Newest)
+}
+
+/*
+fetchChangedOrders re-fetches purchase orders Amazon changed since the last
+run (changedAfter), which the createdAfter query never returns again. Only
+versions not imported yet are stored; the position is kept under
+"<api checkpoint key>:changed" and starts at since.
+*/
+func fetchChangedOrders(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store, token, since string, seen dedupe.Deduper) error {
+	key := checkpoint.Key(apiCheckpointKey(cfg), "changed")
+	cp, found, err := checkpoints.Load(key)
+	if err != nil {
+		return err
+	}
+	if found {
+		since = cp.Value
+	}
+	var page orderPage
+	err = fetchFromAPI(ctx, cfg, token, url.Values{"changedAfter": {since}}, checkpoints, func(body io.Reader) (err error) {
+		page, err = storeOrders(ctx, cfg, body, seen)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching changed orders: %w", err)
+	}
+	if page.NewestChange.IsZero() {
+		return nil
+	}
+	return advanceAPICheckpoint(checkpoints, key, page.NewestChange)
+}
+
+/*
+fetchOrderWindow implements --since and --until for the purchase orders
+endpoint: it stores the orders created and those changed in the window without
+reading or saving checkpoints. Without --since the window starts at from, the
+import checkpoint; without either the whole history would be requested, so
+--until alone needs a checkpoint.
+*/
+func fetchOrderWindow(ctx context.Context, cfg *config.Config, token, from string) error {
+	if !windowSince.IsZero() {
+		from = windowSince.UTC().Format(time.RFC3339)
+	}
+	if from == "" {
+		return fmt.Errorf("--until needs --since before the first import")
+	}
+	logger.LogContext(ctx, utils.LevelWarn, "Fetching the window from --since/--until: ", windowLabel(from))
+	seen := newDeduper(cfg)
+	for _, field := range []string{"created", "changed"} {
+		query := url.Values{field + "After": {from}}
+		if !windowUntil.IsZero() {
+			query.Set(field+"Before", windowUntil.UTC().Format(time.RFC3339))
+		}
+		err := fetchFromAPI(ctx, cfg, token, query, nil, func(body io.Reader) error {
+			_, err := storeOrders(ctx, cfg, body, seen)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error fetching %s orders: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// windowLabel describes the --since/--until window starting at from.
+func windowLabel(from string) string {
+	if windowUntil.IsZero() {
+		return from + " to now"
+	}
+	return from + " to " + windowUntil.UTC().Format(time.RFC3339)
+}
+
+// advanceAPICheckpoint moves the SP‑API checkpoint key forward to newest; an
+// older value never replaces a newer one.
+func advanceAPICheckpoint(checkpoints *checkpoint.Store, key string, newest time.Time) error {
+	cp, found, err := checkpoints.Load(key)
+	if err != nil {
+		return err
+	}
+	if found {
+		if prev, err := time.Parse(time.RFC3339, cp.Value); err == nil && !newest.After(prev) {
+			return nil
+		}
+	}
+	return checkpoints.Save(key, newest.UTC().Format(time.RFC3339))
+}
+
+/*
+apiCheckpointKey names the checkpoint of the configured SP‑API endpoint after
+its region and resource, e.g. "spapi:NA:purchaseOrders" for
+https://sellingpartnerapi-na.amazon.com/vendor/orders/v1/purchaseOrders.
+*/
+func apiCheckpointKey(cfg *config.Config) string {
+	resource := strings.SplitN(cfg.API.EndpointURL, "?", 2)[0]
+	resource = path.Base(strings.TrimSuffix(resource, "/"))
+	return checkpoint.Key("spapi", apiRegion(cfg), resource)
+}
+
+// apiRegion derives the SP‑API region ("NA", "EU", "FE") from the base URL.
+func apiRegion(cfg *config.Config) string {
+	region := cfg.API.BaseURL
+	if u, err := url.Parse(cfg.API.BaseURL); err == nil && u.Host != "" {
+		region = u.Hostname()
+	}
+	if i := strings.Index(region, "sellingpartnerapi-"); i >= 0 {
+		region = strings.ToUpper(strings.SplitN(region[i+len("sellingpartnerapi-"):], ".", 2)[0])
+	}
+	return region
+}
+
+/*
+runReportsFlow requests every configured SP‑API report, waits for Amazon to
+process it, and stores the downloaded (decrypted and decompressed) document.
+
+Each report type keeps the end of its last data window as a checkpoint (e.g.
+"spapi:NA:reports:GET_VENDOR_SALES_REPORT"); the next run requests data from
+there to now. The first run looks back by the report's lookback period. A
+failing report does not stop the others.
+*/
+func runReportsFlow(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store) error {
+	token, err := fetchOAuthToken(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error fetching OAuth2 token: %w", err)
+	}
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return err
+	}
+	client := newSPAPIClient(cfg, token)
+
+	var errs []error
+	for _, rc := range cfg.Reports.Requests {
+		if err := fetchReport(ctx, cfg, client, namer, checkpoints, rc); err != nil {
+			logger.LogContext(ctx, utils.LevelError, "Report failed: ", rc.ReportType+": "+err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", rc.ReportType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+fetchReport runs the create, poll, download cycle for one report and moves its
+checkpoint to the end of the requested data window once the file is stored,
+unless --since or --until chose the window.
+*/
+func fetchReport(ctx context.Context, cfg *config.Config, client *spapi.Client, namer *storage.Namer, checkpoints *checkpoint.Store, rc config.ReportConfig) error {
+	key := checkpoint.Key("spapi", apiRegion(cfg), "reports", rc.ReportType)
+	cp, found, err := checkpoints.Load(key)
+	if err != nil {
+		return err
+	}
+	start, end, save, err := dataWindow(key, cp, found, time.Duration(rc.Lookback))
+	if err != nil {
+		return err
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	id, err := client.CreateReport(ctx, spapi.ReportSpec{
+		ReportType:     rc.ReportType,
+		MarketplaceIDs: cfg.Reports.MarketplaceIDs,
+		DataStartTime:  &start,
+		DataEndTime:    &end,
+		ReportOptions:  rc.Options,
+	})
+	if err != nil {
+		return err
+	}
+	logger.PrintColoredContext(ctx, "Requested report: ", rc.ReportType+" ("+id+")", "#32CD32")
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Reports.Timeout))
+	defer cancel()
+	report, err := client.WaitForReport(waitCtx, id, time.Duration(cfg.Reports.PollInterval))
+	if err != nil {
+		return err
+	}
+	doc, err := client.GetReportDocument(ctx, report.ReportDocumentID)
+	if err != nil {
+		return err
+	}
+
+	var h *audit.Hasher
+	path, err := storage.SaveReport(savePath(ctx, cfg), namer, storage.NameData{ReportType: rc.ReportType, Ext: rc.Extension, Time: end}, func(w io.Writer) error {
+		h = audit.NewHasher(w)
+		_, err := client.DownloadReportDocument(ctx, doc, h)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logger.PrintColoredContext(ctx, "Stored report: ", fmt.Sprintf("%s (%d bytes)", path, h.Bytes()), "#32CD32")
+	if err := recordDownload(ctx, path, storage.TypeReport, "/reports/2021-06-30/documents/"+doc.ReportDocumentID, h, map[string]string{"reportType": rc.ReportType}); err != nil {
+		return err
+	}
+	if !save {
+		return nil
+	}
+	return checkpoints.Save(key, end.Format(time.RFC3339))
+}
+
+/*
+runDataKioskFlow submits every configured Data Kiosk query, waits for it to
+finish and stores the JSONL result.
+
+Each query keeps the end of its last data window as a checkpoint (e.g.
+"datakiosk:vendor-sales"); the next run renders the query for the period from
+there to now. The first run looks back by the query's lookback period. A
+failing query does not stop the others.
+*/
+func runDataKioskFlow(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store) error {
+	token, err := fetchOAuthToken(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error fetching OAuth2 token: %w", err)
+	}
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return err
+	}
+	client := newSPAPIClient(cfg, token)
+
+	var errs []error
+	for _, q := range cfg.DataKiosk.Queries {
+		if err := runQuery(ctx, cfg, client, namer, checkpoints, q); err != nil {
+			logger.LogContext(ctx, utils.LevelError, "Data Kiosk query failed: ", q.Name+": "+err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", q.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+runQuery renders one query for its data window, runs it and moves its
+checkpoint to the end of the window once the result is stored (unless --since
+or --until chose the window). A query that
+matches no data stores no file but still advances the checkpoint.
+*/
+func runQuery(ctx context.Context, cfg *config.Config, client *spapi.Client, namer *storage.Namer, checkpoints *checkpoint.Store, q config.DataKioskQuery) error {
+	key := checkpoint.Key("datakiosk", q.Name)
+	cp, found, err := checkpoints.Load(key)
+	if err != nil {
+		return err
+	}
+	start, end, save, err := dataWindow(key, cp, found, time.Duration(q.Lookback))
+	if err != nil {
+		return err
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	query, err := renderQuery(q.File, start, end)
+	if err != nil {
+		return err
+	}
+	id, err := client.CreateQuery(ctx, query)
+	if err != nil {
+		return err
+	}
+	logger.PrintColoredContext(ctx, "Submitted Data Kiosk query: ", q.Name+" ("+id+")", "#32CD32")
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.DataKiosk.Timeout))
+	defer cancel()
+	result, err := client.WaitForQuery(waitCtx, id, time.Duration(cfg.DataKiosk.PollInterval))
+	if err != nil {
+		return err
+	}
+	if result.DataDocumentID == "" {
+		logger.LogContext(ctx, utils.LevelWarn, "No data for query: ", q.Name)
+		if !save {
+			return nil
+		}
+		return checkpoints.Save(key, end.Format(time.RFC3339))
+	}
+	doc, err := client.GetQueryDocument(ctx, result.DataDocumentID)
+	if err != nil {
+		return err
+	}
+
+	var h *audit.Hasher
+	path, err := storage.SaveQueryResult(savePath(ctx, cfg), namer, storage.NameData{Query: q.Name, Time: end}, func(w io.Writer) error {
+		h = audit.NewHasher(w)
+		_, err := client.DownloadQueryDocument(ctx, doc, h)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logger.PrintColoredContext(ctx, "Stored Data Kiosk result: ", fmt.Sprintf("%s (%d bytes)", path, h.Bytes()), "#32CD32")
+	if err := recordDownload(ctx, path, storage.TypeDataKiosk, "/dataKiosk/2023-11-15/documents/"+doc.DocumentID, h, map[string]string{"query": q.Name}); err != nil {
+		return err
+	}
+	if !save {
+		return nil
+	}
+	return checkpoints.Save(key, end.Format(time.RFC3339))
+}
+
+// recordDownload adds a stored document to the run's files and manifest and
+// passes it to the hooks.
+func recordDownload(ctx context.Context, path, typ, remotePath string, h *audit.Hasher, fields map[string]string) error {
+	result := output.FromContext(ctx)
+	result.AddFiles(path)
+	result.AddArtifacts(output.Artifact{
+		Path:         path,
+		RemotePath:   remotePath,
+		Size:         h.Bytes(),
+		SHA256:       h.Sum(),
+		DownloadedAt: time.Now().UTC(),
+	})
+	return runHooks(ctx, hooks.Event{Event: hooks.Downloaded, Path: path, Type: typ, RemotePath: remotePath, Size: h.Bytes(), SHA256: h.Sum(), Fields: fields})
+}
+
+// runHooks passes a file of the current flow to the configured hooks. Only a
+// failing required hook is returned; other failures are logged.
+func runHooks(ctx context.Context, e hooks.Event) error {
+	if r := output.FromContext(ctx); r != nil {
+		e.Flow = r.Flow
+	}
+	seq := hooks.SequenceFromContext(ctx)
+	run := func(ctx context.Context) error {
+		return hooks.Run(hooks.ContextWithSequence(ctx, seq), e, func(hook string, err error) {
+			logger.LogContext(ctx, utils.LevelWarn, "Hook failed: ", hook+": "+err.Error())
+		})
+	}
+	// In a staged run, files are exported once they reach storage.
+	if e.Event == hooks.Generated || e.Event == hooks.Changed {
+		e.Path = finalPath(ctx, e.Path)
+		return afterCommit(ctx, e.Event+" "+e.Path, run)
+	}
+	return run(ctx)
+}
+
+/*
+flushOrdered releases the documents exporters with an orderBy held during a
+batch (see hooks.Ordered), once the batch is processed or, when the run is
+staged, committed. Only the failure of a required exporter is returned; other
+failures are logged.
+*/
+func flushOrdered(ctx context.Context, seq *hooks.Sequence) error {
+	return afterCommit(ctx, "ordered exports", func(ctx context.Context) error {
+		return seq.Flush(ctx, func(hook string, err error) {
+			logger.LogContext(ctx, utils.LevelWarn, "Hook failed: ", hook+": "+err.Error())
+		})
+	})
+}
+
+/*
+renderQuery reads a GraphQL query file and fills in its data window.
+*/
+func renderQuery(file string, start, end time.Time) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read query: %w", err)
+	}
+	t, err := template.New(filepath.Base(file)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid query template %s: %w", file, err)
+	}
+	var b strings.Builder
+	err = t.Execute(&b, map[string]string{
+		"Start":     start.Format(time.RFC3339),
+		"End":       end.Format(time.RFC3339),
+		"StartDate": start.Format("2006-01-02"),
+		"EndDate":   end.Format("2006-01-02"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render query %s: %w", file, err)
+	}
+	return b.String(), nil
+}
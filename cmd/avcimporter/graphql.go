@@ -0,0 +1,231 @@
+// cmd/avcimporter/graphql.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/graphql"
+	"github.com/heinrichb/avcimporter/pkg/history"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+)
+
+/*
+graphOrder is a stored purchase order as served by GraphQL, with where it
+stands when its lifecycle is tracked.
+*/
+type graphOrder struct {
+	model.Order
+	Status *orderStatus `json:"status,omitempty"`
+}
+
+/*
+graphDocument is a lifecycle document with the PO number it belongs to.
+*/
+type graphDocument struct {
+	PONumber string `json:"poNumber"`
+	lifecycle.Document
+}
+
+/*
+graphQLSchema is the schema served on /graphql: read-only queries over the
+stored orders, the lifecycle store and the run history, read afresh on every
+request with the configuration in effect. Orders have the JSON fields of
+model.Order plus a status as in `status --json`; runs those of `history --json`.
+
+Fields:
+  - orders(poNumber, state, since, until, limit): Stored orders, newest first, with their status.
+  - order(poNumber!):                             One stored order.
+  - documents(poNumber, kind, status):            Lifecycle documents, newest first.
+  - lifecycle(poNumber, open):                    Status of tracked orders, with their documents.
+  - runs(flow, since, failedOnly, limit):         Flow runs, newest first.
+*/
+func (a *app) graphQLSchema() *graphql.Schema {
+	return &graphql.Schema{Query: map[string]graphql.Field{
+		"orders": {
+			Description: "Stored purchase orders dated within since..until (YYYY-MM-DD or RFC 3339), newest first.",
+			Args:        map[string]string{"poNumber": "String", "state": "String", "since": "String", "until": "String", "limit": "Int"},
+			Resolve:     a.graphOrders,
+		},
+		"order": {
+			Description: "One stored purchase order.",
+			Args:        map[string]string{"poNumber": "String!"},
+			Resolve: func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				orders, err := a.graphOrders(ctx, args)
+				if err != nil {
+					return nil, err
+				}
+				if list := orders.([]graphOrder); len(list) > 0 {
+					return list[0], nil
+				}
+				return nil, fmt.Errorf("no stored order %s", args["poNumber"])
+			},
+		},
+		"documents": {
+			Description: "Documents exchanged for tracked orders, newest first.",
+			Args:        map[string]string{"poNumber": "String", "kind": "String", "status": "String"},
+			Resolve:     a.graphDocuments,
+		},
+		"lifecycle": {
+			Description: "Where tracked orders stand; open: true leaves out invoiced ones.",
+			Args:        map[string]string{"poNumber": "String", "open": "Boolean"},
+			Resolve:     a.graphLifecycle,
+		},
+		"runs": {
+			Description: "Flow runs since a duration (24h, 30d) or date, newest first.",
+			Args:        map[string]string{"flow": "String", "since": "String", "failedOnly": "Boolean", "limit": "Int"},
+			Resolve:     a.graphRuns,
+		},
+	}}
+}
+
+// graphOrders resolves orders and order.
+func (a *app) graphOrders(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	cfg := a.holder.Get()
+	since, _ := args["since"].(string)
+	until, _ := args["until"].(string)
+	from, err := parseDateFlag(since, false)
+	if err != nil {
+		return nil, fmt.Errorf("since: %w", err)
+	}
+	to, err := parseDateFlag(until, true)
+	if err != nil {
+		return nil, fmt.Errorf("until: %w", err)
+	}
+	stored, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load orders: %w", err)
+	}
+	tracked, err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle: %w", err)
+	}
+	windows, err := orderDeadlines(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
+	}
+	byPO := make(map[string]lifecycle.Order, len(tracked))
+	for _, o := range tracked {
+		byPO[o.PONumber] = o
+	}
+
+	orders := []graphOrder{}
+	for _, po := range stored {
+		o := model.FromPurchaseOrder(po)
+		if v, ok := args["poNumber"]; ok && o.PONumber != v {
+			continue
+		}
+		if v, ok := args["state"].(string); ok && !strings.EqualFold(o.State, v) {
+			continue
+		}
+		if (!from.IsZero() && o.OrderDate.Before(from)) || (!to.IsZero() && o.OrderDate.After(to)) {
+			continue
+		}
+		g := graphOrder{Order: o}
+		if t, ok := byPO[o.PONumber]; ok {
+			status := newOrderStatus(cfg, t, windows)
+			status.Documents = t.Documents
+			g.Status = &status
+		}
+		orders = append(orders, g)
+	}
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].OrderDate.After(orders[j].OrderDate) })
+	if n, ok := args["limit"].(int); ok && n >= 0 && n < len(orders) {
+		orders = orders[:n]
+	}
+	return orders, nil
+}
+
+// graphDocuments resolves documents.
+func (a *app) graphDocuments(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	tracked, err := a.trackedOrders(args)
+	if err != nil {
+		return nil, err
+	}
+	docs := []graphDocument{}
+	for _, o := range tracked {
+		for _, d := range o.Documents {
+			if v, ok := args["kind"].(string); ok && d.Kind != v && d.SetID != v {
+				continue
+			}
+			if v, ok := args["status"].(string); ok && !strings.EqualFold(d.Status, v) {
+				continue
+			}
+			docs = append(docs, graphDocument{PONumber: o.PONumber, Document: d})
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].At.After(docs[j].At) })
+	return docs, nil
+}
+
+// graphLifecycle resolves lifecycle.
+func (a *app) graphLifecycle(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	cfg := a.holder.Get()
+	tracked, err := a.trackedOrders(args)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := orderDeadlines(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
+	}
+	rows := []orderStatus{}
+	for _, o := range tracked {
+		if open, ok := args["open"].(bool); ok && o.Open() != open {
+			continue
+		}
+		row := newOrderStatus(cfg, o, windows)
+		row.Documents = o.Documents
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// trackedOrders reads the lifecycle of args["poNumber"], or of every order.
+func (a *app) trackedOrders(args map[string]interface{}) ([]lifecycle.Order, error) {
+	store := lifecycle.Open(a.holder.Get().Storage.LifecyclePath, permissions())
+	po, ok := args["poNumber"].(string)
+	if !ok {
+		all, err := store.All()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lifecycle: %w", err)
+		}
+		return all, nil
+	}
+	o, found, err := store.Get(po)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return []lifecycle.Order{o}, nil
+}
+
+// graphRuns resolves runs.
+func (a *app) graphRuns(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	var f history.Filter
+	f.Flow, _ = args["flow"].(string)
+	f.FailedOnly, _ = args["failedOnly"].(bool)
+	f.Limit, _ = args["limit"].(int)
+	if s, ok := args["since"].(string); ok {
+		since, err := parseSince(s, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("since: %w", err)
+		}
+		f.Since = since
+	}
+	runs, err := a.history.List(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if runs == nil {
+		runs = []history.Entry{}
+	}
+	return runs, nil
+}
@@ -0,0 +1,180 @@
+// cmd/avcimporter/history.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/history"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+recordHistory appends the outcome of a finished run to a.history. Recording
+never fails the run: a store error is only reported.
+*/
+func (a *app) recordHistory(r *output.FlowResult) {
+	cfg := a.holder.Get()
+	var bytes int64
+	for _, artifact := range r.Artifacts {
+		bytes += artifact.Size
+	}
+	host, _ := os.Hostname()
+	err := a.history.Record(history.Entry{
+		Flow:       r.Flow,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		Success:    r.Success,
+		Files:      len(r.Files),
+		POs:        len(r.PONumbers),
+		Bytes:      bytes,
+		Error:      r.Error,
+		Host:       host,
+		Profile:    cfg.Profile,
+	})
+	if err != nil {
+		logger.Log(utils.LevelWarn, "Failed to record run history: ", err.Error())
+	}
+}
+
+/*
+cmdHistory implements `avcimporter history`: it lists past flow runs, newest
+first, after a line per flow telling when it last succeeded.
+
+Flags:
+  - --flow:        Only runs of this flow.
+  - --since:       Only runs started within this duration (e.g. "24h" or "7d") or on or after this date (YYYY-MM-DD or RFC 3339).
+  - --limit:       Show at most this many runs (0 for all).
+  - --failed-only: Only failed runs.
+*/
+func cmdHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	flow := fs.String("flow", "", "Only runs of this flow (edi, api, reports, datakiosk, sla, summary, edi-watch)")
+	since := fs.String("since", "", "Only runs started within this duration (e.g. 24h or 7d) or on or after this date (YYYY-MM-DD)")
+	limit := fs.Int("limit", 20, "Show at most this many runs (0 for all)")
+	failedOnly := fs.Bool("failed-only", false, "Only failed runs")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	filter := history.Filter{Flow: *flow, FailedOnly: *failedOnly, Limit: *limit}
+	var err error
+	if filter.Since, err = parseSince(*since, time.Now()); err != nil {
+		logger.Log(utils.LevelError, "Invalid --since: ", err.Error())
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	store := history.Open(cfg.Storage.HistoryPath, 0, permissions())
+	runs, err := store.List(filter)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read run history: ", err.Error())
+		return 1
+	}
+	last, err := store.LastSuccess()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read run history: ", err.Error())
+		return 1
+	}
+	if *flow != "" {
+		last = map[string]history.Entry{*flow: last[*flow]}
+	}
+
+	if output.IsJSON() {
+		if runs == nil {
+			runs = []history.Entry{}
+		}
+		lastSuccess := map[string]*time.Time{}
+		for name, e := range last {
+			if e.Flow != "" {
+				finished := e.FinishedAt
+				lastSuccess[name] = &finished
+			} else {
+				lastSuccess[name] = nil
+			}
+		}
+		output.Emit(map[string]interface{}{"command": "history", "success": true, "lastSuccess": lastSuccess, "runs": runs})
+		return 0
+	}
+	printLastSuccess(last)
+	printHistory(runs)
+	return 0
+}
+
+/*
+parseSince parses a --since flag: a duration before now, in Go syntax (e.g.
+"24h") or whole days (e.g. "30d"), or a date (YYYY-MM-DD or RFC 3339). An
+empty flag is the zero time.
+*/
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	t, err := parseDateFlag(s, false)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a duration (e.g. 24h, 30d), YYYY-MM-DD or RFC 3339, got %q", s)
+	}
+	return t, nil
+}
+
+// printLastSuccess tells when each flow last succeeded.
+func printLastSuccess(last map[string]history.Entry) {
+	names := make([]string, 0, len(last))
+	for name := range last {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := last[name]
+		if e.Flow == "" {
+			logger.Log(utils.LevelError, "Last success of "+name+": ", "never")
+			continue
+		}
+		logger.PrintColored("Last success of "+name+": ", fmt.Sprintf("%s (%s ago)", e.FinishedAt.Format(time.RFC3339), time.Since(e.FinishedAt).Round(time.Second)), "#32CD32")
+	}
+}
+
+// printHistory renders runs for humans, failed ones in red.
+func printHistory(runs []history.Entry) {
+	if len(runs) == 0 {
+		logger.PrintColored("No runs recorded.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tFLOW\tRESULT\tDURATION\tFILES\tPOS\tERROR")
+	for _, r := range runs {
+		result := "ok"
+		if !r.Success {
+			result = "failed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", r.StartedAt.Format(time.RFC3339), r.Flow, result,
+			r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond), r.Files, r.POs, r.Error)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		if !runs[i].Success {
+			color = "#FF0000"
+		}
+		logger.PrintColored(line, "", color)
+	}
+}
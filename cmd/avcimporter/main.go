@@ -2,15 +2,26 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/heinrichb/avcimporter/pkg/audit"
 	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/credentials"
+	"github.com/heinrichb/avcimporter/pkg/export"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/logsink"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
 	"github.com/heinrichb/avcimporter/pkg/utils"
 )
 
@@ -18,157 +29,683 @@ import (
 Global variables for storing command-line arguments.
 
 - configPath: The path to the configuration file.
-- verbose: Enables verbose output.
+- verbose: Enables verbose output (log level debug unless --log-level is given).
+- logLevel: Default log level: error, warn, info or debug.
+- logModules: Per-module log levels, e.g. "sftp=debug,spapi=info".
+- captureHTTP: Directory receiving sanitized captures of every SP‑API exchange.
+- daemon: Keeps running and executes each flow on its configured schedule.
+- progress: Shows transfer progress bars when stdout is a terminal.
+- noColor: Disables ANSI colors regardless of terminal detection.
+- flowPrefix: Prefixes the log lines of each flow with its name, e.g. "[EDI]".
+- outputFormat: "text" (default) or "json" for machine-readable results on stdout.
+- lenient: Ignores unknown config keys instead of rejecting the config.
+- profile: Name of the config profile to apply (e.g. sandbox or production).
+- production: Confirms that outbound interchanges may be sent with usage indicator P.
+- printEffectiveConfig: Prints the merged configuration (secrets redacted) and exits.
+- commitPartial: Commits a staged run (storage.stagingPath) even if a flow failed.
+- apiQuery: Query parameters of --query, overriding api.query.
+- since, until: --since/--until, the data window of a single run (see dataWindow).
 */
 var (
-	configPath string
-	verbose    bool
+	configPath   string
+	verbose      bool
+	logLevel     string
+	logModules   string
+	captureHTTP  string
+	daemon       bool
+	progress     bool
+	noColor      bool
+	flowPrefix   bool
+	outputFormat string
+	lenient      bool
+	profile      string
+	production   bool
+
+	printEffectiveConfig bool
+	commitPartial        bool
+	apiQuery             = queryFlag{}
+	since, until         string
+
+	windowSince, windowUntil time.Time
 )
 
+// logger prints every human-readable message of the importer; --log-level,
+// --no-color, --flow-prefix and logging.sinks configure it.
+var logger = utils.NewLogger(os.Stdout)
+
 func init() {
 	flag.StringVar(&configPath, "config", "", "Path to config file")
 	flag.StringVar(&configPath, "c", "", "Path to config file (shorthand)")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose output")
 	flag.BoolVar(&verbose, "v", false, "Enable verbose output (shorthand)")
+	flag.StringVar(&logLevel, "log-level", "", "Log level: error, warn, info (default) or debug (default with -v)")
+	flag.StringVar(&logModules, "log", "", "Per-module log levels, e.g. sftp=debug,spapi=info (modules: sftp, spapi, config)")
+	flag.StringVar(&captureHTTP, "capture-http", "", "Write sanitized SP‑API request/response pairs to this directory, one subdirectory per run")
+	flag.BoolVar(&daemon, "daemon", false, "Run continuously, executing flows on their schedules")
+	flag.BoolVar(&daemon, "d", false, "Run continuously (shorthand)")
+	flag.BoolVar(&progress, "progress", true, "Show transfer progress bars (ignored when stdout is not a terminal)")
+	flag.BoolVar(&noColor, "no-color", false, "Disable colored output (also honours NO_COLOR)")
+	flag.BoolVar(&flowPrefix, "flow-prefix", true, "Prefix log lines of each flow with its name, e.g. [EDI] or [SP-API NA]")
+	flag.StringVar(&outputFormat, "output", "text", "Result format: text or json (json sends logs to stderr)")
+	flag.StringVar(&outputFormat, "o", "text", "Result format (shorthand)")
+	flag.BoolVar(&lenient, "lenient", false, "Ignore unknown config keys instead of failing")
+	flag.StringVar(&profile, "profile", "", "Config profile to apply (e.g. sandbox or production)")
+	flag.BoolVar(&production, "production", false, "Allow sending production interchanges (edi.usage \"P\")")
+	flag.BoolVar(&printEffectiveConfig, "print-effective-config", false, "Print the configuration merged from file, environment and profile (secrets redacted) and exit")
+	flag.BoolVar(&commitPartial, "commit-partial", false, "Commit a staged run (storage.stagingPath) even if a flow failed")
+	flag.StringVar(&since, "since", "", "Fetch from this time instead of the checkpoints, leaving them untouched (duration ago, YYYY-MM-DD or RFC 3339)")
+	flag.StringVar(&until, "until", "", "Fetch up to this time instead of now, leaving the checkpoints untouched (duration ago, YYYY-MM-DD or RFC 3339)")
+	flag.Var(apiQuery, "query", "SP‑API query parameter name=value added to purchase order fetches, overriding api.query (repeatable)")
+
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage: avcimporter [flags] [command] [command flags]")
+		fmt.Fprintln(out, "\nCommands:")
+		fmt.Fprintln(out, "  run              Execute the configured flows (default)")
+		fmt.Fprintln(out, "  watch            Import EDI files delivered to a local inbound directory")
+		fmt.Fprintln(out, "  backfill         Import the purchase order history for a date range")
+		fmt.Fprintln(out, "  list-orders      List stored purchase orders")
+		fmt.Fprintln(out, "  show-order       Show one stored purchase order")
+		fmt.Fprintln(out, "  acknowledge      Submit acknowledgements for pending orders in batches")
+		fmt.Fprintln(out, "  sync-status      Submit shipment confirmations from a CSV/JSON file")
+		fmt.Fprintln(out, "  route            Split purchase orders into one shipment per warehouse")
+		fmt.Fprintln(out, "  status           Show where every open purchase order stands")
+		fmt.Fprintln(out, "  history          Show past flow runs and when each flow last succeeded")
+		fmt.Fprintln(out, "  top              Live dashboard of flows, transfers, recent runs and checkpoints")
+		fmt.Fprintln(out, "  summary          Write the daily order summary workbook (xlsx)")
+		fmt.Fprintln(out, "  order-metrics    Export fill rates and open PO aging for dashboards (JSON/CSV)")
+		fmt.Fprintln(out, "  stats            Summarize import volume, ack latency, ASN timeliness and error rates")
+		fmt.Fprintln(out, "  windows          List upcoming ship and delivery windows or export them as iCal")
+		fmt.Fprintln(out, "  simulate         Write synthetic inbound 850 purchase orders for testing")
+		fmt.Fprintln(out, "  migrate-storage  Upgrade stored orders and manifests to the current schema version")
+		fmt.Fprintln(out, "  edi inspect      Pretty-print an X12 file and check its envelopes")
+		fmt.Fprintln(out, "  edi to-json      Convert an X12 file to canonical JSON")
+		fmt.Fprintln(out, "  edi from-json    Convert canonical JSON back to X12")
+		fmt.Fprintln(out, "  edi build        Render a custom EDI template for a stored order or shipment")
+		fmt.Fprintln(out, "  doctor           Check credentials, permissions and connectivity")
+		fmt.Fprintln(out, "  rotate-key       Rotate the SFTP key (new, then verify once uploaded)")
+		fmt.Fprintln(out, "  reauthorize      Authorize the app again and store a new LWA refresh token")
+		fmt.Fprintln(out, "  version          Show build metadata and the SP‑API User-Agent")
+		fmt.Fprintln(out, "  config-schema    Print the JSON Schema of the config file")
+		fmt.Fprintln(out, "  validate-config  Check a config file for unknown keys and invalid values")
+		fmt.Fprintln(out, "  config-env       List the AVC_* environment variables that set each config key")
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
 }
 
 /*
 main is the entry point of AVC Importer CLI.
 
 It parses command-line flags, prints a welcome message, loads the configuration,
-and then either runs the EDI/SFTP flow or the SP‑API flow based on the config.
+and then schedules the EDI/SFTP, SP‑API, Reports and Data Kiosk flows based on the config.
+Without -daemon each active flow runs once; with -daemon each flow repeats on
+its own interval until the process receives SIGINT or SIGTERM.
 */
 func main() {
 	flag.Parse()
-	config.Verbose = verbose
+	level := utils.LevelInfo
+	if verbose {
+		level = utils.LevelDebug
+	}
+	if logLevel != "" {
+		l, err := utils.ParseLevel(logLevel)
+		if err != nil {
+			logger.Log(utils.LevelError, "Invalid flag: ", err.Error())
+			os.Exit(2)
+		}
+		level = l
+	}
+	logger.SetLogLevel(level)
+	if err := logger.SetLogLevels(logModules); err != nil {
+		logger.Log(utils.LevelError, "Invalid flag: ", err.Error())
+		os.Exit(2)
+	}
+	logger.SetFlowPrefixes(flowPrefix)
 
-	utils.PrintColored("Starting AVC Importer!", "", "#00FFFF")
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid flag: ", err.Error())
+		os.Exit(2)
+	}
+	output.SetFormat(format)
+	if output.IsJSON() {
+		logger.SetOutput(os.Stderr)
+	}
+	if noColor {
+		logger.SetColorEnabled(false)
+	}
+	if err := parseWindow(time.Now()); err != nil {
+		logger.Log(utils.LevelError, "Invalid flag: ", err.Error())
+		os.Exit(2)
+	}
+	if captureHTTP != "" {
+		command := "run"
+		if flag.NArg() > 0 {
+			command = flag.Arg(0)
+		}
+		apiSigner.Base = &spapi.Capture{Dir: captureHTTP, DefaultRun: command + "-" + time.Now().UTC().Format("20060102T150405"), Base: &spapi.Gzip{Logger: logger}}
+		logger.Log(utils.LevelWarn, "Capturing SP‑API traffic in: ", captureHTTP)
+	}
+	if printEffectiveConfig {
+		os.Exit(cmdPrintEffectiveConfig())
+	}
+	os.Exit(dispatch(flag.Args()))
+}
+
+/*
+dispatch runs the subcommand named by args[0]. Without a subcommand the
+importer runs its flows, as it always has.
 
+Subcommands:
+  - run:             Execute the configured flows (default).
+  - watch:           Import EDI files delivered to edi.localInboundDir as they arrive.
+  - backfill:        Import historical purchase orders in date windows.
+  - list-orders:     List stored purchase orders.
+  - show-order:      Pretty-print one stored purchase order.
+  - acknowledge:     Submit acknowledgements for pending purchase orders in batches.
+  - sync-status:     Submit shipment confirmations from a status file.
+  - route:           Split purchase orders into shipments per warehouse by the routing rules.
+  - status:          Show the document lifecycle of open purchase orders.
+  - history:         Show past flow runs and when each flow last succeeded.
+  - top:             Live terminal dashboard of the daemon's flows, transfers, runs and checkpoints.
+  - summary:         Write the order summary workbook of a day.
+  - order-metrics:   Export fill rates per day and ASIN and open PO aging.
+  - stats:           Summarize import volume, acknowledgment latency, ASN timeliness and error rates.
+  - windows:         List the upcoming order windows or write them as an iCalendar file.
+  - simulate:        Write synthetic inbound 850s to test the pipeline before go-live.
+  - migrate-storage: Upgrade stored orders and manifests to the current schema version.
+  - edi:             Tools for raw X12 files (inspect, to-json, from-json, build).
+  - doctor:          Check credentials, roles, SFTP access and storage permissions.
+  - rotate-key:      Generate a new SFTP key and retire the old one once it works.
+  - reauthorize:     Obtain and store a new LWA refresh token via the authorization-code flow.
+  - version:         Show build metadata.
+  - config-schema:   Print the config JSON Schema.
+  - validate-config: Check a config file against the schema.
+  - config-env:      List the environment variables of the config keys.
+*/
+func dispatch(args []string) int {
+	if len(args) == 0 {
+		return run()
+	}
+	switch args[0] {
+	case "run":
+		return run()
+	case "watch":
+		return cmdWatch(args[1:])
+	case "backfill":
+		return cmdBackfill(args[1:])
+	case "list-orders":
+		return cmdListOrders(args[1:])
+	case "show-order":
+		return cmdShowOrder(args[1:])
+	case "acknowledge":
+		return cmdAcknowledge(args[1:])
+	case "sync-status":
+		return cmdSyncStatus(args[1:])
+	case "route":
+		return cmdRoute(args[1:])
+	case "status":
+		return cmdStatus(args[1:])
+	case "history":
+		return cmdHistory(args[1:])
+	case "top":
+		return cmdTop(args[1:])
+	case "summary":
+		return cmdSummary(args[1:])
+	case "order-metrics":
+		return cmdOrderMetrics(args[1:])
+	case "stats":
+		return cmdStats(args[1:])
+	case "windows":
+		return cmdWindows(args[1:])
+	case "simulate":
+		return cmdSimulate(args[1:])
+	case "migrate-storage":
+		return cmdMigrateStorage(args[1:])
+	case "edi":
+		return cmdEDI(args[1:])
+	case "doctor":
+		return cmdDoctor(args[1:])
+	case "rotate-key":
+		return cmdRotateKey(args[1:])
+	case "reauthorize":
+		return cmdReauthorize(args[1:])
+	case "version":
+		return cmdVersion(args[1:])
+	case "config-schema":
+		return cmdConfigSchema(args[1:])
+	case "validate-config":
+		return cmdValidateConfig(args[1:])
+	case "config-env":
+		return cmdConfigEnv(args[1:])
+	default:
+		logger.Log(utils.LevelError, "Unknown command: ", args[0])
+		flag.Usage()
+		return 2
+	}
+}
+
+/*
+loadConfig loads the configuration named by -config, falling back to
+configs/default.json. Without -config and without that file the configuration
+comes from the AVC_* environment variables alone, which suits containers;
+configPath then stays empty. SFTP key paths stored by rotate-key and the
+SP‑API signing and throttle settings are applied.
+*/
+func loadConfig() (*config.Config, error) {
 	if configPath == "" {
-		configPath = "configs/default.json"
+		if _, err := os.Stat("configs/default.json"); err == nil {
+			configPath = "configs/default.json"
+		}
+	}
+	cfg, err := config.Load(configPath, configOptions())
+	if err != nil {
+		return nil, err
+	}
+	if logger.Enabled(utils.ModuleConfig, utils.LevelDebug) {
+		logger.PrintNonEmptyFields("", cfg)
+	}
+	if err := applyEncryption(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyPermissions(cfg); err != nil {
+		return nil, err
+	}
+	if err := applySFTPKeys(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := sftpChallenge(cfg); err != nil {
+		return nil, err
+	}
+	if err := applySigV4(cfg); err != nil {
+		return nil, err
+	}
+	applyThrottle(cfg)
+	applyQuery(cfg)
+	return cfg, nil
+}
+
+/*
+queryFlag collects the name=value pairs of a repeatable flag.
+*/
+type queryFlag map[string]string
+
+func (q queryFlag) String() string {
+	pairs := make([]string, 0, len(q))
+	for k, v := range q {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (q queryFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	q[strings.TrimSpace(name)] = value
+	return nil
+}
+
+// applyQuery adds the --query parameters to api.query, replacing configured
+// values of the same name.
+func applyQuery(cfg *config.Config) {
+	if len(apiQuery) == 0 {
+		return
+	}
+	merged := make(map[string]string, len(cfg.API.Query)+len(apiQuery))
+	for k, v := range cfg.API.Query {
+		merged[k] = v
+	}
+	for k, v := range apiQuery {
+		merged[k] = v
+	}
+	cfg.API.Query = merged
+}
+
+/*
+configOptions returns the config.LoadOptions selected by --lenient and
+--profile, reading AVC_* variables from the process environment and printing
+config notices with logConfig.
+*/
+func configOptions() config.LoadOptions {
+	return config.LoadOptions{Lenient: lenient, Profile: profile, Environ: os.Environ(), Logger: logConfig}
+}
+
+// logConfig prints a notice of config.Load or config.Watch at its level.
+func logConfig(level utils.Level, prefix, detail string) {
+	logger.Log(level, prefix, detail)
+}
+
+// breakers holds the circuit breakers of the retry policies, shared by every
+// flow and kept across reloads.
+var breakers utils.Breakers
+
+/*
+retryPolicy returns the retry policy of rc for name, with the shared circuit
+breaker of name and retries logged by logger.
+*/
+func retryPolicy(rc config.RetryConfig, name string) utils.RetryPolicy {
+	p := rc.Policy(name, &breakers)
+	p.Logger = logger
+	return p
+}
+
+// recordAudit records e in the default audit trail. The remote action it
+// describes has already happened, so a failed write is reported but not
+// returned.
+func recordAudit(e audit.Event) {
+	if err := audit.Record(e); err != nil {
+		logger.Log(utils.LevelError, "Audit log write failed: ", err.Error())
 	}
+}
 
-	cfg, err := config.Load(configPath)
+/*
+openAudit opens the audit log configured in cfg.Audit and installs it as the
+default, returning a function that closes it. An empty path disables auditing.
+*/
+func openAudit(cfg *config.Config) (func(), error) {
+	if cfg.Audit.Path == "" {
+		return func() {}, nil
+	}
+	l, err := audit.Open(cfg.Audit.Path, int64(cfg.Audit.MaxSizeMB)<<20, cfg.Audit.MaxFiles)
 	if err != nil {
-		utils.PrintColored("Failed to load config: ", err.Error(), "#FF0000")
-		os.Exit(1)
-	}
-
-	// If neither EDI nor API is active, abort
-	if !cfg.EDI.Active && !cfg.API.Active {
-		utils.PrintColored("No valid API or EDI configuration found.", "", "#FF0000")
-		os.Exit(1)
-	}
-
-	// EDI / SFTP flow: download & delete only
-	if cfg.EDI.Active {
-		files, err := utils.FetchFilesOverSFTP(
-			cfg.EDI.Host,
-			cfg.EDI.Port,
-			cfg.EDI.Username,
-			cfg.EDI.PrivateKeyPath,
-			cfg.EDI.InboundDir,
-			cfg.Storage.SavePath,
-		)
-		if err != nil {
-			utils.PrintColored("SFTP download failed: ", err.Error(), "#FF0000")
-			os.Exit(1)
+		return nil, err
+	}
+	audit.SetDefault(l)
+	return func() {
+		audit.SetDefault(nil)
+		l.Close()
+	}, nil
+}
+
+/*
+openLogSinks starts the log sinks configured in cfg.Logging and installs them,
+returning a function that uninstalls them and sends what they still buffer.
+Nothing is installed if a sink cannot be created.
+*/
+func openLogSinks(cfg *config.Config) (func(), error) {
+	type closingSink interface {
+		utils.LogSink
+		Close(ctx context.Context) error
+	}
+	var sinks []closingSink
+	closeAll := func() {
+		logger.RemoveLogSinks()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, s := range sinks {
+			if err := s.Close(ctx); err != nil {
+				logger.Log(utils.LevelError, "Failed to flush log sink: ", err.Error())
+			}
+		}
+	}
+	levels := make([]utils.Level, 0, len(cfg.Logging.Sinks))
+	for i, s := range cfg.Logging.Sinks {
+		level, _ := utils.ParseLevel(s.Level)
+		var sink closingSink
+		var err error
+		switch s.Type {
+		case "cloudwatch":
+			cw := s.CloudWatch
+			var provider sigv4.Provider = sigv4.Default(cw.Profile)
+			if cw.RoleARN != "" {
+				provider = &sigv4.AssumeRole{Source: provider, RoleARN: cw.RoleARN, Region: cw.Region}
+			}
+			stream := cw.Stream
+			if stream == "" {
+				stream = logsink.DefaultStream()
+			}
+			sink, err = logsink.NewCloudWatch(logsink.CloudWatchOptions{
+				Group:         cw.Group,
+				Stream:        stream,
+				Region:        cw.Region,
+				Credentials:   &sigv4.Cache{Provider: provider, Logger: logger},
+				FlushInterval: time.Duration(cw.FlushInterval),
+				Logger:        logger,
+			})
+		case "syslog":
+			facility, ferr := logsink.ParseFacility(s.Syslog.Facility)
+			if ferr != nil {
+				err = ferr
+				break
+			}
+			sink, err = logsink.NewSyslog(logsink.SyslogOptions{
+				Network:  s.Syslog.Network,
+				Addr:     s.Syslog.Addr,
+				Facility: facility,
+				AppName:  s.Syslog.AppName,
+				Logger:   logger,
+			})
+		default:
+			err = fmt.Errorf("unknown type %q", s.Type)
 		}
-		for _, f := range files {
-			utils.PrintColored("Downloaded and removed remote file: ", f, "#00FFFF")
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("logging.sinks[%d]: %w", i, err)
 		}
+		sinks = append(sinks, sink)
+		levels = append(levels, level)
+	}
+	for i, s := range sinks {
+		logger.AddLogSink(s, levels[i])
 	}
+	return closeAll, nil
+}
 
-	// SP‑API flow
-	if cfg.API.Active {
-		token, err := fetchOAuthToken(cfg)
+/*
+installHooks builds the configured processing hooks and exporters and installs
+them, the exporters delivering through the outbox (see openOutbox). Exporters
+with an orderBy hold their documents until the batch is complete (see
+flushOrdered). Nothing is installed if an exporter's templates are invalid.
+*/
+func installHooks(cfg *config.Config) error {
+	hs := make([]hooks.Hook, 0, len(cfg.Hooks)+len(cfg.Exports))
+	for _, h := range cfg.Hooks {
+		hs = append(hs, &hooks.Command{
+			HookName: h.Name,
+			Args:     h.Command,
+			Events:   h.Events,
+			Timeout:  time.Duration(h.Timeout),
+			Required: h.Required,
+		})
+	}
+	exporters := make([]hooks.Hook, 0, len(cfg.Exports))
+	for _, e := range cfg.Exports {
+		h, err := newExporter(cfg, e)
 		if err != nil {
-			utils.PrintColored("Error fetching OAuth2 token: ", err.Error(), "#FF0000")
-			os.Exit(1)
+			return err
 		}
-		if err := fetchFromAPI(cfg, token); err != nil {
-			utils.PrintColored("Error fetching data from API: ", err.Error(), "#FF0000")
-			os.Exit(1)
+		exporters = append(exporters, h)
+	}
+	wrapped := openOutbox(cfg).Wrap(exporters...)
+	for i, e := range cfg.Exports {
+		if e.OrderBy != "" {
+			wrapped[i] = &hooks.Ordered{Hook: wrapped[i], By: e.OrderBy}
 		}
 	}
+	hooks.Set(append(hs, wrapped...)...)
+	return nil
+}
 
-	utils.PrintColored("AVC Importer CLI completed successfully.", "", "#32CD32")
+// newExporter builds the hook of one exports entry.
+func newExporter(cfg *config.Config, e config.ExportConfig) (hooks.Hook, error) {
+	retry := retryPolicy(e.Retry, "export "+e.Name)
+	switch e.Type {
+	case "quickbooks":
+		items, err := export.LoadSKUMap(e.SKUMap)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", e.Name, err)
+		}
+		qb := e.QuickBooks
+		baseURL := export.QuickBooksProductionURL
+		if qb.Sandbox {
+			baseURL = export.QuickBooksSandboxURL
+		}
+		return export.NewQuickBooks(export.QuickBooksOptions{
+			Name:            e.Name,
+			RealmID:         qb.RealmID,
+			BaseURL:         baseURL,
+			ClientID:        qb.ClientID,
+			ClientSecret:    qb.ClientSecret,
+			RefreshToken:    qb.RefreshToken,
+			Tokens:          credentials.Open(cfg.Storage.CredentialsPath),
+			Document:        qb.Document,
+			Customers:       qb.Customers,
+			DefaultCustomer: qb.DefaultCustomer,
+			Items:           items,
+			Retry:           retry,
+			Timeout:         time.Duration(e.Timeout),
+			Required:        e.Required,
+			Logger:          logger,
+		})
+	case "netsuite":
+		items, err := export.LoadSKUMap(e.SKUMap)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", e.Name, err)
+		}
+		ns := e.NetSuite
+		return export.NewNetSuite(export.NetSuiteOptions{
+			Name:            e.Name,
+			AccountID:       ns.AccountID,
+			BaseURL:         ns.BaseURL,
+			ConsumerKey:     ns.ConsumerKey,
+			ConsumerSecret:  ns.ConsumerSecret,
+			TokenID:         ns.TokenID,
+			TokenSecret:     ns.TokenSecret,
+			Customers:       ns.Customers,
+			DefaultCustomer: ns.DefaultCustomer,
+			Items:           items,
+			Location:        ns.Location,
+			Currencies:      ns.Currencies,
+			Retry:           retry,
+			Timeout:         time.Duration(e.Timeout),
+			Required:        e.Required,
+			Logger:          logger,
+		})
+	default:
+		return export.NewREST(export.RESTOptions{
+			Name:         e.Name,
+			Documents:    e.Documents,
+			URL:          e.URL,
+			Method:       e.Method,
+			Headers:      e.Headers,
+			Body:         e.Body,
+			ContentType:  e.ContentType,
+			Auth:         export.RESTAuth(e.Auth),
+			Retry:        retry,
+			Timeout:      time.Duration(e.Timeout),
+			SuccessCodes: e.SuccessCodes,
+			IgnoreCodes:  e.IgnoreCodes,
+			Required:     e.Required,
+			Logger:       logger,
+		})
+	}
 }
 
 /*
-fetchOAuthToken requests an OAuth2 token from Amazon SP‑API.
+run executes the importer and returns the process exit code. Keeping the work
+out of main lets deferred cleanup (such as flushing traces) run before exit.
+With --output json the overall result is written to stdout when run returns.
 */
-func fetchOAuthToken(cfg *config.Config) (string, error) {
-	requestBody := map[string]string{
-		"grant_type":    "refresh_token",
-		"refresh_token": cfg.API.Auth.RefreshToken,
-		"client_id":     cfg.API.Auth.ClientID,
-		"client_secret": cfg.API.Auth.ClientSecret,
+func run() (code int) {
+	results := output.NewCollector(daemon)
+	summary := output.RunResult{Command: "run"}
+	defer func() {
+		summary.Success = code == 0
+		summary.Flows = results.Flows()
+		output.Emit(summary)
+	}()
+	fail := func(prefix string, err error) int {
+		detail := ""
+		if err != nil {
+			detail = err.Error()
+		}
+		logger.Log(utils.LevelError, prefix, detail)
+		summary.Errors = append(summary.Errors, prefix+detail)
+		return 1
 	}
 
-	jsonData, _ := json.Marshal(requestBody)
-	req, _ := http.NewRequest("POST", cfg.API.TokenURL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+	logger.PrintColored("Starting AVC Importer!", "", "#00FFFF")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	cfg, err := loadConfig()
 	if err != nil {
-		return "", err
+		return fail("Failed to load config: ", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to fetch token: %s", string(body))
+	// If no flow is active (and, in daemon mode, no local inbound directory or webhook is served), abort
+	watching := daemon && (cfg.EDI.LocalInboundDir != "" || cfg.Server.Webhook.Secret != "")
+	if !cfg.EDI.Active && !cfg.API.Active && !cfg.Reports.Active && !cfg.DataKiosk.Active && !cfg.SLA.Active && !cfg.Summary.Active && !watching {
+		return fail("No valid API or EDI configuration found.", nil)
 	}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	if err := installHooks(cfg); err != nil {
+		return fail("Invalid export: ", err)
 	}
-
-	if verbose {
-		utils.PrintColored("OAuth2 Token Response: ", fmt.Sprintf("%v", result), "#00FFFF")
+	closeAudit, err := openAudit(cfg)
+	if err != nil {
+		return fail("Failed to open audit log: ", err)
+	}
+	defer closeAudit()
+	closeSinks, err := openLogSinks(cfg)
+	if err != nil {
+		return fail("Failed to start log sinks: ", err)
 	}
+	defer closeSinks()
 
-	return result["access_token"].(string), nil
-}
+	a, err := newApp(cfg, results)
+	if err != nil {
+		return fail("Failed to start: ", err)
+	}
 
-/*
-fetchFromAPI requests data from the configured SP‑API endpoint.
-It uses the `endpointUrl` field in the config to determine which endpoint to call.
+	if cfg.Tracing.Endpoint != "" {
+		shutdown := tracing.Setup(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.Headers, func(err error) {
+			logger.Log(utils.LevelError, "Trace export failed: ", err.Error())
+		})
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				logger.Log(utils.LevelError, "Trace export failed: ", err.Error())
+			}
+		}()
+	}
 
-Parameters:
-  - cfg:   The application configuration, containing API details.
-  - token: The OAuth2 bearer token for authentication.
-*/
-func fetchFromAPI(cfg *config.Config, token string) error {
-	fullURL := cfg.API.BaseURL + cfg.API.EndpointURL
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	utils.PrintColored("Fetching data from: ", fullURL, "#32CD32")
+	summary.Cleaned = a.cleanup()
+	defer func() { summary.Cleaned = append(summary.Cleaned, a.cleanup()...) }()
+	sweepOutbox(ctx, cfg)
 
-	req, _ := http.NewRequest("GET", fullURL, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+	if daemon {
+		if err := a.runDaemon(ctx); err != nil {
+			return fail("Scheduler failed: ", err)
+		}
+		logger.PrintColored("AVC Importer daemon stopped.", "", "#32CD32")
+		return 0
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	runCtx, stage, err := a.beginStaging(ctx, cfg)
 	if err != nil {
-		return err
+		return fail("Failed to start staged run: ", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to fetch data from API: %s", string(body))
+	err = a.sched.RunOnce(runCtx)
+	if stage != nil {
+		if cErr := a.finishStaging(ctx, cfg, stage, err); cErr != nil {
+			fail("Commit failed: ", cErr)
+			if err == nil {
+				err = cErr
+			}
+		}
 	}
-
-	body, _ := io.ReadAll(resp.Body)
-	if verbose {
-		utils.PrintColored("API Response: ", string(body), "#00FFFF")
-	} else {
-		utils.PrintColored("Data fetched successfully. Use -v for details.", "", "#00FFFF")
+	a.pushMetrics(ctx, a.holder.Get())
+	if err != nil {
+		return 1
 	}
 
-	return nil
+	logger.PrintColored("AVC Importer CLI completed successfully.", "", "#32CD32")
+	return 0
 }
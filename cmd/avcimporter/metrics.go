@@ -0,0 +1,65 @@
+// cmd/avcimporter/metrics.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/metrics"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// recordMetrics adds the outcome of a finished run to a.metrics.
+func (a *app) recordMetrics(r *output.FlowResult) {
+	a.metrics.Add(metrics.Runs, r.Flow, 1)
+	if !r.Success {
+		a.metrics.Add(metrics.RunFailures, r.Flow, 1)
+	} else {
+		a.metrics.Set(metrics.LastSuccessAt, r.Flow, float64(r.FinishedAt.Unix()))
+	}
+	a.metrics.Add(metrics.Files, r.Flow, float64(len(r.Files)))
+	a.metrics.Add(metrics.POs, r.Flow, float64(len(r.PONumbers)))
+	var bytes int64
+	for _, artifact := range r.Artifacts {
+		bytes += artifact.Size
+	}
+	a.metrics.Add(metrics.Bytes, r.Flow, float64(bytes))
+	a.metrics.Set(metrics.RunDuration, r.Flow, r.FinishedAt.Sub(r.StartedAt).Seconds())
+}
+
+/*
+pushMetrics sends the metrics of this run to the destination configured in
+cfg.Metrics. Failures are logged; they do not fail the run.
+*/
+func (a *app) pushMetrics(ctx context.Context, cfg *config.Config) {
+	m := cfg.Metrics
+	samples := a.metrics.Snapshot()
+	if m.Push == "" || len(samples) == 0 {
+		return
+	}
+	// Push even when the run was interrupted.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	var err error
+	switch m.Push {
+	case "cloudwatch":
+		var provider sigv4.Provider = sigv4.Default(m.Profile)
+		if m.RoleARN != "" {
+			provider = &sigv4.AssumeRole{Source: provider, RoleARN: m.RoleARN, Region: m.Region}
+		}
+		cw := &metrics.CloudWatch{Namespace: m.Namespace, Region: m.Region, Credentials: provider}
+		err = cw.Push(ctx, samples, time.Now())
+	case "statsd", "dogstatsd":
+		err = metrics.PushStatsD(m.Addr, m.Namespace, m.Push == "dogstatsd", samples)
+	}
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to push metrics: ", err.Error())
+		return
+	}
+	logger.PrintColored("Metrics pushed: ", fmt.Sprintf("%d values to %s", len(samples), m.Push), "#32CD32")
+}
@@ -0,0 +1,73 @@
+// cmd/avcimporter/migrate.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdMigrateStorage implements `avcimporter migrate-storage`: it upgrades the
+stored orders and manifests below storage.savePath to the schema version of
+this build, in place and keeping their compression and encryption. Older
+files are readable without it, but a migrated archive no longer depends on
+the upgrade code. Files of a newer build are reported, not touched.
+
+Flags:
+  - --dir:     Storage directory to migrate (default: storage.savePath).
+  - --dry-run: List the files due for upgrade without rewriting them.
+*/
+func cmdMigrateStorage(args []string) int {
+	fs := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Storage directory to migrate (default: storage.savePath)")
+	dryRun := fs.Bool("dry-run", false, "List the files due for upgrade without rewriting them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter migrate-storage [--dir dir] [--dry-run]")
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	if *dir == "" {
+		*dir = cfg.Storage.SavePath
+	}
+
+	files, err := storage.Migrate(*dir, *dryRun, permissions())
+	if output.IsJSON() {
+		result := map[string]interface{}{"command": "migrate-storage", "success": err == nil,
+			"dir": *dir, "dryRun": *dryRun, "schemaVersion": storage.SchemaVersion, "files": files}
+		if files == nil {
+			result["files"] = []storage.MigratedFile{}
+		}
+		if err != nil {
+			result["error"] = err.Error()
+		}
+		output.Emit(result)
+	} else {
+		verb := "Migrated: "
+		if *dryRun {
+			verb = "Would migrate: "
+		}
+		for _, f := range files {
+			logger.PrintColored(verb, fmt.Sprintf("%s (%s, version %d -> %d)", f.Path, f.Type, f.From, storage.SchemaVersion), "#00FFFF")
+		}
+		if err != nil {
+			logger.Log(utils.LevelError, "Migration incomplete: ", err.Error())
+		}
+		logger.PrintColored(verb, strconv.Itoa(len(files))+" files in "+*dir+" (schema version "+strconv.Itoa(storage.SchemaVersion)+")", "#32CD32")
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,166 @@
+// cmd/avcimporter/ordermetrics.go
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+orderMetrics is the document written by order-metrics in JSON format.
+
+Fields:
+  - GeneratedAt: When the metrics were computed.
+  - Since:       First order day covered (YYYY-MM-DD).
+  - Until:       Last order day covered (YYYY-MM-DD).
+  - FillRates:   Ordered, accepted and shipped units per day and ASIN.
+  - OpenAging:   Age of every open purchase order, oldest first.
+*/
+type orderMetrics struct {
+	GeneratedAt time.Time            `json:"generatedAt"`
+	Since       string               `json:"since"`
+	Until       string               `json:"until"`
+	FillRates   []lifecycle.FillRate `json:"fillRates"`
+	OpenAging   []lifecycle.Aging    `json:"openAging"`
+}
+
+/*
+cmdOrderMetrics implements `avcimporter order-metrics`: it aggregates the
+lifecycle tracker into dashboard data for a BI tool — ordered, accepted and
+shipped units with accept and fill rates per order day and ASIN, and the age
+of every open purchase order — and writes it as JSON (order-metrics.json) or
+CSV (fill-rates.csv and open-po-aging.csv).
+
+Flags:
+  - --since:  First order day, YYYY-MM-DD in the configured time zone (default: 30 days ago).
+  - --until:  Last order day (default: today).
+  - --format: "json" (default) or "csv".
+  - --out:    Directory receiving the files (default: <savePath>/metrics).
+*/
+func cmdOrderMetrics(args []string) int {
+	fs := flag.NewFlagSet("order-metrics", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "", "First order day (YYYY-MM-DD, default: 30 days ago)")
+	untilFlag := fs.String("until", "", "Last order day (YYYY-MM-DD, default: today)")
+	format := fs.String("format", "json", "Output format: json or csv")
+	out := fs.String("out", "", "Directory receiving the files (default: <savePath>/metrics)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || (*format != "json" && *format != "csv") {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter order-metrics [--since YYYY-MM-DD] [--until YYYY-MM-DD] [--format json|csv] [--out DIR]")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid timezone: ", err.Error())
+		return 1
+	}
+	now := time.Now().In(loc)
+	until := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	since := until.AddDate(0, 0, -30)
+	for _, f := range []struct {
+		name  string
+		value string
+		day   *time.Time
+	}{{"--since", *sinceFlag, &since}, {"--until", *untilFlag, &until}} {
+		if f.value == "" {
+			continue
+		}
+		if *f.day, err = time.ParseInLocation("2006-01-02", f.value, loc); err != nil {
+			logger.Log(utils.LevelError, "Invalid "+f.name+": ", fmt.Sprintf("expected YYYY-MM-DD, got %q", f.value))
+			return 2
+		}
+	}
+	if *out == "" {
+		*out = filepath.Join(cfg.Storage.SavePath, "metrics")
+	}
+
+	tracked, err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).All()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read lifecycle: ", err.Error())
+		return 1
+	}
+	m := orderMetrics{
+		GeneratedAt: now,
+		Since:       since.Format("2006-01-02"),
+		Until:       until.Format("2006-01-02"),
+		FillRates:   lifecycle.FillRates(tracked, loc, since, until.AddDate(0, 0, 1)),
+		OpenAging:   lifecycle.OpenAging(tracked, now),
+	}
+	if m.OpenAging == nil {
+		m.OpenAging = []lifecycle.Aging{}
+	}
+
+	files, err := writeOrderMetrics(*out, *format, m)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to write order metrics: ", err.Error())
+		return 1
+	}
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "order-metrics", "success": true, "files": files, "fillRates": len(m.FillRates), "openOrders": len(m.OpenAging)})
+		return 0
+	}
+	for _, f := range files {
+		logger.PrintColored("Wrote order metrics: ", f, "#32CD32")
+	}
+	logger.PrintColored("Rows: ", fmt.Sprintf("%d day/ASIN, %d open POs (%s to %s)", len(m.FillRates), len(m.OpenAging), m.Since, m.Until), "#FFFFFF")
+	return 0
+}
+
+/*
+writeOrderMetrics writes m to dir in the given format.
+
+Returns:
+  - The paths written.
+  - An error if a file cannot be written.
+*/
+func writeOrderMetrics(dir, format string, m orderMetrics) ([]string, error) {
+	if format == "json" {
+		if err := utils.SaveToFile(dir, "order-metrics.json", m); err != nil {
+			return nil, err
+		}
+		return []string{filepath.Join(dir, "order-metrics.json")}, nil
+	}
+
+	rates := [][]string{{"date", "asin", "orders", "ordered", "accepted", "shipped", "accept_rate", "fill_rate"}}
+	for _, r := range m.FillRates {
+		rates = append(rates, []string{r.Date, r.ASIN, strconv.Itoa(r.Orders), strconv.Itoa(r.Ordered), strconv.Itoa(r.Accepted), strconv.Itoa(r.Shipped),
+			strconv.FormatFloat(r.AcceptRate, 'f', -1, 64), strconv.FormatFloat(r.FillRate, 'f', -1, 64)})
+	}
+	aging := [][]string{{"po_number", "order_date", "stage", "age_days", "bucket"}}
+	for _, a := range m.OpenAging {
+		aging = append(aging, []string{a.PONumber, a.OrderDate.Format(time.RFC3339), a.Stage, strconv.Itoa(a.AgeDays), a.Bucket})
+	}
+
+	var files []string
+	for _, f := range []struct {
+		name string
+		rows [][]string
+	}{{"fill-rates.csv", rates}, {"open-po-aging.csv", aging}} {
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		if err := w.WriteAll(f.rows); err != nil {
+			return files, err
+		}
+		if err := utils.SaveToFile(dir, f.name, b.Bytes()); err != nil {
+			return files, err
+		}
+		files = append(files, filepath.Join(dir, f.name))
+	}
+	return files, nil
+}
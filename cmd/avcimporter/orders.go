@@ -0,0 +1,251 @@
+// cmd/avcimporter/orders.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+orderSummary is the machine-readable row emitted by list-orders.
+*/
+type orderSummary struct {
+	PurchaseOrderNumber string    `json:"purchaseOrderNumber"`
+	PurchaseOrderDate   time.Time `json:"purchaseOrderDate"`
+	PurchaseOrderState  string    `json:"purchaseOrderState"`
+	PurchaseOrderType   string    `json:"purchaseOrderType,omitempty"`
+	Items               int       `json:"items"`
+	ShipWindow          string    `json:"shipWindow,omitempty"`
+}
+
+/*
+cmdListOrders implements `avcimporter list-orders`: it lists purchase orders
+in local storage, optionally filtered by PO date, state and PO number.
+
+Flags:
+  - --since / --until: PO date bounds (YYYY-MM-DD or RFC 3339), inclusive.
+  - --status:          PO state (New, Acknowledged, Closed), case-insensitive.
+  - --po:              Substring of the PO number.
+*/
+func cmdListOrders(args []string) int {
+	fs := flag.NewFlagSet("list-orders", flag.ContinueOnError)
+	since := fs.String("since", "", "Only orders dated on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "Only orders dated on or before this date (YYYY-MM-DD)")
+	status := fs.String("status", "", "Only orders in this state (New, Acknowledged, Closed)")
+	po := fs.String("po", "", "Only orders whose PO number contains this text")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	from, err := parseDateFlag(*since, false)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid --since: ", err.Error())
+		return 2
+	}
+	to, err := parseDateFlag(*until, true)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid --until: ", err.Error())
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	orders, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load orders: ", err.Error())
+		return 1
+	}
+
+	var rows []orderSummary
+	for _, o := range orders {
+		date := o.OrderDetails.PurchaseOrderDate
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		if *status != "" && !strings.EqualFold(o.PurchaseOrderState, *status) {
+			continue
+		}
+		if *po != "" && !strings.Contains(o.PurchaseOrderNumber, *po) {
+			continue
+		}
+		rows = append(rows, orderSummary{
+			PurchaseOrderNumber: o.PurchaseOrderNumber,
+			PurchaseOrderDate:   date,
+			PurchaseOrderState:  o.PurchaseOrderState,
+			PurchaseOrderType:   o.OrderDetails.PurchaseOrderType,
+			Items:               len(o.OrderDetails.Items),
+			ShipWindow:          o.OrderDetails.ShipWindow,
+		})
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "list-orders", "success": true, "orders": rows})
+		return 0
+	}
+	if len(rows) == 0 {
+		logger.PrintColored("No orders found in: ", cfg.Storage.SavePath, "#FFFF00")
+		return 0
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PO NUMBER\tDATE\tSTATE\tTYPE\tITEMS\tSHIP WINDOW")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.PurchaseOrderNumber, r.PurchaseOrderDate.Format("2006-01-02"), r.PurchaseOrderState,
+			r.PurchaseOrderType, r.Items, r.ShipWindow)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for _, line := range lines[1:] {
+		logger.PrintColored(line, "", "#FFFFFF")
+	}
+	logger.PrintColored("Orders: ", fmt.Sprint(len(rows)), "#32CD32")
+	return 0
+}
+
+/*
+cmdShowOrder implements `avcimporter show-order <PO>`: it pretty-prints one
+stored purchase order with its parties, windows and line items.
+*/
+func cmdShowOrder(args []string) int {
+	fs := flag.NewFlagSet("show-order", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter show-order <PO number>")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	order, err := storage.LoadOrder(cfg.Storage.SavePath, fs.Arg(0))
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load order: ", err.Error())
+		return 1
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "show-order", "success": true, "order": order})
+		return 0
+	}
+	printOrder(order)
+	return 0
+}
+
+// printOrder renders a purchase order for humans.
+func printOrder(o spapi.PurchaseOrder) {
+	d := o.OrderDetails
+	logger.PrintColored("Purchase order: ", o.PurchaseOrderNumber, "#00FFFF")
+	logger.PrintColored("  State:           ", o.PurchaseOrderState, stateColor(o.PurchaseOrderState))
+	logger.PrintColored("  Acknowledgment:  ", acknowledgmentStatus(o.PurchaseOrderState), "#FFFF00")
+	logger.PrintColored("  Ordered:         ", d.PurchaseOrderDate.Format(time.RFC3339), "#FFFF00")
+	if d.PurchaseOrderChangedDate != nil {
+		logger.PrintColored("  Changed:         ", d.PurchaseOrderChangedDate.Format(time.RFC3339), "#FFFF00")
+	}
+	printIfSet("  Type:            ", d.PurchaseOrderType)
+	printIfSet("  Deal code:       ", d.DealCode)
+	printIfSet("  Payment method:  ", d.PaymentMethod)
+	printIfSet("  Ship window:     ", d.ShipWindow)
+	printIfSet("  Delivery window: ", d.DeliveryWindow)
+	parties := []struct {
+		label string
+		party *spapi.Party
+	}{
+		{"  Buying party:    ", d.BuyingParty},
+		{"  Selling party:   ", d.SellingParty},
+		{"  Ship to:         ", d.ShipToParty},
+		{"  Bill to:         ", d.BillToParty},
+	}
+	for _, p := range parties {
+		if p.party != nil {
+			printIfSet(p.label, p.party.PartyID)
+		}
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  #\tASIN\tSKU\tQTY\tUOM\tNET COST\tBACKORDER")
+	for _, it := range d.Items {
+		cost := ""
+		if it.NetCost != nil {
+			cost = it.NetCost.Amount + " " + it.NetCost.CurrencyCode
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\t%s\t%s\t%t\n",
+			it.ItemSequenceNumber, it.AmazonProductIdentifier, it.VendorProductIdentifier,
+			it.OrderedQuantity.Amount, it.OrderedQuantity.UnitOfMeasure, cost, it.IsBackOrderAllowed)
+	}
+	tw.Flush()
+
+	logger.PrintColored("Line items: ", fmt.Sprint(len(d.Items)), "#00FFFF")
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00BFFF")
+	for _, line := range lines[1:] {
+		logger.PrintColored(line, "", "#FFFFFF")
+	}
+}
+
+// printIfSet prints a labelled value unless it is empty.
+func printIfSet(label, value string) {
+	if value != "" {
+		logger.PrintColored(label, value, "#FFFF00")
+	}
+}
+
+// acknowledgmentStatus derives whether the PO has been acknowledged from its state.
+func acknowledgmentStatus(state string) string {
+	switch state {
+	case "Acknowledged", "Closed":
+		return "Acknowledged"
+	default:
+		return "Pending"
+	}
+}
+
+// stateColor picks a label color for a PO state.
+func stateColor(state string) string {
+	switch state {
+	case "New":
+		return "#FFFF00"
+	case "Acknowledged":
+		return "#32CD32"
+	default:
+		return "#FFFFFF"
+	}
+}
+
+// parseDateFlag parses YYYY-MM-DD or RFC 3339. With endOfDay, a bare date
+// covers the whole day.
+func parseDateFlag(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", s)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,71 @@
+// cmd/avcimporter/outbox.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/outbox"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// exportOutbox is the outbox the installed exporters deliver through; it is
+// kept across reloads unless its settings change.
+var (
+	exportOutboxMu sync.Mutex
+	exportOutbox   *outbox.Outbox
+	outboxSettings outboxKey
+)
+
+// outboxKey holds the settings an outbox is opened with.
+type outboxKey struct {
+	path        string
+	retention   config.Duration
+	maxAttempts int
+}
+
+// openOutbox returns the outbox configured in cfg, reusing the current one if its settings are unchanged.
+func openOutbox(cfg *config.Config) *outbox.Outbox {
+	exportOutboxMu.Lock()
+	defer exportOutboxMu.Unlock()
+	settings := outboxKey{cfg.Storage.OutboxPath, cfg.Outbox.Retention, cfg.Outbox.MaxAttempts}
+	if exportOutbox == nil || settings != outboxSettings {
+		exportOutbox = outbox.Open(cfg.Storage.OutboxPath, time.Duration(cfg.Outbox.Retention), cfg.Outbox.MaxAttempts, permissions(), logger)
+		outboxSettings = settings
+	}
+	return exportOutbox
+}
+
+/*
+sweepOutbox sends again the export deliveries of cfg that are still pending,
+e.g. after an ERP outage or a crash between generating and delivering a
+document. Failures are logged; the deliveries stay pending for the next sweep.
+*/
+func sweepOutbox(ctx context.Context, cfg *config.Config) {
+	if len(cfg.Exports) == 0 {
+		return
+	}
+	ctx = utils.WithLogPrefix(ctx, "Outbox")
+	delivered, err := openOutbox(cfg).Sweep(ctx, time.Duration(cfg.Outbox.SweepInterval))
+	if err != nil {
+		logger.LogContext(ctx, utils.LevelError, "Redelivery failed: ", err.Error())
+	}
+	if delivered > 0 {
+		logger.PrintColoredContext(ctx, "Redelivered exports: ", fmt.Sprint(delivered), "#32CD32")
+	}
+}
+
+// sweepOutboxPeriodically runs sweepOutbox every outbox.sweepInterval until ctx is cancelled.
+func (a *app) sweepOutboxPeriodically(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(a.holder.Get().Outbox.SweepInterval)):
+			sweepOutbox(ctx, a.holder.Get())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// cmd/avcimporter/permissions.go
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// filePerms holds the modes and ownership of storage.permissions; it is
+// replaced on reload while flows are running.
+var filePerms atomic.Pointer[utils.Permissions]
+
+/*
+permissions returns the modes and ownership every writer passes to the stores
+and storage functions (nil, i.e. utils.DefaultPermissions, before the config
+is loaded).
+*/
+func permissions() *utils.Permissions {
+	return filePerms.Load()
+}
+
+/*
+applyPermissions resolves the file and directory modes and the ownership of
+storage.permissions and makes permissions return them.
+
+Returns:
+  - An error if a mode is invalid or the owner or group does not exist.
+*/
+func applyPermissions(cfg *config.Config) error {
+	p := cfg.Storage.Permissions
+	perms := utils.Permissions{UID: -1, GID: -1}
+	var err error
+	if perms.FileMode, err = utils.ParseFileMode(p.FileMode); err != nil {
+		return fmt.Errorf("invalid storage.permissions.fileMode: %w", err)
+	}
+	if perms.DirMode, err = utils.ParseFileMode(p.DirMode); err != nil {
+		return fmt.Errorf("invalid storage.permissions.dirMode: %w", err)
+	}
+	if p.Owner != "" {
+		if perms.UID, err = lookupID(p.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return fmt.Errorf("invalid storage.permissions.owner: %w", err)
+		}
+	}
+	if p.Group != "" {
+		if perms.GID, err = lookupID(p.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return fmt.Errorf("invalid storage.permissions.group: %w", err)
+		}
+	}
+	filePerms.Store(&perms)
+	return nil
+}
+
+// lookupID returns a numeric user or group ID as is, or resolves a name.
+func lookupID(s string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		return id, nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("%s has no numeric ID on this system", s)
+	}
+	return n, nil
+}
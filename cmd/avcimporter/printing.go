@@ -0,0 +1,79 @@
+// cmd/avcimporter/printing.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/labels"
+	"github.com/heinrichb/avcimporter/pkg/printer"
+)
+
+/*
+printedDocument is the outcome of printing one shipment document, emitted by
+sync-status.
+
+Fields:
+  - Document: "label" or "packing slip".
+  - Printer:  Printer URL.
+  - Error:    Why printing failed ("" if it did not).
+*/
+type printedDocument struct {
+	ShipmentID string `json:"shipmentId"`
+	Document   string `json:"document"`
+	Printer    string `json:"printer"`
+	Error      string `json:"error,omitempty"`
+}
+
+// printerRule returns the printers of a warehouse, if any rule matches.
+func printerRule(cfg *config.Config, warehouse string) (config.PrinterRule, bool) {
+	for _, p := range cfg.Printing.Printers {
+		if p.Warehouse == "" || p.Warehouse == warehouse {
+			return p, true
+		}
+	}
+	return config.PrinterRule{}, false
+}
+
+/*
+printShipment prints the shipment label and packing slip of a submitted
+confirmation on the printers of its ship-from warehouse. A failed print does
+not undo the submission; it is reported so the documents can be printed by
+hand.
+*/
+func printShipment(ctx context.Context, cfg *config.Config, s *pendingShipment) []printedDocument {
+	rule, ok := printerRule(cfg, s.confirmation.ShipFromParty.PartyID)
+	if !ok {
+		return nil
+	}
+	id := s.confirmation.ShipmentIdentifier
+	jobs := []struct {
+		document, addr string
+		job            printer.Job
+	}{
+		{"label", rule.Labels, printer.Job{Name: id + " label", Format: printer.FormatRaw, Data: labels.ShipmentLabel(s.confirmation, cfg.Printing.Copies)}},
+		{"packing slip", rule.PackingSlips, printer.Job{Name: id + " packing slip", Format: printer.FormatText, Data: labels.PackingSlip(s.confirmation)}},
+	}
+	var printed []printedDocument
+	for _, j := range jobs {
+		if j.addr == "" {
+			continue
+		}
+		d := printedDocument{ShipmentID: id, Document: j.document, Printer: j.addr}
+		if err := printer.Print(ctx, j.addr, j.job, time.Duration(cfg.Printing.Timeout)); err != nil {
+			d.Error = err.Error()
+		}
+		printed = append(printed, d)
+	}
+	return printed
+}
+
+// String describes a printed document for humans.
+func (d printedDocument) String() string {
+	if d.Error != "" {
+		return fmt.Sprintf("%s of shipment %s: %s", d.Document, d.ShipmentID, d.Error)
+	}
+	return fmt.Sprintf("%s of shipment %s on %s", d.Document, d.ShipmentID, d.Printer)
+}
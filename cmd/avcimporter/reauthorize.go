@@ -0,0 +1,229 @@
+// cmd/avcimporter/reauthorize.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/credentials"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// errInvalidGrant marks a refresh token LWA no longer accepts, e.g. after the
+// app was re-authorized or the authorization was revoked.
+var errInvalidGrant = errors.New("LWA refresh token rejected (invalid_grant)")
+
+// defaultConsentURL is the Vendor Central page that asks the vendor to
+// authorize the app (North America; see --consent-url).
+const defaultConsentURL = "https://vendorcentral.amazon.com/apps/authorize/consent"
+
+// lwaTokenName returns the credential name of the SP‑API refresh token.
+func lwaTokenName(cfg *config.Config) string {
+	return credentials.Key("lwa", cfg.API.Auth.ClientID, "refreshToken")
+}
+
+/*
+cmdReauthorize implements `avcimporter reauthorize`: the LWA authorization-code
+flow that yields a new SP‑API refresh token when the old one was rejected with
+invalid_grant. It prints the Vendor Central consent URL, waits on a local
+listener for the redirect carrying spapi_oauth_code, exchanges the code for a
+refresh token and stores it in the credentials store (storage.credentialsPath),
+where it wins over api.auth.refreshToken from the next token request on, so a
+running daemon needs no restart.
+
+The redirect URI must be registered with the app. When the browser cannot
+reach the listener, copy spapi_oauth_code from the redirect URL and pass it
+with --code.
+
+Flags:
+  - --listen:       Address of the redirect listener (default: 127.0.0.1:8765).
+  - --redirect-uri: Redirect URI registered with the app (default: http://localhost:<port>/callback).
+  - --consent-url:  Consent page of the vendor's marketplace (default: Vendor Central NA).
+  - --draft:        The app is in draft state (adds version=beta to the consent URL).
+  - --code:         Exchange this authorization code instead of waiting for the redirect.
+  - --timeout:      How long to wait for the redirect (default: 10m).
+*/
+func cmdReauthorize(args []string) int {
+	fs := flag.NewFlagSet("reauthorize", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8765", "Address of the redirect listener")
+	redirectURI := fs.String("redirect-uri", "", "Redirect URI registered with the app (default: http://localhost:<port>/callback)")
+	consentURL := fs.String("consent-url", defaultConsentURL, "Consent page of the vendor's marketplace")
+	draft := fs.Bool("draft", false, "The app is in draft state (version=beta)")
+	code := fs.String("code", "", "Exchange this authorization code instead of waiting for the redirect")
+	timeout := fs.Duration("timeout", 10*time.Minute, "How long to wait for the redirect")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	if cfg.API.Auth.ApplicationID == "" || cfg.API.Auth.ClientID == "" || cfg.API.Auth.ClientSecret == "" {
+		logger.Log(utils.LevelError, "Reauthorization needs api.auth.applicationId, clientId and clientSecret.", "")
+		return 1
+	}
+	if *redirectURI == "" {
+		_, port, err := net.SplitHostPort(*listen)
+		if err != nil {
+			logger.Log(utils.LevelError, "Invalid --listen: ", err.Error())
+			return 2
+		}
+		*redirectURI = "http://localhost:" + port + "/callback"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	authCode := *code
+	if authCode == "" {
+		if authCode, err = awaitAuthorization(ctx, cfg, *listen, *redirectURI, *consentURL, *draft); err != nil {
+			logger.Log(utils.LevelError, "Authorization failed: ", err.Error())
+			return 1
+		}
+	}
+	refresh, err := exchangeAuthCode(ctx, cfg, authCode, *redirectURI)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to exchange the authorization code: ", err.Error())
+		return 1
+	}
+	store := credentials.Open(cfg.Storage.CredentialsPath)
+	if err := store.Set(lwaTokenName(cfg), refresh); err != nil {
+		logger.Log(utils.LevelError, "Failed to store the refresh token: ", err.Error())
+		return 1
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "reauthorize", "success": true, "credentials": store.Path()})
+		return 0
+	}
+	logger.PrintColored("Stored the new LWA refresh token in: ", store.Path(), "#32CD32")
+	return 0
+}
+
+/*
+awaitAuthorization prints the consent URL and serves redirectURI's path on
+listen until Amazon redirects the browser there with an authorization code
+for the state it sent.
+
+Returns:
+  - The spapi_oauth_code of the redirect.
+  - An error if the listener fails, the redirect carries an error or ctx ends first.
+*/
+func awaitAuthorization(ctx context.Context, cfg *config.Config, listen, redirectURI, consentURL string, draft bool) (string, error) {
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URI: %w", err)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(nonce)
+
+	consent, err := url.Parse(consentURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid consent URL: %w", err)
+	}
+	q := url.Values{"application_id": {cfg.API.Auth.ApplicationID}, "state": {state}, "redirect_uri": {redirectURI}}
+	if draft {
+		q.Set("version", "beta")
+	}
+	consent.RawQuery = q.Encode()
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for the redirect: %w", err)
+	}
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			http.Error(w, "Unexpected state; start avcimporter reauthorize again.", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("%s: %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("spapi_oauth_code") == "":
+			res.err = fmt.Errorf("redirect carries no spapi_oauth_code")
+		default:
+			res.code = q.Get("spapi_oauth_code")
+		}
+		if res.err != nil {
+			http.Error(w, "Authorization failed: "+res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "AVC Importer is authorized. You can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	logger.PrintColored("Open this URL, sign in to Vendor Central and authorize the app:", "", "#00FFFF")
+	fmt.Println(consent.String())
+	logger.PrintColored("Waiting for the redirect to: ", redirectURI, "#00FFFF")
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("no redirect received: %w", ctx.Err())
+	}
+}
+
+/*
+exchangeAuthCode trades an LWA authorization code for a refresh token at
+api.tokenUrl.
+*/
+func exchangeAuthCode(ctx context.Context, cfg *config.Config, code, redirectURI string) (string, error) {
+	body, _ := json.Marshal(map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          code,
+		"redirect_uri":  redirectURI,
+		"client_id":     cfg.API.Auth.ClientID,
+		"client_secret": cfg.API.Auth.ClientSecret,
+	})
+	resp, err := doWithRetry(ctx, cfg, "exchange authorization code", func(ctx context.Context) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, "POST", cfg.API.TokenURL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tok.RefreshToken == "" {
+		return "", fmt.Errorf("token response has no refresh_token")
+	}
+	return tok.RefreshToken, nil
+}
@@ -0,0 +1,277 @@
+// cmd/avcimporter/rotatekey.go
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/credentials"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/sftpx"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpKeyName returns the credential name of an SFTP key path ("privateKeyPath"
+// or "previousKeyPath") for the configured server and user.
+func sftpKeyName(cfg *config.Config, field string) string {
+	return credentials.Key("sftp", cfg.EDI.Host, cfg.EDI.Username, field)
+}
+
+/*
+applySFTPKeys replaces edi.privateKeyPath and edi.previousKeyPath with the
+paths stored by rotate-key, which win over the config file, and checks the
+inline key edi.privateKey, if any. sftpConn offers the previous key after the
+current one.
+
+Returns:
+  - An error if the credentials store cannot be read or edi.privateKey is invalid.
+*/
+func applySFTPKeys(cfg *config.Config) error {
+	store := credentials.Open(cfg.Storage.CredentialsPath)
+	for _, k := range []struct {
+		field string
+		path  *string
+	}{
+		{"privateKeyPath", &cfg.EDI.PrivateKeyPath},
+		{"previousKeyPath", &cfg.EDI.PreviousKeyPath},
+	} {
+		value, ok, err := store.Get(sftpKeyName(cfg, k.field))
+		if err != nil {
+			return err
+		}
+		if ok {
+			*k.path = value
+		}
+	}
+	if cfg.EDI.PrivateKey != "" {
+		if _, err := utils.DecodeSSHKey(cfg.EDI.PrivateKey); err != nil {
+			return fmt.Errorf("invalid edi.privateKey: %w", err)
+		}
+	}
+	return nil
+}
+
+/*
+cmdRotateKey implements `avcimporter rotate-key new|verify`, the SFTP key
+rotation Amazon asks for periodically:
+  - new:    Generates a keypair next to the current key, prints the public key
+    (also written to <key>.pub) for upload to Vendor Central and switches to
+    it. Until the rotation is verified the old key is offered after the new
+    one, so imports keep working while Amazon activates the new key.
+  - verify: Logs in with the new key alone. Once that works the old key is
+    dropped; it can then be removed from Vendor Central.
+
+The key paths are kept in the credentials store (storage.credentialsPath),
+where they override edi.privateKeyPath and edi.previousKeyPath. A running
+daemon picks them up on its next reload (SIGHUP or POST /reload). Keys given
+inline (edi.privateKey) are rotated by replacing the secret instead. New keys
+are encrypted with edi.privateKeyPassphrase, if set.
+
+Flags (new):
+  - --type:  "rsa" (default), "ecdsa" (P-256) or "ed25519".
+  - --bits:  RSA key size (default: 4096).
+  - --out:   Path of the new private key (default: id_<type>_<timestamp> next to the current key).
+  - --force: Start over although an earlier rotation was not verified.
+
+Flags (verify):
+  - --timeout: Limit for the login check (default: 1m).
+*/
+func cmdRotateKey(args []string) int {
+	if len(args) == 0 || (args[0] != "new" && args[0] != "verify") {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter rotate-key new|verify [flags]")
+		return 2
+	}
+	fs := flag.NewFlagSet("rotate-key "+args[0], flag.ContinueOnError)
+	keyType := fs.String("type", "rsa", "Key type: rsa, ecdsa or ed25519")
+	bits := fs.Int("bits", 4096, "RSA key size")
+	out := fs.String("out", "", "Path of the new private key")
+	force := fs.Bool("force", false, "Start over although an earlier rotation was not verified")
+	timeout := fs.Duration("timeout", time.Minute, "Limit for the login check")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	if cfg.EDI.PrivateKey != "" {
+		logger.Log(utils.LevelError, "rotate-key manages key files; edi.privateKey is set inline, rotate it in your secret store.", "")
+		return 1
+	}
+	if cfg.EDI.Host == "" || cfg.EDI.Username == "" || cfg.EDI.PrivateKeyPath == "" {
+		logger.Log(utils.LevelError, "Key rotation needs edi.host, edi.username and edi.privateKeyPath.", "")
+		return 1
+	}
+	store := credentials.Open(cfg.Storage.CredentialsPath)
+	if args[0] == "verify" {
+		return verifyRotatedKey(cfg, store, *timeout)
+	}
+
+	if cfg.EDI.PreviousKeyPath != "" && !*force {
+		logger.Log(utils.LevelError, "A key rotation is in progress; run `rotate-key verify` or pass --force: ", cfg.EDI.PreviousKeyPath)
+		return 1
+	}
+	path := *out
+	if path == "" {
+		path = filepath.Join(filepath.Dir(cfg.EDI.PrivateKeyPath), fmt.Sprintf("id_%s_%s", *keyType, time.Now().UTC().Format("20060102T150405")))
+	}
+	publicKey, err := generateKey(path, *keyType, *bits, cfg.EDI.Username+"@avcimporter", cfg.EDI.PrivateKeyPassphrase)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to generate key: ", err.Error())
+		return 1
+	}
+	if err := store.Set(sftpKeyName(cfg, "previousKeyPath"), cfg.EDI.PrivateKeyPath); err != nil {
+		logger.Log(utils.LevelError, "Failed to store key paths: ", err.Error())
+		return 1
+	}
+	if err := store.Set(sftpKeyName(cfg, "privateKeyPath"), path); err != nil {
+		logger.Log(utils.LevelError, "Failed to store key paths: ", err.Error())
+		return 1
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "rotate-key", "success": true, "step": "new",
+			"privateKey": path, "publicKey": publicKey, "previousKey": cfg.EDI.PrivateKeyPath})
+		return 0
+	}
+	logger.PrintColored("Generated key: ", path, "#32CD32")
+	logger.PrintColored("Public key (also in "+path+".pub), upload it in Vendor Central:", "", "#00FFFF")
+	fmt.Println(publicKey)
+	logger.Log(utils.LevelWarn, "Until `rotate-key verify` succeeds the old key is still offered: ", cfg.EDI.PrivateKeyPath)
+	return 0
+}
+
+// verifyRotatedKey logs in with the new key alone and ends the grace period
+// of the old key if that works.
+func verifyRotatedKey(cfg *config.Config, store *credentials.Store, timeout time.Duration) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := sftpConn(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid SFTP settings: ", err.Error())
+		return 1
+	}
+	// The check connects once, offering the new key alone.
+	conn.FallbackKeyPath, conn.Retry = "", utils.RetryPolicy{}
+	access := sftpx.Check(ctx, conn, sftpx.CheckOptions{InboundDir: cfg.EDI.InboundDir, OutboundDir: cfg.EDI.OutboundDir})
+	if access.Login != nil {
+		if output.IsJSON() {
+			output.Emit(map[string]interface{}{"command": "rotate-key", "success": false, "step": "verify",
+				"privateKey": cfg.EDI.PrivateKeyPath, "error": access.Login.Error()})
+		} else {
+			logger.Log(utils.LevelError, "Login with the new key failed: ", access.Login.Error())
+			if cfg.EDI.PreviousKeyPath != "" {
+				logger.Log(utils.LevelWarn, "The old key is still offered; retry once Vendor Central has activated the new one: ", cfg.EDI.PreviousKeyPath)
+			}
+		}
+		return 1
+	}
+	// An empty stored value also overrides edi.previousKeyPath in the config file.
+	if err := store.Set(sftpKeyName(cfg, "previousKeyPath"), ""); err != nil {
+		logger.Log(utils.LevelError, "Failed to store key paths: ", err.Error())
+		return 1
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "rotate-key", "success": true, "step": "verify",
+			"privateKey": cfg.EDI.PrivateKeyPath, "retiredKey": cfg.EDI.PreviousKeyPath})
+		return 0
+	}
+	logger.PrintColored("Login with the new key works: ", cfg.EDI.PrivateKeyPath, "#32CD32")
+	if cfg.EDI.PreviousKeyPath != "" {
+		logger.PrintColored("The old key is no longer used and can be removed from Vendor Central: ", cfg.EDI.PreviousKeyPath, "#32CD32")
+	}
+	return 0
+}
+
+/*
+generateKey writes a new private key to path (mode 0600, OpenSSH format,
+encrypted if passphrase is set) and its public key to path + ".pub". Existing
+files are never overwritten.
+
+Returns:
+  - The public key in authorized_keys format.
+  - An error if keyType is unknown or a file cannot be written.
+*/
+func generateKey(path, keyType string, bits int, comment, passphrase string) (string, error) {
+	var priv crypto.PrivateKey
+	var pub crypto.PublicKey
+	switch keyType {
+	case "rsa":
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return "", err
+		}
+		priv, pub = k, &k.PublicKey
+	case "ecdsa":
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		priv, pub = k, &k.PublicKey
+	case "ed25519":
+		p, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		priv, pub = k, p
+	default:
+		return "", fmt.Errorf("unknown key type %q: expected \"rsa\", \"ecdsa\" or \"ed25519\"", keyType)
+	}
+	var block *pem.Block
+	var err error
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, comment, []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, comment)
+	}
+	if err != nil {
+		return "", err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " " + comment
+
+	if err := writeNew(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return "", err
+	}
+	if err := writeNew(path+".pub", []byte(authorized+"\n"), 0o644); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return authorized, nil
+}
+
+// writeNew creates path with data, failing if the file already exists.
+func writeNew(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
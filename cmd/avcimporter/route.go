@@ -0,0 +1,242 @@
+// cmd/avcimporter/route.go
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/routing"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+plannedShipment is one warehouse's part of a purchase order, emitted by
+route.
+
+Fields:
+  - ShipmentID: Suggested shipment ID: the PO number, suffixed with the warehouse when the PO is split.
+  - Warehouse:  Ship-from party ID.
+  - Items:      Item sequence numbers of the lines.
+  - Units:      Ordered units of the lines, in eaches.
+*/
+type plannedShipment struct {
+	PurchaseOrderNumber string   `json:"purchaseOrderNumber"`
+	ShipmentID          string   `json:"shipmentId"`
+	Warehouse           string   `json:"warehouse"`
+	Items               []string `json:"items"`
+	Units               int      `json:"units"`
+
+	lines []spapi.OrderItem
+}
+
+/*
+cmdRoute implements `avcimporter route [PO number...]`: it assigns the lines of
+stored purchase orders to warehouses by the routing rules and shows the
+shipments each PO splits into. Without PO numbers it plans every order that
+was acknowledged but not shipped yet.
+
+With --out it writes a shipment status file for sync-status with one row per
+line, the suggested shipment ID and the warehouse as ship-from party, so each
+warehouse can fill in what it shipped and sync-status submits one shipment
+confirmation per warehouse. Quantities are the ordered ones.
+
+Flags:
+  - --out: Write the shipment status CSV to this file ("-" for stdout).
+*/
+func cmdRoute(args []string) int {
+	fs := flag.NewFlagSet("route", flag.ContinueOnError)
+	out := fs.String("out", "", "Write a shipment status CSV for sync-status to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *out == "-" {
+		// The CSV owns stdout; keep messages out of it.
+		logger.SetOutput(os.Stderr)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	orders, err := ordersToRoute(cfg, fs.Args())
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to collect orders: ", err.Error())
+		return 1
+	}
+	var planned []plannedShipment
+	for _, o := range orders {
+		planned = append(planned, planShipments(cfg, o)...)
+	}
+
+	if *out != "" {
+		w := io.Writer(os.Stdout)
+		if *out != "-" {
+			f, err := os.Create(*out)
+			if err != nil {
+				logger.Log(utils.LevelError, "Failed to write shipment file: ", err.Error())
+				return 1
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := writeShipmentPlan(w, planned); err != nil {
+			logger.Log(utils.LevelError, "Failed to write shipment file: ", err.Error())
+			return 1
+		}
+	}
+	if output.IsJSON() {
+		if *out != "-" {
+			if planned == nil {
+				planned = []plannedShipment{}
+			}
+			output.Emit(map[string]interface{}{"command": "route", "success": true, "shipments": planned})
+		}
+		return 0
+	}
+	if *out != "-" {
+		printShipmentPlan(planned)
+	}
+	if *out != "" && *out != "-" {
+		logger.PrintColored("Wrote shipment file: ", *out, "#32CD32")
+	}
+	return 0
+}
+
+/*
+ordersToRoute loads the named purchase orders, or every stored order that was
+acknowledged but not shipped yet.
+*/
+func ordersToRoute(cfg *config.Config, poNumbers []string) ([]spapi.PurchaseOrder, error) {
+	var orders []spapi.PurchaseOrder
+	if len(poNumbers) > 0 {
+		for _, po := range poNumbers {
+			o, err := storage.LoadOrder(cfg.Storage.SavePath, po)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, o)
+		}
+		return orders, nil
+	}
+	all, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		return nil, err
+	}
+	store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+	for _, o := range all {
+		lc, _, err := store.Get(o.PurchaseOrderNumber)
+		if err != nil {
+			return nil, err
+		}
+		if lc.Stage() == lifecycle.StageAcknowledged {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+// router converts the configured routing.
+func router(cfg *config.Config) routing.Router {
+	r := routing.Router{Regions: cfg.Routing.Regions, Default: cfg.Routing.Default}
+	for _, rule := range cfg.Routing.Rules {
+		r.Rules = append(r.Rules, routing.Rule{Warehouse: rule.Warehouse, ShipTo: rule.ShipTo, Regions: rule.Regions, SKUs: rule.SKUs})
+	}
+	return r
+}
+
+/*
+orderRouter returns the router for one order: lines no rule matches go to
+routing.default or, without one, to the order's selling party.
+*/
+func orderRouter(cfg *config.Config, o spapi.PurchaseOrder) routing.Router {
+	r := router(cfg)
+	if r.Default == "" && o.OrderDetails.SellingParty != nil {
+		r.Default = o.OrderDetails.SellingParty.PartyID
+	}
+	return r
+}
+
+// planShipments splits one order into a shipment per warehouse.
+func planShipments(cfg *config.Config, o spapi.PurchaseOrder) []plannedShipment {
+	split := orderRouter(cfg, o).Split(o)
+	planned := make([]plannedShipment, len(split))
+	for i, s := range split {
+		p := plannedShipment{PurchaseOrderNumber: o.PurchaseOrderNumber, ShipmentID: o.PurchaseOrderNumber, Warehouse: s.Warehouse, Items: []string{}, lines: s.Items}
+		if len(split) > 1 {
+			p.ShipmentID += "-" + s.Warehouse
+		}
+		for _, it := range s.Items {
+			p.Items = append(p.Items, it.ItemSequenceNumber)
+			p.Units += orderEaches(it.OrderedQuantity)
+		}
+		planned[i] = p
+	}
+	return planned
+}
+
+// writeShipmentPlan writes planned shipments as a sync-status CSV file.
+func writeShipmentPlan(w io.Writer, planned []plannedShipment) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(shipmentColumns))
+	for i, c := range shipmentColumns {
+		header[i] = c.Name
+	}
+	cw.Write(header)
+	for _, p := range planned {
+		for _, it := range p.lines {
+			row := map[string]string{
+				"shipmentId":              p.ShipmentID,
+				"purchaseOrderNumber":     p.PurchaseOrderNumber,
+				"itemSequenceNumber":      it.ItemSequenceNumber,
+				"amazonProductIdentifier": it.AmazonProductIdentifier,
+				"vendorProductIdentifier": it.VendorProductIdentifier,
+				"shippedQuantity":         strconv.Itoa(it.OrderedQuantity.Amount),
+				"unitOfMeasure":           it.OrderedQuantity.UnitOfMeasure,
+				"shipFromPartyId":         p.Warehouse,
+			}
+			rec := make([]string, len(shipmentColumns))
+			for i, c := range shipmentColumns {
+				rec[i] = row[c.Name]
+			}
+			cw.Write(rec)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// printShipmentPlan renders planned shipments for humans.
+func printShipmentPlan(planned []plannedShipment) {
+	if len(planned) == 0 {
+		logger.PrintColored("No purchase orders to route.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PO NUMBER\tSHIPMENT\tWAREHOUSE\tITEMS\tUNITS")
+	for _, p := range planned {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", p.PurchaseOrderNumber, p.ShipmentID, p.Warehouse, strings.Join(p.Items, ", "), p.Units)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		if planned[i].ShipmentID != planned[i].PurchaseOrderNumber {
+			color = "#FFFF00"
+		}
+		logger.PrintColored(line, "", color)
+	}
+}
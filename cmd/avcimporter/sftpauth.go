@@ -0,0 +1,92 @@
+// cmd/avcimporter/sftpauth.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/sftpx"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+sftpConn describes the EDI SFTP server of cfg: address, user, keys (after
+applySFTPKeys), keyboard-interactive answers, the retry.sftp policy and the
+handling of missing directories.
+
+Returns:
+  - An error if edi.privateKey, a prompt pattern or a TOTP secret is invalid.
+*/
+func sftpConn(cfg *config.Config) (sftpx.Conn, error) {
+	conn := sftpx.Conn{
+		Host:              cfg.EDI.Host,
+		Port:              cfg.EDI.Port,
+		Username:          cfg.EDI.Username,
+		PrivateKeyPath:    cfg.EDI.PrivateKeyPath,
+		Passphrase:        cfg.EDI.PrivateKeyPassphrase,
+		FallbackKeyPath:   cfg.EDI.PreviousKeyPath,
+		Retry:             retryPolicy(cfg.Retry.SFTP, "sftp"),
+		CreateOutboundDir: cfg.EDI.CreateOutboundDir,
+		DiscoverDirs:      cfg.EDI.DiscoverDirs,
+		Logger:            logger,
+		Permissions:       permissions(),
+	}
+	if cfg.EDI.PrivateKey != "" {
+		key, err := utils.DecodeSSHKey(cfg.EDI.PrivateKey)
+		if err != nil {
+			return conn, fmt.Errorf("invalid edi.privateKey: %w", err)
+		}
+		conn.PrivateKey = key
+	}
+	challenge, err := sftpChallenge(cfg)
+	if err != nil {
+		return conn, err
+	}
+	conn.KeyboardInteractive = challenge
+	return conn, nil
+}
+
+/*
+sftpChallenge answers the prompts of SFTP servers requiring
+keyboard-interactive authentication (e.g. a one-time code after the key) as
+set in edi.keyboardInteractive. Without entries it returns nil, so only
+public key authentication is offered.
+
+Returns:
+  - An error if a prompt pattern or TOTP secret is invalid.
+*/
+func sftpChallenge(cfg *config.Config) (ssh.KeyboardInteractiveChallenge, error) {
+	if len(cfg.EDI.KeyboardInteractive) == 0 {
+		return nil, nil
+	}
+	prompts := make([]utils.SFTPPrompt, 0, len(cfg.EDI.KeyboardInteractive))
+	for i, p := range cfg.EDI.KeyboardInteractive {
+		var prompt utils.SFTPPrompt
+		if p.Prompt != "" {
+			re, err := regexp.Compile("(?i)" + p.Prompt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].prompt: %w", i, err)
+			}
+			prompt.Pattern = re
+		}
+		switch p.Source {
+		case "env":
+			prompt.Answer = utils.EnvAnswer(p.Env)
+		case "totp":
+			answer, err := utils.TOTPAnswer(p.TOTPSecret)
+			if err != nil {
+				return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].totpSecret: %w", i, err)
+			}
+			prompt.Answer = answer
+		case "terminal":
+			prompt.Answer = utils.TerminalAnswer(os.Stdin, os.Stderr)
+		default:
+			return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].source %q", i, p.Source)
+		}
+		prompts = append(prompts, prompt)
+	}
+	return utils.SFTPPromptChallenge(prompts, logger), nil
+}
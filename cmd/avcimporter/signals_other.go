@@ -0,0 +1,35 @@
+// cmd/avcimporter/signals_other.go
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/*
+handleSignals reloads the configuration on SIGHUP and triggers every flow on
+SIGUSR1 until ctx is cancelled.
+*/
+func (a *app) handleSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGHUP {
+					a.reload()
+				} else {
+					a.trigger()
+				}
+			}
+		}
+	}()
+}
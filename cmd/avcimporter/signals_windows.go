@@ -0,0 +1,12 @@
+// cmd/avcimporter/signals_windows.go
+//go:build windows
+
+package main
+
+import "context"
+
+/*
+handleSignals does nothing on Windows, which has no SIGHUP or SIGUSR1; use
+POST /reload and /trigger on server.addr or server.socket instead.
+*/
+func (a *app) handleSignals(ctx context.Context) {}
@@ -0,0 +1,137 @@
+// cmd/avcimporter/simulate.go
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/export"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/simulate"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdSimulate implements `avcimporter simulate`: it writes synthetic inbound 850
+purchase orders (random PO numbers, quantities and ship-to fulfillment
+centers) so the whole pipeline and the ERP mapping can be tested before
+go-live. Items come from the SKU cross-reference, so exports can map them.
+The files go to edi.localInboundDir, where `watch` and the daemon pick them
+up, or to --out. Interchanges are always marked as test data (ISA15 "T").
+
+Flags:
+  - --orders:  Number of purchase orders (default: 10).
+  - --out:     Directory to write to (default: edi.localInboundDir).
+  - --sku-map: SKU cross-reference CSV with the items (default: the skuMap of the first export that has one).
+  - --seed:    Seed of the generator, to repeat a run (default: random, printed).
+*/
+func cmdSimulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	orders := fs.Int("orders", 10, "Number of purchase orders to generate")
+	out := fs.String("out", "", "Directory to write to (default: edi.localInboundDir)")
+	skuMap := fs.String("sku-map", "", "SKU cross-reference CSV with the items to order (default: the first export's skuMap)")
+	seed := fs.Int64("seed", 0, "Seed of the generator, to repeat a run (default: random)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *orders <= 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter simulate [--orders 10] [--out dir] [--sku-map file] [--seed n]")
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	dir := *out
+	if dir == "" {
+		dir = cfg.EDI.LocalInboundDir
+	}
+	if dir == "" {
+		logger.Log(utils.LevelError, "Nowhere to write: ", "set edi.localInboundDir or pass --out")
+		return 2
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	products, source, err := simulatedProducts(cfg, *skuMap)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read SKU map: ", err.Error())
+		return 1
+	}
+	opts, err := cfg.X12Options(simulate.AmazonID)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid X12 settings: ", err.Error())
+		return 1
+	}
+	files, err := simulate.Generate850s(simulate.Options{
+		Orders:     *orders,
+		Products:   products,
+		ReceiverID: cfg.EDI.SenderID,
+		X12:        opts,
+		Seed:       *seed,
+	})
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to generate orders: ", err.Error())
+		return 1
+	}
+
+	if err := permissions().MkdirAll(dir); err != nil {
+		logger.Log(utils.LevelError, "Failed to create directory: ", err.Error())
+		return 1
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name)
+		if err := permissions().WriteFile(path, f.Data); err != nil {
+			logger.Log(utils.LevelError, "Failed to write order: ", err.Error())
+			return 1
+		}
+		names = append(names, path)
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "simulate", "success": true,
+			"dir": dir, "seed": *seed, "items": source, "files": names})
+		return 0
+	}
+	for _, name := range names {
+		logger.PrintColored("Wrote: ", name, "#FFFFFF")
+	}
+	logger.PrintColored("Generated synthetic purchase orders: ", strconv.Itoa(len(files))+" in "+dir, "#32CD32")
+	logger.PrintColored("Items from: ", source, "#00FFFF")
+	logger.PrintColored("Repeat with: ", "--seed "+strconv.FormatInt(*seed, 10), "#00FFFF")
+	return 0
+}
+
+// simulatedProducts returns the items of the SKU map at path, or of the first
+// export with one, and where they came from. Without any, made-up items are used.
+func simulatedProducts(cfg *config.Config, path string) ([]simulate.Product, string, error) {
+	if path == "" {
+		for _, e := range cfg.Exports {
+			if e.SKUMap != "" {
+				path = e.SKUMap
+				break
+			}
+		}
+	}
+	if path == "" {
+		return nil, "sample items (no SKU map configured)", nil
+	}
+	m, err := export.LoadSKUMap(path)
+	if err != nil {
+		return nil, "", err
+	}
+	skus := make([]string, 0, len(m))
+	for sku := range m {
+		skus = append(skus, sku)
+	}
+	if len(skus) == 0 {
+		return nil, "sample items (" + path + " is empty)", nil
+	}
+	return simulate.Products(skus), path, nil
+}
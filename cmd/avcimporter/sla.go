@@ -0,0 +1,146 @@
+// cmd/avcimporter/sla.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+runSLAFlow checks every open purchase order against cfg.SLA.Rules. Each
+alert is printed on every run; it is passed to hooks subscribed to "sla" only
+when its state changes (due soon, then overdue), so notifications are not
+repeated every cycle.
+*/
+func runSLAFlow(ctx context.Context, cfg *config.Config) error {
+	store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+	orders, err := store.All()
+	if err != nil {
+		return err
+	}
+	windows, err := orderDeadlines(cfg)
+	if err != nil {
+		return err
+	}
+	rules := slaRules(cfg)
+	result := output.FromContext(ctx)
+	now := time.Now()
+	overdue, dueSoon := 0, 0
+	for _, o := range orders {
+		if !o.Open() {
+			continue
+		}
+		active := map[string]bool{}
+		for _, a := range o.Evaluate(rules, windows[o.PONumber], now, time.Duration(cfg.SLA.WarnBefore)) {
+			active[a.Rule] = true
+			color := "#FFFF00"
+			if a.State == lifecycle.Overdue {
+				color = "#FF0000"
+				overdue++
+			} else {
+				dueSoon++
+			}
+			logger.PrintColoredContext(ctx, fmt.Sprintf("SLA %s %s: ", a.Rule, a.State), fmt.Sprintf("%s needs %s by %s", a.PONumber, a.Kind, a.Due.Format(time.RFC3339)), color)
+			if o.Alerted[a.Rule] == a.State {
+				continue
+			}
+			result.AddPONumbers(a.PONumber)
+			err := runHooks(ctx, hooks.Event{Event: hooks.SLA, Type: "sla", Fields: map[string]string{
+				"poNumber": a.PONumber,
+				"rule":     a.Rule,
+				"document": a.Kind,
+				"state":    a.State,
+				"due":      a.Due.UTC().Format(time.RFC3339),
+			}})
+			if err != nil {
+				return err
+			}
+			if err := store.SetAlerted(a.PONumber, a.Rule, a.State); err != nil {
+				return err
+			}
+		}
+		for rule := range o.Alerted {
+			if !active[rule] {
+				if err := store.SetAlerted(o.PONumber, rule, ""); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if overdue+dueSoon > 0 {
+		logger.LogContext(ctx, utils.LevelWarn, "SLA alerts: ", fmt.Sprintf("%d overdue, %d due soon", overdue, dueSoon))
+	} else {
+		logger.PrintColoredContext(ctx, "SLA: ", "all open orders on time", "#32CD32")
+	}
+	return nil
+}
+
+// slaRules converts the configured SLA rules.
+func slaRules(cfg *config.Config) []lifecycle.Rule {
+	rules := make([]lifecycle.Rule, 0, len(cfg.SLA.Rules))
+	for _, r := range cfg.SLA.Rules {
+		rules = append(rules, lifecycle.Rule{Name: r.Name, Kind: r.Document, Within: time.Duration(r.Within), Deadline: r.Before})
+	}
+	return rules
+}
+
+/*
+orderDeadlines returns the ship and delivery window boundaries of every
+order, keyed by PO number and then by SLARule.Before name: those tracked with
+the lifecycle (EDI 850s and SP‑API orders), overridden by the stored SP‑API
+order where there is one.
+*/
+func orderDeadlines(cfg *config.Config) (map[string]map[string]time.Time, error) {
+	orders, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		return nil, err
+	}
+	tracked, err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).All()
+	if err != nil {
+		return nil, err
+	}
+	deadlines := make(map[string]map[string]time.Time, len(orders)+len(tracked))
+	for _, o := range tracked {
+		if len(o.Deadlines) > 0 {
+			deadlines[o.PONumber] = o.Deadlines
+		}
+	}
+	for _, o := range orders {
+		d := map[string]time.Time{}
+		addWindow(d, "shipWindow", o.OrderDetails.ShipWindow)
+		addWindow(d, "deliveryWindow", o.OrderDetails.DeliveryWindow)
+		deadlines[o.PurchaseOrderNumber] = d
+	}
+	return deadlines, nil
+}
+
+// addWindow adds the Start and End of an ISO‑8601 "start--end" window to d.
+func addWindow(d map[string]time.Time, name, window string) {
+	start, end, ok := strings.Cut(window, "--")
+	if !ok {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, start); err == nil {
+		d[name+"Start"] = t
+	}
+	if t, err := time.Parse(time.RFC3339, end); err == nil {
+		d[name+"End"] = t
+	}
+}
+
+// slaAlerts evaluates one order for `status`, or returns nil without rules.
+func slaAlerts(cfg *config.Config, o lifecycle.Order, windows map[string]map[string]time.Time) []lifecycle.Alert {
+	if len(cfg.SLA.Rules) == 0 {
+		return nil
+	}
+	return o.Evaluate(slaRules(cfg), windows[o.PONumber], time.Now(), time.Duration(cfg.SLA.WarnBefore))
+}
@@ -0,0 +1,113 @@
+// cmd/avcimporter/staging.go
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/staging"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+beginStaging starts a staged run below storage.stagingPath, if set: the flows
+write their files to the staging area (see savePath), use staged copies of the
+checkpoint, dedupe and lifecycle files, and queue acknowledgments, exports and
+remote deletions until finishStaging commits the run.
+
+Returns:
+  - The context to run the flows with, carrying the staged run.
+  - The staged run, or nil when staging is disabled.
+  - An error if the staging area cannot be prepared.
+*/
+func (a *app) beginStaging(ctx context.Context, cfg *config.Config) (context.Context, *staging.Run, error) {
+	if cfg.Storage.StagingPath == "" {
+		return ctx, nil, nil
+	}
+	stage, err := staging.Begin(cfg.Storage.StagingPath, cfg.Storage.SavePath, permissions())
+	if err != nil {
+		return ctx, nil, err
+	}
+	staged := *cfg
+	for _, p := range []*string{&staged.Storage.CheckpointPath, &staged.Storage.DedupePath, &staged.Storage.LifecyclePath} {
+		if *p == "" {
+			continue
+		}
+		if *p, err = stage.Stage(*p); err != nil {
+			stage.Discard()
+			return ctx, nil, err
+		}
+	}
+	a.holder.Set(&staged)
+	a.checkpoints = checkpoint.Open(staged.Storage.CheckpointPath, permissions())
+	logger.PrintColored("Staging run in: ", stage.Dir(), "#00FFFF")
+	return staging.WithRun(ctx, stage), stage, nil
+}
+
+/*
+finishStaging publishes a staged run if every flow succeeded, or with
+--commit-partial whatever the flows staged; otherwise it discards the run,
+leaving storage, checkpoints and partners as they were so the next run
+imports the same data again.
+
+Returns:
+  - An error if committing failed; the staging area is then kept.
+*/
+func (a *app) finishStaging(ctx context.Context, cfg *config.Config, stage *staging.Run, runErr error) error {
+	a.holder.Set(cfg)
+	a.checkpoints = checkpoint.Open(cfg.Storage.CheckpointPath, permissions())
+	if runErr != nil && !commitPartial {
+		logger.Log(utils.LevelWarn, "Discarding staged run: ", "a flow failed (use --commit-partial to keep what succeeded)")
+		if err := stage.Discard(); err != nil {
+			logger.Log(utils.LevelWarn, "Failed to clean up staging area: ", err.Error())
+		}
+		return nil
+	}
+	moved, err := stage.Commit(utils.WithLogPrefix(ctx, "Commit"))
+	for _, r := range a.results.Flows() {
+		r.Relocate(stage.Final)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit staged run (kept in %s): %w", stage.Dir(), err)
+	}
+	logger.PrintColored("Committed staged run: ", fmt.Sprintf("%d file(s)", len(moved)), "#32CD32")
+	return nil
+}
+
+/*
+savePath returns the directory a flow writes its files to: storage.savePath,
+or its place in the staging area when the run is staged.
+*/
+func savePath(ctx context.Context, cfg *config.Config) string {
+	return stagedPath(ctx, cfg.Storage.SavePath)
+}
+
+// stagedPath returns where a file destined for p is written in the current run.
+func stagedPath(ctx context.Context, p string) string {
+	if stage := staging.FromContext(ctx); stage != nil {
+		return stage.Path(p)
+	}
+	return p
+}
+
+// finalPath returns where a file written by the current run ends up.
+func finalPath(ctx context.Context, p string) string {
+	if stage := staging.FromContext(ctx); stage != nil {
+		return stage.Final(p)
+	}
+	return p
+}
+
+/*
+afterCommit runs fn now or, when the run is staged, once it is committed; use
+it for steps other systems observe, such as uploads and remote deletions.
+*/
+func afterCommit(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if stage := staging.FromContext(ctx); stage != nil {
+		stage.OnCommit(name, fn)
+		return nil
+	}
+	return fn(ctx)
+}
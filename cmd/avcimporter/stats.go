@@ -0,0 +1,192 @@
+// cmd/avcimporter/stats.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/history"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+flowStats is the import volume and error rate of one flow.
+
+Fields:
+  - Flow:      Flow name.
+  - Runs:      Runs started in the period.
+  - Failed:    Of those, runs that failed.
+  - ErrorRate: Failed / Runs.
+  - Files:     Files downloaded or written.
+  - POs:       Purchase orders seen.
+  - Bytes:     Bytes downloaded.
+*/
+type flowStats struct {
+	Flow      string  `json:"flow"`
+	Runs      int     `json:"runs"`
+	Failed    int     `json:"failed"`
+	ErrorRate float64 `json:"errorRate"`
+	Files     int     `json:"files"`
+	POs       int     `json:"pos"`
+	Bytes     int64   `json:"bytes"`
+}
+
+/*
+cmdStats implements `avcimporter stats`: it summarizes a period from the state
+store — import volume and error rate per flow from the run history,
+acknowledgment latency and ship notice timeliness from the lifecycle tracker.
+
+Flags:
+  - --since: Start of the period, a duration before now (e.g. "30d", "12h") or a date (default: 30d).
+*/
+func cmdStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "30d", "Start of the period: a duration (e.g. 30d, 12h) or a date (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter stats [--since 30d]")
+		return 2
+	}
+	now := time.Now()
+	since, err := parseSince(*sinceFlag, now)
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid --since: ", err.Error())
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	runs, err := history.Open(cfg.Storage.HistoryPath, 0, permissions()).List(history.Filter{Since: since})
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read run history: ", err.Error())
+		return 1
+	}
+	tracked, err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).All()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read lifecycle: ", err.Error())
+		return 1
+	}
+	flows := summarizeRuns(runs)
+	orders := lifecycle.Summarize(tracked, since, now)
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{
+			"command": "stats",
+			"success": true,
+			"since":   since,
+			"flows":   flows,
+			"orders": map[string]interface{}{
+				"received":     orders.Orders,
+				"acknowledged": orders.Acknowledged,
+				"ackLatencySeconds": map[string]float64{
+					"median": orders.AckLatency.Median.Seconds(),
+					"p90":    orders.AckLatency.P90.Seconds(),
+					"max":    orders.AckLatency.Max.Seconds(),
+				},
+				"shipped":     orders.Shipped,
+				"shippedLate": orders.ShippedLate,
+				"overdue":     orders.Overdue,
+				"rejected":    orders.Rejected,
+			},
+		})
+		return 0
+	}
+	logger.PrintColored("Statistics since: ", since.Format(time.RFC3339), "#00FFFF")
+	printFlowStats(flows)
+	printOrderStats(orders)
+	return 0
+}
+
+// summarizeRuns aggregates runs per flow, sorted by flow name.
+func summarizeRuns(runs []history.Entry) []flowStats {
+	byFlow := map[string]*flowStats{}
+	for _, r := range runs {
+		s := byFlow[r.Flow]
+		if s == nil {
+			s = &flowStats{Flow: r.Flow}
+			byFlow[r.Flow] = s
+		}
+		s.Runs++
+		if !r.Success {
+			s.Failed++
+		}
+		s.Files += r.Files
+		s.POs += r.POs
+		s.Bytes += r.Bytes
+	}
+	out := make([]flowStats, 0, len(byFlow))
+	for _, s := range byFlow {
+		s.ErrorRate = float64(s.Failed) / float64(s.Runs)
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Flow < out[j].Flow })
+	return out
+}
+
+// printFlowStats renders the per-flow table, flows with failures in yellow or red.
+func printFlowStats(flows []flowStats) {
+	if len(flows) == 0 {
+		logger.PrintColored("No runs recorded.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLOW\tRUNS\tFAILED\tERROR RATE\tFILES\tPOS\tDOWNLOADED")
+	for _, s := range flows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%d\t%d\t%.1f MB\n", s.Flow, s.Runs, s.Failed, s.ErrorRate*100, s.Files, s.POs, float64(s.Bytes)/(1<<20))
+	}
+	tw.Flush()
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#FFFFFF")
+	for i, s := range flows {
+		color := "#32CD32"
+		switch {
+		case s.ErrorRate >= 0.1:
+			color = "#FF0000"
+		case s.Failed > 0:
+			color = "#FFFF00"
+		}
+		logger.PrintColored(lines[i+1], "", color)
+	}
+}
+
+// printOrderStats renders acknowledgment latency and ship notice timeliness.
+func printOrderStats(s lifecycle.Stats) {
+	if s.Orders == 0 {
+		logger.PrintColored("No purchase orders received.", "", "#FFFF00")
+		return
+	}
+	color := "#32CD32"
+	if s.Acknowledged < s.Orders {
+		color = "#FFFF00"
+	}
+	logger.PrintColored("Purchase orders: ", fmt.Sprintf("%d received, %d acknowledged", s.Orders, s.Acknowledged), color)
+	if s.Acknowledged > 0 {
+		logger.PrintColored("Acknowledgment latency: ", fmt.Sprintf("median %s, p90 %s, max %s",
+			s.AckLatency.Median.Round(time.Second), s.AckLatency.P90.Round(time.Second), s.AckLatency.Max.Round(time.Second)), "#FFFFFF")
+	}
+	color = "#32CD32"
+	switch {
+	case s.Overdue > 0:
+		color = "#FF0000"
+	case s.ShippedLate > 0:
+		color = "#FFFF00"
+	}
+	logger.PrintColored("Ship notices: ", fmt.Sprintf("%d on time, %d late, %d overdue", s.Shipped-s.ShippedLate, s.ShippedLate, s.Overdue), color)
+	color = "#32CD32"
+	if s.Rejected > 0 {
+		color = "#FF0000"
+	}
+	logger.PrintColored("Rejected documents: ", fmt.Sprint(s.Rejected), color)
+}
@@ -0,0 +1,335 @@
+// cmd/avcimporter/status.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+trackDocument records d on the lifecycle of poNumber. Tracking never fails
+the caller: a store error is only reported.
+*/
+func trackDocument(cfg *config.Config, poNumber string, d lifecycle.Document) {
+	if err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).Record(poNumber, d); err != nil {
+		logger.Log(utils.LevelWarn, "Failed to track document: ", err.Error())
+	}
+}
+
+/*
+trackOrder records the inbound purchase order document d of o and keeps the
+order's window bounds with its lifecycle, so SLA rules apply to EDI and
+SP‑API orders alike.
+*/
+func trackOrder(cfg *config.Config, o model.Order, d lifecycle.Document) {
+	for _, it := range o.Items {
+		d.Lines = append(d.Lines, lifecycle.Line{Item: it.Sequence, ASIN: it.ASIN, Units: it.Eaches()})
+	}
+	trackDocument(cfg, o.PONumber, d)
+	if err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).SetOrder(o.PONumber, o.OrderDate, o.Deadlines()); err != nil {
+		logger.Log(utils.LevelWarn, "Failed to track document: ", err.Error())
+	}
+}
+
+/*
+trackTransactionSet links one inbound transaction set (as returned by
+x12.Document.Split) to the purchase orders it concerns:
+  - 850: starts the lifecycle of order, the set in canonical form.
+  - 997: resolves the outbound documents acknowledged by AK1/AK2, per AK5 (or AK9 without AK2).
+  - 864: records the message on the PO named by a REF*PO segment.
+*/
+func trackTransactionSet(cfg *config.Config, set *x12.Document, order *model.Order, path string) {
+	isa, _ := set.Find("ISA")
+	gs, _ := set.Find("GS")
+	st, _ := set.Find("ST")
+	d := lifecycle.Document{
+		Kind:        lifecycle.SetKind(st.Element(1)),
+		SetID:       st.Element(1),
+		Direction:   "inbound",
+		Interchange: strings.TrimSpace(isa.Element(13)),
+		Group:       gs.Element(6),
+		Set:         st.Element(2),
+		Reference:   path,
+		Status:      lifecycle.Received,
+	}
+	switch d.SetID {
+	case "850":
+		if order != nil {
+			trackOrder(cfg, *order, d)
+		} else if beg, ok := set.Find("BEG"); ok {
+			trackDocument(cfg, beg.Element(3), d)
+		}
+	case "997":
+		store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+		for _, r := range functionalAckResults(set) {
+			pos, err := store.Resolve(r.group, r.set, r.status, r.detail, d)
+			if err != nil {
+				logger.Log(utils.LevelWarn, "Failed to track document: ", err.Error())
+				return
+			}
+			if verbose && len(pos) > 0 {
+				logger.PrintColored("997 "+r.status+": ", strings.Join(pos, ", "), "#00FFFF")
+			}
+		}
+	case "864":
+		var messages []string
+		for _, seg := range set.Segments {
+			if seg.ID == "MSG" {
+				messages = append(messages, seg.Element(1))
+			}
+		}
+		d.Detail = strings.Join(messages, " ")
+		for _, seg := range set.Segments {
+			if seg.ID == "REF" && seg.Element(1) == "PO" {
+				trackDocument(cfg, seg.Element(2), d)
+			}
+		}
+	}
+}
+
+// ackResult is the outcome a 997 reports for one group or transaction set.
+type ackResult struct {
+	group, set, status, detail string
+}
+
+/*
+functionalAckResults reads the AK1/AK2/AK5/AK9 loops of a 997. A result per
+AK2 set is returned; a group without AK2 loops gets one group-level result
+from AK9. Codes "A" and "E" (accepted with errors) count as accepted.
+*/
+func functionalAckResults(set *x12.Document) []ackResult {
+	var results []ackResult
+	var group string
+	sets := 0
+	status := func(code string) string {
+		if code == "A" || code == "E" {
+			return lifecycle.Accepted
+		}
+		return lifecycle.Rejected
+	}
+	for _, seg := range set.Segments {
+		switch seg.ID {
+		case "AK1":
+			group, sets = seg.Element(2), 0
+		case "AK2":
+			results = append(results, ackResult{group: group, set: seg.Element(2)})
+			sets++
+		case "AK5":
+			if sets > 0 {
+				r := &results[len(results)-1]
+				r.status, r.detail = status(seg.Element(1)), "AK5 "+seg.Element(1)
+			}
+		case "AK9":
+			if sets == 0 {
+				results = append(results, ackResult{group: group, status: status(seg.Element(1)), detail: "AK9 " + seg.Element(1)})
+			}
+		}
+	}
+	valid := results[:0]
+	for _, r := range results {
+		if r.status != "" {
+			valid = append(valid, r)
+		}
+	}
+	return valid
+}
+
+/*
+orderStatus is one row of `avcimporter status`.
+
+Fields:
+  - Stage:     Furthest stage reached (received, acknowledged, shipped, invoiced).
+  - Awaiting:  Outbound documents sent but not yet accepted or rejected.
+  - Rejected:  Documents the partner rejected.
+  - SLA:       Rules due soon or overdue (see sla.rules).
+  - Unshipped: Lines of a shipped order still left to ship, e.g. by another warehouse of a split PO.
+  - Documents: Every linked document (only for named POs).
+*/
+type orderStatus struct {
+	PONumber  string               `json:"poNumber"`
+	Stage     string               `json:"stage"`
+	Awaiting  []string             `json:"awaiting"`
+	Rejected  []string             `json:"rejected"`
+	SLA       []lifecycle.Alert    `json:"sla"`
+	Unshipped []lifecycle.Line     `json:"unshipped,omitempty"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+	Documents []lifecycle.Document `json:"documents,omitempty"`
+}
+
+/*
+cmdStatus implements `avcimporter status [PO number...]`: it shows where every
+open purchase order stands (furthest stage, documents awaiting a response,
+rejections, SLA alerts). Named POs are shown with all their documents.
+
+Flags:
+  - --all: Include invoiced (closed) orders.
+*/
+func cmdStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Include invoiced orders")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+
+	var orders []lifecycle.Order
+	if fs.NArg() > 0 {
+		for _, po := range fs.Args() {
+			o, ok, err := store.Get(po)
+			if err != nil {
+				logger.Log(utils.LevelError, "Failed to read lifecycle: ", err.Error())
+				return 1
+			}
+			if !ok {
+				logger.Log(utils.LevelError, "No documents tracked for: ", po)
+				return 1
+			}
+			orders = append(orders, o)
+		}
+	} else if orders, err = store.All(); err != nil {
+		logger.Log(utils.LevelError, "Failed to read lifecycle: ", err.Error())
+		return 1
+	}
+
+	windows, err := orderDeadlines(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read orders: ", err.Error())
+		return 1
+	}
+
+	var rows []orderStatus
+	for _, o := range orders {
+		if fs.NArg() == 0 && !*all && !o.Open() {
+			continue
+		}
+		row := newOrderStatus(cfg, o, windows)
+		if fs.NArg() > 0 {
+			row.Documents = o.Documents
+		}
+		rows = append(rows, row)
+	}
+
+	if output.IsJSON() {
+		if rows == nil {
+			rows = []orderStatus{}
+		}
+		output.Emit(map[string]interface{}{"command": "status", "success": true, "orders": rows})
+		return 0
+	}
+	printOrderStatus(rows)
+	return 0
+}
+
+/*
+newOrderStatus summarizes where an order stands, without its documents.
+
+Parameters:
+  - cfg:     Config with the SLA rules.
+  - o:       The tracked order.
+  - windows: Order deadlines by PO number (see orderDeadlines).
+*/
+func newOrderStatus(cfg *config.Config, o lifecycle.Order, windows map[string]map[string]time.Time) orderStatus {
+	row := orderStatus{PONumber: o.PONumber, Stage: o.Stage(), Awaiting: []string{}, Rejected: []string{}, SLA: []lifecycle.Alert{}, UpdatedAt: o.UpdatedAt}
+	if o.Open() {
+		row.SLA = append(row.SLA, slaAlerts(cfg, o, windows)...)
+	}
+	if row.Stage == lifecycle.StageShipped {
+		row.Unshipped = o.Unshipped()
+	}
+	for _, d := range o.Filter(lifecycle.Sent) {
+		row.Awaiting = append(row.Awaiting, documentLabel(d))
+	}
+	for _, d := range o.Filter(lifecycle.Rejected) {
+		row.Rejected = append(row.Rejected, documentLabel(d))
+	}
+	return row
+}
+
+// documentLabel names a document in status output, e.g. "855 000000007".
+func documentLabel(d lifecycle.Document) string {
+	label := d.Kind
+	if d.SetID != "" {
+		label = d.SetID
+	}
+	switch {
+	case d.Interchange != "":
+		label += " " + d.Interchange
+	case d.Reference != "":
+		label += " " + d.Reference
+	}
+	return label
+}
+
+// hasOverdue reports whether any alert is overdue.
+func hasOverdue(alerts []lifecycle.Alert) bool {
+	for _, a := range alerts {
+		if a.State == lifecycle.Overdue {
+			return true
+		}
+	}
+	return false
+}
+
+// printOrderStatus renders status rows for humans.
+func printOrderStatus(rows []orderStatus) {
+	if len(rows) == 0 {
+		logger.PrintColored("No open purchase orders.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PO NUMBER\tSTAGE\tAWAITING RESPONSE\tREJECTED\tSLA\tUPDATED")
+	for _, r := range rows {
+		var sla []string
+		for _, a := range r.SLA {
+			sla = append(sla, fmt.Sprintf("%s %s (%s)", a.Rule, a.State, a.Due.Format(time.RFC3339)))
+		}
+		stage := r.Stage
+		if len(r.Unshipped) > 0 {
+			units := 0
+			for _, l := range r.Unshipped {
+				units += l.Units
+			}
+			stage += fmt.Sprintf(" (%d units left)", units)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.PONumber, stage, strings.Join(r.Awaiting, ", "), strings.Join(r.Rejected, ", "), strings.Join(sla, ", "), r.UpdatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		switch {
+		case len(rows[i].Rejected) > 0 || hasOverdue(rows[i].SLA):
+			color = "#FF0000"
+		case len(rows[i].Awaiting) > 0 || len(rows[i].SLA) > 0:
+			color = "#FFFF00"
+		}
+		logger.PrintColored(line, "", color)
+	}
+	for _, r := range rows {
+		for _, d := range r.Documents {
+			detail := d.Status
+			if d.Detail != "" {
+				detail += " (" + d.Detail + ")"
+			}
+			logger.PrintColored(fmt.Sprintf("  %s %s %s: ", r.PONumber, d.Direction, documentLabel(d)), detail+" at "+d.At.Format(time.RFC3339), "#FFFFFF")
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// cmd/avcimporter/summary.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/money"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/xlsx"
+)
+
+/*
+summaryCounts describes a written summary workbook.
+
+Fields:
+  - Path:       The workbook file.
+  - Date:       Summarized day (YYYY-MM-DD in the configured time zone).
+  - Orders:     Rows on the Orders sheet.
+  - Lines:      Rows on the Line items sheet.
+  - Exceptions: Rows on the Exceptions sheet.
+*/
+type summaryCounts struct {
+	Path       string `json:"path"`
+	Date       string `json:"date"`
+	Orders     int    `json:"orders"`
+	Lines      int    `json:"lines"`
+	Exceptions int    `json:"exceptions"`
+}
+
+/*
+cmdSummary implements `avcimporter summary [--date <day>]`: it writes the
+order summary workbook of a day on demand, replacing one written earlier, and
+passes it to the hooks like the summary flow does.
+
+Flags:
+  - --date: Day to summarize, YYYY-MM-DD in the configured time zone (default: today).
+*/
+func cmdSummary(args []string) int {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	dateFlag := fs.String("date", "", "Day to summarize (YYYY-MM-DD, default: today)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter summary [--date YYYY-MM-DD]")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		logger.Log(utils.LevelError, "Invalid timezone: ", err.Error())
+		return 1
+	}
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if *dateFlag != "" {
+		if day, err = time.ParseInLocation("2006-01-02", *dateFlag, loc); err != nil {
+			logger.Log(utils.LevelError, "Invalid --date: ", fmt.Sprintf("expected YYYY-MM-DD, got %q", *dateFlag))
+			return 2
+		}
+	}
+	if err := installHooks(cfg); err != nil {
+		logger.Log(utils.LevelError, "Invalid export: ", err.Error())
+		return 1
+	}
+
+	counts, err := writeSummary(context.Background(), cfg, day)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to write summary: ", err.Error())
+		return 1
+	}
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "summary", "success": true, "summary": counts})
+	}
+	return 0
+}
+
+/*
+runSummaryFlow writes the summary workbook of the previous day (in the
+configured time zone) once that day is over. A day whose workbook is already
+stored is skipped, so the hourly job produces one workbook, and one hook
+notification, per day.
+*/
+func runSummaryFlow(ctx context.Context, cfg *config.Config) error {
+	loc, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc)
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return err
+	}
+	name, err := namer.Name(storage.TypeSummary, storage.NameData{Time: day})
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Storage.SavePath, name)); err == nil {
+		logger.PrintColoredContext(ctx, "Summary already written: ", name, "#FFFFFF")
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	_, err = writeSummary(ctx, cfg, day)
+	return err
+}
+
+/*
+writeSummary builds the workbook of the day starting at day, stores it and
+runs the hooks subscribed to "generated" for it with type "summary", so a
+notification hook can attach it to an email.
+*/
+func writeSummary(ctx context.Context, cfg *config.Config, day time.Time) (summaryCounts, error) {
+	namer, err := newNamer(cfg)
+	if err != nil {
+		return summaryCounts{}, err
+	}
+	book, counts, err := buildSummary(cfg, day)
+	if err != nil {
+		return summaryCounts{}, err
+	}
+	path, err := storage.SaveSummary(savePath(ctx, cfg), namer, storage.NameData{Time: day}, func(w io.Writer) error {
+		return book.Write(w)
+	})
+	if err != nil {
+		return summaryCounts{}, err
+	}
+	counts.Path = path
+	output.FromContext(ctx).AddFiles(path)
+	logger.PrintColoredContext(ctx, "Wrote order summary: ", fmt.Sprintf("%s (%d orders, %d lines, %d exceptions)", path, counts.Orders, counts.Lines, counts.Exceptions), "#32CD32")
+
+	err = runHooks(ctx, hooks.Event{Event: hooks.Generated, Path: path, Type: storage.TypeSummary, Fields: map[string]string{
+		"date":       counts.Date,
+		"orders":     strconv.Itoa(counts.Orders),
+		"exceptions": strconv.Itoa(counts.Exceptions),
+	}})
+	return counts, err
+}
+
+/*
+buildSummary collects the summary of one day into a workbook with three
+sheets: the purchase orders dated that day, their line items, and the
+exceptions of all open orders as of now (rejected documents and SLA alerts).
+Dates are shown in the configured time zone.
+*/
+func buildSummary(cfg *config.Config, day time.Time) (*xlsx.Workbook, summaryCounts, error) {
+	loc := day.Location()
+	end := day.AddDate(0, 0, 1)
+	counts := summaryCounts{Date: day.Format("2006-01-02")}
+
+	orders, err := storage.LoadOrders(cfg.Storage.SavePath)
+	if err != nil {
+		return nil, counts, err
+	}
+	store := lifecycle.Open(cfg.Storage.LifecyclePath, permissions())
+	tracked, err := store.All()
+	if err != nil {
+		return nil, counts, err
+	}
+	stages := make(map[string]string, len(tracked))
+	for _, o := range tracked {
+		stages[o.PONumber] = o.Stage()
+	}
+
+	book := xlsx.New()
+	orderSheet := book.AddSheet("Orders", "PO number", "Order date", "State", "Type", "Stage", "Ship to", "Buying party",
+		"Lines", "Eaches", "Net total", "Currency", "Ship window start", "Ship window end", "Delivery window start", "Delivery window end")
+	lineSheet := book.AddSheet("Line items", "PO number", "Item", "ASIN", "Vendor SKU", "Quantity", "Unit", "Unit size",
+		"Eaches", "Net cost", "Currency", "Line total", "Backorder allowed")
+	exceptionSheet := book.AddSheet("Exceptions", "PO number", "Exception", "Document", "Detail", "Date")
+
+	seen := map[string]bool{}
+	for _, o := range orders {
+		d := o.OrderDetails
+		if seen[o.PurchaseOrderNumber] || d.PurchaseOrderDate.Before(day) || !d.PurchaseOrderDate.Before(end) {
+			continue
+		}
+		// With {{.Hash}} in the order template, older versions are stored too.
+		seen[o.PurchaseOrderNumber] = true
+		counts.Orders++
+
+		eaches, total, currency := 0, 0.0, ""
+		for _, it := range d.Items {
+			n := orderEaches(it.OrderedQuantity)
+			eaches += n
+			var cost, lineTotal *float64
+			code := ""
+			if it.NetCost != nil {
+				if c, err := money.Parse(it.NetCost.Amount, 0); err == nil {
+					t := math.Round(c*float64(it.OrderedQuantity.Amount)*100) / 100
+					cost, lineTotal, code = &c, &t, it.NetCost.CurrencyCode
+					total += t
+					currency = code
+				}
+			}
+			lineSheet.AddRow(o.PurchaseOrderNumber, it.ItemSequenceNumber, it.AmazonProductIdentifier, it.VendorProductIdentifier,
+				it.OrderedQuantity.Amount, it.OrderedQuantity.UnitOfMeasure, it.OrderedQuantity.UnitSize, n, cost, code, lineTotal, it.IsBackOrderAllowed)
+			counts.Lines++
+		}
+
+		windows := map[string]time.Time{}
+		addWindow(windows, "shipWindow", d.ShipWindow)
+		addWindow(windows, "deliveryWindow", d.DeliveryWindow)
+		row := []interface{}{o.PurchaseOrderNumber, d.PurchaseOrderDate.In(loc), o.PurchaseOrderState, d.PurchaseOrderType,
+			stages[o.PurchaseOrderNumber], partyID(d.ShipToParty), partyID(d.BuyingParty), len(d.Items), eaches, math.Round(total*100) / 100, currency}
+		for _, name := range []string{"shipWindowStart", "shipWindowEnd", "deliveryWindowStart", "deliveryWindowEnd"} {
+			if t, ok := windows[name]; ok {
+				row = append(row, t.In(loc))
+			} else {
+				row = append(row, nil)
+			}
+		}
+		orderSheet.AddRow(row...)
+	}
+
+	windows, err := orderDeadlines(cfg)
+	if err != nil {
+		return nil, counts, err
+	}
+	for _, o := range tracked {
+		if !o.Open() {
+			continue
+		}
+		for _, d := range o.Filter(lifecycle.Rejected) {
+			exceptionSheet.AddRow(o.PONumber, "rejected", documentLabel(d), d.Detail, d.At.In(loc))
+			counts.Exceptions++
+		}
+		for _, a := range slaAlerts(cfg, o, windows) {
+			exceptionSheet.AddRow(o.PONumber, a.State, a.Kind, "SLA rule "+a.Rule, a.Due.In(loc))
+			counts.Exceptions++
+		}
+	}
+	return book, counts, nil
+}
+
+// orderEaches converts an ordered quantity to eaches using the case size.
+func orderEaches(q spapi.ItemQuantity) int {
+	if strings.EqualFold(q.UnitOfMeasure, "Cases") && q.UnitSize > 0 {
+		return q.Amount * q.UnitSize
+	}
+	return q.Amount
+}
+
+// partyID returns the ID of p, or "" if there is none.
+func partyID(p *spapi.Party) string {
+	if p == nil {
+		return ""
+	}
+	return p.PartyID
+}
@@ -0,0 +1,558 @@
+// cmd/avcimporter/syncstatus.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/csvinput"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+shipmentRow is one shipped line read from a shipment status file. CSV files
+use the JSON field names as column headers (case-insensitive); line is the
+row's input line and err why it could not be read.
+*/
+type shipmentRow struct {
+	ShipmentID              string `json:"shipmentId"`
+	PurchaseOrderNumber     string `json:"purchaseOrderNumber"`
+	ItemSequenceNumber      string `json:"itemSequenceNumber"`
+	AmazonProductIdentifier string `json:"amazonProductIdentifier,omitempty"`
+	VendorProductIdentifier string `json:"vendorProductIdentifier,omitempty"`
+	ShippedQuantity         int    `json:"shippedQuantity"`
+	UnitOfMeasure           string `json:"unitOfMeasure,omitempty"`
+	ShippedDate             string `json:"shippedDate,omitempty"`
+	ShipFromPartyID         string `json:"shipFromPartyId,omitempty"`
+	CarrierScac             string `json:"carrierScac,omitempty"`
+	TrackingNumber          string `json:"trackingNumber,omitempty"`
+
+	line int
+	err  error
+}
+
+// shipmentColumns are the columns of a shipment status CSV file.
+var shipmentColumns = []csvinput.Column{
+	{Name: "shipmentId", Required: true},
+	{Name: "purchaseOrderNumber", Required: true},
+	{Name: "itemSequenceNumber", Required: true},
+	{Name: "amazonProductIdentifier"},
+	{Name: "vendorProductIdentifier"},
+	{Name: "shippedQuantity", Required: true},
+	{Name: "unitOfMeasure"},
+	{Name: "shippedDate"},
+	{Name: "shipFromPartyId"},
+	{Name: "carrierScac"},
+	{Name: "trackingNumber"},
+}
+
+/*
+lineResult is the outcome of one input line, emitted by sync-status.
+
+Fields:
+  - Line:   CSV line number or JSON record number (1-based).
+  - Status: "valid" (dry run), "invalid", "accepted", "rejected", "submitted" or "queued" (webhook).
+  - Detail: Validation error, SP‑API errors or transaction ID.
+*/
+type lineResult struct {
+	Line                int    `json:"line"`
+	ShipmentID          string `json:"shipmentId"`
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+	ItemSequenceNumber  string `json:"itemSequenceNumber"`
+	Status              string `json:"status"`
+	Detail              string `json:"detail,omitempty"`
+}
+
+/*
+cmdSyncStatus implements `avcimporter sync-status <file>`: it reads shipped
+lines from a CSV or JSON file, groups them by shipment and submits one
+shipment confirmation per shipment through the Vendor Shipments API. Parties
+and missing product identifiers come from the stored purchase orders, so
+only imported orders can be confirmed. Every input line gets a result.
+
+This is the API-program counterpart of an EDI 856 ASN: `edi build
+--shipments` renders an 856 template from the same file, through the same
+buildConfirmations mapping (see loadShipment).
+
+CSV files are read strictly: an unknown, duplicate or missing column rejects
+the file, and a malformed row (wrong number of fields, non-numeric quantity)
+is reported as an invalid line without stopping the others.
+
+With catalog.casePacks set, the quantity shipped of each order line in a
+shipment, added up over its input lines, must be a whole number of cases of
+the product. The lines of an order line that is not are reported as invalid
+with the nearest valid quantities and left out of the confirmation.
+
+A line without shipFromPartyId ships from the warehouse the routing rules
+assign it (the order's selling party without routing); all lines of a
+shipment must ship from the same warehouse. A PO split over warehouses is
+confirmed with one shipment ID per warehouse, as written by `route --out`.
+
+With printing.printers set, the shipment label and packing slip of every
+submitted shipment that was not rejected are sent to the printers of its
+warehouse. A document that fails to print is warned about and does not fail
+the command.
+
+Flags:
+  - --format:  csv or json (default: from the file extension).
+  - --type:    Confirmation type, Original or Replace (default: Original).
+  - --dry-run: Validate the file without submitting.
+  - --wait:    Wait for Amazon to process each submission (default: true).
+  - --timeout: How long to wait per submission (default: 5m).
+  - --print:   Print labels and packing slips of submitted shipments (default: true).
+*/
+func cmdSyncStatus(args []string) int {
+	fs := flag.NewFlagSet("sync-status", flag.ContinueOnError)
+	format := fs.String("format", "", "Input format: csv or json (default: from the file extension)")
+	confirmType := fs.String("type", "Original", "Confirmation type: Original or Replace")
+	dryRun := fs.Bool("dry-run", false, "Validate the file without submitting")
+	wait := fs.Bool("wait", true, "Wait for each submission to be processed")
+	timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait per submission")
+	printDocs := fs.Bool("print", true, "Print labels and packing slips of submitted shipments")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter sync-status [--format csv|json] [--dry-run] <file|->")
+		return 2
+	}
+	if *confirmType != "Original" && *confirmType != "Replace" {
+		logger.Log(utils.LevelError, "Invalid --type: ", *confirmType)
+		return 2
+	}
+
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = shipmentFileFormat(name)
+	}
+	rows, err := readShipmentRows(name, *format)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read shipment status: ", err.Error())
+		return 1
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+
+	packs, err := loadCasePacks(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load case packs: ", err.Error())
+		return 1
+	}
+
+	results := make([]lineResult, len(rows))
+	shipments := dropCaseViolations(packs, buildConfirmations(cfg, rows, *confirmType, results), results)
+	var printed []printedDocument
+	if !*dryRun && len(shipments) > 0 {
+		closeAudit, err := openAudit(cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to open audit log: ", err.Error())
+			return 1
+		}
+		defer closeAudit()
+		ctx := context.Background()
+		token, err := fetchOAuthToken(ctx, cfg)
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to fetch OAuth2 token: ", err.Error())
+			return 1
+		}
+		client := newSPAPIClient(cfg, token)
+		for _, s := range shipments {
+			status, detail := submitShipment(ctx, client, s.confirmation, *wait, *timeout)
+			for _, i := range s.lines {
+				results[i].Status, results[i].Detail = status, detail
+			}
+			trackShipment(cfg, s.confirmation, status, detail)
+			if *printDocs && status != "rejected" {
+				printed = append(printed, printShipment(ctx, cfg, s)...)
+			}
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == "invalid" || r.Status == "rejected" {
+			failed++
+		}
+	}
+	if output.IsJSON() {
+		if printed == nil {
+			printed = []printedDocument{}
+		}
+		output.Emit(map[string]interface{}{"command": "sync-status", "success": failed == 0, "lines": results, "printed": printed})
+	} else {
+		printLineResults(results, failed)
+		for _, d := range printed {
+			if d.Error != "" {
+				logger.Log(utils.LevelWarn, "Failed to print ", d.String())
+			} else {
+				logger.PrintColored("Printed ", d.String(), "#32CD32")
+			}
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+/*
+pendingShipment is a shipment confirmation with the input lines it covers.
+*/
+type pendingShipment struct {
+	confirmation spapi.ShipmentConfirmation
+	lines        []int
+}
+
+/*
+buildConfirmations validates rows against the stored purchase orders and
+groups the valid ones into one confirmation per shipment ID, in input order.
+It fills results for every row, marking valid rows "valid".
+*/
+func buildConfirmations(cfg *config.Config, rows []shipmentRow, confirmType string, results []lineResult) []*pendingShipment {
+	orders := map[string]*spapi.PurchaseOrder{}
+	byID := map[string]*pendingShipment{}
+	var shipments []*pendingShipment
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, row := range rows {
+		results[i] = lineResult{Line: row.line, ShipmentID: row.ShipmentID, PurchaseOrderNumber: row.PurchaseOrderNumber, ItemSequenceNumber: row.ItemSequenceNumber}
+		invalid := func(format string, a ...interface{}) {
+			results[i].Status, results[i].Detail = "invalid", fmt.Sprintf(format, a...)
+		}
+		if row.err != nil {
+			invalid("%v", row.err)
+			continue
+		}
+		if row.ShipmentID == "" || row.PurchaseOrderNumber == "" || row.ItemSequenceNumber == "" {
+			invalid("shipmentId, purchaseOrderNumber and itemSequenceNumber are required")
+			continue
+		}
+		if row.ShippedQuantity <= 0 {
+			invalid("shippedQuantity must be positive")
+			continue
+		}
+		order, ok := orders[row.PurchaseOrderNumber]
+		if !ok {
+			if o, err := storage.LoadOrder(cfg.Storage.SavePath, row.PurchaseOrderNumber); err == nil {
+				order = &o
+			}
+			orders[row.PurchaseOrderNumber] = order
+		}
+		if order == nil {
+			invalid("purchase order not found in storage")
+			continue
+		}
+		var ordered *spapi.OrderItem
+		for j := range order.OrderDetails.Items {
+			if order.OrderDetails.Items[j].ItemSequenceNumber == row.ItemSequenceNumber {
+				ordered = &order.OrderDetails.Items[j]
+				break
+			}
+		}
+		if ordered == nil {
+			invalid("purchase order has no item %s", row.ItemSequenceNumber)
+			continue
+		}
+		d := order.OrderDetails
+		if d.SellingParty == nil || d.ShipToParty == nil {
+			invalid("purchase order has no selling or ship-to party")
+			continue
+		}
+		var shipped *time.Time
+		if row.ShippedDate != "" {
+			t, err := parseDateFlag(row.ShippedDate, false)
+			if err != nil {
+				invalid("invalid shippedDate: %v", err)
+				continue
+			}
+			shipped = &t
+		}
+
+		shipFrom := row.ShipFromPartyID
+		if shipFrom == "" {
+			shipFrom = orderRouter(cfg, *order).Route(d.ShipToParty.PartyID, ordered.VendorProductIdentifier, ordered.AmazonProductIdentifier)
+		}
+		s := byID[row.ShipmentID]
+		if s == nil {
+			s = &pendingShipment{confirmation: spapi.ShipmentConfirmation{
+				ShipmentIdentifier:       row.ShipmentID,
+				ShipmentConfirmationType: confirmType,
+				ShipmentConfirmationDate: now,
+				ShippedDate:              shipped,
+				SellingParty:             spapi.Party{PartyID: d.SellingParty.PartyID},
+				ShipFromParty:            spapi.Party{PartyID: shipFrom},
+				ShipToParty:              spapi.Party{PartyID: d.ShipToParty.PartyID},
+			}}
+			if row.CarrierScac != "" || row.TrackingNumber != "" {
+				s.confirmation.TransportationDetails = &spapi.TransportationDetails{CarrierScac: row.CarrierScac, CarrierShipmentReferenceNumber: row.TrackingNumber}
+			}
+			byID[row.ShipmentID] = s
+			shipments = append(shipments, s)
+		} else if s.confirmation.ShipToParty.PartyID != d.ShipToParty.PartyID || s.confirmation.SellingParty.PartyID != d.SellingParty.PartyID {
+			invalid("purchase order parties differ from the rest of shipment %s", row.ShipmentID)
+			continue
+		} else if s.confirmation.ShipFromParty.PartyID != shipFrom {
+			invalid("ships from %s, the rest of shipment %s from %s", shipFrom, row.ShipmentID, s.confirmation.ShipFromParty.PartyID)
+			continue
+		}
+
+		item := spapi.ShippedItem{
+			ItemSequenceNumber:      row.ItemSequenceNumber,
+			AmazonProductIdentifier: row.AmazonProductIdentifier,
+			VendorProductIdentifier: row.VendorProductIdentifier,
+			ShippedQuantity:         spapi.ItemQuantity{Amount: row.ShippedQuantity, UnitOfMeasure: row.UnitOfMeasure, UnitSize: ordered.OrderedQuantity.UnitSize},
+			ItemDetails:             &spapi.ShippedItemDetails{PurchaseOrderNumber: row.PurchaseOrderNumber},
+		}
+		if item.AmazonProductIdentifier == "" {
+			item.AmazonProductIdentifier = ordered.AmazonProductIdentifier
+		}
+		if item.VendorProductIdentifier == "" {
+			item.VendorProductIdentifier = ordered.VendorProductIdentifier
+		}
+		if item.ShippedQuantity.UnitOfMeasure == "" {
+			item.ShippedQuantity.UnitOfMeasure = ordered.OrderedQuantity.UnitOfMeasure
+		}
+		s.confirmation.ShippedItems = append(s.confirmation.ShippedItems, item)
+		s.lines = append(s.lines, i)
+		results[i].Status = "valid"
+	}
+	return shipments
+}
+
+/*
+loadShipment reads the shipment status file name as sync-status does and
+returns the confirmation of shipment id, for a ship notice built from it.
+
+Returns:
+  - An error if the file cannot be read, has no shipment id, or one of the
+    shipment's lines is invalid.
+*/
+func loadShipment(cfg *config.Config, name, id string) (spapi.ShipmentConfirmation, error) {
+	rows, err := readShipmentRows(name, shipmentFileFormat(name))
+	if err != nil {
+		return spapi.ShipmentConfirmation{}, err
+	}
+	packs, err := loadCasePacks(cfg)
+	if err != nil {
+		return spapi.ShipmentConfirmation{}, err
+	}
+	results := make([]lineResult, len(rows))
+	shipments := dropCaseViolations(packs, buildConfirmations(cfg, rows, "Original", results), results)
+	for _, r := range results {
+		if r.ShipmentID == id && r.Status == "invalid" {
+			return spapi.ShipmentConfirmation{}, fmt.Errorf("line %d: %s", r.Line, r.Detail)
+		}
+	}
+	for _, s := range shipments {
+		if s.confirmation.ShipmentIdentifier == id {
+			return s.confirmation, nil
+		}
+	}
+	return spapi.ShipmentConfirmation{}, fmt.Errorf("no shipment %s in %s", id, name)
+}
+
+// shipmentFileFormat returns the format of a shipment status file by its
+// extension: json for .json, otherwise csv.
+func shipmentFileFormat(name string) string {
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		return "json"
+	}
+	return "csv"
+}
+
+/*
+submitShipment submits one confirmation and, with wait, polls its transaction.
+
+Returns:
+  - The line status: "accepted", "rejected" or "submitted" (not waited for).
+  - The transaction ID or the errors reported by SP‑API.
+*/
+func submitShipment(ctx context.Context, client *spapi.Client, confirmation spapi.ShipmentConfirmation, wait bool, timeout time.Duration) (string, string) {
+	id, err := client.SubmitShipmentConfirmations(ctx, []spapi.ShipmentConfirmation{confirmation})
+	if err != nil {
+		return "rejected", err.Error()
+	}
+	return shipmentTransaction(ctx, client, id, wait, timeout)
+}
+
+/*
+shipmentTransaction reports on the submitted confirmation of transaction id,
+with wait after polling it like submitShipment.
+*/
+func shipmentTransaction(ctx context.Context, client *spapi.Client, id string, wait bool, timeout time.Duration) (string, string) {
+	if !wait {
+		return "submitted", "transaction " + id
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	status, err := client.WaitForTransaction(waitCtx, id, 5*time.Second)
+	if err != nil {
+		return "submitted", fmt.Sprintf("transaction %s: %v", id, err)
+	}
+	if status.Status == spapi.TransactionSuccess {
+		return "accepted", "transaction " + id
+	}
+	msgs := make([]string, len(status.Errors))
+	for i, e := range status.Errors {
+		msgs[i] = e.Error()
+	}
+	return "rejected", fmt.Sprintf("transaction %s: %s", id, strings.Join(msgs, "; "))
+}
+
+/*
+trackShipment records a submitted confirmation on the lifecycle of every
+purchase order it ships against, with the units shipped per line and the
+warehouse shipping them.
+*/
+func trackShipment(cfg *config.Config, confirmation spapi.ShipmentConfirmation, status, detail string) {
+	d := lifecycle.Document{Kind: lifecycle.ShipNotice, Direction: "outbound", Reference: confirmation.ShipmentIdentifier, Status: lifecycle.Sent, Detail: detail, Warehouse: confirmation.ShipFromParty.PartyID}
+	switch status {
+	case "accepted":
+		d.Status = lifecycle.Accepted
+	case "rejected":
+		d.Status = lifecycle.Rejected
+	}
+	trackShipmentDocument(cfg, confirmation, d)
+}
+
+/*
+trackShipmentDocument records d on the lifecycle of every purchase order the
+shipment ships against, with the units shipped of its lines.
+*/
+func trackShipmentDocument(cfg *config.Config, confirmation spapi.ShipmentConfirmation, d lifecycle.Document) {
+	var pos []string
+	lines := map[string][]lifecycle.Line{}
+	for _, item := range confirmation.ShippedItems {
+		po := item.ItemDetails.PurchaseOrderNumber
+		if _, ok := lines[po]; !ok {
+			pos = append(pos, po)
+		}
+		lines[po] = append(lines[po], lifecycle.Line{Item: item.ItemSequenceNumber, ASIN: item.AmazonProductIdentifier, Units: orderEaches(item.ShippedQuantity)})
+	}
+	for _, po := range pos {
+		d.Lines = lines[po]
+		trackDocument(cfg, po, d)
+	}
+}
+
+/*
+readShipmentRows reads shipment lines from name ("-" for stdin) in the given
+format (csv or json).
+
+Returns:
+  - The rows in input order, numbered by CSV line or JSON record.
+  - An error if the file cannot be read, is not valid JSON or has an invalid CSV header.
+*/
+func readShipmentRows(name, format string) ([]shipmentRow, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return decodeShipmentRows(r, format)
+}
+
+// decodeShipmentRows reads shipment lines in the given format from r.
+func decodeShipmentRows(r io.Reader, format string) ([]shipmentRow, error) {
+	switch format {
+	case "json":
+		var rows []shipmentRow
+		if err := json.NewDecoder(r).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		for i := range rows {
+			rows[i].line = i + 1
+		}
+		return rows, nil
+	case "csv":
+		return readShipmentCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want csv or json)", format)
+	}
+}
+
+/*
+readShipmentCSV reads shipment lines from a CSV file with a header row. A row
+that cannot be read keeps its line number and the reason in err.
+*/
+func readShipmentCSV(r io.Reader) ([]shipmentRow, error) {
+	records, err := csvinput.ReadAll(r, shipmentColumns)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]shipmentRow, len(records))
+	for i, rec := range records {
+		rows[i] = shipmentRow{
+			ShipmentID:              rec.Get("shipmentId"),
+			PurchaseOrderNumber:     rec.Get("purchaseOrderNumber"),
+			ItemSequenceNumber:      rec.Get("itemSequenceNumber"),
+			AmazonProductIdentifier: rec.Get("amazonProductIdentifier"),
+			VendorProductIdentifier: rec.Get("vendorProductIdentifier"),
+			UnitOfMeasure:           rec.Get("unitOfMeasure"),
+			ShippedDate:             rec.Get("shippedDate"),
+			ShipFromPartyID:         rec.Get("shipFromPartyId"),
+			CarrierScac:             rec.Get("carrierScac"),
+			TrackingNumber:          rec.Get("trackingNumber"),
+			line:                    rec.Line,
+			err:                     rec.Err,
+		}
+		if rows[i].err == nil {
+			rows[i].ShippedQuantity, rows[i].err = rec.Int("shippedQuantity")
+		}
+	}
+	return rows, nil
+}
+
+// printLineResults renders sync-status results for humans.
+func printLineResults(results []lineResult, failed int) {
+	if len(results) == 0 {
+		logger.PrintColored("No shipment lines found.", "", "#FFFF00")
+		return
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tSHIPMENT\tPO NUMBER\tITEM\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", r.Line, r.ShipmentID, r.PurchaseOrderNumber, r.ItemSequenceNumber, r.Status, r.Detail)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		switch results[i].Status {
+		case "invalid", "rejected":
+			color = "#FF0000"
+		case "accepted":
+			color = "#32CD32"
+		}
+		logger.PrintColored(line, "", color)
+	}
+	if failed > 0 {
+		logger.Log(utils.LevelError, "Failed lines: ", fmt.Sprintf("%d of %d", failed, len(results)))
+		return
+	}
+	logger.PrintColored("Lines: ", fmt.Sprint(len(results)), "#32CD32")
+}
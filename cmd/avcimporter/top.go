@@ -0,0 +1,392 @@
+// cmd/avcimporter/top.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/health"
+	"github.com/heinrichb/avcimporter/pkg/history"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// topTimeout bounds each poll of the daemon's /status endpoint.
+const topTimeout = 5 * time.Second
+
+/*
+topSnapshot is what one refresh of `avcimporter top` shows.
+
+Fields:
+  - Source:      Where flows, transfers and checkpoints were read: the daemon, or local files.
+  - Live:        Whether the daemon was polled.
+  - Error:       Why the daemon or a local file could not be read, if so.
+  - Flows:       State of every scheduled flow (daemon only).
+  - Transfers:   File transfers in progress (daemon only).
+  - Checkpoints: Import positions.
+  - Runs:        Most recent runs from the run history, newest first.
+*/
+type topSnapshot struct {
+	At          time.Time
+	Source      string
+	Live        bool
+	Error       string
+	Flows       map[string]health.FlowStatus
+	Transfers   []utils.Transfer
+	Checkpoints map[string]checkpoint.Checkpoint
+	Runs        []history.Entry
+}
+
+/*
+topSource polls the daemon's /status endpoint.
+
+Fields:
+  - name:   Shown in the header, e.g. "http://127.0.0.1:8080" or "unix:/run/avcimporter.sock".
+  - url:    The /status URL.
+  - key:    API key sent as X-API-Key, if any.
+  - client: HTTP client, dialling the Unix socket when polling server.socket.
+*/
+type topSource struct {
+	name   string
+	url    string
+	key    string
+	client *http.Client
+}
+
+/*
+cmdTop implements `avcimporter top`: a live terminal dashboard of the daemon,
+redrawn every --interval, showing each flow's state, the file transfers in
+progress, the most recent runs and the checkpoint positions. The daemon is
+polled on server.socket when it exists, else on server.addr (with an API key
+of read role when server.auth requires one); without either only the run
+history and checkpoints on disk are shown. Press q to quit and r to refresh.
+When stdout is not a terminal, or with --once, one snapshot is printed.
+
+Flags:
+  - --url:      Daemon base URL, overriding server.socket and server.addr.
+  - --key:      API key for the daemon, sent as X-API-Key.
+  - --interval: Time between refreshes (default: 2s).
+  - --runs:     Recent runs to show (default: 10).
+  - --once:     Print one snapshot and exit.
+*/
+func cmdTop(args []string) int {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	url := fs.String("url", "", "Daemon base URL (e.g. http://host:8080), overriding server.socket and server.addr")
+	key := fs.String("key", "", "API key for the daemon, when server.auth requires one")
+	interval := fs.Duration("interval", 2*time.Second, "Time between refreshes")
+	runs := fs.Int("runs", 10, "Recent runs to show")
+	once := fs.Bool("once", false, "Print one snapshot and exit")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *interval <= 0 {
+		logger.Log(utils.LevelError, "Invalid --interval: ", "must be positive")
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	src := newTopSource(cfg, *url, *key)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	poll := func() topSnapshot { return pollTop(ctx, cfg, src, *runs) }
+
+	fd := int(os.Stdin.Fd())
+	if *once || !utils.IsTerminal(os.Stdout) || !term.IsTerminal(fd) {
+		fmt.Print(strings.Join(renderTop(poll(), 0, 0), "\n") + "\n")
+		return 0
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to set up the terminal: ", err.Error())
+		return 1
+	}
+	// Alternate screen with a hidden cursor, restored however we leave.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, state)
+	}()
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	snap := poll()
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 0, 0
+		}
+		// Raw mode does not translate newlines, so every line returns the carriage.
+		fmt.Print("\x1b[H\x1b[2J" + strings.Join(renderTop(snap, width, height), "\r\n"))
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+			snap = poll()
+		case k, ok := <-keys:
+			switch {
+			case !ok || k == 'q' || k == 'Q' || k == 3: // 3 is Ctrl-C, not signalled in raw mode
+				return 0
+			case k == 'r' || k == 'R':
+				snap = poll()
+			}
+		}
+	}
+}
+
+/*
+newTopSource picks the daemon endpoint to poll: url when given, else
+server.socket when the socket exists, else server.addr (a host-less address
+such as ":8080" is polled on the loopback interface). It returns nil when
+there is none.
+*/
+func newTopSource(cfg *config.Config, url, key string) *topSource {
+	if url != "" {
+		url = strings.TrimSuffix(url, "/")
+		return &topSource{name: url, url: url + "/status", key: key, client: &http.Client{Timeout: topTimeout}}
+	}
+	if path := cfg.Server.Socket; path != "" {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			transport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			}}
+			return &topSource{name: "unix:" + path, url: "http://avcimporter/status", client: &http.Client{Timeout: topTimeout, Transport: transport}}
+		}
+	}
+	if addr := cfg.Server.Addr; addr != "" {
+		if host, port, err := net.SplitHostPort(addr); err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+			addr = net.JoinHostPort("127.0.0.1", port)
+		}
+		return &topSource{name: "http://" + addr, url: "http://" + addr + "/status", key: key, client: &http.Client{Timeout: topTimeout}}
+	}
+	return nil
+}
+
+/*
+pollTop gathers one snapshot: flows, transfers and checkpoints from the
+daemon (checkpoints from storage.checkpointPath when it cannot be polled) and
+the most recent runs from storage.historyPath.
+*/
+func pollTop(ctx context.Context, cfg *config.Config, src *topSource, runs int) topSnapshot {
+	snap := topSnapshot{At: time.Now(), Source: "local files"}
+	if src != nil {
+		snap.Source = "daemon " + src.name
+		if err := src.poll(ctx, &snap); err != nil {
+			snap.Error = err.Error()
+		} else {
+			snap.Live = true
+		}
+	}
+	if !snap.Live {
+		all, err := checkpoint.Open(cfg.Storage.CheckpointPath, permissions()).All()
+		if err != nil && snap.Error == "" {
+			snap.Error = err.Error()
+		}
+		snap.Checkpoints = all
+	}
+	list, err := history.Open(cfg.Storage.HistoryPath, 0, permissions()).List(history.Filter{Limit: runs})
+	if err != nil && snap.Error == "" {
+		snap.Error = err.Error()
+	}
+	snap.Runs = list
+	return snap
+}
+
+// poll reads the daemon's /status document into snap.
+func (s *topSource) poll(ctx context.Context, snap *topSnapshot) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	if s.key != "" {
+		req.Header.Set("X-API-Key", s.key)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", s.url, resp.Status)
+	}
+	var status struct {
+		Flows           map[string]health.FlowStatus     `json:"flows"`
+		Transfers       []utils.Transfer                 `json:"transfers"`
+		Checkpoints     map[string]checkpoint.Checkpoint `json:"checkpoints"`
+		CheckpointError string                           `json:"checkpointError"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("invalid status from %s: %w", s.name, err)
+	}
+	snap.Flows, snap.Transfers, snap.Checkpoints, snap.Error = status.Flows, status.Transfers, status.Checkpoints, status.CheckpointError
+	return nil
+}
+
+/*
+renderTop lays out snap as colored lines cut to width columns and height
+lines (0 leaves them uncut).
+*/
+func renderTop(snap topSnapshot, width, height int) []string {
+	type line struct{ text, color string }
+	var lines []line
+	add := func(text, color string) { lines = append(lines, line{text, color}) }
+	table := func(header string, rows [][]string, colors []string) {
+		var b strings.Builder
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, header)
+		for _, r := range rows {
+			fmt.Fprintln(tw, strings.Join(r, "\t"))
+		}
+		tw.Flush()
+		for i, text := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+			color := "#00FFFF"
+			if i > 0 {
+				color = colors[i-1]
+			}
+			add("  "+text, color)
+		}
+	}
+	ago := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return time.Since(*t).Round(time.Second).String() + " ago"
+	}
+
+	add(fmt.Sprintf("avcimporter top  %s  %s  (q quit, r refresh)", snap.Source, snap.At.Format("2006-01-02 15:04:05")), "#FFFFFF")
+	if snap.Error != "" {
+		add("Error: "+snap.Error, "#FF0000")
+	}
+
+	add("", "")
+	add("FLOWS", "#FFFFFF")
+	switch {
+	case !snap.Live:
+		add("  (needs the daemon)", "#808080")
+	case len(snap.Flows) == 0:
+		add("  No scheduled flows.", "#808080")
+	default:
+		names := make([]string, 0, len(snap.Flows))
+		for name := range snap.Flows {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var rows [][]string
+		var colors []string
+		for _, name := range names {
+			st := snap.Flows[name]
+			state, color := "ok", "#32CD32"
+			switch {
+			case st.Stale:
+				state, color = "stale", "#FF0000"
+			case st.ConsecutiveFailures > 0:
+				state, color = "failing", "#FFFF00"
+			case st.LastRun == nil:
+				state, color = "waiting", "#FFFFFF"
+			}
+			rows = append(rows, []string{name, state, ago(st.LastRun), ago(st.LastSuccess), fmt.Sprint(st.ConsecutiveFailures), st.LastError})
+			colors = append(colors, color)
+		}
+		table("FLOW\tSTATE\tLAST RUN\tLAST SUCCESS\tFAILURES\tLAST ERROR", rows, colors)
+	}
+
+	add("", "")
+	add("TRANSFERS", "#FFFFFF")
+	switch {
+	case !snap.Live:
+		add("  (needs the daemon)", "#808080")
+	case len(snap.Transfers) == 0:
+		add("  No transfer in progress.", "#808080")
+	default:
+		for _, t := range snap.Transfers {
+			label := ""
+			if t.Label != "" {
+				label = "[" + t.Label + "] "
+			}
+			add("  "+label+t.String(), "#00FFFF")
+		}
+	}
+
+	add("", "")
+	add("RECENT RUNS", "#FFFFFF")
+	if len(snap.Runs) == 0 {
+		add("  No runs recorded.", "#808080")
+	} else {
+		var rows [][]string
+		var colors []string
+		for _, e := range snap.Runs {
+			result, color := "ok", "#32CD32"
+			if !e.Success {
+				result, color = "failed", "#FF0000"
+			}
+			rows = append(rows, []string{e.StartedAt.Local().Format("01-02 15:04:05"), e.Flow, result,
+				e.FinishedAt.Sub(e.StartedAt).Round(time.Second).String(), fmt.Sprint(e.Files), fmt.Sprint(e.POs), e.Error})
+			colors = append(colors, color)
+		}
+		table("STARTED\tFLOW\tRESULT\tDURATION\tFILES\tPOS\tERROR", rows, colors)
+	}
+
+	add("", "")
+	add("CHECKPOINTS", "#FFFFFF")
+	if len(snap.Checkpoints) == 0 {
+		add("  No checkpoints stored.", "#808080")
+	} else {
+		keys := make([]string, 0, len(snap.Checkpoints))
+		for k := range snap.Checkpoints {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var rows [][]string
+		var colors []string
+		for _, k := range keys {
+			cp := snap.Checkpoints[k]
+			rows = append(rows, []string{k, cp.Value, ago(&cp.UpdatedAt)})
+			colors = append(colors, "#FFFFFF")
+		}
+		table("KEY\tPOSITION\tUPDATED", rows, colors)
+	}
+
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		text := l.text
+		if width > 0 && len(text) > width {
+			text = text[:width]
+		}
+		if l.color != "" {
+			text = logger.Colorize(text, l.color)
+		}
+		out[i] = text
+	}
+	return out
+}
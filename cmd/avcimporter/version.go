@@ -0,0 +1,52 @@
+// cmd/avcimporter/version.go
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/heinrichb/avcimporter/pkg/buildinfo"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdVersion implements `avcimporter version`: it reports the build metadata
+and the User-Agent sent to SP‑API. The api.appName, api.appVersion and
+api.userAgent overrides are applied when -config is given.
+*/
+func cmdVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	info := buildinfo.Get()
+	ua := buildinfo.UserAgent("", "")
+	if configPath != "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+			return 1
+		}
+		ua = userAgent(cfg)
+	}
+
+	if output.IsJSON() {
+		output.Emit(map[string]interface{}{"command": "version", "success": true, "build": info, "userAgent": ua})
+		return 0
+	}
+	logger.PrintColored("Version:    ", info.Version, "#FFFFFF")
+	if info.Commit != "" {
+		commit := info.Commit
+		if info.Modified {
+			commit += " (modified)"
+		}
+		logger.PrintColored("Commit:     ", commit, "#FFFFFF")
+	}
+	if info.Date != "" {
+		logger.PrintColored("Built:      ", info.Date, "#FFFFFF")
+	}
+	logger.PrintColored("Go:         ", fmt.Sprintf("%s (%s)", info.GoVersion, info.Platform), "#FFFFFF")
+	logger.PrintColored("User-Agent: ", ua, "#FFFFFF")
+	return 0
+}
@@ -0,0 +1,166 @@
+// cmd/avcimporter/watch.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdWatch implements `avcimporter watch`: it watches edi.localInboundDir, where
+partners deliver inbound files with their own tooling instead of us fetching
+them over SFTP, and runs every file through the EDI pipeline once it has
+stopped changing for edi.stableFor. It runs until interrupted; the daemon does
+the same alongside its scheduled jobs.
+
+Flags:
+  - --dir: Watch this directory instead of edi.localInboundDir.
+*/
+func cmdWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Directory to watch instead of edi.localInboundDir")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	if *dir != "" {
+		cfg.EDI.LocalInboundDir = *dir
+	}
+	if cfg.EDI.LocalInboundDir == "" {
+		logger.Log(utils.LevelError, "Nothing to watch: ", "set edi.localInboundDir or pass --dir")
+		return 2
+	}
+
+	if err := installHooks(cfg); err != nil {
+		logger.Log(utils.LevelError, "Invalid export: ", err.Error())
+		return 1
+	}
+	closeAudit, err := openAudit(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to open audit log: ", err.Error())
+		return 1
+	}
+	defer closeAudit()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	checkpoints := checkpoint.Open(cfg.Storage.CheckpointPath, permissions())
+	err = watchLocalInbound(ctx, cfg, func(ctx context.Context, file string) error {
+		return importLocalFile(ctx, cfg, checkpoints, file)
+	})
+	if err != nil {
+		logger.Log(utils.LevelError, "Inbound watcher failed: ", err.Error())
+		return 1
+	}
+	logger.PrintColored("Inbound watcher stopped.", "", "#32CD32")
+	return 0
+}
+
+/*
+watchLocalInbound hands every stable file in edi.localInboundDir to handle,
+logging failures so one bad file does not stop the watcher. It returns once
+ctx is cancelled.
+*/
+func watchLocalInbound(ctx context.Context, cfg *config.Config, handle func(ctx context.Context, file string) error) error {
+	dir := cfg.EDI.LocalInboundDir
+	if err := permissions().MkdirAll(dir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	logger.PrintColoredContext(ctx, "Watching local inbound directory: ", dir, "#00FFFF")
+	return storage.WatchStable(ctx, dir, time.Duration(cfg.EDI.StableFor), func(file string) {
+		if err := handle(ctx, file); err != nil {
+			logger.LogContext(ctx, utils.LevelError, "Failed to import local inbound file: ", fmt.Sprintf("%s: %v", filepath.Base(file), err))
+		}
+	}, logger)
+}
+
+/*
+importLocalFile moves a locally delivered file into storage.savePath, as the
+SFTP flow does with the files it downloads, and runs it through the same
+steps: the downloaded hooks, decompression and processInterchange (split,
+store, 997 and lifecycle tracking). The file leaves the inbound directory
+before it is parsed, so a file that fails to parse is not picked up again.
+*/
+func importLocalFile(ctx context.Context, cfg *config.Config, checkpoints *checkpoint.Store, file string) error {
+	if err := permissions().MkdirAll(cfg.Storage.SavePath); err != nil {
+		return err
+	}
+	local := filepath.Join(cfg.Storage.SavePath, filepath.Base(file))
+	if err := moveFile(file, local); err != nil {
+		return fmt.Errorf("failed to move into %s: %w", cfg.Storage.SavePath, err)
+	}
+	if err := permissions().Apply(local); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	sha := storage.FileSHA256(data)
+	logger.PrintColoredContext(ctx, "Picked up local inbound file: ", fmt.Sprintf("%s -> %s", file, local), "#00FFFF")
+	result := output.FromContext(ctx)
+	result.AddFiles(local)
+	result.AddArtifacts(output.Artifact{Path: local, RemotePath: file, Size: int64(len(data)), SHA256: sha, DownloadedAt: time.Now().UTC()})
+
+	if err := runHooks(ctx, hooks.Event{Event: hooks.Downloaded, Path: local, Type: "inbound", RemotePath: file, Size: int64(len(data)), SHA256: sha}); err != nil {
+		return err
+	}
+	files := []string{local}
+	var errs []error
+	if cfg.EDI.Decompress {
+		files, errs = decompressInbound(ctx, cfg, files)
+	}
+	for _, f := range files {
+		if err := processInterchange(ctx, cfg, checkpoints, f); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(f), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// moveFile renames src to dst, copying and removing it when they are on
+// different filesystems (e.g. an NFS inbound directory).
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
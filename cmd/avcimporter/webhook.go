@@ -0,0 +1,331 @@
+// cmd/avcimporter/webhook.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/outbox"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/webhook"
+)
+
+// maxWebhookBody limits the size of a shipment callback.
+const maxWebhookBody = 10 << 20
+
+// webhookSubmitTimeout is how long the worker waits for Amazon to process a
+// confirmation submitted from a callback.
+const webhookSubmitTimeout = 5 * time.Minute
+
+// shipmentExporterName is the exporter the webhook's shipments are recorded
+// for in the shipment outbox.
+const shipmentExporterName = "shipment-confirmations"
+
+// signatureField is the event field recording the signature of the callback
+// a shipment came with in the shipment outbox (see restoreReplays).
+const signatureField = "webhookSignature"
+
+/*
+openShipmentOutbox opens the outbox holding the shipments accepted by the
+webhook, webhook-outbox.json next to storage.outboxPath, with the retention
+and attempts of outbox.
+*/
+func openShipmentOutbox(cfg *config.Config) *outbox.Outbox {
+	path := filepath.Join(filepath.Dir(cfg.Storage.OutboxPath), "webhook-outbox.json")
+	return outbox.Open(path, time.Duration(cfg.Outbox.Retention), cfg.Outbox.MaxAttempts, permissions(), logger)
+}
+
+/*
+shipmentWebhook serves POST /webhooks/shipments: a WMS reports shipped lines
+in the sync-status format (a JSON array or object, or CSV with Content-Type
+text/csv), signed as server.webhook requires. The lines are checked against
+the stored purchase orders and catalog case packs like in sync-status; the
+shipments of the valid ones are written to disk and recorded in the shipment
+outbox before the response, so an accepted callback survives a restart, and
+submitQueuedShipments submits them. The callback's signature is recorded with
+them, so a replay is also rejected after a restart (see restoreReplays). The response lists every line's result.
+?type=Replace submits replacements.
+
+Responses:
+  - 202: Shipments queued; lines that are not valid are "invalid".
+  - 401: Missing or wrong signature or timestamp.
+  - 404: server.webhook.secret is not set.
+  - 409: The signature was already accepted (a replayed callback).
+  - 400/413/422: Unreadable body, too large, or no valid shipment.
+  - 500: The shipments could not be recorded; the WMS should retry.
+  - 503: Too many shipments are waiting; the WMS should retry later.
+*/
+func (a *app) shipmentWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeControl(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "use POST"})
+		return
+	}
+	cfg := a.holder.Get()
+	wh := cfg.Server.Webhook
+	if wh.Secret == "" {
+		writeControl(w, http.StatusNotFound, map[string]interface{}{"error": "webhook disabled"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeControl(w, http.StatusRequestEntityTooLarge, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		writeControl(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	v := webhook.Verifier{Secret: wh.Secret, SignatureHeader: wh.SignatureHeader, TimestampHeader: wh.TimestampHeader, Tolerance: time.Duration(wh.Tolerance)}
+	now := time.Now()
+	if err := v.Verify(r.Header, body, now); err != nil {
+		logger.Log(utils.LevelWarn, "Rejected shipment webhook from "+r.RemoteAddr+": ", err.Error())
+		writeControl(w, http.StatusUnauthorized, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	// Claimed until the shipments are recorded; released if they are not.
+	sig := v.Signature(r.Header)
+	if err := a.replays.Claim(sig, now, 2*v.Tolerance); err != nil {
+		logger.Log(utils.LevelWarn, "Rejected shipment webhook from "+r.RemoteAddr+": ", err.Error())
+		writeControl(w, http.StatusConflict, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			a.replays.Release(sig)
+		}
+	}()
+
+	confirmType := r.URL.Query().Get("type")
+	if confirmType == "" {
+		confirmType = "Original"
+	}
+	if confirmType != "Original" && confirmType != "Replace" {
+		writeControl(w, http.StatusBadRequest, map[string]interface{}{"error": "type must be Original or Replace"})
+		return
+	}
+	format := "json"
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "text/csv" {
+		format = "csv"
+	} else if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		body = append(append([]byte("["), trimmed...), ']')
+	}
+	rows, err := decodeShipmentRows(bytes.NewReader(body), format)
+	if err != nil {
+		writeControl(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	packs, err := loadCasePacks(cfg)
+	if err != nil {
+		writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": "failed to load case packs: " + err.Error()})
+		return
+	}
+
+	results := make([]lineResult, len(rows))
+	shipments := dropCaseViolations(packs, buildConfirmations(cfg, rows, confirmType, results), results)
+	if len(shipments) == 0 {
+		writeControl(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": "no valid shipment", "lines": results})
+		return
+	}
+	if waiting, err := a.waitingShipments(); err != nil {
+		writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return
+	} else if waiting+len(shipments) > wh.QueueSize {
+		writeControl(w, http.StatusServiceUnavailable, map[string]interface{}{"error": "shipment queue is full"})
+		return
+	}
+	queued, err := a.queueShipments(cfg, shipments, sig)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to queue shipments from webhook: ", err.Error())
+		writeControl(w, http.StatusInternalServerError, map[string]interface{}{"error": "failed to record shipments: " + err.Error()})
+		return
+	}
+	accepted = true
+	select {
+	case a.submitNow <- struct{}{}:
+	default:
+	}
+	for _, s := range shipments {
+		for _, l := range s.lines {
+			results[l].Status = "queued"
+		}
+	}
+	logger.PrintColored("Queued shipments from webhook: ", strings.Join(queued, ", "), "#00FFFF")
+	writeControl(w, http.StatusAccepted, map[string]interface{}{"queued": queued, "lines": results})
+}
+
+/*
+queueShipments writes each confirmation to its own file under webhook/ next
+to storage.outboxPath and records all of them in the shipment outbox at once,
+with the signature sig of the callback they came with.
+
+Returns:
+  - The IDs of the queued shipments.
+  - An error if a file or the outbox cannot be written; nothing is queued then.
+*/
+func (a *app) queueShipments(cfg *config.Config, shipments []*pendingShipment, sig string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(cfg.Storage.OutboxPath), "webhook")
+	if err := permissions().MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(shipments))
+	events := make([]hooks.Event, len(shipments))
+	for i, s := range shipments {
+		data, err := json.MarshalIndent(s.confirmation, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("shipment-%d-%d.json", time.Now().UnixNano(), i))
+		if err := permissions().WriteFile(path, data); err != nil {
+			return nil, err
+		}
+		ids[i] = s.confirmation.ShipmentIdentifier
+		events[i] = hooks.Event{Event: "shipment", Flow: "webhook", Path: path, Type: "shipmentConfirmation", Size: int64(len(data)), SHA256: audit.Sum(data),
+			Fields: map[string]string{signatureField: sig}}
+	}
+	if _, err := a.shipments.Enqueue(shipmentExporterName, events...); err != nil {
+		for _, e := range events {
+			os.Remove(e.Path)
+		}
+		return nil, err
+	}
+	return ids, nil
+}
+
+/*
+restoreReplays loads the signatures of the callbacks accepted before a restart
+from the shipment outbox into a.replays, so they cannot be replayed while
+their timestamps are within the tolerance. Signatures are kept as long as
+their shipments (outbox.retention), far longer than the replay window.
+*/
+func (a *app) restoreReplays() error {
+	entries, err := a.shipments.Entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if sig := e.Event.Fields[signatureField]; sig != "" {
+			a.replays.Restore(sig, e.CreatedAt)
+		}
+	}
+	return nil
+}
+
+// waitingShipments counts the shipments in the shipment outbox that are not
+// submitted yet.
+func (a *app) waitingShipments() (int, error) {
+	entries, err := a.shipments.Entries()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if e.Status == outbox.Pending {
+			n++
+		}
+	}
+	return n, nil
+}
+
+/*
+submitQueuedShipments submits the shipments recorded by shipmentWebhook until
+ctx is cancelled, like sync-status: each is tracked on the lifecycle of its
+orders and, unless rejected, its label and packing slip are printed. It
+submits whatever is waiting when it starts (e.g. shipments accepted before a
+restart), when the webhook accepts new ones, and every outbox.sweepInterval
+for submissions that failed and stay pending. A rejected shipment is not
+submitted again.
+*/
+func (a *app) submitQueuedShipments(ctx context.Context) {
+	a.shipments.Wrap(shipmentExporter{a: a})
+	for {
+		if _, err := a.shipments.Sweep(ctx, 0); err != nil && ctx.Err() == nil {
+			logger.Log(utils.LevelError, "Shipment submission failed: ", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			if n, _ := a.waitingShipments(); n > 0 {
+				logger.Log(utils.LevelWarn, "Shipments to submit after restart: ", fmt.Sprint(n))
+			}
+			return
+		case <-a.submitNow:
+		case <-time.After(time.Duration(a.holder.Get().Outbox.SweepInterval)):
+		}
+	}
+}
+
+/*
+shipmentExporter submits the shipment confirmations queued in the shipment
+outbox. It handles no pipeline events; the outbox runs it directly.
+*/
+type shipmentExporter struct {
+	a *app
+}
+
+func (x shipmentExporter) Name() string              { return shipmentExporterName }
+func (x shipmentExporter) Handles(event string) bool { return false }
+func (x shipmentExporter) Blocking() bool            { return true }
+
+/*
+Run submits the confirmation in the file of e and waits for Amazon to process
+it. A rejection is permanent; a failure to reach SP‑API or a throttled or
+failed request leaves the shipment pending for the next attempt. The file is removed once Amazon has it.
+*/
+func (x shipmentExporter) Run(ctx context.Context, e hooks.Event) error {
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return err
+	}
+	var confirmation spapi.ShipmentConfirmation
+	if err := json.Unmarshal(data, &confirmation); err != nil {
+		return utils.Permanent(fmt.Errorf("invalid shipment file %s: %w", e.Path, err))
+	}
+	cfg := x.a.holder.Get()
+	id := confirmation.ShipmentIdentifier
+	token, err := fetchOAuthToken(ctx, cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to submit shipment "+id+": ", err.Error())
+		return err
+	}
+	client := newSPAPIClient(cfg, token)
+	status, detail := "rejected", ""
+	if tx, err := client.SubmitShipmentConfirmations(ctx, []spapi.ShipmentConfirmation{confirmation}); err == nil {
+		status, detail = shipmentTransaction(ctx, client, tx, true, webhookSubmitTimeout)
+	} else if utils.IsRetryable(err) {
+		logger.Log(utils.LevelError, "Failed to submit shipment "+id+": ", err.Error())
+		return err
+	} else {
+		detail = err.Error()
+	}
+	trackShipment(cfg, confirmation, status, detail)
+	if status == "rejected" {
+		logger.Log(utils.LevelError, "Shipment "+id+" rejected: ", detail)
+		return utils.Permanent(fmt.Errorf("shipment %s rejected: %s", id, detail))
+	}
+	logger.PrintColored("Shipment "+id+" "+status+": ", detail, "#32CD32")
+	os.Remove(e.Path)
+	for _, d := range printShipment(ctx, cfg, &pendingShipment{confirmation: confirmation}) {
+		if d.Error != "" {
+			logger.Log(utils.LevelWarn, "Failed to print ", d.String())
+		} else {
+			logger.PrintColored("Printed ", d.String(), "#32CD32")
+		}
+	}
+	return nil
+}
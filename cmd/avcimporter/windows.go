@@ -0,0 +1,205 @@
+// cmd/avcimporter/windows.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/lifecycle"
+	"github.com/heinrichb/avcimporter/pkg/output"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+cmdWindows implements `avcimporter windows`: it lists the ship and delivery
+windows of open purchase orders that close within the coming days, or writes
+them as an iCalendar file that warehouse staff can subscribe to. A ship
+window is left out once a ship notice was sent for the order.
+
+Flags:
+  - --days: How many days ahead to look (default: 14).
+  - --ical: Write the windows as iCalendar to this file ("-" for stdout) instead of listing them.
+*/
+func cmdWindows(args []string) int {
+	fs := flag.NewFlagSet("windows", flag.ContinueOnError)
+	days := fs.Int("days", 14, "How many days ahead to look")
+	ical := fs.String("ical", "", "Write the windows as iCalendar to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *days <= 0 {
+		logger.Log(utils.LevelError, "Usage: ", "avcimporter windows [--days 14] [--ical file.ics|-]")
+		return 2
+	}
+	if *ical == "-" {
+		// The calendar owns stdout; keep messages out of it.
+		logger.SetOutput(os.Stderr)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+		return 1
+	}
+	deadlines, err := orderDeadlines(cfg)
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read order windows: ", err.Error())
+		return 1
+	}
+	tracked, err := lifecycle.Open(cfg.Storage.LifecyclePath, permissions()).All()
+	if err != nil {
+		logger.Log(utils.LevelError, "Failed to read lifecycle: ", err.Error())
+		return 1
+	}
+	for _, o := range tracked {
+		d, ok := deadlines[o.PONumber]
+		switch {
+		case !ok:
+		case !o.Open():
+			delete(deadlines, o.PONumber)
+		case o.Satisfied(lifecycle.ShipNotice):
+			open := map[string]time.Time{}
+			for k, t := range d {
+				if strings.HasPrefix(k, "deliveryWindow") {
+					open[k] = t
+				}
+			}
+			deadlines[o.PONumber] = open
+		}
+	}
+	now := time.Now()
+	events := lifecycle.UpcomingWindows(deadlines, now, now.AddDate(0, 0, *days))
+
+	if *ical != "" {
+		out := os.Stdout
+		if *ical != "-" {
+			f, err := os.Create(*ical)
+			if err != nil {
+				logger.Log(utils.LevelError, "Failed to write calendar: ", err.Error())
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := lifecycle.WriteICal(out, events, now); err != nil {
+			logger.Log(utils.LevelError, "Failed to write calendar: ", err.Error())
+			return 1
+		}
+		if *ical != "-" && !output.IsJSON() {
+			logger.PrintColored("Wrote calendar: ", fmt.Sprintf("%s (%d windows)", *ical, len(events)), "#32CD32")
+		}
+	}
+	if output.IsJSON() {
+		if events == nil {
+			events = []lifecycle.WindowEvent{}
+		}
+		if *ical != "-" {
+			output.Emit(map[string]interface{}{"command": "windows", "success": true, "windows": events})
+		}
+		return 0
+	}
+	if *ical == "" {
+		printWindows(cfg, events, *days)
+	}
+	return 0
+}
+
+// printWindows renders upcoming windows for humans, in the configured time zone.
+func printWindows(cfg *config.Config, events []lifecycle.WindowEvent, days int) {
+	if len(events) == 0 {
+		logger.PrintColored("No order windows in the next days: ", fmt.Sprint(days), "#FFFF00")
+		return
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PO NUMBER\tWINDOW\tSTART\tEND")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.PONumber, e.Window, e.Start.In(loc).Format(time.RFC3339), e.End.In(loc).Format(time.RFC3339))
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	logger.PrintColored(lines[0], "", "#00FFFF")
+	now := time.Now()
+	for i, line := range lines[1:] {
+		color := "#FFFFFF"
+		if !events[i].Start.After(now) {
+			color = "#FFFF00"
+		}
+		logger.PrintColored(line, "", color)
+	}
+}
+
+// leadTimes converts the configured lead time rules.
+func leadTimes(cfg *config.Config) []lifecycle.LeadTime {
+	rules := make([]lifecycle.LeadTime, 0, len(cfg.Windows.LeadTimes))
+	for _, r := range cfg.Windows.LeadTimes {
+		rules = append(rules, lifecycle.LeadTime{Warehouse: r.Warehouse, ShipTo: r.ShipTo, Handling: time.Duration(r.Handling), Transit: time.Duration(r.Transit), ShipDays: r.Weekdays()})
+	}
+	return rules
+}
+
+/*
+ackWindowRisks checks the acknowledgements that accept a quantity against the
+lead time of every warehouse the accepted lines are routed to and the order's
+ship-to party. Orders without a stored SP‑API order and warehouses without a
+matching rule are not checked.
+*/
+func ackWindowRisks(cfg *config.Config, acks []spapi.OrderAcknowledgement) []lifecycle.WindowRisk {
+	rules := leadTimes(cfg)
+	if len(rules) == 0 {
+		return nil
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	var risks []lifecycle.WindowRisk
+	for _, a := range acks {
+		o, err := storage.LoadOrder(cfg.Storage.SavePath, a.PurchaseOrderNumber)
+		if err != nil || o.OrderDetails.ShipToParty == nil {
+			continue
+		}
+		shipTo := o.OrderDetails.ShipToParty.PartyID
+		r := orderRouter(cfg, o)
+		d := map[string]time.Time{}
+		addWindow(d, "shipWindow", o.OrderDetails.ShipWindow)
+		addWindow(d, "deliveryWindow", o.OrderDetails.DeliveryWindow)
+		checked := map[string]bool{}
+		for _, it := range a.Items {
+			if !acceptsQuantity(it) {
+				continue
+			}
+			w := r.Route(shipTo, it.VendorProductIdentifier, it.AmazonProductIdentifier)
+			if checked[w] {
+				continue
+			}
+			checked[w] = true
+			if rule, ok := lifecycle.MatchLeadTime(rules, w, shipTo); ok {
+				risks = append(risks, rule.CheckWindows(a.PurchaseOrderNumber, d, now)...)
+			}
+		}
+	}
+	return risks
+}
+
+// acceptsQuantity reports whether an acknowledgement item accepts any quantity.
+func acceptsQuantity(it spapi.OrderAcknowledgementItem) bool {
+	for _, ia := range it.ItemAcknowledgements {
+		if ia.AcknowledgementCode == spapi.AckAccepted {
+			return true
+		}
+	}
+	return false
+}
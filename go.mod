@@ -5,11 +5,12 @@ go 1.23.0
 toolchain go1.23.9
 
 require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/klauspost/compress v1.18.0
 	github.com/pkg/sftp v1.13.9
 	golang.org/x/crypto v0.38.0
+	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
 )
 
-require (
-	github.com/kr/fs v0.1.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
-)
+require github.com/kr/fs v0.1.0 // indirect
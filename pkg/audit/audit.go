@@ -0,0 +1,286 @@
+// pkg/audit/audit.go
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Actions recorded in the audit trail.
+*/
+const (
+	SFTPDelete        = "sftp.delete"        // inbound file removed from the partner's server
+	SFTPUpload        = "sftp.upload"        // file written to the partner's server
+	EDIAcknowledge    = "edi.acknowledge"    // 997 submitted for an interchange
+	SPAPICall         = "spapi.call"         // mutating SP‑API operation (POST/PUT/PATCH/DELETE)
+	SPAPIUpload       = "spapi.upload"       // document PUT to a presigned destination
+	CheckpointAdvance = "checkpoint.advance" // import position moved
+	CheckpointDelete  = "checkpoint.delete"  // import position reset
+)
+
+/*
+Event is one line of the audit trail.
+
+Fields:
+  - Time:          When the action completed (UTC).
+  - Action:        One of the action constants.
+  - Target:        What was affected: remote path, operation, checkpoint key.
+  - Host:          Host name of the instance that acted.
+  - ControlNumber: Interchange/group control number, where one applies.
+  - SHA256:        Hex SHA‑256 of the content sent or removed.
+  - Bytes:         Size of that content.
+  - Fields:        Action-specific details (e.g. previous and new checkpoint value).
+*/
+type Event struct {
+	Time          time.Time         `json:"time"`
+	Action        string            `json:"action"`
+	Target        string            `json:"target"`
+	Host          string            `json:"host,omitempty"`
+	ControlNumber string            `json:"controlNumber,omitempty"`
+	SHA256        string            `json:"sha256,omitempty"`
+	Bytes         int64             `json:"bytes,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"`
+}
+
+/*
+Log appends events to an NDJSON file. When the file reaches its size limit it
+is renamed with a timestamp suffix (audit-20261015T120000.000000000Z.ndjson)
+and a new file is started; rotated files are never written again. It is safe
+for concurrent use, and a nil *Log discards events.
+*/
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	host     string
+	f        *os.File
+	size     int64
+}
+
+/*
+Open opens (or creates) the audit log at path for appending.
+
+Parameters:
+  - path:     NDJSON file to append to; parent directories are created.
+  - maxSize:  Rotate once the file reaches this many bytes (0 disables rotation).
+  - maxFiles: Rotated files to keep, oldest removed first (0 keeps all).
+
+Returns:
+  - The log, or an error if the file cannot be opened.
+*/
+func Open(path string, maxSize int64, maxFiles int) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	host, _ := os.Hostname()
+	l := &Log{path: path, maxSize: maxSize, maxFiles: maxFiles, host: host}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current file for appending and records its size.
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+/*
+Record appends e as one JSON line and syncs it to disk. Time defaults to now
+and Host to the local host name.
+*/
+func (l *Log) Record(e Event) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	if e.Host == "" {
+		e.Host = l.host
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("audit log %s is closed", l.path)
+	}
+	var rotateErr error
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		// A failed rotation must not lose the event; it is written to whichever file is open.
+		if rotateErr = l.rotate(time.Now().UTC()); l.f == nil {
+			return rotateErr
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// rotate moves the current file aside and starts a new one.
+func (l *Log) rotate(now time.Time) error {
+	ext := filepath.Ext(l.path)
+	base := strings.TrimSuffix(l.path, ext)
+	rotated := base + "-" + now.Format("20060102T150405.000000000Z") + ext
+	if fileExists(rotated) {
+		return fmt.Errorf("failed to rotate audit log: %s already exists", rotated)
+	}
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.f = nil
+	renameErr := os.Rename(l.path, rotated)
+	// Keep appending to the current file if it could not be moved aside.
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", renameErr)
+	}
+	return l.prune(base, ext)
+}
+
+// prune removes the oldest rotated files beyond maxFiles.
+func (l *Log) prune(base, ext string) error {
+	if l.maxFiles <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return err
+	}
+	// Timestamp suffixes sort chronologically.
+	sort.Strings(matches)
+	for len(matches) > l.maxFiles {
+		if err := os.Remove(matches[0]); err != nil {
+			return fmt.Errorf("failed to prune audit log: %w", err)
+		}
+		matches = matches[1:]
+	}
+	return nil
+}
+
+/*
+Close flushes and closes the log.
+*/
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultLog *Log
+)
+
+/*
+SetDefault installs the process-wide log used by Record. It is set in
+`main.go` from the audit config; nil disables auditing.
+*/
+func SetDefault(l *Log) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLog = l
+}
+
+/*
+Record appends e to the default log. A failure to audit is reported to the
+caller, which decides whether the action itself should fail.
+*/
+func Record(e Event) error {
+	defaultMu.RLock()
+	l := defaultLog
+	defaultMu.RUnlock()
+	return l.Record(e)
+}
+
+/*
+Sum returns the hex SHA‑256 of data.
+*/
+func Sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+/*
+Hasher counts and hashes everything written through it, so streamed content
+can be audited without buffering it.
+*/
+type Hasher struct {
+	w io.Writer
+	h hash.Hash
+	n int64
+}
+
+/*
+NewHasher returns a Hasher writing through to w (which may be nil).
+*/
+func NewHasher(w io.Writer) *Hasher {
+	return &Hasher{w: w, h: sha256.New()}
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	if h.w != nil {
+		n, err := h.w.Write(p)
+		h.h.Write(p[:n])
+		h.n += int64(n)
+		return n, err
+	}
+	h.h.Write(p)
+	h.n += int64(len(p))
+	return len(p), nil
+}
+
+// Sum returns the hex SHA‑256 of the bytes written so far.
+func (h *Hasher) Sum() string { return hex.EncodeToString(h.h.Sum(nil)) }
+
+// Bytes returns the number of bytes written so far.
+func (h *Hasher) Bytes() int64 { return h.n }
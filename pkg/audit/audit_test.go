@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecordAppendsAndRotates verifies events are appended as NDJSON and the file rotates by size.
+func TestRecordAppendsAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.ndjson")
+	l, err := Open(path, 300, 1)
+	if err != nil {
+		t.Fatalf("Open() returned %v", err)
+	}
+	defer l.Close()
+
+	base := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		e := Event{Time: base.Add(time.Duration(i) * time.Second), Action: SFTPDelete, Target: "download/po.edi", SHA256: Sum([]byte("ISA")), Bytes: 3}
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record() returned %v", err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.ndjson"))
+	if len(rotated) != 1 {
+		t.Fatalf("expected 1 rotated file after pruning, got %v", rotated)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	lines := 0
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %d is not JSON: %v", lines+1, err)
+		}
+		if e.Action != SFTPDelete || e.Host == "" {
+			t.Errorf("unexpected event %+v", e)
+		}
+		lines++
+	}
+	if lines == 0 || lines >= 6 {
+		t.Errorf("current file has %d lines, want some but not all events", lines)
+	}
+}
+
+// TestNilLogDiscards verifies a nil log and an unset default are no-ops.
+func TestNilLogDiscards(t *testing.T) {
+	var l *Log
+	if err := l.Record(Event{Action: CheckpointAdvance}); err != nil {
+		t.Errorf("nil Log Record() returned %v", err)
+	}
+	if err := Record(Event{Action: CheckpointAdvance}); err != nil {
+		t.Errorf("default Record() returned %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// pkg/auth/auth.go
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+Role is the access level of a caller. Each role includes the ones below it.
+*/
+type Role int
+
+// Roles in increasing order of access.
+const (
+	RoleNone    Role = iota // no access
+	RoleRead                // status, metrics and queries
+	RoleTrigger             // also starts runs
+	RoleAdmin               // also reloads the configuration and resets checkpoints
+)
+
+// roleNames maps the configured role names to roles.
+var roleNames = map[string]Role{"read": RoleRead, "trigger": RoleTrigger, "admin": RoleAdmin}
+
+/*
+ParseRole returns the role named "read", "trigger" or "admin".
+*/
+func ParseRole(name string) (Role, error) {
+	if r, ok := roleNames[name]; ok {
+		return r, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// String returns the configured name of r.
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// Errors returned by Authenticator.Authenticate.
+var (
+	ErrNoCredentials  = errors.New("missing credentials")
+	ErrBadCredentials = errors.New("invalid credentials")
+)
+
+/*
+Principal is an authenticated caller.
+
+Fields:
+  - Name: Name of the API key, or the subject of the bearer token.
+  - Role: What the caller may do.
+*/
+type Principal struct {
+	Name string
+	Role Role
+}
+
+/*
+Key is a static API key.
+
+Fields:
+  - Name: Shown in logs instead of the key.
+  - Key:  The secret sent by the caller.
+  - Role: Role granted to its holder.
+*/
+type Key struct {
+	Name string
+	Key  string
+	Role Role
+}
+
+/*
+Authenticator identifies callers of the HTTP endpoints by an API key, sent as
+"X-API-Key: <key>" or "Authorization: Bearer <key>", or by an OIDC ID or
+access token sent as a bearer token. A nil *Authenticator admits no one.
+
+Fields:
+  - Keys:      Accepted API keys.
+  - OIDC:      Verifier of bearer tokens that are not API keys; nil accepts keys only.
+  - Anonymous: Role of callers sending no credentials, e.g. on a socket only its
+    owner can reach (default: RoleNone, which refuses them).
+*/
+type Authenticator struct {
+	Keys      []Key
+	OIDC      *OIDC
+	Anonymous Role
+}
+
+/*
+Authenticate identifies the caller of r.
+
+Returns:
+  - The caller.
+  - ErrNoCredentials if r carries none, ErrBadCredentials (possibly wrapped)
+    if they are not accepted.
+*/
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if a == nil {
+		return Principal{}, ErrNoCredentials
+	}
+	cred := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	bearer := false
+	if cred == "" {
+		if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			cred, bearer = strings.TrimSpace(h[7:]), true
+		}
+	}
+	if cred == "" {
+		if a.Anonymous > RoleNone {
+			return Principal{Name: "anonymous", Role: a.Anonymous}, nil
+		}
+		return Principal{}, ErrNoCredentials
+	}
+	for _, k := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(cred), []byte(k.Key)) == 1 {
+			return Principal{Name: k.Name, Role: k.Role}, nil
+		}
+	}
+	if bearer && a.OIDC != nil {
+		return a.OIDC.Verify(r.Context(), cred)
+	}
+	return Principal{}, ErrBadCredentials
+}
+
+// principalKey is the context key of the caller stored by Require.
+type principalKey struct{}
+
+/*
+FromContext returns the caller stored by Require in the request context.
+*/
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+/*
+Require wraps next so that it is only served to callers with at least the
+given role: others get 401 (no or invalid credentials, with a
+WWW-Authenticate challenge) or 403 (role too low) as a JSON error. The
+caller is available to next via FromContext.
+*/
+func (a *Authenticator) Require(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="avcimporter"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if p.Role < role {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("%s needs role %s, has %s", p.Name, role, p.Role))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// writeError writes a JSON error response with the given status code.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
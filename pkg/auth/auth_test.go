@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRequire verifies that API keys are accepted in either header and that
+// callers without credentials or with too low a role are refused.
+func TestRequire(t *testing.T) {
+	a := &Authenticator{Keys: []Key{{Name: "grafana", Key: "r-key", Role: RoleRead}, {Name: "ops", Key: "a-key", Role: RoleAdmin}}}
+	var served Principal
+	h := a.Require(RoleTrigger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = FromContext(r.Context())
+	}))
+	for _, tt := range []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"none", "", "", http.StatusUnauthorized},
+		{"unknown", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"read only", "X-API-Key", "r-key", http.StatusForbidden},
+		{"admin", "X-API-Key", "a-key", http.StatusOK},
+		{"admin bearer", "Authorization", "Bearer a-key", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/trigger", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d; expected %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	if served.Name != "ops" || served.Role != RoleAdmin {
+		t.Errorf("FromContext() = %+v; expected ops/admin", served)
+	}
+
+	var none *Authenticator
+	if _, err := none.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("nil Authenticate() = %v; expected ErrNoCredentials", err)
+	}
+	open := &Authenticator{Anonymous: RoleAdmin}
+	if p, err := open.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil || p.Role != RoleAdmin {
+		t.Errorf("anonymous Authenticate() = %+v, %v; expected admin", p, err)
+	}
+}
+
+// TestOIDC verifies RS256 tokens against keys from the discovery document,
+// the role mapping, and the rejection of wrong issuers, audiences and
+// expired or tampered tokens.
+func TestOIDC(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": srv.URL + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "k1", "kty": "RSA", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	sign := func(claims map[string]interface{}) string {
+		h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		c, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	o := &OIDC{Issuer: srv.URL, Audience: "avcimporter", Roles: map[string]Role{"ops": RoleTrigger, "platform": RoleAdmin}, DefaultRole: RoleRead}
+
+	p, err := o.Verify(context.Background(), sign(map[string]interface{}{"iss": srv.URL, "aud": []string{"avcimporter"}, "sub": "alice", "exp": exp, "roles": []string{"ops", "platform"}}))
+	if err != nil || p.Name != "alice" || p.Role != RoleAdmin {
+		t.Errorf("Verify() = %+v, %v; expected alice/admin", p, err)
+	}
+	if p, err := o.Verify(context.Background(), sign(map[string]interface{}{"iss": srv.URL, "aud": "avcimporter", "sub": "bob", "exp": exp})); err != nil || p.Role != RoleRead {
+		t.Errorf("Verify() without roles = %+v, %v; expected the default role", p, err)
+	}
+
+	good := sign(map[string]interface{}{"iss": srv.URL, "aud": "avcimporter", "sub": "eve", "exp": exp, "roles": "ops"})
+	for name, token := range map[string]string{
+		"issuer":   sign(map[string]interface{}{"iss": "https://other", "aud": "avcimporter", "sub": "eve", "exp": exp}),
+		"audience": sign(map[string]interface{}{"iss": srv.URL, "aud": "grafana", "sub": "eve", "exp": exp}),
+		"expired":  sign(map[string]interface{}{"iss": srv.URL, "aud": "avcimporter", "sub": "eve", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"tampered": good[:len(good)-4] + "AAAA",
+		"garbage":  "not-a-token",
+	} {
+		if _, err := o.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: Verify() accepted the token", name)
+		}
+	}
+
+	a := &Authenticator{OIDC: o}
+	req := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+good)
+	if p, err := a.Authenticate(req); err != nil || p.Role != RoleTrigger {
+		t.Errorf("Authenticate() = %+v, %v; expected trigger", p, err)
+	}
+}
+
+// TestOIDCKeyFetch verifies that a slow key set fetch does not hold up tokens
+// signed with a known key and that a failed fetch is not repeated by every
+// request.
+func TestOIDCKeyFetch(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	sign := func(kid string) string {
+		h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+		c, _ := json.Marshal(map[string]interface{}{"iss": srv.URL, "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+		signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+		digest := sha256.Sum256([]byte(signed))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	o := &OIDC{Issuer: srv.URL, DefaultRole: RoleRead, keys: map[string]crypto.PublicKey{"k1": &priv.PublicKey}}
+
+	unknown := make(chan error, 1)
+	go func() {
+		_, err := o.Verify(context.Background(), sign("k2"))
+		unknown <- err
+	}()
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := o.Verify(context.Background(), sign("k1"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Verify() with a known key = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Verify() with a known key waited for the key set fetch")
+	}
+
+	close(release)
+	if err := <-unknown; err == nil {
+		t.Error("Verify() with an unknown key succeeded although the fetch failed")
+	}
+	if _, err := o.Verify(context.Background(), sign("k3")); err == nil || !strings.Contains(err.Error(), "retry in") {
+		t.Errorf("Verify() right after a failed fetch = %v; expected the back-off", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("key set fetched %d times; expected 1", n)
+	}
+}
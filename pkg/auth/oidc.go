@@ -0,0 +1,349 @@
+// pkg/auth/oidc.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is how far exp and nbf may be off the local clock.
+const clockSkew = time.Minute
+
+// refetchAfter keeps a token with an unknown key ID from fetching the key
+// set more often than this.
+const refetchAfter = time.Minute
+
+// fetchBackoff is the wait after a failed key set fetch, doubled with every
+// further failure up to refetchAfter.
+const fetchBackoff = 5 * time.Second
+
+// fetchTimeout bounds a key set fetch, which outlives the request that
+// started it since others may be waiting for it.
+const fetchTimeout = 10 * time.Second
+
+/*
+OIDC verifies bearer tokens (signed JWTs) issued by an OpenID Connect
+provider. The signing keys are read from the jwks_uri of the issuer's
+discovery document on first use and again when a token names an unknown key.
+One request fetches them while others needing them wait; after a failed
+fetch, tokens are refused without fetching again for a growing back-off.
+RS256 and ES256 signatures are accepted.
+
+Fields:
+  - Issuer:      Expected "iss" claim; the discovery document is read from
+    <Issuer>/.well-known/openid-configuration.
+  - Audience:    Expected "aud" claim (e.g. the client ID); empty accepts any.
+  - RoleClaim:   Claim holding the caller's roles, a string or an array
+    (default: "roles").
+  - Roles:       Claim values mapped to roles; the highest match applies.
+  - DefaultRole: Role of valid tokens without a mapped claim value (RoleNone refuses them).
+  - Client:      HTTP client for discovery (default: http.DefaultClient).
+*/
+type OIDC struct {
+	Issuer      string
+	Audience    string
+	RoleClaim   string
+	Roles       map[string]Role
+	DefaultRole Role
+	Client      *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time     // last successful fetch
+	fetching chan struct{} // closed when the fetch in progress ends
+	failures int           // failed fetches since the last success
+	retryAt  time.Time     // no fetch before this after a failure
+	fetchErr error         // why the last fetch failed
+}
+
+/*
+Verify checks the signature, issuer, audience and validity period of token.
+
+Returns:
+  - The caller, named after the "sub" claim.
+  - An error wrapping ErrBadCredentials if the token is not accepted.
+*/
+func (o *OIDC) Verify(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: not a JWT", ErrBadCredentials)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrBadCredentials, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid signature encoding", ErrBadCredentials)
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return Principal{}, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrBadCredentials, err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrBadCredentials, err)
+	}
+	if iss, _ := claims["iss"].(string); iss != o.Issuer {
+		return Principal{}, fmt.Errorf("%w: issuer %q", ErrBadCredentials, iss)
+	}
+	if o.Audience != "" && !contains(claimValues(claims["aud"]), o.Audience) {
+		return Principal{}, fmt.Errorf("%w: audience does not match", ErrBadCredentials)
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return Principal{}, fmt.Errorf("%w: token expired", ErrBadCredentials)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return Principal{}, fmt.Errorf("%w: token not yet valid", ErrBadCredentials)
+	}
+
+	sub, _ := claims["sub"].(string)
+	role := o.DefaultRole
+	claim := o.RoleClaim
+	if claim == "" {
+		claim = "roles"
+	}
+	for _, v := range claimValues(claims[claim]) {
+		if r, ok := o.Roles[v]; ok && r > role {
+			role = r
+		}
+	}
+	if role == RoleNone {
+		return Principal{}, fmt.Errorf("%w: %s has no role", ErrBadCredentials, sub)
+	}
+	return Principal{Name: sub, Role: role}, nil
+}
+
+// key returns the signing key with the given ID, fetching the key set when
+// it is not known yet. An empty ID matches the only key of the set. The fetch
+// runs without holding o.mu, so a slow provider only delays the requests that
+// need a new key.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	for o.fetching != nil {
+		if k := lookupKey(o.keys, kid); k != nil {
+			o.mu.Unlock()
+			return k, nil
+		}
+		done := o.fetching
+		o.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		o.mu.Lock()
+	}
+	if k := lookupKey(o.keys, kid); k != nil {
+		o.mu.Unlock()
+		return k, nil
+	}
+	now := time.Now()
+	switch {
+	case now.Before(o.retryAt):
+		err := o.fetchErr
+		o.mu.Unlock()
+		return nil, fmt.Errorf("failed to fetch OIDC keys (retry in %s): %w", o.retryAt.Sub(now).Round(time.Second), err)
+	case now.Sub(o.fetched) < refetchAfter:
+		o.mu.Unlock()
+		return nil, fmt.Errorf("%w: unknown key %q", ErrBadCredentials, kid)
+	}
+	done := make(chan struct{})
+	o.fetching = done
+	o.mu.Unlock()
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+	keys, err := o.fetchKeys(fetchCtx)
+	cancel()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fetching = nil
+	close(done)
+	if err != nil {
+		o.failures++
+		backoff := fetchBackoff << (o.failures - 1)
+		if backoff > refetchAfter || backoff <= 0 {
+			backoff = refetchAfter
+		}
+		o.retryAt, o.fetchErr = time.Now().Add(backoff), err
+		return nil, fmt.Errorf("failed to fetch OIDC keys: %w", err)
+	}
+	o.keys, o.fetched, o.failures = keys, time.Now(), 0
+	if k := lookupKey(o.keys, kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrBadCredentials, kid)
+}
+
+// lookupKey returns keys[kid], or the only key when kid is empty.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) crypto.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return keys[kid]
+}
+
+// fetchKeys reads the discovery document of the issuer and the key set it points to.
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := o.getJSON(ctx, strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document has no jwks_uri")
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if k.Crv != "P-256" || errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no usable key in %s", discovery.JWKSURI)
+	}
+	return keys, nil
+}
+
+// getJSON decodes the JSON document at url into v.
+func (o *OIDC) getJSON(ctx context.Context, url string, v interface{}) error {
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// verifySignature checks sig over signed with key for the JWT algorithm alg.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return fmt.Errorf("signature does not match")
+		}
+	case "ES256":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return fmt.Errorf("signature does not match")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into v.
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("invalid token encoding")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid token: %v", err)
+	}
+	return nil
+}
+
+// claimValues returns a string or array claim as a list of strings.
+func claimValues(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// pkg/buildinfo/buildinfo.go
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+/*
+Build metadata, injected at build time with -ldflags, e.g.
+
+	go build -ldflags "-X github.com/heinrichb/avcimporter/pkg/buildinfo.Version=1.4.0
+	  -X github.com/heinrichb/avcimporter/pkg/buildinfo.Commit=$(git rev-parse HEAD)
+	  -X github.com/heinrichb/avcimporter/pkg/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+Binaries built without them fall back to the module version and VCS stamps
+the Go toolchain records (see Get).
+*/
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+/*
+Info describes the running binary.
+
+Fields:
+  - Version:   Release version ("dev" when unknown).
+  - Commit:    VCS revision the binary was built from.
+  - Date:      Build or commit time (RFC 3339).
+  - Modified:  Whether the working tree had uncommitted changes.
+  - GoVersion: Go toolchain version, e.g. "go1.22.3".
+  - Platform:  GOOS/GOARCH, e.g. "linux/amd64".
+*/
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+/*
+Get returns the build metadata, preferring values injected with -ldflags.
+*/
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+/*
+UserAgent builds a User-Agent in the form Amazon asks SP‑API callers to use:
+"AppName/Version (Language=Go/1.22.3; Platform=linux/amd64)".
+
+Parameters:
+  - appName:    Application name; empty uses "avcimporter".
+  - appVersion: Application version; empty uses the build version.
+*/
+func UserAgent(appName, appVersion string) string {
+	info := Get()
+	if appName == "" {
+		appName = "avcimporter"
+	}
+	if appVersion == "" {
+		appVersion = info.Version
+	}
+	return fmt.Sprintf("%s/%s (Language=Go/%s; Platform=%s)", appName, appVersion, strings.TrimPrefix(info.GoVersion, "go"), info.Platform)
+}
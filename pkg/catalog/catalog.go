@@ -0,0 +1,182 @@
+// pkg/catalog/catalog.go
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+CasePacks holds the case pack (units per case) of each product of the vendor
+catalog. Keys are vendor SKUs or ASINs.
+*/
+type CasePacks map[string]int
+
+/*
+LoadCasePacks reads case packs from a CSV file with a header row and the
+columns "sku" (vendor SKU or ASIN) and "casePack" (units per case). Other
+columns are ignored, so an export of the item master can be used as is, and
+products with an empty case pack are skipped.
+
+Returns:
+  - The case packs.
+  - An error if the file cannot be read, lacks a column, has a case pack that
+    is not a positive whole number or lists a SKU twice.
+*/
+func LoadCasePacks(path string) (CasePacks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog header: %w", err)
+	}
+	skuCol, packCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "sku":
+			skuCol = i
+		case "casepack":
+			packCol = i
+		}
+	}
+	if skuCol < 0 || packCol < 0 {
+		return nil, fmt.Errorf("catalog %s needs the columns sku and casePack", path)
+	}
+
+	c := CasePacks{}
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return c, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", path, err)
+		}
+		if max(skuCol, packCol) >= len(rec) {
+			return nil, fmt.Errorf("catalog %s line %d: missing columns", path, line)
+		}
+		sku, pack := strings.TrimSpace(rec[skuCol]), strings.TrimSpace(rec[packCol])
+		if sku == "" || pack == "" {
+			continue
+		}
+		n, err := strconv.Atoi(pack)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("catalog %s line %d: case pack %q is not a positive whole number", path, line, pack)
+		}
+		if _, dup := c[sku]; dup {
+			return nil, fmt.Errorf("catalog %s line %d: %s is listed twice", path, line, sku)
+		}
+		c[sku] = n
+	}
+}
+
+/*
+Size returns the case pack of a product, looking up the vendor SKU first and
+then the ASIN, or 0 if the catalog does not know the product.
+*/
+func (c CasePacks) Size(vendorSKU, asin string) int {
+	if n, ok := c[vendorSKU]; ok && vendorSKU != "" {
+		return n
+	}
+	if n, ok := c[asin]; ok && asin != "" {
+		return n
+	}
+	return 0
+}
+
+/*
+Line is a quantity of one purchase order line in an acknowledgement or a
+shipment confirmation.
+
+Fields:
+  - Shipment:            Shipment ID of an ASN line; empty for acknowledgements.
+  - PurchaseOrderNumber: The order.
+  - ItemSequenceNumber:  The order line.
+  - VendorSKU:           Vendor product identifier.
+  - ASIN:                Amazon product identifier.
+  - Units:               Quantity in eaches.
+*/
+type Line struct {
+	Shipment            string
+	PurchaseOrderNumber string
+	ItemSequenceNumber  string
+	VendorSKU           string
+	ASIN                string
+	Units               int
+}
+
+/*
+Violation is an order line whose total quantity is not a whole number of
+cases.
+
+Fields:
+  - Units:    The total quantity in eaches.
+  - CasePack: Units per case in the catalog.
+*/
+type Violation struct {
+	Shipment            string `json:"shipment,omitempty"`
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+	ItemSequenceNumber  string `json:"itemSequenceNumber"`
+	Product             string `json:"product"`
+	Units               int    `json:"units"`
+	CasePack            int    `json:"casePack"`
+}
+
+// Error describes the violation with the nearest valid quantities.
+func (v Violation) Error() string {
+	down := v.Units / v.CasePack * v.CasePack
+	msg := fmt.Sprintf("%d units of %s are not a multiple of its case pack of %d (use ", v.Units, v.Product, v.CasePack)
+	if down > 0 {
+		msg += fmt.Sprintf("%d or ", down)
+	}
+	return msg + fmt.Sprintf("%d)", down+v.CasePack)
+}
+
+/*
+Check adds up the units of lines per shipment and order line, since an order
+line may be split over several input lines, and checks every total against
+the product's case pack. Products missing from the catalog are not checked.
+
+Returns:
+  - The violations, in the order their order lines first appear.
+*/
+func (c CasePacks) Check(lines []Line) []Violation {
+	type key struct{ shipment, po, item string }
+	totals := map[key]*Violation{}
+	var order []*Violation
+	for _, l := range lines {
+		pack := c.Size(l.VendorSKU, l.ASIN)
+		if pack == 0 {
+			continue
+		}
+		k := key{l.Shipment, l.PurchaseOrderNumber, l.ItemSequenceNumber}
+		v := totals[k]
+		if v == nil {
+			product := l.VendorSKU
+			if product == "" {
+				product = l.ASIN
+			}
+			v = &Violation{Shipment: l.Shipment, PurchaseOrderNumber: l.PurchaseOrderNumber, ItemSequenceNumber: l.ItemSequenceNumber, Product: product, CasePack: pack}
+			totals[k] = v
+			order = append(order, v)
+		}
+		v.Units += l.Units
+	}
+	var violations []Violation
+	for _, v := range order {
+		if v.Units%v.CasePack != 0 {
+			violations = append(violations, *v)
+		}
+	}
+	return violations
+}
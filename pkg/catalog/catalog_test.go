@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLoadCasePacks verifies extra columns and empty case packs are ignored
+// and invalid case packs are refused.
+func TestLoadCasePacks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "catalog.csv")
+	os.WriteFile(path, []byte("sku,description,CasePack\nSKU-1,Widget,6\nB0ASIN2,Gadget,12\nSKU-3,Loose,\n"), 0o644)
+	c, err := LoadCasePacks(path)
+	if err != nil {
+		t.Fatalf("LoadCasePacks() returned %v", err)
+	}
+	if !reflect.DeepEqual(c, CasePacks{"SKU-1": 6, "B0ASIN2": 12}) {
+		t.Errorf("LoadCasePacks() = %v", c)
+	}
+	if n := c.Size("SKU-2", "B0ASIN2"); n != 12 {
+		t.Errorf("Size() by ASIN = %d; expected 12", n)
+	}
+
+	os.WriteFile(path, []byte("sku,casePack\nSKU-1,0\n"), 0o644)
+	if _, err := LoadCasePacks(path); err == nil {
+		t.Error("LoadCasePacks() accepted a case pack of 0")
+	}
+}
+
+// TestCheck verifies split lines are added up before checking, shipments are
+// checked separately and unknown products are skipped.
+func TestCheck(t *testing.T) {
+	c := CasePacks{"SKU-1": 6, "SKU-2": 4}
+	lines := []Line{
+		{Shipment: "S1", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "1", VendorSKU: "SKU-1", Units: 4},
+		{Shipment: "S1", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "2", VendorSKU: "SKU-2", Units: 10},
+		{Shipment: "S1", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "1", VendorSKU: "SKU-1", Units: 2},
+		{Shipment: "S2", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "1", VendorSKU: "SKU-1", Units: 3},
+		{Shipment: "S2", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "3", ASIN: "B0UNKNOWN", Units: 5},
+	}
+	want := []Violation{
+		{Shipment: "S1", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "2", Product: "SKU-2", Units: 10, CasePack: 4},
+		{Shipment: "S2", PurchaseOrderNumber: "PO1", ItemSequenceNumber: "1", Product: "SKU-1", Units: 3, CasePack: 6},
+	}
+	got := c.Check(lines)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Check() = %+v; expected %+v", got, want)
+	}
+	if msg := got[0].Error(); msg != "10 units of SKU-2 are not a multiple of its case pack of 4 (use 8 or 12)" {
+		t.Errorf("Error() = %q", msg)
+	}
+	if msg := got[1].Error(); msg != "3 units of SKU-1 are not a multiple of its case pack of 6 (use 6)" {
+		t.Errorf("Error() = %q", msg)
+	}
+}
@@ -0,0 +1,190 @@
+// pkg/checkpoint/checkpoint.go
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Checkpoint records how far a source has been imported.
+
+Fields:
+  - Value:     Opaque position in the source: a timestamp, control number or token.
+  - UpdatedAt: When the checkpoint last advanced.
+*/
+type Checkpoint struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+/*
+Key joins its parts into a checkpoint key such as "spapi:NA:purchaseOrders" or
+"edi:amazon:inbound". Each source (endpoint, marketplace region, partner,
+direction) should get its own key so adding one never moves another's position.
+*/
+func Key(parts ...string) string {
+	return strings.Join(parts, ":")
+}
+
+/*
+Store keeps every checkpoint in one JSON file, keyed by Key. It is safe for
+concurrent use within a process; writes replace the file atomically so a crash
+never leaves it half-written.
+*/
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	perms *utils.Permissions
+}
+
+/*
+Open returns a Store backed by the file at path. The file is created on the
+first Save, with perms (nil uses utils.DefaultPermissions).
+*/
+func Open(path string, perms *utils.Permissions) *Store {
+	return &Store{path: path, perms: perms}
+}
+
+/*
+Path returns the file backing the store.
+*/
+func (s *Store) Path() string {
+	return s.path
+}
+
+/*
+Load returns the checkpoint stored under key.
+
+Returns:
+  - The checkpoint and true if one exists.
+  - An error if the file cannot be read or is not valid JSON.
+*/
+func (s *Store) Load(key string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	cp, ok := all[key]
+	return cp, ok, nil
+}
+
+/*
+All returns every stored checkpoint.
+*/
+func (s *Store) All() (map[string]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+/*
+Save advances the checkpoint under key to value, leaving all other keys untouched.
+*/
+func (s *Store) Save(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	prev := all[key].Value
+	all[key] = Checkpoint{Value: value, UpdatedAt: time.Now().UTC()}
+	if err := s.write(all); err != nil {
+		return err
+	}
+	if prev == value {
+		return nil
+	}
+	return audit.Record(audit.Event{
+		Action: audit.CheckpointAdvance,
+		Target: key,
+		Fields: map[string]string{"from": prev, "to": value, "store": s.path},
+	})
+}
+
+/*
+Delete removes the checkpoint under key, so the next run starts from scratch.
+*/
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[key]; !ok {
+		return nil
+	}
+	prev := all[key].Value
+	delete(all, key)
+	if err := s.write(all); err != nil {
+		return err
+	}
+	return audit.Record(audit.Event{
+		Action: audit.CheckpointDelete,
+		Target: key,
+		Fields: map[string]string{"from": prev, "store": s.path},
+	})
+}
+
+// read loads the checkpoint file; a missing file is an empty store.
+func (s *Store) read() (map[string]Checkpoint, error) {
+	all := make(map[string]Checkpoint)
+	data, err := codec.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", s.path, err)
+	}
+	return all, nil
+}
+
+// write replaces the checkpoint file via a temporary file and rename.
+func (s *Store) write(all map[string]Checkpoint) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+	if err := s.perms.MkdirAll(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".checkpoints-*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := s.perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	return nil
+}
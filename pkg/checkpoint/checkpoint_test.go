@@ -0,0 +1,36 @@
+package checkpoint
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestKeysAreIndependent verifies advancing one key leaves the others untouched.
+func TestKeysAreIndependent(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "state", "checkpoints.json"), nil)
+	retail := Key("spapi", "NA", "purchaseOrders")
+	edi := Key("edi", "amazon", "inbound")
+
+	if _, ok, err := s.Load(retail); err != nil || ok {
+		t.Fatalf("Load() on empty store = %v, %v; expected no checkpoint", ok, err)
+	}
+	if err := s.Save(retail, "2024-01-02T00:00:00Z"); err != nil {
+		t.Fatalf("Save() returned %v", err)
+	}
+	if err := s.Save(edi, "000000101"); err != nil {
+		t.Fatalf("Save() returned %v", err)
+	}
+
+	reopened := Open(s.Path(), nil)
+	cp, ok, err := reopened.Load(retail)
+	if err != nil || !ok || cp.Value != "2024-01-02T00:00:00Z" {
+		t.Errorf("Load(%q) = %+v, %v, %v; expected the saved timestamp", retail, cp, ok, err)
+	}
+	if err := reopened.Delete(edi); err != nil {
+		t.Fatalf("Delete() returned %v", err)
+	}
+	all, err := reopened.All()
+	if err != nil || len(all) != 1 {
+		t.Errorf("All() = %v, %v; expected only %q", all, err, retail)
+	}
+}
@@ -0,0 +1,204 @@
+// pkg/codec/codec.go
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+Codec compresses stored artifacts. A compressed file carries the codec's
+extension after its own (e.g. "PO1.json.gz") and starts with the codec's
+magic bytes, by which readers recognise it whatever its name.
+*/
+type Codec interface {
+	// Name is the value of storage.compression selecting the codec.
+	Name() string
+	// Ext is the file name suffix of compressed files, including the dot.
+	Ext() string
+	// Magic is the prefix of every compressed stream.
+	Magic() []byte
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = map[string]Codec{}
+)
+
+func init() {
+	Register(gzipCodec{})
+	Register(zstdCodec{})
+}
+
+/*
+Register makes a codec available to Lookup and to the read paths, replacing a
+codec of the same name.
+*/
+func Register(c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+	codecs[c.Name()] = c
+}
+
+/*
+Lookup returns the codec named by storage.compression. An empty name or
+"none" means no compression and returns nil.
+*/
+func Lookup(name string) (Codec, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q (known: none, %s)", name, strings.Join(names(), ", "))
+	}
+	return c, nil
+}
+
+// names lists the registered codecs; the caller holds mu.
+func names() []string {
+	out := make([]string, 0, len(codecs))
+	for name := range codecs {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+/*
+Encode compresses data with c; a nil codec returns data unchanged.
+*/
+func Encode(c Codec, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	var b bytes.Buffer
+	w, err := c.NewWriter(&b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress with %s: %w", c.Name(), err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("failed to compress with %s: %w", c.Name(), err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress with %s: %w", c.Name(), err)
+	}
+	return b.Bytes(), nil
+}
+
+/*
+Decode returns the content of data, decrypting it if it was sealed (see Seal)
+and decompressing it if it starts with the magic bytes of a registered codec.
+*/
+func Decode(data []byte) ([]byte, error) {
+	data, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+	c := Detect(data)
+	if c == nil {
+		return data, nil
+	}
+	r, err := c.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s data: %w", c.Name(), err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s data: %w", c.Name(), err)
+	}
+	return out, nil
+}
+
+/*
+Detect returns the registered codec whose magic bytes start data, or nil.
+*/
+func Detect(data []byte) Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range codecs {
+		if len(c.Magic()) > 0 && bytes.HasPrefix(data, c.Magic()) {
+			return c
+		}
+	}
+	return nil
+}
+
+/*
+ReadFile reads a stored artifact or state file, decrypting and decompressing
+it transparently.
+*/
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return out, nil
+}
+
+/*
+TrimExt returns name without the extension of a registered codec, e.g.
+"PO1.json" for "PO1.json.gz".
+*/
+func TrimExt(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range codecs {
+		if strings.HasSuffix(name, c.Ext()) {
+			return strings.TrimSuffix(name, c.Ext())
+		}
+	}
+	return name
+}
+
+// gzipCodec is gzip at the default level.
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string  { return "gzip" }
+func (gzipCodec) Ext() string   { return ".gz" }
+func (gzipCodec) Magic() []byte { return []byte{0x1f, 0x8b} }
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// zstdCodec is Zstandard at the default level.
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string  { return "zstd" }
+func (zstdCodec) Ext() string   { return ".zst" }
+func (zstdCodec) Magic() []byte { return []byte{0x28, 0xb5, 0x2f, 0xfd} }
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
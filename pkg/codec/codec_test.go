@@ -0,0 +1,45 @@
+package codec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRoundTrip verifies every built-in codec's output is recognised and
+// decoded by ReadFile, and that plain files are read unchanged.
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"purchaseOrderNumber":"PO1"}`), 100)
+	dir := t.TempDir()
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		c, err := Lookup(name)
+		if err != nil {
+			t.Fatalf("Lookup(%q): %v", name, err)
+		}
+		enc, err := Encode(c, data)
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", name, err)
+		}
+		if c != nil && len(enc) >= len(data) {
+			t.Errorf("%s did not compress: %d >= %d bytes", name, len(enc), len(data))
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, enc, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("ReadFile(%s) returned different content", name)
+		}
+	}
+	if _, err := Lookup("lz4"); err == nil {
+		t.Error("Lookup(lz4) succeeded; expected an error")
+	}
+	if got := TrimExt("PO1.json.zst"); got != "PO1.json" {
+		t.Errorf("TrimExt() = %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestSealOpen verifies sealed data is decoded with the right key only, and
+// that plain data still passes through.
+func TestSealOpen(t *testing.T) {
+	defer SetKey(nil)
+	key, err := ParseKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"edi:AMAZON:inbound":{"value":"000000042"}}`)
+	gz, _ := Lookup("gzip")
+	compressed, _ := Encode(gz, data)
+	sealed, err := Seal(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Encrypted(sealed) || bytes.Contains(sealed, []byte("AMAZON")) {
+		t.Fatal("Seal() did not encrypt")
+	}
+	if got, err := Decode(sealed); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Decode() = %q, %v", got, err)
+	}
+	if got, err := Decode(data); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Decode(plain) = %q, %v", got, err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Open(tampered); err == nil {
+		t.Error("Open() accepted tampered data")
+	}
+	other, _ := ParseKey("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	SetKey(other)
+	if _, err := Open(sealed); err == nil {
+		t.Error("Open() accepted data sealed with another key")
+	}
+	SetKey(nil)
+	if _, err := Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Open() without key returned %v; expected ErrNoKey", err)
+	}
+	if _, err := ParseKey("c2hvcnQ="); err == nil {
+		t.Error("ParseKey() accepted a short key")
+	}
+}
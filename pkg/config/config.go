@@ -2,26 +2,60 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/totp"
 	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/x12"
 )
 
 /*
-Global Verbose flag.
+Logger receives the notices of Load and Watch, such as the file loaded or
+ignored environment variables. A nil Logger discards them.
+*/
+type Logger func(level utils.Level, prefix, detail string)
+
+// log passes a notice to l, if set.
+func (l Logger) log(level utils.Level, prefix, detail string) {
+	if l != nil {
+		l(level, prefix, detail)
+	}
+}
 
-This flag determines whether verbose output is enabled.
-It is set in `main.go` and used throughout the application.
+/*
+LoadOptions control how Load builds the configuration. The zero value loads
+the file strictly, without environment variables, profile or notices.
+
+Fields:
+  - Lenient: Ignore keys the importer does not know instead of rejecting the file (--lenient).
+  - Profile: Entry of the config's profiles applied on top of the file, e.g. "sandbox" (--profile).
+  - Environ: "NAME=value" pairs whose AVC_* variables override the file and the profile, usually os.Environ().
+  - Logger:  Receives notices; errors are returned instead.
 */
-var Verbose bool
+type LoadOptions struct {
+	Lenient bool
+	Profile string
+	Environ []string
+	Logger  Logger
+}
 
 /*
 Config holds configuration data used by AVC Importer CLI.
 
 Fields:
   - Version:      The current version of the configuration.
+  - Timezone:     IANA time zone for every generated timestamp: ISA/GS dates and
+    times, and timestamps in file names. Defaults to "UTC" so output does not
+    depend on the host's local time.
   - API:          SP‑API credentials and endpoints.
       - Active:        Enable the SP‑API flow when true.
       - Auth:
@@ -32,22 +66,183 @@ Fields:
       - BaseURL:       The base URL for SP‑API requests.
       - TokenURL:      The URL to retrieve OAuth2 tokens.
       - EndpointURL:   The SP‑API path to fetch data (e.g. purchase orders).
+      - Query:         Query parameters added to every fetch of EndpointURL, e.g. {"poItemState": "Cancelled",
+        "limit": "50"}; createdAfter, changedAfter and nextToken of the fetch itself take precedence.
+      - AppName:       Application name in the User-Agent (default: "avcimporter").
+      - AppVersion:    Application version in the User-Agent (default: the build version).
+      - UserAgent:     Complete User-Agent, replacing the generated one.
+      - Throttle:      Adaptive pacing per operation, following Amazon's x-amzn-RateLimit-Limit header.
+          - Rate:          Requests per second before Amazon reports a limit (0 sends unpaced).
+          - CooldownAfter: Consecutive 429/503 responses that pause the operation (default: 3).
+          - Cooldown:      Length of that pause (default: 1m).
+          - AlertAfter:    Run hooks subscribed to "throttled" when throttling lasts this long (default: 15m).
+      - SigV4:         AWS Signature Version 4 signing of SP‑API requests, for apps registered with an IAM role.
+          - Enabled:     Sign every request to baseUrl.
+          - Region:      AWS region of baseUrl (default: derived from it, e.g. us-east-1 for NA).
+          - Profile:     Shared credentials profile supplying the keys (default: the SDK chain of
+            environment, AWS_PROFILE or default profile, then container or instance role).
+          - RoleARN:     IAM role registered with the app, assumed with STS using those keys.
+          - ExternalID:  External ID the role's trust policy requires.
+          - SessionName: Role session name shown in CloudTrail (default: "avcimporter").
   - EDI:          SFTP credentials and directories for EDI integration.
       - Active:        Enable the EDI/SFTP flow when true.
       - Host:           The SFTP server hostname.
       - Port:           The SFTP port (usually 22).
       - Username:       The SFTP username assigned by Amazon.
       - PrivateKeyPath: Path to your SSH private key for authentication.
+      - PrivateKey:     The private key itself, as PEM text or base64, used instead of PrivateKeyPath (e.g. from a secret store).
+      - PrivateKeyPassphrase: Passphrase of an encrypted private key (inline, file or previous key).
+      - PreviousKeyPath: Old SSH key offered after PrivateKeyPath while a rotated key is not yet active (see rotate-key).
       - InboundDir:     Directory where PO files land.
       - OutboundDir:    Directory for ACKs and other outbound messages.
+      - CreateOutboundDir: Create OutboundDir on connect if it is missing and the server permits it.
+      - DiscoverDirs:   Use Amazon's standard "download"/"upload" directories when InboundDir or OutboundDir does not exist.
       - SenderID:       Your Amazon‑assigned SFTP ID (the “YOURID” in 997).
+      - Usage:          ISA15 usage indicator of outbound interchanges: "T" (test, default) or "P" (production; sending requires --production).
+      - Acknowledge:    Upload a 997 for every inbound interchange when true.
+      - Decompress:     Extract gzip/zip inbound files before parsing, archiving the originals.
+      - KeepRemote:     Leave inbound files on the server; later runs continue after the last file fetched.
+      - MaxFiles:       Fetch at most this many inbound files per run (0 fetches all).
+      - SortBy:         Inbound fetch order: "name" (default) or "mtime" (oldest first).
+      - LocalInboundDir: Local or NFS directory partners deliver inbound files to, watched by `watch` and the daemon (empty disables).
+      - StableFor:      How long a local inbound file must stay unchanged before it is picked up (default: 10s).
+      - X12:            Delimiters, version and decimal separator of X12 documents.
+      - Partners:       Per-partner X12 overrides keyed by the partner's ISA ID (the sender ID of inbound interchanges).
+      - Templates:      Custom outbound documents built by `edi build`, keyed by name.
+      - KeyboardInteractive: Answers to keyboard-interactive prompts (e.g. an MFA code) of servers that require them.
   - Storage:      Settings for where and how to save fetched data.
-      - OutputFormat: The format to save data (e.g. json).
-      - SavePath:     Directory path for saving files.
-      - FileName:     Base name for saved files.
+      - OutputFormat:   "json" (default) stores orders as JSON only; "parquet" also writes them to normalized orders and line items datasets under <savePath>/parquet, partitioned by order date.
+      - Compression:    Compress stored orders and EDI transaction sets: "none" (default), "gzip" or "zstd"; the codec's extension (.gz, .zst) is appended to their names and the read paths decompress them transparently.
+      - SavePath:       Directory path for saving files.
+      - FileName:       Base name for saved files.
+      - CheckpointPath: File holding import positions per source (default: <savePath>/checkpoints.json).
+      - ArchivePath:    Directory keeping compressed originals (default: <savePath>/archive).
+      - LifecyclePath:  File linking each PO to its acknowledgments, ASNs and invoices (default: <savePath>/lifecycle.json).
+      - DedupePath:     File of imported order versions, keyed by PO number and change time (default: <savePath>/dedupe.json).
+      - DedupeRetention: How long an imported version is remembered (default: 2160h).
+      - HistoryPath:    Run history, one JSON line per flow run, shown by `history` (default: <savePath>/history.jsonl).
+      - HistoryMaxAge:  How long runs are kept in the history (default: 2160h).
+      - OutboxPath:     Export deliveries and their state (default: <savePath>/outbox.json).
+      - DedupeChannels: Import a PO only from the channel (EDI 850 or SP‑API) that delivered it first, skipping its copies on the other, e.g. while migrating from EDI to the API.
+      - CredentialsPath: File (mode 0600) keeping credentials that rotate at run time, e.g. QuickBooks refresh tokens (default: <savePath>/credentials.json).
+      - InboundIndex:   Directory indexing processed inbound EDI files by content hash, so a file seen before is skipped (empty disables).
+      - StagingPath:    Directory where one-shot runs stage their files, state changes, acknowledgments and exports, publishing them only if every flow succeeded (empty disables; see `run --commit-partial`).
+      - Encryption:     Encrypt stored orders, EDI transaction sets and the state files (checkpoints, lifecycle, dedupe, outbox, credentials) with AES-256-GCM; files written before are still read and encrypted on their next update. Reports, summaries, Parquet datasets, the run history and the audit log stay plain.
+          - Key:     Base64 of a 32-byte key (e.g. `openssl rand -base64 32`); better set through AVC_STORAGE_ENCRYPTION_KEY.
+          - KeyFile: File holding the key instead, e.g. a mounted secret.
+      - Permissions:    Mode and ownership of the files and directories written (storage, state, history, locks); credentials, SSH keys, HTTP captures and the audit log keep their private modes.
+          - FileMode: Octal mode of files (default: "0644"), e.g. "0664" for group-writable output.
+          - DirMode:  Octal mode of directories (default: "0755"), e.g. "2775" so new files inherit the group.
+          - Owner:    User name or UID to chown to (empty keeps the process user; needs privileges).
+          - Group:    Group name or GID to chown to (empty keeps the process group).
+      - FileNames:      File name templates per document type ("order", "edi", "ack", "report", "datakiosk", "manifest", "summary", "document"); {{.Hash}} adds a content hash.
+  - Reports:      SP‑API Reports flow (uses the API credentials and base URL).
+      - Active:         Enable the Reports flow when true.
+      - MarketplaceIDs: Marketplaces every report covers.
+      - PollInterval:   Time between report status checks (default: 30s).
+      - Timeout:        Give up waiting for a report after this long (default: 30m).
+      - Requests:       Reports to request on every run.
+  - DataKiosk:    Data Kiosk (GraphQL analytics) flow (uses the API credentials and base URL).
+      - Active:       Enable the Data Kiosk flow when true.
+      - PollInterval: Time between query status checks (default: 30s).
+      - Timeout:      Give up waiting for a query after this long (default: 1h).
+      - Queries:      GraphQL queries to submit on every run.
+  - Audit:        Append-only NDJSON trail of every remote-affecting action.
+      - Path:          Audit log file; empty disables auditing.
+      - MaxSizeMB:     Rotate the file once it reaches this size (default: 100).
+      - MaxFiles:      Rotated files to keep (0 keeps all).
+  - Retry:        Retry policies for network calls, also used by library consumers.
+      - SPAPI:         SP‑API requests, token refreshes and document downloads.
+      - SFTP:          SFTP connections.
+  - Processing:   How the purchase orders of an SP‑API response or EDI interchange are processed
+    (store, render documents, export through hooks, track).
+      - Workers:       Orders processed in parallel (default: 1, one after another); versions of one
+        PO are always processed in the order they arrived.
+      - Retry:         Retry policy of each order's processing (default: 3 attempts on network,
+        throttle and server errors); an order still failing fails the run.
+  - Hooks:        External commands run for every downloaded file and generated document.
+  - Exports:      Destinations every generated document of the configured types is sent to (e.g. an OMS REST API).
+  - Outbox:       Bookkeeping of export deliveries (in storage.outboxPath): a document is marked delivered only
+    once the target confirmed it, is never sent twice to one export, and is sent again until it gets through.
+      - SweepInterval: How often pending deliveries are sent again, and how long after its last attempt one is (default: 5m).
+      - MaxAttempts:   Attempts after which a delivery is given up (default: 20).
+      - Retention:     How long delivered and given-up documents are remembered (default: 2160h).
+  - SLA:          Deadline monitoring of open purchase orders (uses the lifecycle file).
+      - Active:     Check the rules on every run (daemon job "sla").
+      - WarnBefore: Report a document as due soon this long before its deadline (default: 4h).
+      - Rules:      Deadlines for acknowledgments, ASNs and invoices.
+  - Routing:      Which of our warehouses ships each order line; `route` splits a PO into one shipment per warehouse.
+      - Regions:    Amazon fulfillment center codes (ship-to party IDs) by region name.
+      - Rules:      Checked in order; the first rule matching a line's ship-to party, region and product routes it.
+      - Default:    Warehouse of lines no rule matches (empty: the order's selling party).
+  - Windows:      Ship and delivery window checks of acknowledgements and the `windows` calendar.
+      - LeadTimes:  Handling and transit times per warehouse; the first matching rule applies, and accepting an order whose window it cannot meet is warned about.
+  - Catalog:      Vendor catalog data the outbound documents are checked against before submission.
+      - CasePacks:  CSV file with the columns sku (vendor SKU or ASIN) and casePack (units per case); acknowledged and shipped quantities of listed products must be whole cases (empty disables the check).
+  - Printing:     Shipment labels (ZPL) and packing slips printed when sync-status submits a shipment.
+      - Printers:   Printers per warehouse; the first rule matching a shipment's ship-from party applies (none: nothing is printed).
+      - Copies:     Labels printed per shipment (default: 1).
+      - Timeout:    How long sending one document to a printer may take (default: 30s).
+  - Documents:    PDF printouts of every imported purchase order, stored next to it (file name type "document").
+      - PDF:                   Render a purchase order PDF and a packing slip PDF per warehouse the order is routed to.
+      - PurchaseOrderTemplate: Template file of the purchase order (empty: built-in; see documents.Template).
+      - PackingSlipTemplate:   Template file of the packing slips (empty: built-in).
+      - Email:                 SMTP delivery of the PDFs to the warehouses (host empty: not emailed).
+          - Host/Port:          SMTP server (port default: 587; 465 uses TLS).
+          - Username/Password:  SMTP login, if the server requires one.
+          - From:               Sender address.
+          - Recipients:         Addresses per warehouse; each gets the purchase order and its packing slip.
+  - Summary:      Daily order summary workbook (xlsx) with orders, line items and exceptions.
+      - Active:     Write the previous day's workbook once it is over (daemon job "summary").
+  - Daemon:       Scheduling settings used when running with -daemon.
+      - Workers:      Size of the worker pool shared by all jobs.
+      - Jobs:         Per-flow schedule keyed by flow name ("edi", "api", "reports", "datakiosk", "sla", "summary").
+        Jobs of a daemon profile are keyed "edi@<profile>" and "api@<profile>" and fall back to "edi" and "api".
+      - Profiles:     Profiles whose EDI and SP-API flows run as jobs of their own next to those of the loaded
+        configuration, e.g. one per SFTP partner mailbox or API region, each with its own schedule and lock.
+  - Lock:         Cross-instance locking so only one host runs a flow at a time.
+      - Backend:       "file", "redis", or empty to disable locking.
+      - Dir:           Shared (e.g. NFS) directory for lock files.
+      - RedisAddr:     Redis server address (host:port).
+      - RedisPassword: Redis AUTH password, if any.
+      - TTL:           Lease duration; a crashed instance releases its locks after this.
+  - Cleanup:      Removal of leftovers of interrupted runs at the start and end of each run
+    (hourly in daemon mode): partial downloads, temporary files and expired lock files.
+      - Active:        Enable the cleanup when true.
+      - MaxAge:        Only leftovers older than this are removed (default: 24h).
+  - Server:       HTTP endpoints exposed in daemon mode.
+      - Addr:          Listen address (e.g. ":8080"); empty disables the server.
+      - Socket:        Unix socket path serving the same endpoints (owner-only); empty disables it.
+      - Webhook:       Signed shipment callbacks accepted on POST /webhooks/shipments (see WebhookConfig).
+      - Auth:          API keys and OIDC bearer tokens required on server.addr (see AuthConfig).
+  - Tracing:      OpenTelemetry trace export.
+      - Endpoint:      OTLP/HTTP collector base URL; empty disables tracing.
+      - ServiceName:   Reported service.name resource attribute.
+      - Headers:       Extra headers sent with each export (e.g. API keys).
+  - Logging:      Where log messages are forwarded besides the console.
+      - Sinks:         CloudWatch Logs and syslog destinations, each with its own level.
+  - Metrics:      Run metrics (the counters of the daemon's /metrics endpoint) pushed at the
+    end of every run without -daemon, for cron invocations Prometheus cannot scrape.
+      - Push:          "cloudwatch" (PutMetricData), "statsd", "dogstatsd" (flow as a tag) or empty to disable.
+      - Namespace:     CloudWatch namespace (default: "AVCImporter") or StatsD prefix (default: "avcimporter").
+      - Addr:          StatsD server address (default: "127.0.0.1:8125").
+      - Region:        CloudWatch region (default: api.sigv4.region).
+      - Profile:       Shared credentials profile for CloudWatch; empty uses the default AWS credential chain.
+      - RoleARN:       Role to assume for CloudWatch, if any.
+  - Escalation:   On-call incident opened when a scheduled flow fails several runs in a row,
+    resolved by its next successful run.
+      - Provider:      "pagerduty", "opsgenie" or empty to disable.
+      - After:         Consecutive failed runs that open an incident (default: 3).
+      - RoutingKey:    PagerDuty Events API v2 integration key.
+      - APIKey:        Opsgenie API integration key.
+      - URL:           PagerDuty events endpoint or Opsgenie API base URL (e.g. "https://api.eu.opsgenie.com").
+  - Profiles:     Named overrides (e.g. "sandbox", "production") selected with --profile,
+    switching endpoints, the ISA usage indicator and storage paths together.
+  - Profile:      Name of the applied profile (not read from the file).
 */
 type Config struct {
-	Version string `json:"version"`
+	Version  string `json:"version"`
+	Timezone string `json:"timezone"`
 	API     struct {
 		Active      bool `json:"active"`
 		Auth        struct {
@@ -58,23 +253,824 @@ type Config struct {
 		} `json:"auth"`
 		BaseURL     string `json:"baseUrl"`
 		TokenURL    string `json:"tokenUrl"`
-		EndpointURL string `json:"endpointUrl"`
+		EndpointURL string            `json:"endpointUrl"`
+		Query       map[string]string `json:"query"`
+		AppName     string `json:"appName"`
+		AppVersion  string `json:"appVersion"`
+		UserAgent   string `json:"userAgent"`
+		Throttle    struct {
+			Rate          float64  `json:"rate"`
+			CooldownAfter int      `json:"cooldownAfter"`
+			Cooldown      Duration `json:"cooldown"`
+			AlertAfter    Duration `json:"alertAfter"`
+		} `json:"throttle"`
+		SigV4       struct {
+			Enabled     bool   `json:"enabled"`
+			Region      string `json:"region"`
+			Profile     string `json:"profile"`
+			RoleARN     string `json:"roleArn"`
+			ExternalID  string `json:"externalId"`
+			SessionName string `json:"sessionName"`
+		} `json:"sigv4"`
 	} `json:"api"`
 	EDI struct {
-		Active         bool   `json:"active"`
-		Host           string `json:"host"`
-		Port           int    `json:"port"`
-		Username       string `json:"username"`
-		PrivateKeyPath string `json:"privateKeyPath"`
-		InboundDir     string `json:"inboundDir"`
-		OutboundDir    string `json:"outboundDir"`
-		SenderID       string `json:"senderId"`
+		Active         bool                   `json:"active"`
+		Host           string                 `json:"host"`
+		Port           int                    `json:"port"`
+		Username       string                 `json:"username"`
+		PrivateKeyPath string                 `json:"privateKeyPath"`
+		PrivateKey     string                 `json:"privateKey"`
+		PrivateKeyPassphrase string           `json:"privateKeyPassphrase"`
+		PreviousKeyPath string                 `json:"previousKeyPath"`
+		InboundDir     string                 `json:"inboundDir"`
+		OutboundDir    string                 `json:"outboundDir"`
+		CreateOutboundDir bool                `json:"createOutboundDir"`
+		DiscoverDirs   bool                   `json:"discoverDirs"`
+		SenderID       string                 `json:"senderId"`
+		Usage          string                 `json:"usage"`
+		Acknowledge    bool                   `json:"acknowledge"`
+		Decompress     bool                   `json:"decompress"`
+		KeepRemote     bool                   `json:"keepRemote"`
+		MaxFiles       int                    `json:"maxFiles"`
+		SortBy          string                 `json:"sortBy"`
+		LocalInboundDir string                 `json:"localInboundDir"`
+		StableFor       Duration               `json:"stableFor"`
+		X12             X12Config              `json:"x12"`
+		Partners        map[string]X12Config   `json:"partners"`
+		Templates       map[string]EDITemplate `json:"templates"`
+		KeyboardInteractive []SFTPPrompt      `json:"keyboardInteractive"`
 	} `json:"edi"`
 	Storage struct {
-		OutputFormat string `json:"outputFormat"`
-		SavePath     string `json:"savePath"`
-		FileName     string `json:"fileName"`
+		OutputFormat    string            `json:"outputFormat"`
+		Compression     string            `json:"compression"`
+		SavePath        string            `json:"savePath"`
+		FileName        string            `json:"fileName"`
+		CheckpointPath  string            `json:"checkpointPath"`
+		ArchivePath     string            `json:"archivePath"`
+		LifecyclePath   string            `json:"lifecyclePath"`
+		DedupePath      string            `json:"dedupePath"`
+		DedupeRetention Duration          `json:"dedupeRetention"`
+		DedupeChannels  bool              `json:"dedupeChannels"`
+		HistoryPath     string            `json:"historyPath"`
+		HistoryMaxAge   Duration          `json:"historyMaxAge"`
+		OutboxPath      string            `json:"outboxPath"`
+		InboundIndex    string            `json:"inboundIndex"`
+		StagingPath     string            `json:"stagingPath"`
+		CredentialsPath string            `json:"credentialsPath"`
+		Encryption      struct {
+			Key     string `json:"key"`
+			KeyFile string `json:"keyFile"`
+		} `json:"encryption"`
+		Permissions struct {
+			FileMode string `json:"fileMode"`
+			DirMode  string `json:"dirMode"`
+			Owner    string `json:"owner"`
+			Group    string `json:"group"`
+		} `json:"permissions"`
+		FileNames map[string]string `json:"fileNames"`
 	} `json:"storage"`
+	Reports struct {
+		Active         bool           `json:"active"`
+		MarketplaceIDs []string       `json:"marketplaceIds"`
+		PollInterval   Duration       `json:"pollInterval"`
+		Timeout        Duration       `json:"timeout"`
+		Requests       []ReportConfig `json:"requests"`
+	} `json:"reports"`
+	DataKiosk struct {
+		Active       bool             `json:"active"`
+		PollInterval Duration         `json:"pollInterval"`
+		Timeout      Duration         `json:"timeout"`
+		Queries      []DataKioskQuery `json:"queries"`
+	} `json:"dataKiosk"`
+	Audit struct {
+		Path      string `json:"path"`
+		MaxSizeMB int    `json:"maxSizeMB"`
+		MaxFiles  int    `json:"maxFiles"`
+	} `json:"audit"`
+	Retry struct {
+		SPAPI RetryConfig `json:"spapi"`
+		SFTP  RetryConfig `json:"sftp"`
+	} `json:"retry"`
+	Processing struct {
+		Workers int         `json:"workers"`
+		Retry   RetryConfig `json:"retry"`
+	} `json:"processing"`
+	Hooks   []HookConfig   `json:"hooks"`
+	Exports []ExportConfig `json:"exports"`
+	Outbox  struct {
+		SweepInterval Duration `json:"sweepInterval"`
+		MaxAttempts   int      `json:"maxAttempts"`
+		Retention     Duration `json:"retention"`
+	} `json:"outbox"`
+	SLA     struct {
+		Active     bool      `json:"active"`
+		WarnBefore Duration  `json:"warnBefore"`
+		Rules      []SLARule `json:"rules"`
+	} `json:"sla"`
+	Routing struct {
+		Regions map[string][]string `json:"regions"`
+		Rules   []RoutingRule       `json:"rules"`
+		Default string              `json:"default"`
+	} `json:"routing"`
+	Windows struct {
+		LeadTimes []LeadTimeRule `json:"leadTimes"`
+	} `json:"windows"`
+	Catalog struct {
+		CasePacks string `json:"casePacks"`
+	} `json:"catalog"`
+	Printing struct {
+		Printers []PrinterRule `json:"printers"`
+		Copies   int           `json:"copies"`
+		Timeout  Duration      `json:"timeout"`
+	} `json:"printing"`
+	Documents struct {
+		PDF                   bool   `json:"pdf"`
+		PurchaseOrderTemplate string `json:"purchaseOrderTemplate"`
+		PackingSlipTemplate   string `json:"packingSlipTemplate"`
+		Email                 struct {
+			Host       string              `json:"host"`
+			Port       int                 `json:"port"`
+			Username   string              `json:"username"`
+			Password   string              `json:"password"`
+			From       string              `json:"from"`
+			Recipients []DocumentRecipient `json:"recipients"`
+		} `json:"email"`
+	} `json:"documents"`
+	Summary struct {
+		Active bool `json:"active"`
+	} `json:"summary"`
+	Daemon struct {
+		Workers  int                  `json:"workers"`
+		Jobs     map[string]JobConfig `json:"jobs"`
+		Profiles []string             `json:"profiles"`
+	} `json:"daemon"`
+	Lock struct {
+		Backend       string   `json:"backend"`
+		Dir           string   `json:"dir"`
+		RedisAddr     string   `json:"redisAddr"`
+		RedisPassword string   `json:"redisPassword"`
+		TTL           Duration `json:"ttl"`
+	} `json:"lock"`
+	Cleanup struct {
+		Active bool     `json:"active"`
+		MaxAge Duration `json:"maxAge"`
+	} `json:"cleanup"`
+	Server struct {
+		Addr    string        `json:"addr"`
+		Socket  string        `json:"socket"`
+		Webhook WebhookConfig `json:"webhook"`
+		Auth    AuthConfig    `json:"auth"`
+	} `json:"server"`
+	Tracing struct {
+		Endpoint    string            `json:"endpoint"`
+		ServiceName string            `json:"serviceName"`
+		Headers     map[string]string `json:"headers"`
+	} `json:"tracing"`
+	Logging struct {
+		Sinks []LogSinkConfig `json:"sinks"`
+	} `json:"logging"`
+	Metrics struct {
+		Push      string `json:"push"`
+		Namespace string `json:"namespace"`
+		Addr      string `json:"addr"`
+		Region    string `json:"region"`
+		Profile   string `json:"profile"`
+		RoleARN   string `json:"roleArn"`
+	} `json:"metrics"`
+	Escalation struct {
+		Provider   string `json:"provider"`
+		After      int    `json:"after"`
+		RoutingKey string `json:"routingKey"`
+		APIKey     string `json:"apiKey"`
+		URL        string `json:"url"`
+	} `json:"escalation"`
+	Profiles map[string]ConfigOverride `json:"profiles"`
+	Profile  string                    `json:"-"`
+}
+
+/*
+JobConfig holds the schedule for a single flow.
+
+Fields:
+  - Interval:      Time between runs (e.g. "15m").
+  - MaxConcurrent: How many runs of this flow may overlap.
+  - Retry:         Retry policy for each run.
+*/
+type JobConfig struct {
+	Interval      Duration    `json:"interval"`
+	MaxConcurrent int         `json:"maxConcurrent"`
+	Retry         RetryConfig `json:"retry"`
+}
+
+/*
+RetryConfig is a retry policy as written in the config file.
+
+Fields:
+  - MaxAttempts:      Total attempts.
+  - BaseDelay:        Delay before the first retry, doubled on each retry.
+  - MaxDelay:         Upper bound for the retry delay.
+  - Jitter:           Fraction of each delay that is randomized, between 0 and 1 (e.g. 0.2).
+  - RetryOn:          Error classes to retry ("network", "throttle", "server", "client", "other"); empty retries everything but client errors.
+  - BreakerThreshold: Consecutive failures that open the service's circuit breaker (0 disables it).
+  - BreakerCooldown:  How long an open breaker rejects calls before probing again.
+*/
+type RetryConfig struct {
+	MaxAttempts      int      `json:"maxAttempts"`
+	BaseDelay        Duration `json:"baseDelay"`
+	MaxDelay         Duration `json:"maxDelay"`
+	Jitter           float64  `json:"jitter,omitempty"`
+	RetryOn          []string `json:"retryOn,omitempty"`
+	BreakerThreshold int      `json:"breakerThreshold,omitempty"`
+	BreakerCooldown  Duration `json:"breakerCooldown,omitempty"`
+}
+
+/*
+Policy converts rc into a utils.RetryPolicy named name. Policies with a
+breaker threshold share the breaker for name in breakers (none if nil).
+*/
+func (rc RetryConfig) Policy(name string, breakers *utils.Breakers) utils.RetryPolicy {
+	p := utils.RetryPolicy{
+		Name:        name,
+		MaxAttempts: rc.MaxAttempts,
+		BaseDelay:   time.Duration(rc.BaseDelay),
+		MaxDelay:    time.Duration(rc.MaxDelay),
+		Jitter:      rc.Jitter,
+	}
+	if len(rc.RetryOn) > 0 {
+		p.Retryable = utils.RetryOn(rc.RetryOn...)
+	}
+	if rc.BreakerThreshold > 0 && breakers != nil {
+		p.Breaker = breakers.Get(name, rc.BreakerThreshold, time.Duration(rc.BreakerCooldown))
+	}
+	return p
+}
+
+// validate checks that rc only names known error classes and that its
+// jitter is a fraction.
+func (rc RetryConfig) validate(name string) error {
+	if rc.Jitter < 0 || rc.Jitter > 1 {
+		return fmt.Errorf("%s: jitter must be between 0 and 1, got %g", name, rc.Jitter)
+	}
+	for _, class := range rc.RetryOn {
+		switch class {
+		case utils.ClassNetwork, utils.ClassThrottle, utils.ClassServer, utils.ClassClient, utils.ClassOther:
+		default:
+			return fmt.Errorf("%s: unknown retryOn class %q", name, class)
+		}
+	}
+	return nil
+}
+
+/*
+ReportConfig describes one SP‑API report requested by the Reports flow. Each
+run covers the period since the previous run's data end time.
+
+Fields:
+  - ReportType: SP‑API report type, e.g. "GET_VENDOR_SALES_REPORT".
+  - Options:    Report options passed through unchanged (e.g. reportPeriod).
+  - Lookback:   Period covered by the first run, before any checkpoint exists (default: 24h).
+  - Extension:  File extension of stored reports (default: "json").
+*/
+type ReportConfig struct {
+	ReportType string            `json:"reportType"`
+	Options    map[string]string `json:"options"`
+	Lookback   Duration          `json:"lookback"`
+	Extension  string            `json:"extension"`
+}
+
+/*
+EDITemplate describes a custom outbound document rendered from a template file
+(see x12.Template for the notation and functions). The template is executed
+with the stored purchase order as .Order, its number as .PONumber and Vars as
+.Vars; a ship notice built with `edi build --shipments` gets the shipment as
+.Shipment instead of an order. The envelope and control numbers are added by
+the importer.
+
+Fields:
+  - File:              Template file.
+  - SetID:             Transaction set identifier (ST01), e.g. "855".
+  - FunctionalID:      Functional identifier code (GS01), e.g. "PR".
+  - ReceiverID:        Partner's interchange ID (ISA08); also selects partner X12 overrides.
+  - ReceiverQualifier: ISA07 (default: "ZZ").
+  - SenderQualifier:   ISA05 (default: "ZZ"); the sender ID is edi.senderId.
+  - Vars:              Free-form values available to the template.
+*/
+type EDITemplate struct {
+	File              string            `json:"file"`
+	SetID             string            `json:"setId"`
+	FunctionalID      string            `json:"functionalId"`
+	ReceiverID        string            `json:"receiverId"`
+	ReceiverQualifier string            `json:"receiverQualifier"`
+	SenderQualifier   string            `json:"senderQualifier"`
+	Vars              map[string]string `json:"vars"`
+}
+
+/*
+SFTPPrompt answers the keyboard-interactive prompts of the SFTP server that
+match Prompt. Prompts are tried in order; the first match answers.
+
+Fields:
+  - Prompt:     Case-insensitive regular expression matched against the server's question (empty matches any).
+  - Source:     "env" (the value of Env), "totp" (the current code of TOTPSecret) or "terminal" (asked interactively).
+  - Env:        Environment variable holding the answer, read at login.
+  - TOTPSecret: Base32 secret of the account's authenticator enrollment.
+*/
+type SFTPPrompt struct {
+	Prompt     string `json:"prompt"`
+	Source     string `json:"source"`
+	Env        string `json:"env"`
+	TOTPSecret string `json:"totpSecret"`
+}
+
+/*
+HookConfig describes an external command run for each file. The command gets
+the file's path and metadata as JSON on stdin and as AVC_* environment
+variables, and may rewrite the file in place.
+
+Fields:
+  - Name:     Name used in logs.
+  - Command:  Program and arguments, e.g. ["clamscan", "--no-summary"].
+  - Events:   "downloaded", "generated", "changed", "sla" and/or "throttled" (default: downloaded and generated).
+  - Timeout:  Kill the command after this long (default: 1m).
+  - Required: Reject the file when the command fails instead of only logging it.
+*/
+type HookConfig struct {
+	Name     string   `json:"name"`
+	Command  []string `json:"command"`
+	Events   []string `json:"events"`
+	Timeout  Duration `json:"timeout"`
+	Required bool     `json:"required"`
+}
+
+/*
+ExportConfig describes a destination generated documents are sent to. URL,
+header values and Body are Go templates over the event and the document
+(e.g. {{.Event.Fields.poNumber}}, {{.Document.purchaseOrderNumber}}, {{json .Document}}
+or {{env "OMS_TOKEN"}}).
+
+Fields:
+  - Name:         Name used in logs.
+  - Type:         Exporter type: "rest", "netsuite" (sales orders) or "quickbooks" (invoices or estimates).
+  - Documents:    Document types to send (default: ["order"]).
+  - URL:          Target URL (rest only).
+  - Method:       HTTP method (default: POST).
+  - Headers:      Extra request headers.
+  - Body:         Request body; empty sends the document unchanged.
+  - ContentType:  Content-Type of the body (default: application/json).
+  - Auth:         Authentication of the requests.
+  - Retry:        Retry policy (default: 3 attempts retrying network, throttle and server errors).
+  - Timeout:      Limit per request (default: 30s).
+  - SuccessCodes: Status codes that mean the document was accepted (default: any 2xx).
+  - IgnoreCodes:  Status codes logged and treated as delivered, e.g. 409 for an order the target already has.
+  - Required:     Fail the run when an export fails instead of only logging it.
+  - OrderBy:      Deliver the documents of each SP‑API response or X12 interchange in "poNumber" or
+    "timestamp" (order date) order once all are processed, instead of as each finishes (default: unordered).
+  - SKUMap:       CSV file with the columns sku (vendor SKU or ASIN) and item (item ID in the target system).
+  - NetSuite:     Account, credentials and mapping of a "netsuite" export.
+  - QuickBooks:   Company, app credentials and mapping of a "quickbooks" export.
+*/
+type ExportConfig struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Documents    []string          `json:"documents"`
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	ContentType  string            `json:"contentType"`
+	Auth         ExportAuth        `json:"auth"`
+	Retry        RetryConfig       `json:"retry"`
+	Timeout      Duration          `json:"timeout"`
+	SuccessCodes []int             `json:"successCodes"`
+	IgnoreCodes  []int             `json:"ignoreCodes"`
+	Required     bool              `json:"required"`
+	OrderBy      string            `json:"orderBy"`
+	SKUMap       string            `json:"skuMap"`
+	NetSuite     NetSuiteConfig    `json:"netsuite"`
+	QuickBooks   QuickBooksConfig  `json:"quickbooks"`
+}
+
+/*
+NetSuiteConfig connects a "netsuite" export to a NetSuite account using
+token-based authentication.
+
+Fields:
+  - AccountID:       Account ID, e.g. "1234567" or "1234567_SB1" for a sandbox.
+  - BaseURL:         REST endpoint override (default: derived from the account ID).
+  - ConsumerKey:     Consumer key of the integration record.
+  - ConsumerSecret:  Consumer secret of the integration record.
+  - TokenID:         Access token ID.
+  - TokenSecret:     Access token secret.
+  - Customers:       Customer internal IDs keyed by Amazon party ID (ship-to party, then buying party).
+  - DefaultCustomer: Customer of orders whose parties are not listed.
+  - Location:        Location internal ID set on every sales order.
+  - Currencies:      Currency internal IDs keyed by ISO code, for multi-currency accounts.
+*/
+type NetSuiteConfig struct {
+	AccountID       string            `json:"accountId"`
+	BaseURL         string            `json:"baseUrl"`
+	ConsumerKey     string            `json:"consumerKey"`
+	ConsumerSecret  string            `json:"consumerSecret"`
+	TokenID         string            `json:"tokenId"`
+	TokenSecret     string            `json:"tokenSecret"`
+	Customers       map[string]string `json:"customers"`
+	DefaultCustomer string            `json:"defaultCustomer"`
+	Location        string            `json:"location"`
+	Currencies      map[string]string `json:"currencies"`
+}
+
+/*
+QuickBooksConfig connects a "quickbooks" export to a QuickBooks Online
+company. QuickBooks replaces the refresh token on every refresh; the current
+one is kept in storage.credentialsPath, so RefreshToken only needs to be set
+when the app is (re-)authorized.
+
+Fields:
+  - RealmID:         Company ID.
+  - Sandbox:         Use the sandbox API.
+  - ClientID:        OAuth2 client ID of the app.
+  - ClientSecret:    OAuth2 client secret of the app.
+  - RefreshToken:    Refresh token from authorizing the app.
+  - Document:        "invoice" (default) or "estimate".
+  - Customers:       Customer IDs keyed by Amazon party ID (ship-to party, then buying party).
+  - DefaultCustomer: Customer of orders whose parties are not listed.
+*/
+type QuickBooksConfig struct {
+	RealmID         string            `json:"realmId"`
+	Sandbox         bool              `json:"sandbox"`
+	ClientID        string            `json:"clientId"`
+	ClientSecret    string            `json:"clientSecret"`
+	RefreshToken    string            `json:"refreshToken"`
+	Document        string            `json:"document"`
+	Customers       map[string]string `json:"customers"`
+	DefaultCustomer string            `json:"defaultCustomer"`
+}
+
+/*
+ExportAuth is the authentication of an export destination.
+
+Fields:
+  - Type:         "" (none), "oauth2" (client credentials grant) or "apiKey".
+  - TokenURL:     OAuth2 token endpoint.
+  - ClientID:     OAuth2 client ID.
+  - ClientSecret: OAuth2 client secret.
+  - Scopes:       OAuth2 scopes.
+  - Header:       Header carrying the API key (default: "X-API-Key").
+  - Key:          The API key.
+*/
+type ExportAuth struct {
+	Type         string   `json:"type"`
+	TokenURL     string   `json:"tokenUrl"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Scopes       []string `json:"scopes"`
+	Header       string   `json:"header"`
+	Key          string   `json:"key"`
+}
+
+/*
+LogSinkConfig forwards log messages to a log aggregation service.
+
+Fields:
+  - Type:       "cloudwatch" (CloudWatch Logs) or "syslog" (RFC 5424).
+  - Level:      Most detailed level forwarded: "error", "warn", "info" (default) or "debug".
+  - CloudWatch: Destination of a "cloudwatch" sink.
+      - Group:         Log group, created if missing.
+      - Stream:        Log stream, created if missing (default: the host name).
+      - Region:        AWS region (default: api.sigv4.region).
+      - Profile:       Shared credentials profile; empty uses the default AWS credential chain.
+      - RoleARN:       Role to assume for the calls, if any.
+      - FlushInterval: How often buffered messages are sent (default: 5s).
+  - Syslog:     Destination of a "syslog" sink.
+      - Network:       "udp" (default) or "tcp".
+      - Addr:          Server address (host:port).
+      - Facility:      Facility name, e.g. "daemon" or "local0" (default: "user").
+      - AppName:       APP-NAME of the messages (default: "avcimporter").
+*/
+type LogSinkConfig struct {
+	Type       string `json:"type"`
+	Level      string `json:"level"`
+	CloudWatch struct {
+		Group         string   `json:"group"`
+		Stream        string   `json:"stream"`
+		Region        string   `json:"region"`
+		Profile       string   `json:"profile"`
+		RoleARN       string   `json:"roleArn"`
+		FlushInterval Duration `json:"flushInterval"`
+	} `json:"cloudWatch"`
+	Syslog struct {
+		Network  string `json:"network"`
+		Addr     string `json:"addr"`
+		Facility string `json:"facility"`
+		AppName  string `json:"appName"`
+	} `json:"syslog"`
+}
+
+/*
+SLARule is a deadline by which a purchase order needs a document. The deadline
+is the earlier of Within after the order was received and the order window
+boundary named by Before; at least one must be set. Window boundaries are only
+known for orders imported through SP‑API.
+
+Fields:
+  - Name:     Rule name used in alerts, e.g. "acknowledge".
+  - Document: Document kind that satisfies the rule: "ack", "asn" or "invoice".
+  - Within:   Time allowed after the order was received.
+  - Before:   "shipWindowStart", "shipWindowEnd", "deliveryWindowStart" or "deliveryWindowEnd".
+*/
+type SLARule struct {
+	Name     string   `json:"name"`
+	Document string   `json:"document"`
+	Within   Duration `json:"within"`
+	Before   string   `json:"before"`
+}
+
+/*
+RoutingRule routes the order lines it matches to a warehouse. Every condition
+set must match; a rule without conditions matches every line.
+
+Fields:
+  - Warehouse: Ship-from party ID of the warehouse.
+  - ShipTo:    Ship-to party IDs (Amazon fulfillment center codes).
+  - Regions:   Names of routing.regions.
+  - SKUs:      Vendor SKUs or ASINs the warehouse stocks.
+*/
+type RoutingRule struct {
+	Warehouse string   `json:"warehouse"`
+	ShipTo    []string `json:"shipTo"`
+	Regions   []string `json:"regions"`
+	SKUs      []string `json:"skus"`
+}
+
+/*
+LeadTimeRule is how long a warehouse takes to ship an order and the carrier
+to deliver it, used to warn about acknowledgements committing to a window the
+order cannot meet.
+
+Fields:
+  - Warehouse: Ship-from party ID of the warehouse (see routing; without it the order's selling party); empty matches any.
+  - ShipTo:    Ship-to party IDs (Amazon fulfillment centers); empty matches any.
+  - Handling:  Time from acceptance until the order can ship.
+  - Transit:   Time from shipping until delivery.
+  - ShipDays:  Weekdays the warehouse ships on ("Mon" to "Sun", in the configured timezone); empty ships every day.
+*/
+type LeadTimeRule struct {
+	Warehouse string   `json:"warehouse"`
+	ShipTo    []string `json:"shipTo"`
+	Handling  Duration `json:"handling"`
+	Transit   Duration `json:"transit"`
+	ShipDays  []string `json:"shipDays"`
+}
+
+/*
+PrinterRule names the printers a warehouse prints shipment documents on.
+Printers are addressed by URL: raw://host:9100 for a raw socket (ZPL label
+printers), ipp://host/printers/queue or ipps:// for IPP printers and CUPS.
+
+Fields:
+  - Warehouse:    Ship-from party ID of the warehouse; empty matches any.
+  - Labels:       Printer of the ZPL shipment labels (empty: none).
+  - PackingSlips: Printer of the plain text packing slips (empty: none).
+*/
+type PrinterRule struct {
+	Warehouse    string `json:"warehouse"`
+	Labels       string `json:"labels"`
+	PackingSlips string `json:"packingSlips"`
+}
+
+/*
+DocumentRecipient is who gets the order documents of a warehouse by email.
+
+Fields:
+  - Warehouse: Ship-from party ID of the warehouse (see routing); empty matches any.
+  - To:        Email addresses.
+*/
+type DocumentRecipient struct {
+	Warehouse string   `json:"warehouse"`
+	To        []string `json:"to"`
+}
+
+/*
+WebhookConfig enables the shipment webhook of the daemon: callbacks from a WMS
+carrying shipped lines in the sync-status format, signed with HMAC-SHA256 (see
+webhook.Sign), from which shipment confirmations are submitted. Every callback
+must carry a signed timestamp within the tolerance, and a signature is only
+accepted once, so a captured callback cannot be replayed. Accepted shipments
+are recorded in webhook-outbox.json next to storage.outboxPath before the
+callback is answered, and submitted from there.
+
+Fields:
+  - Secret:          Shared HMAC secret; empty disables the webhook.
+  - SignatureHeader: Header carrying the signature (default: "X-Signature-256").
+  - TimestampHeader: Header carrying the Unix signing time, signed with the body (default: "X-Timestamp").
+  - Tolerance:       How old a signed timestamp may be (default: 5m).
+  - QueueSize:       Shipments waiting for submission before further callbacks are refused with 503 (default: 100).
+*/
+type WebhookConfig struct {
+	Secret          string   `json:"secret"`
+	SignatureHeader string   `json:"signatureHeader"`
+	TimestampHeader string   `json:"timestampHeader"`
+	Tolerance       Duration `json:"tolerance"`
+	QueueSize       int      `json:"queueSize"`
+}
+
+/*
+AuthConfig protects the daemon's HTTP endpoints on server.addr. Each caller
+gets a role: "read" (status, metrics, GraphQL), "trigger" (also POST /trigger)
+or "admin" (also POST /reload and checkpoint resets). /healthz, /readyz and the
+signed shipment webhook stay open; the owner-only server.socket is never
+authenticated. Without keys or an OIDC issuer the daemon only starts if
+server.addr is a loopback address, where every caller is admitted.
+
+Fields:
+  - Keys: Static API keys, sent as X-API-Key or as a bearer token.
+  - OIDC: OpenID Connect provider whose bearer tokens are accepted (see OIDCConfig).
+*/
+type AuthConfig struct {
+	Keys []APIKeyConfig `json:"keys"`
+	OIDC OIDCConfig     `json:"oidc"`
+}
+
+/*
+APIKeyConfig is one static API key.
+
+Fields:
+  - Name: Shown in logs instead of the key.
+  - Key:  The secret the caller sends.
+  - Role: "read", "trigger" or "admin".
+*/
+type APIKeyConfig struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role string `json:"role"`
+}
+
+/*
+OIDCConfig accepts bearer tokens (RS256 or ES256 JWTs) of an OpenID Connect
+provider, verified with the keys of its discovery document.
+
+Fields:
+  - Issuer:      Issuer URL, the expected "iss" claim; empty disables OIDC.
+  - Audience:    Expected "aud" claim (e.g. the client ID); empty accepts any.
+  - RoleClaim:   Claim listing the caller's groups or roles (default: "roles").
+  - Roles:       Claim values mapped to "read", "trigger" or "admin"; the highest match applies.
+  - DefaultRole: Role of valid tokens without a mapped claim value; empty refuses them.
+*/
+type OIDCConfig struct {
+	Issuer      string            `json:"issuer"`
+	Audience    string            `json:"audience"`
+	RoleClaim   string            `json:"roleClaim"`
+	Roles       map[string]string `json:"roles"`
+	DefaultRole string            `json:"defaultRole"`
+}
+
+// weekdays maps the ShipDays names to weekdays.
+var weekdays = map[string]time.Weekday{"Sun": time.Sunday, "Mon": time.Monday, "Tue": time.Tuesday, "Wed": time.Wednesday, "Thu": time.Thursday, "Fri": time.Friday, "Sat": time.Saturday}
+
+/*
+Weekdays returns the ship days of the rule, skipping unknown names.
+*/
+func (r LeadTimeRule) Weekdays() []time.Weekday {
+	var days []time.Weekday
+	for _, name := range r.ShipDays {
+		if d, ok := weekdays[name]; ok {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+/*
+DataKioskQuery describes one Data Kiosk GraphQL query. The query file is a Go
+text/template rendered on every run with the data window since the previous
+run: {{.StartDate}} and {{.EndDate}} (YYYY-MM-DD), {{.Start}} and {{.End}}
+(RFC 3339).
+
+Fields:
+  - Name:     Unique name used in checkpoints and file names.
+  - File:     Path of the GraphQL query file.
+  - Lookback: Period covered by the first run, before any checkpoint exists (default: 7 days).
+*/
+type DataKioskQuery struct {
+	Name     string   `json:"name"`
+	File     string   `json:"file"`
+	Lookback Duration `json:"lookback"`
+}
+
+/*
+X12Config holds the delimiters and version used when writing X12 documents
+and the decimal convention of a partner's amounts. Empty fields fall back to
+the next level: partner, then edi.x12, then Amazon's defaults.
+
+Fields:
+  - Version:             Interchange version (ISA12): "00400", "00401", "00501", ...
+  - ElementSeparator:    Element separator, e.g. "*".
+  - RepetitionSeparator: Repetition separator, written for 00402 and later, e.g. "^".
+  - ComponentSeparator:  Component separator (ISA16), e.g. ">".
+  - SegmentTerminator:   Segment terminator, e.g. "~".
+  - DecimalSeparator:    "." or "," in amounts the partner sends and templates write for it; inbound amounts are read by their look when unset.
+*/
+type X12Config struct {
+	Version             string `json:"version"`
+	ElementSeparator    string `json:"elementSeparator"`
+	RepetitionSeparator string `json:"repetitionSeparator"`
+	ComponentSeparator  string `json:"componentSeparator"`
+	SegmentTerminator   string `json:"segmentTerminator"`
+	DecimalSeparator    string `json:"decimalSeparator"`
+}
+
+/*
+Location returns the time zone named by cfg.Timezone (UTC when empty).
+*/
+func (cfg *Config) Location() (*time.Location, error) {
+	if cfg.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
+	}
+	return loc, nil
+}
+
+/*
+X12Options resolves the outbound X12 options for a trading partner.
+
+Parameters:
+  - partnerID: The partner's ISA ID (e.g. "AMAZON"); surrounding padding is ignored.
+
+Returns:
+  - The merged options, with edi.usage as the usage indicator.
+  - An error if a separator is not a single character or the result is invalid.
+*/
+func (cfg *Config) X12Options(partnerID string) (x12.Options, error) {
+	opts := x12.DefaultOptions
+	opts.Usage = cfg.EDI.Usage
+	loc, err := cfg.Location()
+	if err != nil {
+		return x12.Options{}, err
+	}
+	opts.Location = loc
+	levels := []X12Config{cfg.EDI.X12}
+	if p, ok := cfg.EDI.Partners[strings.TrimSpace(partnerID)]; ok {
+		levels = append(levels, p)
+	}
+	for _, c := range levels {
+		if c.Version != "" {
+			opts.Version = c.Version
+		}
+		for _, f := range []struct {
+			name  string
+			value string
+			dst   *byte
+		}{
+			{"elementSeparator", c.ElementSeparator, &opts.Delimiters.Element},
+			{"repetitionSeparator", c.RepetitionSeparator, &opts.Delimiters.Repetition},
+			{"componentSeparator", c.ComponentSeparator, &opts.Delimiters.Component},
+			{"segmentTerminator", c.SegmentTerminator, &opts.Delimiters.Segment},
+			{"decimalSeparator", c.DecimalSeparator, &opts.Decimal},
+		} {
+			if f.value == "" {
+				continue
+			}
+			if len(f.value) != 1 {
+				return x12.Options{}, fmt.Errorf("%s must be a single character, got %q", f.name, f.value)
+			}
+			*f.dst = f.value[0]
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return x12.Options{}, fmt.Errorf("invalid X12 settings for partner %q: %w", partnerID, err)
+	}
+	return opts, nil
+}
+
+/*
+Duration is a time.Duration that unmarshals from a JSON string such as "15m" or "1h30m".
+*/
+type Duration time.Duration
+
+/*
+UnmarshalJSON parses a duration string using time.ParseDuration.
+*/
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+/*
+MarshalJSON renders the duration in time.Duration string form.
+*/
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 /*
@@ -94,21 +1090,48 @@ type ConfigOverride struct {
 		BaseURL     *string `json:"baseUrl"`
 		TokenURL    *string `json:"tokenUrl"`
 		EndpointURL *string `json:"endpointUrl"`
+		Query       map[string]string `json:"query"`
+		SigV4       *struct {
+			Enabled    *bool   `json:"enabled"`
+			Region     *string `json:"region"`
+			Profile    *string `json:"profile"`
+			RoleARN    *string `json:"roleArn"`
+			ExternalID *string `json:"externalId"`
+		} `json:"sigv4"`
 	} `json:"api"`
 	EDI *struct {
-		Host           *string `json:"host"`
-		Port           *int    `json:"port"`
-		Username       *string `json:"username"`
-		PrivateKeyPath *string `json:"privateKeyPath"`
-		InboundDir     *string `json:"inboundDir"`
-		OutboundDir    *string `json:"outboundDir"`
-		SenderID       *string `json:"senderId"`
+		Host            *string `json:"host"`
+		Port            *int    `json:"port"`
+		Username        *string `json:"username"`
+		PrivateKeyPath  *string `json:"privateKeyPath"`
+		PrivateKey      *string `json:"privateKey"`
+		PrivateKeyPassphrase *string `json:"privateKeyPassphrase"`
+		InboundDir      *string `json:"inboundDir"`
+		OutboundDir     *string `json:"outboundDir"`
+		SenderID        *string `json:"senderId"`
+		Usage           *string `json:"usage"`
+		LocalInboundDir *string `json:"localInboundDir"`
 	} `json:"edi"`
 	Storage *struct {
-		OutputFormat *string `json:"outputFormat"`
-		SavePath     *string `json:"savePath"`
-		FileName     *string `json:"fileName"`
+		OutputFormat    *string `json:"outputFormat"`
+		SavePath        *string `json:"savePath"`
+		FileName        *string `json:"fileName"`
+		CheckpointPath  *string `json:"checkpointPath"`
+		ArchivePath     *string `json:"archivePath"`
+		LifecyclePath   *string `json:"lifecyclePath"`
+		DedupePath      *string `json:"dedupePath"`
+		HistoryPath     *string `json:"historyPath"`
+		OutboxPath      *string `json:"outboxPath"`
+		InboundIndex    *string `json:"inboundIndex"`
+		StagingPath     *string `json:"stagingPath"`
+		CredentialsPath *string `json:"credentialsPath"`
 	} `json:"storage"`
+	Audit *struct {
+		Path *string `json:"path"`
+	} `json:"audit"`
+	Daemon *struct {
+		Workers *int `json:"workers"`
+	} `json:"daemon"`
 }
 
 func (cfg *Config) ApplyDefaults() {
@@ -124,40 +1147,586 @@ func (cfg *Config) ApplyDefaults() {
 	if cfg.Storage.OutputFormat == "" {
 		cfg.Storage.OutputFormat = "json"
 	}
+	if cfg.Storage.Permissions.FileMode == "" {
+		cfg.Storage.Permissions.FileMode = "0644"
+	}
+	if cfg.Storage.Permissions.DirMode == "" {
+		cfg.Storage.Permissions.DirMode = "0755"
+	}
+	if cfg.EDI.Usage == "" {
+		cfg.EDI.Usage = "T"
+	}
+	if cfg.EDI.StableFor == 0 {
+		cfg.EDI.StableFor = Duration(10 * time.Second)
+	}
 	if cfg.Storage.SavePath == "" {
 		cfg.Storage.SavePath = "output/"
 	}
 	if cfg.Storage.FileName == "" {
 		cfg.Storage.FileName = "data_dump"
 	}
+	if cfg.Storage.CheckpointPath == "" {
+		cfg.Storage.CheckpointPath = filepath.Join(cfg.Storage.SavePath, "checkpoints.json")
+	}
+	if cfg.Storage.ArchivePath == "" {
+		cfg.Storage.ArchivePath = filepath.Join(cfg.Storage.SavePath, "archive")
+	}
+	if cfg.Storage.LifecyclePath == "" {
+		cfg.Storage.LifecyclePath = filepath.Join(cfg.Storage.SavePath, "lifecycle.json")
+	}
+	if cfg.Storage.DedupePath == "" {
+		cfg.Storage.DedupePath = filepath.Join(cfg.Storage.SavePath, "dedupe.json")
+	}
+	if cfg.Storage.CredentialsPath == "" {
+		cfg.Storage.CredentialsPath = filepath.Join(cfg.Storage.SavePath, "credentials.json")
+	}
+	if cfg.Storage.DedupeRetention == 0 {
+		cfg.Storage.DedupeRetention = Duration(90 * 24 * time.Hour)
+	}
+	if cfg.Storage.HistoryPath == "" {
+		cfg.Storage.HistoryPath = filepath.Join(cfg.Storage.SavePath, "history.jsonl")
+	}
+	if cfg.Storage.HistoryMaxAge == 0 {
+		cfg.Storage.HistoryMaxAge = Duration(90 * 24 * time.Hour)
+	}
+	if cfg.Storage.OutboxPath == "" {
+		cfg.Storage.OutboxPath = filepath.Join(cfg.Storage.SavePath, "outbox.json")
+	}
+	if cfg.Outbox.SweepInterval == 0 {
+		cfg.Outbox.SweepInterval = Duration(5 * time.Minute)
+	}
+	if cfg.Outbox.MaxAttempts == 0 {
+		cfg.Outbox.MaxAttempts = 20
+	}
+	if cfg.Outbox.Retention == 0 {
+		cfg.Outbox.Retention = Duration(90 * 24 * time.Hour)
+	}
+	if cfg.Reports.PollInterval == 0 {
+		cfg.Reports.PollInterval = Duration(30 * time.Second)
+	}
+	if cfg.Printing.Copies == 0 {
+		cfg.Printing.Copies = 1
+	}
+	if cfg.Printing.Timeout == 0 {
+		cfg.Printing.Timeout = Duration(30 * time.Second)
+	}
+	if cfg.Documents.Email.Port == 0 {
+		cfg.Documents.Email.Port = 587
+	}
+	if cfg.Server.Webhook.SignatureHeader == "" {
+		cfg.Server.Webhook.SignatureHeader = "X-Signature-256"
+	}
+	if cfg.Server.Webhook.TimestampHeader == "" {
+		cfg.Server.Webhook.TimestampHeader = "X-Timestamp"
+	}
+	if cfg.Server.Webhook.Tolerance == 0 {
+		cfg.Server.Webhook.Tolerance = Duration(5 * time.Minute)
+	}
+	if cfg.Server.Webhook.QueueSize == 0 {
+		cfg.Server.Webhook.QueueSize = 100
+	}
+	if cfg.Server.Auth.OIDC.RoleClaim == "" {
+		cfg.Server.Auth.OIDC.RoleClaim = "roles"
+	}
+	if cfg.Reports.Timeout == 0 {
+		cfg.Reports.Timeout = Duration(30 * time.Minute)
+	}
+	for i := range cfg.Reports.Requests {
+		r := &cfg.Reports.Requests[i]
+		if r.Lookback == 0 {
+			r.Lookback = Duration(24 * time.Hour)
+		}
+		if r.Extension == "" {
+			r.Extension = "json"
+		}
+	}
+	for name, t := range cfg.EDI.Templates {
+		if t.ReceiverQualifier == "" {
+			t.ReceiverQualifier = "ZZ"
+		}
+		if t.SenderQualifier == "" {
+			t.SenderQualifier = "ZZ"
+		}
+		cfg.EDI.Templates[name] = t
+	}
+	for i := range cfg.Hooks {
+		if cfg.Hooks[i].Timeout == 0 {
+			cfg.Hooks[i].Timeout = Duration(time.Minute)
+		}
+	}
+	for i := range cfg.Exports {
+		e := &cfg.Exports[i]
+		if e.Timeout == 0 {
+			e.Timeout = Duration(30 * time.Second)
+		}
+		if e.Retry.MaxAttempts == 0 {
+			e.Retry = RetryConfig{MaxAttempts: 3, BaseDelay: Duration(2 * time.Second), MaxDelay: Duration(30 * time.Second), RetryOn: []string{utils.ClassNetwork, utils.ClassThrottle, utils.ClassServer}}
+		}
+	}
+	if cfg.SLA.WarnBefore == 0 {
+		cfg.SLA.WarnBefore = Duration(4 * time.Hour)
+	}
+	if cfg.DataKiosk.PollInterval == 0 {
+		cfg.DataKiosk.PollInterval = Duration(30 * time.Second)
+	}
+	if cfg.DataKiosk.Timeout == 0 {
+		cfg.DataKiosk.Timeout = Duration(time.Hour)
+	}
+	for i := range cfg.DataKiosk.Queries {
+		if cfg.DataKiosk.Queries[i].Lookback == 0 {
+			cfg.DataKiosk.Queries[i].Lookback = Duration(7 * 24 * time.Hour)
+		}
+	}
+	if cfg.Audit.MaxSizeMB == 0 {
+		cfg.Audit.MaxSizeMB = 100
+	}
+	for _, rc := range []*RetryConfig{&cfg.Retry.SPAPI, &cfg.Retry.SFTP} {
+		if rc.MaxAttempts == 0 {
+			rc.MaxAttempts = 3
+		}
+		if rc.BaseDelay == 0 {
+			rc.BaseDelay = Duration(2 * time.Second)
+		}
+		if rc.MaxDelay == 0 {
+			rc.MaxDelay = Duration(30 * time.Second)
+		}
+		if rc.BreakerThreshold > 0 && rc.BreakerCooldown == 0 {
+			rc.BreakerCooldown = Duration(time.Minute)
+		}
+	}
+	if cfg.Daemon.Workers == 0 {
+		cfg.Daemon.Workers = 2
+	}
+	if cfg.Processing.Workers == 0 {
+		cfg.Processing.Workers = 1
+	}
+	if cfg.Processing.Retry.MaxAttempts == 0 {
+		cfg.Processing.Retry = RetryConfig{MaxAttempts: 3, BaseDelay: Duration(time.Second), MaxDelay: Duration(30 * time.Second), RetryOn: []string{utils.ClassNetwork, utils.ClassThrottle, utils.ClassServer}}
+	}
+	if cfg.Timezone == "" {
+		cfg.Timezone = "UTC"
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "avcimporter"
+	}
+	if cfg.Lock.TTL == 0 {
+		cfg.Lock.TTL = Duration(5 * time.Minute)
+	}
+	if cfg.Cleanup.MaxAge == 0 {
+		cfg.Cleanup.MaxAge = Duration(24 * time.Hour)
+	}
+	for i := range cfg.Logging.Sinks {
+		s := &cfg.Logging.Sinks[i]
+		if s.Level == "" {
+			s.Level = "info"
+		}
+		if s.Type == "cloudwatch" && s.CloudWatch.Region == "" {
+			s.CloudWatch.Region = cfg.API.SigV4.Region
+		}
+		if s.Type == "syslog" && s.Syslog.Facility == "" {
+			s.Syslog.Facility = "user"
+		}
+	}
+	switch cfg.Metrics.Push {
+	case "cloudwatch":
+		if cfg.Metrics.Namespace == "" {
+			cfg.Metrics.Namespace = "AVCImporter"
+		}
+		if cfg.Metrics.Region == "" {
+			cfg.Metrics.Region = cfg.API.SigV4.Region
+		}
+	case "statsd", "dogstatsd":
+		if cfg.Metrics.Namespace == "" {
+			cfg.Metrics.Namespace = "avcimporter"
+		}
+		if cfg.Metrics.Addr == "" {
+			cfg.Metrics.Addr = "127.0.0.1:8125"
+		}
+	}
+	if cfg.Escalation.Provider != "" && cfg.Escalation.After == 0 {
+		cfg.Escalation.After = 3
+	}
 }
 
 /*
-Load reads configuration data from the specified filePath.
+Load reads configuration data from the specified filePath and then applies
+opts.Profile and the AVC_* variables of opts.Environ (see ApplyEnv): the
+variables take precedence over the profile, which takes precedence over the
+file. The profile may itself be defined by AVC_PROFILES. An empty filePath builds
+the configuration from the environment alone, e.g. in a container.
 
 Parameters:
-  - filePath: The path to the JSON configuration file.
+  - filePath: The path to the JSON configuration file, or "" for none.
+  - opts:     Strictness, profile, environment and logger.
 
 Returns:
-  - A Config pointer populated from the file and defaults.
-  - An error if the file is missing or invalid JSON.
+  - A Config pointer populated from the file, the environment, the selected profile and defaults.
+  - An error if the file is missing or invalid JSON, or, unless lenient, has unknown keys,
+    or an environment variable has an invalid value.
 */
-func Load(filePath string) (*Config, error) {
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file %s does not exist", filePath)
+func Load(filePath string, opts LoadOptions) (*Config, error) {
+	var cfg Config
+	if filePath == "" {
+		opts.Logger.log(utils.LevelInfo, "Loaded config from: ", "environment")
+	} else {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("config file %s does not exist", filePath)
+		}
+		opts.Logger.log(utils.LevelInfo, "Loaded config from: ", filePath)
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if !opts.Lenient {
+			if err := checkUnknownKeys(data); err != nil {
+				return nil, err
+			}
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
 	}
-	utils.PrintColored("Loaded config from: ", filePath, "#32CD32")
-	data, err := os.ReadFile(filePath)
+	unknown, err := ApplyEnv(&cfg, opts.Environ, opts.Lenient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+		return nil, err
 	}
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("invalid JSON: %w", err)
+	for _, name := range unknown {
+		opts.Logger.log(utils.LevelWarn, "Ignoring unknown environment variable: ", name)
+	}
+	if opts.Profile != "" {
+		p, ok := cfg.Profiles[opts.Profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", opts.Profile)
+		}
+		cfg.OverrideConfig(p)
+		cfg.Profile = opts.Profile
+		opts.Logger.log(utils.LevelInfo, "Using profile: ", opts.Profile)
+		// Apply the variables again so they also win over the profile.
+		if _, err := ApplyEnv(&cfg, opts.Environ, opts.Lenient); err != nil {
+			return nil, err
+		}
 	}
 	cfg.ApplyDefaults()
-	if Verbose {
-		utils.PrintNonEmptyFields("", cfg)
+	if _, err := cfg.Location(); err != nil {
+		return nil, err
+	}
+	if cfg.EDI.Usage != "P" && cfg.EDI.Usage != "T" {
+		return nil, fmt.Errorf("invalid edi.usage %q: expected \"P\" or \"T\"", cfg.EDI.Usage)
+	}
+	switch cfg.EDI.SortBy {
+	case "", "name", "mtime":
+	default:
+		return nil, fmt.Errorf("invalid edi.sortBy %q: expected \"name\" or \"mtime\"", cfg.EDI.SortBy)
+	}
+	if cfg.EDI.PrivateKey != "" {
+		key, err := utils.DecodeSSHKey(cfg.EDI.PrivateKey)
+		if err == nil {
+			_, err = utils.ParseSSHKey(key, []byte(cfg.EDI.PrivateKeyPassphrase))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid edi.privateKey: %w", err)
+		}
+	}
+	if cfg.EDI.MaxFiles < 0 {
+		return nil, fmt.Errorf("edi.maxFiles must not be negative")
+	}
+	if cfg.EDI.StableFor < 0 {
+		return nil, fmt.Errorf("edi.stableFor must not be negative")
+	}
+	for name, t := range cfg.EDI.Templates {
+		if t.File == "" || t.SetID == "" || t.FunctionalID == "" || t.ReceiverID == "" {
+			return nil, fmt.Errorf("edi template %s needs a file, setId, functionalId and receiverId", name)
+		}
+	}
+	for i, p := range cfg.EDI.KeyboardInteractive {
+		if _, err := regexp.Compile("(?i)" + p.Prompt); err != nil {
+			return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].prompt: %w", i, err)
+		}
+		switch p.Source {
+		case "env":
+			if p.Env == "" {
+				return nil, fmt.Errorf("edi.keyboardInteractive[%d] needs an env variable", i)
+			}
+		case "totp":
+			if _, err := totp.DecodeSecret(p.TOTPSecret); err != nil {
+				return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].totpSecret: %w", i, err)
+			}
+		case "terminal":
+		default:
+			return nil, fmt.Errorf("invalid edi.keyboardInteractive[%d].source %q: expected \"env\", \"totp\" or \"terminal\"", i, p.Source)
+		}
+	}
+	for i, h := range cfg.Hooks {
+		if h.Name == "" || len(h.Command) == 0 {
+			return nil, fmt.Errorf("hooks[%d] needs a name and a command", i)
+		}
+		for _, e := range h.Events {
+			if e != "downloaded" && e != "generated" && e != "changed" && e != "sla" && e != "throttled" {
+				return nil, fmt.Errorf("hook %s: unknown event %q", h.Name, e)
+			}
+		}
+	}
+	for i, e := range cfg.Exports {
+		if e.Name == "" {
+			return nil, fmt.Errorf("exports[%d] needs a name", i)
+		}
+		switch e.Type {
+		case "rest":
+			if e.URL == "" {
+				return nil, fmt.Errorf("export %s needs a url", e.Name)
+			}
+		case "netsuite":
+			if e.NetSuite.AccountID == "" || e.SKUMap == "" {
+				return nil, fmt.Errorf("export %s needs netsuite.accountId and a skuMap", e.Name)
+			}
+		case "quickbooks":
+			if e.QuickBooks.RealmID == "" || e.SKUMap == "" {
+				return nil, fmt.Errorf("export %s needs quickbooks.realmId and a skuMap", e.Name)
+			}
+			switch e.QuickBooks.Document {
+			case "", "invoice", "estimate":
+			default:
+				return nil, fmt.Errorf("export %s: invalid quickbooks.document %q", e.Name, e.QuickBooks.Document)
+			}
+		default:
+			return nil, fmt.Errorf("export %s: unknown type %q", e.Name, e.Type)
+		}
+		switch e.Auth.Type {
+		case "", "oauth2", "apiKey":
+		default:
+			return nil, fmt.Errorf("export %s: unknown auth type %q", e.Name, e.Auth.Type)
+		}
+		switch e.OrderBy {
+		case "", "poNumber", "timestamp":
+		default:
+			return nil, fmt.Errorf("export %s: invalid orderBy %q", e.Name, e.OrderBy)
+		}
+		if err := e.Retry.validate("export " + e.Name + " retry"); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range cfg.SLA.Rules {
+		if r.Name == "" || r.Document == "" {
+			return nil, fmt.Errorf("sla.rules[%d] needs a name and a document", i)
+		}
+		switch r.Before {
+		case "", "shipWindowStart", "shipWindowEnd", "deliveryWindowStart", "deliveryWindowEnd":
+		default:
+			return nil, fmt.Errorf("sla rule %s: invalid before %q", r.Name, r.Before)
+		}
+		if r.Within <= 0 && r.Before == "" {
+			return nil, fmt.Errorf("sla rule %s needs within or before", r.Name)
+		}
+	}
+	for i, r := range cfg.Routing.Rules {
+		if r.Warehouse == "" {
+			return nil, fmt.Errorf("routing.rules[%d] needs a warehouse", i)
+		}
+		for _, name := range r.Regions {
+			if _, ok := cfg.Routing.Regions[name]; !ok {
+				return nil, fmt.Errorf("routing.rules[%d]: unknown region %q", i, name)
+			}
+		}
+	}
+	for i, r := range cfg.Windows.LeadTimes {
+		if r.Handling < 0 || r.Transit < 0 {
+			return nil, fmt.Errorf("windows.leadTimes[%d]: handling and transit must not be negative", i)
+		}
+		for _, d := range r.ShipDays {
+			if _, ok := weekdays[d]; !ok {
+				return nil, fmt.Errorf("windows.leadTimes[%d]: invalid ship day %q (expected Mon to Sun)", i, d)
+			}
+		}
+	}
+	for i, p := range cfg.Printing.Printers {
+		for _, addr := range []string{p.Labels, p.PackingSlips} {
+			scheme, _, _ := strings.Cut(addr, "://")
+			switch {
+			case addr == "":
+			case scheme == "raw", scheme == "tcp", scheme == "ipp", scheme == "ipps", scheme == "http", scheme == "https":
+			default:
+				return nil, fmt.Errorf("printing.printers[%d]: invalid printer %q (expected raw://, ipp:// or ipps://)", i, addr)
+			}
+		}
+	}
+	if cfg.Printing.Copies < 0 || cfg.Printing.Timeout < 0 {
+		return nil, fmt.Errorf("printing.copies and printing.timeout must not be negative")
+	}
+	if e := cfg.Documents.Email; e.Host != "" {
+		if !cfg.Documents.PDF {
+			return nil, fmt.Errorf("documents.email needs documents.pdf")
+		}
+		if e.From == "" {
+			return nil, fmt.Errorf("documents.email.from is required")
+		}
+		for i, r := range e.Recipients {
+			if len(r.To) == 0 {
+				return nil, fmt.Errorf("documents.email.recipients[%d] needs an address", i)
+			}
+		}
+	}
+	if w := cfg.Server.Webhook; w.Secret != "" {
+		if cfg.Server.Addr == "" && cfg.Server.Socket == "" {
+			return nil, fmt.Errorf("server.webhook needs server.addr or server.socket")
+		}
+		if w.Tolerance < 0 || w.QueueSize < 0 {
+			return nil, fmt.Errorf("server.webhook.tolerance and queueSize must not be negative")
+		}
+	}
+	for i, k := range cfg.Server.Auth.Keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("server.auth.keys[%d].key is required", i)
+		}
+		if k.Role != "read" && k.Role != "trigger" && k.Role != "admin" {
+			return nil, fmt.Errorf("server.auth.keys[%d].role must be read, trigger or admin, got %q", i, k.Role)
+		}
+	}
+	if o := cfg.Server.Auth.OIDC; o.Issuer != "" {
+		if !strings.HasPrefix(o.Issuer, "https://") && !strings.HasPrefix(o.Issuer, "http://") {
+			return nil, fmt.Errorf("server.auth.oidc.issuer must be a URL, got %q", o.Issuer)
+		}
+		for v, r := range o.Roles {
+			if r != "read" && r != "trigger" && r != "admin" {
+				return nil, fmt.Errorf("server.auth.oidc.roles[%q] must be read, trigger or admin, got %q", v, r)
+			}
+		}
+		if r := o.DefaultRole; r != "" && r != "read" && r != "trigger" && r != "admin" {
+			return nil, fmt.Errorf("server.auth.oidc.defaultRole must be read, trigger or admin, got %q", r)
+		}
+	}
+	if s := cfg.API.SigV4; s.Enabled {
+		if s.RoleARN != "" && !strings.HasPrefix(s.RoleARN, "arn:") {
+			return nil, fmt.Errorf("api.sigv4.roleArn must be an ARN, got %q", s.RoleARN)
+		}
+		if s.ExternalID != "" && s.RoleARN == "" {
+			return nil, fmt.Errorf("api.sigv4.externalId needs a roleArn")
+		}
+	}
+	if t := cfg.API.Throttle; t.Rate < 0 || t.CooldownAfter < 0 || t.Cooldown < 0 || t.AlertAfter < 0 {
+		return nil, fmt.Errorf("api.throttle settings must not be negative")
+	}
+	for k := range cfg.API.Query {
+		if strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("api.query has an empty parameter name")
+		}
+	}
+	if cfg.Cleanup.MaxAge < 0 {
+		return nil, fmt.Errorf("cleanup.maxAge must not be negative")
+	}
+	if cfg.Storage.HistoryMaxAge < 0 {
+		return nil, fmt.Errorf("storage.historyMaxAge must not be negative")
+	}
+	if _, err := codec.Lookup(cfg.Storage.Compression); err != nil {
+		return nil, fmt.Errorf("invalid storage.compression: %w", err)
+	}
+	for name, mode := range map[string]string{"fileMode": cfg.Storage.Permissions.FileMode, "dirMode": cfg.Storage.Permissions.DirMode} {
+		if _, err := utils.ParseFileMode(mode); err != nil {
+			return nil, fmt.Errorf("invalid storage.permissions.%s: %w", name, err)
+		}
+	}
+	if e := cfg.Storage.Encryption; e.Key != "" {
+		if e.KeyFile != "" {
+			return nil, fmt.Errorf("storage.encryption: set either key or keyFile")
+		}
+		if _, err := codec.ParseKey(e.Key); err != nil {
+			return nil, fmt.Errorf("invalid storage.encryption.key: %w", err)
+		}
+	}
+	if o := cfg.Outbox; o.SweepInterval < 0 || o.MaxAttempts < 0 || o.Retention < 0 {
+		return nil, fmt.Errorf("outbox settings must not be negative")
+	}
+	switch cfg.Metrics.Push {
+	case "", "statsd", "dogstatsd":
+	case "cloudwatch":
+		if cfg.Metrics.Region == "" {
+			return nil, fmt.Errorf("metrics.region is required to push to CloudWatch")
+		}
+		if cfg.Metrics.RoleARN != "" && !strings.HasPrefix(cfg.Metrics.RoleARN, "arn:") {
+			return nil, fmt.Errorf("metrics.roleArn %q is not an ARN", cfg.Metrics.RoleARN)
+		}
+	default:
+		return nil, fmt.Errorf("invalid metrics.push %q: expected cloudwatch, statsd or dogstatsd", cfg.Metrics.Push)
+	}
+	switch e := cfg.Escalation; e.Provider {
+	case "":
+	case "pagerduty":
+		if e.RoutingKey == "" {
+			return nil, fmt.Errorf("escalation.routingKey is required for PagerDuty")
+		}
+	case "opsgenie":
+		if e.APIKey == "" {
+			return nil, fmt.Errorf("escalation.apiKey is required for Opsgenie")
+		}
+	default:
+		return nil, fmt.Errorf("invalid escalation.provider %q: expected pagerduty or opsgenie", e.Provider)
+	}
+	if cfg.Escalation.After < 0 {
+		return nil, fmt.Errorf("escalation.after must not be negative")
+	}
+	for i, s := range cfg.Logging.Sinks {
+		if _, err := utils.ParseLevel(s.Level); err != nil {
+			return nil, fmt.Errorf("logging.sinks[%d]: %w", i, err)
+		}
+		switch s.Type {
+		case "cloudwatch":
+			if s.CloudWatch.Group == "" || s.CloudWatch.Region == "" {
+				return nil, fmt.Errorf("logging.sinks[%d] needs cloudWatch.group and cloudWatch.region", i)
+			}
+			if s.CloudWatch.RoleARN != "" && !strings.HasPrefix(s.CloudWatch.RoleARN, "arn:") {
+				return nil, fmt.Errorf("logging.sinks[%d].cloudWatch.roleArn %q is not an ARN", i, s.CloudWatch.RoleARN)
+			}
+		case "syslog":
+			if s.Syslog.Addr == "" {
+				return nil, fmt.Errorf("logging.sinks[%d] needs syslog.addr", i)
+			}
+			if s.Syslog.Network != "" && s.Syslog.Network != "udp" && s.Syslog.Network != "tcp" {
+				return nil, fmt.Errorf("logging.sinks[%d].syslog.network %q: expected udp or tcp", i, s.Syslog.Network)
+			}
+			if !slices.Contains(syslogFacilities, s.Syslog.Facility) {
+				return nil, fmt.Errorf("logging.sinks[%d].syslog.facility %q is not a syslog facility", i, s.Syslog.Facility)
+			}
+		default:
+			return nil, fmt.Errorf("logging.sinks[%d] has unknown type %q: expected cloudwatch or syslog", i, s.Type)
+		}
+	}
+	if err := cfg.Retry.SPAPI.validate("retry.spapi"); err != nil {
+		return nil, err
+	}
+	if err := cfg.Retry.SFTP.validate("retry.sftp"); err != nil {
+		return nil, err
+	}
+	if cfg.Processing.Workers < 0 {
+		return nil, fmt.Errorf("processing.workers must not be negative")
+	}
+	if err := cfg.Processing.Retry.validate("processing.retry"); err != nil {
+		return nil, err
+	}
+	for name, jc := range cfg.Daemon.Jobs {
+		if err := jc.Retry.validate("daemon.jobs." + name + ".retry"); err != nil {
+			return nil, err
+		}
+	}
+	daemonProfiles := map[string]bool{}
+	for _, name := range cfg.Daemon.Profiles {
+		if _, ok := cfg.Profiles[name]; !ok {
+			return nil, fmt.Errorf("unknown profile %q in daemon.profiles", name)
+		}
+		if daemonProfiles[name] {
+			return nil, fmt.Errorf("duplicate profile %q in daemon.profiles", name)
+		}
+		daemonProfiles[name] = true
+	}
+	seen := map[string]bool{}
+	for _, q := range cfg.DataKiosk.Queries {
+		if q.Name == "" || q.File == "" {
+			return nil, fmt.Errorf("dataKiosk query needs a name and a file")
+		}
+		if seen[q.Name] {
+			return nil, fmt.Errorf("duplicate dataKiosk query name %q", q.Name)
+		}
+		seen[q.Name] = true
 	}
 	return &cfg, nil
 }
@@ -193,6 +1762,26 @@ func (cfg *Config) OverrideConfig(o ConfigOverride) {
 		if o.API.EndpointURL != nil {
 			cfg.API.EndpointURL = *o.API.EndpointURL
 		}
+		if o.API.Query != nil {
+			cfg.API.Query = o.API.Query
+		}
+		if s := o.API.SigV4; s != nil {
+			if s.Enabled != nil {
+				cfg.API.SigV4.Enabled = *s.Enabled
+			}
+			if s.Region != nil {
+				cfg.API.SigV4.Region = *s.Region
+			}
+			if s.Profile != nil {
+				cfg.API.SigV4.Profile = *s.Profile
+			}
+			if s.RoleARN != nil {
+				cfg.API.SigV4.RoleARN = *s.RoleARN
+			}
+			if s.ExternalID != nil {
+				cfg.API.SigV4.ExternalID = *s.ExternalID
+			}
+		}
 	}
 	if o.EDI != nil {
 		if o.EDI.Host != nil {
@@ -207,6 +1796,12 @@ func (cfg *Config) OverrideConfig(o ConfigOverride) {
 		if o.EDI.PrivateKeyPath != nil {
 			cfg.EDI.PrivateKeyPath = *o.EDI.PrivateKeyPath
 		}
+		if o.EDI.PrivateKey != nil {
+			cfg.EDI.PrivateKey = *o.EDI.PrivateKey
+		}
+		if o.EDI.PrivateKeyPassphrase != nil {
+			cfg.EDI.PrivateKeyPassphrase = *o.EDI.PrivateKeyPassphrase
+		}
 		if o.EDI.InboundDir != nil {
 			cfg.EDI.InboundDir = *o.EDI.InboundDir
 		}
@@ -216,6 +1811,12 @@ func (cfg *Config) OverrideConfig(o ConfigOverride) {
 		if o.EDI.SenderID != nil {
 			cfg.EDI.SenderID = *o.EDI.SenderID
 		}
+		if o.EDI.Usage != nil {
+			cfg.EDI.Usage = *o.EDI.Usage
+		}
+		if o.EDI.LocalInboundDir != nil {
+			cfg.EDI.LocalInboundDir = *o.EDI.LocalInboundDir
+		}
 	}
 	if o.Storage != nil {
 		if o.Storage.OutputFormat != nil {
@@ -227,5 +1828,42 @@ func (cfg *Config) OverrideConfig(o ConfigOverride) {
 		if o.Storage.FileName != nil {
 			cfg.Storage.FileName = *o.Storage.FileName
 		}
+		if o.Storage.CheckpointPath != nil {
+			cfg.Storage.CheckpointPath = *o.Storage.CheckpointPath
+		}
+		if o.Storage.ArchivePath != nil {
+			cfg.Storage.ArchivePath = *o.Storage.ArchivePath
+		}
+		if o.Storage.LifecyclePath != nil {
+			cfg.Storage.LifecyclePath = *o.Storage.LifecyclePath
+		}
+		if o.Storage.DedupePath != nil {
+			cfg.Storage.DedupePath = *o.Storage.DedupePath
+		}
+		if o.Storage.HistoryPath != nil {
+			cfg.Storage.HistoryPath = *o.Storage.HistoryPath
+		}
+		if o.Storage.OutboxPath != nil {
+			cfg.Storage.OutboxPath = *o.Storage.OutboxPath
+		}
+		if o.Storage.CredentialsPath != nil {
+			cfg.Storage.CredentialsPath = *o.Storage.CredentialsPath
+		}
+		if o.Storage.InboundIndex != nil {
+			cfg.Storage.InboundIndex = *o.Storage.InboundIndex
+		}
+		if o.Storage.StagingPath != nil {
+			cfg.Storage.StagingPath = *o.Storage.StagingPath
+		}
+	}
+	if o.Audit != nil {
+		if o.Audit.Path != nil {
+			cfg.Audit.Path = *o.Audit.Path
+		}
+	}
+	if o.Daemon != nil {
+		if o.Daemon.Workers != nil {
+			cfg.Daemon.Workers = *o.Daemon.Workers
+		}
 	}
 }
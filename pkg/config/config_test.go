@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// writeConfig writes data to a config file in a temporary directory and
+// returns its path.
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestValidate verifies the issues reported by Validate (and so by
+// validate-config) for a sound config, unknown keys and values of the wrong type.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []Issue
+	}{
+		{
+			name: "valid",
+			data: `{"version": "1.0", "edi": {"active": true, "port": 22}, "storage": {"savePath": "out"}, "daemon": {"jobs": {"edi": {"interval": "15m"}}}}`,
+		},
+		{
+			name: "unknown key",
+			data: `{"storage": {"savepath": "out", "fileNmae": "x"}}`,
+			expected: []Issue{
+				{Path: "storage.fileNmae", Message: `unknown key "fileNmae"; did you mean "fileName"?`},
+				{Path: "storage.savepath", Message: `unknown key "savepath"; did you mean "savePath"? (keys are case-sensitive)`},
+			},
+		},
+		{
+			name: "wrong type",
+			data: `{"edi": {"active": "yes", "port": "22"}, "daemon": {"jobs": {"edi": {"interval": "soon"}}}}`,
+			expected: []Issue{
+				{Path: "daemon.jobs.edi.interval", Message: `invalid duration "soon"`},
+				{Path: "edi.active", Message: "expected true or false, got a string"},
+				{Path: "edi.port", Message: "expected an integer, got a string"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		issues, err := Validate([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: Validate() returned %v", tt.name, err)
+			continue
+		}
+		for i := range issues {
+			issues[i].unknown = false
+		}
+		if !reflect.DeepEqual(issues, tt.expected) {
+			t.Errorf("%s: Validate() = %+v; expected %+v", tt.name, issues, tt.expected)
+		}
+	}
+
+	if _, err := Validate([]byte(`{"storage": `)); err == nil || err.Error() != "invalid JSON: unexpected end of JSON input" {
+		t.Errorf("Validate() of truncated JSON returned %v; expected invalid JSON", err)
+	}
+}
+
+// TestLoadErrors verifies the errors of Load for a sound config, unknown keys,
+// values of the wrong type and missing required fields.
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name: "valid",
+			data: `{"version": "1.0", "edi": {"active": true, "port": 22}, "storage": {"savePath": "out"}}`,
+		},
+		{
+			name:     "unknown key",
+			data:     `{"storage": {"savepath": "out"}}`,
+			expected: `unknown config keys (use --lenient to ignore): storage.savepath: unknown key "savepath"; did you mean "savePath"? (keys are case-sensitive)`,
+		},
+		{
+			name:     "wrong type",
+			data:     `{"edi": {"port": "22"}}`,
+			expected: "invalid JSON: json: cannot unmarshal string into Go struct field Config.edi.port of type int",
+		},
+		{
+			name:     "missing required field",
+			data:     `{"hooks": [{"name": "notify", "events": ["downloaded"]}]}`,
+			expected: "hooks[0] needs a name and a command",
+		},
+		{
+			name:     "missing required field of a provider",
+			data:     `{"escalation": {"provider": "pagerduty"}}`,
+			expected: "escalation.routingKey is required for PagerDuty",
+		},
+	}
+	for _, tt := range tests {
+		cfg, err := Load(writeConfig(t, tt.data), LoadOptions{})
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%s: Load() returned %v", tt.name, err)
+			} else if cfg.Storage.SavePath != "out" || cfg.EDI.Port != 22 {
+				t.Errorf("%s: Load() = savePath %q, port %d; expected \"out\", 22", tt.name, cfg.Storage.SavePath, cfg.EDI.Port)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: Load() error = %v; expected %q", tt.name, err, tt.expected)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := Load(missing, LoadOptions{}); err == nil || err.Error() != "config file "+missing+" does not exist" {
+		t.Errorf("Load() of a missing file error = %v; expected %q", err, "config file "+missing+" does not exist")
+	}
+}
+
+// profileConfig is a config file with a sandbox profile overriding part of the
+// API, EDI and storage settings.
+const profileConfig = `{
+	"api": {"baseUrl": "https://sellingpartnerapi-eu.amazon.com", "tokenUrl": "https://api.amazon.co.uk/auth/o2/token"},
+	"edi": {"host": "sftp-eu.example.com", "usage": "P"},
+	"storage": {"savePath": "output/", "historyPath": "output/history.jsonl"},
+	"profiles": {
+		"sandbox": {
+			"api": {"baseUrl": "https://sandbox.sellingpartnerapi-eu.amazon.com"},
+			"edi": {"usage": "T"},
+			"storage": {"savePath": "output/sandbox/"}
+		}
+	}
+}`
+
+// TestLoadProfiles verifies that without --profile the base values apply, that
+// a profile overrides only the values it sets, and that an unknown profile fails.
+func TestLoadProfiles(t *testing.T) {
+	path := writeConfig(t, profileConfig)
+
+	cfg, err := Load(path, LoadOptions{})
+	if err != nil {
+		t.Fatalf("Load() returned %v", err)
+	}
+	if cfg.Profile != "" || cfg.API.BaseURL != "https://sellingpartnerapi-eu.amazon.com" || cfg.EDI.Usage != "P" || cfg.Storage.SavePath != "output/" {
+		t.Errorf("Load() without profile = profile %q, baseUrl %q, usage %q, savePath %q; expected the base values",
+			cfg.Profile, cfg.API.BaseURL, cfg.EDI.Usage, cfg.Storage.SavePath)
+	}
+
+	var notices []string
+	opts := LoadOptions{Profile: "sandbox", Logger: func(level utils.Level, prefix, detail string) {
+		notices = append(notices, level.String()+" "+prefix+detail)
+	}}
+	cfg, err = Load(path, opts)
+	if err != nil {
+		t.Fatalf("Load() with profile returned %v", err)
+	}
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"profile", cfg.Profile, "sandbox"},
+		{"api.baseUrl", cfg.API.BaseURL, "https://sandbox.sellingpartnerapi-eu.amazon.com"},
+		{"edi.usage", cfg.EDI.Usage, "T"},
+		{"storage.savePath", cfg.Storage.SavePath, "output/sandbox/"},
+		{"api.tokenUrl", cfg.API.TokenURL, "https://api.amazon.co.uk/auth/o2/token"},
+		{"edi.host", cfg.EDI.Host, "sftp-eu.example.com"},
+		{"storage.historyPath", cfg.Storage.HistoryPath, "output/history.jsonl"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("Load() with profile: %s = %q; expected %q", tt.name, tt.got, tt.expected)
+		}
+	}
+	if expected := []string{"info Loaded config from: " + path, "info Using profile: sandbox"}; !reflect.DeepEqual(notices, expected) {
+		t.Errorf("Load() with profile logged %q; expected %q", notices, expected)
+	}
+
+	if _, err := Load(path, LoadOptions{Profile: "staging"}); err == nil || err.Error() != `unknown profile "staging"` {
+		t.Errorf("Load() with unknown profile error = %v; expected %q", err, `unknown profile "staging"`)
+	}
+}
+
+// TestLoadEnvPrecedence verifies that AVC_* variables override the profile,
+// which overrides the file, and that the file supplies the rest.
+func TestLoadEnvPrecedence(t *testing.T) {
+	path := writeConfig(t, profileConfig)
+	t.Setenv("AVC_STORAGE_SAVE_PATH", "/data/orders/")
+	t.Setenv("AVC_EDI_PORT", "2222")
+	t.Setenv("AVC_STORAGE_SAVEPATH", "typo")
+
+	var notices []string
+	cfg, err := Load(path, LoadOptions{Profile: "sandbox", Environ: os.Environ(), Logger: func(level utils.Level, prefix, detail string) {
+		notices = append(notices, level.String()+" "+prefix+detail)
+	}})
+	if err != nil {
+		t.Fatalf("Load() returned %v", err)
+	}
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"env over profile", cfg.Storage.SavePath, "/data/orders/"},
+		{"env over file", fmt.Sprint(cfg.EDI.Port), "2222"},
+		{"profile over file", cfg.EDI.Usage, "T"},
+		{"profile only", cfg.API.BaseURL, "https://sandbox.sellingpartnerapi-eu.amazon.com"},
+		{"file only", cfg.EDI.Host, "sftp-eu.example.com"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("Load() %s = %q; expected %q", tt.name, tt.got, tt.expected)
+		}
+	}
+	expected := []string{
+		"info Loaded config from: " + path,
+		"warn Ignoring unknown environment variable: AVC_STORAGE_SAVEPATH",
+		"info Using profile: sandbox",
+	}
+	if !reflect.DeepEqual(notices, expected) {
+		t.Errorf("Load() logged %q; expected %q", notices, expected)
+	}
+
+	// Without a file the environment alone builds the configuration.
+	cfg, err = Load("", LoadOptions{Environ: os.Environ()})
+	if err != nil {
+		t.Fatalf("Load() without file returned %v", err)
+	}
+	if cfg.Storage.SavePath != "/data/orders/" || cfg.EDI.Port != 2222 {
+		t.Errorf("Load() without file = savePath %q, port %d; expected \"/data/orders/\", 2222", cfg.Storage.SavePath, cfg.EDI.Port)
+	}
+}
+
+// TestLoadEnvErrors verifies the errors for AVC_* values that cannot be
+// converted to the type of their key.
+func TestLoadEnvErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"AVC_EDI_PORT", "twenty-two", "invalid AVC_EDI_PORT (edi.port): expected an integer"},
+		{"AVC_EDI_ACTIVE", "maybe", "invalid AVC_EDI_ACTIVE (edi.active): expected true or false"},
+		{"AVC_LOCK_TTL", "5 minutes", `invalid AVC_LOCK_TTL (lock.ttl): invalid duration "5 minutes": time: unknown unit " minutes" in duration "5 minutes"`},
+		{"AVC_DAEMON_JOBS", `{"edi": {"interval": 5}}`, "invalid AVC_DAEMON_JOBS (daemon.jobs): invalid JSON: duration must be a string: json: cannot unmarshal number into Go value of type string"},
+		{"AVC_DAEMON_JOBS", `{"edi": {"intervall": "5m"}}`, `invalid AVC_DAEMON_JOBS (daemon.jobs): invalid JSON: json: unknown field "intervall"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			if _, err := Load("", LoadOptions{Environ: os.Environ()}); err == nil || err.Error() != tt.expected {
+				t.Errorf("Load() with %s=%s error = %v; expected %q", tt.name, tt.value, err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// pkg/config/env.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix starts the name of every environment variable read by ApplyEnv.
+const EnvPrefix = "AVC_"
+
+/*
+EnvVar describes the environment variable that sets one config key.
+
+Fields:
+  - Name: Variable name, e.g. "AVC_API_AUTH_CLIENT_ID".
+  - Path: Config key it sets, e.g. "api.auth.clientId".
+  - Type: "string", "boolean", "integer", "number", "duration", "list" or "json".
+*/
+type EnvVar struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+/*
+EnvName returns the environment variable for the config key at path: the
+prefix AVC_ followed by the path in upper snake case, e.g. "storage.savePath"
+becomes "AVC_STORAGE_SAVE_PATH".
+*/
+func EnvName(path string) string {
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, part := range strings.Split(path, ".") {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		runes := []rune(part)
+		for j, r := range runes {
+			if j > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(runes[j-1]) || j+1 < len(runes) && unicode.IsLower(runes[j+1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+/*
+EnvVars lists the environment variable of every config key, sorted by name.
+Nested objects are listed key by key; arrays of objects and maps (e.g. hooks,
+exports, daemon.jobs, profiles) are set as a whole from a JSON value.
+*/
+func EnvVars() []EnvVar {
+	var vars []EnvVar
+	walkEnv(reflect.TypeOf(Config{}), "", func(path string, t reflect.Type, _ []int) {
+		vars = append(vars, EnvVar{Name: EnvName(path), Path: path, Type: envType(t)})
+	})
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
+
+/*
+ApplyEnv sets every config key whose environment variable (see EnvName) is
+set, so the importer can run from the environment alone or override single
+values of a config file. Values are read as:
+  - string, boolean, integer, number and duration ("15m") keys: plain text.
+  - lists of strings: comma-separated ("network,throttle") or a JSON array.
+  - arrays of objects and maps: JSON, replacing the whole value.
+
+AVC_* variables that match no key are returned, since they are usually typos.
+
+Parameters:
+  - cfg:     The configuration to update.
+  - environ: "NAME=value" pairs, as returned by os.Environ.
+  - lenient: Ignore unknown keys in JSON values.
+
+Returns:
+  - The AVC_* variables that match no key, sorted.
+  - An error naming the first variable whose value cannot be parsed.
+*/
+func ApplyEnv(cfg *Config, environ []string, lenient bool) ([]string, error) {
+	values := map[string]string{}
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, EnvPrefix) {
+			values[name] = value
+		}
+	}
+	var err error
+	root := reflect.ValueOf(cfg).Elem()
+	walkEnv(root.Type(), "", func(path string, t reflect.Type, index []int) {
+		name := EnvName(path)
+		value, ok := values[name]
+		delete(values, name)
+		if !ok || err != nil {
+			return
+		}
+		if e := setEnv(root.FieldByIndex(index), value, lenient); e != nil {
+			err = fmt.Errorf("invalid %s (%s): %w", name, path, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	unknown := make([]string, 0, len(values))
+	for name := range values {
+		if !hookEnv(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// hookEnv reports whether name is one of the variables hooks.Command passes to
+// hook commands, which an importer started from a hook inherits.
+func hookEnv(name string) bool {
+	switch name {
+	case "AVC_EVENT", "AVC_FLOW", "AVC_FILE", "AVC_TYPE", "AVC_REMOTE_PATH", "AVC_SIZE", "AVC_SHA256":
+		return true
+	}
+	return strings.HasPrefix(name, "AVC_FIELD_")
+}
+
+// walkEnv calls fn for every settable key below t, descending into nested
+// objects. index is the field index path from the Config struct.
+func walkEnv(t reflect.Type, path string, fn func(path string, t reflect.Type, index []int), index ...int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		fieldPath := join(path, name)
+		fieldIndex := append(append([]int{}, index...), i)
+		if f.Type.Kind() == reflect.Struct && f.Type != durationType {
+			walkEnv(f.Type, fieldPath, fn, fieldIndex...)
+			continue
+		}
+		fn(fieldPath, f.Type, fieldIndex)
+	}
+}
+
+// envType names how values of type t are written (see EnvVar.Type).
+func envType(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t.Kind() == reflect.String:
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "number"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		return "list"
+	}
+	return "json"
+}
+
+// setEnv parses value into v according to its type.
+func setEnv(v reflect.Value, value string, lenient bool) error {
+	switch envType(v.Type()) {
+	case "string":
+		v.SetString(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		v.SetBool(b)
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return fmt.Errorf("expected an integer")
+		}
+		v.SetInt(n)
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		v.SetFloat(f)
+	case "duration":
+		var d Duration
+		if err := d.UnmarshalJSON([]byte(strconv.Quote(value))); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d))
+	case "list":
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			return setJSON(v, value, lenient)
+		}
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(items)
+	default:
+		return setJSON(v, value, lenient)
+	}
+	return nil
+}
+
+// setJSON decodes value into a fresh value of v's type and stores it in v.
+func setJSON(v reflect.Value, value string, lenient bool) error {
+	p := reflect.New(v.Type())
+	dec := json.NewDecoder(strings.NewReader(value))
+	if !lenient {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(p.Interface()); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	v.Set(p.Elem())
+	return nil
+}
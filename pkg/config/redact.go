@@ -0,0 +1,67 @@
+// pkg/config/redact.go
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// redacted replaces secret values in Redacted output.
+const redacted = "[redacted]"
+
+/*
+Redacted returns cfg as generic JSON with every non-empty secret replaced by
+"[redacted]", for printing the effective configuration. Secrets are keys
+containing "secret", "password" or "passphrase", ending in "token" or "key"
+(e.g. clientSecret, refreshToken, consumerKey), tokenId, and the values of
+headers maps.
+
+Returns:
+  - The redacted configuration.
+  - An error if cfg cannot be encoded.
+*/
+func Redacted(cfg *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	redactValue(v, false)
+	return v, nil
+}
+
+// redactValue redacts the secrets below v in place; all replaces every string.
+func redactValue(v interface{}, all bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if s, ok := child.(string); ok {
+				if s != "" && (all || secretKey(k)) {
+					v[k] = redacted
+				}
+				continue
+			}
+			redactValue(child, all || strings.EqualFold(k, "headers"))
+		}
+	case []interface{}:
+		for i, child := range v {
+			if s, ok := child.(string); ok {
+				if s != "" && all {
+					v[i] = redacted
+				}
+				continue
+			}
+			redactValue(child, all)
+		}
+	}
+}
+
+// secretKey reports whether values under key k are credentials.
+func secretKey(k string) bool {
+	k = strings.ToLower(k)
+	return strings.Contains(k, "secret") || strings.Contains(k, "password") || strings.Contains(k, "passphrase") ||
+		strings.HasSuffix(k, "token") || strings.HasSuffix(k, "key") || k == "tokenid"
+}
@@ -0,0 +1,336 @@
+// pkg/config/schema.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+var retryClasses = []string{utils.ClassNetwork, utils.ClassThrottle, utils.ClassServer, utils.ClassClient, utils.ClassOther}
+
+var syslogFacilities = []string{"", "kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+
+// enums lists the accepted values of enumerated settings, keyed by schema
+// path ("[]" stands for any array element, "*" for any map value).
+var enums = map[string][]string{
+	"lock.backend":                  {"", "file", "redis"},
+	"edi.sortBy":                    {"", "name", "mtime"},
+	"edi.usage":                     {"", "P", "T"},
+	"storage.outputFormat":          {"", "json", "parquet"},
+	"storage.compression":           {"", "none", "gzip", "zstd"},
+	"profiles.*.edi.usage":          {"", "P", "T"},
+	"hooks[].events[]":              {"downloaded", "generated", "changed", "sla", "throttled"},
+	"sla.rules[].document":          {"ack", "asn", "invoice"},
+	"sla.rules[].before":            {"", "shipWindowStart", "shipWindowEnd", "deliveryWindowStart", "deliveryWindowEnd"},
+	"retry.spapi.retryOn[]":         retryClasses,
+	"retry.sftp.retryOn[]":          retryClasses,
+	"daemon.jobs.*.retry.retryOn[]": retryClasses,
+	"processing.retry.retryOn[]":    retryClasses,
+	"exports[].type":                {"rest", "netsuite", "quickbooks"},
+	"exports[].quickbooks.document": {"", "invoice", "estimate"},
+	"exports[].auth.type":           {"", "oauth2", "apiKey"},
+	"exports[].orderBy":             {"", "poNumber", "timestamp"},
+	"exports[].retry.retryOn[]":     retryClasses,
+
+	"edi.keyboardInteractive[].source": {"env", "totp", "terminal"},
+	"logging.sinks[].type":             {"cloudwatch", "syslog"},
+	"logging.sinks[].level":            {"", "error", "warn", "info", "debug"},
+	"logging.sinks[].syslog.network":   {"", "udp", "tcp"},
+	"logging.sinks[].syslog.facility":  syslogFacilities,
+	"metrics.push":                     {"", "cloudwatch", "statsd", "dogstatsd"},
+	"escalation.provider":              {"", "pagerduty", "opsgenie"},
+	"windows.leadTimes[].shipDays[]":   {"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"},
+	"edi.x12.decimalSeparator":         {"", ".", ","},
+	"edi.partners.*.decimalSeparator":  {"", ".", ","},
+	"server.auth.keys[].role":          {"read", "trigger", "admin"},
+	"server.auth.oidc.roles.*":         {"read", "trigger", "admin"},
+	"server.auth.oidc.defaultRole":     {"", "read", "trigger", "admin"},
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+/*
+Schema returns the JSON Schema (draft 2020-12) of the config file. It is
+derived from the Config struct, so it always lists every supported key;
+objects reject keys they do not define.
+*/
+func Schema() map[string]interface{} {
+	s := schemaFor(reflect.TypeOf(Config{}), "")
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "AVC Importer configuration"
+	return s
+}
+
+// schemaFor describes type t found at path.
+func schemaFor(t reflect.Type, path string) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var s map[string]interface{}
+	switch {
+	case t == durationType:
+		s = map[string]interface{}{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`, "description": "Duration such as \"30s\", \"15m\" or \"1h30m\"."}
+	case t.Kind() == reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := jsonName(f)
+			if name == "" {
+				continue
+			}
+			props[name] = schemaFor(f.Type, join(path, name))
+		}
+		s = map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+	case t.Kind() == reflect.Map:
+		s = map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), join(path, "*"))}
+	case t.Kind() == reflect.Slice:
+		s = map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), path+"[]")}
+	case t.Kind() == reflect.String:
+		s = map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		s = map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = map[string]interface{}{"type": "number"}
+	default:
+		s = map[string]interface{}{}
+	}
+	if values, ok := enums[path]; ok {
+		s["enum"] = values
+	}
+	return s
+}
+
+// jsonName returns the JSON key of a struct field, or "" if it is not encoded.
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+/*
+Issue is one problem found by Validate.
+
+Fields:
+  - Path:    Location of the value, e.g. "storage.savepath" or "hooks[0].events[1]".
+  - Message: What is wrong and, for unknown keys, the closest known key.
+*/
+type Issue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	unknown bool
+}
+
+/*
+Validate checks a config file's JSON against Schema. Unlike Load it reports
+every key the importer does not know, including keys that differ from a
+known one only in case (encoding/json would accept "savepath" for "savePath",
+but the mismatch usually hides a typo elsewhere) and misspellings that would
+otherwise be ignored silently.
+
+Returns:
+  - The issues found, sorted by path.
+  - An error if data is not valid JSON.
+*/
+func Validate(data []byte) ([]Issue, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	var issues []Issue
+	validateValue(Schema(), v, "", &issues)
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
+
+/*
+checkUnknownKeys is the strict-mode check of Load: it fails if data contains
+any key Validate reports as unknown, naming each by its path. Unlike
+DisallowUnknownFields it also catches keys that only match in case.
+*/
+func checkUnknownKeys(data []byte) error {
+	issues, err := Validate(data)
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for _, issue := range issues {
+		if issue.unknown {
+			unknown = append(unknown, issue.Path+": "+issue.Message)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown config keys (use --lenient to ignore): %s", strings.Join(unknown, "; "))
+	}
+	return nil
+}
+
+// validateValue checks v against schema s, appending problems to issues.
+func validateValue(s map[string]interface{}, v interface{}, path string, issues *[]Issue) {
+	add := func(format string, args ...interface{}) {
+		p := path
+		if p == "" {
+			p = "(root)"
+		}
+		*issues = append(*issues, Issue{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+	if v == nil {
+		return
+	}
+	want, _ := s["type"].(string)
+	switch want {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			add("expected an object, got %s", jsonType(v))
+			return
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		extra, _ := s["additionalProperties"].(map[string]interface{})
+		for key, val := range obj {
+			child := join(path, key)
+			if ps, ok := props[key].(map[string]interface{}); ok {
+				validateValue(ps, val, child, issues)
+				continue
+			}
+			if extra != nil {
+				validateValue(extra, val, child, issues)
+				continue
+			}
+			*issues = append(*issues, Issue{Path: child, Message: unknownKey(key, props), unknown: true})
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			add("expected an array, got %s", jsonType(v))
+			return
+		}
+		items, _ := s["items"].(map[string]interface{})
+		for i, item := range arr {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), issues)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			add("expected a string, got %s", jsonType(v))
+			return
+		}
+		if _, isDuration := s["pattern"]; isDuration {
+			if _, err := time.ParseDuration(str); err != nil {
+				add("invalid duration %q", str)
+			}
+		}
+		if values, ok := s["enum"].([]string); ok && !contains(values, str) {
+			add("invalid value %q; expected one of %s", str, quoteAll(values))
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			add("expected an integer, got %s", jsonType(v))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			add("expected a number, got %s", jsonType(v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			add("expected true or false, got %s", jsonType(v))
+		}
+	}
+}
+
+// unknownKey describes an unknown key, suggesting the closest known one.
+func unknownKey(key string, props map[string]interface{}) string {
+	best, bestDist := "", 3
+	for known := range props {
+		if strings.EqualFold(known, key) {
+			return fmt.Sprintf("unknown key %q; did you mean %q? (keys are case-sensitive)", key, known)
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(known)); d < bestDist || (d == bestDist && best != "" && known < best) {
+			best, bestDist = known, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("unknown key %q; did you mean %q?", key, best)
+	}
+	return fmt.Sprintf("unknown key %q", key)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			quoted = append(quoted, fmt.Sprintf("%q", v))
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
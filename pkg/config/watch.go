@@ -0,0 +1,135 @@
+// pkg/config/watch.go
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// reloadDebounce groups the burst of events editors emit when saving a file.
+const reloadDebounce = 250 * time.Millisecond
+
+/*
+Holder provides concurrency-safe access to the configuration currently in
+effect, so long-running jobs pick up a reloaded config on their next run.
+*/
+type Holder struct {
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+/*
+NewHolder returns a Holder initialised with cfg.
+*/
+func NewHolder(cfg *Config) *Holder {
+	return &Holder{cfg: cfg}
+}
+
+/*
+Get returns the configuration currently in effect.
+*/
+func (h *Holder) Get() *Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cfg
+}
+
+/*
+Set replaces the configuration in effect and returns the previous one.
+*/
+func (h *Holder) Set(cfg *Config) *Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old := h.cfg
+	h.cfg = cfg
+	return old
+}
+
+/*
+CredentialsChanged reports whether any SP‑API or SFTP credential differs
+between a and b. Jobs authenticate at the start of every run, so a change only
+needs to be announced; the next cycle re-authenticates with the new values.
+*/
+func CredentialsChanged(a, b *Config) bool {
+	return a.API.Auth != b.API.Auth ||
+		a.API.SigV4 != b.API.SigV4 ||
+		a.EDI.Host != b.EDI.Host ||
+		a.EDI.Port != b.EDI.Port ||
+		a.EDI.Username != b.EDI.Username ||
+		a.EDI.PrivateKeyPath != b.EDI.PrivateKeyPath ||
+		a.EDI.PrivateKey != b.EDI.PrivateKey ||
+		a.EDI.PrivateKeyPassphrase != b.EDI.PrivateKeyPassphrase ||
+		a.EDI.PreviousKeyPath != b.EDI.PreviousKeyPath
+}
+
+/*
+Watch monitors filePath and calls onChange with the freshly loaded
+configuration every time the file is written or replaced. A file that fails to
+load is reported and ignored, leaving the previous configuration in effect.
+
+The containing directory is watched rather than the file itself so that
+editors and config management tools that save by rename are handled.
+
+Parameters:
+  - ctx:      Watching stops when ctx is cancelled.
+  - filePath: The configuration file to watch.
+  - opts:     Passed to Load; its Logger also receives watcher and reload errors.
+  - onChange: Callback receiving each successfully reloaded Config.
+
+Returns:
+  - An error if the watcher cannot be started.
+*/
+func Watch(ctx context.Context, filePath string, opts LoadOptions, onChange func(*Config)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(absPath), err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != absPath || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				debounce = time.After(reloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				opts.Logger.log(utils.LevelError, "Config watcher error: ", err.Error())
+			case <-debounce:
+				debounce = nil
+				cfg, err := Load(filePath, opts)
+				if err != nil {
+					opts.Logger.log(utils.LevelError, "Ignoring config reload: ", err.Error())
+					continue
+				}
+				onChange(cfg)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,140 @@
+// pkg/credentials/credentials.go
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+)
+
+/*
+Secret is a stored credential.
+
+Fields:
+  - Value:     The credential, e.g. a refresh token.
+  - UpdatedAt: When it was last replaced.
+*/
+type Secret struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+/*
+Key joins its parts into a credential name such as
+"quickbooks:4620816365:refreshToken".
+*/
+func Key(parts ...string) string {
+	return strings.Join(parts, ":")
+}
+
+/*
+Store keeps credentials that change at run time, such as OAuth2 refresh tokens
+that are replaced on every refresh, in one JSON file readable only by its
+owner. Values from the config file only seed it; once a credential was
+rotated, the stored value wins. Writes replace the file atomically, so a crash
+never loses the current token.
+*/
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+/*
+Open returns a Store backed by the file at path. The file is created on the
+first Set.
+*/
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+/*
+Path returns the file backing the store.
+*/
+func (s *Store) Path() string {
+	return s.path
+}
+
+/*
+Get returns the credential stored under name.
+
+Returns:
+  - The value and true if one is stored.
+  - An error if the file cannot be read or is not valid JSON.
+*/
+func (s *Store) Get(name string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	secret, ok := all[name]
+	return secret.Value, ok, nil
+}
+
+/*
+Set stores value under name, leaving all other credentials untouched.
+*/
+func (s *Store) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[name] = Secret{Value: value, UpdatedAt: time.Now().UTC()}
+	return s.write(all)
+}
+
+// read loads the credentials file; a missing file is an empty store.
+func (s *Store) read() (map[string]Secret, error) {
+	all := make(map[string]Secret)
+	data, err := codec.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", s.path, err)
+	}
+	return all, nil
+}
+
+// write replaces the credentials file via a temporary file (mode 0600) and rename.
+func (s *Store) write(all map[string]Secret) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create credentials dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStoreRotation verifies a replaced credential survives reopening and that
+// the file is only readable by its owner.
+func TestStoreRotation(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "state", "credentials.json"))
+	name := Key("quickbooks", "123", "refreshToken")
+
+	if _, ok, err := s.Get(name); err != nil || ok {
+		t.Fatalf("Get() on empty store = %v, %v; expected no credential", ok, err)
+	}
+	if err := s.Set(name, "rt-1"); err != nil {
+		t.Fatalf("Set() returned %v", err)
+	}
+	if err := s.Set(name, "rt-2"); err != nil {
+		t.Fatalf("Set() returned %v", err)
+	}
+
+	value, ok, err := Open(s.Path()).Get(name)
+	if err != nil || !ok || value != "rt-2" {
+		t.Errorf("Get(%q) = %q, %v, %v; expected the rotated token", name, value, ok, err)
+	}
+	info, err := os.Stat(s.Path())
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("credentials file mode = %v, %v; expected 0600", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,175 @@
+// pkg/csvinput/csvinput.go
+package csvinput
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+Column describes one column a CSV file may have.
+
+Fields:
+  - Name:     Column header; matched case-insensitively and ignoring surrounding spaces.
+  - Required: The header must have the column and every row a value in it.
+*/
+type Column struct {
+	Name     string
+	Required bool
+}
+
+/*
+Row is one data record of a CSV file.
+
+Fields:
+  - Line: Line number of the record in the file (1-based, counting the header).
+  - Err:  Why the record is malformed (bad quoting, wrong number of fields or a
+    missing required value); nil for a well-formed record.
+*/
+type Row struct {
+	Line   int
+	Err    error
+	values map[string]string
+}
+
+/*
+Get returns the trimmed value of a column, or "" if the file has no such column.
+*/
+func (r Row) Get(name string) string {
+	return r.values[name]
+}
+
+/*
+Int returns the value of a column as an integer. An empty value is 0.
+
+Returns:
+  - The value.
+  - An error naming the column if the value is not an integer.
+*/
+func (r Row) Int(name string) (int, error) {
+	v := r.values[name]
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a whole number", name, v)
+	}
+	return n, nil
+}
+
+/*
+ReadAll reads a CSV file whose first line is a header naming its columns.
+
+The header is validated strictly: every column must be one of columns, none
+may appear twice and all required columns must be present, so a misspelled
+or shifted column is reported instead of being read as empty. Problems with
+single records do not stop the read; they are set on the record's Row so the
+caller can report every bad line at once. A UTF-8 byte order mark, as written
+by spreadsheet programs, is skipped and blank lines are ignored.
+
+Returns:
+  - The data records in file order.
+  - An error if the file cannot be read or its header is invalid.
+*/
+func ReadAll(r io.Reader, columns []Column) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV file: expected a header line")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	names, err := matchHeader(header, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			rows = append(rows, Row{Line: pe.StartLine, Err: pe.Err})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line, values: make(map[string]string, len(names))}
+		for i, name := range names {
+			if i < len(rec) {
+				row.values[name] = strings.TrimSpace(rec[i])
+			}
+		}
+		if len(rec) != len(names) {
+			// Keep the values for the report, but they may be shifted.
+			row.Err = fmt.Errorf("has %d fields, the header has %d", len(rec), len(names))
+			rows = append(rows, row)
+			continue
+		}
+		for _, c := range columns {
+			if c.Required && row.values[c.Name] == "" {
+				row.Err = fmt.Errorf("%s is empty", c.Name)
+				break
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// matchHeader maps each header field to the name of its column.
+func matchHeader(header []string, columns []Column) ([]string, error) {
+	known := make(map[string]string, len(columns))
+	for _, c := range columns {
+		known[strings.ToLower(c.Name)] = c.Name
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	names := make([]string, len(header))
+	seen := map[string]bool{}
+	var unknown []string
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		name, ok := known[strings.ToLower(h)]
+		switch {
+		case h == "":
+			return nil, fmt.Errorf("CSV header: column %d has no name", i+1)
+		case !ok:
+			unknown = append(unknown, h)
+		case seen[name]:
+			return nil, fmt.Errorf("CSV header: column %s appears twice", name)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	if len(unknown) > 0 {
+		allowed := make([]string, len(columns))
+		for i, c := range columns {
+			allowed[i] = c.Name
+		}
+		return nil, fmt.Errorf("CSV header: unknown column %s (expected %s)", strings.Join(unknown, ", "), strings.Join(allowed, ", "))
+	}
+	var missing []string
+	for _, c := range columns {
+		if c.Required && !seen[c.Name] {
+			missing = append(missing, c.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header: missing column %s", strings.Join(missing, ", "))
+	}
+	return names, nil
+}
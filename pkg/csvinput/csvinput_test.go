@@ -0,0 +1,66 @@
+package csvinput
+
+import (
+	"strings"
+	"testing"
+)
+
+var testColumns = []Column{{Name: "purchaseOrderNumber", Required: true}, {Name: "quantity", Required: true}, {Name: "note"}}
+
+// TestReadAllReportsBadRows verifies bad records are reported with their line
+// numbers while the good ones around them are still read.
+func TestReadAllReportsBadRows(t *testing.T) {
+	in := "\ufeffPurchaseOrderNumber, Quantity\n" +
+		"PO1,3\n" +
+		"\n" +
+		"PO2\n" +
+		",4\n" +
+		"PO3,\"5\n" +
+		"x\"y\n" +
+		"PO4,six\n"
+	rows, err := ReadAll(strings.NewReader(in), testColumns)
+	if err != nil {
+		t.Fatalf("ReadAll() returned %v", err)
+	}
+
+	var got []string
+	for _, r := range rows {
+		s := r.Get("purchaseOrderNumber")
+		if r.Err != nil {
+			s = "error"
+		}
+		got = append(got, s)
+	}
+	if strings.Join(got, " ") != "PO1 error error error PO4" {
+		t.Fatalf("rows = %v; expected PO1, three bad rows and PO4", got)
+	}
+	if rows[0].Line != 2 || rows[1].Line != 4 || rows[2].Line != 5 || rows[3].Line != 6 {
+		t.Errorf("lines = %d %d %d %d; expected 2 4 5 6", rows[0].Line, rows[1].Line, rows[2].Line, rows[3].Line)
+	}
+	if n, err := rows[0].Int("quantity"); err != nil || n != 3 {
+		t.Errorf("Int() = %d, %v; expected 3", n, err)
+	}
+	if _, err := rows[4].Int("quantity"); err == nil {
+		t.Errorf("Int() of %q returned no error", rows[4].Get("quantity"))
+	}
+	if rows[0].Get("note") != "" {
+		t.Errorf("Get() of an absent optional column = %q; expected empty", rows[0].Get("note"))
+	}
+}
+
+// TestReadAllRejectsBadHeaders verifies unknown, duplicate and missing columns
+// fail the whole file.
+func TestReadAllRejectsBadHeaders(t *testing.T) {
+	for header, expected := range map[string]string{
+		"purchaseOrderNumber,quantity,qty":      "unknown column qty",
+		"purchaseOrderNumber,quantity,Quantity": "quantity appears twice",
+		"purchaseOrderNumber,note":              "missing column quantity",
+		"purchaseOrderNumber,,quantity":         "column 2 has no name",
+		"":                                      "empty CSV file",
+	} {
+		_, err := ReadAll(strings.NewReader(header), testColumns)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("ReadAll() with header %q returned %v; expected %q", header, err, expected)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// pkg/dedupe/dedupe.go
+package dedupe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Deduper remembers which versions of a purchase order were imported, so a
+version fetched again (overlapping pages, backfill windows, a changed-orders
+query returning an order already stored) is skipped while a newer version of
+the same PO is not. Programs embedding the importer can supply their own,
+e.g. backed by a database.
+*/
+type Deduper interface {
+	// Seen reports whether key was marked.
+	Seen(key string) (bool, error)
+	// Mark records key as imported.
+	Mark(key string) error
+}
+
+/*
+Key identifies one version of a purchase order as "<PO number>@<RFC 3339 time>".
+*/
+func Key(poNumber string, changedAt time.Time) string {
+	return poNumber + "@" + changedAt.UTC().Format(time.RFC3339Nano)
+}
+
+/*
+OrderKey returns the Key of an order's current version. Its time is when
+Amazon last changed the order: the later of purchaseOrderStateChangedDate and
+purchaseOrderChangedDate, or the purchase order date if it never changed.
+*/
+func OrderKey(o spapi.PurchaseOrder) string {
+	return Key(o.PurchaseOrderNumber, ChangedAt(o))
+}
+
+/*
+ChangedAt returns the time the order's current version was created, as used by OrderKey.
+*/
+func ChangedAt(o spapi.PurchaseOrder) time.Time {
+	t := o.OrderDetails.PurchaseOrderDate
+	for _, c := range []*time.Time{o.OrderDetails.PurchaseOrderStateChangedDate, o.OrderDetails.PurchaseOrderChangedDate} {
+		if c != nil && c.After(t) {
+			t = *c
+		}
+	}
+	return t
+}
+
+/*
+ChannelKey identifies a purchase order delivered on a channel (model.SourceAPI
+or model.SourceEDI) as "<channel>:<PO number>", whatever its version.
+*/
+func ChannelKey(channel, poNumber string) string {
+	return channel + ":" + poNumber
+}
+
+/*
+OtherChannel reports the channel other than o.Source that already delivered
+the purchase order o (marked with ChannelKey), so an order Amazon sends over
+both EDI and SP‑API, e.g. while a vendor migrates, is imported only once.
+
+Returns:
+  - The other channel, or "" if o was not delivered on one.
+  - An error if d cannot be read.
+*/
+func OtherChannel(d Deduper, o model.Order) (string, error) {
+	for _, c := range []string{model.SourceAPI, model.SourceEDI} {
+		if c == o.Source {
+			continue
+		}
+		seen, err := d.Seen(ChannelKey(c, o.PONumber))
+		if err != nil {
+			return "", err
+		}
+		if seen {
+			return c, nil
+		}
+	}
+	return "", nil
+}
+
+/*
+Memory is a Deduper that forgets everything when the process exits.
+*/
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+// NewMemory returns an empty in-memory Deduper.
+func NewMemory() *Memory {
+	return &Memory{keys: map[string]bool{}}
+}
+
+// Seen reports whether key was marked.
+func (m *Memory) Seen(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys[key], nil
+}
+
+// Mark records key.
+func (m *Memory) Mark(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = true
+	return nil
+}
+
+/*
+File is a Deduper keeping its keys with the time they were marked in one JSON
+file. Keys older than the retention are dropped on the next Mark, so the file
+does not grow forever; a version that old is covered by the checkpoints.
+*/
+type File struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	perms     *utils.Permissions
+}
+
+/*
+NewFile returns a File deduper stored at path and written with perms (nil uses
+utils.DefaultPermissions). A retention of 0 keeps keys forever.
+*/
+func NewFile(path string, retention time.Duration, perms *utils.Permissions) *File {
+	return &File{path: path, retention: retention, perms: perms}
+}
+
+// Path returns the file backing the deduper.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Seen reports whether key was marked.
+func (f *File) Seen(key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return false, err
+	}
+	_, ok := all[key]
+	return ok, nil
+}
+
+// Mark records key and drops expired keys.
+func (f *File) Mark(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if f.retention > 0 {
+		for k, at := range all {
+			if now.Sub(at) > f.retention {
+				delete(all, k)
+			}
+		}
+	}
+	all[key] = now
+	return f.write(all)
+}
+
+// read loads the key file; a missing file is an empty set.
+func (f *File) read() (map[string]time.Time, error) {
+	all := make(map[string]time.Time)
+	data, err := codec.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dedupe keys: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid dedupe file %s: %w", f.path, err)
+	}
+	return all, nil
+}
+
+// write replaces the key file via a temporary file and rename.
+func (f *File) write(all map[string]time.Time) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode dedupe keys: %w", err)
+	}
+	if err := f.perms.MkdirAll(filepath.Dir(f.path)); err != nil {
+		return fmt.Errorf("failed to create dedupe dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".dedupe-*")
+	if err != nil {
+		return fmt.Errorf("failed to write dedupe keys: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write dedupe keys: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write dedupe keys: %w", err)
+	}
+	if err := f.perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write dedupe keys: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write dedupe keys: %w", err)
+	}
+	return nil
+}
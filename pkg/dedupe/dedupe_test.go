@@ -0,0 +1,60 @@
+package dedupe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// TestOrderKeyChangesWithVersion verifies a re-fetched copy is a duplicate
+// while a state change of the same PO is a new version.
+func TestOrderKeyChangesWithVersion(t *testing.T) {
+	d := NewFile(filepath.Join(t.TempDir(), "state", "dedupe.json"), time.Hour, nil)
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	order := spapi.PurchaseOrder{PurchaseOrderNumber: "PO1", PurchaseOrderState: "New", OrderDetails: spapi.OrderDetails{PurchaseOrderDate: created}}
+
+	if seen, err := d.Seen(OrderKey(order)); err != nil || seen {
+		t.Fatalf("Seen() on empty store = %v, %v; expected false", seen, err)
+	}
+	if err := d.Mark(OrderKey(order)); err != nil {
+		t.Fatalf("Mark() returned %v", err)
+	}
+	refetched := order
+	if seen, err := NewFile(d.Path(), time.Hour, nil).Seen(OrderKey(refetched)); err != nil || !seen {
+		t.Errorf("Seen() of an identical copy = %v, %v; expected true", seen, err)
+	}
+
+	changed := created.Add(2 * time.Hour)
+	order.PurchaseOrderState = "Acknowledged"
+	order.OrderDetails.PurchaseOrderStateChangedDate = &changed
+	if seen, _ := d.Seen(OrderKey(order)); seen {
+		t.Errorf("Seen() of a changed version = true; expected it to be imported again")
+	}
+	if key := OrderKey(order); key != "PO1@2024-03-01T12:00:00Z" {
+		t.Errorf("OrderKey() = %q; expected the state change time", key)
+	}
+}
+
+// TestOtherChannel verifies an order delivered on one channel is reported when
+// it arrives on the other, but not when it arrives again on the same one.
+func TestOtherChannel(t *testing.T) {
+	d := NewMemory()
+	if err := d.Mark(ChannelKey(model.SourceEDI, "PO1")); err != nil {
+		t.Fatalf("Mark() returned %v", err)
+	}
+	for _, tc := range []struct {
+		order    model.Order
+		expected string
+	}{
+		{model.Order{Source: model.SourceAPI, PONumber: "PO1"}, model.SourceEDI},
+		{model.Order{Source: model.SourceEDI, PONumber: "PO1"}, ""},
+		{model.Order{Source: model.SourceAPI, PONumber: "PO2"}, ""},
+	} {
+		if got, err := OtherChannel(d, tc.order); err != nil || got != tc.expected {
+			t.Errorf("OtherChannel(%s %s) = %q, %v; expected %q", tc.order.Source, tc.order.PONumber, got, err, tc.expected)
+		}
+	}
+}
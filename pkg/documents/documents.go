@@ -0,0 +1,192 @@
+// pkg/documents/documents.go
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/money"
+	"github.com/heinrichb/avcimporter/pkg/pdf"
+)
+
+/*
+DefaultPurchaseOrder is the built-in purchase order template.
+*/
+const DefaultPurchaseOrder = `# Purchase order {{.Order.PONumber}}
+
+Order date:      {{date .Order.OrderDate}}
+Type:            {{.Order.Type}}
+State:           {{.Order.State}}
+Buying party:    {{.Order.BuyingParty}}
+Selling party:   {{.Order.SellingParty}}
+Ship to:         {{.Order.ShipToParty}}
+Bill to:         {{.Order.BillToParty}}
+Ship window:     {{window .Order.ShipWindow}}
+Delivery window: {{window .Order.DeliveryWindow}}
+
+{{printf "%-5s %-12s %-16s %9s %-7s %10s %12s" "ITEM" "ASIN" "VENDOR SKU" "QUANTITY" "UNIT" "NET COST" "TOTAL"}}
+{{range .Items}}{{printf "%-5s %-12s %-16s %9d %-7s %10s %12s" .Sequence .ASIN .VendorSKU .Quantity .Unit (money .NetCost $.Order.Currency) (money (lineTotal .) $.Order.Currency)}}
+{{end}}
+Lines: {{len .Items}}   Units: {{units .Items}}   Total: {{money (total .Items) .Order.Currency}} {{.Order.Currency}}
+`
+
+/*
+DefaultPackingSlip is the built-in packing slip template.
+*/
+const DefaultPackingSlip = `# Packing slip PO {{.Order.PONumber}}
+
+Ship from:       {{.Warehouse}}
+Ship to:         {{.Order.ShipToParty}}
+Ship window:     {{window .Order.ShipWindow}}
+Delivery window: {{window .Order.DeliveryWindow}}
+
+{{printf "%-5s %-12s %-16s %-16s %9s %-7s %6s" "ITEM" "ASIN" "VENDOR SKU" "EXTERNAL ID" "QUANTITY" "UNIT" "EACHES"}}
+{{range .Items}}{{printf "%-5s %-12s %-16s %-16s %9d %-7s %6d" .Sequence .ASIN .VendorSKU .ExternalID .Quantity .Unit .Eaches}}
+{{end}}
+Lines: {{len .Items}}   Units: {{units .Items}}
+
+Packed by: ____________________   Date: ____________
+`
+
+/*
+Data is what document templates render.
+
+Fields:
+  - Order:     The purchase order.
+  - Warehouse: Ship-from party of a packing slip.
+  - Items:     The lines of the document: every line of a purchase order, the lines the warehouse ships on a packing slip.
+  - Generated: When the document was rendered.
+*/
+type Data struct {
+	Order     model.Order
+	Warehouse string
+	Items     []model.Item
+	Generated time.Time
+}
+
+/*
+Template is a parsed document template. Templates are Go text/template text
+rendering Data; besides the built-in functions they can use:
+
+  - date:      Formats a time as "2006-01-02 15:04" in the configured time zone ("" for zero).
+  - window:    Formats a *model.Window as "start - end".
+  - money:     Formats a *float64 with the decimals of an optional ISO currency, two by default ("" for nil).
+  - lineTotal: Net cost times quantity of an item (nil without a cost).
+  - total:     Sum of lineTotal over items (nil if no item has a cost).
+  - units:     Sum of the items' quantities in eaches.
+
+Lines starting with "# " are headings and a form feed starts a new page (see
+pdf.Render).
+*/
+type Template struct {
+	t *template.Template
+}
+
+/*
+Parse reads the template in path, or parses def if path is empty.
+
+Returns:
+  - The template.
+  - An error if the file cannot be read or the template is invalid.
+*/
+func Parse(name, path, def string) (*Template, error) {
+	text := def
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", name, err)
+		}
+		text = string(data)
+	}
+	t, err := template.New(name).Funcs(funcs(time.UTC)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return &Template{t: t}, nil
+}
+
+/*
+RenderPDF renders data with the template and lays the text out as a PDF.
+
+Parameters:
+  - w:     Destination of the PDF.
+  - title: Document title.
+  - data:  The document data.
+  - loc:   Time zone of the dates (nil means UTC).
+*/
+func (t *Template) RenderPDF(w io.Writer, title string, data Data, loc *time.Location) error {
+	if loc == nil {
+		loc = time.UTC
+	}
+	clone, err := t.t.Clone()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := clone.Funcs(funcs(loc)).Execute(&b, data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", t.t.Name(), err)
+	}
+	return pdf.Render(w, title, b.String())
+}
+
+// funcs returns the template functions, formatting dates in loc.
+func funcs(loc *time.Location) template.FuncMap {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(loc).Format("2006-01-02 15:04")
+	}
+	lineTotal := func(it model.Item) *float64 {
+		if it.NetCost == nil {
+			return nil
+		}
+		t := math.Round(*it.NetCost*float64(it.Quantity)*100) / 100
+		return &t
+	}
+	return template.FuncMap{
+		"date": date,
+		"window": func(w *model.Window) string {
+			if w == nil {
+				return ""
+			}
+			return date(w.Start) + " - " + date(w.End)
+		},
+		"money": func(v *float64, currency ...string) string {
+			if v == nil {
+				return ""
+			}
+			places := 2
+			if len(currency) > 0 {
+				places = money.Places(currency[0])
+			}
+			return money.Format(*v, places, '.')
+		},
+		"lineTotal": lineTotal,
+		"total": func(items []model.Item) *float64 {
+			var sum *float64
+			for _, it := range items {
+				if t := lineTotal(it); t != nil {
+					if sum == nil {
+						sum = new(float64)
+					}
+					*sum += *t
+				}
+			}
+			return sum
+		},
+		"units": func(items []model.Item) int {
+			n := 0
+			for _, it := range items {
+				n += it.Eaches()
+			}
+			return n
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package documents
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/model"
+)
+
+// testData is a two-line order with one priced line.
+func testData() Data {
+	cost := 2.5
+	return Data{
+		Order: model.Order{
+			PONumber:    "PO1",
+			OrderDate:   time.Date(2024, 5, 3, 22, 30, 0, 0, time.UTC),
+			ShipToParty: "PHX3",
+			ShipWindow:  &model.Window{Start: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)},
+			Currency:    "USD",
+		},
+		Warehouse: "WH1",
+		Items: []model.Item{
+			{Sequence: "1", ASIN: "B001", Quantity: 3, Unit: model.Cases, UnitSize: 6, NetCost: &cost},
+			{Sequence: "2", VendorSKU: "SKU-2", Quantity: 4, Unit: model.Eaches},
+		},
+	}
+}
+
+// TestDefaultTemplates verifies the built-in templates render the order in
+// the given time zone with totals.
+func TestDefaultTemplates(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	for _, tt := range []struct {
+		name, def string
+		want      []string
+	}{
+		{"purchase order", DefaultPurchaseOrder, []string{"(Purchase order PO1)", "(Order date:      2024-05-04 00:30)", "(Ship window:     2024-05-06 02:00 - 2024-05-08 02:00)", "7.50", "(Lines: 2   Units: 22   Total: 7.50 USD)"}},
+		{"packing slip", DefaultPackingSlip, []string{"(Packing slip PO PO1)", "(Ship from:       WH1)", "SKU-2"}},
+	} {
+		tmpl, err := Parse(tt.name, "", tt.def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.RenderPDF(&b, "PO1", testData(), berlin); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s lacks %q:\n%s", tt.name, want, b.String())
+			}
+		}
+	}
+}
+
+// TestParseFile verifies templates are read from files and invalid ones are
+// rejected.
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "slip.tmpl")
+	os.WriteFile(good, []byte("{{.Warehouse}} ships {{units .Items}} units for {{money (total .Items) \"JPY\"}} yen"), 0o644)
+	tmpl, err := Parse("packing slip", good, DefaultPackingSlip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.RenderPDF(&b, "PO1", testData(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "(WH1 ships 22 units for 8 yen)") {
+		t.Errorf("custom template not rendered:\n%s", b.String())
+	}
+
+	bad := filepath.Join(dir, "bad.tmpl")
+	os.WriteFile(bad, []byte("{{.Warehouse"), 0o644)
+	if _, err := Parse("packing slip", bad, DefaultPackingSlip); err == nil {
+		t.Error("Parse() accepted an invalid template")
+	}
+}
@@ -0,0 +1,142 @@
+// pkg/edidoc/edidoc.go
+package edidoc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+TemplateData is what an EDI template (edi.templates) is executed with.
+
+Fields:
+  - Order:    The stored purchase order (empty for a shipment document).
+  - PONumber: Its purchase order number, or the first one a shipment ships against.
+  - Shipment: The shipment of a ship notice (856), the same confirmation
+    `sync-status` submits through the Vendor Shipments API; nil for order
+    documents.
+  - Vars:     The template's vars from the config.
+*/
+type TemplateData struct {
+	Order    spapi.PurchaseOrder
+	PONumber string
+	Shipment *spapi.ShipmentConfirmation
+	Vars     map[string]string
+}
+
+/*
+Render builds the document of template name for order, as `edi build` sends
+it: wrapped in an interchange from edi.senderId to the template's receiver,
+with the receiver's X12 options.
+
+Parameters:
+  - cfg:     The configuration holding edi.templates.
+  - name:    The template (a key of edi.templates).
+  - order:   The purchase order.
+  - control: The nine-digit interchange control number.
+  - at:      Interchange date and time.
+
+Returns:
+  - The X12 interchange.
+  - An error if the template is unknown, unreadable or fails to render.
+*/
+func Render(cfg *config.Config, name string, order spapi.PurchaseOrder, control string, at time.Time) ([]byte, error) {
+	return render(cfg, name, TemplateData{Order: order, PONumber: order.PurchaseOrderNumber}, control, at)
+}
+
+/*
+RenderShipment builds the ship notice of template name (e.g. an 856) for a
+shipment, as `edi build --shipments` sends it. The template sees the shipment
+as .Shipment, so an 856 and a Vendor Shipments API confirmation are mapped
+from the same shipment lines.
+
+Returns:
+  - The X12 interchange.
+  - An error if the template is unknown, unreadable or fails to render.
+*/
+func RenderShipment(cfg *config.Config, name string, shipment spapi.ShipmentConfirmation, control string, at time.Time) ([]byte, error) {
+	data := TemplateData{Shipment: &shipment}
+	for _, item := range shipment.ShippedItems {
+		if item.ItemDetails != nil {
+			data.PONumber = item.ItemDetails.PurchaseOrderNumber
+			break
+		}
+	}
+	return render(cfg, name, data, control, at)
+}
+
+// render executes template name with data inside its envelope.
+func render(cfg *config.Config, name string, data TemplateData, control string, at time.Time) ([]byte, error) {
+	tc, ok := cfg.EDI.Templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown EDI template %s", name)
+	}
+	text, err := os.ReadFile(tc.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := x12.ParseTemplate(name, string(text))
+	if err != nil {
+		return nil, err
+	}
+	opts, err := cfg.X12Options(tc.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+	data.Vars = tc.Vars
+	return tmpl.Build(opts, Envelope(cfg, tc, control, at), data)
+}
+
+/*
+Envelope fills in the envelope of a templated document sent by us to the
+template's receiver. The group control number is the interchange control
+number without leading zeros.
+*/
+func Envelope(cfg *config.Config, tc config.EDITemplate, control string, at time.Time) x12.Envelope {
+	return x12.Envelope{
+		ISA: x12.ISAHeader{
+			SenderQualifier:   tc.SenderQualifier,
+			SenderID:          cfg.EDI.SenderID,
+			ReceiverQualifier: tc.ReceiverQualifier,
+			ReceiverID:        tc.ReceiverID,
+			Time:              at,
+			ControlNumber:     control,
+			Usage:             cfg.EDI.Usage,
+		},
+		FunctionalID: tc.FunctionalID,
+		SenderCode:   cfg.EDI.SenderID,
+		ReceiverCode: tc.ReceiverID,
+		GroupControl: strings.TrimLeft(control, "0"),
+		SetID:        tc.SetID,
+		SetControl:   "0001",
+	}
+}
+
+/*
+Ack builds the 997 functional acknowledgment of an inbound interchange as the
+EDI flow uploads it: from edi.senderId, with the X12 options of the partner
+(the interchange's ISA06) and edi.usage as ISA15, whatever the inbound says.
+*/
+func Ack(cfg *config.Config, inbound []byte) ([]byte, error) {
+	doc, err := x12.Parse(inbound)
+	if err != nil {
+		return nil, err
+	}
+	isa, _ := doc.Find("ISA")
+	opts, err := cfg.X12Options(strings.TrimSpace(isa.Element(6)))
+	if err != nil {
+		return nil, err
+	}
+	ack, err := utils.Generate997With(string(inbound), cfg.EDI.SenderID, opts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build 997: %w", err)
+	}
+	return []byte(ack), nil
+}
@@ -0,0 +1,191 @@
+// pkg/edidoc/edidoctest/edidoctest.go
+package edidoctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+	"github.com/heinrichb/avcimporter/pkg/edidoc"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+// UpdateEnv names the environment variable that, set to any value, makes
+// Compare write golden files instead of checking them.
+const UpdateEnv = "EDIDOCTEST_UPDATE"
+
+// Time is the interchange time of documents rendered by RenderTemplate.
+var Time = time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+// Control is the interchange control number of documents rendered by RenderTemplate.
+const Control = "000000001"
+
+/*
+LoadOrder reads a purchase order fixture: the JSON of an order as stored by
+the importer (or as returned by SP‑API getPurchaseOrder).
+*/
+func LoadOrder(t testing.TB, path string) spapi.PurchaseOrder {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read order fixture: %v", err)
+	}
+	var order spapi.PurchaseOrder
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatalf("invalid order fixture %s: %v", path, err)
+	}
+	return order
+}
+
+/*
+LoadShipment reads a shipment fixture: the JSON of a Vendor Shipments API
+shipment confirmation, as `sync-status` builds it from its input file.
+*/
+func LoadShipment(t testing.TB, path string) spapi.ShipmentConfirmation {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read shipment fixture: %v", err)
+	}
+	var shipment spapi.ShipmentConfirmation
+	if err := json.Unmarshal(data, &shipment); err != nil {
+		t.Fatalf("invalid shipment fixture %s: %v", path, err)
+	}
+	return shipment
+}
+
+/*
+RenderTemplate renders template name of cfg.EDI.Templates (e.g. an 855, 856
+or 810) for order exactly as `edi build` does, at Time with control number
+Control.
+*/
+func RenderTemplate(t testing.TB, cfg *config.Config, name string, order spapi.PurchaseOrder) []byte {
+	t.Helper()
+	doc, err := edidoc.Render(cfg, name, order, Control, Time)
+	if err != nil {
+		t.Fatalf("failed to render %s: %v", name, err)
+	}
+	return doc
+}
+
+/*
+RenderShipmentTemplate renders the ship notice template name (e.g. an 856)
+for shipment exactly as `edi build --shipments` does, at Time with control
+number Control.
+*/
+func RenderShipmentTemplate(t testing.TB, cfg *config.Config, name string, shipment spapi.ShipmentConfirmation) []byte {
+	t.Helper()
+	doc, err := edidoc.RenderShipment(cfg, name, shipment, Control, Time)
+	if err != nil {
+		t.Fatalf("failed to render %s: %v", name, err)
+	}
+	return doc
+}
+
+/*
+RenderAck builds the 997 the EDI flow sends for the inbound interchange in the
+fixture file at path.
+*/
+func RenderAck(t testing.TB, cfg *config.Config, path string) []byte {
+	t.Helper()
+	inbound, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read inbound fixture: %v", err)
+	}
+	doc, err := edidoc.Ack(cfg, inbound)
+	if err != nil {
+		t.Fatalf("failed to build 997 for %s: %v", path, err)
+	}
+	return doc
+}
+
+/*
+Normalize rewrites an interchange so that runs compare equal: the interchange
+and group dates and times (ISA09/ISA10, GS04/GS05) and the control numbers of
+the envelopes (ISA13/IEA02, GS06/GE02, ST02/SE02) are replaced by fixed
+values, and every segment is put on its own line. Delimiters are kept, so a
+changed separator still shows up as a difference.
+
+Returns:
+  - The normalized interchange.
+  - An error if doc does not start with an ISA segment.
+*/
+func Normalize(doc []byte) ([]byte, error) {
+	parsed, err := x12.Parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	fixed := map[string]map[int]string{
+		"ISA": {9: "000000", 10: "0000", 13: "000000000"},
+		"IEA": {2: "000000000"},
+		"GS":  {4: "00000000", 5: "0000", 6: "1"},
+		"GE":  {2: "1"},
+		"ST":  {2: "0001"},
+		"SE":  {2: "0001"},
+	}
+	for i, seg := range parsed.Segments {
+		for pos, value := range fixed[seg.ID] {
+			if pos <= len(seg.Elements) {
+				seg.Elements[pos-1] = value
+			}
+		}
+		parsed.Segments[i] = seg
+	}
+	parsed.LineBreak = "\n"
+	return parsed.Bytes(), nil
+}
+
+/*
+Compare checks the normalized form of got against the golden file at path and
+reports the first differing segment. With the environment variable UpdateEnv
+set, the golden file is written instead; review the diff before committing it.
+*/
+func Compare(t testing.TB, path string, got []byte) {
+	t.Helper()
+	normalized, err := Normalize(got)
+	if err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, normalized, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with %s=1 to create it): %v", UpdateEnv, err)
+	}
+	if bytes.Equal(normalized, want) {
+		return
+	}
+	t.Errorf("output differs from %s at %s\n(run with %s=1 to accept the new output)", path, firstDifference(string(want), string(normalized)), UpdateEnv)
+}
+
+// firstDifference describes the first line that differs between want and got.
+func firstDifference(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			return fmt.Sprintf("line %d\n  want: %s\n  got:  %s", i+1, wl, gl)
+		}
+	}
+	return "line endings differ"
+}
@@ -0,0 +1,62 @@
+package edidoctest
+
+import (
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/config"
+)
+
+// testConfig returns a configuration with the shipped 855 and 856 templates.
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.EDI.SenderID = "VENDOR"
+	cfg.EDI.Usage = "T"
+	cfg.EDI.Templates = map[string]config.EDITemplate{
+		"855": {
+			File:              "../../../configs/edi/855.tmpl",
+			SetID:             "855",
+			FunctionalID:      "PR",
+			ReceiverID:        "AMAZON",
+			ReceiverQualifier: "ZZ",
+			SenderQualifier:   "ZZ",
+			Vars:              map[string]string{"vendorCode": "VEND1"},
+		},
+		"856": {
+			File:              "../../../configs/edi/856.tmpl",
+			SetID:             "856",
+			FunctionalID:      "SH",
+			ReceiverID:        "AMAZON",
+			ReceiverQualifier: "ZZ",
+			SenderQualifier:   "ZZ",
+		},
+	}
+	return cfg
+}
+
+// TestGolden verifies the shipped 855 and 856 templates and the 997 against
+// their golden files.
+func TestGolden(t *testing.T) {
+	cfg := testConfig()
+	order := LoadOrder(t, "testdata/order.json")
+	Compare(t, "testdata/855.golden", RenderTemplate(t, cfg, "855", order))
+	Compare(t, "testdata/856.golden", RenderShipmentTemplate(t, cfg, "856", LoadShipment(t, "testdata/shipment.json")))
+	Compare(t, "testdata/997.golden", RenderAck(t, cfg, "testdata/850.edi"))
+}
+
+// TestNormalize verifies envelope dates and control numbers are masked.
+func TestNormalize(t *testing.T) {
+	a, err := Normalize([]byte("ISA*00*          *00*          *ZZ*A              *ZZ*B              *240102*1200*U*00401*000000101*0*T*>~GS*PR*A*B*20240102*1200*101*X*004010~ST*855*0007~BAK*00*AC*PO1*20240102~SE*3*0007~GE*1*101~IEA*1*000000101~"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Normalize([]byte("ISA*00*          *00*          *ZZ*A              *ZZ*B              *250309*0815*U*00401*000000202*0*T*>~GS*PR*A*B*20250309*0815*202*X*004010~ST*855*0001~BAK*00*AC*PO1*20240102~SE*3*0001~GE*1*202~IEA*1*000000202~"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("Normalize() differs:\n%s\n%s", a, b)
+	}
+	if got := firstDifference("ST*855~\nBAK*00~", "ST*855~\nBAK*01~"); got != "line 2\n  want: BAK*00~\n  got:  BAK*01~" {
+		t.Errorf("firstDifference() = %q", got)
+	}
+}
@@ -0,0 +1,253 @@
+// pkg/export/netsuite.go
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+NetSuiteOptions configures the NetSuite exporter.
+
+Fields:
+  - Name:            Name used in logs and errors.
+  - AccountID:       NetSuite account ID, e.g. "1234567" or "1234567_SB1" for a sandbox.
+  - BaseURL:         REST endpoint (default: https://<account>.suitetalk.api.netsuite.com).
+  - ConsumerKey:     Token-based authentication consumer key of the integration record.
+  - ConsumerSecret:  Its consumer secret.
+  - TokenID:         Access token ID.
+  - TokenSecret:     Access token secret.
+  - Customers:       Customer internal IDs keyed by Amazon party ID (ship-to party first, then buying party).
+  - DefaultCustomer: Customer of orders whose parties are not in Customers.
+  - Items:           SKU cross-reference to NetSuite item internal IDs.
+  - Location:        Location internal ID set on every sales order, if any.
+  - Currencies:      Currency internal IDs keyed by ISO code; orders in other currencies fail when set.
+  - Retry:           Retry policy for failed requests.
+  - Timeout:         Limit per request (0 means no limit).
+  - Required:        Fail the run when an export fails instead of only logging it.
+  - Logger:          Receives the sales orders created; nil discards them.
+*/
+type NetSuiteOptions struct {
+	Name            string
+	AccountID       string
+	BaseURL         string
+	ConsumerKey     string
+	ConsumerSecret  string
+	TokenID         string
+	TokenSecret     string
+	Customers       map[string]string
+	DefaultCustomer string
+	Items           SKUMap
+	Location        string
+	Currencies      map[string]string
+	Retry           utils.RetryPolicy
+	Timeout         time.Duration
+	Required        bool
+	Logger          *utils.Logger
+}
+
+/*
+NetSuite is a hook that creates a NetSuite sales order for every imported
+purchase order through the SuiteTalk REST record API. Each order carries the PO
+number as its external ID and a request idempotency key derived from it, so a
+retried request or a re-imported order never creates a second sales order.
+*/
+type NetSuite struct {
+	opts   NetSuiteOptions
+	client *http.Client
+}
+
+/*
+NewNetSuite checks opts and fills in its defaults.
+*/
+func NewNetSuite(opts NetSuiteOptions) (*NetSuite, error) {
+	if opts.AccountID == "" || opts.ConsumerKey == "" || opts.TokenID == "" {
+		return nil, fmt.Errorf("export %s: netsuite needs an accountId, consumerKey and tokenId", opts.Name)
+	}
+	if opts.BaseURL == "" {
+		host := strings.ReplaceAll(strings.ToLower(opts.AccountID), "_", "-")
+		opts.BaseURL = "https://" + host + ".suitetalk.api.netsuite.com"
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	if opts.Retry.Name == "" {
+		opts.Retry.Name = "export " + opts.Name
+	}
+	return &NetSuite{opts: opts, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Name returns the exporter's name.
+func (n *NetSuite) Name() string { return n.opts.Name }
+
+// Blocking reports whether a failed export fails the run.
+func (n *NetSuite) Blocking() bool { return n.opts.Required }
+
+// Handles reports whether event is one the exporter sends orders for.
+func (n *NetSuite) Handles(event string) bool { return event == hooks.Generated }
+
+// Wants reports whether e is about a purchase order.
+func (n *NetSuite) Wants(e hooks.Event) bool { return e.Order != nil || e.Type == "order" }
+
+/*
+Run creates the sales order of a purchase order, whether it came from SP‑API
+or an EDI 850. Other documents are ignored. An order that cannot be mapped (unknown customer or item) fails
+without being retried.
+*/
+func (n *NetSuite) Run(ctx context.Context, e hooks.Event) error {
+	order, err := eventOrder(e)
+	if err != nil || order == nil {
+		return err
+	}
+	so, err := n.SalesOrder(*order)
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	body, err := json.Marshal(so)
+	if err != nil {
+		return err
+	}
+	key := IdempotencyKey(n.opts.AccountID, order.PONumber)
+	target := n.opts.BaseURL + "/services/rest/record/v1/salesOrder"
+
+	return utils.Retry(ctx, n.opts.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-NetSuite-Idempotency-Key", key)
+		req.Header.Set("Authorization", n.authorization(req.Method, req.URL))
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			n.opts.Logger.PrintColoredContext(ctx, "Created NetSuite sales order: ", fmt.Sprintf("%s (%s)", order.PONumber, resp.Header.Get("Location")), "#32CD32")
+			return nil
+		}
+		if resp.StatusCode == http.StatusBadRequest && bytes.Contains(msg, []byte("DUP_RCRD")) {
+			n.opts.Logger.LogContext(ctx, utils.LevelWarn, "NetSuite sales order exists already: ", order.PONumber)
+			return nil
+		}
+		return &utils.StatusError{Op: "export " + n.opts.Name, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	})
+}
+
+/*
+SalesOrder maps a purchase order to the body of a NetSuite salesOrder record.
+Quantities ordered in cases are converted to eaches using the case size, and
+the net cost becomes the item rate. With Currencies set, the order's currency
+is set on the record.
+
+Returns:
+  - The record.
+  - An error naming the customer, the currency or the items that have no NetSuite ID.
+*/
+func (n *NetSuite) SalesOrder(o model.Order) (map[string]interface{}, error) {
+	customer, err := orderCustomer(o, n.opts.Customers, n.opts.DefaultCustomer, "NetSuite")
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := orderLines(o, n.opts.Items, "NetSuite")
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]map[string]interface{}, 0, len(mapped))
+	for _, l := range mapped {
+		line := map[string]interface{}{"item": map[string]string{"id": l.Item}, "quantity": l.Quantity}
+		if l.Rate != nil {
+			line["rate"] = *l.Rate
+		}
+		lines = append(lines, line)
+	}
+
+	so := map[string]interface{}{
+		"externalId":  "AVC-" + o.PONumber,
+		"otherRefNum": o.PONumber,
+		"entity":      map[string]string{"id": customer},
+		"tranDate":    o.OrderDate.Format("2006-01-02"),
+		"item":        map[string]interface{}{"items": lines},
+	}
+	if n.opts.Location != "" {
+		so["location"] = map[string]string{"id": n.opts.Location}
+	}
+	if o.Currency != "" && len(n.opts.Currencies) > 0 {
+		id, ok := n.opts.Currencies[strings.ToUpper(o.Currency)]
+		if !ok {
+			return nil, fmt.Errorf("no NetSuite currency for %s of order %s", o.Currency, o.PONumber)
+		}
+		so["currency"] = map[string]string{"id": id}
+	}
+	return so, nil
+}
+
+/*
+authorization returns the OAuth 1.0a header of NetSuite token-based
+authentication (HMAC-SHA256 signature, realm set to the account ID).
+*/
+func (n *NetSuite) authorization(method string, u *url.URL) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	oauth := map[string]string{
+		"oauth_consumer_key":     n.opts.ConsumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA256",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            n.opts.TokenID,
+		"oauth_version":          "1.0",
+	}
+	oauth["oauth_signature"] = oauthSignature(method, u, oauth, n.opts.ConsumerSecret, n.opts.TokenSecret)
+
+	keys := make([]string, 0, len(oauth))
+	for k := range oauth {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{`realm="` + strings.ToUpper(n.opts.AccountID) + `"`}
+	for _, k := range keys {
+		parts = append(parts, k+`="`+percentEncode(oauth[k])+`"`)
+	}
+	return "OAuth " + strings.Join(parts, ", ")
+}
+
+// oauthSignature signs a request as described in RFC 5849 section 3.4.
+func oauthSignature(method string, u *url.URL, oauth map[string]string, consumerSecret, tokenSecret string) string {
+	var params []string
+	for k, v := range oauth {
+		params = append(params, percentEncode(k)+"="+percentEncode(v))
+	}
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			params = append(params, percentEncode(k)+"="+percentEncode(v))
+		}
+	}
+	sort.Strings(params)
+	base := strings.ToUpper(method) + "&" + percentEncode(u.Scheme+"://"+u.Host+u.EscapedPath()) + "&" + percentEncode(strings.Join(params, "&"))
+	mac := hmac.New(sha256.New, []byte(percentEncode(consumerSecret)+"&"+percentEncode(tokenSecret)))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode encodes s as RFC 3986 requires for OAuth.
+func percentEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// TestNetSuiteExport verifies the sales order mapping, the idempotency key
+// reused across retries and that unmapped items fail without a request.
+func TestNetSuiteExport(t *testing.T) {
+	var keys []string
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/rest/record/v1/salesOrder" || !strings.HasPrefix(r.Header.Get("Authorization"), `OAuth realm="123_SB1", oauth_consumer_key="ck"`) {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		keys = append(keys, r.Header.Get("X-NetSuite-Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Location", "/services/rest/record/v1/salesOrder/42")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	skus := filepath.Join(dir, "skus.csv")
+	os.WriteFile(skus, []byte("sku,description,item\nSKU-1,Widget,501\nB0ASIN2,Gadget,502\n"), 0o644)
+	items, err := LoadSKUMap(skus)
+	if err != nil {
+		t.Fatalf("LoadSKUMap() returned %v", err)
+	}
+	n, err := NewNetSuite(NetSuiteOptions{
+		Name: "netsuite", AccountID: "123_SB1", BaseURL: srv.URL, ConsumerKey: "ck", ConsumerSecret: "cs", TokenID: "tk", TokenSecret: "ts",
+		Customers: map[string]string{"ABCD": "77"}, Items: items, Retry: utils.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewNetSuite() returned %v", err)
+	}
+
+	order := spapi.PurchaseOrder{PurchaseOrderNumber: "PO1", OrderDetails: spapi.OrderDetails{
+		PurchaseOrderDate: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+		ShipToParty:       &spapi.Party{PartyID: "ABCD"},
+		Items: []spapi.OrderItem{
+			{ItemSequenceNumber: "1", VendorProductIdentifier: "SKU-1", OrderedQuantity: spapi.ItemQuantity{Amount: 2, UnitOfMeasure: "Cases", UnitSize: 6}, NetCost: &spapi.Money{CurrencyCode: "USD", Amount: "4.50"}},
+			{ItemSequenceNumber: "2", AmazonProductIdentifier: "B0ASIN2", OrderedQuantity: spapi.ItemQuantity{Amount: 3, UnitOfMeasure: "Eaches"}},
+		},
+	}}
+	write := func(o spapi.PurchaseOrder) hooks.Event {
+		path := filepath.Join(dir, o.PurchaseOrderNumber+".json")
+		data, _ := json.Marshal(o)
+		os.WriteFile(path, data, 0o644)
+		return hooks.Event{Event: hooks.Generated, Path: path, Type: "order"}
+	}
+
+	if err := n.Run(context.Background(), write(order)); err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	if len(keys) != 2 || keys[0] != keys[1] || keys[0] != IdempotencyKey("123_SB1", "PO1") {
+		t.Errorf("idempotency keys = %q; expected the same key on every attempt", keys)
+	}
+	body, _ := json.Marshal(got)
+	expected := `{"entity":{"id":"77"},"externalId":"AVC-PO1","item":{"items":[{"item":{"id":"501"},"quantity":12,"rate":4.5},{"item":{"id":"502"},"quantity":3}]},"otherRefNum":"PO1","tranDate":"2024-05-02"}`
+	if string(body) != expected {
+		t.Errorf("sales order = %s; expected %s", body, expected)
+	}
+
+	order.PurchaseOrderNumber = "PO2"
+	order.OrderDetails.Items[0].VendorProductIdentifier = "SKU-9"
+	err = n.Run(context.Background(), write(order))
+	if err == nil || !strings.Contains(err.Error(), "no NetSuite item for SKU-9") || len(keys) != 2 {
+		t.Errorf("Run() with an unmapped item returned %v after %d requests; expected a mapping error and no request", err, len(keys))
+	}
+
+	// An EDI 850 carries the canonical order on the event instead of a stored SP‑API order.
+	edi := model.FromPurchaseOrder(order)
+	edi.Source, edi.PONumber, edi.Items[0].VendorSKU = model.SourceEDI, "PO3", "SKU-1"
+	if err := n.Run(context.Background(), hooks.Event{Event: hooks.Generated, Path: "PO3.edi", Type: "edi", Order: &edi}); err != nil {
+		t.Fatalf("Run() for an EDI order returned %v", err)
+	}
+	if len(keys) != 3 || keys[2] != IdempotencyKey("123_SB1", "PO3") || got["otherRefNum"] != "PO3" {
+		t.Errorf("EDI order export sent %d requests with %v; expected PO3 to be created", len(keys), got)
+	}
+	if err := n.Run(context.Background(), hooks.Event{Event: hooks.Generated, Path: "ack.edi", Type: "edi"}); err != nil || len(keys) != 3 {
+		t.Errorf("Run() for a non-order EDI document returned %v after %d requests; expected it to be ignored", err, len(keys))
+	}
+
+	// With currencies mapped, the order's currency is set and unmapped ones fail.
+	n.opts.Currencies = map[string]string{"EUR": "4"}
+	edi.Currency = "eur"
+	so, err := n.SalesOrder(edi)
+	if err != nil || so["currency"].(map[string]string)["id"] != "4" {
+		t.Errorf("SalesOrder() in EUR = %v, %v; expected currency 4", so["currency"], err)
+	}
+	edi.Currency = "GBP"
+	if _, err := n.SalesOrder(edi); err == nil || !strings.Contains(err.Error(), "no NetSuite currency for GBP") {
+		t.Errorf("SalesOrder() in GBP returned %v; expected a currency error", err)
+	}
+}
@@ -0,0 +1,126 @@
+// pkg/export/orders.go
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/storage"
+)
+
+/*
+orderLine is a purchase order item mapped to a target system.
+
+Fields:
+  - Item:     Item ID in the target system.
+  - SKU:      Vendor SKU or ASIN the item was found by.
+  - Quantity: Ordered quantity in eaches.
+  - Rate:     Net cost per each, if the order has one.
+*/
+type orderLine struct {
+	Item     string
+	SKU      string
+	Quantity int
+	Rate     *float64
+}
+
+/*
+eventOrder returns the purchase order an event is about: the canonical order
+the importer attached to it, or, for events raised without one, the SP‑API
+order stored at e.Path if e is of type "order".
+
+Returns:
+  - The order, or nil if the event is not about a purchase order.
+  - An error if the stored order cannot be read.
+*/
+func eventOrder(e hooks.Event) (*model.Order, error) {
+	if e.Order != nil {
+		return e.Order, nil
+	}
+	if e.Type != "order" {
+		return nil, nil
+	}
+	po, err := storage.ReadOrder(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	o := model.FromPurchaseOrder(po)
+	return &o, nil
+}
+
+/*
+orderCustomer returns the customer of an order in a target system: the entry
+of customers for the ship-to party, else for the buying party, else def.
+*/
+func orderCustomer(o model.Order, customers map[string]string, def, target string) (string, error) {
+	for _, p := range []string{o.ShipToParty, o.BuyingParty} {
+		if p == "" {
+			continue
+		}
+		if id, ok := customers[p]; ok {
+			return id, nil
+		}
+	}
+	if def == "" {
+		return "", fmt.Errorf("order %s: no %s customer for its parties", o.PONumber, target)
+	}
+	return def, nil
+}
+
+/*
+orderLines maps every item of an order through the SKU cross-reference.
+Quantities ordered in cases are converted to eaches using the case size.
+
+Returns:
+  - The lines, in order.
+  - An error listing every item that has no ID in the target system.
+*/
+func orderLines(o model.Order, items SKUMap, target string) ([]orderLine, error) {
+	var lines []orderLine
+	var unmapped []string
+	for _, it := range o.Items {
+		sku := firstNonEmpty(it.VendorSKU, it.ASIN, it.ExternalID, it.Sequence)
+		item, ok := items.Item(it.VendorSKU, it.ASIN)
+		if !ok {
+			unmapped = append(unmapped, sku)
+			continue
+		}
+		line := orderLine{Item: item, SKU: sku, Quantity: it.Eaches()}
+		if it.NetCost != nil {
+			rate := *it.NetCost
+			line.Rate = &rate
+		}
+		lines = append(lines, line)
+	}
+	if len(unmapped) > 0 {
+		return nil, fmt.Errorf("order %s: no %s item for %s", o.PONumber, target, strings.Join(unmapped, ", "))
+	}
+	return lines, nil
+}
+
+/*
+IdempotencyKey derives the request idempotency key of a purchase order. It is
+a UUID built from a hash of the account and PO number, so every attempt to
+export the order to the account, from any run, sends the same key.
+*/
+func IdempotencyKey(account, poNumber string) string {
+	sum := sha256.Sum256([]byte("avcimporter:" + account + ":" + poNumber))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x50 // version 5 layout
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,311 @@
+// pkg/export/quickbooks.go
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/money"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Endpoints of QuickBooks Online.
+*/
+const (
+	QuickBooksProductionURL = "https://quickbooks.api.intuit.com"
+	QuickBooksSandboxURL    = "https://sandbox-quickbooks.api.intuit.com"
+	QuickBooksTokenURL      = "https://oauth.platform.intuit.com/oauth2/v1/tokens/bearer"
+)
+
+/*
+TokenStore persists credentials that change at run time; credentials.Store
+implements it.
+*/
+type TokenStore interface {
+	Get(name string) (string, bool, error)
+	Set(name, value string) error
+}
+
+/*
+QuickBooksOptions configures the QuickBooks Online exporter.
+
+Fields:
+  - Name:            Name used in logs and errors.
+  - RealmID:         Company ID of the QuickBooks company.
+  - BaseURL:         API endpoint (default: QuickBooksProductionURL).
+  - TokenURL:        OAuth2 token endpoint (default: QuickBooksTokenURL).
+  - ClientID:        OAuth2 client ID of the app.
+  - ClientSecret:    OAuth2 client secret of the app.
+  - RefreshToken:    Refresh token from authorizing the app; only used until Tokens holds a newer one.
+  - Tokens:          Where the refresh token is kept as QuickBooks replaces it.
+  - Document:        "invoice" (default) or "estimate".
+  - Customers:       Customer IDs keyed by Amazon party ID (ship-to party first, then buying party).
+  - DefaultCustomer: Customer of orders whose parties are not in Customers.
+  - Items:           SKU cross-reference to QuickBooks item IDs.
+  - Retry:           Retry policy for failed requests.
+  - Timeout:         Limit per request (0 means no limit).
+  - Required:        Fail the run when an export fails instead of only logging it.
+  - Logger:          Receives the documents created; nil discards them.
+*/
+type QuickBooksOptions struct {
+	Name            string
+	RealmID         string
+	BaseURL         string
+	TokenURL        string
+	ClientID        string
+	ClientSecret    string
+	RefreshToken    string
+	Tokens          TokenStore
+	Document        string
+	Customers       map[string]string
+	DefaultCustomer string
+	Items           SKUMap
+	Retry           utils.RetryPolicy
+	Timeout         time.Duration
+	Required        bool
+	Logger          *utils.Logger
+}
+
+/*
+QuickBooks is a hook that creates a QuickBooks Online invoice or estimate for
+every imported purchase order. QuickBooks issues a new refresh token with
+every access token and invalidates the old one soon after, so the current
+refresh token is kept in a TokenStore rather than in the config file. Each
+request carries a requestid derived from the PO number, which QuickBooks uses
+to answer a repeated request without creating a second document.
+*/
+type QuickBooks struct {
+	opts   QuickBooksOptions
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+/*
+NewQuickBooks checks opts and fills in its defaults.
+*/
+func NewQuickBooks(opts QuickBooksOptions) (*QuickBooks, error) {
+	if opts.RealmID == "" || opts.ClientID == "" || opts.Tokens == nil {
+		return nil, fmt.Errorf("export %s: quickbooks needs a realmId, clientId and token store", opts.Name)
+	}
+	switch opts.Document {
+	case "":
+		opts.Document = "invoice"
+	case "invoice", "estimate":
+	default:
+		return nil, fmt.Errorf("export %s: unknown quickbooks document %q", opts.Name, opts.Document)
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = QuickBooksProductionURL
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	if opts.TokenURL == "" {
+		opts.TokenURL = QuickBooksTokenURL
+	}
+	if opts.Retry.Name == "" {
+		opts.Retry.Name = "export " + opts.Name
+	}
+	return &QuickBooks{opts: opts, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Name returns the exporter's name.
+func (q *QuickBooks) Name() string { return q.opts.Name }
+
+// Blocking reports whether a failed export fails the run.
+func (q *QuickBooks) Blocking() bool { return q.opts.Required }
+
+// Handles reports whether event is one the exporter sends orders for.
+func (q *QuickBooks) Handles(event string) bool { return event == hooks.Generated }
+
+// Wants reports whether e is about a purchase order.
+func (q *QuickBooks) Wants(e hooks.Event) bool { return e.Order != nil || e.Type == "order" }
+
+/*
+Run creates the invoice or estimate of a purchase order, whether it came from
+SP‑API or an EDI 850. Other documents are ignored. An order that cannot be mapped (unknown customer or
+item) fails without being retried.
+*/
+func (q *QuickBooks) Run(ctx context.Context, e hooks.Event) error {
+	order, err := eventOrder(e)
+	if err != nil || order == nil {
+		return err
+	}
+	doc, err := q.Transaction(*order)
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"minorversion": {"73"}, "requestid": {IdempotencyKey(q.opts.RealmID, order.PONumber)}}
+	target := q.opts.BaseURL + "/v3/company/" + url.PathEscape(q.opts.RealmID) + "/" + q.opts.Document + "?" + query.Encode()
+
+	return utils.Retry(ctx, q.opts.Retry, func(ctx context.Context) error {
+		token, err := q.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := q.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			q.opts.Logger.PrintColoredContext(ctx, "Created QuickBooks "+q.opts.Document+": ", order.PONumber, "#32CD32")
+			return nil
+		case resp.StatusCode == http.StatusBadRequest && bytes.Contains(msg, []byte(`"6140"`)):
+			// Duplicate document number: the order was exported before.
+			q.opts.Logger.LogContext(ctx, utils.LevelWarn, "QuickBooks "+q.opts.Document+" exists already: ", order.PONumber)
+			return nil
+		case resp.StatusCode == http.StatusUnauthorized:
+			q.mu.Lock()
+			q.token = ""
+			q.mu.Unlock()
+		}
+		return &utils.StatusError{Op: "export " + q.opts.Name, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	})
+}
+
+/*
+Transaction maps a purchase order to the body of a QuickBooks Invoice or
+Estimate. The PO number becomes the document number (truncated to the 21
+characters QuickBooks allows) and each item a sales item line priced at its
+net cost. The order's currency becomes the CurrencyRef, so multicurrency
+companies book it in that currency, and line amounts are rounded to its
+minor units.
+
+Returns:
+  - The document.
+  - An error naming the customer or the items that have no QuickBooks ID.
+*/
+func (q *QuickBooks) Transaction(o model.Order) (map[string]interface{}, error) {
+	customer, err := orderCustomer(o, q.opts.Customers, q.opts.DefaultCustomer, "QuickBooks")
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := orderLines(o, q.opts.Items, "QuickBooks")
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]map[string]interface{}, 0, len(mapped))
+	for _, l := range mapped {
+		detail := map[string]interface{}{"ItemRef": map[string]string{"value": l.Item}, "Qty": l.Quantity}
+		amount := 0.0
+		if l.Rate != nil {
+			detail["UnitPrice"] = *l.Rate
+			scale := math.Pow(10, float64(money.Places(o.Currency)))
+			amount = math.Round(*l.Rate*float64(l.Quantity)*scale) / scale
+		}
+		lines = append(lines, map[string]interface{}{
+			"DetailType":          "SalesItemLineDetail",
+			"Amount":              amount,
+			"Description":         l.SKU,
+			"SalesItemLineDetail": detail,
+		})
+	}
+	docNumber := o.PONumber
+	if len(docNumber) > 21 {
+		docNumber = docNumber[:21]
+	}
+	txn := map[string]interface{}{
+		"CustomerRef": map[string]string{"value": customer},
+		"DocNumber":   docNumber,
+		"TxnDate":     o.OrderDate.Format("2006-01-02"),
+		"PrivateNote": "Amazon PO " + o.PONumber,
+		"Line":        lines,
+	}
+	if o.Currency != "" {
+		txn["CurrencyRef"] = map[string]string{"value": strings.ToUpper(o.Currency)}
+	}
+	return txn, nil
+}
+
+/*
+accessToken returns a cached access token, refreshing it (and storing the
+refresh token QuickBooks returns with it) when there is none or it is about to
+expire.
+*/
+func (q *QuickBooks) accessToken(ctx context.Context) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.token != "" && time.Now().Before(q.expiry) {
+		return q.token, nil
+	}
+	key := "quickbooks:" + q.opts.RealmID + ":refreshToken"
+	refresh, found, err := q.opts.Tokens.Get(key)
+	if err != nil {
+		return "", utils.Permanent(err)
+	}
+	if !found {
+		refresh = q.opts.RefreshToken
+	}
+	if refresh == "" {
+		return "", utils.Permanent(fmt.Errorf("export %s: no QuickBooks refresh token; authorize the app and set quickbooks.refreshToken", q.opts.Name))
+	}
+
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.opts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", utils.Permanent(err)
+	}
+	req.SetBasicAuth(q.opts.ClientID, q.opts.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		err := &utils.StatusError{Op: "export " + q.opts.Name + " token refresh", StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+		if bytes.Contains(msg, []byte("invalid_grant")) {
+			return "", utils.Permanent(fmt.Errorf("%w; re-authorize the app and set quickbooks.refreshToken", err))
+		}
+		return "", err
+	}
+	var tok struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", utils.Permanent(fmt.Errorf("export %s: token response has no access_token", q.opts.Name))
+	}
+	if tok.RefreshToken != "" && tok.RefreshToken != refresh {
+		if err := q.opts.Tokens.Set(key, tok.RefreshToken); err != nil {
+			return "", utils.Permanent(fmt.Errorf("failed to store the new QuickBooks refresh token: %w", err))
+		}
+	}
+	q.token = tok.AccessToken
+	// Refresh a minute early so a token does not expire mid-request.
+	q.expiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return q.token, nil
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// memoryTokens is a TokenStore kept in memory.
+type memoryTokens map[string]string
+
+func (m memoryTokens) Get(name string) (string, bool, error) { v, ok := m[name]; return v, ok, nil }
+func (m memoryTokens) Set(name, value string) error          { m[name] = value; return nil }
+
+// TestQuickBooksExport verifies the refresh token is rotated into the token
+// store, the invoice mapping and the idempotent request ID.
+func TestQuickBooksExport(t *testing.T) {
+	var refreshed []string
+	var requestID string
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if id, _, _ := r.BasicAuth(); id != "app" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			refreshed = append(refreshed, r.FormValue("refresh_token"))
+			io.WriteString(w, `{"access_token":"at","refresh_token":"rt-2","expires_in":3600}`)
+		case "/v3/company/4620/invoice":
+			if r.Header.Get("Authorization") != "Bearer at" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			requestID = r.URL.Query().Get("requestid")
+			json.NewDecoder(r.Body).Decode(&got)
+			io.WriteString(w, `{"Invoice":{"Id":"130"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tokens := memoryTokens{}
+	q, err := NewQuickBooks(QuickBooksOptions{
+		Name: "qbo", RealmID: "4620", BaseURL: srv.URL, TokenURL: srv.URL + "/token", ClientID: "app", ClientSecret: "secret",
+		RefreshToken: "rt-1", Tokens: tokens, DefaultCustomer: "58", Items: SKUMap{"SKU-1": "19"},
+	})
+	if err != nil {
+		t.Fatalf("NewQuickBooks() returned %v", err)
+	}
+
+	order := spapi.PurchaseOrder{PurchaseOrderNumber: "2JK3S9VC", OrderDetails: spapi.OrderDetails{
+		PurchaseOrderDate: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+		Items: []spapi.OrderItem{
+			{ItemSequenceNumber: "1", VendorProductIdentifier: "SKU-1", OrderedQuantity: spapi.ItemQuantity{Amount: 3, UnitOfMeasure: "Eaches"}, NetCost: &spapi.Money{CurrencyCode: "USD", Amount: "4.15"}},
+		},
+	}}
+	path := filepath.Join(t.TempDir(), "order.json")
+	data, _ := json.Marshal(order)
+	os.WriteFile(path, data, 0o644)
+	event := hooks.Event{Event: hooks.Generated, Path: path, Type: "order"}
+
+	for i := 0; i < 2; i++ {
+		if err := q.Run(context.Background(), event); err != nil {
+			t.Fatalf("Run() returned %v", err)
+		}
+	}
+	if len(refreshed) != 1 || refreshed[0] != "rt-1" || tokens["quickbooks:4620:refreshToken"] != "rt-2" {
+		t.Errorf("refreshes = %q, stored = %v; expected one refresh with rt-1 and rt-2 stored", refreshed, tokens)
+	}
+	if requestID != IdempotencyKey("4620", "2JK3S9VC") {
+		t.Errorf("requestid = %q; expected the order's idempotency key", requestID)
+	}
+	body, _ := json.Marshal(got)
+	expected := `{"CurrencyRef":{"value":"USD"},"CustomerRef":{"value":"58"},"DocNumber":"2JK3S9VC","Line":[{"Amount":12.45,"Description":"SKU-1","DetailType":"SalesItemLineDetail","SalesItemLineDetail":{"ItemRef":{"value":"19"},"Qty":3,"UnitPrice":4.15}}],"PrivateNote":"Amazon PO 2JK3S9VC","TxnDate":"2024-05-02"}`
+	if string(body) != expected {
+		t.Errorf("invoice = %s; expected %s", body, expected)
+	}
+}
@@ -0,0 +1,358 @@
+// pkg/export/rest.go
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/outbox"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Authentication schemes supported by RESTAuth.Type.
+*/
+const (
+	AuthNone   = ""
+	AuthOAuth2 = "oauth2" // OAuth2 client credentials grant
+	AuthAPIKey = "apiKey" // static key sent in a header
+)
+
+/*
+RESTAuth describes how a REST exporter authenticates.
+
+Fields:
+  - Type:         AuthNone, AuthOAuth2 or AuthAPIKey.
+  - TokenURL:     OAuth2 token endpoint.
+  - ClientID:     OAuth2 client ID.
+  - ClientSecret: OAuth2 client secret.
+  - Scopes:       OAuth2 scopes requested with the token.
+  - Header:       Header carrying the API key (default: "X-API-Key").
+  - Key:          The API key.
+*/
+type RESTAuth struct {
+	Type         string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Header       string
+	Key          string
+}
+
+/*
+RESTOptions configures a REST exporter. URL, header values and Body are
+text/template templates executed with a TemplateData; besides the built-in
+functions they can use json (marshal a value) and env (read an environment
+variable).
+
+Fields:
+  - Name:         Name used in logs and errors.
+  - Documents:    Document types to export (default: "order").
+  - URL:          Target URL.
+  - Method:       HTTP method (default: POST).
+  - Headers:      Extra request headers.
+  - Body:         Request body; empty sends the document unchanged.
+  - ContentType:  Content-Type of the body (default: application/json).
+  - Auth:         Authentication.
+  - Retry:        Retry policy for failed requests.
+  - Timeout:      Limit per request (0 means no limit).
+  - SuccessCodes: Status codes that mean the document was accepted (default: any 2xx).
+  - IgnoreCodes:  Status codes logged and treated as delivered, e.g. 409 for an order the target already has.
+  - Required:     Fail the run when an export fails instead of only logging it.
+  - Logger:       Receives responses treated as delivered; nil discards them.
+*/
+type RESTOptions struct {
+	Name         string
+	Documents    []string
+	URL          string
+	Method       string
+	Headers      map[string]string
+	Body         string
+	ContentType  string
+	Auth         RESTAuth
+	Retry        utils.RetryPolicy
+	Timeout      time.Duration
+	SuccessCodes []int
+	IgnoreCodes  []int
+	Required     bool
+	Logger       *utils.Logger
+}
+
+/*
+TemplateData is the data available to REST exporter templates.
+
+Fields:
+  - Event:    The generated event, e.g. {{.Event.Fields.poNumber}}.
+  - Document: The document decoded from JSON, e.g. {{.Document.purchaseOrderNumber}}; nil for other formats.
+  - Raw:      The document as written.
+  - Order:    The purchase order in canonical form, e.g. {{.Order.PONumber}}, for SP‑API orders and EDI 850s alike; nil for other documents.
+*/
+type TemplateData struct {
+	Event    hooks.Event
+	Document interface{}
+	Raw      string
+	Order    *model.Order
+}
+
+/*
+REST is a hook that sends every generated document of the configured types to
+a REST endpoint, such as an OMS ingest API, so no separate glue service is
+needed between the importer and the system of record.
+*/
+type REST struct {
+	opts    RESTOptions
+	url     *template.Template
+	body    *template.Template
+	headers map[string]*template.Template
+	client  *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// funcs are the functions available to exporter templates besides the built-in ones.
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"env": os.Getenv,
+}
+
+/*
+NewREST compiles the templates of opts and fills in its defaults.
+
+Returns:
+  - The exporter.
+  - An error naming the first invalid template or setting.
+*/
+func NewREST(opts RESTOptions) (*REST, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("export %s has no url", opts.Name)
+	}
+	switch opts.Auth.Type {
+	case AuthNone:
+	case AuthOAuth2:
+		if opts.Auth.TokenURL == "" || opts.Auth.ClientID == "" {
+			return nil, fmt.Errorf("export %s: oauth2 needs a tokenUrl and a clientId", opts.Name)
+		}
+	case AuthAPIKey:
+		if opts.Auth.Key == "" {
+			return nil, fmt.Errorf("export %s: apiKey needs a key", opts.Name)
+		}
+		if opts.Auth.Header == "" {
+			opts.Auth.Header = "X-API-Key"
+		}
+	default:
+		return nil, fmt.Errorf("export %s: unknown auth type %q", opts.Name, opts.Auth.Type)
+	}
+	if len(opts.Documents) == 0 {
+		opts.Documents = []string{"order"}
+	}
+	if opts.Method == "" {
+		opts.Method = http.MethodPost
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/json"
+	}
+	if opts.Retry.Name == "" {
+		opts.Retry.Name = "export " + opts.Name
+	}
+
+	r := &REST{opts: opts, headers: map[string]*template.Template{}, client: &http.Client{Timeout: opts.Timeout}}
+	var err error
+	if r.url, err = template.New("url").Funcs(funcs).Parse(opts.URL); err != nil {
+		return nil, fmt.Errorf("export %s: invalid url template: %w", opts.Name, err)
+	}
+	if opts.Body != "" {
+		if r.body, err = template.New("body").Funcs(funcs).Parse(opts.Body); err != nil {
+			return nil, fmt.Errorf("export %s: invalid body template: %w", opts.Name, err)
+		}
+	}
+	for name, text := range opts.Headers {
+		if r.headers[name], err = template.New(name).Funcs(funcs).Parse(text); err != nil {
+			return nil, fmt.Errorf("export %s: invalid template for header %s: %w", opts.Name, name, err)
+		}
+	}
+	return r, nil
+}
+
+// Name returns the exporter's name.
+func (r *REST) Name() string { return r.opts.Name }
+
+// Blocking reports whether a failed export fails the run.
+func (r *REST) Blocking() bool { return r.opts.Required }
+
+// Handles reports whether event is one the exporter sends documents for.
+func (r *REST) Handles(event string) bool { return event == hooks.Generated }
+
+// Wants reports whether the document of e is of a type the exporter sends.
+func (r *REST) Wants(e hooks.Event) bool { return slices.Contains(r.opts.Documents, e.Type) }
+
+/*
+Run sends the document of e, retrying failures the retry policy allows.
+Documents of other types are ignored. Deliveries through the outbox carry
+their delivery ID in an Idempotency-Key header, unless Headers sets one.
+*/
+func (r *REST) Run(ctx context.Context, e hooks.Event) error {
+	if !slices.Contains(r.opts.Documents, e.Type) {
+		return nil
+	}
+	raw, err := codec.ReadFile(e.Path)
+	if err != nil {
+		return err
+	}
+	data := TemplateData{Event: e, Raw: string(raw)}
+	if json.Valid(raw) {
+		json.Unmarshal(raw, &data.Document)
+	}
+	if o, err := eventOrder(e); err == nil {
+		data.Order = o
+	}
+
+	target, err := render(r.url, data)
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	body := raw
+	if r.body != nil {
+		rendered, err := render(r.body, data)
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		body = []byte(rendered)
+	}
+	headers := make(http.Header, len(r.headers))
+	for name, t := range r.headers {
+		value, err := render(t, data)
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		headers.Set(name, value)
+	}
+	if id := e.Fields[outbox.DeliveryField]; id != "" && headers.Get("Idempotency-Key") == "" {
+		headers.Set("Idempotency-Key", id)
+	}
+
+	return utils.Retry(ctx, r.opts.Retry, func(ctx context.Context) error {
+		return r.send(ctx, target, headers, body)
+	})
+}
+
+// send makes one request and maps its status code to the outcome.
+func (r *REST) send(ctx context.Context, target string, headers http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, r.opts.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	req.Header = headers.Clone()
+	req.Header.Set("Content-Type", r.opts.ContentType)
+	switch r.opts.Auth.Type {
+	case AuthOAuth2:
+		token, err := r.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case AuthAPIKey:
+		req.Header.Set(r.opts.Auth.Header, r.opts.Auth.Key)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+
+	switch {
+	case r.accepted(resp.StatusCode):
+		return nil
+	case slices.Contains(r.opts.IgnoreCodes, resp.StatusCode):
+		r.opts.Logger.LogContext(ctx, utils.LevelWarn, "Export treated as delivered: ", fmt.Sprintf("%s: status %d: %s", r.opts.Name, resp.StatusCode, strings.TrimSpace(string(msg))))
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized && r.opts.Auth.Type == AuthOAuth2:
+		// The token may have been revoked early; the next attempt fetches a new one.
+		r.mu.Lock()
+		r.token = ""
+		r.mu.Unlock()
+	}
+	return &utils.StatusError{Op: "export " + r.opts.Name, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
+
+// accepted reports whether code means the target took the document.
+func (r *REST) accepted(code int) bool {
+	if len(r.opts.SuccessCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	return slices.Contains(r.opts.SuccessCodes, code)
+}
+
+/*
+accessToken returns a cached OAuth2 access token, fetching a new one with the
+client credentials grant when there is none or it is about to expire.
+*/
+func (r *REST) accessToken(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" && time.Now().Before(r.expiry) {
+		return r.token, nil
+	}
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {r.opts.Auth.ClientID}, "client_secret": {r.opts.Auth.ClientSecret}}
+	if len(r.opts.Auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(r.opts.Auth.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.Auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", utils.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", &utils.StatusError{Op: "export " + r.opts.Name + " token request", StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", utils.Permanent(fmt.Errorf("export %s: token response has no access_token", r.opts.Name))
+	}
+	r.token = tok.AccessToken
+	// Refresh a minute early so a token does not expire mid-request.
+	r.expiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return r.token, nil
+}
+
+// render executes t with data.
+func render(t *template.Template, data TemplateData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// TestRESTExport verifies templated requests, OAuth2 token reuse, retries of
+// server errors and the handling of ignored and failing status codes.
+func TestRESTExport(t *testing.T) {
+	var tokens, attempts atomic.Int32
+	var gotBody, gotAuth, gotHeader, gotPath, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens.Add(1)
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "oms" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, `{"access_token":"tok","expires_in":3600}`)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/orders/PO1":
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			gotBody, gotAuth, gotHeader, gotPath = string(b), r.Header.Get("Authorization"), r.Header.Get("X-Source"), r.URL.Path
+			w.WriteHeader(http.StatusCreated)
+		case "/orders/PO2":
+			gotKey = r.Header.Get("Idempotency-Key")
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := NewREST(RESTOptions{
+		Name:        "oms",
+		URL:         srv.URL + "/orders/{{.Event.Fields.poNumber}}",
+		Headers:     map[string]string{"X-Source": "avc-{{.Event.Flow}}"},
+		Body:        `{"po":{{json .Document.purchaseOrderNumber}},"lines":{{len .Document.items}}}`,
+		Auth:        RESTAuth{Type: AuthOAuth2, TokenURL: srv.URL + "/token", ClientID: "oms", ClientSecret: "secret"},
+		Retry:       utils.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+		IgnoreCodes: []int{http.StatusConflict},
+	})
+	if err != nil {
+		t.Fatalf("NewREST() returned %v", err)
+	}
+
+	dir := t.TempDir()
+	event := func(po string) hooks.Event {
+		path := filepath.Join(dir, po+".json")
+		os.WriteFile(path, []byte(`{"purchaseOrderNumber":"`+po+`","items":[{},{}]}`), 0o644)
+		return hooks.Event{Event: hooks.Generated, Flow: "api", Path: path, Type: "order", Fields: map[string]string{"poNumber": po}}
+	}
+	ctx := context.Background()
+
+	if err := r.Run(ctx, event("PO1")); err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	if attempts.Load() != 2 || gotPath != "/orders/PO1" || gotBody != `{"po":"PO1","lines":2}` || gotAuth != "Bearer tok" || gotHeader != "avc-api" {
+		t.Errorf("request after %d attempts = %s %s (auth %q, X-Source %q)", attempts.Load(), gotPath, gotBody, gotAuth, gotHeader)
+	}
+	po2 := event("PO2")
+	po2.Fields["deliveryId"] = "d1"
+	if err := r.Run(ctx, po2); err != nil {
+		t.Errorf("Run() with an ignored status returned %v", err)
+	}
+	if gotKey != "d1" {
+		t.Errorf("Idempotency-Key = %q; expected the delivery ID", gotKey)
+	}
+	err = r.Run(ctx, event("PO3"))
+	if err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Run() with a client error returned %v; expected status 400", err)
+	}
+	if tokens.Load() != 1 {
+		t.Errorf("token requests = %d; expected the token to be reused", tokens.Load())
+	}
+
+	edi := event("PO4")
+	edi.Type = "edi"
+	if err := r.Run(ctx, edi); err != nil {
+		t.Errorf("Run() for a document type not exported returned %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+// pkg/export/skumap.go
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+SKUMap cross-references Amazon products with the item IDs of a target system.
+Keys are vendor SKUs or ASINs.
+*/
+type SKUMap map[string]string
+
+/*
+LoadSKUMap reads a SKU cross-reference from a CSV file with a header row and
+the columns "sku" (vendor SKU or ASIN) and "item" (the item ID in the target
+system). Other columns are ignored, so an export of the item master can be
+used as is.
+
+Returns:
+  - The map.
+  - An error if the file cannot be read, lacks a column or maps a SKU twice.
+*/
+func LoadSKUMap(path string) (SKUMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SKU map: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SKU map header: %w", err)
+	}
+	skuCol, itemCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "sku":
+			skuCol = i
+		case "item":
+			itemCol = i
+		}
+	}
+	if skuCol < 0 || itemCol < 0 {
+		return nil, fmt.Errorf("SKU map %s needs the columns sku and item", path)
+	}
+
+	m := SKUMap{}
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SKU map %s: %w", path, err)
+		}
+		if max(skuCol, itemCol) >= len(rec) {
+			return nil, fmt.Errorf("SKU map %s line %d: missing columns", path, line)
+		}
+		sku, item := strings.TrimSpace(rec[skuCol]), strings.TrimSpace(rec[itemCol])
+		if sku == "" {
+			continue
+		}
+		if _, dup := m[sku]; dup {
+			return nil, fmt.Errorf("SKU map %s line %d: %s is mapped twice", path, line, sku)
+		}
+		m[sku] = item
+	}
+}
+
+/*
+Item returns the item ID of a product, looking up the vendor SKU first and
+then the ASIN.
+*/
+func (m SKUMap) Item(vendorSKU, asin string) (string, bool) {
+	if id, ok := m[vendorSKU]; ok && vendorSKU != "" {
+		return id, true
+	}
+	if id, ok := m[asin]; ok && asin != "" {
+		return id, true
+	}
+	return "", false
+}
@@ -0,0 +1,583 @@
+// pkg/graphql/graphql.go
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+/*
+Field is a field of the Query root type.
+
+Fields:
+  - Description: What the field returns, for documentation.
+  - Args:        Argument types by name: "String", "Int", "Float", "Boolean",
+    "ID" or a list such as "[String]"; a trailing "!" makes an argument required.
+  - Resolve:     Returns the value of the field for the coerced arguments
+    (string, int, float64, bool or []interface{}); absent optional arguments
+    are not in the map.
+*/
+type Field struct {
+	Description string
+	Args        map[string]string
+	Resolve     func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+}
+
+/*
+Schema is a read-only GraphQL API: the fields of its Query root type resolve
+to Go values, and the selection sets below them pick fields from the values'
+JSON encoding, so nested types need no declaration. A field that is absent
+from an object (e.g. omitted as empty) is null, objects must have a
+selection set and scalars must not.
+
+Queries may use aliases, variables, fragments, inline fragments and the
+@include and @skip directives. Mutations, subscriptions and introspection are
+not supported; __typename is "Query" at the root.
+*/
+type Schema struct {
+	Query map[string]Field
+}
+
+/*
+Request is a GraphQL request as sent over HTTP.
+*/
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+/*
+Response is the result of a request. Data is absent when the request could
+not be executed at all; otherwise fields that failed are null and have an
+entry in Errors.
+*/
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+/*
+Error is a request or field error.
+
+Fields:
+  - Message:   What went wrong.
+  - Locations: Line and column of the selection in the query.
+  - Path:      Response keys and list indexes leading to the field.
+*/
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+// Location is a 1-based position in a query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+/*
+Execute runs a query against the schema.
+*/
+func (s *Schema) Execute(ctx context.Context, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		if se, ok := err.(*SyntaxError); ok {
+			return Response{Errors: []Error{{Message: se.Msg, Locations: []Location{{se.Line, se.Column}}}}}
+		}
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	vars, err := coerceVariables(op.variables, req.Variables)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	e := &executor{schema: s, doc: doc, src: req.Query, vars: vars}
+	fields, err := e.collect(op.selection)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if errs := e.validateRoot(fields); len(errs) > 0 {
+		return Response{Errors: errs}
+	}
+
+	data := object{}
+	for _, f := range fields {
+		data = append(data, member{f.key, e.resolveRoot(ctx, f)})
+	}
+	return Response{Data: data, Errors: e.errors}
+}
+
+// operation picks the operation to run.
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables applies defaults and the declared types to the variables.
+func coerceVariables(defs []variableDef, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, d := range defs {
+		v, ok := given[d.name]
+		if !ok && d.hasDef {
+			v, ok = d.def, true
+		}
+		if !ok {
+			if strings.HasSuffix(d.typ, "!") {
+				return nil, fmt.Errorf("variable $%s of type %s is required", d.name, d.typ)
+			}
+			continue
+		}
+		c, err := coerce(v, d.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", d.name, err)
+		}
+		vars[d.name] = c
+	}
+	return vars, nil
+}
+
+/*
+coerce converts an argument or variable value to typ.
+
+Returns:
+  - The value: string, int, float64, bool, nil or []interface{}.
+  - An error if the value does not fit the type.
+*/
+func coerce(v interface{}, typ string) (interface{}, error) {
+	required := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if v == nil {
+		if required {
+			return nil, fmt.Errorf("expected a non-null %s", typ)
+		}
+		return nil, nil
+	}
+	if strings.HasPrefix(typ, "[") && strings.HasSuffix(typ, "]") {
+		elem := typ[1 : len(typ)-1]
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerce(item, elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = c
+		}
+		return list, nil
+	}
+	switch typ {
+	case "String", "ID":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case int64:
+			if typ == "ID" {
+				return fmt.Sprint(x), nil
+			}
+		case float64:
+			if typ == "ID" && x == math.Trunc(x) {
+				return fmt.Sprint(int64(x)), nil
+			}
+		}
+	case "Int":
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case int64:
+			if x >= math.MinInt32 && x <= math.MaxInt32 {
+				return int(x), nil
+			}
+		case float64:
+			if x == math.Trunc(x) && x >= math.MinInt32 && x <= math.MaxInt32 {
+				return int(x), nil
+			}
+		}
+	case "Float":
+		switch x := v.(type) {
+		case int:
+			return float64(x), nil
+		case int64:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	return nil, fmt.Errorf("%s is not a valid %s", describeValue(v), typ)
+}
+
+// describeValue renders a value in error messages.
+func describeValue(v interface{}) string {
+	switch x := v.(type) {
+	case enumValue:
+		return string(x)
+	case string:
+		return fmt.Sprintf("%q", x)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+/*
+executor runs one operation, collecting field errors.
+*/
+type executor struct {
+	schema *Schema
+	doc    *document
+	src    string
+	vars   map[string]interface{}
+	errors []Error
+}
+
+/*
+collected is the selections of one response key, merged from fields,
+fragments and repeated fields.
+*/
+type collected struct {
+	key    string
+	name   string
+	args   []argument
+	offset int
+	sub    []selection
+}
+
+/*
+collect flattens a selection set into its response keys in query order,
+expanding fragments and applying @include and @skip.
+*/
+func (e *executor) collect(set []selection) ([]*collected, error) {
+	var out []*collected
+	byKey := map[string]*collected{}
+	var walk func(set []selection, seen map[string]bool) error
+	walk = func(set []selection, seen map[string]bool) error {
+		for _, s := range set {
+			include, err := e.included(s.directives)
+			if err != nil {
+				return err
+			}
+			if !include {
+				continue
+			}
+			switch {
+			case s.spread != "":
+				f, ok := e.doc.fragments[s.spread]
+				if !ok {
+					return fmt.Errorf("unknown fragment %s", s.spread)
+				}
+				if seen[s.spread] {
+					return fmt.Errorf("fragment %s spreads itself", s.spread)
+				}
+				seen[s.spread] = true
+				if err := walk(f.selection, seen); err != nil {
+					return err
+				}
+				delete(seen, s.spread)
+			case s.name == "":
+				if err := walk(s.selection, seen); err != nil {
+					return err
+				}
+			default:
+				key := s.responseKey()
+				c, ok := byKey[key]
+				if !ok {
+					c = &collected{key: key, name: s.name, args: s.args, offset: s.offset}
+					byKey[key] = c
+					out = append(out, c)
+				} else if c.name != s.name {
+					return fmt.Errorf("fields %s and %s both use the response key %s", c.name, s.name, key)
+				}
+				c.sub = append(c.sub, s.selection...)
+			}
+		}
+		return nil
+	}
+	return out, walk(set, map[string]bool{})
+}
+
+// included evaluates @include(if:) and @skip(if:).
+func (e *executor) included(ds []directive) (bool, error) {
+	for _, d := range ds {
+		if d.name != "include" && d.name != "skip" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		args, err := e.arguments(d.args, map[string]string{"if": "Boolean!"})
+		if err != nil {
+			return false, fmt.Errorf("@%s: %w", d.name, err)
+		}
+		if args["if"].(bool) == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// arguments resolves variables in args and coerces them to the declared types.
+func (e *executor) arguments(args []argument, types map[string]string) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	for _, a := range args {
+		typ, ok := types[a.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown argument %s", a.name)
+		}
+		v, err := e.resolveVariables(a.value)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = coerce(v, typ); err != nil {
+			return nil, fmt.Errorf("argument %s: %w", a.name, err)
+		}
+		if v != nil {
+			out[a.name] = v
+		}
+	}
+	for name, typ := range types {
+		if _, ok := out[name]; !ok && strings.HasSuffix(typ, "!") {
+			return nil, fmt.Errorf("argument %s of type %s is required", name, typ)
+		}
+	}
+	return out, nil
+}
+
+// resolveVariables replaces variable references in a literal.
+func (e *executor) resolveVariables(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case variable:
+		val, ok := e.vars[string(x)]
+		if !ok {
+			return nil, nil
+		}
+		return val, nil
+	case enumValue:
+		return nil, fmt.Errorf("unexpected enum value %s", x)
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i, item := range x {
+			r, err := e.resolveVariables(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = r
+		}
+		return list, nil
+	}
+	return v, nil
+}
+
+// validateRoot reports root fields the schema does not have.
+func (e *executor) validateRoot(fields []*collected) []Error {
+	var errs []Error
+	for _, f := range fields {
+		if f.name == "__typename" {
+			continue
+		}
+		if _, ok := e.schema.Query[f.name]; !ok {
+			msg := fmt.Sprintf("cannot query field %s on type Query", f.name)
+			if strings.HasPrefix(f.name, "__") {
+				msg = "introspection is not supported"
+			}
+			errs = append(errs, Error{Message: msg, Locations: e.locations(f.offset)})
+		}
+	}
+	return errs
+}
+
+// resolveRoot resolves a root field and completes its value.
+func (e *executor) resolveRoot(ctx context.Context, f *collected) interface{} {
+	if f.name == "__typename" {
+		return "Query"
+	}
+	field := e.schema.Query[f.name]
+	path := []interface{}{f.key}
+	args, err := e.arguments(f.args, field.Args)
+	if err != nil {
+		e.fail(f, path, err)
+		return nil
+	}
+	v, err := field.Resolve(ctx, args)
+	if err != nil {
+		e.fail(f, path, err)
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		e.fail(f, path, err)
+		return nil
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		e.fail(f, path, err)
+		return nil
+	}
+	return e.complete(f, generic, path)
+}
+
+/*
+complete shapes a JSON value by the selection set of f: objects keep the
+selected keys, lists are completed element by element and scalars are
+returned as they are.
+*/
+func (e *executor) complete(f *collected, v interface{}, path []interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i, item := range x {
+			list[i] = e.complete(f, item, append(append([]interface{}(nil), path...), i))
+		}
+		return list
+	case map[string]interface{}:
+		if len(f.sub) == 0 {
+			e.fail(f, path, fmt.Errorf("field %s is an object and needs a selection of subfields", f.name))
+			return nil
+		}
+		fields, err := e.collect(f.sub)
+		if err != nil {
+			e.fail(f, path, err)
+			return nil
+		}
+		obj := object{}
+		for _, sub := range fields {
+			subPath := append(append([]interface{}(nil), path...), sub.key)
+			if len(sub.args) > 0 {
+				e.fail(sub, subPath, fmt.Errorf("field %s takes no arguments", sub.name))
+				obj = append(obj, member{sub.key, nil})
+				continue
+			}
+			obj = append(obj, member{sub.key, e.complete(sub, x[sub.name], subPath)})
+		}
+		return obj
+	default:
+		if len(f.sub) > 0 {
+			e.fail(f, path, fmt.Errorf("field %s is a scalar and has no subfields", f.name))
+			return nil
+		}
+		return v
+	}
+}
+
+// fail records a field error.
+func (e *executor) fail(f *collected, path []interface{}, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Locations: e.locations(f.offset), Path: path})
+}
+
+func (e *executor) locations(offset int) []Location {
+	line, col := position(e.src, offset)
+	return []Location{{line, col}}
+}
+
+// object is a JSON object that keeps its keys in query order.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// maxRequest limits the size of a request body.
+const maxRequest = 1 << 20
+
+/*
+ServeHTTP executes GraphQL over HTTP: GET with query, variables (JSON) and
+operationName parameters, or POST with a JSON Request body or an
+application/graphql query. Responses are JSON; requests that cannot be
+executed at all are answered with 400.
+*/
+func (s *Schema) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequest))
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+			return
+		}
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/graphql" {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			writeResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: "invalid request: " + err.Error()}}})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeResponse(w, http.StatusMethodNotAllowed, Response{Errors: []Error{{Message: "use GET or POST"}}})
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeResponse(w, http.StatusBadRequest, Response{Errors: []Error{{Message: "query is required"}}})
+		return
+	}
+	resp := s.Execute(r.Context(), req)
+	code := http.StatusOK
+	if resp.Data == nil {
+		code = http.StatusBadRequest
+	}
+	writeResponse(w, code, resp)
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
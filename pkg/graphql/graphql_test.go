@@ -0,0 +1,174 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testLine struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type testOrder struct {
+	PONumber string     `json:"poNumber"`
+	Vendor   string     `json:"vendor,omitempty"`
+	Lines    []testLine `json:"lines"`
+}
+
+func testSchema() *Schema {
+	orders := []testOrder{
+		{PONumber: "PO1", Vendor: "ACME", Lines: []testLine{{"A", 2}, {"B", 1}}},
+		{PONumber: "PO2", Lines: []testLine{{"C", 5}}},
+	}
+	return &Schema{Query: map[string]Field{
+		"orders": {
+			Args: map[string]string{"limit": "Int", "vendors": "[String]"},
+			Resolve: func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				out := orders
+				if n, ok := args["limit"].(int); ok && n < len(out) {
+					out = out[:n]
+				}
+				return out, nil
+			},
+		},
+		"order": {
+			Args: map[string]string{"poNumber": "String!"},
+			Resolve: func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				for _, o := range orders {
+					if o.PONumber == args["poNumber"] {
+						return o, nil
+					}
+				}
+				return nil, errors.New("order not found")
+			},
+		},
+		"count": {
+			Resolve: func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+				return len(orders), nil
+			},
+		},
+	}}
+}
+
+// execute runs a query and returns the JSON encoding of the response.
+func execute(t *testing.T, query string, vars map[string]interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(testSchema().Execute(context.Background(), Request{Query: query, Variables: vars}))
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return string(data)
+}
+
+// TestExecute verifies selections, aliases, arguments, variables, fragments,
+// directives and the field order of the result.
+func TestExecute(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{"shorthand", `{ count }`, nil, `{"data":{"count":2}}`},
+		{"nested", `{ orders(limit: 1) { poNumber lines { sku quantity } } }`, nil,
+			`{"data":{"orders":[{"poNumber":"PO1","lines":[{"sku":"A","quantity":2},{"sku":"B","quantity":1}]}]}}`},
+		{"alias and order", `{ n: count, first: order(poNumber: "PO2") { lines { quantity } po: poNumber vendor } }`, nil,
+			`{"data":{"n":2,"first":{"lines":[{"quantity":5}],"po":"PO2","vendor":null}}}`},
+		{"variables", `query One($po: String!, $n: Int = 1) { order(poNumber: $po) { poNumber } orders(limit: $n) { poNumber } }`,
+			map[string]interface{}{"po": "PO1"}, `{"data":{"order":{"poNumber":"PO1"},"orders":[{"poNumber":"PO1"}]}}`},
+		{"fragments", `query { order(poNumber: "PO1") { ...ids ... on Order { vendor } } } fragment ids on Order { poNumber }`, nil,
+			`{"data":{"order":{"poNumber":"PO1","vendor":"ACME"}}}`},
+		{"directives", `query($all: Boolean!) { count @skip(if: true) orders(limit: 1) { poNumber lines @include(if: $all) { sku } } }`,
+			map[string]interface{}{"all": false}, `{"data":{"orders":[{"poNumber":"PO1"}]}}`},
+		{"typename", `{ __typename }`, nil, `{"data":{"__typename":"Query"}}`},
+		{"list argument", `{ orders(vendors: "ACME", limit: 0) { poNumber } }`, nil, `{"data":{"orders":[]}}`},
+	} {
+		if got := execute(t, tt.query, tt.vars); got != tt.want {
+			t.Errorf("%s: got %s; expected %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestExecuteErrors verifies that request errors fail the whole request and
+// field errors null only the field, with its path.
+func TestExecuteErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{"syntax", "{\n  orders {", nil, `{"errors":[{"message":"expected a name, got end of document","locations":[{"line":2,"column":11}]}]}`},
+		{"unknown field", `{ count shipments }`, nil, `{"errors":[{"message":"cannot query field shipments on type Query","locations":[{"line":1,"column":9}]}]}`},
+		{"introspection", `{ __schema { types { name } } }`, nil, `{"errors":[{"message":"introspection is not supported","locations":[{"line":1,"column":3}]}]}`},
+		{"missing variable", `query($po: String!) { order(poNumber: $po) { poNumber } }`, nil, `{"errors":[{"message":"variable $po of type String! is required"}]}`},
+		{"resolver", `{ count order(poNumber: "PO9") { poNumber } }`, nil,
+			`{"data":{"count":2,"order":null},"errors":[{"message":"order not found","locations":[{"line":1,"column":9}],"path":["order"]}]}`},
+		{"argument type", `{ orders(limit: "x") { poNumber } }`, nil,
+			`{"data":{"orders":null},"errors":[{"message":"argument limit: \"x\" is not a valid Int","locations":[{"line":1,"column":3}],"path":["orders"]}]}`},
+		{"object without selection", `{ order(poNumber: "PO1") }`, nil,
+			`{"data":{"order":null},"errors":[{"message":"field order is an object and needs a selection of subfields","locations":[{"line":1,"column":3}],"path":["order"]}]}`},
+		{"scalar with selection", `{ orders { lines { sku { x } } } }`, nil,
+			`{"data":{"orders":[{"lines":[{"sku":null},{"sku":null}]},{"lines":[{"sku":null}]}]},"errors":[` +
+				`{"message":"field sku is a scalar and has no subfields","locations":[{"line":1,"column":20}],"path":["orders",0,"lines",0,"sku"]},` +
+				`{"message":"field sku is a scalar and has no subfields","locations":[{"line":1,"column":20}],"path":["orders",0,"lines",1,"sku"]},` +
+				`{"message":"field sku is a scalar and has no subfields","locations":[{"line":1,"column":20}],"path":["orders",1,"lines",0,"sku"]}]}`},
+	} {
+		if got := execute(t, tt.query, tt.vars); got != tt.want {
+			t.Errorf("%s: got %s; expected %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestServeHTTP verifies GET and POST requests and the status codes.
+func TestServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(testSchema())
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		code        int
+		want        string
+	}{
+		{"get", "GET", "/?query=%7Bcount%7D", "", "", http.StatusOK, `{"data":{"count":2}}`},
+		{"post json", "POST", "/", "application/json", `{"query":"query($po: String!) { order(poNumber: $po) { vendor } }","variables":{"po":"PO1"}}`,
+			http.StatusOK, `{"data":{"order":{"vendor":"ACME"}}}`},
+		{"post graphql", "POST", "/", "application/graphql", `{ count }`, http.StatusOK, `{"data":{"count":2}}`},
+		{"missing query", "GET", "/", "", "", http.StatusBadRequest, `{"errors":[{"message":"query is required"}]}`},
+		{"request error", "GET", "/?query=%7Bnope%7D", "", "", http.StatusBadRequest,
+			`{"errors":[{"message":"cannot query field nope on type Query","locations":[{"line":1,"column":2}]}]}`},
+		{"method", "DELETE", "/", "", "", http.StatusMethodNotAllowed, `{"errors":[{"message":"use GET or POST"}]}`},
+	} {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.url, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.code || strings.TrimSpace(body.String()) != tt.want {
+			t.Errorf("%s: got %d %s; expected %d %s", tt.name, resp.StatusCode, body.String(), tt.code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,549 @@
+// pkg/graphql/parse.go
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+document is a parsed GraphQL request document.
+*/
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+/*
+operation is a query of a document.
+*/
+type operation struct {
+	name      string
+	variables []variableDef
+	selection []selection
+}
+
+/*
+variableDef declares a variable of an operation, e.g. "$po: String! = "PO1"".
+*/
+type variableDef struct {
+	name   string
+	typ    string
+	def    interface{}
+	hasDef bool
+}
+
+/*
+fragment is a named fragment definition.
+*/
+type fragment struct {
+	name      string
+	selection []selection
+}
+
+/*
+selection is a field, a fragment spread or an inline fragment. Fields have a
+name; spreads a spread name; inline fragments neither.
+*/
+type selection struct {
+	alias      string
+	name       string
+	args       []argument
+	directives []directive
+	selection  []selection
+	spread     string
+	offset     int
+}
+
+// responseKey is the key of a field in the result: its alias or name.
+func (s selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+/*
+argument is a named value; values are literals (string, int64, float64, bool,
+nil, []interface{}, map[string]interface{}), enums (enumValue) or variables
+(variable).
+*/
+type argument struct {
+	name  string
+	value interface{}
+}
+
+type directive struct {
+	name string
+	args []argument
+}
+
+// variable is a reference to an operation variable in a value.
+type variable string
+
+// enumValue is an unquoted enum literal.
+type enumValue string
+
+/*
+token is a lexical token: a punctuator ("{", "...", ...), a name, a number
+or a string.
+*/
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+/*
+SyntaxError is a query that cannot be parsed, with the line and column of the
+offending token.
+*/
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// parser reads a document from the token stream of src.
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+/*
+parse reads a query document.
+
+Returns:
+  - The document.
+  - A *SyntaxError if src is not a valid document.
+*/
+func parse(src string) (doc *document, err error) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, se
+		}
+	}()
+	p.next()
+	doc = &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.isPunct("{"):
+			doc.operations = append(doc.operations, &operation{selection: p.selectionSet()})
+		case p.tok.kind == tokName && p.tok.text == "query":
+			p.next()
+			doc.operations = append(doc.operations, p.operation())
+		case p.tok.kind == tokName && (p.tok.text == "mutation" || p.tok.text == "subscription"):
+			p.fail(p.tok.text + " operations are not supported")
+		case p.tok.kind == tokName && p.tok.text == "fragment":
+			p.next()
+			f := &fragment{name: p.name()}
+			if _, dup := doc.fragments[f.name]; dup {
+				p.fail("duplicate fragment " + f.name)
+			}
+			p.expectName("on")
+			p.name()
+			f.selection = p.selectionSet()
+			doc.fragments[f.name] = f
+		default:
+			p.fail("unexpected " + p.describe())
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail("no query in document")
+	}
+	return doc, nil
+}
+
+// operation reads a query after the "query" keyword.
+func (p *parser) operation() *operation {
+	op := &operation{}
+	if p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.accept("(") {
+		for !p.accept(")") {
+			p.expect("$")
+			v := variableDef{name: p.name()}
+			p.expect(":")
+			v.typ = p.typeRef()
+			if p.accept("=") {
+				v.def, v.hasDef = p.value(true), true
+			}
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.directives()
+	op.selection = p.selectionSet()
+	return op
+}
+
+// typeRef reads a type such as "String", "[Int!]" or "ID!".
+func (p *parser) typeRef() string {
+	var t string
+	if p.accept("[") {
+		t = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		t = p.name()
+	}
+	if p.accept("!") {
+		t += "!"
+	}
+	return t
+}
+
+// selectionSet reads "{ selection... }".
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var set []selection
+	for !p.accept("}") {
+		set = append(set, p.selection())
+	}
+	if len(set) == 0 {
+		p.fail("empty selection set")
+	}
+	return set
+}
+
+// selection reads a field, a fragment spread or an inline fragment.
+func (p *parser) selection() selection {
+	s := selection{offset: p.tok.offset}
+	if p.accept("...") {
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			s.spread = p.name()
+			s.directives = p.directives()
+			return s
+		}
+		if p.tok.kind == tokName && p.tok.text == "on" {
+			p.next()
+			p.name()
+		}
+		s.directives = p.directives()
+		s.selection = p.selectionSet()
+		return s
+	}
+	s.name = p.name()
+	if p.accept(":") {
+		s.alias, s.name = s.name, p.name()
+	}
+	s.args = p.arguments()
+	s.directives = p.directives()
+	if p.isPunct("{") {
+		s.selection = p.selectionSet()
+	}
+	return s
+}
+
+// arguments reads an optional "(name: value ...)" list.
+func (p *parser) arguments() []argument {
+	if !p.accept("(") {
+		return nil
+	}
+	var args []argument
+	for !p.accept(")") {
+		a := argument{name: p.name()}
+		p.expect(":")
+		a.value = p.value(false)
+		args = append(args, a)
+	}
+	return args
+}
+
+// directives reads any "@name(args)" after a field, spread or operation.
+func (p *parser) directives() []directive {
+	var ds []directive
+	for p.accept("@") {
+		ds = append(ds, directive{name: p.name(), args: p.arguments()})
+	}
+	return ds
+}
+
+// value reads a literal; const forbids variables (in defaults).
+func (p *parser) value(isConst bool) interface{} {
+	t := p.tok
+	switch {
+	case t.kind == tokPunct && t.text == "$" && !isConst:
+		p.next()
+		return variable(p.name())
+	case t.kind == tokInt:
+		p.next()
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			p.failAt(t.offset, "integer out of range: "+t.text)
+		}
+		return n
+	case t.kind == tokFloat:
+		p.next()
+		f, _ := strconv.ParseFloat(t.text, 64)
+		return f
+	case t.kind == tokString:
+		p.next()
+		return t.text
+	case t.kind == tokName:
+		p.next()
+		switch t.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(t.text)
+	case p.accept("["):
+		list := []interface{}{}
+		for !p.accept("]") {
+			list = append(list, p.value(isConst))
+		}
+		return list
+	case p.accept("{"):
+		obj := map[string]interface{}{}
+		for !p.accept("}") {
+			name := p.name()
+			p.expect(":")
+			obj[name] = p.value(isConst)
+		}
+		return obj
+	}
+	p.fail("expected a value, got " + p.describe())
+	return nil
+}
+
+func (p *parser) isPunct(s string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == s
+}
+
+// accept consumes the punctuator s if it is next.
+func (p *parser) accept(s string) bool {
+	if p.isPunct(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.accept(s) {
+		p.fail(fmt.Sprintf("expected %q, got %s", s, p.describe()))
+	}
+}
+
+func (p *parser) expectName(s string) {
+	if p.tok.kind != tokName || p.tok.text != s {
+		p.fail(fmt.Sprintf("expected %q, got %s", s, p.describe()))
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.fail("expected a name, got " + p.describe())
+	}
+	n := p.tok.text
+	p.next()
+	return n
+}
+
+// describe names the current token in errors.
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokEOF:
+		return "end of document"
+	case tokString:
+		return "string " + strconv.Quote(p.tok.text)
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *parser) fail(msg string) {
+	p.failAt(p.tok.offset, msg)
+}
+
+func (p *parser) failAt(offset int, msg string) {
+	line, col := position(p.src, offset)
+	panic(&SyntaxError{Line: line, Column: col, Msg: msg})
+}
+
+// position returns the 1-based line and column of offset in src.
+func position(src string, offset int) (int, int) {
+	before := src[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return line, col
+}
+
+// next reads the next token, skipping white space, commas and comments.
+func (p *parser) next() {
+	src := p.src
+	for p.pos < len(src) {
+		c := src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(src) && src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(src) {
+		p.tok = token{kind: tokEOF, offset: start}
+		return
+	}
+	c := src[p.pos]
+	switch {
+	case strings.HasPrefix(src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, text: "...", offset: start}
+	case strings.IndexByte("{}()[]:!$=@|&", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokPunct, text: string(c), offset: start}
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		for p.pos < len(src) && isNameChar(src[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, text: src[start:p.pos], offset: start}
+	case c == '-' || c >= '0' && c <= '9':
+		p.number(start)
+	case c == '"':
+		p.str(start)
+	default:
+		p.pos++
+		p.failAt(start, fmt.Sprintf("unexpected character %q", c))
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// number reads an IntValue or FloatValue.
+func (p *parser) number(start int) {
+	src := p.src
+	if src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() int {
+		n := 0
+		for p.pos < len(src) && src[p.pos] >= '0' && src[p.pos] <= '9' {
+			p.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		p.failAt(start, "invalid number")
+	}
+	kind := tokInt
+	if p.pos < len(src) && src[p.pos] == '.' {
+		p.pos++
+		kind = tokFloat
+		if digits() == 0 {
+			p.failAt(start, "invalid number")
+		}
+	}
+	if p.pos < len(src) && (src[p.pos] == 'e' || src[p.pos] == 'E') {
+		p.pos++
+		kind = tokFloat
+		if p.pos < len(src) && (src[p.pos] == '+' || src[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			p.failAt(start, "invalid number")
+		}
+	}
+	if p.pos < len(src) && (isNameChar(src[p.pos]) || src[p.pos] == '.') {
+		p.failAt(start, "invalid number")
+	}
+	p.tok = token{kind: kind, text: src[start:p.pos], offset: start}
+}
+
+// str reads a quoted string or a """block string""".
+func (p *parser) str(start int) {
+	src := p.src
+	if strings.HasPrefix(src[p.pos:], `"""`) {
+		end := strings.Index(src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.failAt(start, "unterminated block string")
+		}
+		text := src[p.pos+3 : p.pos+3+end]
+		p.pos += 6 + end
+		p.tok = token{kind: tokString, text: strings.TrimSpace(text), offset: start}
+		return
+	}
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(src) || src[p.pos] == '\n' {
+			p.failAt(start, "unterminated string")
+		}
+		c := src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(src) {
+			p.failAt(start, "unterminated string")
+		}
+		esc := src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(src) {
+				p.failAt(start, "invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.failAt(start, "invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.failAt(start, fmt.Sprintf("invalid escape \\%c", esc))
+		}
+	}
+	p.tok = token{kind: tokString, text: b.String(), offset: start}
+}
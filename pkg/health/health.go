@@ -0,0 +1,216 @@
+// pkg/health/health.go
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+FlowStatus is the externally visible state of one flow.
+
+Fields:
+  - LastRun:             When the most recent run finished.
+  - LastSuccess:         When the most recent successful run finished.
+  - LastError:           The error of the most recent failed run.
+  - LastErrorAt:         When that failed run finished.
+  - ConsecutiveFailures: Failed runs since the last success.
+  - StaleAfter:          Maximum age of LastSuccess before the flow counts as stuck.
+  - Stale:               Whether the flow is currently considered stuck.
+*/
+type FlowStatus struct {
+	LastRun             *time.Time `json:"lastRun,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	StaleAfter          string     `json:"staleAfter"`
+	Stale               bool       `json:"stale"`
+}
+
+// flow holds the tracked state of one flow.
+type flow struct {
+	status     FlowStatus
+	staleAfter time.Duration
+}
+
+/*
+Tracker records the outcome of each flow run and serves it over HTTP for
+liveness/readiness probes and monitoring.
+*/
+type Tracker struct {
+	mu          sync.RWMutex
+	started     time.Time
+	ready       bool
+	flows       map[string]*flow
+	checkpoints *checkpoint.Store
+	logger      *utils.Logger
+}
+
+/*
+NewTracker returns an empty Tracker. It reports not-ready until SetReady is called.
+*/
+func NewTracker() *Tracker {
+	return &Tracker{started: time.Now(), flows: make(map[string]*flow)}
+}
+
+/*
+Register adds a flow to the tracker.
+
+Parameters:
+  - name:       The flow name.
+  - staleAfter: How long the flow may go without a successful run (counted from
+    start-up before its first success) before /healthz reports it stuck.
+    Zero disables the check.
+*/
+func (t *Tracker) Register(name string, staleAfter time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.flows[name]
+	if !ok {
+		f = &flow{}
+		t.flows[name] = f
+	}
+	f.staleAfter = staleAfter
+	f.status.StaleAfter = staleAfter.String()
+}
+
+/*
+SetReady marks the importer as ready to run its flows.
+*/
+func (t *Tracker) SetReady(ready bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ready = ready
+}
+
+/*
+SetCheckpoints makes /status report the positions held in store.
+*/
+func (t *Tracker) SetCheckpoints(store *checkpoint.Store) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.checkpoints = store
+}
+
+/*
+SetLogger makes /status list the file transfers in progress on l.
+*/
+func (t *Tracker) SetLogger(l *utils.Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logger = l
+}
+
+/*
+Record stores the outcome of a flow run that finished at the given time.
+*/
+func (t *Tracker) Record(name string, finished time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.flows[name]
+	if !ok {
+		f = &flow{}
+		t.flows[name] = f
+	}
+	f.status.LastRun = &finished
+	if err != nil {
+		f.status.LastError = err.Error()
+		f.status.LastErrorAt = &finished
+		f.status.ConsecutiveFailures++
+		return
+	}
+	f.status.LastSuccess = &finished
+	f.status.ConsecutiveFailures = 0
+}
+
+/*
+Snapshot returns a copy of every flow's status as of now.
+*/
+func (t *Tracker) Snapshot() map[string]FlowStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	now := time.Now()
+	out := make(map[string]FlowStatus, len(t.flows))
+	for name, f := range t.flows {
+		st := f.status
+		if f.staleAfter > 0 {
+			since := t.started
+			if st.LastSuccess != nil {
+				since = *st.LastSuccess
+			}
+			st.Stale = now.Sub(since) > f.staleAfter
+		}
+		out[name] = st
+	}
+	return out
+}
+
+/*
+Handler returns an http.Handler serving:
+  - /healthz: 200 while no flow is stale, 503 otherwise (liveness).
+  - /readyz:  200 once SetReady(true) was called, 503 before (readiness).
+  - /status:  JSON document with the state of every flow, the stored checkpoints
+    and the file transfers in progress (see SetLogger and utils.Logger.Transfers).
+*/
+func (t *Tracker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		var stale []string
+		for name, st := range t.Snapshot() {
+			if st.Stale {
+				stale = append(stale, name)
+			}
+		}
+		sort.Strings(stale)
+		if len(stale) > 0 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "stale", "flows": stale})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		t.mu.RLock()
+		ready := t.ready
+		t.mu.RUnlock()
+		if !ready {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		t.mu.RLock()
+		store, logger := t.checkpoints, t.logger
+		t.mu.RUnlock()
+		status := map[string]interface{}{
+			"startedAt": t.started,
+			"flows":     t.Snapshot(),
+			"transfers": logger.Transfers(),
+		}
+		if store != nil {
+			if all, err := store.All(); err != nil {
+				status["checkpointError"] = err.Error()
+			} else {
+				status["checkpoints"] = all
+			}
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	return mux
+}
+
+// writeJSON writes v as an indented JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,245 @@
+// pkg/history/history.go
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Entry is the outcome of one flow run.
+
+Fields:
+  - Flow:       Flow name ("edi", "api", ...).
+  - StartedAt:  When the run began.
+  - FinishedAt: When the run ended.
+  - Success:    Whether the run completed without error.
+  - Files:      Files downloaded or written.
+  - POs:        Purchase orders seen.
+  - Bytes:      Bytes downloaded.
+  - Error:      The failure message, if any.
+  - Host:       Host the run executed on.
+  - Profile:    Config profile in effect, if any.
+*/
+type Entry struct {
+	Flow       string    `json:"flow"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Success    bool      `json:"success"`
+	Files      int       `json:"files"`
+	POs        int       `json:"pos"`
+	Bytes      int64     `json:"bytes"`
+	Error      string    `json:"error,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Profile    string    `json:"profile,omitempty"`
+}
+
+/*
+Filter selects entries for List.
+
+Fields:
+  - Flow:       Only runs of this flow; empty matches all.
+  - Since:      Only runs started at or after this time; zero matches all.
+  - FailedOnly: Only failed runs.
+  - Limit:      At most this many entries, the newest; 0 means no limit.
+*/
+type Filter struct {
+	Flow       string
+	Since      time.Time
+	FailedOnly bool
+	Limit      int
+}
+
+// match reports whether e passes the filter (ignoring Limit).
+func (f Filter) match(e Entry) bool {
+	return (f.Flow == "" || e.Flow == f.Flow) &&
+		(f.Since.IsZero() || !e.StartedAt.Before(f.Since)) &&
+		(!f.FailedOnly || !e.Success)
+}
+
+/*
+Store is the run history, one JSON line per run appended to a file, so
+recording stays cheap however long the history is. Entries older than the
+retention are dropped once the oldest one expires. Several processes (cron
+runs and the daemon) may append to the same file.
+*/
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	perms     *utils.Permissions
+}
+
+/*
+Open returns the Store backed by the file at path. A retention of 0 keeps
+entries forever. The file is created with the first Record, with perms (nil
+uses utils.DefaultPermissions).
+*/
+func Open(path string, retention time.Duration, perms *utils.Permissions) *Store {
+	return &Store{path: path, retention: retention, perms: perms}
+}
+
+/*
+Path returns the file backing the store.
+*/
+func (s *Store) Path() string {
+	return s.path
+}
+
+/*
+Record appends e and prunes expired entries.
+*/
+func (s *Store) Record(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode run history: %w", err)
+	}
+	if err := s.perms.MkdirAll(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("failed to create run history dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open run history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := s.perms.Apply(s.path); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	return s.prune(time.Now())
+}
+
+/*
+List returns the entries matching f, newest first.
+*/
+func (s *Store) List(f Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for i := len(all) - 1; i >= 0; i-- {
+		if f.match(all[i]) {
+			out = append(out, all[i])
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+/*
+LastSuccess returns the most recent successful run of every flow in the history.
+*/
+func (s *Store) LastSuccess() (map[string]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]Entry{}
+	for _, e := range all {
+		if prev, ok := last[e.Flow]; e.Success && (!ok || e.FinishedAt.After(prev.FinishedAt)) {
+			last[e.Flow] = e
+		}
+	}
+	return last, nil
+}
+
+// read loads every entry in file order; a missing file is an empty history.
+// A line that is not valid JSON (e.g. cut short by a crash) is skipped.
+func (s *Store) read() ([]Entry, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	var all []Entry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			all = append(all, e)
+		}
+	}
+	return all, sc.Err()
+}
+
+// prune rewrites the file without expired entries when its first entry has
+// expired; otherwise it leaves the file alone.
+func (s *Store) prune(now time.Time) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-s.retention)
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read run history: %w", err)
+	}
+	var first Entry
+	line, _ := bufio.NewReader(f).ReadBytes('\n')
+	f.Close()
+	if json.Unmarshal(line, &first) == nil && !first.StartedAt.Before(cutoff) {
+		return nil
+	}
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, e := range all {
+		if e.StartedAt.Before(cutoff) {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode run history: %w", err)
+		}
+		b.Write(append(data, '\n'))
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := s.perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestListFilters verifies entries come back newest first and filtered.
+func TestListFilters(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, nil)
+	start := time.Now().Add(-time.Hour)
+	for i, e := range []Entry{
+		{Flow: "edi", Success: true},
+		{Flow: "api", Success: false, Error: "throttled"},
+		{Flow: "edi", Success: false, Error: "connection refused"},
+		{Flow: "api", Success: true, POs: 4},
+	} {
+		e.StartedAt = start.Add(time.Duration(i) * time.Minute)
+		e.FinishedAt = e.StartedAt.Add(time.Second)
+		if err := s.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{}, "api ok,edi failed,api failed,edi ok"},
+		{Filter{FailedOnly: true}, "edi failed,api failed"},
+		{Filter{Flow: "api", Limit: 1}, "api ok"},
+		{Filter{Since: start.Add(90 * time.Second)}, "api ok,edi failed"},
+	} {
+		entries, err := s.List(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			result := "ok"
+			if !e.Success {
+				result = "failed"
+			}
+			got = append(got, e.Flow+" "+result)
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("List(%+v) = %s; expected %s", tc.filter, strings.Join(got, ","), tc.want)
+		}
+	}
+
+	last, err := s.LastSuccess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last["edi"].StartedAt.Equal(start) || last["api"].POs != 4 {
+		t.Errorf("LastSuccess() = %+v", last)
+	}
+}
+
+// TestRecordPrunes verifies expired entries are dropped once the oldest expires.
+func TestRecordPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := Open(path, 24*time.Hour, nil)
+	old := time.Now().Add(-48 * time.Hour)
+	if err := s.Record(Entry{Flow: "edi", StartedAt: old, FinishedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(Entry{Flow: "api", StartedAt: time.Now(), FinishedAt: time.Now(), Success: true}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 || !strings.Contains(string(data), `"flow":"api"`) {
+		t.Errorf("history file =\n%s\nexpected only the recent run", data)
+	}
+}
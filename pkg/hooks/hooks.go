@@ -0,0 +1,226 @@
+// pkg/hooks/hooks.go
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/model"
+)
+
+/*
+Events a hook can subscribe to.
+*/
+const (
+	Downloaded = "downloaded" // file fetched from a remote source (SFTP, report, Data Kiosk document)
+	Generated  = "generated"  // document written by the importer (transaction set, order, summary workbook)
+	Changed    = "changed"    // re-fetched purchase order differs from the stored version
+	SLA        = "sla"        // purchase order document due soon or overdue; no file
+	Throttled  = "throttled"  // SP‑API operation throttled beyond api.throttle.alertAfter; no file
+)
+
+/*
+Event describes the file a hook is run for. Command hooks receive it as JSON
+on stdin and as AVC_* environment variables.
+
+Fields:
+  - Event:      Downloaded, Generated, Changed, SLA or Throttled.
+  - Flow:       Flow that produced the file ("edi", "api", "reports", "datakiosk", "sla", "summary").
+  - Path:       Local path of the file (empty for SLA).
+  - Type:       Document type ("inbound", "edi", "order", "report", "datakiosk", "summary", "parquet").
+  - RemotePath: Where a downloaded file came from.
+  - Size:       File size in bytes.
+  - SHA256:     Hex SHA‑256 of the content, where already known.
+  - Fields:     Type-specific details (e.g. PO number, report type).
+  - Order:      The purchase order in canonical form, for SP‑API orders and EDI 850s alike.
+*/
+type Event struct {
+	Event      string            `json:"event"`
+	Flow       string            `json:"flow"`
+	Path       string            `json:"path"`
+	Type       string            `json:"type"`
+	RemotePath string            `json:"remotePath,omitempty"`
+	Size       int64             `json:"size"`
+	SHA256     string            `json:"sha256,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Order      *model.Order      `json:"order,omitempty"`
+}
+
+/*
+Hook is a processing step run for every file it subscribes to. It may inspect
+or rewrite the file in place. Programs embedding the importer can implement it
+in Go and add it with Register; Command covers external scripts.
+*/
+type Hook interface {
+	// Name identifies the hook in log output and errors.
+	Name() string
+	// Handles reports whether the hook wants events of the given kind.
+	Handles(event string) bool
+	// Run processes one file. An error rejects the file if the hook is blocking.
+	Run(ctx context.Context, e Event) error
+	// Blocking reports whether a failure rejects the file instead of only being logged.
+	Blocking() bool
+}
+
+/*
+Command runs an external program for each event, e.g. a virus scanner or a
+transformation script. The program gets the event as JSON on stdin and as
+environment variables (AVC_EVENT, AVC_FLOW, AVC_FILE, AVC_TYPE,
+AVC_REMOTE_PATH, AVC_SIZE, AVC_SHA256 and AVC_FIELD_<NAME>); a non-zero exit
+status is a failure.
+
+Fields:
+  - HookName: Name used in logs.
+  - Args:     Program and arguments.
+  - Events:   Events to run for (empty runs for Downloaded and Generated).
+  - Timeout:  Kill the program after this long (0 means no limit).
+  - Required: Reject the file when the program fails.
+*/
+type Command struct {
+	HookName string
+	Args     []string
+	Events   []string
+	Timeout  time.Duration
+	Required bool
+}
+
+// Name returns the hook's name.
+func (c *Command) Name() string { return c.HookName }
+
+// Blocking reports whether a failure rejects the file.
+func (c *Command) Blocking() bool { return c.Required }
+
+// Handles reports whether the command subscribes to event.
+func (c *Command) Handles(event string) bool {
+	if len(c.Events) == 0 {
+		return event == Downloaded || event == Generated
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the command for e.
+func (c *Command) Run(ctx context.Context, e Event) error {
+	if len(c.Args) == 0 {
+		return fmt.Errorf("hook %s has no command", c.HookName)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	input, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), e.env()...)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, truncate(msg, 500))
+		}
+		return err
+	}
+	return nil
+}
+
+// env returns the event as AVC_* environment variables.
+func (e Event) env() []string {
+	vars := []string{
+		"AVC_EVENT=" + e.Event,
+		"AVC_FLOW=" + e.Flow,
+		"AVC_FILE=" + e.Path,
+		"AVC_TYPE=" + e.Type,
+		"AVC_REMOTE_PATH=" + e.RemotePath,
+		"AVC_SIZE=" + strconv.FormatInt(e.Size, 10),
+		"AVC_SHA256=" + e.SHA256,
+	}
+	for k, v := range e.Fields {
+		vars = append(vars, "AVC_FIELD_"+strings.ToUpper(k)+"="+v)
+	}
+	return vars
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+var (
+	mu         sync.RWMutex
+	configured []Hook
+	registered []Hook
+)
+
+/*
+Set replaces the hooks built from the hooks config. It is called in `main.go`
+at start-up and again on reload; hooks added with Register are kept.
+*/
+func Set(hs ...Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	configured = append([]Hook(nil), hs...)
+}
+
+/*
+Register adds a Go hook, run after the configured ones.
+*/
+func Register(h Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, h)
+}
+
+/*
+Run passes e to every installed hook that handles it, in order, stopping at
+the first blocking hook that fails. The Size is filled in from the file when
+it is zero.
+
+Returns:
+  - nil if the file may be processed further.
+  - The failure of a blocking hook; failures of other hooks go to warn (which may be nil).
+*/
+func Run(ctx context.Context, e Event, warn func(hook string, err error)) error {
+	mu.RLock()
+	hs := append(append([]Hook(nil), configured...), registered...)
+	mu.RUnlock()
+	if len(hs) == 0 {
+		return nil
+	}
+	if e.Size == 0 {
+		if info, err := os.Stat(e.Path); err == nil {
+			e.Size = info.Size()
+		}
+	}
+	for _, h := range hs {
+		if !h.Handles(e.Event) {
+			continue
+		}
+		err := h.Run(ctx, e)
+		switch {
+		case err == nil:
+		case h.Blocking():
+			return fmt.Errorf("hook %s rejected %s: %w", h.Name(), e.Path, err)
+		case warn != nil:
+			warn(h.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCommandReceivesEvent verifies the event reaches the command via env and stdin.
+func TestCommandReceivesEvent(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	c := &Command{HookName: "dump", Args: []string{"sh", "-c", `echo "$AVC_EVENT $AVC_FILE $AVC_FIELD_PONUMBER" > "$0"; cat >> "$0"`, out}}
+	Set(c)
+	defer Set()
+
+	e := Event{Event: Downloaded, Flow: "edi", Path: "/tmp/po.edi", Fields: map[string]string{"poNumber": "PO1"}}
+	if err := Run(context.Background(), e, nil); err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	if lines[0] != "downloaded /tmp/po.edi PO1" {
+		t.Errorf("env line = %q; expected %q", lines[0], "downloaded /tmp/po.edi PO1")
+	}
+	if !strings.Contains(lines[1], `"flow":"edi"`) {
+		t.Errorf("stdin = %q; expected the event as JSON", lines[1])
+	}
+}
+
+// TestRunBlocking verifies only required hooks reject a file and later hooks are skipped.
+func TestRunBlocking(t *testing.T) {
+	var warned []string
+	warn := func(hook string, err error) { warned = append(warned, hook) }
+	after := &recorder{name: "after"}
+
+	Set(&Command{HookName: "lint", Args: []string{"false"}}, &Command{HookName: "other", Args: []string{"false"}, Events: []string{Generated}})
+	Register(after)
+	defer func() { Set(); registered = nil }()
+
+	if err := Run(context.Background(), Event{Event: Downloaded}, warn); err != nil {
+		t.Fatalf("Run() returned %v for an optional hook", err)
+	}
+	if len(warned) != 1 || warned[0] != "lint" || after.calls != 1 {
+		t.Errorf("warned = %v, after.calls = %d; expected [lint] and 1", warned, after.calls)
+	}
+
+	Set(&Command{HookName: "scan", Args: []string{"sh", "-c", "echo infected; exit 1"}, Required: true})
+	err := Run(context.Background(), Event{Event: Downloaded, Path: "x"}, warn)
+	if err == nil || !strings.Contains(err.Error(), "infected") {
+		t.Errorf("Run() = %v; expected the scanner's rejection", err)
+	}
+	if after.calls != 1 {
+		t.Error("hooks after a rejection should not run")
+	}
+}
+
+type recorder struct {
+	name  string
+	calls int
+}
+
+func (r *recorder) Name() string              { return r.name }
+func (r *recorder) Handles(event string) bool { return true }
+func (r *recorder) Blocking() bool            { return false }
+func (r *recorder) Run(ctx context.Context, e Event) error {
+	r.calls++
+	if e.Event == "" {
+		return errors.New("empty event")
+	}
+	return nil
+}
+
+// TestOrderedFlushesInSequence verifies an Ordered hook holds events within a
+// Sequence and releases them sorted, and runs them at once outside one.
+func TestOrderedFlushesInSequence(t *testing.T) {
+	var got []string
+	h := &Ordered{Hook: &collector{runs: &got}, By: ByPONumber}
+
+	ctx, seq := WithSequence(context.Background())
+	for _, po := range []string{"120", "", "9", "35"} {
+		fields := map[string]string{}
+		if po != "" {
+			fields["poNumber"] = po
+		}
+		if err := h.Run(ctx, Event{Event: Generated, Path: "po" + po, Fields: fields}); err != nil {
+			t.Fatalf("Run() returned %v", err)
+		}
+	}
+	if len(got) != 0 {
+		t.Fatalf("events ran before Flush: %v", got)
+	}
+	if err := seq.Flush(context.Background(), nil); err != nil {
+		t.Fatalf("Flush() returned %v", err)
+	}
+	if want := "po9 po35 po120 po"; strings.Join(got, " ") != want {
+		t.Errorf("released %v; expected %s", got, want)
+	}
+
+	got = nil
+	h.Run(context.Background(), Event{Event: Generated, Path: "now"})
+	if len(got) != 1 {
+		t.Errorf("event outside a Sequence was not run at once: %v", got)
+	}
+}
+
+type collector struct {
+	runs *[]string
+}
+
+func (c *collector) Name() string              { return "collector" }
+func (c *collector) Handles(event string) bool { return true }
+func (c *collector) Blocking() bool            { return true }
+func (c *collector) Run(ctx context.Context, e Event) error {
+	*c.runs = append(*c.runs, e.Path)
+	return nil
+}
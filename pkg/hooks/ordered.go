@@ -0,0 +1,165 @@
+// pkg/hooks/ordered.go
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+Orders an Ordered hook can release its events in.
+*/
+const (
+	ByPONumber  = "poNumber"  // PO number, numeric ones by value
+	ByTimestamp = "timestamp" // order date of the purchase order
+)
+
+/*
+Ordered holds the events of Hook while a Sequence is in effect (see
+WithSequence) and runs them when the Sequence is flushed, sorted by PO number
+or order date, so a target that needs orders in sequence gets them that way
+even though they were processed concurrently. Events of equal rank, and
+events without a PO number or order, keep the order they arrived in; the
+latter go last. Outside a Sequence events run immediately.
+
+A held event cannot reject its file: failures of a blocking hook are returned
+by Flush instead.
+*/
+type Ordered struct {
+	Hook
+	By string
+}
+
+// Run holds e if ctx carries a Sequence and runs it otherwise.
+func (o *Ordered) Run(ctx context.Context, e Event) error {
+	if s := SequenceFromContext(ctx); s != nil {
+		s.hold(o, e)
+		return nil
+	}
+	return o.Hook.Run(ctx, e)
+}
+
+// sequenceKey is the context key of the Sequence.
+type sequenceKey struct{}
+
+/*
+Sequence collects the events Ordered hooks hold during one batch of work,
+e.g. an SP‑API response or an X12 interchange.
+*/
+type Sequence struct {
+	mu    sync.Mutex
+	hooks []*Ordered
+	held  map[*Ordered][]Event
+}
+
+/*
+WithSequence returns ctx carrying a new Sequence; Ordered hooks run with the
+returned context hold their events until Flush.
+*/
+func WithSequence(ctx context.Context) (context.Context, *Sequence) {
+	s := &Sequence{held: map[*Ordered][]Event{}}
+	return context.WithValue(ctx, sequenceKey{}, s), s
+}
+
+/*
+SequenceFromContext returns the Sequence of ctx, or nil.
+*/
+func SequenceFromContext(ctx context.Context) *Sequence {
+	s, _ := ctx.Value(sequenceKey{}).(*Sequence)
+	return s
+}
+
+/*
+ContextWithSequence returns ctx carrying s, e.g. to run deferred hooks in the
+Sequence of the work that queued them. A nil s returns ctx unchanged.
+*/
+func ContextWithSequence(ctx context.Context, s *Sequence) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sequenceKey{}, s)
+}
+
+// hold queues e for o.
+func (s *Sequence) hold(o *Ordered, e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.held[o]; !ok {
+		s.hooks = append(s.hooks, o)
+	}
+	s.held[o] = append(s.held[o], e)
+}
+
+/*
+Flush runs the held events of every Ordered hook in its order, hook by hook,
+and empties the Sequence. A failure does not stop the later events, which
+would otherwise never be sent.
+
+Returns:
+  - The failures of blocking hooks joined; failures of other hooks go to warn (which may be nil).
+*/
+func (s *Sequence) Flush(ctx context.Context, warn func(hook string, err error)) error {
+	s.mu.Lock()
+	hs, held := s.hooks, s.held
+	s.hooks, s.held = nil, map[*Ordered][]Event{}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, o := range hs {
+		events := held[o]
+		sort.SliceStable(events, func(i, j int) bool { return o.before(events[i], events[j]) })
+		for _, e := range events {
+			err := o.Hook.Run(ctx, e)
+			switch {
+			case err == nil:
+			case o.Blocking():
+				errs = append(errs, fmt.Errorf("hook %s rejected %s: %w", o.Name(), e.Path, err))
+			case warn != nil:
+				warn(o.Name(), err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// before reports whether a is released before b.
+func (o *Ordered) before(a, b Event) bool {
+	if o.By == ByTimestamp {
+		if a.Order == nil || b.Order == nil {
+			return a.Order != nil
+		}
+		return a.Order.OrderDate.Before(b.Order.OrderDate)
+	}
+	pa, pb := poNumber(a), poNumber(b)
+	if pa == "" || pb == "" {
+		return pa != ""
+	}
+	if numeric(pa) && numeric(pb) && len(pa) != len(pb) {
+		return len(pa) < len(pb)
+	}
+	return pa < pb
+}
+
+// poNumber returns the PO number of e, or "".
+func poNumber(e Event) string {
+	if po := e.Fields["poNumber"]; po != "" {
+		return po
+	}
+	if e.Order != nil {
+		return e.Order.PONumber
+	}
+	return ""
+}
+
+// numeric reports whether s consists of digits only.
+func numeric(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
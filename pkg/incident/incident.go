@@ -0,0 +1,231 @@
+// pkg/incident/incident.go
+package incident
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+)
+
+/*
+Incident describes a flow that keeps failing.
+
+Fields:
+  - Key:       Deduplication key, stable per flow, so repeated triggers update one
+    incident and Resolve closes it.
+  - Flow:      The failing flow, e.g. "api".
+  - Summary:   One-line title.
+  - Source:    Host the importer runs on.
+  - Failures:  Consecutive failed runs.
+  - Since:     When the first of them finished.
+  - LastError: Error of the most recent run.
+  - Details:   Further run context, e.g. profile and version.
+*/
+type Incident struct {
+	Key       string
+	Flow      string
+	Summary   string
+	Source    string
+	Failures  int
+	Since     time.Time
+	LastError string
+	Details   map[string]string
+}
+
+// fields returns the run context of inc as a flat map for the incident body.
+func (inc Incident) fields() map[string]string {
+	out := map[string]string{
+		"flow":      inc.Flow,
+		"failures":  fmt.Sprint(inc.Failures),
+		"since":     inc.Since.UTC().Format(time.RFC3339),
+		"lastError": inc.LastError,
+	}
+	for k, v := range inc.Details {
+		out[k] = v
+	}
+	return out
+}
+
+/*
+Notifier opens and closes incidents in an on-call service.
+*/
+type Notifier interface {
+	// Trigger opens the incident, or updates the open one with the same key.
+	Trigger(ctx context.Context, inc Incident) error
+	// Resolve closes the incident with the given key.
+	Resolve(ctx context.Context, key string) error
+}
+
+/*
+Outcomes of Escalator.Record.
+*/
+const (
+	Triggered = "triggered"
+	Resolved  = "resolved"
+)
+
+/*
+Run is the outcome of one flow run as seen by the Escalator.
+
+Fields:
+  - Flow:     Flow name.
+  - Finished: When the run (including its retries) finished.
+  - Err:      Why it failed; nil for a successful run.
+  - Source:   Host the importer runs on.
+  - Details:  Context attached to a triggered incident.
+*/
+type Run struct {
+	Flow     string
+	Finished time.Time
+	Err      error
+	Source   string
+	Details  map[string]string
+}
+
+// state is the failure streak of a flow, stored as a checkpoint value.
+type state struct {
+	Failures int       `json:"failures"`
+	Since    time.Time `json:"since"`
+	Open     bool      `json:"open"`
+}
+
+/*
+Escalator counts consecutive failed runs per flow and opens an incident once a
+flow has failed often enough, resolving it with the next successful run. The
+streaks are kept in the checkpoint store under "escalation:<flow>", so they
+carry over between cron invocations and restarts.
+*/
+type Escalator struct {
+	store *checkpoint.Store
+	mu    sync.Mutex
+}
+
+/*
+NewEscalator returns an Escalator keeping its state in store.
+*/
+func NewEscalator(store *checkpoint.Store) *Escalator {
+	return &Escalator{store: store}
+}
+
+/*
+Record updates the failure streak of r.Flow and triggers or resolves its
+incident through n. An incident is triggered when the streak reaches after
+failures; if that fails it is tried again with the next failure. A failed
+resolve is tried again with the next success.
+
+Parameters:
+  - ctx:   Context bounding the call to the on-call service.
+  - n:     Service the incident is opened in.
+  - after: Consecutive failures that open an incident.
+  - r:     The finished run.
+
+Returns:
+  - Triggered, Resolved, or "" when no incident changed.
+  - An error if the state cannot be stored or the service rejects the call.
+*/
+func (e *Escalator) Record(ctx context.Context, n Notifier, after int, r Run) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := checkpoint.Key("escalation", r.Flow)
+	var st state
+	if cp, ok, err := e.store.Load(key); err != nil {
+		return "", err
+	} else if ok {
+		if err := json.Unmarshal([]byte(cp.Value), &st); err != nil {
+			return "", fmt.Errorf("invalid escalation state for %s: %w", r.Flow, err)
+		}
+	}
+
+	if r.Err == nil {
+		if st.Failures == 0 && !st.Open {
+			return "", nil
+		}
+		if st.Open {
+			if err := n.Resolve(ctx, Key(r.Flow)); err != nil {
+				return "", err
+			}
+		}
+		if err := e.store.Delete(key); err != nil {
+			return "", err
+		}
+		if st.Open {
+			return Resolved, nil
+		}
+		return "", nil
+	}
+
+	st.Failures++
+	if st.Failures == 1 {
+		st.Since = r.Finished
+	}
+	outcome := ""
+	var notifyErr error
+	if !st.Open && st.Failures >= after {
+		inc := Incident{
+			Key:       Key(r.Flow),
+			Flow:      r.Flow,
+			Summary:   fmt.Sprintf("avcimporter %s flow failed %d times in a row: %s", r.Flow, st.Failures, r.Err),
+			Source:    r.Source,
+			Failures:  st.Failures,
+			Since:     st.Since,
+			LastError: r.Err.Error(),
+			Details:   r.Details,
+		}
+		if notifyErr = n.Trigger(ctx, inc); notifyErr == nil {
+			st.Open = true
+			outcome = Triggered
+		}
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	if err := e.store.Save(key, string(data)); err != nil {
+		return "", err
+	}
+	return outcome, notifyErr
+}
+
+/*
+Key returns the deduplication key of a flow's incident, e.g. "avcimporter:api".
+*/
+func Key(flow string) string {
+	return "avcimporter:" + flow
+}
+
+// post sends body as JSON to url and expects a 2xx response.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body interface{}) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,124 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/checkpoint"
+)
+
+// TestEscalatorPagerDuty verifies an incident is triggered once after the
+// configured failures, survives a new Escalator and is resolved by a success.
+func TestEscalatorPagerDuty(t *testing.T) {
+	var events []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	store := checkpoint.Open(filepath.Join(t.TempDir(), "checkpoints.json"), nil)
+	pd := &PagerDuty{RoutingKey: "rk", URL: srv.URL}
+	failed := errors.New("sftp: connection refused")
+	steps := []struct {
+		err  error
+		want string
+	}{
+		{failed, ""},
+		{failed, ""},
+		{failed, Triggered},
+		{failed, ""},
+		{nil, Resolved},
+		{nil, ""},
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, step := range steps {
+		// A fresh Escalator per run, as with cron invocations.
+		got, err := NewEscalator(store).Record(context.Background(), pd, 3, Run{
+			Flow: "edi", Finished: start.Add(time.Duration(i) * time.Minute), Err: step.err,
+			Source: "host", Details: map[string]string{"profile": "production"},
+		})
+		if err != nil {
+			t.Fatalf("run %d: Record() returned %v", i, err)
+		}
+		if got != step.want {
+			t.Errorf("run %d: Record() = %q; expected %q", i, got, step.want)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("sent %d events; expected a trigger and a resolve", len(events))
+	}
+	if events[0]["event_action"] != "trigger" || events[1]["event_action"] != "resolve" ||
+		events[0]["dedup_key"] != "avcimporter:edi" || events[1]["dedup_key"] != "avcimporter:edi" {
+		t.Errorf("events = %v", events)
+	}
+	payload := events[0]["payload"].(map[string]interface{})
+	details := payload["custom_details"].(map[string]interface{})
+	if details["failures"] != "3" || details["since"] != "2024-05-01T12:00:00Z" || details["profile"] != "production" ||
+		payload["source"] != "host" || payload["component"] != "edi" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+// TestEscalatorRetriesFailedTrigger verifies a rejected trigger is sent again with the next failure.
+func TestEscalatorRetriesFailedTrigger(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	e := NewEscalator(checkpoint.Open(filepath.Join(t.TempDir(), "checkpoints.json"), nil))
+	pd := &PagerDuty{RoutingKey: "rk", URL: srv.URL}
+	run := Run{Flow: "api", Finished: time.Now(), Err: errors.New("throttled")}
+	if _, err := e.Record(context.Background(), pd, 1, run); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Record() returned %v; expected the rejection", err)
+	}
+	if got, err := e.Record(context.Background(), pd, 1, run); err != nil || got != Triggered {
+		t.Errorf("Record() = %q, %v; expected a retried trigger", got, err)
+	}
+}
+
+// TestOpsgenie verifies alerts are created and closed by alias with the API key.
+func TestOpsgenie(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Alias string `json:"alias"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, strings.TrimSpace(r.URL.RequestURI()+" "+body.Alias))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	o := &Opsgenie{APIKey: "key", URL: srv.URL + "/"}
+	if err := o.Trigger(context.Background(), Incident{Key: "avcimporter:sla", Flow: "sla", Summary: "failing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Resolve(context.Background(), "avcimporter:sla"); err != nil {
+		t.Fatal(err)
+	}
+	want := "/v2/alerts avcimporter:sla,/v2/alerts/avcimporter:sla/close?identifierType=alias"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("requests = %s; expected %s", got, want)
+	}
+}
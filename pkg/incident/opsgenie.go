@@ -0,0 +1,73 @@
+// pkg/incident/opsgenie.go
+package incident
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// opsgenieURL is the Opsgenie API base URL; EU accounts use https://api.eu.opsgenie.com.
+const opsgenieURL = "https://api.opsgenie.com"
+
+/*
+Opsgenie opens incidents as Opsgenie alerts, using the incident key as alias.
+
+Fields:
+  - APIKey: API key of an Opsgenie API integration.
+  - URL:    API base URL (default: https://api.opsgenie.com).
+  - HTTP:   HTTP client; nil means http.DefaultClient.
+*/
+type Opsgenie struct {
+	APIKey string
+	URL    string
+	HTTP   *http.Client
+}
+
+/*
+Trigger creates an alert with priority P2; Opsgenie folds it into the open
+alert with the same alias.
+*/
+func (o *Opsgenie) Trigger(ctx context.Context, inc Incident) error {
+	body := map[string]interface{}{
+		"message":     truncate(inc.Summary, 130),
+		"alias":       inc.Key,
+		"description": inc.LastError,
+		"source":      inc.Source,
+		"entity":      inc.Flow,
+		"details":     inc.fields(),
+		"priority":    "P2",
+	}
+	if _, err := post(ctx, o.HTTP, o.base()+"/v2/alerts", o.header(), body); err != nil {
+		return fmt.Errorf("failed to create Opsgenie alert: %w", err)
+	}
+	return nil
+}
+
+/*
+Resolve closes the alert whose alias is key. An alert that no longer exists
+counts as closed.
+*/
+func (o *Opsgenie) Resolve(ctx context.Context, key string) error {
+	endpoint := o.base() + "/v2/alerts/" + url.PathEscape(key) + "/close?identifierType=alias"
+	status, err := post(ctx, o.HTTP, endpoint, o.header(), map[string]string{"source": "avcimporter"})
+	if err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("failed to close Opsgenie alert: %w", err)
+	}
+	return nil
+}
+
+// base returns the API base URL without a trailing slash.
+func (o *Opsgenie) base() string {
+	if o.URL == "" {
+		return opsgenieURL
+	}
+	return strings.TrimSuffix(o.URL, "/")
+}
+
+// header returns the authentication header of each call.
+func (o *Opsgenie) header() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+}
@@ -0,0 +1,78 @@
+// pkg/incident/pagerduty.go
+package incident
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// pagerDutyURL is the PagerDuty Events API v2 endpoint.
+const pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+/*
+PagerDuty opens incidents through the PagerDuty Events API v2, deduplicated
+by the incident key.
+
+Fields:
+  - RoutingKey: Integration key of the PagerDuty service.
+  - URL:        Events endpoint (default: https://events.pagerduty.com/v2/enqueue).
+  - HTTP:       HTTP client; nil means http.DefaultClient.
+*/
+type PagerDuty struct {
+	RoutingKey string
+	URL        string
+	HTTP       *http.Client
+}
+
+/*
+Trigger sends a trigger event with severity "error", the flow as component
+and the run context as custom details.
+*/
+func (p *PagerDuty) Trigger(ctx context.Context, inc Incident) error {
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    inc.Key,
+		"payload": map[string]interface{}{
+			"summary":        truncate(inc.Summary, 1024),
+			"source":         inc.Source,
+			"severity":       "error",
+			"component":      inc.Flow,
+			"timestamp":      inc.Since.UTC().Format(time.RFC3339),
+			"custom_details": inc.fields(),
+		},
+	})
+}
+
+/*
+Resolve sends a resolve event for key.
+*/
+func (p *PagerDuty) Resolve(ctx context.Context, key string) error {
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    key,
+	})
+}
+
+// send posts one event.
+func (p *PagerDuty) send(ctx context.Context, event map[string]interface{}) error {
+	url := p.URL
+	if url == "" {
+		url = pagerDutyURL
+	}
+	if _, err := post(ctx, p.HTTP, url, nil, event); err != nil {
+		return fmt.Errorf("failed to send PagerDuty %s event: %w", event["event_action"], err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
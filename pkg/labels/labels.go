@@ -0,0 +1,102 @@
+// pkg/labels/labels.go
+package labels
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+/*
+ShipmentLabel renders the shipment label of a confirmation as ZPL for a 4x6
+inch label at 203 dpi: ship-from and ship-to parties, the purchase orders,
+line and unit counts and the shipment ID as a Code 128 barcode.
+
+Parameters:
+  - s:      The shipment confirmation.
+  - copies: Number of labels to print (at least one).
+
+Returns:
+  - The ZPL document.
+*/
+func ShipmentLabel(s spapi.ShipmentConfirmation, copies int) []byte {
+	if copies < 1 {
+		copies = 1
+	}
+	units := 0
+	for _, it := range s.ShippedItems {
+		q := it.ShippedQuantity
+		if strings.EqualFold(q.UnitOfMeasure, "Cases") && q.UnitSize > 0 {
+			units += q.Amount * q.UnitSize
+		} else {
+			units += q.Amount
+		}
+	}
+	var b strings.Builder
+	b.WriteString("^XA\n^CI28\n^PW812\n^LL1218\n")
+	field(&b, 40, 40, 30, "SHIP FROM: "+s.ShipFromParty.PartyID)
+	field(&b, 40, 90, 30, "SHIP TO: "+s.ShipToParty.PartyID)
+	b.WriteString("^FO40,140^GB732,3,3^FS\n")
+	field(&b, 40, 170, 40, "PO "+strings.Join(purchaseOrders(s), ", "))
+	field(&b, 40, 230, 30, fmt.Sprintf("LINES: %d  UNITS: %d", len(s.ShippedItems), units))
+	if s.TransportationDetails != nil && s.TransportationDetails.CarrierScac != "" {
+		field(&b, 40, 280, 30, "CARRIER: "+s.TransportationDetails.CarrierScac)
+	}
+	fmt.Fprintf(&b, "^FO40,360^BY3^BCN,160,Y,N,N^FH_^FD%s^FS\n", escape(s.ShipmentIdentifier))
+	fmt.Fprintf(&b, "^PQ%d\n^XZ\n", copies)
+	return []byte(b.String())
+}
+
+// field writes one line of text at x,y in font 0 of the given height.
+func field(b *strings.Builder, x, y, height int, text string) {
+	fmt.Fprintf(b, "^FO%d,%d^A0N,%d,%d^FH_^FD%s^FS\n", x, y, height, height, escape(text))
+}
+
+// escape hex-encodes the characters ZPL reads as commands in field data.
+func escape(s string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E").Replace(s)
+}
+
+// purchaseOrders returns the PO numbers of a shipment in order of appearance.
+func purchaseOrders(s spapi.ShipmentConfirmation) []string {
+	var pos []string
+	seen := map[string]bool{}
+	for _, it := range s.ShippedItems {
+		if it.ItemDetails == nil || seen[it.ItemDetails.PurchaseOrderNumber] {
+			continue
+		}
+		seen[it.ItemDetails.PurchaseOrderNumber] = true
+		pos = append(pos, it.ItemDetails.PurchaseOrderNumber)
+	}
+	return pos
+}
+
+/*
+PackingSlip renders the packing slip of a confirmation as plain text, which
+any office printer accepts: the parties and one row per shipped line with
+its purchase order, item, products and quantity.
+*/
+func PackingSlip(s spapi.ShipmentConfirmation) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PACKING SLIP  Shipment %s\n\n", s.ShipmentIdentifier)
+	fmt.Fprintf(&b, "Ship from: %s\nShip to:   %s\n", s.ShipFromParty.PartyID, s.ShipToParty.PartyID)
+	if s.ShippedDate != nil {
+		fmt.Fprintf(&b, "Shipped:   %s\n", s.ShippedDate.Format("2006-01-02"))
+	}
+	b.WriteString("\n")
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PO NUMBER\tITEM\tASIN\tVENDOR SKU\tQUANTITY")
+	for _, it := range s.ShippedItems {
+		po := ""
+		if it.ItemDetails != nil {
+			po = it.ItemDetails.PurchaseOrderNumber
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d %s\n", po, it.ItemSequenceNumber, it.AmazonProductIdentifier, it.VendorProductIdentifier, it.ShippedQuantity.Amount, it.ShippedQuantity.UnitOfMeasure)
+	}
+	tw.Flush()
+	// Eject the page on printers that take plain text as is.
+	b.WriteString("\f")
+	return []byte(b.String())
+}
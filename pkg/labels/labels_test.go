@@ -0,0 +1,52 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// testShipment is a two-PO shipment with a case-packed line.
+func testShipment() spapi.ShipmentConfirmation {
+	return spapi.ShipmentConfirmation{
+		ShipmentIdentifier: "PO1-WH^A",
+		ShipFromParty:      spapi.Party{PartyID: "WHA"},
+		ShipToParty:        spapi.Party{PartyID: "PHX3"},
+		ShippedItems: []spapi.ShippedItem{
+			{ItemSequenceNumber: "1", AmazonProductIdentifier: "B001", ShippedQuantity: spapi.ItemQuantity{Amount: 2, UnitOfMeasure: "Cases", UnitSize: 6}, ItemDetails: &spapi.ShippedItemDetails{PurchaseOrderNumber: "PO1"}},
+			{ItemSequenceNumber: "2", VendorProductIdentifier: "SKU-2", ShippedQuantity: spapi.ItemQuantity{Amount: 3, UnitOfMeasure: "Eaches"}, ItemDetails: &spapi.ShippedItemDetails{PurchaseOrderNumber: "PO1"}},
+			{ItemSequenceNumber: "1", VendorProductIdentifier: "SKU-3", ShippedQuantity: spapi.ItemQuantity{Amount: 1, UnitOfMeasure: "Eaches"}, ItemDetails: &spapi.ShippedItemDetails{PurchaseOrderNumber: "PO2"}},
+		},
+	}
+}
+
+// TestShipmentLabel verifies the label lists the parties, POs and units and
+// escapes ZPL control characters in field data.
+func TestShipmentLabel(t *testing.T) {
+	zpl := string(ShipmentLabel(testShipment(), 2))
+	if !strings.HasPrefix(zpl, "^XA") || !strings.HasSuffix(zpl, "^PQ2\n^XZ\n") {
+		t.Errorf("label is not one ZPL format with two copies:\n%s", zpl)
+	}
+	for _, want := range []string{"^FDSHIP FROM: WHA^FS", "^FDSHIP TO: PHX3^FS", "^FDPO PO1, PO2^FS", "^FDLINES: 3  UNITS: 16^FS", "^BCN,160,Y,N,N^FH_^FDPO1-WH_5EA^FS"} {
+		if !strings.Contains(zpl, want) {
+			t.Errorf("label lacks %q:\n%s", want, zpl)
+		}
+	}
+}
+
+// TestPackingSlip verifies the slip has one row per shipped line.
+func TestPackingSlip(t *testing.T) {
+	slip := string(PackingSlip(testShipment()))
+	lines := strings.Split(slip, "\n")
+	var rows []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, "PO1 ") || strings.HasPrefix(l, "PO2 ") {
+			rows = append(rows, strings.Join(strings.Fields(l), " "))
+		}
+	}
+	want := []string{"PO1 1 B001 2 Cases", "PO1 2 SKU-2 3 Eaches", "PO2 1 SKU-3 1 Eaches"}
+	if strings.Join(rows, "|") != strings.Join(want, "|") {
+		t.Errorf("packing slip rows = %q; expected %q\n%s", rows, want, slip)
+	}
+}
@@ -0,0 +1,218 @@
+// pkg/lifecycle/fillrate.go
+package lifecycle
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+FillRate is how much of one ASIN ordered on one day was accepted and shipped.
+
+Fields:
+  - Date:       Order day (YYYY-MM-DD in the time zone given to FillRates).
+  - ASIN:       Amazon product identifier.
+  - Orders:     Purchase orders with the ASIN that day.
+  - Ordered:    Units ordered.
+  - Accepted:   Units accepted by the latest acknowledgment of each order.
+  - Shipped:    Units confirmed shipped.
+  - AcceptRate: Accepted / Ordered.
+  - FillRate:   Shipped / Ordered.
+*/
+type FillRate struct {
+	Date       string  `json:"date"`
+	ASIN       string  `json:"asin"`
+	Orders     int     `json:"orders"`
+	Ordered    int     `json:"ordered"`
+	Accepted   int     `json:"accepted"`
+	Shipped    int     `json:"shipped"`
+	AcceptRate float64 `json:"acceptRate"`
+	FillRate   float64 `json:"fillRate"`
+}
+
+/*
+FillRates aggregates the orders dated in [from, to) by day and ASIN, sorted by
+day and ASIN. Orders without recorded line quantities are skipped; an order
+without an order date counts on the day it was received.
+*/
+func FillRates(orders []Order, loc *time.Location, from, to time.Time) []FillRate {
+	rows := map[[2]string]*FillRate{}
+	for _, o := range orders {
+		po := o.latest(PurchaseOrder)
+		if po == nil || len(po.Lines) == 0 {
+			continue
+		}
+		day := po.At
+		if o.OrderDate != nil {
+			day = *o.OrderDate
+		}
+		if day.Before(from) || !day.Before(to) {
+			continue
+		}
+		date := day.In(loc).Format("2006-01-02")
+
+		// Acknowledgments and shipments name lines by sequence number, or by ASIN.
+		asins := map[string]string{}
+		for _, l := range po.Lines {
+			asins[l.Item] = l.ASIN
+		}
+		asinOf := func(l Line) string {
+			if a, ok := asins[l.Item]; ok && l.Item != "" {
+				return a
+			}
+			return l.ASIN
+		}
+		row := func(asin string) *FillRate {
+			r := rows[[2]string{date, asin}]
+			if r == nil {
+				r = &FillRate{Date: date, ASIN: asin}
+				rows[[2]string{date, asin}] = r
+			}
+			return r
+		}
+		counted := map[string]bool{}
+		for _, l := range po.Lines {
+			r := row(l.ASIN)
+			r.Ordered += l.Units
+			if !counted[l.ASIN] {
+				counted[l.ASIN] = true
+				r.Orders++
+			}
+		}
+		if ack := o.latest(Acknowledgment); ack != nil {
+			for _, l := range ack.Lines {
+				row(asinOf(l)).Accepted += l.Units
+			}
+		}
+		for _, d := range o.Documents {
+			if d.Kind == ShipNotice && d.counts() {
+				for _, l := range d.Lines {
+					row(asinOf(l)).Shipped += l.Units
+				}
+			}
+		}
+	}
+
+	out := make([]FillRate, 0, len(rows))
+	for _, r := range rows {
+		r.AcceptRate = ratio(r.Accepted, r.Ordered)
+		r.FillRate = ratio(r.Shipped, r.Ordered)
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Date != out[j].Date {
+			return out[i].Date < out[j].Date
+		}
+		return out[i].ASIN < out[j].ASIN
+	})
+	return out
+}
+
+/*
+Aging is how long an open purchase order has been waiting.
+
+Fields:
+  - PONumber:  Purchase order number.
+  - OrderDate: Order date, or when the order was received.
+  - Stage:     Furthest stage reached (see Order.Stage).
+  - AgeDays:   Whole days since OrderDate.
+  - Bucket:    Age bucket: "0-2d", "3-7d", "8-14d", "15-30d" or "30d+".
+*/
+type Aging struct {
+	PONumber  string    `json:"poNumber"`
+	OrderDate time.Time `json:"orderDate"`
+	Stage     string    `json:"stage"`
+	AgeDays   int       `json:"ageDays"`
+	Bucket    string    `json:"bucket"`
+}
+
+// agingBuckets are the upper bounds (in days) of the aging buckets.
+var agingBuckets = []struct {
+	max  int
+	name string
+}{{2, "0-2d"}, {7, "3-7d"}, {14, "8-14d"}, {30, "15-30d"}}
+
+/*
+OpenAging returns the age of every open order as of now, oldest first. Orders
+whose date is unknown are skipped.
+*/
+func OpenAging(orders []Order, now time.Time) []Aging {
+	var out []Aging
+	for _, o := range orders {
+		if !o.Open() {
+			continue
+		}
+		var since time.Time
+		if o.OrderDate != nil {
+			since = *o.OrderDate
+		} else if po := o.latest(PurchaseOrder); po != nil {
+			since = po.At
+		} else {
+			continue
+		}
+		a := Aging{PONumber: o.PONumber, OrderDate: since, Stage: o.Stage(), AgeDays: int(now.Sub(since).Hours() / 24), Bucket: "30d+"}
+		for _, b := range agingBuckets {
+			if a.AgeDays <= b.max {
+				a.Bucket = b.name
+				break
+			}
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OrderDate.Before(out[j].OrderDate) })
+	return out
+}
+
+// latest returns the most recent counting document of a kind, or nil.
+func (o Order) latest(kind string) *Document {
+	var found *Document
+	for i := range o.Documents {
+		d := &o.Documents[i]
+		if d.Kind == kind && d.counts() && (found == nil || !d.At.Before(found.At)) {
+			found = d
+		}
+	}
+	return found
+}
+
+/*
+Unshipped returns the order lines not yet shipped in full by the ship notices
+that took effect, with the units left, in purchase order line order. Orders
+without recorded line quantities yield nil. This tells a PO split over
+several warehouses that shipped in part from a complete one.
+*/
+func (o Order) Unshipped() []Line {
+	po := o.latest(PurchaseOrder)
+	if po == nil {
+		return nil
+	}
+	shipped := map[string]int{}
+	for _, d := range o.Documents {
+		if d.Kind == ShipNotice && d.counts() {
+			for _, l := range d.Lines {
+				shipped[l.Item] += l.Units
+			}
+		}
+	}
+	var left []Line
+	for _, l := range po.Lines {
+		if n := l.Units - shipped[l.Item]; n > 0 {
+			left = append(left, Line{Item: l.Item, ASIN: l.ASIN, Units: n})
+		}
+	}
+	return left
+}
+
+// counts reports whether d took effect, i.e. it was neither rejected nor only generated locally.
+func (d Document) counts() bool {
+	return d.Status != Rejected && d.Status != Generated
+}
+
+// ratio returns n / total rounded to four decimals, or 0 without a total.
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 10000
+}
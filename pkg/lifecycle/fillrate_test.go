@@ -0,0 +1,78 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFillRates verifies units are aggregated per day and ASIN from the PO,
+// its latest acknowledgment and every shipment that was not rejected.
+func TestFillRates(t *testing.T) {
+	day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{PONumber: "A", OrderDate: &day, Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: day, Lines: []Line{{Item: "1", ASIN: "B01", Units: 10}, {Item: "2", ASIN: "B02", Units: 4}}},
+			{Kind: Acknowledgment, Status: Accepted, At: day.Add(time.Hour), Lines: []Line{{Item: "1", Units: 10}, {Item: "2", Units: 4}}},
+			{Kind: Acknowledgment, Status: Sent, At: day.Add(2 * time.Hour), Reference: "resubmitted", Lines: []Line{{Item: "1", Units: 8}}},
+			{Kind: ShipNotice, Status: Accepted, At: day.Add(24 * time.Hour), Lines: []Line{{ASIN: "B01", Units: 6}}},
+			{Kind: ShipNotice, Status: Rejected, At: day.Add(25 * time.Hour), Lines: []Line{{Item: "1", Units: 2}}},
+		}},
+		{PONumber: "B", Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: day.Add(3 * time.Hour), Lines: []Line{{Item: "1", ASIN: "B01", Units: 5}}},
+		}},
+		{PONumber: "C", OrderDate: ptr(day.AddDate(0, 0, -3)), Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, Lines: []Line{{Item: "1", ASIN: "B01", Units: 1}}},
+		}},
+	}
+
+	got := FillRates(orders, time.UTC, day.Truncate(24*time.Hour), day.Truncate(24*time.Hour).AddDate(0, 0, 1))
+	want := []FillRate{
+		{Date: "2024-05-01", ASIN: "B01", Orders: 2, Ordered: 15, Accepted: 8, Shipped: 6, AcceptRate: 0.5333, FillRate: 0.4},
+		{Date: "2024-05-01", ASIN: "B02", Orders: 1, Ordered: 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("FillRates() = %+v; expected %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v; expected %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestOpenAging verifies only open orders are aged and bucketed, oldest first.
+func TestOpenAging(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{PONumber: "NEW", Documents: []Document{{Kind: PurchaseOrder, Status: Received, At: now.Add(-30 * time.Hour)}}},
+		{PONumber: "OLD", OrderDate: ptr(now.AddDate(0, 0, -10)), Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received},
+			{Kind: Acknowledgment, Status: Accepted},
+		}},
+		{PONumber: "DONE", OrderDate: ptr(now.AddDate(0, 0, -40)), Documents: []Document{{Kind: Invoice, Status: Sent}}},
+	}
+	got := OpenAging(orders, now)
+	if len(got) != 2 || got[0].PONumber != "OLD" || got[0].AgeDays != 10 || got[0].Bucket != "8-14d" || got[0].Stage != StageAcknowledged ||
+		got[1].PONumber != "NEW" || got[1].AgeDays != 1 || got[1].Bucket != "0-2d" {
+		t.Errorf("OpenAging() = %+v", got)
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+// TestUnshipped verifies ship notices from several warehouses add up and a
+// rejected one does not count.
+func TestUnshipped(t *testing.T) {
+	o := Order{PONumber: "PO1", Documents: []Document{
+		{Kind: PurchaseOrder, Status: Received, Lines: []Line{{Item: "1", ASIN: "A", Units: 10}, {Item: "2", ASIN: "B", Units: 4}}},
+		{Kind: ShipNotice, Status: Accepted, Reference: "PO1-WH1", Warehouse: "WH1", Lines: []Line{{Item: "1", Units: 6}}},
+		{Kind: ShipNotice, Status: Sent, Reference: "PO1-WH2", Warehouse: "WH2", Lines: []Line{{Item: "2", Units: 4}}},
+		{Kind: ShipNotice, Status: Rejected, Reference: "PO1-WH3", Warehouse: "WH3", Lines: []Line{{Item: "1", Units: 4}}},
+	}}
+	got := o.Unshipped()
+	if len(got) != 1 || got[0] != (Line{Item: "1", ASIN: "A", Units: 4}) {
+		t.Errorf("Unshipped() = %+v; expected 4 units of item 1", got)
+	}
+}
@@ -0,0 +1,427 @@
+// pkg/lifecycle/lifecycle.go
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Document kinds tracked for a purchase order.
+*/
+const (
+	PurchaseOrder     = "po"      // 850 or SP‑API purchase order
+	Acknowledgment    = "ack"     // 855 or API acknowledgement
+	ShipNotice        = "asn"     // 856 or Vendor Shipments confirmation
+	Invoice           = "invoice" // 810 or API invoice
+	FunctionalAck     = "997"     // functional acknowledgment of an outbound interchange
+	ApplicationAdvice = "864"     // text message about a document
+)
+
+/*
+Document statuses.
+*/
+const (
+	Received  = "received"  // inbound document stored
+	Generated = "generated" // outbound document written locally, not sent
+	Sent      = "sent"      // outbound document delivered, awaiting a response
+	Accepted  = "accepted"  // accepted by the partner (997 AK5 "A"/"E" or API transaction success)
+	Rejected  = "rejected"  // rejected by the partner
+)
+
+/*
+Stages of an order, in the order they are reached.
+*/
+const (
+	StageReceived     = "received"
+	StageAcknowledged = "acknowledged"
+	StageShipped      = "shipped"
+	StageInvoiced     = "invoiced"
+)
+
+// stages maps the document kinds that advance an order to the stage they reach.
+var stages = []struct{ kind, stage string }{
+	{Invoice, StageInvoiced},
+	{ShipNotice, StageShipped},
+	{Acknowledgment, StageAcknowledged},
+	{PurchaseOrder, StageReceived},
+}
+
+/*
+SetKind returns the document kind of an X12 transaction set ID, or the ID
+itself for sets without a dedicated kind.
+*/
+func SetKind(setID string) string {
+	switch setID {
+	case "850":
+		return PurchaseOrder
+	case "855":
+		return Acknowledgment
+	case "856":
+		return ShipNotice
+	case "810":
+		return Invoice
+	}
+	return setID
+}
+
+/*
+Document is one exchange linked to a purchase order.
+
+Fields:
+  - Kind:        PurchaseOrder, Acknowledgment, ShipNotice, Invoice, FunctionalAck, ...
+  - SetID:       X12 transaction set ID, if the document is EDI.
+  - Direction:   "inbound" or "outbound".
+  - Interchange: ISA13 interchange control number.
+  - Group:       GS06 group control number; 997s are matched on it.
+  - Set:         ST02 transaction set control number.
+  - Reference:   Local file or SP‑API transaction ID.
+  - Status:      Received, Generated, Sent, Accepted or Rejected.
+  - Detail:      Rejection reasons or other notes.
+  - At:          When the status was last set.
+  - Lines:       Quantities per order line: ordered (PO), accepted (acknowledgment) or shipped (ASN).
+  - Warehouse:   Ship-from party ID of an ASN; a PO split over warehouses has one ASN per warehouse.
+*/
+type Document struct {
+	Kind        string    `json:"kind"`
+	SetID       string    `json:"setId,omitempty"`
+	Direction   string    `json:"direction"`
+	Interchange string    `json:"interchange,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Set         string    `json:"set,omitempty"`
+	Reference   string    `json:"reference,omitempty"`
+	Status      string    `json:"status"`
+	Detail      string    `json:"detail,omitempty"`
+	At          time.Time `json:"at"`
+	Lines       []Line    `json:"lines,omitempty"`
+	Warehouse   string    `json:"warehouse,omitempty"`
+}
+
+/*
+Line is the quantity of one order line a document covers.
+
+Fields:
+  - Item:  Item sequence number of the line on the purchase order.
+  - ASIN:  Amazon product identifier.
+  - Units: Quantity in eaches.
+*/
+type Line struct {
+	Item  string `json:"item,omitempty"`
+	ASIN  string `json:"asin,omitempty"`
+	Units int    `json:"units"`
+}
+
+// same reports whether d and o describe the same exchange.
+func (d Document) same(o Document) bool {
+	return d.Kind == o.Kind && d.Direction == o.Direction && d.Interchange == o.Interchange && d.Set == o.Set && d.Reference == o.Reference
+}
+
+/*
+Order is the lifecycle of one purchase order.
+
+Fields:
+  - PONumber:  Purchase order number.
+  - OrderDate: Purchase order date, if known.
+  - Documents: Exchanges in the order they were recorded.
+  - UpdatedAt: When a document was last recorded or resolved.
+  - Alerted:   Last SLA alert state notified per rule name.
+  - Deadlines: Ship and delivery window bounds of the order (see model.Order.Deadlines), whatever its source.
+*/
+type Order struct {
+	PONumber  string               `json:"poNumber"`
+	OrderDate *time.Time           `json:"orderDate,omitempty"`
+	Documents []Document           `json:"documents"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+	Alerted   map[string]string    `json:"alerted,omitempty"`
+	Deadlines map[string]time.Time `json:"deadlines,omitempty"`
+}
+
+/*
+Stage returns the furthest stage reached by a document that was neither
+rejected nor only generated locally, or "" if nothing but responses is known.
+*/
+func (o Order) Stage() string {
+	for _, s := range stages {
+		for _, d := range o.Documents {
+			if d.Kind == s.kind && d.counts() {
+				return s.stage
+			}
+		}
+	}
+	return ""
+}
+
+/*
+Open reports whether the order still needs action, i.e. it has not been
+invoiced.
+*/
+func (o Order) Open() bool {
+	return o.Stage() != StageInvoiced
+}
+
+/*
+Filter returns the documents with the given status.
+*/
+func (o Order) Filter(status string) []Document {
+	var docs []Document
+	for _, d := range o.Documents {
+		if d.Status == status {
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+/*
+Store keeps the lifecycle of every purchase order in one JSON file keyed by
+PO number. Like checkpoint.Store it replaces the file atomically; writes from
+all Stores in the process are serialized, so flows may each open their own.
+*/
+type Store struct {
+	path  string
+	perms *utils.Permissions
+}
+
+// fileMu serializes read-modify-write cycles of every Store.
+var fileMu sync.Mutex
+
+/*
+Open returns a Store backed by the file at path. The file is created on the
+first write, with perms (nil uses utils.DefaultPermissions).
+*/
+func Open(path string, perms *utils.Permissions) *Store {
+	return &Store{path: path, perms: perms}
+}
+
+/*
+Path returns the file backing the store.
+*/
+func (s *Store) Path() string {
+	return s.path
+}
+
+/*
+Record adds d to the order poNumber, replacing an earlier record of the same
+exchange (same kind, direction, control numbers and reference) so re-imports
+do not duplicate it. A zero At is set to now.
+*/
+func (s *Store) Record(poNumber string, d Document) error {
+	if poNumber == "" {
+		return fmt.Errorf("lifecycle document %s has no PO number", d.Kind)
+	}
+	if d.At.IsZero() {
+		d.At = time.Now().UTC()
+	}
+	return s.update(func(all map[string]*Order) []string {
+		o := all[poNumber]
+		if o == nil {
+			o = &Order{PONumber: poNumber}
+			all[poNumber] = o
+		}
+		replaced := false
+		for i := range o.Documents {
+			if o.Documents[i].same(d) {
+				o.Documents[i], replaced = d, true
+				break
+			}
+		}
+		if !replaced {
+			o.Documents = append(o.Documents, d)
+		}
+		o.UpdatedAt = d.At
+		return []string{poNumber}
+	})
+}
+
+/*
+SetDeadlines replaces the window bounds of the order poNumber, keyed like SLA
+rule anchors, so rules can be evaluated for orders that are not stored as
+SP‑API JSON (e.g. EDI 850s). An empty deadlines map leaves the order as it is.
+*/
+func (s *Store) SetDeadlines(poNumber string, deadlines map[string]time.Time) error {
+	return s.SetOrder(poNumber, time.Time{}, deadlines)
+}
+
+/*
+SetOrder records the order date and window bounds (see SetDeadlines) of the
+order poNumber in one write. A zero date or empty deadlines map leaves that
+part as it is.
+*/
+func (s *Store) SetOrder(poNumber string, orderDate time.Time, deadlines map[string]time.Time) error {
+	if orderDate.IsZero() && len(deadlines) == 0 {
+		return nil
+	}
+	return s.update(func(all map[string]*Order) []string {
+		o := all[poNumber]
+		if o == nil {
+			o = &Order{PONumber: poNumber}
+			all[poNumber] = o
+		}
+		if !orderDate.IsZero() {
+			o.OrderDate = &orderDate
+		}
+		if len(deadlines) > 0 {
+			o.Deadlines = deadlines
+		}
+		return []string{poNumber}
+	})
+}
+
+/*
+Resolve applies a partner's response to the outbound documents it refers to:
+those with the given group control number and, when set is not empty, the
+given transaction set control number. Each is marked status with detail, and
+the response itself is recorded on the same orders as r.
+
+Returns:
+  - The PO numbers of the affected orders, sorted.
+  - An error if the store cannot be read or written.
+*/
+func (s *Store) Resolve(group, set, status, detail string, r Document) ([]string, error) {
+	if r.At.IsZero() {
+		r.At = time.Now().UTC()
+	}
+	var affected []string
+	err := s.update(func(all map[string]*Order) []string {
+		for po, o := range all {
+			matched := false
+			for i := range o.Documents {
+				d := &o.Documents[i]
+				if d.Direction != "outbound" || d.Group != group || (set != "" && d.Set != set) {
+					continue
+				}
+				d.Status, d.Detail, d.At = status, detail, r.At
+				matched = true
+			}
+			if !matched {
+				continue
+			}
+			recorded := false
+			for i := range o.Documents {
+				if o.Documents[i].same(r) {
+					o.Documents[i], recorded = r, true
+				}
+			}
+			if !recorded {
+				o.Documents = append(o.Documents, r)
+			}
+			o.UpdatedAt = r.At
+			affected = append(affected, po)
+		}
+		sort.Strings(affected)
+		return affected
+	})
+	return affected, err
+}
+
+/*
+Get returns the lifecycle of one order.
+
+Returns:
+  - The order and true if anything was recorded for it.
+  - An error if the file cannot be read.
+*/
+func (s *Store) Get(poNumber string) (Order, bool, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return Order{}, false, err
+	}
+	o, ok := all[poNumber]
+	if !ok {
+		return Order{}, false, nil
+	}
+	return *o, true, nil
+}
+
+/*
+All returns every tracked order, sorted by PO number.
+*/
+func (s *Store) All() ([]Order, error) {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]Order, 0, len(all))
+	for _, o := range all {
+		orders = append(orders, *o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].PONumber < orders[j].PONumber })
+	return orders, nil
+}
+
+// update runs change on the stored orders and writes them back if it reports
+// any changed order.
+func (s *Store) update(change func(all map[string]*Order) []string) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(change(all)) == 0 {
+		return nil
+	}
+	return s.write(all)
+}
+
+// read loads the lifecycle file; a missing file is an empty store.
+func (s *Store) read() (map[string]*Order, error) {
+	all := make(map[string]*Order)
+	data, err := codec.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle file %s: %w", s.path, err)
+	}
+	return all, nil
+}
+
+// write replaces the lifecycle file via a temporary file and rename.
+func (s *Store) write(all map[string]*Order) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode lifecycle: %w", err)
+	}
+	if err := s.perms.MkdirAll(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("failed to create lifecycle dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".lifecycle-*")
+	if err != nil {
+		return fmt.Errorf("failed to write lifecycle: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write lifecycle: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write lifecycle: %w", err)
+	}
+	if err := s.perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write lifecycle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write lifecycle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package lifecycle
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestResolveLinksAcknowledgment verifies a 997 resolves the outbound document it
+// acknowledges and that re-recording a document does not duplicate it.
+func TestResolveLinksAcknowledgment(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "lifecycle.json"), nil)
+	po := Document{Kind: PurchaseOrder, SetID: "850", Direction: "inbound", Interchange: "000000101", Set: "0001", Status: Received}
+	for i := 0; i < 2; i++ {
+		if err := s.Record("2JK3S9VC", po); err != nil {
+			t.Fatalf("Record() returned %v", err)
+		}
+	}
+	ack := Document{Kind: Acknowledgment, SetID: "855", Direction: "outbound", Interchange: "000000007", Group: "7", Set: "0001", Status: Sent}
+	if err := s.Record("2JK3S9VC", ack); err != nil {
+		t.Fatalf("Record() returned %v", err)
+	}
+	if err := s.Record("OTHER", Document{Kind: Acknowledgment, Direction: "outbound", Group: "8", Set: "0001", Status: Sent}); err != nil {
+		t.Fatalf("Record() returned %v", err)
+	}
+
+	affected, err := s.Resolve("7", "0001", Rejected, "AK5 R", Document{Kind: FunctionalAck, SetID: "997", Direction: "inbound", Interchange: "000000900", Status: Received})
+	if err != nil || len(affected) != 1 || affected[0] != "2JK3S9VC" {
+		t.Fatalf("Resolve() = %v, %v; expected [2JK3S9VC]", affected, err)
+	}
+
+	o, ok, err := Open(s.Path(), nil).Get("2JK3S9VC")
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v", ok, err)
+	}
+	if len(o.Documents) != 3 {
+		t.Errorf("documents = %+v; expected PO, 855 and 997", o.Documents)
+	}
+	if o.Stage() != StageReceived || len(o.Filter(Rejected)) != 1 {
+		t.Errorf("Stage() = %q, rejected = %v; expected the rejected 855 not to count", o.Stage(), o.Filter(Rejected))
+	}
+	other, _, _ := s.Get("OTHER")
+	if other.Filter(Sent) == nil || other.Stage() != StageAcknowledged {
+		t.Errorf("OTHER = %+v; expected it untouched", other)
+	}
+}
+
+// TestEvaluate verifies deadlines take the earlier of Within and a window and
+// that a sent document satisfies its rule.
+func TestEvaluate(t *testing.T) {
+	received := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := Order{PONumber: "PO1", Documents: []Document{{Kind: PurchaseOrder, Status: Received, At: received}}}
+	rules := []Rule{
+		{Name: "acknowledge", Kind: Acknowledgment, Within: 24 * time.Hour},
+		{Name: "ship", Kind: ShipNotice, Within: 96 * time.Hour, Deadline: "shipWindowEnd"},
+	}
+	deadlines := map[string]time.Time{"shipWindowEnd": received.Add(26 * time.Hour)}
+
+	alerts := o.Evaluate(rules, deadlines, received.Add(23*time.Hour), 4*time.Hour)
+	if len(alerts) != 2 || alerts[0].State != DueSoon || alerts[1].State != DueSoon || !alerts[1].Due.Equal(deadlines["shipWindowEnd"]) {
+		t.Fatalf("Evaluate() = %+v; expected both rules due soon, ship by the window end", alerts)
+	}
+	alerts = o.Evaluate(rules, deadlines, received.Add(25*time.Hour), 4*time.Hour)
+	if len(alerts) != 2 || alerts[0].State != Overdue || alerts[1].State != DueSoon {
+		t.Fatalf("Evaluate() = %+v; expected acknowledge overdue", alerts)
+	}
+
+	o.Documents = append(o.Documents, Document{Kind: Acknowledgment, Direction: "outbound", Status: Sent})
+	if alerts := o.Evaluate(rules, nil, received.Add(25*time.Hour), 4*time.Hour); len(alerts) != 0 {
+		t.Errorf("Evaluate() = %+v; expected the sent 855 to satisfy the rule and ship to be far off without a window", alerts)
+	}
+}
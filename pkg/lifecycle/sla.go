@@ -0,0 +1,135 @@
+// pkg/lifecycle/sla.go
+package lifecycle
+
+import (
+	"time"
+)
+
+/*
+Alert states.
+*/
+const (
+	DueSoon = "due-soon"
+	Overdue = "overdue"
+)
+
+/*
+Rule is a deadline by which an order needs a document of Kind.
+
+Fields:
+  - Name:     Rule name, e.g. "acknowledge".
+  - Kind:     Document kind that satisfies the rule once sent or accepted.
+  - Within:   Time allowed after the order was received (0 for none).
+  - Deadline: Key of an absolute deadline passed to Evaluate, e.g. "shipWindowEnd" ("" for none).
+*/
+type Rule struct {
+	Name     string
+	Kind     string
+	Within   time.Duration
+	Deadline string
+}
+
+/*
+Alert reports an unmet rule.
+
+Fields:
+  - PONumber: The order.
+  - Rule:     Name of the rule.
+  - Kind:     Document kind still missing.
+  - State:    DueSoon or Overdue.
+  - Due:      The deadline.
+*/
+type Alert struct {
+	PONumber string    `json:"poNumber"`
+	Rule     string    `json:"rule"`
+	Kind     string    `json:"kind"`
+	State    string    `json:"state"`
+	Due      time.Time `json:"due"`
+}
+
+/*
+ReceivedAt returns when the order's purchase order was first recorded.
+*/
+func (o Order) ReceivedAt() (time.Time, bool) {
+	for _, d := range o.Documents {
+		if d.Kind == PurchaseOrder {
+			return d.At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/*
+Satisfied reports whether a document of kind was sent or accepted.
+*/
+func (o Order) Satisfied(kind string) bool {
+	for _, d := range o.Documents {
+		if d.Kind == kind && (d.Status == Sent || d.Status == Accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Evaluate checks the order against rules at now.
+
+Parameters:
+  - deadlines:  Absolute deadlines of this order by name (e.g. its ship window end); may be nil.
+  - warnBefore: How long before a deadline an unmet rule is reported as DueSoon.
+
+Returns:
+  - One alert per rule that is unmet and due within warnBefore or overdue.
+*/
+func (o Order) Evaluate(rules []Rule, deadlines map[string]time.Time, now time.Time, warnBefore time.Duration) []Alert {
+	var alerts []Alert
+	received, hasReceived := o.ReceivedAt()
+	for _, r := range rules {
+		if o.Satisfied(r.Kind) {
+			continue
+		}
+		var due time.Time
+		if r.Within > 0 && hasReceived {
+			due = received.Add(r.Within)
+		}
+		if d, ok := deadlines[r.Deadline]; ok && r.Deadline != "" && (due.IsZero() || d.Before(due)) {
+			due = d
+		}
+		if due.IsZero() {
+			continue
+		}
+		state := ""
+		switch {
+		case now.After(due):
+			state = Overdue
+		case now.After(due.Add(-warnBefore)):
+			state = DueSoon
+		default:
+			continue
+		}
+		alerts = append(alerts, Alert{PONumber: o.PONumber, Rule: r.Name, Kind: r.Kind, State: state, Due: due})
+	}
+	return alerts
+}
+
+/*
+SetAlerted records that state was notified for the order's rule, so repeated
+checks only notify when the state changes. An empty state clears it.
+*/
+func (s *Store) SetAlerted(poNumber, rule, state string) error {
+	return s.update(func(all map[string]*Order) []string {
+		o := all[poNumber]
+		if o == nil || o.Alerted[rule] == state {
+			return nil
+		}
+		if state == "" {
+			delete(o.Alerted, rule)
+		} else {
+			if o.Alerted == nil {
+				o.Alerted = map[string]string{}
+			}
+			o.Alerted[rule] = state
+		}
+		return []string{poNumber}
+	})
+}
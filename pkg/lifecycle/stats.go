@@ -0,0 +1,104 @@
+// pkg/lifecycle/stats.go
+package lifecycle
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+Stats summarizes how orders received in a period were handled.
+
+Fields:
+  - Orders:       Purchase orders received.
+  - Acknowledged: Of those, orders acknowledged (sent or accepted).
+  - AckLatency:   Time from receiving an order to its first acknowledgment.
+  - Shipped:      Orders with a ship notice and a known ship window end.
+  - ShippedLate:  Of those, orders whose first ship notice came after the window ended.
+  - Overdue:      Orders without a ship notice whose ship window has ended.
+  - Rejected:     Documents of those orders rejected by the partner.
+*/
+type Stats struct {
+	Orders       int
+	Acknowledged int
+	AckLatency   Latency
+	Shipped      int
+	ShippedLate  int
+	Overdue      int
+	Rejected     int
+}
+
+/*
+Latency describes a set of durations.
+
+Fields:
+  - Median: The middle value.
+  - P90:    The value 90% of durations do not exceed.
+  - Max:    The longest.
+*/
+type Latency struct {
+	Median time.Duration
+	P90    time.Duration
+	Max    time.Duration
+}
+
+/*
+Summarize computes the Stats of the orders received at or after since, with
+ship windows judged as of now.
+*/
+func Summarize(orders []Order, since, now time.Time) Stats {
+	var s Stats
+	var latencies []time.Duration
+	for _, o := range orders {
+		received, ok := o.ReceivedAt()
+		if !ok || received.Before(since) {
+			continue
+		}
+		s.Orders++
+		if ack, ok := o.first(Acknowledgment); ok {
+			s.Acknowledged++
+			latencies = append(latencies, ack.At.Sub(received))
+		}
+		if end, ok := o.Deadlines["shipWindowEnd"]; ok {
+			if asn, ok := o.first(ShipNotice); ok {
+				s.Shipped++
+				if asn.At.After(end) {
+					s.ShippedLate++
+				}
+			} else if now.After(end) {
+				s.Overdue++
+			}
+		}
+		s.Rejected += len(o.Filter(Rejected))
+	}
+	s.AckLatency = summarizeLatency(latencies)
+	return s
+}
+
+// first returns the earliest counting document of a kind.
+func (o Order) first(kind string) (Document, bool) {
+	var found *Document
+	for i := range o.Documents {
+		d := &o.Documents[i]
+		if d.Kind == kind && d.counts() && (found == nil || d.At.Before(found.At)) {
+			found = d
+		}
+	}
+	if found == nil {
+		return Document{}, false
+	}
+	return *found, true
+}
+
+// summarizeLatency returns the median, 90th percentile and maximum of ds.
+func summarizeLatency(ds []time.Duration) Latency {
+	if len(ds) == 0 {
+		return Latency{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	return Latency{
+		Median: ds[(len(ds)-1)/2],
+		P90:    ds[(len(ds)*9+9)/10-1],
+		Max:    ds[len(ds)-1],
+	}
+}
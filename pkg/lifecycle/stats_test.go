@@ -0,0 +1,49 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSummarize verifies acknowledgment latency, ship notice timeliness and
+// rejections are computed for the orders received in the period only.
+func TestSummarize(t *testing.T) {
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := since.AddDate(0, 0, 10)
+	at := func(h int) time.Time { return since.Add(time.Duration(h) * time.Hour) }
+	orders := []Order{
+		{PONumber: "ONTIME", Deadlines: map[string]time.Time{"shipWindowEnd": at(48)}, Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: at(1)},
+			{Kind: Acknowledgment, Status: Rejected, At: at(2)},
+			{Kind: Acknowledgment, Status: Accepted, At: at(3)},
+			{Kind: ShipNotice, Status: Sent, At: at(40)},
+		}},
+		{PONumber: "LATE", Deadlines: map[string]time.Time{"shipWindowEnd": at(48)}, Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: at(1)},
+			{Kind: Acknowledgment, Status: Sent, At: at(11)},
+			{Kind: ShipNotice, Status: Accepted, At: at(50)},
+		}},
+		{PONumber: "OVERDUE", Deadlines: map[string]time.Time{"shipWindowEnd": at(72)}, Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: at(2)},
+			{Kind: ShipNotice, Status: Generated, At: at(70)},
+		}},
+		{PONumber: "OLD", Documents: []Document{
+			{Kind: PurchaseOrder, Status: Received, At: since.Add(-time.Hour)},
+			{Kind: Acknowledgment, Status: Rejected, At: at(1)},
+		}},
+	}
+
+	got := Summarize(orders, since, now)
+	want := Stats{
+		Orders:       3,
+		Acknowledged: 2,
+		AckLatency:   Latency{Median: 2 * time.Hour, P90: 10 * time.Hour, Max: 10 * time.Hour},
+		Shipped:      2,
+		ShippedLate:  1,
+		Overdue:      1,
+		Rejected:     1,
+	}
+	if got != want {
+		t.Errorf("Summarize() = %+v; expected %+v", got, want)
+	}
+}
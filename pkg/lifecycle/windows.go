@@ -0,0 +1,226 @@
+// pkg/lifecycle/windows.go
+package lifecycle
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+LeadTime is how long a warehouse needs before an order can leave it and how
+long the carrier needs to deliver it.
+
+Fields:
+  - Warehouse: Ship-from party ID of the warehouse the rule applies to ("" for any).
+  - ShipTo:    Ship-to party IDs (Amazon fulfillment centers) the rule applies to (empty for any).
+  - Handling:  Time from acceptance until the order can ship.
+  - Transit:   Time from shipping until delivery.
+  - ShipDays:  Weekdays the warehouse ships on (empty for every day).
+*/
+type LeadTime struct {
+	Warehouse string
+	ShipTo    []string
+	Handling  time.Duration
+	Transit   time.Duration
+	ShipDays  []time.Weekday
+}
+
+/*
+MatchLeadTime returns the first rule that applies to an order shipped from
+warehouse to shipTo.
+*/
+func MatchLeadTime(rules []LeadTime, warehouse, shipTo string) (LeadTime, bool) {
+	for _, r := range rules {
+		if r.Warehouse != "" && r.Warehouse != warehouse {
+			continue
+		}
+		if len(r.ShipTo) > 0 && !contains(r.ShipTo, shipTo) {
+			continue
+		}
+		return r, true
+	}
+	return LeadTime{}, false
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Earliest returns when an order accepted at now can ship and be delivered at
+the earliest. A ship time falling on a day the warehouse does not ship moves
+to the start of its next ship day, in now's location.
+*/
+func (l LeadTime) Earliest(now time.Time) (ship, delivery time.Time) {
+	ship = now.Add(l.Handling)
+	if len(l.ShipDays) > 0 {
+		for i := 0; i < 7 && !l.shipsOn(ship.Weekday()); i++ {
+			y, m, d := ship.Date()
+			ship = time.Date(y, m, d+1, 0, 0, 0, 0, ship.Location())
+		}
+	}
+	return ship, ship.Add(l.Transit)
+}
+
+// shipsOn reports whether the warehouse ships on day.
+func (l LeadTime) shipsOn(day time.Weekday) bool {
+	for _, d := range l.ShipDays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+WindowRisk is a window an order would miss if it were accepted now.
+
+Fields:
+  - PONumber: The order.
+  - Window:   "shipWindow" or "deliveryWindow".
+  - End:      When the window closes.
+  - Earliest: The earliest ship or delivery time given the lead time.
+*/
+type WindowRisk struct {
+	PONumber string    `json:"poNumber"`
+	Window   string    `json:"window"`
+	End      time.Time `json:"end"`
+	Earliest time.Time `json:"earliest"`
+}
+
+// String describes the risk.
+func (r WindowRisk) String() string {
+	what := "ship"
+	if r.Window == "deliveryWindow" {
+		what = "delivery"
+	}
+	return fmt.Sprintf("%s: earliest %s %s is after the %s window ends %s", r.PONumber, what, r.Earliest.Format(time.RFC3339), what, r.End.Format(time.RFC3339))
+}
+
+/*
+CheckWindows reports the windows an order accepted at now cannot meet with
+lead time l.
+
+Parameters:
+  - poNumber:  The order.
+  - deadlines: Window bounds of the order, keyed like Order.Deadlines.
+*/
+func (l LeadTime) CheckWindows(poNumber string, deadlines map[string]time.Time, now time.Time) []WindowRisk {
+	ship, delivery := l.Earliest(now)
+	var risks []WindowRisk
+	if end, ok := deadlines["shipWindowEnd"]; ok && ship.After(end) {
+		risks = append(risks, WindowRisk{PONumber: poNumber, Window: "shipWindow", End: end, Earliest: ship})
+	}
+	if end, ok := deadlines["deliveryWindowEnd"]; ok && delivery.After(end) {
+		risks = append(risks, WindowRisk{PONumber: poNumber, Window: "deliveryWindow", End: end, Earliest: delivery})
+	}
+	return risks
+}
+
+/*
+WindowEvent is the ship or delivery window of one order.
+
+Fields:
+  - PONumber: The order.
+  - Window:   "shipWindow" or "deliveryWindow".
+  - Start:    When the window opens (End if unknown).
+  - End:      When the window closes (Start if unknown).
+*/
+type WindowEvent struct {
+	PONumber string    `json:"poNumber"`
+	Window   string    `json:"window"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+/*
+UpcomingWindows lists the windows that have not closed by from and open
+before to, sorted by start.
+
+Parameters:
+  - deadlines: Window bounds by PO number, keyed like Order.Deadlines.
+*/
+func UpcomingWindows(deadlines map[string]map[string]time.Time, from, to time.Time) []WindowEvent {
+	var events []WindowEvent
+	for po, d := range deadlines {
+		for _, w := range []string{"shipWindow", "deliveryWindow"} {
+			start, hasStart := d[w+"Start"]
+			end, hasEnd := d[w+"End"]
+			switch {
+			case !hasStart && !hasEnd:
+				continue
+			case !hasStart:
+				start = end
+			case !hasEnd:
+				end = start
+			}
+			if end.Before(from) || !start.Before(to) {
+				continue
+			}
+			events = append(events, WindowEvent{PONumber: po, Window: w, Start: start, End: end})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		if events[i].PONumber != events[j].PONumber {
+			return events[i].PONumber < events[j].PONumber
+		}
+		return events[i].Window > events[j].Window
+	})
+	return events
+}
+
+/*
+WriteICal writes events as an iCalendar (RFC 5545) file that calendar
+applications can import or subscribe to. Each event keeps its UID across
+exports, so a re-imported calendar updates instead of duplicating events.
+
+Parameters:
+  - stamp: Time of the export (DTSTAMP).
+*/
+func WriteICal(w io.Writer, events []WindowEvent, stamp time.Time) error {
+	const layout = "20060102T150405Z"
+	var b strings.Builder
+	line := func(s string) { b.WriteString(s + "\r\n") }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//avcimporter//order windows//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Amazon order windows")
+	for _, e := range events {
+		what := "Ship"
+		if e.Window == "deliveryWindow" {
+			what = "Delivery"
+		}
+		end := e.End
+		if !end.After(e.Start) {
+			end = e.Start.Add(time.Hour)
+		}
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%s-%s@avcimporter", icalText(e.PONumber), e.Window))
+		line("DTSTAMP:" + stamp.UTC().Format(layout))
+		line("DTSTART:" + e.Start.UTC().Format(layout))
+		line("DTEND:" + end.UTC().Format(layout))
+		line(fmt.Sprintf("SUMMARY:%s window PO %s", what, icalText(e.PONumber)))
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icalText escapes a value for an iCalendar text property.
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
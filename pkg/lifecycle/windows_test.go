@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckWindows verifies the earliest ship time skips days the warehouse
+// does not ship and that windows closing before it are reported.
+func TestCheckWindows(t *testing.T) {
+	rules := []LeadTime{
+		{Warehouse: "WH1", ShipTo: []string{"FC1"}, Handling: time.Hour},
+		{Warehouse: "WH1", Handling: 24 * time.Hour, Transit: 48 * time.Hour, ShipDays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+	}
+	if l, ok := MatchLeadTime(rules, "WH1", "FC1"); !ok || l.Handling != time.Hour {
+		t.Errorf("MatchLeadTime(WH1, FC1) = %+v, %v", l, ok)
+	}
+	if _, ok := MatchLeadTime(rules, "WH2", "FC1"); ok {
+		t.Error("MatchLeadTime(WH2) matched a WH1 rule")
+	}
+	l, _ := MatchLeadTime(rules, "WH1", "FC2")
+
+	friday := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	ship, delivery := l.Earliest(friday)
+	if want := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC); !ship.Equal(want) || !delivery.Equal(want.Add(48*time.Hour)) {
+		t.Errorf("Earliest(Friday) = %v, %v; expected Monday", ship, delivery)
+	}
+
+	deadlines := map[string]time.Time{
+		"shipWindowEnd":     time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC),
+		"deliveryWindowEnd": time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC),
+	}
+	risks := l.CheckWindows("PO1", deadlines, friday)
+	if len(risks) != 1 || risks[0].Window != "deliveryWindow" {
+		t.Fatalf("CheckWindows() = %+v; expected the delivery window", risks)
+	}
+	if s := risks[0].String(); !strings.Contains(s, "earliest delivery 2024-05-08T00:00:00Z") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+// TestWriteICal verifies only windows in range are exported, in order, with
+// stable UIDs.
+func TestWriteICal(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	deadlines := map[string]map[string]time.Time{
+		"PO1": {"shipWindowStart": day(4), "shipWindowEnd": day(6), "deliveryWindowStart": day(8), "deliveryWindowEnd": day(10)},
+		"PO2": {"shipWindowEnd": day(2)},
+		"PO3": {"shipWindowStart": day(20), "shipWindowEnd": day(22)},
+	}
+	events := UpcomingWindows(deadlines, day(3), day(15))
+	if len(events) != 2 || events[0].Window != "shipWindow" || events[1].Window != "deliveryWindow" {
+		t.Fatalf("UpcomingWindows() = %+v", events)
+	}
+
+	var b strings.Builder
+	if err := WriteICal(&b, events, day(3)); err != nil {
+		t.Fatal(err)
+	}
+	cal := b.String()
+	for _, want := range []string{"BEGIN:VCALENDAR\r\n", "UID:PO1-shipWindow@avcimporter\r\n", "DTSTART:20240508T000000Z\r\n", "SUMMARY:Delivery window PO PO1\r\n"} {
+		if !strings.Contains(cal, want) {
+			t.Errorf("calendar lacks %q:\n%s", want, cal)
+		}
+	}
+	if strings.Count(cal, "BEGIN:VEVENT") != 2 {
+		t.Errorf("calendar has %d events; expected 2", strings.Count(cal, "BEGIN:VEVENT"))
+	}
+}
@@ -0,0 +1,243 @@
+// pkg/lock/file.go
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+FileLocker implements Locker with lock files in a shared directory.
+
+Locks are created with O_EXCL, which is atomic on local filesystems and on
+NFSv3 and later, unlike flock(2). Each lock file records its owner and expiry
+so a lock left behind by a crashed instance can be taken over once it expires.
+*/
+type FileLocker struct {
+	dir   string
+	perms *utils.Permissions
+}
+
+// fileLockInfo is the content of a lock file.
+type fileLockInfo struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+/*
+NewFileLocker returns a FileLocker storing lock files in dir, creating it if
+needed. Lock files are written with perms (nil uses utils.DefaultPermissions).
+*/
+func NewFileLocker(dir string, perms *utils.Permissions) (*FileLocker, error) {
+	if err := perms.MkdirAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to create lock dir %s: %w", dir, err)
+	}
+	return &FileLocker{dir: dir, perms: perms}, nil
+}
+
+/*
+Acquire creates the lock file for name, taking over an expired one.
+*/
+func (l *FileLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	lease := &fileLease{path: filepath.Join(l.dir, name+".lock"), owner: newOwnerID(), ttl: ttl, perms: l.perms}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lease.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			werr := json.NewEncoder(f).Encode(fileLockInfo{Owner: lease.owner, Expires: time.Now().Add(ttl)})
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr == nil {
+				werr = l.perms.Apply(lease.path)
+			}
+			if werr != nil {
+				os.Remove(lease.path)
+				return nil, fmt.Errorf("failed to write lock file %s: %w", lease.path, werr)
+			}
+			return lease, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", lease.path, err)
+		}
+
+		expired, err := lockExpired(lease.path, ttl)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue // released meanwhile
+		case err != nil:
+			return nil, err
+		case !expired:
+			return nil, ErrLocked
+		}
+		if err := lease.takeOver(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrLocked
+}
+
+/*
+Clean removes lock files that expired more than olderThan ago, e.g. those of
+flows that no longer run and so are never taken over. A lock file is renamed
+aside before removal and restored if it turns out to have been re-acquired
+meanwhile.
+
+Returns:
+  - The removed lock files.
+  - An error if the directory cannot be read.
+*/
+func (l *FileLocker) Clean(olderThan time.Duration) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.lock"))
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var removed []string
+	for _, path := range paths {
+		if !staleLock(path, cutoff) {
+			continue
+		}
+		aside := path + ".stale"
+		if err := os.Rename(path, aside); err != nil {
+			continue
+		}
+		if !staleLock(aside, cutoff) {
+			// Re-acquired between the checks; put it back unless a new one exists.
+			if os.Link(aside, path) == nil {
+				os.Remove(aside)
+			}
+			continue
+		}
+		if err := os.Remove(aside); err == nil {
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// lockExpired reports whether the lock file at path has expired. Unreadable
+// content, possibly still being written by its owner, counts as expired ttl
+// after the modification time.
+func lockExpired(path string, ttl time.Duration) (bool, error) {
+	info, err := readFileLock(path)
+	if errors.Is(err, errCorruptLock) {
+		st, serr := os.Stat(path)
+		if serr != nil {
+			return false, serr
+		}
+		return !time.Now().Before(st.ModTime().Add(ttl)), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !time.Now().Before(info.Expires), nil
+}
+
+// staleLock reports whether the lock file at path expired before cutoff.
+// Unreadable content is judged by the modification time.
+func staleLock(path string, cutoff time.Time) bool {
+	info, err := readFileLock(path)
+	if errors.Is(err, errCorruptLock) {
+		st, serr := os.Stat(path)
+		return serr == nil && st.ModTime().Before(cutoff)
+	}
+	return err == nil && info.Expires.Before(cutoff)
+}
+
+// fileLease is a lock held through FileLocker.
+type fileLease struct {
+	path  string
+	owner string
+	ttl   time.Duration
+	perms *utils.Permissions
+}
+
+/*
+takeOver clears the lock file found expired, the way Clean does: it is renamed
+aside, which only one contender can do, and put back if what was moved turns
+out not to be expired, i.e. another contender took the lock over in between.
+Removing it instead could delete the lock just created by that contender.
+
+Returns:
+  - nil if the lock file is gone, so creating it may be tried again.
+  - ErrLocked if it was taken over by another contender.
+*/
+func (l *fileLease) takeOver() error {
+	aside := l.path + "." + l.owner + ".stale"
+	if err := os.Rename(l.path, aside); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to remove expired lock %s: %w", l.path, err)
+	}
+	if expired, _ := lockExpired(aside, l.ttl); !expired {
+		// Put it back unless yet another one exists.
+		os.Link(aside, l.path)
+		os.Remove(aside)
+		return ErrLocked
+	}
+	os.Remove(aside)
+	return nil
+}
+
+/*
+Refresh rewrites the lock file with a new expiry if this lease still owns it.
+*/
+func (l *fileLease) Refresh(ctx context.Context) error {
+	info, err := readFileLock(l.path)
+	if err != nil {
+		return err
+	}
+	if info.Owner != l.owner {
+		return fmt.Errorf("lock %s was taken over by %s", l.path, info.Owner)
+	}
+	data, _ := json.Marshal(fileLockInfo{Owner: l.owner, Expires: time.Now().Add(l.ttl)})
+	if err := l.perms.WriteFile(l.path, data); err != nil {
+		return fmt.Errorf("failed to refresh lock %s: %w", l.path, err)
+	}
+	return nil
+}
+
+/*
+Release removes the lock file if this lease still owns it.
+*/
+func (l *fileLease) Release(ctx context.Context) error {
+	info, err := readFileLock(l.path)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, errCorruptLock) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Owner != l.owner {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// errCorruptLock marks a lock file whose content cannot be parsed.
+var errCorruptLock = errors.New("corrupt lock file")
+
+// readFileLock parses the lock file at path.
+func readFileLock(path string) (fileLockInfo, error) {
+	var info fileLockInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fileLockInfo{}, fmt.Errorf("%s: %w", path, errCorruptLock)
+	}
+	return info, nil
+}
@@ -0,0 +1,134 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFileLockerExclusive verifies a held lock cannot be acquired twice and is free after release.
+func TestFileLockerExclusive(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewFileLocker(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := l.Acquire(ctx, "edi", time.Minute)
+	if err != nil {
+		t.Fatalf("first Acquire() returned %v", err)
+	}
+	if _, err := l.Acquire(ctx, "edi", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Acquire() = %v; expected ErrLocked", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("Release() returned %v", err)
+	}
+	if _, err := l.Acquire(ctx, "edi", time.Minute); err != nil {
+		t.Errorf("Acquire() after release returned %v", err)
+	}
+}
+
+// TestFileLockerTakesOverExpired verifies an expired lock left by a dead owner is taken over.
+func TestFileLockerTakesOverExpired(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stale := `{"owner":"dead-host","expires":"2000-01-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(dir, "api.lock"), []byte(stale), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := NewFileLocker(dir, nil)
+	if _, err := l.Acquire(ctx, "api", time.Minute); err != nil {
+		t.Errorf("Acquire() over expired lock returned %v", err)
+	}
+}
+
+// TestFileLockerConcurrentTakeover verifies that of two instances taking over
+// the same expired lock, the one that found it expired after the other had
+// already replaced it does not remove the new lock, and that of several
+// instances racing for an expired lock exactly one gets it.
+func TestFileLockerConcurrentTakeover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "edi.lock")
+	stale := []byte(`{"owner":"dead-host","expires":"2000-01-01T00:00:00Z"}`)
+
+	// B read the expired lock; A then took it over before B acted on it.
+	os.WriteFile(path, stale, 0o644)
+	l, _ := NewFileLocker(dir, nil)
+	a, err := l.Acquire(ctx, "edi", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() over expired lock returned %v", err)
+	}
+	b := &fileLease{path: path, owner: "host-b", ttl: time.Minute}
+	if err := b.takeOver(); !errors.Is(err, ErrLocked) {
+		t.Errorf("takeOver() of a fresh lock = %v; expected ErrLocked", err)
+	}
+	if info, err := readFileLock(path); err != nil || info.Owner != a.(*fileLease).owner {
+		t.Errorf("lock after late takeover = %+v, %v; expected it kept by A", info, err)
+	}
+	a.Release(ctx)
+
+	for round := 0; round < 50; round++ {
+		if err := os.WriteFile(path, stale, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		var won int32
+		start := make(chan struct{})
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if _, err := l.Acquire(ctx, "edi", time.Minute); err == nil {
+					atomic.AddInt32(&won, 1)
+				} else if !errors.Is(err, ErrLocked) {
+					t.Errorf("Acquire() returned %v", err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if won != 1 {
+			t.Fatalf("round %d: %d instances acquired the lock; expected 1", round, won)
+		}
+		if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.stale")); len(leftovers) > 0 {
+			t.Fatalf("round %d: takeover left %v behind", round, leftovers)
+		}
+		os.Remove(path)
+	}
+}
+
+// TestFileLockerClean verifies only locks expired longer than the given age are removed.
+func TestFileLockerClean(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewFileLocker(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "old.lock"), []byte(`{"owner":"dead-host","expires":"2000-01-01T00:00:00Z"}`), 0o644)
+	recent := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	os.WriteFile(filepath.Join(dir, "recent.lock"), []byte(`{"owner":"dead-host","expires":"`+recent+`"}`), 0o644)
+	if _, err := l.Acquire(context.Background(), "held", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := l.Clean(time.Hour)
+	if err != nil {
+		t.Fatalf("Clean() returned %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != "old.lock" {
+		t.Errorf("Clean() removed %v; expected only old.lock", removed)
+	}
+	for _, name := range []string{"recent.lock", "held.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// pkg/lock/lock.go
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+ErrLocked is returned by Acquire when another instance currently holds the lock.
+*/
+var ErrLocked = errors.New("lock is held by another instance")
+
+/*
+ErrLockLost is returned by Run when the lease could not be refreshed while fn
+was running, so another instance may have taken the lock over.
+*/
+var ErrLockLost = errors.New("lock lost while running")
+
+/*
+Locker grants exclusive, expiring leases on named locks shared between
+importer instances.
+*/
+type Locker interface {
+	// Acquire takes the named lock for ttl, or returns ErrLocked.
+	Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+}
+
+/*
+Lease is a held lock. It expires after its ttl unless refreshed.
+*/
+type Lease interface {
+	// Refresh extends the lease by another ttl.
+	Refresh(ctx context.Context) error
+	// Release gives up the lease so another instance may acquire it.
+	Release(ctx context.Context) error
+}
+
+/*
+Run executes fn while holding the named lock, refreshing the lease every third
+of ttl so long runs keep ownership. If a refresh fails or the lease was taken
+over, the context of fn is cancelled so it stops before another instance
+starts the same work. The lease is released when fn returns; Run logs nothing
+and reports a failed release with its error instead.
+
+Parameters:
+  - ctx:    Parent of the context passed to fn.
+  - locker: The lock backend.
+  - name:   The lock name (usually the flow name).
+  - ttl:    Lease duration; an instance that dies loses the lock after ttl.
+  - fn:     The work to perform.
+
+Returns:
+  - ErrLocked if another instance holds the lock, an error wrapping
+    ErrLockLost if the lease was lost during fn, otherwise fn's error,
+    joined with the error of a failed release.
+*/
+func Run(ctx context.Context, locker Locker, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lease, err := locker.Acquire(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(ttl / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := lease.Refresh(runCtx); err != nil {
+					cancel(fmt.Errorf("%w: %s: %v", ErrLockLost, name, err))
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(runCtx)
+	close(done)
+	if cause := context.Cause(runCtx); errors.Is(cause, ErrLockLost) {
+		err = cause
+	}
+	if rerr := lease.Release(context.WithoutCancel(ctx)); rerr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to release lock %s: %w", name, rerr))
+	}
+	return err
+}
+
+// newOwnerID returns a token identifying this process as a lock owner.
+func newOwnerID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
@@ -0,0 +1,67 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLocker hands out leases whose Refresh returns refreshErr.
+type fakeLocker struct {
+	refreshErr error
+	released   atomic.Bool
+}
+
+func (l *fakeLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	return fakeLease{l}, nil
+}
+
+type fakeLease struct{ l *fakeLocker }
+
+func (f fakeLease) Refresh(ctx context.Context) error { return f.l.refreshErr }
+
+func (f fakeLease) Release(ctx context.Context) error {
+	f.l.released.Store(true)
+	return nil
+}
+
+// TestRunLostLease verifies that a failed refresh cancels the context of fn
+// and that Run then reports ErrLockLost and still releases the lease.
+func TestRunLostLease(t *testing.T) {
+	l := &fakeLocker{refreshErr: errors.New("no longer owned")}
+	err := Run(context.Background(), l, "edi", 30*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			t.Error("context of fn was not cancelled after the refresh failed")
+			return nil
+		}
+	})
+	if !errors.Is(err, ErrLockLost) {
+		t.Errorf("Run() = %v; expected ErrLockLost", err)
+	}
+	if !l.released.Load() {
+		t.Error("Run() did not release the lease")
+	}
+}
+
+// TestRunRefreshes verifies that successful refreshes leave fn running and
+// that fn's own result is returned.
+func TestRunRefreshes(t *testing.T) {
+	l := &fakeLocker{}
+	want := errors.New("flow failed")
+	err := Run(context.Background(), l, "edi", 15*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			t.Error("context of fn was cancelled although the refreshes succeeded")
+		case <-time.After(60 * time.Millisecond):
+		}
+		return want
+	})
+	if err != want {
+		t.Errorf("Run() = %v; expected %v", err, want)
+	}
+}
@@ -0,0 +1,157 @@
+// pkg/lock/redis.go
+package lock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lua scripts making refresh and release conditional on ownership.
+const (
+	redisRefreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+/*
+RedisLocker implements Locker with Redis keys set via SET NX PX, the standard
+single-instance Redis locking pattern. It speaks RESP directly so no client
+library is required.
+*/
+type RedisLocker struct {
+	addr     string
+	password string
+	prefix   string
+}
+
+/*
+NewRedisLocker returns a RedisLocker for the server at addr ("host:port").
+Keys are named prefix + lock name; password may be empty.
+*/
+func NewRedisLocker(addr, password, prefix string) *RedisLocker {
+	return &RedisLocker{addr: addr, password: password, prefix: prefix}
+}
+
+/*
+Acquire sets the lock key if it does not exist yet.
+*/
+func (l *RedisLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	lease := &redisLease{locker: l, key: l.prefix + name, owner: newOwnerID(), ttl: ttl}
+	reply, err := l.do(ctx, "SET", lease.key, lease.owner, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire redis lock %s: %w", lease.key, err)
+	}
+	if reply == nil {
+		return nil, ErrLocked
+	}
+	return lease, nil
+}
+
+// redisLease is a lock held through RedisLocker.
+type redisLease struct {
+	locker *RedisLocker
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+/*
+Refresh extends the key's expiry if this lease still owns it.
+*/
+func (l *redisLease) Refresh(ctx context.Context) error {
+	reply, err := l.locker.do(ctx, "EVAL", redisRefreshScript, "1", l.key, l.owner, strconv.FormatInt(l.ttl.Milliseconds(), 10))
+	if err != nil {
+		return fmt.Errorf("failed to refresh redis lock %s: %w", l.key, err)
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return fmt.Errorf("redis lock %s is no longer owned by this instance", l.key)
+	}
+	return nil
+}
+
+/*
+Release deletes the key if this lease still owns it.
+*/
+func (l *redisLease) Release(ctx context.Context) error {
+	if _, err := l.locker.do(ctx, "EVAL", redisReleaseScript, "1", l.key, l.owner); err != nil {
+		return fmt.Errorf("failed to release redis lock %s: %w", l.key, err)
+	}
+	return nil
+}
+
+// do opens a connection, authenticates if configured, and runs one command.
+func (l *RedisLocker) do(ctx context.Context, args ...string) (interface{}, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", l.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	r := bufio.NewReader(conn)
+	if l.password != "" {
+		if _, err := redisCommand(conn, r, "AUTH", l.password); err != nil {
+			return nil, err
+		}
+	}
+	return redisCommand(conn, r, args...)
+}
+
+// redisCommand writes args as a RESP array and reads a single reply.
+func redisCommand(w io.Writer, r *bufio.Reader, args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(r)
+}
+
+// readRedisReply parses one RESP reply. Nil bulk strings are returned as nil.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	default:
+		return nil, fmt.Errorf("unsupported redis reply %q", line)
+	}
+}
@@ -0,0 +1,213 @@
+package lock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+fakeRedis serves the commands RedisLocker sends (AUTH, SET NX PX and the two
+EVAL scripts) from a map, ignoring expiry, and records every command.
+*/
+type fakeRedis struct {
+	password string
+	mu       sync.Mutex
+	keys     map[string]string
+	commands [][]string
+}
+
+// startFakeRedis serves f on a loopback port until the test ends.
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	f := &fakeRedis{password: password, keys: map[string]string{}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		reply := "-ERR unknown command\r\n"
+		switch {
+		case args[0] == "AUTH":
+			if len(args) == 2 && args[1] == f.password {
+				authed, reply = true, "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SET" && len(args) == 6 && args[3] == "NX" && args[4] == "PX":
+			if _, ok := f.keys[args[1]]; ok {
+				reply = "$-1\r\n"
+			} else {
+				f.keys[args[1]], reply = args[2], "+OK\r\n"
+			}
+		case args[0] == "EVAL" && len(args) >= 5:
+			owned := f.keys[args[3]] == args[4]
+			switch {
+			case !owned:
+				reply = ":0\r\n"
+			case args[1] == redisRefreshScript:
+				reply = ":1\r\n"
+			case args[1] == redisReleaseScript:
+				delete(f.keys, args[3])
+				reply = ":1\r\n"
+			}
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("not a RESP array: %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		v, err := readRedisReply(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i], _ = v.(string)
+	}
+	return args, nil
+}
+
+// TestRedisLocker walks a lease through acquire, refresh and release against
+// a fake server and checks that refresh and release only act on the key
+// while this lease owns it.
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	f, addr := startFakeRedis(t, "")
+	l := NewRedisLocker(addr, "", "avc:")
+
+	lease, err := l.Acquire(ctx, "edi", 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Acquire() returned %v", err)
+	}
+	if _, err := l.Acquire(ctx, "edi", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Acquire() = %v; expected ErrLocked", err)
+	}
+	if err := lease.Refresh(ctx); err != nil {
+		t.Errorf("Refresh() returned %v", err)
+	}
+	set := f.commands[0]
+	if want := []string{"SET", "avc:edi", set[2], "NX", "PX", "1500"}; strings.Join(set, " ") != strings.Join(want, " ") {
+		t.Errorf("Acquire() sent %q; expected %q", set, want)
+	}
+	refresh := f.commands[2]
+	if refresh[0] != "EVAL" || refresh[1] != redisRefreshScript || refresh[3] != "avc:edi" || refresh[4] != set[2] || refresh[5] != "1500" {
+		t.Errorf("Refresh() sent %q", refresh)
+	}
+
+	// Another instance took the key over after it expired.
+	f.mu.Lock()
+	f.keys["avc:edi"] = "other-owner"
+	f.mu.Unlock()
+	if err := lease.Refresh(ctx); err == nil || !strings.Contains(err.Error(), "no longer owned") {
+		t.Errorf("Refresh() of a lost lease = %v; expected an ownership error", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Errorf("Release() of a lost lease returned %v", err)
+	}
+	if f.keys["avc:edi"] != "other-owner" {
+		t.Error("Release() deleted the key of another owner")
+	}
+
+	f.mu.Lock()
+	delete(f.keys, "avc:edi")
+	f.mu.Unlock()
+	lease, err = l.Acquire(ctx, "edi", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() of a free key returned %v", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Errorf("Release() returned %v", err)
+	}
+	if _, ok := f.keys["avc:edi"]; ok {
+		t.Error("Release() left the key")
+	}
+}
+
+// TestRedisLockerAuth verifies the password is sent before every command and
+// that a wrong password fails the acquire.
+func TestRedisLockerAuth(t *testing.T) {
+	ctx := context.Background()
+	f, addr := startFakeRedis(t, "secret")
+	if _, err := NewRedisLocker(addr, "secret", "").Acquire(ctx, "api", time.Minute); err != nil {
+		t.Fatalf("Acquire() with the password returned %v", err)
+	}
+	if f.commands[0][0] != "AUTH" || f.commands[1][0] != "SET" {
+		t.Errorf("commands = %q; expected AUTH before SET", f.commands)
+	}
+	for _, password := range []string{"", "wrong"} {
+		if _, err := NewRedisLocker(addr, password, "").Acquire(ctx, "edi", time.Minute); err == nil || errors.Is(err, ErrLocked) {
+			t.Errorf("Acquire() with password %q = %v; expected an authentication error", password, err)
+		}
+	}
+}
+
+// TestReadRedisReply verifies the RESP reply types the locker relies on.
+func TestReadRedisReply(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{":1\r\n", int64(1), false},
+		{"$5\r\nowner\r\n", "owner", false},
+		{"$0\r\n\r\n", "", false},
+		{"$-1\r\n", nil, false},
+		{"-ERR wrong\r\n", nil, true},
+		{"*1\r\n", nil, true},
+		{"\r\n", nil, true},
+		{"$9\r\nshort\r\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := readRedisReply(bufio.NewReader(strings.NewReader(tt.in)))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("readRedisReply(%q) = %v, %v; expected %v (error: %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// pkg/logsink/cloudwatch.go
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// DefaultFlushInterval is how often buffered CloudWatch events are sent.
+const DefaultFlushInterval = 5 * time.Second
+
+// Limits of a PutLogEvents batch; each event counts 26 bytes on top of its message.
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1 << 20
+	eventOverhead  = 26
+	maxEventBytes  = 256<<10 - eventOverhead
+	maxPending     = 100000
+)
+
+/*
+CloudWatchOptions configures a CloudWatch Logs sink.
+
+Fields:
+  - Group:         Log group; created if it does not exist.
+  - Stream:        Log stream; created if it does not exist.
+  - Region:        AWS region of the log group.
+  - Credentials:   AWS credentials the requests are signed with.
+  - FlushInterval: How often buffered events are sent (default: DefaultFlushInterval).
+  - Endpoint:      URL replacing https://logs.<region>.amazonaws.com (tests, VPC endpoints).
+  - HTTP:          HTTP client; nil means a client with a 30s timeout.
+  - Logger:        Logger the sink is added to; failures of the sink are printed to its output.
+*/
+type CloudWatchOptions struct {
+	Group         string
+	Stream        string
+	Region        string
+	Credentials   sigv4.Provider
+	FlushInterval time.Duration
+	Endpoint      string
+	HTTP          *http.Client
+	Logger        *utils.Logger
+}
+
+/*
+CloudWatch is a utils.LogSink sending log messages to CloudWatch Logs. Messages
+are buffered and sent in batches with PutLogEvents every FlushInterval, or
+sooner once a batch is full. The stream's sequence token is tracked across
+batches and corrected when CloudWatch reports a different one. Messages
+arriving while the buffer holds maxPending events are dropped and counted.
+*/
+type CloudWatch struct {
+	opts CloudWatchOptions
+
+	mu      sync.Mutex
+	pending []cwEvent
+	dropped int
+
+	// token and streamReady are only used by the flushing goroutine.
+	token       string
+	streamReady bool
+	failing     bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// cwEvent is a log event as PutLogEvents expects it.
+type cwEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+/*
+NewCloudWatch returns a started CloudWatch sink; Close flushes and stops it.
+
+Returns:
+  - An error if the group, region or credentials are missing.
+*/
+func NewCloudWatch(opts CloudWatchOptions) (*CloudWatch, error) {
+	if opts.Group == "" || opts.Stream == "" || opts.Region == "" {
+		return nil, fmt.Errorf("cloudwatch sink needs a group, stream and region")
+	}
+	if opts.Credentials == nil {
+		return nil, fmt.Errorf("cloudwatch sink needs AWS credentials")
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://logs." + opts.Region + ".amazonaws.com/"
+	}
+	if opts.HTTP == nil {
+		opts.HTTP = &http.Client{Timeout: 30 * time.Second}
+	}
+	c := &CloudWatch{opts: opts, wake: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	go c.run()
+	return c, nil
+}
+
+/*
+Log buffers r.
+*/
+func (c *CloudWatch) Log(r utils.LogRecord) {
+	msg := Format(r)
+	if len(msg) > maxEventBytes {
+		msg = msg[:maxEventBytes]
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) >= maxPending {
+		c.dropped++
+		return
+	}
+	c.pending = append(c.pending, cwEvent{Timestamp: r.Time.UnixMilli(), Message: msg})
+	if len(c.pending) == maxBatchEvents {
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+/*
+Close sends the buffered events, giving up after ctx is done, and stops the sink.
+*/
+func (c *CloudWatch) Close(ctx context.Context) error {
+	close(c.stop)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.flush(ctx)
+}
+
+// run flushes every FlushInterval or when woken until Close is called.
+func (c *CloudWatch) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		case <-c.wake:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		c.report(c.flush(ctx))
+		cancel()
+	}
+}
+
+// report prints a failed flush once, and that sending works again once
+// it does. It writes to the log output directly so the message is not fed
+// back into the sink.
+func (c *CloudWatch) report(err error) {
+	switch {
+	case err != nil && !c.failing:
+		c.opts.Logger.PrintOutput("CloudWatch log sink failed: ", err.Error(), "#FF0000")
+	case err == nil && c.failing:
+		c.opts.Logger.PrintOutput("CloudWatch log sink recovered.", "", "#32CD32")
+	}
+	c.failing = err != nil
+}
+
+// flush sends the buffered events in as many batches as needed. Events of
+// a batch that fails stay buffered for the next flush.
+func (c *CloudWatch) flush(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		batch, size := c.pending, 0
+		for i, e := range batch {
+			size += len(e.Message) + eventOverhead
+			if i == maxBatchEvents || size > maxBatchBytes {
+				batch = batch[:i]
+				break
+			}
+		}
+		dropped := c.dropped
+		c.dropped = 0
+		c.mu.Unlock()
+		if dropped > 0 {
+			c.opts.Logger.PrintOutput("CloudWatch log sink dropped messages: ", fmt.Sprintf("%d (buffer full)", dropped), "#FFFF00")
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := c.put(ctx, batch); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.pending = c.pending[len(batch):]
+		c.mu.Unlock()
+	}
+}
+
+// put sends one batch, creating the stream (and group) on first use and
+// retrying once with the sequence token CloudWatch expects.
+func (c *CloudWatch) put(ctx context.Context, batch []cwEvent) error {
+	// Events of a batch must be in chronological order.
+	events := append([]cwEvent(nil), batch...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+
+	if !c.streamReady {
+		if err := c.createStream(ctx); err != nil {
+			return err
+		}
+		c.streamReady = true
+	}
+	for attempt := 1; ; attempt++ {
+		req := map[string]interface{}{"logGroupName": c.opts.Group, "logStreamName": c.opts.Stream, "logEvents": events}
+		if c.token != "" {
+			req["sequenceToken"] = c.token
+		}
+		var resp struct {
+			NextSequenceToken string `json:"nextSequenceToken"`
+		}
+		err := c.call(ctx, "PutLogEvents", req, &resp)
+		if err == nil {
+			c.token = resp.NextSequenceToken
+			return nil
+		}
+		e, ok := err.(*cwError)
+		switch {
+		case ok && e.Type == "DataAlreadyAcceptedException":
+			// A previous attempt went through after all.
+			c.token = e.ExpectedSequenceToken
+			return nil
+		case ok && e.Type == "InvalidSequenceTokenException" && attempt == 1:
+			c.token = e.ExpectedSequenceToken
+		case ok && e.Type == "ResourceNotFoundException" && attempt == 1:
+			if err := c.createStream(ctx); err != nil {
+				return err
+			}
+			c.token = ""
+		default:
+			return err
+		}
+	}
+}
+
+// createStream creates the log stream, and the group if that is missing too.
+func (c *CloudWatch) createStream(ctx context.Context) error {
+	stream := map[string]interface{}{"logGroupName": c.opts.Group, "logStreamName": c.opts.Stream}
+	err := c.call(ctx, "CreateLogStream", stream, nil)
+	if e, ok := err.(*cwError); ok && e.Type == "ResourceNotFoundException" {
+		if err := c.call(ctx, "CreateLogGroup", map[string]interface{}{"logGroupName": c.opts.Group}, nil); err != nil && !alreadyExists(err) {
+			return err
+		}
+		err = c.call(ctx, "CreateLogStream", stream, nil)
+	}
+	if err != nil && !alreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// cwError is an error response of the CloudWatch Logs API.
+type cwError struct {
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+	Status                int    `json:"-"`
+}
+
+func (e *cwError) Error() string {
+	return fmt.Sprintf("%s (status %d): %s", e.Type, e.Status, e.Message)
+}
+
+func alreadyExists(err error) bool {
+	e, ok := err.(*cwError)
+	return ok && e.Type == "ResourceAlreadyExistsException"
+}
+
+// call invokes a CloudWatch Logs API action with a signed JSON request.
+func (c *CloudWatch) call(ctx context.Context, action string, in, out interface{}) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	creds, err := c.opts.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opts.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	sigv4.Sign(req, payload, creds, c.opts.Region, "logs", time.Now())
+
+	resp, err := c.opts.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &cwError{Status: resp.StatusCode}
+		if json.Unmarshal(body, e) != nil || e.Type == "" {
+			e.Type, e.Message = "HTTPError", strings.TrimSpace(string(body))
+		}
+		// __type may be namespaced, e.g. "com.amazonaws.logs#InvalidSequenceTokenException".
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("invalid %s response: %w", action, err)
+		}
+	}
+	return nil
+}
+
+/*
+DefaultStream returns the stream name used when none is configured: the host name.
+*/
+func DefaultStream() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "avcimporter"
+	}
+	return host
+}
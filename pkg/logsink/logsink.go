@@ -0,0 +1,25 @@
+// pkg/logsink/logsink.go
+package logsink
+
+import (
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Format renders r as a single line: the level, the flow and module labels in
+brackets, then the message, e.g. "WARN [EDI] Skipping file: PO_1.edi".
+*/
+func Format(r utils.LogRecord) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(r.Level.String()))
+	if r.Flow != "" {
+		b.WriteString(" [" + r.Flow + "]")
+	}
+	if r.Module != "" {
+		b.WriteString(" [" + r.Module + "]")
+	}
+	b.WriteString(" " + strings.TrimSpace(r.Message))
+	return b.String()
+}
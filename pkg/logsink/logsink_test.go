@@ -0,0 +1,129 @@
+package logsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// TestCloudWatchBatchesWithSequenceTokens verifies a missing stream is
+// created, a stale sequence token is corrected and events arrive in order.
+func TestCloudWatchBatchesWithSequenceTokens(t *testing.T) {
+	var mu sync.Mutex
+	var actions []string
+	var messages []string
+	streamExists := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+		actions = append(actions, action)
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/logs/aws4_request") {
+			t.Errorf("Authorization = %s; expected a logs scope", r.Header.Get("Authorization"))
+		}
+		var in struct {
+			SequenceToken string `json:"sequenceToken"`
+			LogEvents     []struct {
+				Message string `json:"message"`
+			} `json:"logEvents"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch action {
+		case "CreateLogStream":
+			streamExists = true
+		case "PutLogEvents":
+			if !streamExists {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"__type":"ResourceNotFoundException","message":"stream missing"}`)
+				return
+			}
+			if in.SequenceToken != "t1" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"__type":"InvalidSequenceTokenException","message":"bad token","expectedSequenceToken":"t1"}`)
+				return
+			}
+			for _, e := range in.LogEvents {
+				messages = append(messages, e.Message)
+			}
+			io.WriteString(w, `{"nextSequenceToken":"t1"}`)
+		}
+	}))
+	defer srv.Close()
+
+	sink, err := NewCloudWatch(CloudWatchOptions{Group: "g", Stream: "s", Region: "eu-west-1", Endpoint: srv.URL,
+		Credentials: static{AccessKeyID: "AKID", SecretAccessKey: "secret"}, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	sink.Log(utils.LogRecord{Time: now.Add(time.Millisecond), Level: utils.LevelWarn, Flow: "EDI", Message: "second"})
+	sink.Log(utils.LogRecord{Time: now, Level: utils.LevelInfo, Message: "first"})
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("Close() returned %v", err)
+	}
+
+	// The stream is created up front; the token is learned from the first rejection.
+	if got := strings.Join(actions, ","); got != "CreateLogStream,PutLogEvents,PutLogEvents" {
+		t.Errorf("actions = %s", got)
+	}
+	if len(messages) != 2 || messages[0] != "INFO first" || messages[1] != "WARN [EDI] second" {
+		t.Errorf("messages = %q; expected both in chronological order", messages)
+	}
+}
+
+// TestSyslogTCP verifies RFC 5424 messages are sent octet-counted over TCP.
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(bufio.NewReader(conn))
+		received <- string(data)
+	}()
+
+	facility, _ := ParseFacility("local0")
+	sink, err := NewSyslog(SyslogOptions{Network: "tcp", Addr: ln.Addr().String(), Facility: facility, Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Log(utils.LogRecord{Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Level: utils.LevelWarn, Module: "sftp", Flow: "EDI", Message: "Retrying: dial"})
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	length, line, _ := strings.Cut(got, " ")
+	if !strings.HasPrefix(line, "<132>1 2024-05-01T12:00:00.000000Z host avcimporter ") || !strings.HasSuffix(line, " sftp - [EDI] Retrying: dial") {
+		t.Errorf("message = %q", got)
+	}
+	if length != strconv.Itoa(len(line)) {
+		t.Errorf("frame length %s; message has %d bytes", length, len(line))
+	}
+}
+
+// static is a credentials provider returning fixed keys.
+type static sigv4.Credentials
+
+func (s static) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	return sigv4.Credentials(s), nil
+}
@@ -0,0 +1,223 @@
+// pkg/logsink/syslog.go
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// syslogQueue is how many messages wait for the syslog connection before new ones are dropped.
+const syslogQueue = 10000
+
+// facilities are the syslog facility codes by name.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+/*
+ParseFacility returns the code of a syslog facility name such as "daemon" or "local0".
+*/
+func ParseFacility(name string) (int, error) {
+	code, ok := facilities[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return code, nil
+}
+
+// severities maps log levels to syslog severities (err, warning, info, debug).
+var severities = map[utils.Level]int{utils.LevelError: 3, utils.LevelWarn: 4, utils.LevelInfo: 6, utils.LevelDebug: 7}
+
+/*
+SyslogOptions configures a syslog sink.
+
+Fields:
+  - Network:  "udp" (default) or "tcp".
+  - Addr:     Server address (host:port).
+  - Facility: Facility code (see ParseFacility).
+  - AppName:  APP-NAME of each message (default: "avcimporter").
+  - Hostname: HOSTNAME of each message (default: the host name).
+  - Logger:   Logger the sink is added to; failures of the sink are printed to its output.
+*/
+type SyslogOptions struct {
+	Network  string
+	Addr     string
+	Facility int
+	AppName  string
+	Hostname string
+	Logger   *utils.Logger
+}
+
+/*
+Syslog is a utils.LogSink sending RFC 5424 messages to a syslog server, one
+datagram per message over UDP or octet-counted (RFC 6587) over TCP. Messages
+are queued and written by a background goroutine that reconnects after
+errors; messages arriving while the queue is full are dropped and counted.
+*/
+type Syslog struct {
+	opts    SyslogOptions
+	queue   chan utils.LogRecord
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// conn and failing are only used by the writing goroutine.
+	conn    net.Conn
+	failing bool
+}
+
+/*
+NewSyslog returns a started syslog sink; Close sends the queued messages and
+stops it. The connection is opened with the first message.
+
+Returns:
+  - An error if the address or network is invalid.
+*/
+func NewSyslog(opts SyslogOptions) (*Syslog, error) {
+	if opts.Network == "" {
+		opts.Network = "udp"
+	}
+	if opts.Network != "udp" && opts.Network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q: expected udp or tcp", opts.Network)
+	}
+	if _, _, err := net.SplitHostPort(opts.Addr); err != nil {
+		return nil, fmt.Errorf("invalid syslog address %q: %w", opts.Addr, err)
+	}
+	if opts.AppName == "" {
+		opts.AppName = "avcimporter"
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	s := &Syslog{opts: opts, queue: make(chan utils.LogRecord, syslogQueue), done: make(chan struct{})}
+	go s.run()
+	return s, nil
+}
+
+/*
+Log queues r.
+*/
+func (s *Syslog) Log(r utils.LogRecord) {
+	select {
+	case s.queue <- r:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+/*
+Close sends the queued messages, giving up after ctx is done, and closes the connection.
+*/
+func (s *Syslog) Close(ctx context.Context) error {
+	close(s.queue)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run writes queued messages until the queue is closed.
+func (s *Syslog) run() {
+	defer close(s.done)
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+	for r := range s.queue {
+		if n := s.dropped.Swap(0); n > 0 {
+			s.send(utils.LogRecord{Time: time.Now(), Level: utils.LevelWarn, Message: fmt.Sprintf("%d log messages dropped (syslog queue full)", n)})
+		}
+		s.send(r)
+	}
+}
+
+// send writes one message, reconnecting once if the connection fails.
+// Failures are reported once, to the log output directly.
+func (s *Syslog) send(r utils.LogRecord) {
+	msg := s.Format(r)
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = net.DialTimeout(s.opts.Network, s.opts.Addr, 10*time.Second); err != nil {
+				s.conn = nil
+				continue
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err = s.conn.Write(msg); err == nil {
+			break
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	switch {
+	case err != nil && !s.failing:
+		s.opts.Logger.PrintOutput("Syslog sink failed: ", err.Error(), "#FF0000")
+	case err == nil && s.failing:
+		s.opts.Logger.PrintOutput("Syslog sink recovered.", "", "#32CD32")
+	}
+	s.failing = err != nil
+}
+
+/*
+Format renders r as an RFC 5424 message, framed for the configured network.
+The level becomes the severity and the log module (if any) the MSGID; the
+flow label precedes the message text.
+
+Usage:
+
+	<134>1 2024-05-01T12:00:00.000000Z host avcimporter 4242 sftp - Listing /in: 3 entries
+*/
+func (s *Syslog) Format(r utils.LogRecord) []byte {
+	severity, ok := severities[r.Level]
+	if !ok {
+		severity = 6
+	}
+	msgID := r.Module
+	if msgID == "" {
+		msgID = "-"
+	}
+	text := strings.TrimSpace(r.Message)
+	if r.Flow != "" {
+		text = "[" + r.Flow + "] " + text
+	}
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.opts.Facility*8+severity,
+		r.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		header(s.opts.Hostname, 255), header(s.opts.AppName, 48), os.Getpid(), header(msgID, 32),
+		text)
+	if s.opts.Network == "tcp" {
+		return []byte(strconv.Itoa(len(line)) + " " + line)
+	}
+	return []byte(line)
+}
+
+// header makes v a valid RFC 5424 header field: printable ASCII without
+// spaces, at most max characters, "-" when empty.
+func header(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, v)
+	if len(v) > max {
+		v = v[:max]
+	}
+	if v == "" {
+		return "-"
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+// pkg/mail/mail.go
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Server is the SMTP server mail is sent through.
+
+Fields:
+  - Host:     SMTP server host name.
+  - Port:     SMTP port; 465 connects with TLS, other ports upgrade with STARTTLS when the server offers it.
+  - Username: Login for PLAIN authentication (empty: send without authenticating).
+  - Password: Password of Username.
+*/
+type Server struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+/*
+Attachment is a file attached to a Message.
+*/
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+/*
+Message is a plain text email with attachments.
+*/
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+/*
+Bytes encodes the message as MIME: a multipart/mixed message with the body as
+the first part and the attachments base64-encoded.
+
+Parameters:
+  - date: Value of the Date header.
+*/
+func (m Message) Bytes(date time.Time) []byte {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@avcimporter>\r\n", randomID())
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64(part, []byte(m.Body))
+	for _, a := range m.Attachments {
+		part, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		writeBase64(part, a.Data)
+	}
+	mw.Close()
+	return b.Bytes()
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters.
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	w.Write([]byte(enc + "\r\n"))
+}
+
+// randomID returns a random message ID.
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+Send delivers m through s.
+
+Returns:
+  - An error if the server cannot be reached or refuses the message.
+*/
+func Send(s Server, m Message) error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	data := m.Bytes(time.Now())
+	if s.Port != 465 {
+		if err := smtp.SendMail(addr, auth, m.From, m.To, data); err != nil {
+			return fmt.Errorf("send mail via %s: %w", addr, err)
+		}
+		return nil
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: s.Host})
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("authenticate to %s: %w", addr, err)
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("send mail to %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMessage is a message with one PDF attachment.
+var testMessage = Message{
+	From:        "avc@example.com",
+	To:          []string{"wh1@example.com", "wh2@example.com"},
+	Subject:     "PO PO1 – documents",
+	Body:        "Documents of PO1.",
+	Attachments: []Attachment{{Name: "PO1_po.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")}},
+}
+
+// TestBytes verifies the message parses as multipart MIME with the body and
+// the attachment.
+func TestBytes(t *testing.T) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(testMessage.Bytes(time.Now()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != testMessage.Subject {
+		t.Errorf("Subject = %q", subject)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(p)
+		parts = append(parts, p.FileName()+"="+string(data))
+	}
+	// multipart.Reader decodes quoted-printable only, so the parts are still base64.
+	want := []string{"=RG9jdW1lbnRzIG9mIFBPMS4=\r\n", "PO1_po.pdf=JVBERi0xLjQ=\r\n"}
+	if strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("parts = %q; expected %q", parts, want)
+	}
+}
+
+// TestSend verifies the envelope and message reach an SMTP server.
+func TestSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var commands []string
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimSpace(line)
+			commands = append(commands, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 test")
+			case line == "DATA":
+				reply("354 go on")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				got <- commands
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		got <- commands
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	if err := Send(Server{Host: host, Port: p}, testMessage); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	commands := strings.Join(<-got, "\n")
+	for _, want := range []string{"MAIL FROM:<avc@example.com>", "RCPT TO:<wh1@example.com>", "RCPT TO:<wh2@example.com>", "DATA"} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("server did not receive %q:\n%s", want, commands)
+		}
+	}
+}
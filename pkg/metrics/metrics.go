@@ -0,0 +1,136 @@
+// pkg/metrics/metrics.go
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+Metric names, without the "avcimporter_" prefix the Prometheus endpoint adds.
+Every metric carries a flow label.
+*/
+const (
+	Runs          = "runs_total"
+	RunFailures   = "run_failures_total"
+	Files         = "files_total"
+	POs           = "purchase_orders_total"
+	Bytes         = "downloaded_bytes_total"
+	RunDuration   = "run_duration_seconds"
+	LastSuccessAt = "last_success_timestamp_seconds"
+)
+
+// kinds tells counters from gauges; help describes each metric.
+var (
+	gauges = map[string]bool{RunDuration: true, LastSuccessAt: true}
+	help   = map[string]string{
+		Runs:          "Flow runs, successful or not.",
+		RunFailures:   "Flow runs that failed.",
+		Files:         "Files written by flow runs.",
+		POs:           "Purchase orders handled by flow runs.",
+		Bytes:         "Bytes downloaded by flow runs.",
+		RunDuration:   "Duration of the most recent run.",
+		LastSuccessAt: "Unix time of the most recent successful run.",
+	}
+)
+
+/*
+Sample is the value of one metric for one flow.
+
+Fields:
+  - Name:  Metric name, e.g. Runs.
+  - Flow:  Flow name, e.g. "edi".
+  - Value: Current value.
+  - Gauge: Whether the metric is a gauge (set) rather than a counter (added to).
+*/
+type Sample struct {
+	Name  string
+	Flow  string
+	Value float64
+	Gauge bool
+}
+
+// key identifies a metric of one flow.
+type key struct {
+	name string
+	flow string
+}
+
+/*
+Registry holds the metrics of this process. Counters start at zero, so for a
+single run they are the increments of that run. The zero Registry is ready to use.
+*/
+type Registry struct {
+	mu     sync.Mutex
+	values map[key]float64
+}
+
+/*
+Add adds v to the counter name of flow.
+*/
+func (r *Registry) Add(name, flow string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = map[key]float64{}
+	}
+	r.values[key{name, flow}] += v
+}
+
+/*
+Set sets the gauge name of flow to v.
+*/
+func (r *Registry) Set(name, flow string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = map[key]float64{}
+	}
+	r.values[key{name, flow}] = v
+}
+
+/*
+Snapshot returns every metric, sorted by name and flow.
+*/
+func (r *Registry) Snapshot() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Sample, 0, len(r.values))
+	for k, v := range r.values {
+		out = append(out, Sample{Name: k.name, Flow: k.flow, Value: v, Gauge: gauges[k.name]})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Flow < out[j].Flow
+	})
+	return out
+}
+
+/*
+WritePrometheus writes every metric in the Prometheus text exposition format.
+*/
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	last := ""
+	for _, s := range r.Snapshot() {
+		name := "avcimporter_" + s.Name
+		if s.Name != last {
+			kind := "counter"
+			if s.Gauge {
+				kind = "gauge"
+			}
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help[s.Name], name, kind); err != nil {
+				return err
+			}
+			last = s.Name
+		}
+		if _, err := fmt.Fprintf(w, "%s{flow=%q} %s\n", name, s.Flow, strconv.FormatFloat(s.Value, 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+)
+
+func testRegistry() *Registry {
+	r := &Registry{}
+	r.Add(Runs, "edi", 1)
+	r.Add(Runs, "edi", 1)
+	r.Add(RunFailures, "api", 1)
+	r.Set(RunDuration, "edi", 1.5)
+	return r
+}
+
+// TestWritePrometheus verifies the exposition format with one HELP/TYPE per metric.
+func TestWritePrometheus(t *testing.T) {
+	var b strings.Builder
+	if err := testRegistry().WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP avcimporter_run_duration_seconds Duration of the most recent run.
+# TYPE avcimporter_run_duration_seconds gauge
+avcimporter_run_duration_seconds{flow="edi"} 1.5
+# HELP avcimporter_run_failures_total Flow runs that failed.
+# TYPE avcimporter_run_failures_total counter
+avcimporter_run_failures_total{flow="api"} 1
+# HELP avcimporter_runs_total Flow runs, successful or not.
+# TYPE avcimporter_runs_total counter
+avcimporter_runs_total{flow="edi"} 2
+`
+	if b.String() != want {
+		t.Errorf("WritePrometheus() =\n%s\nexpected\n%s", b.String(), want)
+	}
+}
+
+// TestPushStatsD verifies plain StatsD names carry the flow and DogStatsD tags it.
+func TestPushStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	samples := testRegistry().Snapshot()
+
+	for _, tc := range []struct {
+		dog  bool
+		want string
+	}{
+		{false, "avc.edi.run_duration_seconds:1.5|g\navc.api.run_failures_total:1|c\navc.edi.runs_total:2|c"},
+		{true, "avc.run_duration_seconds:1.5|g|#flow:edi\navc.run_failures_total:1|c|#flow:api\navc.runs_total:2|c|#flow:edi"},
+	} {
+		if err := PushStatsD(conn.LocalAddr().String(), "avc", tc.dog, samples); err != nil {
+			t.Fatalf("PushStatsD() returned %v", err)
+		}
+		buf := make([]byte, 2048)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != tc.want {
+			t.Errorf("dogStatsD=%v packet =\n%s\nexpected\n%s", tc.dog, got, tc.want)
+		}
+	}
+}
+
+// TestCloudWatchPush verifies PutMetricData carries each sample with its flow dimension.
+func TestCloudWatchPush(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/monitoring/aws4_request") {
+			t.Errorf("Authorization = %s; expected a monitoring scope", r.Header.Get("Authorization"))
+		}
+	}))
+	defer srv.Close()
+
+	cw := &CloudWatch{Namespace: "AVCImporter", Region: "us-east-1", Endpoint: srv.URL,
+		Credentials: static{AccessKeyID: "AKID", SecretAccessKey: "secret"}}
+	if err := cw.Push(context.Background(), testRegistry().Snapshot(), time.Now()); err != nil {
+		t.Fatalf("Push() returned %v", err)
+	}
+	for k, want := range map[string]string{
+		"Action":                         "PutMetricData",
+		"Namespace":                      "AVCImporter",
+		"MetricData.member.3.MetricName": "runs_total",
+		"MetricData.member.3.Value":      "2",
+		"MetricData.member.3.Unit":       "Count",
+		"MetricData.member.3.Dimensions.member.1.Value": "edi",
+		"MetricData.member.1.Unit":                      "Seconds",
+	} {
+		if got := strings.Join(form[k], ","); got != want {
+			t.Errorf("%s = %q; expected %q", k, got, want)
+		}
+	}
+}
+
+// static is a credentials provider returning fixed keys.
+type static sigv4.Credentials
+
+func (s static) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	return sigv4.Credentials(s), nil
+}
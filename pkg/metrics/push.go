@@ -0,0 +1,184 @@
+// pkg/metrics/push.go
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/sigv4"
+)
+
+// statsdPacket bounds the size of a StatsD datagram, safe for any MTU path.
+const statsdPacket = 1432
+
+/*
+PushStatsD sends samples to a StatsD server over UDP, counters as "|c" and
+gauges as "|g". Plain StatsD has no tags, so the flow becomes part of the
+name ("<prefix>.<flow>.<metric>"); with dogStatsD it is sent as a "flow" tag
+of "<prefix>.<metric>" instead.
+
+Parameters:
+  - addr:      Server address (host:port).
+  - prefix:    Name prefix, e.g. "avcimporter".
+  - dogStatsD: Whether to use DogStatsD tags.
+  - samples:   The metrics to send.
+
+Returns:
+  - An error if the address cannot be resolved or a datagram not sent.
+*/
+func PushStatsD(addr, prefix string, dogStatsD bool, samples []Sample) error {
+	conn, err := net.DialTimeout("udp", addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to reach statsd at %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	send := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for _, s := range samples {
+		kind := "c"
+		if s.Gauge {
+			kind = "g"
+		}
+		value := strconv.FormatFloat(s.Value, 'f', -1, 64)
+		var line string
+		if dogStatsD {
+			line = fmt.Sprintf("%s.%s:%s|%s|#flow:%s", prefix, s.Name, value, kind, s.Flow)
+		} else {
+			line = fmt.Sprintf("%s.%s.%s:%s|%s", prefix, s.Flow, s.Name, value, kind)
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdPacket {
+			if err := send(); err != nil {
+				return fmt.Errorf("failed to send metrics to statsd: %w", err)
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if err := send(); err != nil {
+		return fmt.Errorf("failed to send metrics to statsd: %w", err)
+	}
+	return nil
+}
+
+// cloudWatchBatch is how many metrics one PutMetricData call carries.
+const cloudWatchBatch = 500
+
+/*
+CloudWatch pushes metrics to CloudWatch with PutMetricData.
+
+Fields:
+  - Namespace:   Metric namespace, e.g. "AVCImporter".
+  - Region:      AWS region.
+  - Credentials: AWS credentials the calls are signed with.
+  - Endpoint:    URL replacing https://monitoring.<region>.amazonaws.com (tests, VPC endpoints).
+  - HTTP:        HTTP client; nil means http.DefaultClient.
+*/
+type CloudWatch struct {
+	Namespace   string
+	Region      string
+	Credentials sigv4.Provider
+	Endpoint    string
+	HTTP        *http.Client
+}
+
+/*
+Push sends samples as metrics named after the sample with a "Flow" dimension,
+all stamped with now. Counters are sent with unit Count (Bytes for byte
+counts) and run durations in Seconds.
+
+Returns:
+  - An error carrying CloudWatch's error code if a call is rejected.
+*/
+func (c *CloudWatch) Push(ctx context.Context, samples []Sample, now time.Time) error {
+	for start := 0; start < len(samples); start += cloudWatchBatch {
+		end := min(start+cloudWatchBatch, len(samples))
+		form := url.Values{"Action": {"PutMetricData"}, "Version": {"2010-08-01"}, "Namespace": {c.Namespace}}
+		for i, s := range samples[start:end] {
+			p := "MetricData.member." + strconv.Itoa(i+1) + "."
+			form.Set(p+"MetricName", s.Name)
+			form.Set(p+"Value", strconv.FormatFloat(s.Value, 'f', -1, 64))
+			form.Set(p+"Unit", unit(s.Name))
+			form.Set(p+"Timestamp", now.UTC().Format(time.RFC3339))
+			form.Set(p+"Dimensions.member.1.Name", "Flow")
+			form.Set(p+"Dimensions.member.1.Value", s.Flow)
+		}
+		if err := c.call(ctx, form); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unit returns the CloudWatch unit of a metric.
+func unit(name string) string {
+	switch name {
+	case RunDuration:
+		return "Seconds"
+	case LastSuccessAt:
+		return "None"
+	case Bytes:
+		return "Bytes"
+	}
+	return "Count"
+}
+
+// call posts one signed PutMetricData request.
+func (c *CloudWatch) call(ctx context.Context, form url.Values) error {
+	creds, err := c.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://monitoring." + c.Region + ".amazonaws.com/"
+	}
+	payload := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	sigv4.Sign(req, payload, creds, c.Region, "monitoring", time.Now())
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to CloudWatch: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("failed to push metrics to CloudWatch: %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("failed to push metrics to CloudWatch: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,351 @@
+// pkg/model/order.go
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/money"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+Order sources.
+*/
+const (
+	SourceAPI = "api" // SP‑API Vendor Orders purchase order
+	SourceEDI = "edi" // X12 850 transaction set
+)
+
+/*
+Units of measure of ordered quantities. EDI codes are mapped to the SP‑API
+names; other codes are kept as they are.
+*/
+const (
+	Eaches = "Eaches"
+	Cases  = "Cases"
+)
+
+/*
+Order is the canonical purchase order every exporter, hook and tracker works
+on, whether the order arrived as an SP‑API purchase order or an EDI 850. Values
+the source does not carry are left empty.
+
+Fields:
+  - Source:         SourceAPI or SourceEDI.
+  - PONumber:       Purchase order number.
+  - State:          New, Acknowledged or Closed (EDI: New, Closed for a cancellation).
+  - Type:           RegularOrder, ConsignedOrder, NewProductIntroduction or RushOrder.
+  - OrderDate:      When the PO was issued.
+  - ChangedAt:      When the PO was last changed by Amazon (API only).
+  - Currency:       ISO‑4217 code of the item prices.
+  - BuyingParty/SellingParty/ShipToParty/BillToParty: Party IDs.
+  - ShipWindow/DeliveryWindow: Windows the order must ship and arrive in.
+  - Items:          Line items.
+*/
+type Order struct {
+	Source         string     `json:"source"`
+	PONumber       string     `json:"poNumber"`
+	State          string     `json:"state,omitempty"`
+	Type           string     `json:"type,omitempty"`
+	OrderDate      time.Time  `json:"orderDate"`
+	ChangedAt      *time.Time `json:"changedAt,omitempty"`
+	Currency       string     `json:"currency,omitempty"`
+	BuyingParty    string     `json:"buyingParty,omitempty"`
+	SellingParty   string     `json:"sellingParty,omitempty"`
+	ShipToParty    string     `json:"shipToParty,omitempty"`
+	BillToParty    string     `json:"billToParty,omitempty"`
+	ShipWindow     *Window    `json:"shipWindow,omitempty"`
+	DeliveryWindow *Window    `json:"deliveryWindow,omitempty"`
+	Items          []Item     `json:"items"`
+}
+
+/*
+Window is a time window; either end may be unknown (zero).
+*/
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+/*
+Item is one line of an Order.
+
+Fields:
+  - Sequence:         Line number within the PO.
+  - ASIN:             Amazon product identifier.
+  - VendorSKU:        Vendor product identifier.
+  - ExternalID:       EAN, UPC or ISBN, if the source names one.
+  - Quantity:         Ordered amount in Unit.
+  - Unit:             Eaches or Cases.
+  - UnitSize:         Eaches per case.
+  - BackOrderAllowed: Whether the line may be back-ordered.
+  - NetCost:          Net cost per unit, if known.
+  - ListPrice:        List price per unit, if known.
+*/
+type Item struct {
+	Sequence         string   `json:"sequence"`
+	ASIN             string   `json:"asin,omitempty"`
+	VendorSKU        string   `json:"vendorSku,omitempty"`
+	ExternalID       string   `json:"externalId,omitempty"`
+	Quantity         int      `json:"quantity"`
+	Unit             string   `json:"unit"`
+	UnitSize         int      `json:"unitSize,omitempty"`
+	BackOrderAllowed bool     `json:"backOrderAllowed"`
+	NetCost          *float64 `json:"netCost,omitempty"`
+	ListPrice        *float64 `json:"listPrice,omitempty"`
+}
+
+/*
+Eaches returns the ordered quantity in eaches, converting cases by the case
+size.
+*/
+func (it Item) Eaches() int {
+	if strings.EqualFold(it.Unit, Cases) && it.UnitSize > 0 {
+		return it.Quantity * it.UnitSize
+	}
+	return it.Quantity
+}
+
+/*
+Deadlines returns the known window bounds keyed like SLA rule anchors
+("shipWindowStart", "shipWindowEnd", "deliveryWindowStart", "deliveryWindowEnd").
+*/
+func (o Order) Deadlines() map[string]time.Time {
+	d := map[string]time.Time{}
+	for name, w := range map[string]*Window{"shipWindow": o.ShipWindow, "deliveryWindow": o.DeliveryWindow} {
+		if w == nil {
+			continue
+		}
+		if !w.Start.IsZero() {
+			d[name+"Start"] = w.Start
+		}
+		if !w.End.IsZero() {
+			d[name+"End"] = w.End
+		}
+	}
+	return d
+}
+
+/*
+FromPurchaseOrder maps an SP‑API purchase order. Prices are read with either
+decimal separator (see money.Parse); ones that are not numbers are dropped.
+*/
+func FromPurchaseOrder(po spapi.PurchaseOrder) Order {
+	d := po.OrderDetails
+	o := Order{
+		Source:         SourceAPI,
+		PONumber:       po.PurchaseOrderNumber,
+		State:          po.PurchaseOrderState,
+		Type:           d.PurchaseOrderType,
+		OrderDate:      d.PurchaseOrderDate,
+		ChangedAt:      d.PurchaseOrderChangedDate,
+		BuyingParty:    partyID(d.BuyingParty),
+		SellingParty:   partyID(d.SellingParty),
+		ShipToParty:    partyID(d.ShipToParty),
+		BillToParty:    partyID(d.BillToParty),
+		ShipWindow:     parseWindow(d.ShipWindow),
+		DeliveryWindow: parseWindow(d.DeliveryWindow),
+		Items:          make([]Item, 0, len(d.Items)),
+	}
+	for _, it := range d.Items {
+		item := Item{
+			Sequence:         it.ItemSequenceNumber,
+			ASIN:             it.AmazonProductIdentifier,
+			VendorSKU:        it.VendorProductIdentifier,
+			Quantity:         it.OrderedQuantity.Amount,
+			Unit:             it.OrderedQuantity.UnitOfMeasure,
+			UnitSize:         it.OrderedQuantity.UnitSize,
+			BackOrderAllowed: it.IsBackOrderAllowed,
+		}
+		if it.NetCost != nil {
+			item.NetCost = parseAmount(it.NetCost.Amount, 0)
+			if o.Currency == "" {
+				o.Currency = it.NetCost.CurrencyCode
+			}
+		}
+		if it.ListPrice != nil {
+			item.ListPrice = parseAmount(it.ListPrice.Amount, 0)
+			if o.Currency == "" {
+				o.Currency = it.ListPrice.CurrencyCode
+			}
+		}
+		o.Items = append(o.Items, item)
+	}
+	return o
+}
+
+// ediOrderTypes maps BEG02 purchase order type codes to SP‑API order types.
+var ediOrderTypes = map[string]string{
+	"SA": "RegularOrder",
+	"CN": "ConsignedOrder",
+	"NE": "NewProductIntroduction",
+	"RO": "RushOrder",
+}
+
+// ediUnits maps PO103 units of measure to SP‑API names.
+var ediUnits = map[string]string{"EA": Eaches, "CA": Cases}
+
+/*
+FromX12 maps a standalone 850 interchange (as returned by
+x12.Document.Split). It reads:
+  - BEG: purpose (01 cancellation is Closed, anything else New), type, PO number and date.
+  - CUR: currency.
+  - DTM 037/038 and 064/063: ship and delivery window (date, optional time, UTC).
+  - N1 BY/SE/ST/BT with a 92 (assigned by buyer) ID: parties.
+  - PO1: sequence, quantity, unit, net cost and the BP (ASIN), VN/VP (vendor SKU)
+    and EN/UP/IB/UK (external ID) product IDs; a following PO4 sets the case size.
+
+Quantities and prices may be written with either decimal separator and
+grouped thousands (see money.Parse); decimal is the partner's separator, or 0
+to tell them apart by their look.
+
+Returns:
+  - The order.
+  - An error if doc holds no 850 or it has no BEG segment or an invalid PO1 quantity.
+*/
+func FromX12(doc *x12.Document, decimal byte) (Order, error) {
+	st, ok := doc.Find("ST")
+	if !ok || st.Element(1) != "850" {
+		return Order{}, fmt.Errorf("interchange holds no 850 transaction set")
+	}
+	beg, ok := doc.Find("BEG")
+	if !ok {
+		return Order{}, fmt.Errorf("850 %s has no BEG segment", st.Element(2))
+	}
+	o := Order{Source: SourceEDI, PONumber: beg.Element(3), State: "New", Items: []Item{}}
+	if beg.Element(1) == "01" {
+		o.State = "Closed"
+	}
+	o.Type = beg.Element(2)
+	if t, ok := ediOrderTypes[o.Type]; ok {
+		o.Type = t
+	}
+	if t, err := time.Parse("20060102", beg.Element(5)); err == nil {
+		o.OrderDate = t
+	}
+
+	var item *Item
+	for _, seg := range doc.Segments {
+		switch seg.ID {
+		case "CUR":
+			o.Currency = seg.Element(2)
+		case "DTM":
+			t, ok := ediTime(seg.Element(2), seg.Element(3))
+			if !ok {
+				continue
+			}
+			switch seg.Element(1) {
+			case "037":
+				o.ShipWindow = window(o.ShipWindow)
+				o.ShipWindow.Start = t
+			case "038":
+				o.ShipWindow = window(o.ShipWindow)
+				o.ShipWindow.End = t
+			case "064":
+				o.DeliveryWindow = window(o.DeliveryWindow)
+				o.DeliveryWindow.Start = t
+			case "063":
+				o.DeliveryWindow = window(o.DeliveryWindow)
+				o.DeliveryWindow.End = t
+			}
+		case "N1":
+			if seg.Element(3) != "92" {
+				continue
+			}
+			switch seg.Element(1) {
+			case "BY":
+				o.BuyingParty = seg.Element(4)
+			case "SE":
+				o.SellingParty = seg.Element(4)
+			case "ST":
+				o.ShipToParty = seg.Element(4)
+			case "BT":
+				o.BillToParty = seg.Element(4)
+			}
+		case "PO1":
+			qty, err := money.ParseQuantity(seg.Element(2), decimal)
+			if err != nil {
+				return Order{}, fmt.Errorf("850 %s: PO1 %s has an invalid quantity %q", st.Element(2), seg.Element(1), seg.Element(2))
+			}
+			o.Items = append(o.Items, Item{Sequence: seg.Element(1), Quantity: qty, Unit: seg.Element(3), NetCost: parseAmount(seg.Element(4), decimal)})
+			item = &o.Items[len(o.Items)-1]
+			if u, ok := ediUnits[item.Unit]; ok {
+				item.Unit = u
+			}
+			for i := 6; i+1 <= len(seg.Elements); i += 2 {
+				switch id := seg.Element(i + 1); seg.Element(i) {
+				case "BP":
+					item.ASIN = id
+				case "VN", "VP":
+					item.VendorSKU = id
+				case "EN", "UP", "IB", "UK":
+					item.ExternalID = id
+				}
+			}
+		case "PO4":
+			if item != nil {
+				item.UnitSize, _ = strconv.Atoi(seg.Element(1))
+			}
+		}
+	}
+	return o, nil
+}
+
+// window returns w, or a new Window if w is nil.
+func window(w *Window) *Window {
+	if w == nil {
+		return &Window{}
+	}
+	return w
+}
+
+// ediTime parses a CCYYMMDD date and an optional HHMM time as UTC.
+func ediTime(date, clock string) (time.Time, bool) {
+	if clock != "" {
+		if t, err := time.Parse("200601021504", date+clock[:min(len(clock), 4)]); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.Parse("20060102", date)
+	return t, err == nil
+}
+
+// parseWindow parses an ISO‑8601 "start--end" window, or returns nil.
+func parseWindow(s string) *Window {
+	start, end, ok := strings.Cut(s, "--")
+	if !ok {
+		return nil
+	}
+	var w Window
+	if t, err := time.Parse(time.RFC3339, start); err == nil {
+		w.Start = t
+	}
+	if t, err := time.Parse(time.RFC3339, end); err == nil {
+		w.End = t
+	}
+	if w.Start.IsZero() && w.End.IsZero() {
+		return nil
+	}
+	return &w
+}
+
+// parseAmount parses a decimal amount, or returns nil if s is not one.
+func parseAmount(s string, decimal byte) *float64 {
+	v, err := money.Parse(s, decimal)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// partyID returns the ID of p, or "" if there is none.
+func partyID(p *spapi.Party) string {
+	if p == nil {
+		return ""
+	}
+	return p.PartyID
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+const sample850 = "ISA*00*          *00*          *ZZ*AMAZON         *ZZ*VENDOR         *240102*1200*U*00401*000000101*0*P*>~\n" +
+	"GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" +
+	"ST*850*0001~\n" +
+	"BEG*00*RO*PO12345**20240102~\n" +
+	"CUR*BY*USD~\n" +
+	"DTM*064*20240110~\n" +
+	"DTM*063*20240115*1700~\n" +
+	"N1*ST**92*ABCD~\n" +
+	"N1*BY**92*AMZN~\n" +
+	"PO1*1*2*CA*39.00**BP*B0ASIN1*VN*SKU-1~\n" +
+	"PO4*6~\n" +
+	"PO1*2*10*EA*9.99**UP*012345678905~\n" +
+	"CTT*2~\n" +
+	"SE*13*0001~\n" +
+	"GE*1*101~\n" +
+	"IEA*1*000000101~\n"
+
+// TestFromX12 verifies the header, parties, windows and lines of an 850.
+func TestFromX12(t *testing.T) {
+	doc, err := x12.Parse([]byte(sample850))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	o, err := FromX12(doc, 0)
+	if err != nil {
+		t.Fatalf("FromX12() returned %v", err)
+	}
+	if o.Source != SourceEDI || o.PONumber != "PO12345" || o.State != "New" || o.Type != "RushOrder" || o.Currency != "USD" {
+		t.Errorf("header = %+v; expected a new USD rush order PO12345 from EDI", o)
+	}
+	if !o.OrderDate.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) || o.ShipToParty != "ABCD" || o.BuyingParty != "AMZN" {
+		t.Errorf("date and parties = %v, %q, %q", o.OrderDate, o.ShipToParty, o.BuyingParty)
+	}
+	d := o.Deadlines()
+	if len(d) != 2 || !d["deliveryWindowEnd"].Equal(time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC)) || o.ShipWindow != nil {
+		t.Errorf("Deadlines() = %v; expected only the delivery window", d)
+	}
+	if len(o.Items) != 2 {
+		t.Fatalf("items = %+v; expected 2", o.Items)
+	}
+	first, second := o.Items[0], o.Items[1]
+	if first.ASIN != "B0ASIN1" || first.VendorSKU != "SKU-1" || first.Unit != Cases || first.Eaches() != 12 || *first.NetCost != 39 {
+		t.Errorf("first item = %+v; expected 2 cases of 6 of B0ASIN1", first)
+	}
+	if second.ExternalID != "012345678905" || second.Unit != Eaches || second.Eaches() != 10 || second.UnitSize != 0 {
+		t.Errorf("second item = %+v; expected 10 eaches of a UPC", second)
+	}
+
+	doc.Segments[2].Elements[0] = "855"
+	if _, err := FromX12(doc, 0); err == nil {
+		t.Errorf("FromX12() accepted a set that is not an 850")
+	}
+}
+
+// TestFromX12Decimal verifies costs and quantities of partners writing comma
+// decimals.
+func TestFromX12Decimal(t *testing.T) {
+	eu := strings.NewReplacer("CUR*BY*USD", "CUR*BY*EUR", "PO1*1*2*CA*39.00", "PO1*1*1.200*CA*1.039,50", "PO1*2*10*EA*9.99", "PO1*2*10*EA*9,99").Replace(sample850)
+	doc, err := x12.Parse([]byte(eu))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	o, err := FromX12(doc, ',')
+	if err != nil {
+		t.Fatalf("FromX12() returned %v", err)
+	}
+	if o.Currency != "EUR" || o.Items[0].Quantity != 1200 || *o.Items[0].NetCost != 1039.5 || *o.Items[1].NetCost != 9.99 {
+		t.Errorf("order = %+v; expected 1200 cases at 1039.50 EUR and 9.99 EUR", o)
+	}
+	if _, err := FromX12(doc, '.'); err == nil {
+		t.Errorf("FromX12() read %q with a dot decimal separator", "1.039,50")
+	}
+}
+
+// TestFromPurchaseOrder verifies an SP‑API order maps to the same structure.
+func TestFromPurchaseOrder(t *testing.T) {
+	o := FromPurchaseOrder(spapi.PurchaseOrder{PurchaseOrderNumber: "PO1", PurchaseOrderState: "Acknowledged", OrderDetails: spapi.OrderDetails{
+		PurchaseOrderDate: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		PurchaseOrderType: "RegularOrder",
+		ShipToParty:       &spapi.Party{PartyID: "ABCD"},
+		ShipWindow:        "2024-05-03T07:00:00Z--2024-05-06T07:00:00Z",
+		Items: []spapi.OrderItem{
+			{ItemSequenceNumber: "1", AmazonProductIdentifier: "B0ASIN1", OrderedQuantity: spapi.ItemQuantity{Amount: 2, UnitOfMeasure: "Cases", UnitSize: 6}, NetCost: &spapi.Money{CurrencyCode: "EUR", Amount: "4.50"}, ListPrice: &spapi.Money{CurrencyCode: "EUR", Amount: "n/a"}},
+		},
+	}})
+	if o.Source != SourceAPI || o.PONumber != "PO1" || o.State != "Acknowledged" || o.Currency != "EUR" || o.ShipToParty != "ABCD" || o.BuyingParty != "" {
+		t.Errorf("header = %+v", o)
+	}
+	if d := o.Deadlines(); len(d) != 2 || !d["shipWindowStart"].Equal(time.Date(2024, 5, 3, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("Deadlines() = %v; expected the ship window", d)
+	}
+	if it := o.Items[0]; it.Eaches() != 12 || it.NetCost == nil || *it.NetCost != 4.5 || it.ListPrice != nil {
+		t.Errorf("item = %+v; expected 12 eaches at 4.50 without a list price", it)
+	}
+}
@@ -0,0 +1,166 @@
+// pkg/money/money.go
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Parse reads a decimal amount as trading partners write it: with "." or ","
+as the decimal separator, the other one (or spaces and apostrophes) grouping
+thousands, and currency symbols or codes around it, e.g. "1.234,56 EUR",
+"£1,234.56" or "12,50".
+
+Parameters:
+  - s:       The amount.
+  - decimal: The partner's decimal separator ('.' or ','), or 0 to detect it:
+    of both separators the last one is the decimal separator, a separator
+    that occurs more than once groups thousands, and a single one is the
+    decimal separator.
+
+Returns:
+  - The amount.
+  - An error if s is not an amount in that convention.
+*/
+func Parse(s string, decimal byte) (float64, error) {
+	digits, sep, err := normalize(s)
+	if err != nil {
+		return 0, err
+	}
+	if decimal == 0 {
+		decimal = detect(digits, sep, false)
+	}
+	return parse(s, digits, decimal)
+}
+
+/*
+ParseQuantity reads a whole quantity such as "1.200", "1,200" or "12,0".
+Unlike Parse, a single separator followed by exactly three digits groups
+thousands when the convention is detected, as quantities are whole numbers.
+
+Returns:
+  - The quantity.
+  - An error if s is not a whole number.
+*/
+func ParseQuantity(s string, decimal byte) (int, error) {
+	digits, sep, err := normalize(s)
+	if err != nil {
+		return 0, err
+	}
+	if decimal == 0 {
+		decimal = detect(digits, sep, true)
+	}
+	v, err := parse(s, digits, decimal)
+	if err != nil {
+		return 0, err
+	}
+	if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+		return 0, fmt.Errorf("%q is not a whole number", s)
+	}
+	return int(v), nil
+}
+
+// normalize strips currency symbols, codes and group spacing from s and
+// reports the separators it holds.
+func normalize(s string) (string, string, error) {
+	trimmed := strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '-' && r != '+' && r != '.' && r != ','
+	})
+	var b strings.Builder
+	var seps []byte
+	for _, r := range trimmed {
+		switch {
+		case r >= '0' && r <= '9', r == '-' && b.Len() == 0, r == '+' && b.Len() == 0:
+			b.WriteRune(r)
+		case r == '.' || r == ',':
+			b.WriteRune(r)
+			seps = append(seps, byte(r))
+		case unicode.IsSpace(r), r == '\'', r == '’':
+			// Group spacing, e.g. "1 234,56" or "1'234.56".
+		default:
+			return "", "", fmt.Errorf("%q is not an amount", s)
+		}
+	}
+	if strings.Trim(b.String(), "+-.,") == "" {
+		return "", "", fmt.Errorf("%q is not an amount", s)
+	}
+	return b.String(), string(seps), nil
+}
+
+// detect returns the decimal separator of a normalized amount.
+func detect(digits, seps string, whole bool) byte {
+	switch {
+	case seps == "":
+		return '.'
+	case strings.Contains(seps, ".") && strings.Contains(seps, ","):
+		return seps[len(seps)-1]
+	case len(seps) > 1:
+		return other(seps[0])
+	case whole && len(digits)-strings.IndexByte(digits, seps[0]) == 4:
+		return other(seps[0])
+	default:
+		return seps[0]
+	}
+}
+
+// other returns the separator that is not sep.
+func other(sep byte) byte {
+	if sep == ',' {
+		return '.'
+	}
+	return ','
+}
+
+// parse converts a normalized amount with the given decimal separator.
+func parse(s, digits string, decimal byte) (float64, error) {
+	if decimal != '.' && decimal != ',' {
+		return 0, fmt.Errorf("invalid decimal separator %q", decimal)
+	}
+	// Grouping separators only come before the decimal separator.
+	if d := strings.IndexByte(digits, decimal); strings.Count(digits, string(decimal)) > 1 || d >= 0 && strings.LastIndexByte(digits, other(decimal)) > d {
+		return 0, fmt.Errorf("%q is not an amount", s)
+	}
+	digits = strings.ReplaceAll(digits, string(other(decimal)), "")
+	v, err := strconv.ParseFloat(strings.Replace(digits, string(decimal), ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount", s)
+	}
+	return v, nil
+}
+
+/*
+Format writes an amount with the given decimal separator and no grouping.
+
+Parameters:
+  - v:       The amount.
+  - places:  Digits after the separator, or -1 for as many as v needs.
+  - decimal: '.' or ',' (0 means '.').
+*/
+func Format(v float64, places int, decimal byte) string {
+	s := strconv.FormatFloat(v, 'f', places, 64)
+	if decimal == ',' {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// minorUnits lists the ISO-4217 currencies that do not have two decimals.
+var minorUnits = map[string]int{
+	"BHD": 3, "CLP": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "LYD": 3, "OMR": 3, "TND": 3, "UGX": 0, "VND": 0, "XAF": 0, "XOF": 0,
+}
+
+/*
+Places returns the number of decimals amounts in an ISO-4217 currency are
+written with: 0 for JPY, 3 for KWD, 2 for most others and for unknown codes.
+*/
+func Places(currency string) int {
+	if n, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return n
+	}
+	return 2
+}
@@ -0,0 +1,72 @@
+package money
+
+import "testing"
+
+// TestParse verifies amounts in both conventions, with grouping and currency
+// markers, are read with a given or detected decimal separator.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		decimal byte
+		want    float64
+		ok      bool
+	}{
+		{"12.50", 0, 12.5, true},
+		{"12,50", 0, 12.5, true},
+		{"1.234,56 EUR", 0, 1234.56, true},
+		{"£1,234.56", 0, 1234.56, true},
+		{"1 234,56", 0, 1234.56, true},
+		{"1'234.56", 0, 1234.56, true},
+		{"1.234.567", 0, 1234567, true},
+		{"-4,5", 0, -4.5, true},
+		{"1,234", ',', 1.234, true},
+		{"1,234", '.', 1234, true},
+		{"1.234,5", '.', 0, false},
+		{"12x5", 0, 0, false},
+		{"EUR", 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.decimal)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q, %q) = %v, %v; expected %v", tt.in, tt.decimal, got, err, tt.want)
+		}
+	}
+}
+
+// TestParseQuantity verifies a lone separator before three digits groups
+// thousands and fractions are rejected.
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in      string
+		decimal byte
+		want    int
+		ok      bool
+	}{
+		{"12", 0, 12, true},
+		{"1.200", 0, 1200, true},
+		{"1,200", 0, 1200, true},
+		{"12,0", 0, 12, true},
+		{"1.200", ',', 1200, true},
+		{"1.200", '.', 0, false},
+		{"1,5", 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.in, tt.decimal)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseQuantity(%q, %q) = %v, %v; expected %v", tt.in, tt.decimal, got, err, tt.want)
+		}
+	}
+}
+
+// TestFormat verifies the separator and currency decimals.
+func TestFormat(t *testing.T) {
+	if got := Format(1234.5, Places("EUR"), ','); got != "1234,50" {
+		t.Errorf("Format(EUR, ',') = %q", got)
+	}
+	if got := Format(1234.5, Places("JPY"), '.'); got != "1234" {
+		t.Errorf("Format(JPY) = %q", got)
+	}
+	if got := Format(4.125, -1, 0); got != "4.125" {
+		t.Errorf("Format(-1) = %q", got)
+	}
+}
@@ -0,0 +1,426 @@
+// pkg/outbox/outbox.go
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Delivery states.
+*/
+const (
+	Pending   = "pending"   // recorded, not yet confirmed by the target
+	Delivered = "delivered" // the exporter reported success
+	Failed    = "failed"    // given up: a permanent error or too many attempts
+)
+
+// DeliveryField is the hooks.Event field carrying the delivery ID to the exporter.
+const DeliveryField = "deliveryId"
+
+/*
+Entry is one document queued for one exporter.
+
+Fields:
+  - Exporter:    Name of the exporter (exports[].name).
+  - Event:       The event the document was generated with; redeliveries reuse it.
+  - Status:      Pending, Delivered or Failed.
+  - CreatedAt:   When the document was first handed to the exporter.
+  - Attempts:    Delivery attempts so far (each may retry per the exporter's policy).
+  - LastAttempt: When the last attempt started.
+  - LastError:   Why the last attempt failed.
+  - DeliveredAt: When the target confirmed the document.
+*/
+type Entry struct {
+	Exporter    string      `json:"exporter"`
+	Event       hooks.Event `json:"event"`
+	Status      string      `json:"status"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Attempts    int         `json:"attempts"`
+	LastAttempt time.Time   `json:"lastAttempt"`
+	LastError   string      `json:"lastError,omitempty"`
+	DeliveredAt *time.Time  `json:"deliveredAt,omitempty"`
+}
+
+/*
+Outbox makes exports exactly-once from the target's point of view: every
+document is recorded before an exporter sends it and only marked delivered
+once the exporter reports success. A document already delivered to an
+exporter is not sent again, and one whose delivery failed or was cut short
+(crash, shutdown) is sent again by Sweep. Each delivery has a stable ID,
+passed to the exporter as the event field "deliveryId", so a target that
+deduplicates by it never receives a document twice even if the importer
+stops between sending and recording.
+
+Entries live in one JSON file; delivered and failed entries are dropped once
+older than the retention.
+*/
+type Outbox struct {
+	mu          sync.Mutex
+	path        string
+	retention   time.Duration
+	maxAttempts int
+	exporters   map[string]hooks.Hook
+	perms       *utils.Permissions
+	logger      *utils.Logger
+}
+
+/*
+Open returns the Outbox backed by the file at path.
+
+Parameters:
+  - path:        The outbox file; created with the first delivery.
+  - retention:   How long delivered and failed entries are kept (0 keeps them forever).
+  - maxAttempts: Attempts after which a delivery is given up (0 means no limit).
+  - perms:       Mode and ownership of the file; nil uses utils.DefaultPermissions.
+  - logger:      Receives skipped deliveries; nil discards them.
+*/
+func Open(path string, retention time.Duration, maxAttempts int, perms *utils.Permissions, logger *utils.Logger) *Outbox {
+	return &Outbox{path: path, retention: retention, maxAttempts: maxAttempts, exporters: map[string]hooks.Hook{}, perms: perms, logger: logger}
+}
+
+/*
+Path returns the file backing the outbox.
+*/
+func (o *Outbox) Path() string {
+	return o.path
+}
+
+/*
+ID returns the delivery ID of a document for an exporter, derived from the
+exporter name, document type and content, so the same document always gets
+the same ID and a changed version of it a new one.
+
+Returns:
+  - The ID.
+  - An error if the document cannot be read to hash it.
+*/
+func ID(exporter string, e hooks.Event) (string, error) {
+	sum := e.SHA256
+	if sum == "" {
+		data, err := codec.ReadFile(e.Path)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.Sum256(data)
+		sum = hex.EncodeToString(h[:])
+	}
+	h := sha256.Sum256([]byte(exporter + "\x00" + e.Type + "\x00" + sum))
+	return hex.EncodeToString(h[:16]), nil
+}
+
+/*
+Wrap routes the exporters hs through the outbox and returns the wrapped hooks
+to install. It replaces the exporters of an earlier Wrap, so Sweep redelivers
+with the configuration in effect.
+*/
+func (o *Outbox) Wrap(hs ...hooks.Hook) []hooks.Hook {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.exporters = make(map[string]hooks.Hook, len(hs))
+	out := make([]hooks.Hook, len(hs))
+	for i, h := range hs {
+		o.exporters[h.Name()] = h
+		out[i] = &hook{Hook: h, box: o}
+	}
+	return out
+}
+
+/*
+Enqueue records documents for exporter as pending without sending them, so
+they survive a restart from the moment Enqueue returns; the next Sweep
+delivers them through the exporter of the last Wrap. All events are recorded
+in one write, so either all or none of them are queued.
+
+Returns:
+  - The delivery IDs, in the order of events.
+  - An error if a document cannot be read or the outbox cannot be written.
+*/
+func (o *Outbox) Enqueue(exporter string, events ...hooks.Event) ([]string, error) {
+	ids := make([]string, len(events))
+	for i, e := range events {
+		id, err := ID(exporter, e)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	all, err := o.read()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for i, id := range ids {
+		if _, ok := all[id]; !ok {
+			all[id] = Entry{Exporter: exporter, Event: events[i], Status: Pending, CreatedAt: now}
+		}
+	}
+	if err := o.write(all); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+/*
+Selective is implemented by exporters that only send some of the events they
+handle (e.g. only orders), so the outbox does not record the others.
+*/
+type Selective interface {
+	Wants(e hooks.Event) bool
+}
+
+// hook is an exporter whose deliveries go through the outbox.
+type hook struct {
+	hooks.Hook
+	box *Outbox
+}
+
+// Run records the document and delivers it unless it was delivered before.
+func (h *hook) Run(ctx context.Context, e hooks.Event) error {
+	if s, ok := h.Hook.(Selective); ok && !s.Wants(e) {
+		return nil
+	}
+	id, err := ID(h.Name(), e)
+	if err != nil {
+		return err
+	}
+	entry, fresh, err := h.box.claim(id, h.Name(), e, time.Time{})
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		if entry.Status == Delivered {
+			h.box.logger.LogContext(ctx, utils.LevelWarn, "Already exported, skipping: ", fmt.Sprintf("%s to %s", e.Path, h.Name()))
+		}
+		return nil
+	}
+	return h.box.deliver(ctx, h.Hook, id, entry)
+}
+
+/*
+claim records a delivery of e to exporter as started, creating its entry if
+needed. It reports fresh=false for a document that was already delivered or
+given up, or, with a non-zero cutoff, that was last attempted after cutoff
+(by another sweep, or a run that may still be sending it).
+*/
+func (o *Outbox) claim(id, exporter string, e hooks.Event, cutoff time.Time) (Entry, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	all, err := o.read()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	now := time.Now().UTC()
+	entry, ok := all[id]
+	switch {
+	case !ok:
+		entry = Entry{Exporter: exporter, Event: e, Status: Pending, CreatedAt: now}
+	case entry.Status != Pending, !cutoff.IsZero() && entry.LastAttempt.After(cutoff):
+		return entry, false, nil
+	}
+	entry.Attempts++
+	entry.LastAttempt = now
+	all[id] = entry
+	if err := o.write(all); err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// deliver runs the exporter for a claimed entry and records the outcome.
+func (o *Outbox) deliver(ctx context.Context, exporter hooks.Hook, id string, entry Entry) error {
+	e := entry.Event
+	fields := make(map[string]string, len(e.Fields)+1)
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	fields[DeliveryField] = id
+	e.Fields = fields
+
+	runErr := exporter.Run(ctx, e)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	all, err := o.read()
+	if err != nil {
+		return errors.Join(runErr, err)
+	}
+	now := time.Now().UTC()
+	entry = all[id]
+	switch {
+	case runErr == nil:
+		entry.Status = Delivered
+		entry.DeliveredAt = &now
+		entry.LastError = ""
+	case errors.Is(runErr, context.Canceled):
+		// Shutting down: leave the entry pending for the next sweep.
+		entry.LastError = runErr.Error()
+	case utils.ClassifyError(runErr) == utils.ClassPermanent || (o.maxAttempts > 0 && entry.Attempts >= o.maxAttempts):
+		entry.Status = Failed
+		entry.LastError = runErr.Error()
+	default:
+		entry.LastError = runErr.Error()
+	}
+	all[id] = entry
+	o.prune(all, now)
+	return errors.Join(runErr, o.write(all))
+}
+
+/*
+Sweep redelivers every pending entry whose last attempt started at least
+olderThan ago, e.g. after a crash or an ERP outage, using the exporters of
+the last Wrap. Entries of exporters no longer configured are left alone;
+entries whose document was deleted are given up.
+
+Returns:
+  - How many entries were delivered.
+  - The errors of the deliveries that failed again.
+*/
+func (o *Outbox) Sweep(ctx context.Context, olderThan time.Duration) (int, error) {
+	o.mu.Lock()
+	all, err := o.read()
+	exporters := o.exporters
+	o.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	ids := make([]string, 0, len(all))
+	for id, entry := range all {
+		if entry.Status == Pending && exporters[entry.Exporter] != nil && !entry.LastAttempt.After(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	// Oldest first, so documents reach the target in the order they were generated.
+	sort.Slice(ids, func(i, j int) bool { return all[ids[i]].CreatedAt.Before(all[ids[j]].CreatedAt) })
+
+	delivered := 0
+	var errs []error
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		entry := all[id]
+		if _, err := os.Stat(entry.Event.Path); errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, o.giveUp(id, "document no longer exists"))
+			continue
+		}
+		entry, fresh, err := o.claim(id, entry.Exporter, entry.Event, cutoff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !fresh {
+			continue
+		}
+		if err := o.deliver(ctx, exporters[entry.Exporter], id, entry); err != nil {
+			errs = append(errs, fmt.Errorf("%s to %s: %w", entry.Event.Path, entry.Exporter, err))
+			continue
+		}
+		delivered++
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// giveUp marks the entry id failed with reason.
+func (o *Outbox) giveUp(id, reason string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	all, err := o.read()
+	if err != nil {
+		return err
+	}
+	entry, ok := all[id]
+	if !ok {
+		return nil
+	}
+	entry.Status = Failed
+	entry.LastError = reason
+	all[id] = entry
+	return o.write(all)
+}
+
+/*
+Entries returns every entry by delivery ID.
+*/
+func (o *Outbox) Entries() (map[string]Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.read()
+}
+
+// prune drops delivered and failed entries older than the retention.
+func (o *Outbox) prune(all map[string]Entry, now time.Time) {
+	if o.retention <= 0 {
+		return
+	}
+	for id, entry := range all {
+		if entry.Status != Pending && now.Sub(entry.LastAttempt) > o.retention {
+			delete(all, id)
+		}
+	}
+}
+
+// read loads the outbox file; a missing file is an empty outbox.
+func (o *Outbox) read() (map[string]Entry, error) {
+	all := make(map[string]Entry)
+	data, err := codec.ReadFile(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid outbox file %s: %w", o.path, err)
+	}
+	return all, nil
+}
+
+// write replaces the outbox file via a temporary file and rename.
+func (o *Outbox) write(all map[string]Entry) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox: %w", err)
+	}
+	if err := o.perms.MkdirAll(filepath.Dir(o.path)); err != nil {
+		return fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".outbox-*")
+	if err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := o.perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	return nil
+}
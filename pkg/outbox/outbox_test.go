@@ -0,0 +1,140 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/hooks"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// exporter is a hook recording the delivery IDs it was run with.
+type exporter struct {
+	fail []error
+	ids  []string
+}
+
+func (x *exporter) Name() string              { return "erp" }
+func (x *exporter) Handles(event string) bool { return event == hooks.Generated }
+func (x *exporter) Blocking() bool            { return false }
+func (x *exporter) Run(ctx context.Context, e hooks.Event) error {
+	x.ids = append(x.ids, e.Fields[DeliveryField])
+	if len(x.fail) > 0 {
+		err := x.fail[0]
+		x.fail = x.fail[1:]
+		return err
+	}
+	return nil
+}
+
+// TestOutboxDeliversOnce verifies a failed delivery stays pending until a
+// sweep gets it through, after which the document is not sent again.
+func TestOutboxDeliversOnce(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "order.json")
+	os.WriteFile(doc, []byte(`{"purchaseOrderNumber":"PO1"}`), 0o644)
+	box := Open(filepath.Join(dir, "outbox.json"), 0, 0, nil, nil)
+	x := &exporter{fail: []error{errors.New("503 from ERP")}}
+	h := box.Wrap(x)[0]
+	e := hooks.Event{Event: hooks.Generated, Path: doc, Type: "order"}
+
+	if err := h.Run(context.Background(), e); err == nil {
+		t.Fatal("Run() succeeded; expected the exporter's failure")
+	}
+	if n, err := box.Sweep(context.Background(), 0); n != 1 || err != nil {
+		t.Fatalf("Sweep() = %d, %v; expected one redelivery", n, err)
+	}
+	if err := h.Run(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := box.Sweep(context.Background(), 0); n != 0 {
+		t.Errorf("Sweep() redelivered %d delivered documents", n)
+	}
+	if len(x.ids) != 2 || x.ids[0] == "" || x.ids[0] != x.ids[1] {
+		t.Errorf("delivery IDs = %q; expected two attempts with one stable ID", x.ids)
+	}
+	entries, _ := box.Entries()
+	if entry := entries[x.ids[0]]; entry.Status != Delivered || entry.Attempts != 2 || entry.DeliveredAt == nil {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	// A changed document is a new delivery.
+	os.WriteFile(doc, []byte(`{"purchaseOrderNumber":"PO1","changed":true}`), 0o644)
+	if err := h.Run(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(x.ids) != 3 || x.ids[2] == x.ids[0] {
+		t.Errorf("delivery IDs = %q; expected a new ID for the changed document", x.ids)
+	}
+}
+
+// TestOutboxGivesUp verifies permanent failures and exhausted attempts are not swept again.
+func TestOutboxGivesUp(t *testing.T) {
+	dir := t.TempDir()
+	box := Open(filepath.Join(dir, "outbox.json"), 0, 2, nil, nil)
+	x := &exporter{fail: []error{
+		utils.Permanent(errors.New("no ERP item for SKU")),
+		errors.New("timeout"), errors.New("timeout"),
+	}}
+	h := box.Wrap(x)[0]
+	for i, content := range []string{"a", "b"} {
+		path := filepath.Join(dir, content+".json")
+		os.WriteFile(path, []byte(content), 0o644)
+		if err := h.Run(context.Background(), hooks.Event{Event: hooks.Generated, Path: path, Type: "order"}); err == nil {
+			t.Fatalf("document %d: Run() succeeded", i)
+		}
+	}
+	// b gets its second and last attempt.
+	if n, err := box.Sweep(context.Background(), 0); n != 0 || err == nil {
+		t.Fatalf("Sweep() = %d, %v; expected the second timeout", n, err)
+	}
+	if n, err := box.Sweep(context.Background(), 0); n != 0 || err != nil {
+		t.Errorf("Sweep() = %d, %v; expected nothing left to send", n, err)
+	}
+	entries, _ := box.Entries()
+	for id, entry := range entries {
+		if entry.Status != Failed {
+			t.Errorf("entry %s = %+v; expected failed", id, entry)
+		}
+	}
+	if len(x.ids) != 3 {
+		t.Errorf("exporter ran %d times; expected 3", len(x.ids))
+	}
+}
+
+// TestOutboxEnqueue verifies that enqueued documents are only sent by a
+// sweep, survive reopening the outbox and are not queued twice.
+func TestOutboxEnqueue(t *testing.T) {
+	dir := t.TempDir()
+	var events []hooks.Event
+	for _, name := range []string{"s1", "s2"} {
+		path := filepath.Join(dir, name+".json")
+		os.WriteFile(path, []byte(name), 0o644)
+		events = append(events, hooks.Event{Path: path, Type: "shipment"})
+	}
+	x := &exporter{}
+	box := Open(filepath.Join(dir, "outbox.json"), 0, 0, nil, nil)
+	box.Wrap(x)
+	ids, err := box.Enqueue("erp", events...)
+	if err != nil || len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("Enqueue() = %q, %v; expected two IDs", ids, err)
+	}
+	if len(x.ids) != 0 {
+		t.Fatalf("Enqueue() sent %q", x.ids)
+	}
+	if again, _ := box.Enqueue("erp", events[0]); len(again) != 1 || again[0] != ids[0] {
+		t.Errorf("Enqueue() again = %q; expected %s", again, ids[0])
+	}
+
+	reopened := Open(filepath.Join(dir, "outbox.json"), 0, 0, nil, nil)
+	reopened.Wrap(x)
+	if n, err := reopened.Sweep(context.Background(), 0); n != 2 || err != nil {
+		t.Fatalf("Sweep() = %d, %v; expected both documents", n, err)
+	}
+	if len(x.ids) != 2 || x.ids[0] == x.ids[1] || (x.ids[0] != ids[0] && x.ids[0] != ids[1]) {
+		t.Errorf("delivered %q; expected %q", x.ids, ids)
+	}
+}
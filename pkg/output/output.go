@@ -0,0 +1,337 @@
+// pkg/output/output.go
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+Format selects how command results are written to stdout.
+*/
+type Format string
+
+const (
+	// Text leaves stdout to the human-readable log (the default).
+	Text Format = "text"
+	// JSON writes machine-readable results to stdout; logs go to stderr.
+	JSON Format = "json"
+)
+
+// current is the active format; resultWriter is where results are written.
+var (
+	current                = Text
+	resultWriter io.Writer = os.Stdout
+	writeMu      sync.Mutex
+)
+
+/*
+ParseFormat validates a --output flag value.
+*/
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case Text, JSON:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (expected text or json)", s)
+	}
+}
+
+/*
+SetFormat selects the output format for the process.
+*/
+func SetFormat(f Format) {
+	current = f
+}
+
+/*
+IsJSON reports whether machine-readable output is enabled.
+*/
+func IsJSON() bool {
+	return current == JSON
+}
+
+/*
+Emit writes v to stdout as a single line of JSON when the JSON format is
+active, and does nothing in text mode (where the log already tells the story).
+Each call produces one line, so repeated emits form an NDJSON stream.
+*/
+func Emit(v interface{}) error {
+	if current != JSON {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	_, err = fmt.Fprintln(resultWriter, string(data))
+	return err
+}
+
+/*
+FlowResult is the machine-readable outcome of one flow run.
+
+Fields:
+  - Flow:       Flow name ("edi", "api").
+  - StartedAt:  When the run began.
+  - FinishedAt: When the run ended.
+  - Success:    Whether the run completed without error.
+  - Files:      Local paths of files downloaded or written.
+  - PONumbers:  Purchase order numbers seen during the run.
+  - Manifest:   Path of the integrity manifest written for the run, if any.
+  - Error:      The failure message, if any.
+  - Artifacts:  Downloaded artifacts listed in the manifest.
+*/
+type FlowResult struct {
+	Flow       string     `json:"flow"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt time.Time  `json:"finishedAt"`
+	Success    bool       `json:"success"`
+	Files      []string   `json:"files,omitempty"`
+	PONumbers  []string   `json:"poNumbers,omitempty"`
+	Manifest   string     `json:"manifest,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Artifacts  []Artifact `json:"-"`
+
+	mu sync.Mutex
+}
+
+/*
+Artifact is one downloaded file as listed in a run's integrity manifest.
+
+Fields:
+  - Path:         Local path, relative to the storage directory.
+  - RemotePath:   Where it came from (SFTP path or SP‑API document).
+  - Size:         Size in bytes.
+  - SHA256:       Hex SHA‑256 of the content.
+  - DownloadedAt: When the download finished.
+*/
+type Artifact struct {
+	Path         string    `json:"path"`
+	RemotePath   string    `json:"remotePath"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+/*
+Manifest lists every artifact a run downloaded so downstream consumers can
+verify the batch is complete and unmodified.
+
+Fields:
+  - Flow:        Flow that produced the batch.
+  - StartedAt:   When the run began.
+  - FinishedAt:  When the run ended.
+  - Success:     Whether the run completed without error; failed runs still list what they downloaded.
+  - Count:       Number of files.
+  - TotalBytes:  Sum of their sizes.
+  - Files:       The artifacts, in download order.
+*/
+type Manifest struct {
+	Flow       string     `json:"flow"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt time.Time  `json:"finishedAt"`
+	Success    bool       `json:"success"`
+	Count      int        `json:"count"`
+	TotalBytes int64      `json:"totalBytes"`
+	Files      []Artifact `json:"files"`
+}
+
+// flowResultKey is the context key for the FlowResult of the current run.
+type flowResultKey struct{}
+
+/*
+WithFlowResult returns a context carrying r, so code deep in a flow can record
+what it did without threading the result through every call.
+*/
+func WithFlowResult(ctx context.Context, r *FlowResult) context.Context {
+	return context.WithValue(ctx, flowResultKey{}, r)
+}
+
+/*
+FromContext returns the FlowResult in ctx, or nil. All recording methods are
+safe to call on a nil *FlowResult.
+*/
+func FromContext(ctx context.Context) *FlowResult {
+	r, _ := ctx.Value(flowResultKey{}).(*FlowResult)
+	return r
+}
+
+/*
+AddFiles records file paths produced by the run.
+*/
+func (r *FlowResult) AddFiles(files ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, files...)
+}
+
+/*
+AddPONumbers records purchase order numbers handled by the run.
+*/
+func (r *FlowResult) AddPONumbers(pos ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PONumbers = append(r.PONumbers, pos...)
+}
+
+/*
+AddArtifacts records downloaded artifacts for the run's manifest.
+*/
+func (r *FlowResult) AddArtifacts(artifacts ...Artifact) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Artifacts = append(r.Artifacts, artifacts...)
+}
+
+/*
+BuildManifest returns the manifest of a finished run, or nil if it downloaded
+nothing.
+*/
+func (r *FlowResult) BuildManifest() *Manifest {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.Artifacts) == 0 {
+		return nil
+	}
+	m := &Manifest{
+		Flow:       r.Flow,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		Success:    r.Success,
+		Count:      len(r.Artifacts),
+		Files:      append([]Artifact(nil), r.Artifacts...),
+	}
+	for _, a := range r.Artifacts {
+		m.TotalBytes += a.Size
+	}
+	return m
+}
+
+/*
+SetManifest records where the run's manifest was written.
+*/
+func (r *FlowResult) SetManifest(path string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Manifest = path
+}
+
+/*
+Relocate rewrites the recorded file paths with move, e.g. once files written
+to a staging area have been moved to storage.
+*/
+func (r *FlowResult) Relocate(move func(path string) string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, f := range r.Files {
+		r.Files[i] = move(f)
+	}
+	for i, a := range r.Artifacts {
+		r.Artifacts[i].Path = move(a.Path)
+	}
+	if r.Manifest != "" {
+		r.Manifest = move(r.Manifest)
+	}
+}
+
+/*
+Finish stamps the end time and outcome of the run.
+*/
+func (r *FlowResult) Finish(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now()
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+/*
+RunResult is the machine-readable outcome of a command.
+
+Fields:
+  - Command: The command that ran (e.g. "run").
+  - Success: Whether the command succeeded overall.
+  - Flows:   Per-flow results, for commands that execute flows.
+  - Errors:  Errors not attributable to a single flow (e.g. config problems).
+  - Cleaned: Leftover temporary files and stale locks removed before and after the run.
+*/
+type RunResult struct {
+	Command string        `json:"command"`
+	Success bool          `json:"success"`
+	Flows   []*FlowResult `json:"flows,omitempty"`
+	Errors  []string      `json:"errors,omitempty"`
+	Cleaned []string      `json:"cleaned,omitempty"`
+}
+
+/*
+Collector accumulates FlowResults from concurrently running flows.
+*/
+type Collector struct {
+	mu     sync.Mutex
+	flows  []*FlowResult
+	stream bool
+}
+
+/*
+NewCollector returns a Collector. With stream set, every added result is also
+emitted immediately, which suits long-running daemons.
+*/
+func NewCollector(stream bool) *Collector {
+	return &Collector{stream: stream}
+}
+
+/*
+Add stores a finished FlowResult (and emits it when streaming).
+*/
+func (c *Collector) Add(r *FlowResult) {
+	c.mu.Lock()
+	if !c.stream {
+		c.flows = append(c.flows, r)
+	}
+	c.mu.Unlock()
+	if c.stream {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		Emit(r)
+	}
+}
+
+/*
+Flows returns the results collected so far.
+*/
+func (c *Collector) Flows() []*FlowResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*FlowResult(nil), c.flows...)
+}
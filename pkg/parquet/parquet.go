@@ -0,0 +1,344 @@
+// pkg/parquet/parquet.go
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+/*
+Type is the logical type of a column.
+*/
+type Type int
+
+/*
+Column types. Timestamps are stored as milliseconds since the Unix epoch in
+UTC, dates as days since the epoch.
+*/
+const (
+	String Type = iota
+	Int32
+	Int64
+	Double
+	Boolean
+	Timestamp
+	Date
+)
+
+/*
+Column describes one column of a file.
+
+Fields:
+  - Name:     Column name.
+  - Type:     Value type.
+  - Optional: Whether the column may hold nulls.
+*/
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+/*
+Writer collects rows of a flat schema and writes them as one Parquet file
+with a single row group: every column in one PLAIN-encoded, uncompressed data
+page. That keeps the format small enough to write without a dependency while
+staying readable by Spark, Athena, DuckDB and pyarrow; files are meant to hold
+one batch of imported orders, not millions of rows.
+*/
+type Writer struct {
+	columns []Column
+	values  [][]interface{}
+	rows    int
+}
+
+/*
+NewWriter returns a Writer for the given columns.
+*/
+func NewWriter(columns []Column) *Writer {
+	return &Writer{columns: columns, values: make([][]interface{}, len(columns))}
+}
+
+/*
+Rows returns the number of rows added.
+*/
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+/*
+Add appends a row with one value per column: string for String, int32 or int
+for Int32, int64 or int for Int64, float64 for Double, bool for Boolean and
+time.Time for Timestamp and Date. Optional columns also take nil.
+
+Returns:
+  - An error naming the column if a value does not fit it; the row is not added.
+*/
+func (w *Writer) Add(values ...interface{}) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("row has %d values, the schema has %d columns", len(values), len(w.columns))
+	}
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		c := w.columns[i]
+		if v == nil {
+			if !c.Optional {
+				return fmt.Errorf("column %s is required", c.Name)
+			}
+			continue
+		}
+		var ok bool
+		switch c.Type {
+		case String:
+			row[i], ok = v.(string)
+		case Int32:
+			switch x := v.(type) {
+			case int32:
+				row[i], ok = x, true
+			case int:
+				row[i], ok = int32(x), x >= math.MinInt32 && x <= math.MaxInt32
+			}
+		case Int64:
+			switch x := v.(type) {
+			case int64:
+				row[i], ok = x, true
+			case int:
+				row[i], ok = int64(x), true
+			}
+		case Double:
+			row[i], ok = v.(float64)
+		case Boolean:
+			row[i], ok = v.(bool)
+		case Timestamp:
+			var t time.Time
+			if t, ok = v.(time.Time); ok {
+				row[i] = t.UnixMilli()
+			}
+		case Date:
+			var t time.Time
+			if t, ok = v.(time.Time); ok {
+				row[i] = int32(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+			}
+		}
+		if !ok {
+			return fmt.Errorf("column %s: unsupported value %v (%T)", c.Name, v, v)
+		}
+	}
+	for i, v := range row {
+		w.values[i] = append(w.values[i], v)
+	}
+	w.rows++
+	return nil
+}
+
+// Physical types, converted types, encodings and repetitions of parquet.thrift.
+const (
+	physBoolean   = 0
+	physInt32     = 1
+	physInt64     = 2
+	physDouble    = 5
+	physByteArray = 6
+
+	convUTF8            = 0
+	convDate            = 6
+	convTimestampMillis = 9
+
+	encPlain = 0
+	encRLE   = 3
+
+	repRequired = 0
+	repOptional = 1
+)
+
+// physical returns the physical and converted type (-1 for none) of t.
+func physical(t Type) (int32, int32) {
+	switch t {
+	case String:
+		return physByteArray, convUTF8
+	case Int32:
+		return physInt32, -1
+	case Double:
+		return physDouble, -1
+	case Boolean:
+		return physBoolean, -1
+	case Timestamp:
+		return physInt64, convTimestampMillis
+	case Date:
+		return physInt32, convDate
+	default:
+		return physInt64, -1
+	}
+}
+
+// chunk is the location of a written column chunk.
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+/*
+Write encodes the rows added so far as a Parquet file to out.
+*/
+func (w *Writer) Write(out io.Writer) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+	chunks := make([]chunk, len(w.columns))
+	for i := range w.columns {
+		page := w.page(i)
+		var header compact
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(w.rows))
+		header.i32(2, encPlain)
+		header.i32(3, encRLE)
+		header.i32(4, encRLE)
+		header.endStruct()
+		header.end()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.b.Len() + len(page))}
+		file.Write(header.b.Bytes())
+		file.Write(page)
+	}
+
+	footer := w.footer(chunks)
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString("PAR1")
+	_, err := out.Write(file.Bytes())
+	return err
+}
+
+// page encodes the data page body of column i: definition levels (optional
+// columns only) followed by the PLAIN-encoded non-null values.
+func (w *Writer) page(i int) []byte {
+	var b bytes.Buffer
+	c := w.columns[i]
+	values := w.values[i]
+	if c.Optional {
+		levels := make([]bool, len(values))
+		for j, v := range values {
+			levels[j] = v != nil
+		}
+		packed := bitPackedRun(levels)
+		binary.Write(&b, binary.LittleEndian, uint32(len(packed)))
+		b.Write(packed)
+	}
+
+	var bits []bool
+	for _, v := range values {
+		switch x := v.(type) {
+		case nil:
+		case string:
+			binary.Write(&b, binary.LittleEndian, uint32(len(x)))
+			b.WriteString(x)
+		case int32:
+			binary.Write(&b, binary.LittleEndian, x)
+		case int64:
+			binary.Write(&b, binary.LittleEndian, x)
+		case float64:
+			binary.Write(&b, binary.LittleEndian, math.Float64bits(x))
+		case bool:
+			bits = append(bits, x)
+		}
+	}
+	if c.Type == Boolean {
+		b.Write(packBits(bits))
+	}
+	return b.Bytes()
+}
+
+// footer encodes the FileMetaData of the file.
+func (w *Writer) footer(chunks []chunk) []byte {
+	var m compact
+	m.begin()
+	m.i32(1, 1) // version
+	m.beginList(2, typeStruct, len(w.columns)+1)
+	m.beginElement()
+	m.binary(4, "schema")
+	m.i32(5, int32(len(w.columns)))
+	m.endStruct()
+	for _, c := range w.columns {
+		phys, conv := physical(c.Type)
+		rep := int32(repRequired)
+		if c.Optional {
+			rep = repOptional
+		}
+		m.beginElement()
+		m.i32(1, phys)
+		m.i32(3, rep)
+		m.binary(4, c.Name)
+		if conv >= 0 {
+			m.i32(6, conv)
+		}
+		m.endStruct()
+	}
+	m.i64(3, int64(w.rows))
+
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	m.beginList(4, typeStruct, 1)
+	m.beginElement()
+	m.beginList(1, typeStruct, len(w.columns))
+	for i, c := range w.columns {
+		phys, _ := physical(c.Type)
+		m.beginElement()
+		m.i64(2, chunks[i].offset)
+		m.beginStruct(3)
+		m.i32(1, phys)
+		m.beginList(2, typeI32, 2)
+		m.listI32(encPlain)
+		m.listI32(encRLE)
+		m.beginList(3, typeBinary, 1)
+		m.listBinary(c.Name)
+		m.i32(4, 0) // UNCOMPRESSED
+		m.i64(5, int64(w.rows))
+		m.i64(6, chunks[i].size)
+		m.i64(7, chunks[i].size)
+		m.i64(9, chunks[i].offset)
+		m.endStruct()
+		m.endStruct()
+	}
+	m.i64(2, total)
+	m.i64(3, int64(w.rows))
+	m.endStruct()
+	m.binary(6, "avcimporter")
+	m.end()
+	return m.b.Bytes()
+}
+
+/*
+bitPackedRun encodes 1-bit definition levels as a single bit-packed run of the
+RLE/bit-packing hybrid encoding.
+*/
+func bitPackedRun(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	var b bytes.Buffer
+	writeUvarint(&b, uint64(groups)<<1|1)
+	b.Write(packBits(levels))
+	return b.Bytes()
+}
+
+// packBits packs values LSB first, padding the last byte with zeros.
+func packBits(values []bool) []byte {
+	out := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+func writeUvarint(b *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	b.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
@@ -0,0 +1,163 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes compact protocol structs into maps by field ID, enough
+// to inspect the metadata the Writer produces.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case typeBoolTrue:
+		return true
+	case typeBoolFalse:
+		return false
+	case typeI32, typeI64:
+		return r.zigzag()
+	case typeBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case typeList:
+		h := r.b[r.pos]
+		r.pos++
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0F)
+		}
+		return list
+	case typeStruct:
+		return r.structure()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		h := r.b[r.pos]
+		r.pos++
+		if h == 0 {
+			return fields
+		}
+		if d := int16(h >> 4); d != 0 {
+			id += d
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0F)
+	}
+}
+
+// TestWriteFile verifies the file layout, the footer metadata and the encoding
+// of required, optional and boolean columns.
+func TestWriteFile(t *testing.T) {
+	w := NewWriter([]Column{
+		{Name: "po", Type: String},
+		{Name: "qty", Type: Int32, Optional: true},
+		{Name: "ok", Type: Boolean},
+		{Name: "cost", Type: Double},
+		{Name: "at", Type: Timestamp},
+		{Name: "day", Type: Date},
+	})
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("PDT", -7*3600))
+	if err := w.Add("PO1", 3, true, 1.5, at, at); err != nil {
+		t.Fatalf("Add() returned %v", err)
+	}
+	if err := w.Add("PO2", nil, false, 2.25, at, at); err != nil {
+		t.Fatalf("Add() returned %v", err)
+	}
+	if err := w.Add(nil, 1, true, 0.0, at, at); err == nil {
+		t.Errorf("Add() accepted a null in a required column")
+	}
+	if err := w.Add("PO3", "many", true, 0.0, at, at); err == nil {
+		t.Errorf("Add() accepted a string in an int32 column")
+	}
+	if w.Rows() != 2 {
+		t.Fatalf("Rows() = %d; expected 2", w.Rows())
+	}
+
+	var buf bytes.Buffer
+	if err := w.Write(&buf); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+		t.Fatalf("file does not start and end with PAR1")
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta := (&thriftReader{b: b[len(b)-8-n : len(b)-8]}).structure()
+
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v; expected 2", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 7 || schema[0].(map[int16]interface{})[5] != int64(6) {
+		t.Fatalf("schema = %v; expected a root with 6 children", schema)
+	}
+	qty := schema[2].(map[int16]interface{})
+	if qty[4] != "qty" || qty[3] != int64(repOptional) {
+		t.Errorf("schema element = %v; expected optional qty", qty)
+	}
+	if day := schema[6].(map[int16]interface{}); day[6] != int64(convDate) {
+		t.Errorf("schema element = %v; expected a DATE column", day)
+	}
+
+	columns := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	pages := make([][]byte, len(columns))
+	for i, c := range columns {
+		md := c.(map[int16]interface{})[3].(map[int16]interface{})
+		r := &thriftReader{b: b, pos: int(md[9].(int64))}
+		header := r.structure()
+		pages[i] = b[r.pos : r.pos+int(header[2].(int64))]
+		if r.pos+len(pages[i])-int(md[9].(int64)) != int(md[6].(int64)) {
+			t.Errorf("column %d: chunk size %v does not match its header and page", i, md[6])
+		}
+	}
+
+	if expected := []byte("\x03\x00\x00\x00PO1\x03\x00\x00\x00PO2"); !bytes.Equal(pages[0], expected) {
+		t.Errorf("po page = %q; expected %q", pages[0], expected)
+	}
+	// Definition levels: length 2, one bit-packed group (header 3), bits 01.
+	if expected := []byte{2, 0, 0, 0, 3, 1, 3, 0, 0, 0}; !bytes.Equal(pages[1], expected) {
+		t.Errorf("qty page = %v; expected %v", pages[1], expected)
+	}
+	if !bytes.Equal(pages[2], []byte{1}) {
+		t.Errorf("ok page = %v; expected [1]", pages[2])
+	}
+	if math.Float64frombits(binary.LittleEndian.Uint64(pages[3][8:])) != 2.25 {
+		t.Errorf("cost page = %v; expected 1.5, 2.25", pages[3])
+	}
+	if ms := int64(binary.LittleEndian.Uint64(pages[4])); ms != at.UnixMilli() {
+		t.Errorf("at = %d; expected %d", ms, at.UnixMilli())
+	}
+	if days := binary.LittleEndian.Uint32(pages[5]); days != 19844 {
+		t.Errorf("day = %d; expected 19844 (2024-05-01)", days)
+	}
+}
@@ -0,0 +1,110 @@
+// pkg/parquet/thrift.go
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol type IDs.
+const (
+	typeBoolTrue  = 1
+	typeBoolFalse = 2
+	typeI32       = 5
+	typeI64       = 6
+	typeBinary    = 8
+	typeList      = 9
+	typeStruct    = 12
+)
+
+/*
+compact encodes the Thrift structs of the Parquet metadata with the compact
+protocol. Fields must be written in ascending ID order within a struct; last
+tracks the previous field ID of every open struct for the delta encoding.
+*/
+type compact struct {
+	b    bytes.Buffer
+	last []int16
+}
+
+// begin opens the top-level struct.
+func (c *compact) begin() { c.last = append(c.last, 0) }
+
+// end closes the top-level struct.
+func (c *compact) end() { c.endStruct() }
+
+func (c *compact) field(id int16, typ byte) {
+	last := &c.last[len(c.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		c.b.WriteByte(byte(d)<<4 | typ)
+	} else {
+		c.b.WriteByte(typ)
+		c.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint writes a zigzag-encoded varint.
+func (c *compact) varint(v int64) {
+	writeUvarint(&c.b, uint64(v<<1^v>>63))
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, typeI32)
+	c.varint(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, typeI64)
+	c.varint(v)
+}
+
+func (c *compact) bool(id int16, v bool) {
+	if v {
+		c.field(id, typeBoolTrue)
+	} else {
+		c.field(id, typeBoolFalse)
+	}
+}
+
+func (c *compact) binary(id int16, s string) {
+	c.field(id, typeBinary)
+	c.listBinary(s)
+}
+
+// beginStruct opens a struct-valued field; close it with endStruct.
+func (c *compact) beginStruct(id int16) {
+	c.field(id, typeStruct)
+	c.last = append(c.last, 0)
+}
+
+// beginElement opens a struct element of a list; close it with endStruct.
+func (c *compact) beginElement() {
+	c.last = append(c.last, 0)
+}
+
+func (c *compact) endStruct() {
+	c.b.WriteByte(0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+// beginList writes the header of a list field with n elements of type elem.
+func (c *compact) beginList(id int16, elem byte, n int) {
+	c.field(id, typeList)
+	if n < 15 {
+		c.b.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	c.b.WriteByte(0xF0 | elem)
+	writeUvarint(&c.b, uint64(n))
+}
+
+// listI32 writes an i32 list element.
+func (c *compact) listI32(v int32) { c.varint(int64(v)) }
+
+// listBinary writes a binary list element (also the value of a binary field).
+func (c *compact) listBinary(s string) {
+	var tmp [binary.MaxVarintLen64]byte
+	c.b.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(s)))])
+	c.b.WriteString(s)
+}
@@ -0,0 +1,186 @@
+// pkg/pdf/pdf.go
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry in points: US Letter with half-inch margins.
+const (
+	pageWidth  = 612
+	pageHeight = 792
+	margin     = 36
+	fontSize   = 10
+	leading    = 12
+	headSize   = 14
+	headLead   = 20
+	// Courier is 0.6 em wide, so this many characters fit between the margins.
+	lineChars = (pageWidth - 2*margin) * 10 / (6 * fontSize)
+)
+
+/*
+Render lays out text on as many pages as it needs and writes them as a PDF
+document. Text is set in Courier so columns aligned with spaces stay aligned;
+lines starting with "# " are set as bold headings, a form feed starts a new
+page and lines too long for the page are wrapped. Characters outside Latin-1
+are replaced with "?".
+
+Parameters:
+  - w:     Destination of the document.
+  - title: Document title shown by PDF viewers.
+  - text:  The text to lay out.
+
+Returns:
+  - An error if writing fails.
+*/
+func Render(w io.Writer, title, text string) error {
+	pages := layout(text)
+
+	// Objects: 1 catalog, 2 page tree, 3 and 4 fonts, 5 info, then a page and
+	// its content stream per page.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title %s /Producer (avcimporter) >>", literal(title)),
+	)
+	for i, p := range pages {
+		content := p.content()
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 7+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// line is one line of a page; heading lines are set bold and larger.
+type line struct {
+	text    string
+	heading bool
+}
+
+// page is the lines of one page.
+type page []line
+
+// layout breaks text into pages.
+func layout(text string) []page {
+	pages := []page{nil}
+	y := pageHeight - margin
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for {
+			before, after, feed := strings.Cut(raw, "\f")
+			for _, l := range wrap(before) {
+				height := leading
+				if l.heading {
+					height = headLead
+				}
+				if y-height < margin {
+					pages = append(pages, nil)
+					y = pageHeight - margin
+				}
+				pages[len(pages)-1] = append(pages[len(pages)-1], l)
+				y -= height
+			}
+			if !feed {
+				break
+			}
+			pages = append(pages, nil)
+			y = pageHeight - margin
+			raw = after
+			if raw == "" {
+				break
+			}
+		}
+	}
+	// A trailing form feed leaves an empty page behind.
+	if len(pages) > 1 && len(pages[len(pages)-1]) == 0 {
+		pages = pages[:len(pages)-1]
+	}
+	return pages
+}
+
+// wrap splits one input line into lines that fit the page.
+func wrap(s string) []line {
+	heading := strings.HasPrefix(s, "# ")
+	if heading {
+		s = strings.TrimPrefix(s, "# ")
+	}
+	width := lineChars
+	if heading {
+		width = lineChars * fontSize / headSize
+	}
+	runes := []rune(s)
+	var lines []line
+	for len(runes) > width {
+		lines = append(lines, line{string(runes[:width]), heading})
+		runes = runes[width:]
+	}
+	return append(lines, line{string(runes), heading})
+}
+
+// content renders the content stream of a page.
+func (p page) content() string {
+	var b strings.Builder
+	y := pageHeight - margin
+	for _, l := range p {
+		font, size, height := "F1", fontSize, leading
+		if l.heading {
+			font, size, height = "F2", headSize, headLead
+		}
+		y -= height
+		if l.text == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "BT /%s %d Tf %d %d Td %s Tj ET\n", font, size, margin, y+height-size, literal(l.text))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// literal encodes s as a PDF string literal in WinAnsi (Latin-1) encoding.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 0x20 || r == 0x7f:
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestRender verifies the cross-reference table points at the objects, text
+// is escaped and form feeds and overflowing lines start new pages.
+func TestRender(t *testing.T) {
+	text := "# Purchase order (PO1)\nItem\\1  Müller €5\fPage two\n" + strings.Repeat("line\n", 70)
+	var b bytes.Buffer
+	if err := Render(&b, "PO1", text); err != nil {
+		t.Fatal(err)
+	}
+	doc := b.String()
+	if !strings.HasPrefix(doc, "%PDF-1.4\n") || !strings.HasSuffix(doc, "%%EOF\n") {
+		t.Fatalf("not a PDF document:\n%s", doc)
+	}
+	if !strings.Contains(doc, "/Count 3 >>") {
+		t.Errorf("expected 3 pages:\n%s", doc)
+	}
+	for _, want := range []string{`/F2 14 Tf 36 742 Td (Purchase order \(PO1\)) Tj`, `(Item\\1  M\374ller ?5) Tj`, "(Page two) Tj", "/Title (PO1)"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document lacks %q", want)
+		}
+	}
+
+	start, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(doc)[1])
+	if err != nil || !strings.HasPrefix(doc[start:], "xref\n") {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(doc[start:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(e[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(doc[off:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, doc[off:off+10])
+		}
+	}
+}
+
+// TestWrap verifies long lines are broken at the page width.
+func TestWrap(t *testing.T) {
+	lines := wrap(strings.Repeat("x", lineChars+5))
+	if len(lines) != 2 || len(lines[0].text) != lineChars || lines[1].text != "xxxxx" {
+		t.Errorf("wrap() = %+v", lines)
+	}
+}
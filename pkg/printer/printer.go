@@ -0,0 +1,181 @@
+// pkg/printer/printer.go
+package printer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MIME types of the documents the importer prints. ZPL is sent as FormatRaw,
+// which CUPS passes to the printer unfiltered.
+const (
+	FormatRaw  = "application/octet-stream"
+	FormatText = "text/plain"
+)
+
+/*
+Job is one document sent to a printer.
+
+Fields:
+  - Name:   Job name shown in the printer queue.
+  - Format: MIME type of Data; IPP printers pick their filter by it (raw printers ignore it).
+  - Data:   The document as the printer reads it.
+*/
+type Job struct {
+	Name   string
+	Format string
+	Data   []byte
+}
+
+/*
+Print sends a job to the printer at addr:
+
+  - raw://host[:port] or tcp://host[:port]: the data is written to a raw
+    socket (JetDirect, port 9100 by default), as ZPL label printers expect.
+  - ipp://host[:port]/path or ipps://...: an IPP Print-Job request to a
+    network printer or CUPS queue (port 631 by default), e.g.
+    ipp://cups.local/printers/labels.
+  - http:// and https:// URLs are sent the IPP request as they are.
+
+Parameters:
+  - ctx:     Bounds connecting and sending.
+  - addr:    Printer URL as above.
+  - job:     The document.
+  - timeout: How long connecting and sending may take (0: no limit beyond ctx).
+
+Returns:
+  - An error if the printer cannot be reached or refuses the job.
+*/
+func Print(ctx context.Context, addr string, job Job, timeout time.Duration) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid printer address %q: %w", addr, err)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	switch u.Scheme {
+	case "raw", "tcp":
+		return printRaw(ctx, hostPort(u, "9100"), job.Data)
+	case "ipp", "ipps", "http", "https":
+		return printIPP(ctx, u, job)
+	default:
+		return fmt.Errorf("unsupported printer address %q: expected raw://, ipp:// or ipps://", addr)
+	}
+}
+
+// hostPort returns the host of u with port def if it has none.
+func hostPort(u *url.URL, def string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), def)
+}
+
+// printRaw writes data to a raw print socket.
+func printRaw(ctx context.Context, addr string, data []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connect to printer %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("send to printer %s: %w", addr, err)
+	}
+	return conn.Close()
+}
+
+// IPP value tags and operation of the Print-Job request.
+const (
+	ippPrintJob        = 0x0002
+	tagOperation       = 0x01
+	tagEnd             = 0x03
+	tagName            = 0x42
+	tagURI             = 0x45
+	tagCharset         = 0x47
+	tagNaturalLanguage = 0x48
+	tagMimeMediaType   = 0x49
+)
+
+// printIPP sends job as an IPP/1.1 Print-Job request.
+func printIPP(ctx context.Context, u *url.URL, job Job) error {
+	endpoint, printerURI := *u, *u
+	switch u.Scheme {
+	case "ipp", "ipps":
+		endpoint.Scheme = "http"
+		if u.Scheme == "ipps" {
+			endpoint.Scheme = "https"
+		}
+		endpoint.Host = hostPort(u, "631")
+	case "http":
+		printerURI.Scheme = "ipp"
+	case "https":
+		printerURI.Scheme = "ipps"
+	}
+	body := encodePrintJob(printerURI.String(), job)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/ipp")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send to printer %s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("printer %s returned HTTP %d", u.Host, resp.StatusCode)
+	}
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(resp.Body, head); err != nil {
+		return fmt.Errorf("read printer %s response: %w", u.Host, err)
+	}
+	// Status codes 0x0000-0x00FF are successful-ok and its variants.
+	if status := binary.BigEndian.Uint16(head[2:4]); status > 0x00FF {
+		return fmt.Errorf("printer %s refused the job: IPP status 0x%04x", u.Host, status)
+	}
+	return nil
+}
+
+// encodePrintJob builds the Print-Job request with the document appended.
+func encodePrintJob(printerURI string, job Job) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{1, 1}) // IPP/1.1
+	binary.Write(&b, binary.BigEndian, uint16(ippPrintJob))
+	binary.Write(&b, binary.BigEndian, uint32(1)) // request-id
+	b.WriteByte(tagOperation)
+	attr := func(tag byte, name, value string) {
+		b.WriteByte(tag)
+		binary.Write(&b, binary.BigEndian, uint16(len(name)))
+		b.WriteString(name)
+		binary.Write(&b, binary.BigEndian, uint16(len(value)))
+		b.WriteString(value)
+	}
+	attr(tagCharset, "attributes-charset", "utf-8")
+	attr(tagNaturalLanguage, "attributes-natural-language", "en")
+	attr(tagURI, "printer-uri", printerURI)
+	attr(tagName, "requesting-user-name", "avcimporter")
+	if job.Name != "" {
+		attr(tagName, "job-name", job.Name)
+	}
+	if job.Format != "" {
+		attr(tagMimeMediaType, "document-format", job.Format)
+	}
+	b.WriteByte(tagEnd)
+	b.Write(job.Data)
+	return b.Bytes()
+}
@@ -0,0 +1,76 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPrintRaw verifies the document is written to the socket unchanged.
+func TestPrintRaw(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		got <- data
+	}()
+
+	if err := Print(context.Background(), "raw://"+ln.Addr().String(), Job{Data: []byte("^XA^XZ")}, 5*time.Second); err != nil {
+		t.Fatalf("Print() error: %v", err)
+	}
+	if data := <-got; string(data) != "^XA^XZ" {
+		t.Errorf("printer received %q", data)
+	}
+	if err := Print(context.Background(), "lpd://host/queue", Job{}, 0); err == nil {
+		t.Error("Print() accepted an unsupported scheme")
+	}
+}
+
+// TestPrintIPP verifies the Print-Job request carries the printer URI, job
+// attributes and document, and that IPP error statuses fail the job.
+func TestPrintIPP(t *testing.T) {
+	var body []byte
+	status := []byte{0x00, 0x00}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/ipp" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.Write(append([]byte{1, 1}, append(status, 0, 0, 0, 1, tagEnd)...))
+	}))
+	defer srv.Close()
+
+	job := Job{Name: "PO1 packing slip", Format: FormatText, Data: []byte("PACKING SLIP\f")}
+	if err := Print(context.Background(), srv.URL+"/printers/office", job, 5*time.Second); err != nil {
+		t.Fatalf("Print() error: %v", err)
+	}
+	if !bytes.HasPrefix(body, []byte{1, 1, 0, 2}) || !bytes.HasSuffix(body, append([]byte{tagEnd}, job.Data...)) {
+		t.Fatalf("request is not a Print-Job with the document: %q", body)
+	}
+	for _, want := range []string{"ipp://" + strings.TrimPrefix(srv.URL, "http://") + "/printers/office", "PO1 packing slip", FormatText} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("request lacks %q", want)
+		}
+	}
+
+	status = []byte{0x04, 0x0A} // client-error-document-format-not-supported
+	if err := Print(context.Background(), srv.URL+"/printers/office", job, 5*time.Second); err == nil || !strings.Contains(err.Error(), "0x040a") {
+		t.Errorf("Print() error = %v; expected the IPP status", err)
+	}
+}
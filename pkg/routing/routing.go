@@ -0,0 +1,116 @@
+// pkg/routing/routing.go
+package routing
+
+import (
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+/*
+Rule routes order lines to a warehouse. A rule matches a line when every
+condition it sets matches; a rule without conditions matches every line.
+
+Fields:
+  - Warehouse: Ship-from party ID of the warehouse.
+  - ShipTo:    Ship-to party IDs (Amazon fulfillment center codes).
+  - Regions:   Names of Router.Regions the ship-to party belongs to.
+  - SKUs:      Vendor SKUs or ASINs the warehouse stocks.
+*/
+type Rule struct {
+	Warehouse string
+	ShipTo    []string
+	Regions   []string
+	SKUs      []string
+}
+
+/*
+Router assigns each order line to the warehouse that ships it.
+
+Fields:
+  - Regions: Fulfillment center codes by region name, e.g. "west": ["PHX3", "LAX9"].
+  - Rules:   Checked in order; the first match wins.
+  - Default: Warehouse of lines no rule matches ("" leaves them unrouted).
+*/
+type Router struct {
+	Regions map[string][]string
+	Rules   []Rule
+	Default string
+}
+
+/*
+Route returns the warehouse of a line with the given product shipped to
+shipTo, or "" if no rule matches and there is no default.
+*/
+func (r Router) Route(shipTo, vendorSKU, asin string) string {
+	for _, rule := range r.Rules {
+		if len(rule.ShipTo) > 0 && !contains(rule.ShipTo, shipTo) {
+			continue
+		}
+		if len(rule.Regions) > 0 && !r.inRegion(rule.Regions, shipTo) {
+			continue
+		}
+		if len(rule.SKUs) > 0 && !(vendorSKU != "" && contains(rule.SKUs, vendorSKU)) && !(asin != "" && contains(rule.SKUs, asin)) {
+			continue
+		}
+		return rule.Warehouse
+	}
+	return r.Default
+}
+
+// inRegion reports whether shipTo belongs to one of the named regions.
+func (r Router) inRegion(regions []string, shipTo string) bool {
+	for _, name := range regions {
+		if contains(r.Regions[name], shipTo) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Shipment is the part of a purchase order one warehouse ships.
+
+Fields:
+  - Warehouse: Ship-from party ID ("" for lines no rule routes).
+  - Items:     The order lines, in order.
+*/
+type Shipment struct {
+	Warehouse string
+	Items     []spapi.OrderItem
+}
+
+/*
+Split divides the lines of po among the warehouses that ship them. An order
+routed to a single warehouse yields one Shipment.
+
+Returns:
+  - One Shipment per warehouse, in the order of their first line.
+*/
+func (r Router) Split(po spapi.PurchaseOrder) []Shipment {
+	shipTo := ""
+	if p := po.OrderDetails.ShipToParty; p != nil {
+		shipTo = p.PartyID
+	}
+	var shipments []Shipment
+	index := map[string]int{}
+	for _, it := range po.OrderDetails.Items {
+		w := r.Route(shipTo, it.VendorProductIdentifier, it.AmazonProductIdentifier)
+		i, ok := index[w]
+		if !ok {
+			i = len(shipments)
+			index[w] = i
+			shipments = append(shipments, Shipment{Warehouse: w})
+		}
+		shipments[i].Items = append(shipments[i].Items, it)
+	}
+	return shipments
+}
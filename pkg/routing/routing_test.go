@@ -0,0 +1,51 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// TestSplit verifies lines are routed by SKU, fulfillment center and region in
+// rule order, with the default catching the rest.
+func TestSplit(t *testing.T) {
+	r := Router{
+		Regions: map[string][]string{"west": {"PHX3", "LAX9"}},
+		Rules: []Rule{
+			{Warehouse: "WH-BULK", SKUs: []string{"SKU-BIG", "B0BULKY"}},
+			{Warehouse: "WH-WEST", Regions: []string{"west"}},
+			{Warehouse: "WH-TX", ShipTo: []string{"DFW7"}},
+		},
+		Default: "WH-EAST",
+	}
+	tests := []struct {
+		shipTo, sku, asin string
+		want              string
+	}{
+		{"PHX3", "SKU-1", "", "WH-WEST"},
+		{"PHX3", "", "B0BULKY", "WH-BULK"},
+		{"DFW7", "SKU-1", "", "WH-TX"},
+		{"JFK8", "SKU-1", "", "WH-EAST"},
+	}
+	for _, tt := range tests {
+		if got := r.Route(tt.shipTo, tt.sku, tt.asin); got != tt.want {
+			t.Errorf("Route(%s, %s, %s) = %q; expected %q", tt.shipTo, tt.sku, tt.asin, got, tt.want)
+		}
+	}
+
+	po := spapi.PurchaseOrder{PurchaseOrderNumber: "PO1", OrderDetails: spapi.OrderDetails{
+		ShipToParty: &spapi.Party{PartyID: "LAX9"},
+		Items: []spapi.OrderItem{
+			{ItemSequenceNumber: "1", VendorProductIdentifier: "SKU-1"},
+			{ItemSequenceNumber: "2", VendorProductIdentifier: "SKU-BIG"},
+			{ItemSequenceNumber: "3", VendorProductIdentifier: "SKU-2"},
+		},
+	}}
+	shipments := r.Split(po)
+	if len(shipments) != 2 || shipments[0].Warehouse != "WH-WEST" || len(shipments[0].Items) != 2 || shipments[1].Warehouse != "WH-BULK" {
+		t.Fatalf("Split() = %+v", shipments)
+	}
+	if shipments[0].Items[1].ItemSequenceNumber != "3" {
+		t.Errorf("Split() reordered lines: %+v", shipments[0].Items)
+	}
+}
@@ -0,0 +1,372 @@
+// pkg/scheduler/scheduler.go
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+RetryPolicy controls how a failed job run is retried before it is reported as
+failed. It is utils.RetryPolicy; an empty Name defaults to the job name.
+*/
+type RetryPolicy = utils.RetryPolicy
+
+/*
+Job is a single flow (an EDI partner, an SP‑API profile, a feed) executed by the Scheduler.
+
+Fields:
+  - Name:          Unique name used in log output.
+  - Interval:      Time between runs in daemon mode.
+  - MaxConcurrent: How many runs of this job may execute at once (values below 1 are treated as 1).
+  - Retry:         Retry policy applied to each run.
+  - Run:           The work to perform.
+*/
+type Job struct {
+	Name          string
+	Interval      time.Duration
+	MaxConcurrent int
+	Retry         RetryPolicy
+	Run           func(ctx context.Context) error
+}
+
+// jobState pairs a Job with the semaphore enforcing its concurrency limit.
+// The job definition may be replaced at runtime through Scheduler.Update.
+type jobState struct {
+	mu      sync.Mutex
+	job     Job
+	slots   chan struct{}
+	changed chan struct{}
+	trigger chan struct{}
+}
+
+// current returns the job definition and concurrency semaphore in effect.
+func (js *jobState) current() (Job, chan struct{}) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	return js.job, js.slots
+}
+
+// task is one queued run of a job; done receives the final result.
+type task struct {
+	job   Job
+	slots chan struct{}
+	done  chan error
+}
+
+/*
+Result describes one completed run of a job, after all retries.
+
+Fields:
+  - Job:      Name of the job.
+  - Started:  When the first attempt began.
+  - Finished: When the last attempt ended.
+  - Err:      The final error, or nil on success.
+*/
+type Result struct {
+	Job      string
+	Started  time.Time
+	Finished time.Time
+	Err      error
+}
+
+/*
+Scheduler executes jobs on a fixed-size worker pool, so a slow job only
+occupies its own worker and never delays the others.
+*/
+type Scheduler struct {
+	workers   int
+	mu        sync.Mutex
+	jobs      []*jobState
+	observers []func(Result)
+	logger    *utils.Logger
+}
+
+/*
+New creates a Scheduler backed by the given number of workers.
+
+Parameters:
+  - workers: Size of the worker pool (values below 1 are treated as 1).
+
+Returns:
+  - A Scheduler with no jobs registered.
+*/
+func New(workers int) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Scheduler{workers: workers}
+}
+
+/*
+SetLogger makes the scheduler report skipped and failed runs to l. It must be
+called before Run or RunOnce; without it nothing is printed.
+*/
+func (s *Scheduler) SetLogger(l *utils.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
+/*
+Add registers a job with the scheduler. It must be called before Run or RunOnce.
+*/
+func (s *Scheduler) Add(job Job) {
+	if job.MaxConcurrent < 1 {
+		job.MaxConcurrent = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &jobState{
+		job:     job,
+		slots:   make(chan struct{}, job.MaxConcurrent),
+		changed: make(chan struct{}, 1),
+		trigger: make(chan struct{}, 1),
+	})
+}
+
+/*
+Observe registers fn to be called after every completed job run. Observers are
+called from worker goroutines and must be safe for concurrent use.
+*/
+func (s *Scheduler) Observe(fn func(Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
+/*
+Update replaces the definition of the registered job with the same name while
+the scheduler is running. The new interval takes effect from the next tick and
+the new retry policy and Run function from the next run; runs already in flight
+finish under the old definition.
+
+Returns:
+  - false if no job with that name is registered.
+*/
+func (s *Scheduler) Update(job Job) bool {
+	if job.MaxConcurrent < 1 {
+		job.MaxConcurrent = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, js := range s.jobs {
+		if js.job.Name != job.Name {
+			continue
+		}
+		js.mu.Lock()
+		if job.MaxConcurrent != js.job.MaxConcurrent {
+			js.slots = make(chan struct{}, job.MaxConcurrent)
+		}
+		js.job = job
+		js.mu.Unlock()
+		select {
+		case js.changed <- struct{}{}:
+		default:
+		}
+		return true
+	}
+	return false
+}
+
+/*
+Trigger requests an immediate run of the named jobs, or of every job when no
+name is given, outside their schedule; the interval then restarts from the
+triggered run. Like a tick, a trigger is skipped when the job is at its
+concurrency limit, and triggers arriving before the job gets to run count
+once. It only has an effect while Run is executing.
+
+Returns:
+  - The names of the triggered jobs; unknown names are ignored.
+*/
+func (s *Scheduler) Trigger(names ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var triggered []string
+	for _, js := range s.jobs {
+		job, _ := js.current()
+		if len(names) > 0 && !contains(names, job.Name) {
+			continue
+		}
+		select {
+		case js.trigger <- struct{}{}:
+		default:
+		}
+		triggered = append(triggered, job.Name)
+	}
+	return triggered
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+RunOnce executes every registered job a single time on the worker pool and waits
+for all of them to finish.
+
+Returns:
+  - An error joining the failures of every job that did not succeed, or nil.
+*/
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	queue := make(chan task)
+	var wg sync.WaitGroup
+	s.startWorkers(ctx, queue, &wg)
+
+	s.mu.Lock()
+	jobs := append([]*jobState(nil), s.jobs...)
+	s.mu.Unlock()
+
+	names := make([]string, len(jobs))
+	results := make([]chan error, len(jobs))
+	for i, js := range jobs {
+		job, slots := js.current()
+		names[i] = job.Name
+		results[i] = make(chan error, 1)
+		slots <- struct{}{}
+		queue <- task{job: job, slots: slots, done: results[i]}
+	}
+	close(queue)
+	wg.Wait()
+
+	var errs []error
+	for i := range jobs {
+		if err := <-results[i]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+Run executes every registered job immediately and then again on each job's
+interval until ctx is cancelled. A tick is skipped when the job is already
+running at its concurrency limit. Run blocks until all in-flight runs finish.
+*/
+func (s *Scheduler) Run(ctx context.Context) error {
+	queue := make(chan task)
+	var wg sync.WaitGroup
+	s.startWorkers(ctx, queue, &wg)
+
+	s.mu.Lock()
+	jobs := append([]*jobState(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, js := range jobs {
+		if job, _ := js.current(); job.Interval <= 0 {
+			close(queue)
+			wg.Wait()
+			return fmt.Errorf("job %s has no interval configured", job.Name)
+		}
+	}
+
+	var tickers sync.WaitGroup
+	for _, js := range jobs {
+		tickers.Add(1)
+		go func(js *jobState) {
+			defer tickers.Done()
+			job, _ := js.current()
+			t := time.NewTicker(job.Interval)
+			defer t.Stop()
+			for {
+				s.enqueue(ctx, queue, js)
+				if !waitTick(ctx, js, t) {
+					return
+				}
+			}
+		}(js)
+	}
+
+	tickers.Wait()
+	close(queue)
+	wg.Wait()
+	return nil
+}
+
+// waitTick blocks until the job's next tick or trigger, resetting the ticker
+// whenever the job is updated or triggered. It returns false once ctx is cancelled.
+func waitTick(ctx context.Context, js *jobState, t *time.Ticker) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+			return true
+		case <-js.trigger:
+			if job, _ := js.current(); job.Interval > 0 {
+				t.Reset(job.Interval)
+			}
+			return true
+		case <-js.changed:
+			if job, _ := js.current(); job.Interval > 0 {
+				t.Reset(job.Interval)
+			}
+		}
+	}
+}
+
+// enqueue hands a run of js to the pool unless the job is at its concurrency limit.
+func (s *Scheduler) enqueue(ctx context.Context, queue chan<- task, js *jobState) {
+	job, slots := js.current()
+	select {
+	case slots <- struct{}{}:
+	default:
+		s.logger.Log(utils.LevelWarn, "Skipping run, job still busy: ", job.Name)
+		return
+	}
+	select {
+	case queue <- task{job: job, slots: slots, done: make(chan error, 1)}:
+	case <-ctx.Done():
+		<-slots
+	}
+}
+
+// startWorkers launches the worker pool consuming from queue.
+func (s *Scheduler) startWorkers(ctx context.Context, queue <-chan task, wg *sync.WaitGroup) {
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				started := time.Now()
+				err := runWithRetry(ctx, t.job)
+				<-t.slots
+				if err != nil {
+					s.logger.Log(utils.LevelError, "Job failed: ", t.job.Name+": "+err.Error())
+				}
+				s.notify(Result{Job: t.job.Name, Started: started, Finished: time.Now(), Err: err})
+				t.done <- err
+			}
+		}()
+	}
+}
+
+// notify passes r to every registered observer.
+func (s *Scheduler) notify(r Result) {
+	s.mu.Lock()
+	observers := make([]func(Result), len(s.observers))
+	copy(observers, s.observers)
+	s.mu.Unlock()
+	for _, fn := range observers {
+		fn(r)
+	}
+}
+
+// runWithRetry executes job.Run, retrying with exponential backoff per job.Retry.
+func runWithRetry(ctx context.Context, job Job) error {
+	policy := job.Retry
+	if policy.Name == "" {
+		policy.Name = job.Name
+	}
+	return utils.Retry(ctx, policy, job.Run)
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRunOnceRetries verifies a failing job is retried until it succeeds.
+func TestRunOnceRetries(t *testing.T) {
+	var calls int32
+	s := New(2)
+	s.Add(Job{
+		Name:  "flaky",
+		Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+		Run: func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+	})
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce() returned %v; expected nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+// TestRunOnceJoinsErrors verifies failures of all jobs are reported together.
+func TestRunOnceJoinsErrors(t *testing.T) {
+	errA := errors.New("a failed")
+	s := New(1)
+	s.Add(Job{Name: "a", Run: func(ctx context.Context) error { return errA }})
+	s.Add(Job{Name: "b", Run: func(ctx context.Context) error { return nil }})
+	err := s.RunOnce(context.Background())
+	if !errors.Is(err, errA) {
+		t.Errorf("RunOnce() = %v; expected it to wrap %v", err, errA)
+	}
+}
+
+// TestRunIndependentJobs verifies a slow job does not block a fast one.
+func TestRunIndependentJobs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var fast int32
+	s := New(2)
+	s.Add(Job{Name: "slow", Interval: time.Hour, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}})
+	s.Add(Job{Name: "fast", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		atomic.AddInt32(&fast, 1)
+		return nil
+	}})
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	if atomic.LoadInt32(&fast) < 2 {
+		t.Errorf("expected fast job to run repeatedly, ran %d times", fast)
+	}
+}
+
+// TestTrigger verifies a triggered job runs again before its interval elapses
+// and that only known jobs are triggered.
+func TestTrigger(t *testing.T) {
+	runs := make(chan string, 10)
+	s := New(2)
+	for _, name := range []string{"api", "edi"} {
+		name := name
+		s.Add(Job{Name: name, Interval: time.Hour, Run: func(ctx context.Context) error {
+			runs <- name
+			return nil
+		}})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 0; i < 2; i++ {
+		<-runs
+	}
+	if got := s.Trigger("api", "unknown"); len(got) != 1 || got[0] != "api" {
+		t.Fatalf("Trigger() = %v; expected [api]", got)
+	}
+	select {
+	case name := <-runs:
+		if name != "api" {
+			t.Errorf("triggered run of %s; expected api", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("triggered job did not run")
+	}
+	if got := s.Trigger(); len(got) != 2 {
+		t.Errorf("Trigger() without names = %v; expected every job", got)
+	}
+}
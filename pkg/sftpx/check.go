@@ -0,0 +1,75 @@
+// pkg/sftpx/check.go
+package sftpx
+
+import (
+	"context"
+	"fmt"
+	"path"
+)
+
+/*
+CheckOptions selects what Check verifies.
+
+Fields:
+  - InboundDir:  Directory that must be listable (e.g. "download").
+  - OutboundDir: Directory that must exist (e.g. "upload").
+  - Probe:       Also create and remove a zero-byte file in OutboundDir.
+*/
+type CheckOptions struct {
+	InboundDir  string
+	OutboundDir string
+	Probe       bool
+}
+
+/*
+Access is the outcome of Check; a nil error means the check passed.
+
+Fields:
+  - Login:    Key parsing, SSH dial and SFTP session.
+  - Inbound:  Listing the inbound directory.
+  - Outbound: Stat of the outbound directory, plus the write probe if requested.
+*/
+type Access struct {
+	Login    error
+	Inbound  error
+	Outbound error
+}
+
+/*
+Check logs in to the server and verifies both directions: that
+opts.InboundDir can be listed and that opts.OutboundDir is a directory. The
+write probe is opt-in because Amazon may pick up anything written to its
+upload folder. Missing directories are discovered or created as set in conn.
+*/
+func Check(ctx context.Context, conn Conn, opts CheckOptions) Access {
+	var access Access
+	s, err := conn.open(ctx)
+	if err != nil {
+		access.Login = err
+		return access
+	}
+	defer s.Close()
+
+	if inboundDir, err := conn.resolveDir(s.client, opts.InboundDir, InboundDirName, false); err != nil {
+		access.Inbound = err
+	} else if _, err := s.client.ReadDirContext(ctx, inboundDir); err != nil {
+		access.Inbound = fmt.Errorf("failed to list %s: %w", inboundDir, err)
+	}
+	outboundDir, err := conn.resolveDir(s.client, opts.OutboundDir, OutboundDirName, true)
+	switch {
+	case err != nil:
+		access.Outbound = err
+	case opts.Probe:
+		name := path.Join(outboundDir, ".avcimporter-doctor")
+		f, err := s.client.Create(name)
+		if err != nil {
+			access.Outbound = fmt.Errorf("failed to create %s: %w", name, err)
+			break
+		}
+		f.Close()
+		if err := s.client.Remove(name); err != nil {
+			access.Outbound = fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return access
+}
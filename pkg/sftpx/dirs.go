@@ -0,0 +1,102 @@
+// pkg/sftpx/dirs.go
+package sftpx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// discoveredDirs maps configured to discovered directories, so each
+// discovery is reported once.
+var discoveredDirs sync.Map
+
+// Standard directories of Amazon's SFTP layout, relative to the home directory.
+const (
+	InboundDirName  = "download"
+	OutboundDirName = "upload"
+)
+
+/*
+resolveDir verifies that dir exists on the server and is a directory.
+A missing directory is replaced by the standard one (InboundDirName or
+OutboundDirName, matched case-insensitively in the home directory) when
+c.DiscoverDirs is set, and created when create and c.CreateOutboundDir are
+set. Otherwise the error lists the home directory, so a wrong path is
+easy to spot.
+
+Parameters:
+  - client:   The SFTP session.
+  - dir:      The configured directory, relative to the home directory.
+  - standard: Amazon's name for the directory.
+  - create:   Whether the directory may be created (outbound only).
+
+Returns:
+  - The directory to use.
+  - An error if it does not exist and cannot be discovered or created.
+*/
+func (c Conn) resolveDir(client *sftp.Client, dir, standard string, create bool) (string, error) {
+	dir = strings.TrimPrefix(dir, "/")
+	info, err := client.Stat(dir)
+	switch {
+	case err == nil && info.IsDir():
+		return dir, nil
+	case err == nil:
+		return "", fmt.Errorf("remote path %s is not a directory", dir)
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("failed to stat remote directory %s: %w", dir, err)
+	}
+
+	home, _ := client.ReadDir(".")
+	var found string
+	for _, e := range home {
+		if e.IsDir() && strings.EqualFold(e.Name(), standard) {
+			found = e.Name()
+			break
+		}
+	}
+	if found != "" && c.DiscoverDirs {
+		if _, seen := discoveredDirs.LoadOrStore(dir, found); !seen {
+			c.Logger.Log(utils.LevelWarn, "Using discovered remote directory: ", fmt.Sprintf("%s (%s does not exist)", found, dir))
+		}
+		return found, nil
+	}
+	if create && c.CreateOutboundDir {
+		if err := client.MkdirAll(dir); err != nil {
+			return "", fmt.Errorf("remote directory %s does not exist and creating it failed (the server may not permit it): %w", dir, err)
+		}
+		c.Logger.PrintColored("Created remote directory: ", dir, "#32CD32")
+		return dir, nil
+	}
+	if found != "" {
+		return "", fmt.Errorf("remote directory %s does not exist; Amazon's %s/ does, set edi.discoverDirs to use it", dir, found)
+	}
+	return "", fmt.Errorf("remote directory %s does not exist%s", dir, describeHome(home, path.Dir(dir)))
+}
+
+// describeHome lists the directories of the home directory for diagnostics,
+// unless the missing directory is nested below another one.
+func describeHome(entries []fs.FileInfo, parent string) string {
+	if parent != "." {
+		return ""
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name()+"/")
+		}
+	}
+	if len(dirs) == 0 {
+		return "; the home directory has no subdirectories"
+	}
+	sort.Strings(dirs)
+	return "; the home directory contains " + strings.Join(dirs, ", ")
+}
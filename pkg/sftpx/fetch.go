@@ -0,0 +1,274 @@
+// pkg/sftpx/fetch.go
+package sftpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/pkg/sftp"
+)
+
+/*
+File describes one file fetched (and removed) by Fetch.
+
+Fields:
+  - LocalPath:    Where the file was saved.
+  - RemotePath:   Its path on the SFTP server.
+  - Size:         Bytes written locally.
+  - SHA256:       Hex SHA‑256 of the content.
+  - DownloadedAt: When the transfer finished.
+  - ModTime:      Modification time reported by the server.
+  - Cursor:       Listing position of the file; pass it as FetchOptions.After to continue after it.
+*/
+type File struct {
+	LocalPath    string
+	RemotePath   string
+	Size         int64
+	SHA256       string
+	DownloadedAt time.Time
+	ModTime      time.Time
+	Cursor       string
+}
+
+// Listing orders supported by FetchOptions.SortBy.
+const (
+	SortByName    = "name"
+	SortByModTime = "mtime"
+)
+
+/*
+FetchOptions controls which files Fetch downloads and where they go.
+
+Fields:
+  - RemoteDir: Directory on the server, relative to the home directory (e.g. "download").
+  - LocalDir:  Local directory the files are saved in; created if missing.
+  - Keep:      Leave files on the server instead of deleting them after download.
+  - Limit:     Fetch at most this many files per call (0 fetches all).
+  - SortBy:    SortByName (default) or SortByModTime, oldest first; ties are broken by name.
+  - After:     Cursor of the last file fetched by a previous call; only files listed after it are fetched.
+  - Progress:  Draw a progress bar of the transfers on the log output of conn.Logger if it is a terminal.
+*/
+type FetchOptions struct {
+	RemoteDir string
+	LocalDir  string
+	Keep      bool
+	Limit     int
+	SortBy    string
+	After     string
+	Progress  bool
+}
+
+// cursor returns the listing position of a file; cursors of the same sort
+// order compare as strings.
+func (o FetchOptions) cursor(info os.FileInfo) string {
+	if o.SortBy == SortByModTime {
+		return info.ModTime().UTC().Format("20060102T150405.000000000Z") + "/" + info.Name()
+	}
+	return info.Name()
+}
+
+// selectFiles orders entries and drops directories and everything up to the
+// cursor.
+func (o FetchOptions) selectFiles(entries []os.FileInfo) []os.FileInfo {
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && (o.After == "" || o.cursor(e) > o.After) {
+			files = append(files, e)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return o.cursor(files[i]) < o.cursor(files[j]) })
+	return files
+}
+
+/*
+Resume returns the cursor a later Fetch continues from when only some of
+files (as returned by Fetch, in listing order) were handled: that of the last
+file in the unbroken run of handled files from the start, so the first
+failure and everything after it are fetched again. If the first file failed,
+after (the cursor files were fetched with) is returned.
+*/
+func Resume(after string, files []File, handled []bool) string {
+	for i, f := range files {
+		if !handled[i] {
+			break
+		}
+		after = f.Cursor
+	}
+	return after
+}
+
+/*
+Fetch downloads the files in opts.RemoteDir to opts.LocalDir and, unless
+opts.Keep is set, deletes them from the server (to satisfy Amazon's receiving
+test). For huge directories opts can cap the files per call and continue from
+the cursor of an earlier call. If a transfer fails, the files fetched before
+it are returned along with the error, so the caller can still account for
+them.
+
+Parameters:
+  - ctx:  Cancels the transfer; each file is traced as a child span of ctx's span.
+  - conn: The server to fetch from.
+  - opts: Directories and file selection.
+
+Returns:
+  - The files fetched, in listing order.
+  - An error if any step fails.
+*/
+func Fetch(ctx context.Context, conn Conn, opts FetchOptions) ([]File, error) {
+	s, err := conn.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	remoteDir, err := conn.resolveDir(s.client, opts.RemoteDir, InboundDirName, false)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.client.ReadDirContext(ctx, remoteDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote directory %s: %w", remoteDir, err)
+	}
+	conn.Logger.Debugf(utils.ModuleSFTP, "Listed "+remoteDir+": ", "%d entries", len(entries))
+	entries = opts.selectFiles(entries)
+	if opts.Limit > 0 && len(entries) > opts.Limit {
+		conn.Logger.LogContext(ctx, utils.LevelWarn, "File limit reached: ", fmt.Sprintf("fetching %d of %d files in %s; the rest follow on later runs", opts.Limit, len(entries), remoteDir))
+		entries = entries[:opts.Limit]
+	}
+
+	if len(entries) == 0 {
+		conn.Logger.LogContext(ctx, utils.LevelWarn, "No files found in ", remoteDir)
+		return nil, nil
+	}
+
+	if err := conn.Permissions.MkdirAll(opts.LocalDir); err != nil {
+		return nil, fmt.Errorf("failed to create local dir %s: %w", opts.LocalDir, err)
+	}
+
+	var totalBytes int64
+	for _, entry := range entries {
+		totalBytes += entry.Size()
+	}
+	progress := conn.Logger.NewProgress(ctx, opts.Progress, totalBytes, len(entries))
+	defer progress.Finish()
+
+	var downloaded []File
+	for _, entry := range entries {
+		// Remote paths always use forward slashes, even when running on Windows.
+		remotePath := path.Join(remoteDir, entry.Name())
+		localPath := filepath.Join(opts.LocalDir, entry.Name())
+
+		file, err := conn.download(ctx, s.client, remotePath, localPath, entry.Size(), !opts.Keep, progress)
+		if err != nil {
+			return downloaded, err
+		}
+		file.ModTime, file.Cursor = entry.ModTime(), opts.cursor(entry)
+
+		downloaded = append(downloaded, file)
+	}
+
+	return downloaded, nil
+}
+
+// download copies one remote file of the given size to localPath, reporting
+// to progress (which may be nil), and with remove set deletes it remotely. The
+// deletion is recorded in the audit trail with the hash of the saved copy.
+// The copy is written to localPath+".part" and renamed when complete, so an
+// interrupted transfer never looks like a finished file.
+func (c Conn) download(ctx context.Context, client *sftp.Client, remotePath, localPath string, size int64, remove bool, progress *utils.Progress) (file File, err error) {
+	_, span := tracing.Start(ctx, "sftp.download", "remote.path", remotePath)
+	defer func() { span.End(err) }()
+
+	rf, err := client.Open(remotePath)
+	if err != nil {
+		return file, fmt.Errorf("open remote %s: %w", remotePath, err)
+	}
+	partPath := localPath + ".part"
+	lf, err := os.Create(partPath)
+	if err != nil {
+		rf.Close()
+		return file, fmt.Errorf("create local %s: %w", partPath, err)
+	}
+	hashed := audit.NewHasher(lf)
+	n, err := io.Copy(hashed, progress.Reader(path.Base(remotePath), size, rf))
+	rf.Close()
+	if cerr := lf.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = c.Permissions.Apply(partPath)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return file, fmt.Errorf("copy %s to %s: %w", remotePath, localPath, err)
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		os.Remove(partPath)
+		return file, fmt.Errorf("rename %s: %w", partPath, err)
+	}
+	span.SetAttr("bytes", n)
+	c.Logger.Debugf(utils.ModuleSFTP, "Downloaded: ", "%s -> %s (%d bytes)", remotePath, localPath, n)
+	file = File{LocalPath: localPath, RemotePath: remotePath, Size: n, SHA256: hashed.Sum(), DownloadedAt: time.Now()}
+	if !remove {
+		return file, nil
+	}
+	return file, c.removeRemote(client, file)
+}
+
+// removeRemote deletes the remote copy of a downloaded file and records the
+// deletion in the audit trail with the hash of the saved copy.
+func (c Conn) removeRemote(client *sftp.Client, file File) error {
+	if err := client.Remove(file.RemotePath); err != nil {
+		return fmt.Errorf("delete remote %s: %w", file.RemotePath, err)
+	}
+	c.Logger.Debugf(utils.ModuleSFTP, "Deleted remote: ", "%s", file.RemotePath)
+	c.recordAudit(audit.Event{
+		Action: audit.SFTPDelete,
+		Target: file.RemotePath,
+		SHA256: file.SHA256,
+		Bytes:  file.Size,
+		Fields: map[string]string{"server": c.Host, "localPath": file.LocalPath},
+	})
+	return nil
+}
+
+/*
+Remove deletes files fetched earlier with FetchOptions.Keep from the server,
+once the caller has safely processed them. A file already gone counts as
+removed.
+
+Returns:
+  - The files removed.
+  - An error if connecting fails or, joined, the deletions that failed.
+*/
+func Remove(ctx context.Context, conn Conn, files []File) ([]File, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	s, err := conn.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	var removed []File
+	var errs []error
+	for _, f := range files {
+		if err := conn.removeRemote(s.client, f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, f)
+	}
+	return removed, errors.Join(errs...)
+}
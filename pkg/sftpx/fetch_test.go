@@ -0,0 +1,87 @@
+package sftpx
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fileInfo is a remote directory entry.
+type fileInfo struct {
+	name    string
+	modTime time.Time
+	dir     bool
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() fs.FileMode  { return 0o644 }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
+func (f fileInfo) IsDir() bool        { return f.dir }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+// names returns the names of entries.
+func names(entries []os.FileInfo) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Name()
+	}
+	return out
+}
+
+// TestSelectFiles verifies both listing orders, that directories are skipped
+// and that a cursor resumes after the file it names.
+func TestSelectFiles(t *testing.T) {
+	t0 := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	entries := []os.FileInfo{
+		fileInfo{name: "c.edi", modTime: t0},
+		fileInfo{name: "archive", modTime: t0, dir: true},
+		fileInfo{name: "a.edi", modTime: t0.Add(2 * time.Minute)},
+		fileInfo{name: "b.edi", modTime: t0.Add(time.Minute)},
+	}
+
+	tests := []struct {
+		name string
+		opts FetchOptions
+		want []string
+	}{
+		{"by name", FetchOptions{}, []string{"a.edi", "b.edi", "c.edi"}},
+		{"by mtime", FetchOptions{SortBy: SortByModTime}, []string{"c.edi", "b.edi", "a.edi"}},
+		{"after name", FetchOptions{After: "a.edi"}, []string{"b.edi", "c.edi"}},
+	}
+	for _, tt := range tests {
+		if got := names(tt.opts.selectFiles(entries)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: selectFiles() = %v; expected %v", tt.name, got, tt.want)
+		}
+	}
+
+	opts := FetchOptions{SortBy: SortByModTime}
+	opts.After = opts.cursor(entries[3])
+	if got := names(opts.selectFiles(entries)); !reflect.DeepEqual(got, []string{"a.edi"}) {
+		t.Errorf("after mtime cursor: selectFiles() = %v; expected [a.edi]", got)
+	}
+}
+
+// TestResumeRefetchesFailed verifies that when the second of three files
+// fails, the cursor stops before it and the next listing fetches it again.
+func TestResumeRefetchesFailed(t *testing.T) {
+	entries := []os.FileInfo{fileInfo{name: "a.edi"}, fileInfo{name: "b.edi"}, fileInfo{name: "c.edi"}}
+	var opts FetchOptions
+	var fetched []File
+	for _, e := range opts.selectFiles(entries) {
+		fetched = append(fetched, File{RemotePath: e.Name(), Cursor: opts.cursor(e)})
+	}
+
+	opts.After = Resume(opts.After, fetched, []bool{true, false, true})
+	if got := names(opts.selectFiles(entries)); !reflect.DeepEqual(got, []string{"b.edi", "c.edi"}) {
+		t.Errorf("next run lists %v; expected [b.edi c.edi]", got)
+	}
+	if got := Resume("", fetched, []bool{false, true, true}); got != "" {
+		t.Errorf("Resume() = %q after the first file failed; expected the cursor unchanged", got)
+	}
+	if got := Resume("", fetched, []bool{true, true, true}); got != "c.edi" {
+		t.Errorf("Resume() = %q; expected c.edi", got)
+	}
+}
@@ -0,0 +1,153 @@
+// pkg/sftpx/sftpx.go
+package sftpx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+Conn describes how to reach and log in to an SFTP server. Every operation of
+this package (Fetch, Remove, Upload, Check) takes a Conn and its own options
+struct, so new settings are added as fields without breaking callers. The
+zero value of each optional field keeps the plain behavior.
+
+Fields:
+  - Host:                SFTP server hostname or IP.
+  - Port:                SFTP port (usually 22).
+  - Username:            Username for SSH authentication.
+  - PrivateKeyPath:      Path to the SSH private key file.
+  - PrivateKey:          Key material used instead of PrivateKeyPath (edi.privateKey).
+  - Passphrase:          Passphrase of encrypted keys, inline or in files (edi.privateKeyPassphrase).
+  - FallbackKeyPath:     Second key offered after the first, while a rotated key is not yet active (edi.previousKeyPath).
+  - KeyboardInteractive: Answers keyboard-interactive prompts after the keys; see utils.SFTPPromptChallenge.
+  - Retry:               Retry policy (and circuit breaker) for dialing; the zero policy dials once.
+  - CreateOutboundDir:   Create a missing outbound directory (edi.createOutboundDir).
+  - DiscoverDirs:        Fall back to Amazon's standard "download" and "upload" directories
+    when the configured ones do not exist (edi.discoverDirs).
+  - Logger:              Receives notices and debug output of ModuleSFTP; nil discards them.
+  - Permissions:         Mode and ownership of downloaded files; nil uses utils.DefaultPermissions.
+*/
+type Conn struct {
+	Host                string
+	Port                int
+	Username            string
+	PrivateKeyPath      string
+	PrivateKey          []byte
+	Passphrase          string
+	FallbackKeyPath     string
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge
+	Retry               utils.RetryPolicy
+	CreateOutboundDir   bool
+	DiscoverDirs        bool
+	Logger              *utils.Logger
+	Permissions         *utils.Permissions
+}
+
+/*
+auth loads PrivateKey, or the key at PrivateKeyPath, followed by the fallback
+key, if any. An unreadable fallback key is reported and skipped.
+Keyboard-interactive authentication follows the keys when set, so servers
+requiring a key and a one-time code accept the login.
+*/
+func (c Conn) auth() ([]ssh.AuthMethod, error) {
+	key, source := c.PrivateKey, "edi.privateKey"
+	if len(key) == 0 {
+		var err error
+		if key, err = os.ReadFile(c.PrivateKeyPath); err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		source = c.PrivateKeyPath
+	}
+	passphrase := []byte(c.Passphrase)
+	signer, err := utils.ParseSSHKey(key, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signers := []ssh.Signer{signer}
+	c.Logger.Debugf(utils.ModuleSFTP, "Offering key: ", "%s (%s)", source, ssh.FingerprintSHA256(signer.PublicKey()))
+
+	if c.FallbackKeyPath != "" && c.FallbackKeyPath != source {
+		key, err := os.ReadFile(c.FallbackKeyPath)
+		if err == nil {
+			signer, err = utils.ParseSSHKey(key, passphrase)
+		}
+		if err != nil {
+			c.Logger.Log(utils.LevelWarn, "Skipping fallback SFTP key: ", err.Error())
+		} else {
+			signers = append(signers, signer)
+			c.Logger.Debugf(utils.ModuleSFTP, "Offering fallback key: ", "%s (%s)", c.FallbackKeyPath, ssh.FingerprintSHA256(signer.PublicKey()))
+		}
+	}
+	methods := []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+	if c.KeyboardInteractive != nil {
+		methods = append(methods, ssh.KeyboardInteractive(c.KeyboardInteractive))
+	}
+	return methods, nil
+}
+
+// session is an SFTP session together with the SSH connection carrying it.
+type session struct {
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// Close ends the SFTP session and the SSH connection.
+func (s *session) Close() {
+	s.client.Close()
+	s.conn.Close()
+}
+
+/*
+open logs in to the server, retrying the dial per c.Retry, and starts an
+SFTP session. Errors are wrapped with the step that failed.
+*/
+func (c Conn) open(ctx context.Context) (*session, error) {
+	auth, err := c.auth()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ssh.ClientConfig{
+		User:            c.Username,
+		Auth:            auth,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}
+
+	var conn *ssh.Client
+	err = utils.Retry(ctx, c.Retry, func(context.Context) (err error) {
+		c.Logger.Debugf(utils.ModuleSFTP, "Dialing: ", "%s@%s:%d", c.Username, c.Host, c.Port)
+		conn, err = ssh.Dial("tcp", fmt.Sprintf("%s:%d", c.Host, c.Port), cfg)
+		if err != nil {
+			c.Logger.Debugf(utils.ModuleSFTP, "Dial failed: ", "%v", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial SSH: %w", err)
+	}
+	c.Logger.Debugf(utils.ModuleSFTP, "Connected: ", "%s (%s)", conn.RemoteAddr(), conn.ServerVersion())
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+	return &session{conn: conn, client: client}, nil
+}
+
+// recordAudit records e in the default audit trail. The remote action it
+// describes has already happened, so a failed write is reported but not
+// returned.
+func (c Conn) recordAudit(e audit.Event) {
+	if err := audit.Record(e); err != nil {
+		c.Logger.Log(utils.LevelError, "Audit log write failed: ", err.Error())
+	}
+}
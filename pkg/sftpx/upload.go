@@ -0,0 +1,94 @@
+// pkg/sftpx/upload.go
+package sftpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+	"github.com/pkg/sftp"
+)
+
+/*
+UploadOptions describes a file Upload creates on the server. Its contents are
+Data or, for large documents (such as a feed with hundreds of thousands of
+rows) that should never be built in memory, whatever Write streams. Writes
+are buffered, so Write may emit many small rows cheaply.
+
+Fields:
+  - RemoteDir: Directory on the server, relative to the home directory (e.g. "upload").
+  - FileName:  Name of the file to create (".gz" is added when Gzip is set).
+  - Data:      Contents of the file, used when Write is nil.
+  - Write:     Writes the file contents to the given writer.
+  - Gzip:      Compress the contents with gzip (only if the partner accepts it).
+*/
+type UploadOptions struct {
+	RemoteDir string
+	FileName  string
+	Data      []byte
+	Write     func(w io.Writer) error
+	Gzip      bool
+}
+
+/*
+Upload creates opts.FileName in opts.RemoteDir on the server and writes its
+contents straight into the remote file. A missing directory is discovered or
+created as set in conn. The upload is recorded in the audit trail.
+
+Returns:
+  - An error if connecting, creating the file, writing or the final flush fails.
+*/
+func Upload(ctx context.Context, conn Conn, opts UploadOptions) error {
+	s, err := conn.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return conn.upload(s.client, opts)
+}
+
+// upload creates and writes the file of opts through client.
+func (c Conn) upload(client *sftp.Client, opts UploadOptions) error {
+	write := opts.Write
+	if write == nil {
+		write = func(w io.Writer) error {
+			_, err := w.Write(opts.Data)
+			return err
+		}
+	}
+	fileName := opts.FileName
+	if opts.Gzip && !strings.HasSuffix(fileName, ".gz") {
+		fileName += ".gz"
+	}
+	remoteDir, err := c.resolveDir(client, opts.RemoteDir, OutboundDirName, true)
+	if err != nil {
+		return err
+	}
+	remotePath := path.Join(remoteDir, fileName)
+
+	f, err := client.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("create remote file %s: %w", remotePath, err)
+	}
+	hashed := audit.NewHasher(f)
+	if err := utils.WriteStream(hashed, opts.Gzip, write); err != nil {
+		f.Close()
+		return fmt.Errorf("write remote file %s: %w", remotePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close remote file %s: %w", remotePath, err)
+	}
+	c.Logger.Debugf(utils.ModuleSFTP, "Uploaded: ", "%s (%d bytes)", remotePath, hashed.Bytes())
+	c.recordAudit(audit.Event{
+		Action: audit.SFTPUpload,
+		Target: remotePath,
+		SHA256: hashed.Sum(),
+		Bytes:  hashed.Bytes(),
+		Fields: map[string]string{"server": c.Host},
+	})
+	return nil
+}
@@ -0,0 +1,93 @@
+package sftpx
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// memClient returns a client of an in-memory SFTP server holding an "upload"
+// directory.
+func memClient(t *testing.T) *sftp.Client {
+	t.Helper()
+	local, remote := net.Pipe()
+	server := sftp.NewRequestServer(remote, sftp.InMemHandler())
+	go server.Serve()
+	client, err := sftp.NewClientPipe(local, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	if err := client.Mkdir("upload"); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestUpload verifies plain and gzip uploads, streamed and from Data, and
+// that ".gz" is added to gzip file names exactly once.
+func TestUpload(t *testing.T) {
+	rows := func(w io.Writer) error {
+		for i := 0; i < 1000; i++ {
+			if _, err := fmt.Fprintf(w, "row %d\n", i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var want string
+	for i := 0; i < 1000; i++ {
+		want += fmt.Sprintf("row %d\n", i)
+	}
+
+	tests := []struct {
+		name string
+		opts UploadOptions
+		path string
+	}{
+		{"data", UploadOptions{RemoteDir: "upload", FileName: "feed.txt", Data: []byte(want)}, "upload/feed.txt"},
+		{"stream", UploadOptions{RemoteDir: "upload", FileName: "feed.csv", Write: rows}, "upload/feed.csv"},
+		{"gzip", UploadOptions{RemoteDir: "upload", FileName: "feed.csv", Write: rows, Gzip: true}, "upload/feed.csv.gz"},
+		{"gzip named", UploadOptions{RemoteDir: "/upload", FileName: "named.csv.gz", Data: []byte(want), Gzip: true}, "upload/named.csv.gz"},
+	}
+	client := memClient(t)
+	for _, tt := range tests {
+		if err := (Conn{}).upload(client, tt.opts); err != nil {
+			t.Errorf("%s: upload() returned %v", tt.name, err)
+			continue
+		}
+		f, err := client.Open(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var r io.Reader = f
+		if tt.opts.Gzip {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Errorf("%s: not gzip: %v", tt.name, err)
+				f.Close()
+				continue
+			}
+		}
+		got, err := io.ReadAll(r)
+		f.Close()
+		if err != nil || string(got) != want {
+			t.Errorf("%s: %s holds %d bytes (%v); expected the %d written", tt.name, tt.path, len(got), err, len(want))
+		}
+	}
+
+	failing := UploadOptions{RemoteDir: "upload", FileName: "broken.csv", Write: func(w io.Writer) error { return fmt.Errorf("query failed") }}
+	if err := (Conn{}).upload(client, failing); err == nil {
+		t.Error("upload() succeeded although Write failed")
+	}
+	if err := (Conn{}).upload(client, UploadOptions{RemoteDir: "missing", FileName: "x"}); err == nil {
+		t.Error("upload() into a missing directory succeeded")
+	}
+}
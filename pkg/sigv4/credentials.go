@@ -0,0 +1,343 @@
+// pkg/sigv4/credentials.go
+package sigv4
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Provider supplies AWS credentials.
+*/
+type Provider interface {
+	// Retrieve returns the current credentials.
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// ErrNoCredentials is returned (wrapped) when a provider has no credentials to offer.
+var ErrNoCredentials = errors.New("no AWS credentials found")
+
+/*
+Env reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+*/
+type Env struct{}
+
+// Retrieve returns the keys of the environment.
+func (Env) Retrieve(ctx context.Context) (Credentials, error) {
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return Credentials{}, fmt.Errorf("env: %w", ErrNoCredentials)
+	}
+	return Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN"), Source: "env"}, nil
+}
+
+/*
+Profile reads static keys (aws_access_key_id, aws_secret_access_key,
+aws_session_token) of a named profile from the shared credentials file and,
+failing that, the shared config file, as written by `aws configure`.
+
+Fields:
+  - Name:            Profile name; empty means AWS_PROFILE or "default".
+  - CredentialsFile: Empty means AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+  - ConfigFile:      Empty means AWS_CONFIG_FILE or ~/.aws/config.
+*/
+type Profile struct {
+	Name            string
+	CredentialsFile string
+	ConfigFile      string
+}
+
+// Retrieve returns the keys of the profile.
+func (p Profile) Retrieve(ctx context.Context) (Credentials, error) {
+	name := p.Name
+	if name == "" {
+		if name = os.Getenv("AWS_PROFILE"); name == "" {
+			name = "default"
+		}
+	}
+	home, _ := os.UserHomeDir()
+	files := []struct {
+		path, env, def, section string
+	}{
+		// The config file prefixes every profile but the default one with "profile ".
+		{p.CredentialsFile, "AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, ".aws", "credentials"), name},
+		{p.ConfigFile, "AWS_CONFIG_FILE", filepath.Join(home, ".aws", "config"), "profile " + name},
+	}
+	if name == "default" {
+		files[1].section = "default"
+	}
+	for _, f := range files {
+		path := f.path
+		if path == "" {
+			if path = os.Getenv(f.env); path == "" {
+				path = f.def
+			}
+		}
+		values, err := readINISection(path, f.section)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if values["aws_access_key_id"] != "" && values["aws_secret_access_key"] != "" {
+			return Credentials{
+				AccessKeyID:     values["aws_access_key_id"],
+				SecretAccessKey: values["aws_secret_access_key"],
+				SessionToken:    values["aws_session_token"],
+				Source:          "profile " + name,
+			}, nil
+		}
+	}
+	return Credentials{}, fmt.Errorf("profile %s: %w", name, ErrNoCredentials)
+}
+
+/*
+readINISection returns the key/value pairs of one section of an INI file.
+A missing file yields no values.
+*/
+func readINISection(path, section string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	in := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			in = strings.Join(strings.Fields(line[1:len(line)-1]), " ") == section
+		case in:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				values[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+/*
+Instance reads the role credentials of the host: those of an ECS task when
+AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI
+is set, otherwise those of the EC2 instance profile via IMDSv2.
+
+Fields:
+  - HTTP:     HTTP client; nil means one with a 2s timeout.
+  - Endpoint: IMDS base URL; empty means AWS_EC2_METADATA_SERVICE_ENDPOINT or http://169.254.169.254.
+*/
+type Instance struct {
+	HTTP     *http.Client
+	Endpoint string
+}
+
+// Retrieve returns the role credentials of the container or instance.
+func (m Instance) Retrieve(ctx context.Context) (Credentials, error) {
+	client := m.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return containerCredentials(ctx, client, "http://169.254.170.2"+uri)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return containerCredentials(ctx, client, uri)
+	}
+
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		if endpoint = os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); endpoint == "" {
+			endpoint = "http://169.254.169.254"
+		}
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	token, err := metadata(ctx, client, http.MethodPut, endpoint+"/latest/api/token", "X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w: %v", ErrNoCredentials, err)
+	}
+	list := endpoint + "/latest/meta-data/iam/security-credentials/"
+	roles, err := metadata(ctx, client, http.MethodGet, list, "X-Aws-Ec2-Metadata-Token", token)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w: %v", ErrNoCredentials, err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return Credentials{}, fmt.Errorf("instance metadata: %w: no instance role", ErrNoCredentials)
+	}
+	body, err := metadata(ctx, client, http.MethodGet, list+role, "X-Aws-Ec2-Metadata-Token", token)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	creds, err := decodeRoleCredentials([]byte(body))
+	creds.Source = "instance role " + role
+	return creds, err
+}
+
+// containerCredentials fetches the credentials of an ECS task role.
+func containerCredentials(ctx context.Context, client *http.Client, uri string) (Credentials, error) {
+	header, value := "", ""
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		header, value = "Authorization", token
+	}
+	body, err := metadata(ctx, client, http.MethodGet, uri, header, value)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("container credentials: %w", err)
+	}
+	creds, err := decodeRoleCredentials([]byte(body))
+	creds.Source = "container role"
+	return creds, err
+}
+
+// metadata sends one request to a metadata endpoint and returns the body.
+func metadata(ctx context.Context, client *http.Client, method, url, header, value string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s: status %d", method, url, resp.StatusCode)
+	}
+	return string(body), nil
+}
+
+// decodeRoleCredentials parses the credentials document of IMDS and ECS.
+func decodeRoleCredentials(body []byte) (Credentials, error) {
+	var doc struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return Credentials{}, fmt.Errorf("invalid role credentials: %w", err)
+	}
+	if doc.AccessKeyID == "" || doc.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("role credentials lack keys")
+	}
+	return Credentials{AccessKeyID: doc.AccessKeyID, SecretAccessKey: doc.SecretAccessKey, SessionToken: doc.Token, Expires: doc.Expiration}, nil
+}
+
+/*
+Chain tries its providers in order and returns the first credentials found.
+Errors other than ErrNoCredentials stop the search.
+*/
+type Chain []Provider
+
+// Retrieve returns the credentials of the first provider that has some.
+func (c Chain) Retrieve(ctx context.Context) (Credentials, error) {
+	var tried []string
+	for _, p := range c {
+		creds, err := p.Retrieve(ctx)
+		if err == nil {
+			return creds, nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			return Credentials{}, err
+		}
+		tried = append(tried, err.Error())
+	}
+	return Credentials{}, fmt.Errorf("%w (%s)", ErrNoCredentials, strings.Join(tried, "; "))
+}
+
+/*
+Default returns the provider used without an explicit profile, in the order
+of the AWS SDKs: environment variables, the AWS_PROFILE (or default) profile
+of the shared files, then the container or instance role. With a profile name
+only that profile is read.
+*/
+func Default(profile string) Provider {
+	if profile != "" {
+		return Profile{Name: profile}
+	}
+	return Chain{Env{}, Profile{}, Instance{}}
+}
+
+// DefaultRefreshWindow is how long before they expire Cache replaces temporary credentials.
+const DefaultRefreshWindow = 5 * time.Minute
+
+/*
+Cache keeps the credentials of Provider and retrieves new ones once temporary
+credentials are within Window of expiring, so an assumed role is assumed again
+before its session (1h by default) ends. Long-term keys are kept until
+Invalidate. If a refresh fails while the cached credentials are still valid,
+they are used and the refresh is tried again on the next call.
+
+Fields:
+  - Provider: Source of the credentials, e.g. an AssumeRole.
+  - Window:   Refresh this long before expiry; 0 means DefaultRefreshWindow.
+  - Logger:   Receives failed refreshes and, at debug level, retrieved credentials; nil discards them.
+*/
+type Cache struct {
+	Provider Provider
+	Window   time.Duration
+	Logger   *utils.Logger
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// Retrieve returns the cached credentials, retrieving them on first use and
+// when they are about to expire.
+func (c *Cache) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	window := c.Window
+	if window <= 0 {
+		window = DefaultRefreshWindow
+	}
+	now := time.Now()
+	if c.creds != nil && (c.creds.Expires.IsZero() || c.creds.Expires.Sub(now) > window) {
+		return *c.creds, nil
+	}
+	creds, err := c.Provider.Retrieve(ctx)
+	if err != nil {
+		if c.creds != nil && c.creds.Expires.After(now) {
+			c.Logger.Log(utils.LevelWarn, "Failed to refresh AWS credentials, using the current ones: ", fmt.Sprintf("%v (expire %s)", err, c.creds.Expires.Format(time.RFC3339)))
+			return *c.creds, nil
+		}
+		return Credentials{}, err
+	}
+	if creds.Expires.IsZero() {
+		c.Logger.Debugf(utils.ModuleSPAPI, "AWS credentials: ", "%s", creds.Source)
+	} else {
+		c.Logger.Debugf(utils.ModuleSPAPI, "AWS credentials: ", "%s, expire %s", creds.Source, creds.Expires.Format(time.RFC3339))
+	}
+	c.creds = &creds
+	return creds, nil
+}
+
+/*
+Invalidate drops the cached credentials, so the next Retrieve fetches new ones.
+*/
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creds = nil
+}
@@ -0,0 +1,277 @@
+// pkg/sigv4/sigv4.go
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// Formats of the X-Amz-Date header and the credential scope date.
+const (
+	amzDateFormat   = "20060102T150405Z"
+	scopeDateFormat = "20060102"
+)
+
+/*
+Credentials are AWS access keys.
+
+Fields:
+  - AccessKeyID:     The access key ID.
+  - SecretAccessKey: The secret access key.
+  - SessionToken:    Session token of temporary credentials (assumed roles, instance roles).
+  - Expires:         When temporary credentials expire; zero for long-term keys.
+  - Source:          Where the credentials came from, for log output (e.g. "env", "profile default").
+*/
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+	Source          string
+}
+
+/*
+Sign adds AWS Signature Version 4 headers (X-Amz-Date, X-Amz-Security-Token
+for temporary credentials, and Authorization) to req. The host, every x-amz-*
+header and Content-Type are signed.
+
+Parameters:
+  - req:     The request; its body is not read.
+  - payload: The request body (nil for none).
+  - creds:   Keys to sign with.
+  - region:  AWS region of the endpoint, e.g. "us-east-1".
+  - service: Signing name of the service, e.g. "execute-api" or "sts".
+  - now:     Signing time.
+*/
+func Sign(req *http.Request, payload []byte, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			headers[name] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	sum := sha256.Sum256(payload)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req),
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+
+	scope := strings.Join([]string{now.Format(scopeDateFormat), region, service, "aws4_request"}, "/")
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format(scopeDateFormat))
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalPath encodes the already escaped path once more, as services other
+// than S3 expect.
+func canonicalPath(req *http.Request) string {
+	p := req.URL.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	return uriEncode(p, false)
+}
+
+// canonicalQuery sorts and encodes the query parameters.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters
+// and, unless encodeSlash is set, '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+/*
+Settings configure a Transport.
+
+Fields:
+  - Host:        Only requests to this host are signed (e.g. the SP‑API endpoint,
+    not LWA or presigned document URLs); empty disables signing.
+  - Region:      AWS region of Host, e.g. "us-east-1".
+  - Service:     Signing name, e.g. "execute-api".
+  - Credentials: Supplies the keys for each request.
+*/
+type Settings struct {
+	Host        string
+	Region      string
+	Service     string
+	Credentials Provider
+}
+
+/*
+Transport is an http.RoundTripper that signs requests to the configured host
+with SigV4 and sends the rest unchanged. Re-signed requests are reported to
+Logger (nil discards them). The zero Transport signs nothing and sends through
+http.DefaultTransport.
+*/
+type Transport struct {
+	Base   http.RoundTripper
+	Logger *utils.Logger
+
+	mu       sync.Mutex
+	settings Settings
+}
+
+/*
+Configure replaces the settings.
+*/
+func (t *Transport) Configure(s Settings) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settings = s
+}
+
+/*
+RoundTrip signs a copy of req when it goes to the configured host and sends it
+through Base. If the response says the credentials expired (e.g. a session
+that ended before its stated expiry, or a clock skew), cached credentials are
+invalidated and the request is signed and sent once more.
+
+Returns:
+  - An error if the credentials cannot be retrieved or the body not read.
+*/
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t.mu.Lock()
+	s := t.settings
+	t.mu.Unlock()
+	if s.Host == "" || s.Credentials == nil || !strings.EqualFold(req.URL.Host, s.Host) {
+		return base.RoundTrip(req)
+	}
+
+	var payload []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		payload, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		creds, err := s.Credentials.Retrieve(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AWS credentials for signing: %w", err)
+		}
+		signed := req.Clone(req.Context())
+		if payload != nil {
+			signed.Body = io.NopCloser(bytes.NewReader(payload))
+		}
+		Sign(signed, payload, creds, s.Region, s.Service, time.Now())
+		resp, err := base.RoundTrip(signed)
+		inv, refreshable := s.Credentials.(interface{ Invalidate() })
+		if err != nil || attempt > 1 || !refreshable || !expired(resp) {
+			return resp, err
+		}
+		t.Logger.LogContext(req.Context(), utils.LevelWarn, "AWS credentials expired, retrieving new ones: ", creds.Source)
+		inv.Invalidate()
+	}
+}
+
+// expiredCodes are error messages of requests signed with expired credentials.
+var expiredCodes = []string{"ExpiredToken", "security token included in the request is expired", "Signature expired"}
+
+/*
+expired reports whether resp rejects expired credentials. The body of a 403
+is inspected and consumed unless it matches; otherwise it is restored.
+*/
+func expired(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err == nil {
+		for _, code := range expiredCodes {
+			if bytes.Contains(body, []byte(code)) {
+				resp.Body.Close()
+				return true
+			}
+		}
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	return false
+}
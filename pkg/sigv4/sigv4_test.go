@@ -0,0 +1,207 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCreds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+// TestSignVanilla checks Sign against the get-vanilla case of the AWS SigV4 test suite.
+func TestSignVanilla(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	Sign(req, nil, testCreds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nexpected %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+}
+
+// TestTransportSignsOnlyConfiguredHost verifies requests to other hosts (LWA,
+// presigned documents) pass unsigned and signed ones carry the session token.
+func TestTransportSignsOnlyConfiguredHost(t *testing.T) {
+	var auth, token []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		token = append(token, r.Header.Get("X-Amz-Security-Token"))
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(body) != `{"a":1}` {
+			t.Errorf("body = %q; expected it forwarded", body)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	creds := testCreds
+	creds.SessionToken = "session"
+	transport := &Transport{}
+	transport.Configure(Settings{Host: u.Host, Region: "us-east-1", Service: "execute-api", Credentials: &Cache{Provider: static(creds)}})
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post(srv.URL+"/vendor/orders/v1/acknowledgements", "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Post() returned %v", err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get("http://localhost:" + u.Port() + "/doc")
+	if err != nil {
+		t.Fatalf("Get() returned %v", err)
+	}
+	resp.Body.Close()
+
+	if !strings.HasPrefix(auth[0], "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth[0], "x-amz-security-token") || token[0] != "session" {
+		t.Errorf("signed request has Authorization %q, token %q", auth[0], token[0])
+	}
+	if auth[1] != "" || token[1] != "" {
+		t.Errorf("request to another host was signed: %q", auth[1])
+	}
+}
+
+// TestAssumeRole verifies the STS call carries the role and external ID and
+// that the temporary credentials are parsed.
+func TestAssumeRole(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRole" || r.Form.Get("RoleArn") != "arn:aws:iam::1:role/spapi" || r.Form.Get("ExternalId") != "ext" {
+			t.Errorf("form = %v", r.Form)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/sts/aws4_request") {
+			t.Errorf("Authorization = %s; expected an sts scope", r.Header.Get("Authorization"))
+		}
+		io.WriteString(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>tok</SessionToken>
+<Expiration>2030-01-02T03:04:05Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	}))
+	defer srv.Close()
+
+	role := &AssumeRole{Source: static(testCreds), RoleARN: "arn:aws:iam::1:role/spapi", ExternalID: "ext", Region: "eu-west-1", Endpoint: srv.URL}
+	creds, err := role.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() returned %v", err)
+	}
+	if creds.AccessKeyID != "ASIATEMP" || creds.SessionToken != "tok" || !creds.Expires.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("credentials = %+v", creds)
+	}
+}
+
+// TestAssumeRoleError verifies STS errors are reported with their code.
+func TestAssumeRoleError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`)
+	}))
+	defer srv.Close()
+
+	role := &AssumeRole{Source: static(testCreds), RoleARN: "arn:aws:iam::1:role/spapi", Region: "us-east-1", Endpoint: srv.URL}
+	if _, err := role.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "AccessDenied: not authorized") {
+		t.Errorf("Retrieve() returned %v; expected the STS error", err)
+	}
+}
+
+// TestProfile verifies keys are read from the credentials file and the
+// "profile <name>" sections of the config file.
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	credsFile, configFile := filepath.Join(dir, "credentials"), filepath.Join(dir, "config")
+	os.WriteFile(credsFile, []byte("[default]\naws_access_key_id = AKIDEFAULT\naws_secret_access_key = s1\n"), 0o600)
+	os.WriteFile(configFile, []byte("[profile spapi]\nregion = eu-west-1\naws_access_key_id=AKISPAPI\naws_secret_access_key=s2\n"), 0o600)
+
+	for name, want := range map[string]string{"default": "AKIDEFAULT", "spapi": "AKISPAPI"} {
+		creds, err := Profile{Name: name, CredentialsFile: credsFile, ConfigFile: configFile}.Retrieve(context.Background())
+		if err != nil || creds.AccessKeyID != want {
+			t.Errorf("profile %s = %+v, %v; expected %s", name, creds, err, want)
+		}
+	}
+	_, err := Profile{Name: "missing", CredentialsFile: credsFile, ConfigFile: configFile}.Retrieve(context.Background())
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("missing profile returned %v; expected ErrNoCredentials", err)
+	}
+}
+
+// TestCacheRefreshesBeforeExpiry verifies temporary credentials are replaced
+// within the refresh window and kept when a refresh fails while still valid.
+func TestCacheRefreshesBeforeExpiry(t *testing.T) {
+	p := &sequence{creds: []Credentials{
+		{AccessKeyID: "first", Expires: time.Now().Add(2 * time.Minute)},
+		{AccessKeyID: "second", Expires: time.Now().Add(time.Hour)},
+	}}
+	cache := &Cache{Provider: p}
+	for _, want := range []string{"first", "second", "second"} {
+		creds, err := cache.Retrieve(context.Background())
+		if err != nil || creds.AccessKeyID != want {
+			t.Fatalf("Retrieve() = %+v, %v; expected %s", creds, err, want)
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("provider called %d times; expected 2", p.calls)
+	}
+
+	failing := &sequence{creds: []Credentials{{AccessKeyID: "valid", Expires: time.Now().Add(time.Minute)}}}
+	cache = &Cache{Provider: failing}
+	cache.Retrieve(context.Background())
+	if creds, err := cache.Retrieve(context.Background()); err != nil || creds.AccessKeyID != "valid" {
+		t.Errorf("Retrieve() after a failed refresh = %+v, %v; expected the still valid credentials", creds, err)
+	}
+}
+
+// TestTransportRetriesExpiredToken verifies a request rejected for expired
+// credentials is signed again with new ones.
+func TestTransportRetriesExpiredToken(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Amz-Security-Token"))
+		if r.Header.Get("X-Amz-Security-Token") == "old" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":[{"code":"Unauthorized","message":"The security token included in the request is expired"}]}`)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	p := &sequence{creds: []Credentials{
+		{AccessKeyID: "a", SecretAccessKey: "s", SessionToken: "old", Expires: time.Now().Add(time.Hour)},
+		{AccessKeyID: "b", SecretAccessKey: "s", SessionToken: "new", Expires: time.Now().Add(time.Hour)},
+	}}
+	transport := &Transport{}
+	transport.Configure(Settings{Host: u.Host, Region: "us-east-1", Service: "execute-api", Credentials: &Cache{Provider: p}})
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL + "/vendor/orders/v1/purchaseOrders")
+	if err != nil {
+		t.Fatalf("Get() returned %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.Join(keys, ",") != "old,new" {
+		t.Errorf("status %d after tokens %v; expected a retry with the new token", resp.StatusCode, keys)
+	}
+}
+
+// sequence is a Provider returning its credentials in turn, then failing.
+type sequence struct {
+	creds []Credentials
+	calls int
+}
+
+func (s *sequence) Retrieve(ctx context.Context) (Credentials, error) {
+	if s.calls >= len(s.creds) {
+		return Credentials{}, errors.New("provider unavailable")
+	}
+	s.calls++
+	return s.creds[s.calls-1], nil
+}
+
+// static is a Provider returning fixed credentials.
+type static Credentials
+
+func (s static) Retrieve(ctx context.Context) (Credentials, error) { return Credentials(s), nil }
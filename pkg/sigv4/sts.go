@@ -0,0 +1,127 @@
+// pkg/sigv4/sts.go
+package sigv4
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSessionName is the role session name used when none is configured.
+const DefaultSessionName = "avcimporter"
+
+/*
+AssumeRole obtains temporary credentials for a role with STS AssumeRole,
+signing the call with the credentials of Source.
+
+Fields:
+  - Source:      Credentials allowed to assume the role, e.g. the instance role.
+  - RoleARN:     ARN of the role to assume.
+  - ExternalID:  External ID the role's trust policy requires, if any.
+  - SessionName: Role session name shown in CloudTrail; empty means DefaultSessionName.
+  - Region:      STS region; the regional endpoint https://sts.<region>.amazonaws.com is called.
+  - Duration:    Requested lifetime of the credentials; 0 leaves the role's default (1h).
+  - Endpoint:    STS URL replacing the regional endpoint (tests, VPC endpoints).
+  - HTTP:        HTTP client; nil means http.DefaultClient.
+*/
+type AssumeRole struct {
+	Source      Provider
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	Region      string
+	Duration    time.Duration
+	Endpoint    string
+	HTTP        *http.Client
+}
+
+/*
+Retrieve assumes the role.
+
+Returns:
+  - The role's temporary credentials.
+  - An error if the source credentials are missing or STS refuses, carrying STS's error code and message.
+*/
+func (a *AssumeRole) Retrieve(ctx context.Context) (Credentials, error) {
+	source, err := a.Source.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to get credentials to assume %s: %w", a.RoleARN, err)
+	}
+	session := a.SessionName
+	if session == "" {
+		session = DefaultSessionName
+	}
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {a.RoleARN},
+		"RoleSessionName": {session},
+	}
+	if a.ExternalID != "" {
+		form.Set("ExternalId", a.ExternalID)
+	}
+	if a.Duration > 0 {
+		form.Set("DurationSeconds", strconv.Itoa(int(a.Duration.Seconds())))
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sts." + a.Region + ".amazonaws.com/"
+	}
+	payload := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	Sign(req, payload, source, a.Region, "sts", time.Now())
+
+	client := a.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to assume %s: %w", a.RoleARN, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to assume %s: %w", a.RoleARN, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+			return Credentials{}, fmt.Errorf("failed to assume %s: %s: %s", a.RoleARN, e.Code, e.Message)
+		}
+		return Credentials{}, fmt.Errorf("failed to assume %s: status %d: %s", a.RoleARN, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		AccessKeyID     string    `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string    `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string    `xml:"AssumeRoleResult>Credentials>SessionToken"`
+		Expiration      time.Time `xml:"AssumeRoleResult>Credentials>Expiration"`
+	}
+	if err := xml.Unmarshal(body, &out); err != nil {
+		return Credentials{}, fmt.Errorf("invalid AssumeRole response: %w", err)
+	}
+	if out.AccessKeyID == "" || out.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("AssumeRole response for %s lacks credentials", a.RoleARN)
+	}
+	return Credentials{
+		AccessKeyID:     out.AccessKeyID,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.SessionToken,
+		Expires:         out.Expiration,
+		Source:          "role " + a.RoleARN + " via " + source.Source,
+	}, nil
+}
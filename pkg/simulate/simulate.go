@@ -0,0 +1,206 @@
+// pkg/simulate/simulate.go
+package simulate
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+// AmazonID is the interchange and group ID synthetic orders are sent from.
+const AmazonID = "AMAZON"
+
+// fulfillmentCenters are ship-to locations of synthetic orders.
+var fulfillmentCenters = []string{"ABE8", "AVP1", "BFI4", "CLT2", "DFW7", "LGB8", "MDW2", "ONT8", "PHX6", "RIC2", "SDF8", "TPA1"}
+
+// asinPattern matches ASINs, telling them apart from vendor SKUs.
+var asinPattern = regexp.MustCompile(`^B0[0-9A-Z]{8}$`)
+
+/*
+Product is an item synthetic orders may contain.
+
+Fields:
+  - VendorSKU: Sent as PO1 VN.
+  - ASIN:      Sent as PO1 BP.
+*/
+type Product struct {
+	VendorSKU string
+	ASIN      string
+}
+
+/*
+Products turns the keys of a SKU cross-reference (vendor SKUs or ASINs) into
+products, sorted so a seed always yields the same orders.
+*/
+func Products(skus []string) []Product {
+	products := make([]Product, 0, len(skus))
+	for _, sku := range skus {
+		if asinPattern.MatchString(sku) {
+			products = append(products, Product{ASIN: sku})
+		} else {
+			products = append(products, Product{VendorSKU: sku})
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].VendorSKU+products[i].ASIN < products[j].VendorSKU+products[j].ASIN
+	})
+	return products
+}
+
+/*
+Options control the generated orders.
+
+Fields:
+  - Orders:     Number of purchase orders.
+  - Products:   Items to order (default: 20 made-up SKUs and ASINs).
+  - ReceiverID: The vendor's interchange ID (edi.senderId; default: "VENDOR").
+  - X12:        Delimiters and version of the interchanges.
+  - Usage:      ISA15 (default: "T").
+  - Seed:       Seed of the generator; equal seeds give equal orders.
+  - Now:        Order date (default: the current time).
+*/
+type Options struct {
+	Orders     int
+	Products   []Product
+	ReceiverID string
+	X12        x12.Options
+	Usage      string
+	Seed       int64
+	Now        time.Time
+}
+
+/*
+File is one generated interchange holding a single 850.
+
+Fields:
+  - Name:     Suggested file name.
+  - PONumber: The purchase order number (BEG03).
+  - Data:     The X12 interchange.
+*/
+type File struct {
+	Name     string
+	PONumber string
+	Data     []byte
+}
+
+/*
+Generate850s builds opts.Orders standalone 850 interchanges as Amazon sends
+them: a new stand-alone order (BEG 00/SA) with currency, delivery window,
+ship-to fulfillment center and one to eight PO1 lines, some ordered in cases.
+
+Returns:
+  - The interchanges.
+  - An error if the X12 options are invalid.
+*/
+func Generate850s(opts Options) ([]File, error) {
+	if err := opts.X12.Validate(); err != nil {
+		return nil, err
+	}
+	products := opts.Products
+	if len(products) == 0 {
+		products = sampleProducts()
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	usage := opts.Usage
+	if usage == "" {
+		usage = "T"
+	}
+	if opts.ReceiverID == "" {
+		opts.ReceiverID = "VENDOR"
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	base := 100000000 + r.Intn(800000000)
+
+	files := make([]File, 0, opts.Orders)
+	for i := 0; i < opts.Orders; i++ {
+		po := poNumber(r)
+		control := strconv.Itoa(base + i)
+		at := opts.X12.In(now)
+		segments := []x12.Segment{
+			opts.X12.ISA(x12.ISAHeader{
+				SenderQualifier:   "ZZ",
+				SenderID:          AmazonID,
+				ReceiverQualifier: "ZZ",
+				ReceiverID:        opts.ReceiverID,
+				Time:              now,
+				ControlNumber:     control,
+				Usage:             usage,
+			}),
+			{ID: "GS", Elements: []string{"PO", AmazonID, opts.ReceiverID, at.Format("20060102"), at.Format("1504"), control, "X", opts.X12.GroupVersion()}},
+		}
+		set := orderSegments(r, po, products, at)
+		set = append(set, x12.Segment{ID: "SE", Elements: []string{strconv.Itoa(len(set) + 1), "0001"}})
+		segments = append(segments, set...)
+		segments = append(segments,
+			x12.Segment{ID: "GE", Elements: []string{"1", control}},
+			x12.Segment{ID: "IEA", Elements: []string{"1", control}},
+		)
+		files = append(files, File{
+			Name:     fmt.Sprintf("850_%s_%s.edi", po, control),
+			PONumber: po,
+			Data:     append(opts.X12.Write(segments), '\n'),
+		})
+	}
+	return files, nil
+}
+
+// orderSegments builds the ST through CTT segments of one order.
+func orderSegments(r *rand.Rand, po string, products []Product, at time.Time) []x12.Segment {
+	start := at.AddDate(0, 0, 3+r.Intn(7))
+	end := start.AddDate(0, 0, 7+r.Intn(14))
+	segments := []x12.Segment{
+		{ID: "ST", Elements: []string{"850", "0001"}},
+		{ID: "BEG", Elements: []string{"00", "SA", po, "", at.Format("20060102")}},
+		{ID: "CUR", Elements: []string{"BY", "USD"}},
+		{ID: "DTM", Elements: []string{"064", start.Format("20060102")}},
+		{ID: "DTM", Elements: []string{"063", end.Format("20060102")}},
+		{ID: "N1", Elements: []string{"ST", "", "92", fulfillmentCenters[r.Intn(len(fulfillmentCenters))]}},
+	}
+	lines := 1 + r.Intn(min(8, len(products)))
+	for i, idx := range r.Perm(len(products))[:lines] {
+		p := products[idx]
+		qty, unit, caseSize := 1+r.Intn(48), "EA", 0
+		if r.Intn(4) == 0 {
+			qty, unit, caseSize = 1+r.Intn(10), "CA", []int{6, 12, 24}[r.Intn(3)]
+		}
+		po1 := []string{strconv.Itoa(i + 1), strconv.Itoa(qty), unit, fmt.Sprintf("%.2f", float64(199+r.Intn(9800))/100), "NT"}
+		if p.ASIN != "" {
+			po1 = append(po1, "BP", p.ASIN)
+		}
+		if p.VendorSKU != "" {
+			po1 = append(po1, "VN", p.VendorSKU)
+		}
+		segments = append(segments, x12.Segment{ID: "PO1", Elements: po1})
+		if caseSize > 0 {
+			segments = append(segments, x12.Segment{ID: "PO4", Elements: []string{strconv.Itoa(caseSize)}})
+		}
+	}
+	return append(segments, x12.Segment{ID: "CTT", Elements: []string{strconv.Itoa(lines)}})
+}
+
+// poNumber returns an eight-character purchase order number like Amazon's.
+func poNumber(r *rand.Rand) string {
+	const chars = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// sampleProducts are used when no SKU cross-reference is available.
+func sampleProducts() []Product {
+	products := make([]Product, 20)
+	for i := range products {
+		products[i] = Product{VendorSKU: fmt.Sprintf("SIM-%04d", i+1), ASIN: fmt.Sprintf("B0SIM%05d", i+1)}
+	}
+	return products
+}
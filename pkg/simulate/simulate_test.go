@@ -0,0 +1,64 @@
+package simulate
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/model"
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+// TestGenerate850s verifies the orders have sound envelopes, map through the
+// EDI pipeline's 850 parser and repeat for the same seed.
+func TestGenerate850s(t *testing.T) {
+	opts := Options{
+		Orders:     5,
+		Products:   Products([]string{"WIDGET-1", "B012345678", "GADGET-2"}),
+		ReceiverID: "VENDOR",
+		X12:        x12.DefaultOptions,
+		Seed:       42,
+		Now:        time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+	}
+	files, err := Generate850s(opts)
+	if err != nil {
+		t.Fatalf("Generate850s() returned %v", err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("Generate850s() returned %d files; expected 5", len(files))
+	}
+	for _, f := range files {
+		doc, err := x12.Parse(f.Data)
+		if err != nil {
+			t.Fatalf("%s: Parse() returned %v", f.Name, err)
+		}
+		if issues := doc.ValidateEnvelopes(); len(issues) > 0 {
+			t.Errorf("%s: envelope issues %v", f.Name, issues)
+		}
+		o, err := model.FromX12(doc, 0)
+		if err != nil {
+			t.Fatalf("%s: FromX12() returned %v", f.Name, err)
+		}
+		if o.PONumber != f.PONumber || len(o.Items) == 0 || o.DeliveryWindow == nil || o.ShipToParty == "" {
+			t.Errorf("%s: unexpected order %+v", f.Name, o)
+		}
+		for _, item := range o.Items {
+			if item.Quantity <= 0 || (item.VendorSKU == "" && item.ASIN == "") {
+				t.Errorf("%s: unexpected item %+v", f.Name, item)
+			}
+		}
+	}
+
+	again, _ := Generate850s(opts)
+	if !bytes.Equal(again[0].Data, files[0].Data) {
+		t.Error("Generate850s() differs for the same seed")
+	}
+}
+
+// TestProducts verifies ASINs are told apart from vendor SKUs.
+func TestProducts(t *testing.T) {
+	got := Products([]string{"B012345678", "SKU-1"})
+	if got[0] != (Product{ASIN: "B012345678"}) || got[1] != (Product{VendorSKU: "SKU-1"}) {
+		t.Errorf("Products() = %+v", got)
+	}
+}
@@ -0,0 +1,141 @@
+// pkg/spapi/acknowledgements.go
+package spapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/*
+Acknowledgement codes of an item acknowledgement.
+*/
+const (
+	AckAccepted    = "Accepted"
+	AckBackordered = "Backordered"
+	AckRejected    = "Rejected"
+)
+
+/*
+OrderAcknowledgement confirms a purchase order (Vendor Orders API v1
+submitAcknowledgement).
+
+Fields:
+  - PurchaseOrderNumber: The acknowledged PO.
+  - SellingParty:        Vendor party from the purchase order.
+  - AcknowledgementDate: When the vendor acknowledged the order.
+  - Items:               One entry per purchase order line.
+*/
+type OrderAcknowledgement struct {
+	PurchaseOrderNumber string                     `json:"purchaseOrderNumber"`
+	SellingParty        Party                      `json:"sellingParty"`
+	AcknowledgementDate time.Time                  `json:"acknowledgementDate"`
+	Items               []OrderAcknowledgementItem `json:"items"`
+}
+
+/*
+OrderAcknowledgementItem acknowledges one purchase order line.
+*/
+type OrderAcknowledgementItem struct {
+	ItemSequenceNumber      string                `json:"itemSequenceNumber,omitempty"`
+	AmazonProductIdentifier string                `json:"amazonProductIdentifier,omitempty"`
+	VendorProductIdentifier string                `json:"vendorProductIdentifier,omitempty"`
+	OrderedQuantity         ItemQuantity          `json:"orderedQuantity"`
+	NetCost                 *Money                `json:"netCost,omitempty"`
+	ItemAcknowledgements    []ItemAcknowledgement `json:"itemAcknowledgements"`
+}
+
+/*
+ItemAcknowledgement is the vendor's answer for (part of) a line.
+
+Fields:
+  - AcknowledgementCode:   AckAccepted, AckBackordered or AckRejected.
+  - AcknowledgedQuantity:  Quantity the code applies to.
+  - ScheduledShipDate:     When the goods will ship, if known.
+  - ScheduledDeliveryDate: When the goods will arrive, if known.
+  - RejectionReason:       Why a rejected quantity is rejected (e.g. "TemporarilyUnavailable").
+*/
+type ItemAcknowledgement struct {
+	AcknowledgementCode   string       `json:"acknowledgementCode"`
+	AcknowledgedQuantity  ItemQuantity `json:"acknowledgedQuantity"`
+	ScheduledShipDate     *time.Time   `json:"scheduledShipDate,omitempty"`
+	ScheduledDeliveryDate *time.Time   `json:"scheduledDeliveryDate,omitempty"`
+	RejectionReason       string       `json:"rejectionReason,omitempty"`
+}
+
+/*
+AckLimits bounds one submitAcknowledgement request.
+
+Fields:
+  - MaxOrders: Acknowledgements per request.
+  - MaxItems:  Item lines per request, summed over its acknowledgements.
+  - MaxBytes:  Size of the encoded request body.
+*/
+type AckLimits struct {
+	MaxOrders int
+	MaxItems  int
+	MaxBytes  int
+}
+
+/*
+DefaultAckLimits keeps requests well inside the limits SP‑API enforces on
+submitAcknowledgement payloads.
+*/
+var DefaultAckLimits = AckLimits{MaxOrders: 50, MaxItems: 1000, MaxBytes: 1 << 20}
+
+/*
+ChunkAcknowledgements splits acknowledgements into batches that each respect
+limits, keeping their order. An acknowledgement is never split, since its
+lines must reach Amazon together.
+
+Returns:
+  - The batches.
+  - Acknowledgements that exceed the limits on their own and cannot be submitted.
+*/
+func ChunkAcknowledgements(acks []OrderAcknowledgement, limits AckLimits) (batches [][]OrderAcknowledgement, oversize []OrderAcknowledgement) {
+	// The request wraps the acknowledgements in {"orderAcknowledgements":[...]}.
+	const envelope = len(`{"orderAcknowledgements":[]}`)
+	var batch []OrderAcknowledgement
+	items, size := 0, envelope
+	for _, a := range acks {
+		data, err := json.Marshal(a)
+		n := len(data) + 1 // separating comma
+		if err != nil || len(a.Items) > limits.MaxItems || envelope+n > limits.MaxBytes {
+			oversize = append(oversize, a)
+			continue
+		}
+		if len(batch) > 0 && (len(batch) == limits.MaxOrders || items+len(a.Items) > limits.MaxItems || size+n > limits.MaxBytes) {
+			batches = append(batches, batch)
+			batch, items, size = nil, 0, envelope
+		}
+		batch = append(batch, a)
+		items += len(a.Items)
+		size += n
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, oversize
+}
+
+/*
+SubmitAcknowledgements submits order acknowledgements and returns the
+transaction ID used to check their processing with GetTransaction.
+*/
+func (c *Client) SubmitAcknowledgements(ctx context.Context, acks []OrderAcknowledgement) (string, error) {
+	in := map[string]interface{}{"orderAcknowledgements": acks}
+	var out struct {
+		Payload struct {
+			TransactionID string `json:"transactionId"`
+		} `json:"payload"`
+	}
+	if err := c.call(ctx, "submit_acknowledgement", http.MethodPost, "/vendor/orders/v1/acknowledgements", nil, in, &out); err != nil {
+		return "", err
+	}
+	if out.Payload.TransactionID == "" {
+		return "", fmt.Errorf("submitAcknowledgement response has no transactionId")
+	}
+	return out.Payload.TransactionID, nil
+}
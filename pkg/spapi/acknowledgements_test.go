@@ -0,0 +1,41 @@
+package spapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestChunkAcknowledgements verifies batches respect every limit, keep their
+// order and that an acknowledgement too large on its own is set aside.
+func TestChunkAcknowledgements(t *testing.T) {
+	ack := func(po string, lines int) OrderAcknowledgement {
+		a := OrderAcknowledgement{PurchaseOrderNumber: po}
+		for i := 1; i <= lines; i++ {
+			a.Items = append(a.Items, OrderAcknowledgementItem{ItemSequenceNumber: fmt.Sprint(i)})
+		}
+		return a
+	}
+	acks := []OrderAcknowledgement{ack("PO1", 2), ack("PO2", 2), ack("PO3", 1), ack("BIG", 5), ack("PO4", 3), ack("PO5", 1)}
+
+	batches, oversize := ChunkAcknowledgements(acks, AckLimits{MaxOrders: 2, MaxItems: 4, MaxBytes: 1 << 20})
+	var got []string
+	for _, b := range batches {
+		var pos string
+		for _, a := range b {
+			pos += a.PurchaseOrderNumber + " "
+		}
+		got = append(got, pos)
+	}
+	expected := []string{"PO1 PO2 ", "PO3 PO4 ", "PO5 "}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("batches = %q; expected %q", got, expected)
+	}
+	if len(oversize) != 1 || oversize[0].PurchaseOrderNumber != "BIG" {
+		t.Errorf("oversize = %+v; expected BIG", oversize)
+	}
+
+	batches, oversize = ChunkAcknowledgements(acks[:3], AckLimits{MaxOrders: 10, MaxItems: 100, MaxBytes: 400})
+	if len(batches) < 2 || len(oversize) != 0 {
+		t.Errorf("ChunkAcknowledgements() with a byte limit = %d batches, %d oversize; expected a split", len(batches), len(oversize))
+	}
+}
@@ -0,0 +1,309 @@
+// pkg/spapi/capture.go
+package spapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultCaptureBody is how many bytes of each body a Capture keeps by default.
+const DefaultCaptureBody = 1 << 20
+
+// captureRunKey carries the capture run name in a context.
+type captureRunKey struct{}
+
+/*
+WithCaptureRun names the capture run of requests sent with ctx; their
+exchanges are written to a subdirectory of that name (see Capture).
+*/
+func WithCaptureRun(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, captureRunKey{}, name)
+}
+
+/*
+Capture is an http.RoundTripper that writes every request/response pair it
+carries to a JSON file, for debugging and for Amazon support cases. Files are
+named <Dir>/<run>/<sequence>-<method>-<last path segment>.json, where run is
+set with WithCaptureRun (DefaultRun otherwise). Each file holds the URL,
+headers, bodies, status, start time and duration.
+
+Captures are sanitized: credential headers (Authorization, x-amz-access-token,
+x-amz-security-token, cookies), the query of presigned URLs, and JSON or form
+values of tokens, secrets, passwords, authorization codes and encryption keys
+are replaced by "[redacted]".
+
+Fields:
+  - Dir:        Directory the runs are written to.
+  - DefaultRun: Run name of requests without WithCaptureRun.
+  - Base:       Transport sending the requests; nil means http.DefaultTransport.
+  - MaxBody:    Bytes of each body kept (the rest is marked truncated); 0 means DefaultCaptureBody.
+*/
+type Capture struct {
+	Dir        string
+	DefaultRun string
+	Base       http.RoundTripper
+	MaxBody    int64
+
+	mu  sync.Mutex
+	seq map[string]int
+}
+
+/*
+capturedMessage is the request or response half of a capture file.
+*/
+type capturedMessage struct {
+	Method    string          `json:"method,omitempty"`
+	URL       string          `json:"url,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Headers   http.Header     `json:"headers,omitempty"`
+	Body      json.RawMessage `json:"body,omitempty"`
+	Bytes     int64           `json:"bytes"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+/*
+captureFile is the content of one capture file.
+*/
+type captureFile struct {
+	StartedAt  time.Time        `json:"startedAt"`
+	DurationMS int64            `json:"durationMs"`
+	Request    capturedMessage  `json:"request"`
+	Response   *capturedMessage `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+/*
+RoundTrip sends req through Base and records the exchange. The response body
+is passed through untouched; its capture is written once the caller has read
+or closed it, so streamed downloads are neither buffered nor delayed.
+*/
+func (c *Capture) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := c.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	file := &captureFile{StartedAt: time.Now().UTC(), Request: capturedMessage{
+		Method:  req.Method,
+		URL:     sanitizeURL(req.URL),
+		Headers: sanitizeHeaders(req.Header),
+	}}
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		file.Request.Body, file.Request.Bytes, file.Request.Truncated = c.captureBody(data, int64(len(data)), req.Header.Get("Content-Type"))
+	}
+	dest := c.nextPath(req)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		file.DurationMS = time.Since(file.StartedAt).Milliseconds()
+		file.Error = err.Error()
+		c.write(dest, file)
+		return nil, err
+	}
+	resp.Body = &captureBody{ReadCloser: resp.Body, c: c, path: dest, file: file, resp: resp}
+	return resp, nil
+}
+
+// nextPath returns the capture file of the next exchange of req's run.
+func (c *Capture) nextPath(req *http.Request) string {
+	run, _ := req.Context().Value(captureRunKey{}).(string)
+	if run == "" {
+		run = c.DefaultRun
+	}
+	c.mu.Lock()
+	if c.seq == nil {
+		c.seq = map[string]int{}
+	}
+	c.seq[run]++
+	n := c.seq[run]
+	c.mu.Unlock()
+
+	name := unsafeName.ReplaceAllString(path.Base(req.URL.Path), "_")
+	if name == "" || name == "." || name == "_" {
+		name = "root"
+	}
+	return filepath.Join(c.Dir, run, fmt.Sprintf("%04d-%s-%s.json", n, strings.ToLower(req.Method), name))
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// captureBody encodes the first MaxBody bytes of a body of total bytes for a
+// capture file: sanitized JSON or form values, text as a string, or a note
+// for binary content.
+func (c *Capture) captureBody(data []byte, total int64, contentType string) (json.RawMessage, int64, bool) {
+	truncated := total > int64(len(data))
+	if !truncated {
+		var v interface{}
+		if json.Unmarshal(data, &v) == nil {
+			out, _ := json.Marshal(sanitizeJSON(v, ""))
+			return out, total, false
+		}
+		if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+			if form, err := url.ParseQuery(string(data)); err == nil {
+				for k := range form {
+					if secretName(k) {
+						form[k] = []string{redactedValue}
+					}
+				}
+				out, _ := json.Marshal(form.Encode())
+				return out, total, false
+			}
+		}
+	}
+	if !utf8.Valid(data) {
+		out, _ := json.Marshal(fmt.Sprintf("(%d bytes of binary content)", total))
+		return out, total, truncated
+	}
+	out, _ := json.Marshal(string(data))
+	return out, total, truncated
+}
+
+// write stores file at dest, reporting failures on stderr since a capture
+// must never break the request it records.
+func (c *Capture) write(dest string, file *captureFile) {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(dest), 0o700); err == nil {
+			err = os.WriteFile(dest, append(data, '\n'), 0o600)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write HTTP capture %s: %v\n", dest, err)
+	}
+}
+
+func (c *Capture) maxBody() int64 {
+	if c.MaxBody > 0 {
+		return c.MaxBody
+	}
+	return DefaultCaptureBody
+}
+
+/*
+captureBody passes a response body through, keeping its first bytes, and
+writes the capture file when the body is exhausted or closed.
+*/
+type captureBody struct {
+	io.ReadCloser
+	c    *Capture
+	path string
+	file *captureFile
+	resp *http.Response
+	kept bytes.Buffer
+	n    int64
+	once sync.Once
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.c.maxBody() - int64(b.kept.Len()); room > 0 {
+		b.kept.Write(p[:min(int64(n), room)])
+	}
+	b.n += int64(n)
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+// finish writes the capture file once.
+func (b *captureBody) finish() {
+	b.once.Do(func() {
+		b.file.DurationMS = time.Since(b.file.StartedAt).Milliseconds()
+		msg := &capturedMessage{Status: b.resp.Status, Headers: sanitizeHeaders(b.resp.Header)}
+		if b.n > 0 {
+			msg.Body, msg.Bytes, msg.Truncated = b.c.captureBody(b.kept.Bytes(), b.n, b.resp.Header.Get("Content-Type"))
+		}
+		b.file.Response = msg
+		b.c.write(b.path, b.file)
+	})
+}
+
+// redactedValue replaces secrets in captures.
+const redactedValue = "[redacted]"
+
+// secretHeaders are replaced in captured headers.
+var secretHeaders = []string{"Authorization", "X-Amz-Access-Token", "X-Amz-Security-Token", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// sanitizeHeaders returns a copy of h with credentials redacted.
+func sanitizeHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range secretHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, redactedValue)
+		}
+	}
+	return out
+}
+
+// sanitizeURL drops the query values of presigned URLs (X-Amz-*) and any
+// secret-looking parameter.
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	q := clean.Query()
+	for k := range q {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-") || secretName(k) {
+			q.Set(k, redactedValue)
+		}
+	}
+	clean.RawQuery = q.Encode()
+	clean.User = nil
+	return clean.String()
+}
+
+// sanitizeJSON redacts secret values below v; key is v's key in its parent.
+func sanitizeJSON(v interface{}, key string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = sanitizeJSON(child, k)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = sanitizeJSON(child, key)
+		}
+		return v
+	case string:
+		if secretName(key) && v != "" {
+			return redactedValue
+		}
+		if strings.Contains(v, "X-Amz-Signature=") || strings.Contains(v, "X-Amz-Credential=") {
+			return redactURL(v) + "?" + redactedValue
+		}
+		return v
+	}
+	return v
+}
+
+// secretName reports whether a JSON key or parameter holds a credential,
+// e.g. refresh_token, client_secret, code or an encryption key.
+func secretName(k string) bool {
+	k = strings.ToLower(strings.ReplaceAll(k, "_", ""))
+	return strings.HasSuffix(k, "token") || strings.Contains(k, "secret") || strings.Contains(k, "password") ||
+		k == "code" || k == "key" || k == "spapioauthcode"
+}
@@ -0,0 +1,60 @@
+package spapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCaptureWritesSanitizedExchange verifies a request/response pair is
+// written to the run's directory with credentials redacted and the response
+// body passed through unchanged.
+func TestCaptureWritesSanitizedExchange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"secret-access","payload":{"url":"https://s3/doc?X-Amz-Signature=abc","poNumber":"PO1"}}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: &Capture{Dir: dir, DefaultRun: "default"}}
+	ctx := WithCaptureRun(context.Background(), "api-1")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/auth/o2/token?X-Amz-Credential=xyz", strings.NewReader(`{"grant_type":"refresh_token","refresh_token":"rt","client_secret":"cs"}`))
+	req.Header.Set("Authorization", "Bearer abc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() returned %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "secret-access") {
+		t.Errorf("response body = %s; expected it unchanged", body)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "api-1", "0001-post-token.json"))
+	if err != nil {
+		t.Fatalf("capture file missing: %v", err)
+	}
+	for _, secret := range []string{"secret-access", "Bearer abc", `"rt"`, `"cs"`, "xyz", "X-Amz-Signature=abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("capture contains %q:\n%s", secret, data)
+		}
+	}
+	var file captureFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("capture is not valid JSON: %v", err)
+	}
+	if file.Response == nil || file.Response.Status != "200 OK" || !strings.Contains(string(file.Response.Body), "PO1") {
+		t.Errorf("captured response = %+v; expected status and body", file.Response)
+	}
+	var reqBody map[string]string
+	if err := json.Unmarshal(file.Request.Body, &reqBody); err != nil || reqBody["grant_type"] != "refresh_token" {
+		t.Errorf("captured request body = %s; expected the non-secret fields", file.Request.Body)
+	}
+}
@@ -0,0 +1,208 @@
+// pkg/spapi/client.go
+package spapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Client calls SP‑API operations on behalf of one authorized selling partner.
+
+Fields:
+  - BaseURL:   Regional endpoint (api.baseUrl), e.g. https://sellingpartnerapi-na.amazon.com.
+  - Token:     OAuth2 access token.
+  - HTTP:      HTTP client to use; nil means http.DefaultClient.
+  - Retry:     Retry policy for each request; the zero policy sends once.
+  - UserAgent: User-Agent header sent with every request; empty leaves Go's default.
+  - Logger:    Receives debug output of ModuleSPAPI and failed audit writes; nil discards them.
+*/
+type Client struct {
+	BaseURL   string
+	Token     string
+	HTTP      *http.Client
+	Retry     utils.RetryPolicy
+	UserAgent string
+	Logger    *utils.Logger
+}
+
+// httpClient returns the configured HTTP client or the default one.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+/*
+do sends req and returns the response if its status is 2xx. Other responses
+are closed and returned as a *utils.StatusError naming operation.
+*/
+func (c *Client) do(req *http.Request, operation string) (*http.Response, error) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	c.Logger.Debugf(utils.ModuleSPAPI, "Request: ", "%s %s", req.Method, redactURL(req.URL.String()))
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Debugf(utils.ModuleSPAPI, "Response: ", "%s %s", resp.Status, resp.Header.Get("x-amzn-RequestId"))
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &utils.StatusError{Op: operation, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp, nil
+}
+
+/*
+call performs one JSON operation against path (relative to BaseURL), encoding
+in as the request body when non-nil and decoding the response into out when
+non-nil. Each call is traced as a span named after the operation and retried
+per c.Retry; successful mutating calls are recorded in the audit trail.
+*/
+func (c *Client) call(ctx context.Context, operation, method, path string, query url.Values, in, out interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "spapi."+operation)
+	defer func() { span.End(err) }()
+
+	endpoint := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var data []byte
+	if in != nil {
+		if data, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", operation, err)
+		}
+	}
+	var resp *http.Response
+	err = utils.Retry(ctx, c.Retry, func(ctx context.Context) error {
+		var body io.Reader
+		if in != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		req.Header.Set("x-amz-access-token", c.Token)
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err = c.do(req, operation)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if method != http.MethodGet && method != http.MethodHead {
+		e := audit.Event{Action: audit.SPAPICall, Target: operation, Fields: map[string]string{"method": method, "path": path}}
+		if in != nil {
+			e.SHA256, e.Bytes = audit.Sum(data), int64(len(data))
+		}
+		c.recordAudit(e)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid %s response: %w", operation, err)
+	}
+	return nil
+}
+
+/*
+Probe sends a read-only GET to path and discards the response, to verify the
+token is authorized for the operation. A refresh token without the required
+role fails with a *utils.StatusError of status 403.
+*/
+func (c *Client) Probe(ctx context.Context, path string, query url.Values) error {
+	return c.call(ctx, "probe", http.MethodGet, path, query, nil, nil)
+}
+
+/*
+download fetches a document from a presigned URL and writes its plain content
+to w, decrypting and decompressing it as required. Unencrypted documents are
+streamed; encrypted ones are buffered for decryption.
+
+Parameters:
+  - operation:   Name used for the trace span.
+  - rawURL:      Presigned download URL.
+  - enc:         Client-side encryption details; nil when not encrypted.
+  - compression: "GZIP" or empty.
+  - w:           Receives the content.
+
+Returns:
+  - The number of bytes written to w.
+  - An error if the download, decryption or decompression fails.
+*/
+func (c *Client) download(ctx context.Context, operation, rawURL string, enc *EncryptionDetails, compression string, w io.Writer) (n int64, err error) {
+	ctx, span := tracing.Start(ctx, "spapi."+operation)
+	defer func() {
+		span.SetAttr("bytes", n)
+		span.End(err)
+	}()
+
+	// Only the request is retried; once content reaches w it cannot be taken back.
+	var resp *http.Response
+	err = utils.Retry(ctx, c.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		resp, err = c.do(req, "download")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var r io.Reader = resp.Body
+	if enc != nil {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		if data, err = Decrypt(*enc, data); err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(data)
+	}
+	switch compression {
+	case "":
+	case "GZIP":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decompress document: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return 0, fmt.Errorf("unsupported compression algorithm %q", compression)
+	}
+	return io.Copy(w, r)
+}
+
+// recordAudit records e in the default audit trail. The call it describes has
+// already been made, so a failed write is reported but not returned.
+func (c *Client) recordAudit(e audit.Event) {
+	if err := audit.Record(e); err != nil {
+		c.Logger.Log(utils.LevelError, "Audit log write failed: ", err.Error())
+	}
+}
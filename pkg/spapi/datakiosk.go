@@ -0,0 +1,140 @@
+// pkg/spapi/datakiosk.go
+package spapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+Query is the status of a Data Kiosk query (Data Kiosk API 2023-11-15).
+Processing states match the Reports API (IN_QUEUE, IN_PROGRESS, DONE,
+CANCELLED, FATAL).
+
+Fields:
+  - QueryID:          Identifier returned by createQuery.
+  - Query:            The GraphQL query text.
+  - ProcessingStatus: Current processing state.
+  - DataDocumentID:   JSONL result document; empty when the query matched no data.
+  - ErrorDocumentID:  Error document of a FATAL query.
+*/
+type Query struct {
+	QueryID          string `json:"queryId"`
+	Query            string `json:"query"`
+	ProcessingStatus string `json:"processingStatus"`
+	DataDocumentID   string `json:"dataDocumentId,omitempty"`
+	ErrorDocumentID  string `json:"errorDocumentId,omitempty"`
+}
+
+/*
+QueryDocument locates the content of a Data Kiosk document.
+*/
+type QueryDocument struct {
+	DocumentID  string `json:"documentId"`
+	DocumentURL string `json:"documentUrl"`
+}
+
+/*
+CreateQuery submits a GraphQL query and returns its queryId.
+*/
+func (c *Client) CreateQuery(ctx context.Context, query string) (string, error) {
+	in := map[string]string{"query": query}
+	var out struct {
+		QueryID string `json:"queryId"`
+	}
+	if err := c.call(ctx, "create_query", http.MethodPost, "/dataKiosk/2023-11-15/queries", nil, in, &out); err != nil {
+		return "", err
+	}
+	if out.QueryID == "" {
+		return "", fmt.Errorf("createQuery response has no queryId")
+	}
+	return out.QueryID, nil
+}
+
+/*
+GetQuery returns the processing status of a query.
+*/
+func (c *Client) GetQuery(ctx context.Context, queryID string) (Query, error) {
+	var out Query
+	err := c.call(ctx, "get_query", http.MethodGet, "/dataKiosk/2023-11-15/queries/"+url.PathEscape(queryID), nil, nil, &out)
+	return out, err
+}
+
+/*
+WaitForQuery polls a query every interval until it is DONE.
+
+Returns:
+  - The finished query.
+  - An error if the query is CANCELLED or FATAL (including the start of its error document), polling fails, or ctx ends.
+*/
+func (c *Client) WaitForQuery(ctx context.Context, queryID string, interval time.Duration) (Query, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		query, err := c.GetQuery(ctx, queryID)
+		if err != nil {
+			return query, err
+		}
+		switch query.ProcessingStatus {
+		case ReportDone:
+			return query, nil
+		case ReportCancelled, ReportFatal:
+			err := fmt.Errorf("query %s finished with status %s", queryID, query.ProcessingStatus)
+			if detail := c.queryError(ctx, query.ErrorDocumentID); detail != "" {
+				err = fmt.Errorf("%w: %s", err, detail)
+			}
+			return query, err
+		}
+		select {
+		case <-ctx.Done():
+			return query, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// queryError fetches the start of a query's error document, or "" if unavailable.
+func (c *Client) queryError(ctx context.Context, documentID string) string {
+	if documentID == "" {
+		return ""
+	}
+	doc, err := c.GetQueryDocument(ctx, documentID)
+	if err != nil {
+		return ""
+	}
+	var b bytes.Buffer
+	if _, err := c.DownloadQueryDocument(ctx, doc, &b); err != nil {
+		return ""
+	}
+	detail := strings.TrimSpace(b.String())
+	if len(detail) > 500 {
+		detail = detail[:500] + "..."
+	}
+	return detail
+}
+
+/*
+GetQueryDocument returns the download location of a Data Kiosk document.
+*/
+func (c *Client) GetQueryDocument(ctx context.Context, documentID string) (QueryDocument, error) {
+	var out QueryDocument
+	err := c.call(ctx, "get_query_document", http.MethodGet, "/dataKiosk/2023-11-15/documents/"+url.PathEscape(documentID), nil, nil, &out)
+	return out, err
+}
+
+/*
+DownloadQueryDocument streams a Data Kiosk document (JSONL) to w.
+
+Returns:
+  - The number of bytes written to w.
+  - An error if the download fails.
+*/
+func (c *Client) DownloadQueryDocument(ctx context.Context, doc QueryDocument, w io.Writer) (int64, error) {
+	return c.download(ctx, "download_query_document", doc.DocumentURL, nil, "", w)
+}
@@ -0,0 +1,69 @@
+package spapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestQueryWorkflow verifies a query is submitted, polled and its JSONL result downloaded.
+func TestQueryWorkflow(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dataKiosk/2023-11-15/queries":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			if !strings.Contains(in["query"], "manufacturingView") {
+				t.Errorf("query = %q", in["query"])
+			}
+			w.Write([]byte(`{"queryId":"Q1"}`))
+		case "/dataKiosk/2023-11-15/queries/Q1":
+			json.NewEncoder(w).Encode(Query{QueryID: "Q1", ProcessingStatus: ReportDone, DataDocumentID: "D1"})
+		case "/dataKiosk/2023-11-15/queries/Q2":
+			json.NewEncoder(w).Encode(Query{QueryID: "Q2", ProcessingStatus: ReportFatal, ErrorDocumentID: "E1"})
+		case "/dataKiosk/2023-11-15/documents/D1", "/dataKiosk/2023-11-15/documents/E1":
+			id := strings.TrimPrefix(r.URL.Path, "/dataKiosk/2023-11-15/documents/")
+			json.NewEncoder(w).Encode(QueryDocument{DocumentID: id, DocumentURL: srv.URL + "/download/" + id})
+		case "/download/D1":
+			w.Write([]byte("{\"asin\":\"B0001\"}\n{\"asin\":\"B0002\"}\n"))
+		case "/download/E1":
+			w.Write([]byte(`{"errorMessage":"Invalid date range"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{BaseURL: srv.URL, Token: "t", HTTP: srv.Client()}
+	id, err := c.CreateQuery(ctx, "query { manufacturingView { startDate } }")
+	if err != nil {
+		t.Fatalf("CreateQuery() returned %v", err)
+	}
+	q, err := c.WaitForQuery(ctx, id, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForQuery() returned %v", err)
+	}
+	doc, err := c.GetQueryDocument(ctx, q.DataDocumentID)
+	if err != nil {
+		t.Fatalf("GetQueryDocument() returned %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := c.DownloadQueryDocument(ctx, doc, &out); err != nil {
+		t.Fatalf("DownloadQueryDocument() returned %v", err)
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("got %q, want two JSONL records", out.String())
+	}
+
+	_, err = c.WaitForQuery(ctx, "Q2", time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Invalid date range") {
+		t.Errorf("WaitForQuery(FATAL) returned %v, want error document detail", err)
+	}
+}
@@ -0,0 +1,118 @@
+// pkg/spapi/diff.go
+package spapi
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+Kinds of change reported by Diff.
+*/
+const (
+	StateChanged    = "state-changed"    // purchaseOrderState moved, e.g. New to Acknowledged
+	WindowMoved     = "window-moved"     // ship or delivery window changed
+	QuantityChanged = "quantity-changed" // ordered amount or unit of a line changed
+	PriceChanged    = "price-changed"    // net cost or list price of a line changed
+	LineAdded       = "line-added"       // new item sequence number
+	LineCancelled   = "line-cancelled"   // line removed or its quantity set to zero
+	FieldChanged    = "field-changed"    // any other compared header field
+)
+
+/*
+Change is one field-level difference between two versions of a purchase order.
+
+Fields:
+  - Kind:  One of the kinds above.
+  - Field: JSON name of the changed field, e.g. "shipWindow" or "orderedQuantity.amount".
+  - Item:  Item sequence number for line changes ("" for header fields).
+  - Old:   Previous value ("" when added).
+  - New:   Current value ("" when removed).
+*/
+type Change struct {
+	Kind  string `json:"kind"`
+	Field string `json:"field"`
+	Item  string `json:"item,omitempty"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// String describes the change for log output.
+func (c Change) String() string {
+	field := c.Field
+	if c.Item != "" {
+		field = "item " + c.Item + " " + field
+	}
+	return fmt.Sprintf("%s: %s %q -> %q", c.Kind, field, c.Old, c.New)
+}
+
+/*
+Diff compares a stored purchase order with a re-fetched version of it. Header
+fields are compared first, then lines matched by item sequence number, in the
+order they appear. Change timestamps are not reported themselves; they only
+say that something changed.
+
+Returns:
+  - The changes, or nil if both versions carry the same data.
+*/
+func Diff(old, cur PurchaseOrder) []Change {
+	var changes []Change
+	add := func(kind, field, item, o, n string) {
+		if o != n {
+			changes = append(changes, Change{Kind: kind, Field: field, Item: item, Old: o, New: n})
+		}
+	}
+
+	add(StateChanged, "purchaseOrderState", "", old.PurchaseOrderState, cur.PurchaseOrderState)
+	od, cd := old.OrderDetails, cur.OrderDetails
+	add(WindowMoved, "shipWindow", "", od.ShipWindow, cd.ShipWindow)
+	add(WindowMoved, "deliveryWindow", "", od.DeliveryWindow, cd.DeliveryWindow)
+	add(FieldChanged, "purchaseOrderType", "", od.PurchaseOrderType, cd.PurchaseOrderType)
+	add(FieldChanged, "dealCode", "", od.DealCode, cd.DealCode)
+	add(FieldChanged, "paymentMethod", "", od.PaymentMethod, cd.PaymentMethod)
+	add(FieldChanged, "shipToParty", "", partyID(od.ShipToParty), partyID(cd.ShipToParty))
+	add(FieldChanged, "billToParty", "", partyID(od.BillToParty), partyID(cd.BillToParty))
+
+	previous := make(map[string]OrderItem, len(od.Items))
+	for _, it := range od.Items {
+		previous[it.ItemSequenceNumber] = it
+	}
+	seen := make(map[string]bool, len(cd.Items))
+	for _, it := range cd.Items {
+		seq := it.ItemSequenceNumber
+		seen[seq] = true
+		prev, ok := previous[seq]
+		if !ok {
+			add(LineAdded, "orderedQuantity.amount", seq, "", strconv.Itoa(it.OrderedQuantity.Amount))
+			continue
+		}
+		if prev.OrderedQuantity.Amount > 0 && it.OrderedQuantity.Amount == 0 {
+			add(LineCancelled, "orderedQuantity.amount", seq, strconv.Itoa(prev.OrderedQuantity.Amount), "0")
+			continue
+		}
+		add(QuantityChanged, "orderedQuantity.amount", seq, strconv.Itoa(prev.OrderedQuantity.Amount), strconv.Itoa(it.OrderedQuantity.Amount))
+		add(QuantityChanged, "orderedQuantity.unitOfMeasure", seq, prev.OrderedQuantity.UnitOfMeasure, it.OrderedQuantity.UnitOfMeasure)
+		add(PriceChanged, "netCost", seq, money(prev.NetCost), money(it.NetCost))
+		add(PriceChanged, "listPrice", seq, money(prev.ListPrice), money(it.ListPrice))
+	}
+	for _, it := range od.Items {
+		if !seen[it.ItemSequenceNumber] {
+			add(LineCancelled, "orderedQuantity.amount", it.ItemSequenceNumber, strconv.Itoa(it.OrderedQuantity.Amount), "")
+		}
+	}
+	return changes
+}
+
+func partyID(p *Party) string {
+	if p == nil {
+		return ""
+	}
+	return p.PartyID
+}
+
+func money(m *Money) string {
+	if m == nil {
+		return ""
+	}
+	return m.Amount + " " + m.CurrencyCode
+}
@@ -0,0 +1,51 @@
+package spapi
+
+import (
+	"testing"
+)
+
+// TestDiff verifies state, window, quantity and line changes are reported and
+// identical versions yield none.
+func TestDiff(t *testing.T) {
+	old := PurchaseOrder{
+		PurchaseOrderNumber: "PO1",
+		PurchaseOrderState:  "New",
+		OrderDetails: OrderDetails{
+			ShipWindow: "2024-01-05T00:00:00Z--2024-01-09T00:00:00Z",
+			Items: []OrderItem{
+				{ItemSequenceNumber: "1", OrderedQuantity: ItemQuantity{Amount: 10, UnitOfMeasure: "Eaches"}},
+				{ItemSequenceNumber: "2", OrderedQuantity: ItemQuantity{Amount: 4, UnitOfMeasure: "Cases"}},
+				{ItemSequenceNumber: "3", OrderedQuantity: ItemQuantity{Amount: 1, UnitOfMeasure: "Eaches"}},
+			},
+		},
+	}
+	if changes := Diff(old, old); changes != nil {
+		t.Fatalf("Diff() of identical orders = %v; expected none", changes)
+	}
+
+	cur := old
+	cur.PurchaseOrderState = "Acknowledged"
+	cur.OrderDetails.ShipWindow = "2024-01-07T00:00:00Z--2024-01-11T00:00:00Z"
+	cur.OrderDetails.Items = []OrderItem{
+		{ItemSequenceNumber: "1", OrderedQuantity: ItemQuantity{Amount: 8, UnitOfMeasure: "Eaches"}},
+		{ItemSequenceNumber: "2", OrderedQuantity: ItemQuantity{Amount: 0, UnitOfMeasure: "Cases"}},
+		{ItemSequenceNumber: "4", OrderedQuantity: ItemQuantity{Amount: 2, UnitOfMeasure: "Eaches"}},
+	}
+	expected := []Change{
+		{Kind: StateChanged, Field: "purchaseOrderState", Old: "New", New: "Acknowledged"},
+		{Kind: WindowMoved, Field: "shipWindow", Old: old.OrderDetails.ShipWindow, New: cur.OrderDetails.ShipWindow},
+		{Kind: QuantityChanged, Field: "orderedQuantity.amount", Item: "1", Old: "10", New: "8"},
+		{Kind: LineCancelled, Field: "orderedQuantity.amount", Item: "2", Old: "4", New: "0"},
+		{Kind: LineAdded, Field: "orderedQuantity.amount", Item: "4", Old: "", New: "2"},
+		{Kind: LineCancelled, Field: "orderedQuantity.amount", Item: "3", Old: "1", New: ""},
+	}
+	changes := Diff(old, cur)
+	if len(changes) != len(expected) {
+		t.Fatalf("Diff() = %v; expected %v", changes, expected)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("change %d = %v; expected %v", i, changes[i], expected[i])
+		}
+	}
+}
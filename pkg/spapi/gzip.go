@@ -0,0 +1,111 @@
+// pkg/spapi/gzip.go
+package spapi
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Gzip is an http.RoundTripper that asks for gzip-compressed responses and
+decompresses them, so large purchase order lists cross slow links at a
+fraction of their size. Go's own transport does this only when nothing else
+set Accept-Encoding and hides it; Gzip works over any Base and reports the
+compressed size at debug level to Logger.
+
+Requests that already carry Accept-Encoding are passed through untouched, and
+so are their responses. Place Gzip below signing transports and Capture, so
+captures record the plain bodies.
+
+The zero Gzip sends through http.DefaultTransport.
+*/
+type Gzip struct {
+	Base   http.RoundTripper
+	Logger *utils.Logger
+}
+
+// RoundTrip implements http.RoundTripper.
+func (g *Gzip) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := g.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := base.RoundTrip(req)
+	if err != nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	body := &gzipBody{raw: resp.Body, counted: &countingBody{r: resp.Body}, url: req.URL.String(), logger: g.Logger}
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// countingBody counts the bytes read through it.
+type countingBody struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+/*
+gzipBody decompresses a response body on first read, so a response that is
+closed unread costs nothing.
+*/
+type gzipBody struct {
+	raw     io.ReadCloser
+	counted *countingBody
+	zr      *gzip.Reader
+	err     error
+	n       int64
+	url     string
+	logger  *utils.Logger
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		if b.zr, b.err = gzip.NewReader(b.counted); b.err != nil {
+			b.err = fmt.Errorf("failed to decompress response: %w", b.err)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.zr.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("failed to decompress response: %w", err)
+	}
+	return n, err
+}
+
+// Close closes the response body and reports the compression ratio achieved.
+func (b *gzipBody) Close() error {
+	if b.n > 0 {
+		b.logger.Debugf(utils.ModuleSPAPI, "Decompressed: ", "%s (%d bytes transferred, %d bytes decoded)", redactURL(b.url), b.counted.n, b.n)
+	}
+	if b.zr != nil {
+		b.zr.Close()
+	}
+	return b.raw.Close()
+}
@@ -0,0 +1,52 @@
+package spapi
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGzip verifies responses are requested compressed and decoded, and that
+// requests choosing their own encoding are passed through.
+func TestGzip(t *testing.T) {
+	const payload = `{"payload":{"orders":[]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			io.WriteString(w, payload)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, payload)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Gzip{}}
+	resp, err := client.Get(srv.URL + "/vendor/orders/v1/purchaseOrders")
+	if err != nil {
+		t.Fatalf("Get() returned %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != payload {
+		t.Errorf("body = %q, %v; expected %q", body, err, payload)
+	}
+	if !resp.Uncompressed || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("response still marked as compressed: %v", resp.Header)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() returned %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != payload {
+		t.Errorf("identity body = %q; expected %q", body, payload)
+	}
+}
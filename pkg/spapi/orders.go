@@ -0,0 +1,243 @@
+// pkg/spapi/orders.go
+package spapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+PurchaseOrder is a vendor purchase order as returned by the SP‑API
+Vendor Orders v1 getPurchaseOrders/getPurchaseOrder operations.
+
+Fields:
+  - PurchaseOrderNumber: Amazon's PO number.
+  - PurchaseOrderState:  New, Acknowledged or Closed.
+  - OrderDetails:        Dates, parties, windows and line items.
+*/
+type PurchaseOrder struct {
+	PurchaseOrderNumber string       `json:"purchaseOrderNumber"`
+	PurchaseOrderState  string       `json:"purchaseOrderState"`
+	OrderDetails        OrderDetails `json:"orderDetails"`
+}
+
+/*
+OrderDetails holds the body of a purchase order.
+
+Fields:
+  - PurchaseOrderDate:             When the PO was issued.
+  - PurchaseOrderChangedDate:      When the PO was last changed by Amazon.
+  - PurchaseOrderStateChangedDate: When PurchaseOrderState last changed.
+  - PurchaseOrderType:             RegularOrder, ConsignedOrder, NewProductIntroduction or RushOrder.
+  - BuyingParty/SellingParty/ShipToParty/BillToParty: Parties by ID.
+  - ShipWindow/DeliveryWindow:     ISO‑8601 intervals ("start--end").
+  - Items:                         Line items.
+*/
+type OrderDetails struct {
+	PurchaseOrderDate             time.Time   `json:"purchaseOrderDate"`
+	PurchaseOrderChangedDate      *time.Time  `json:"purchaseOrderChangedDate,omitempty"`
+	PurchaseOrderStateChangedDate *time.Time  `json:"purchaseOrderStateChangedDate,omitempty"`
+	PurchaseOrderType             string      `json:"purchaseOrderType,omitempty"`
+	DealCode                      string      `json:"dealCode,omitempty"`
+	PaymentMethod                 string      `json:"paymentMethod,omitempty"`
+	BuyingParty                   *Party      `json:"buyingParty,omitempty"`
+	SellingParty                  *Party      `json:"sellingParty,omitempty"`
+	ShipToParty                   *Party      `json:"shipToParty,omitempty"`
+	BillToParty                   *Party      `json:"billToParty,omitempty"`
+	ShipWindow                    string      `json:"shipWindow,omitempty"`
+	DeliveryWindow                string      `json:"deliveryWindow,omitempty"`
+	Items                         []OrderItem `json:"items"`
+}
+
+/*
+Party identifies a buying, selling, ship-to or bill-to party.
+*/
+type Party struct {
+	PartyID string `json:"partyId"`
+}
+
+/*
+OrderItem is one line of a purchase order.
+
+Fields:
+  - ItemSequenceNumber:      Line number within the PO.
+  - AmazonProductIdentifier: ASIN.
+  - VendorProductIdentifier: Vendor SKU.
+  - OrderedQuantity:         Quantity and unit of measure.
+  - IsBackOrderAllowed:      Whether the line may be back-ordered.
+  - NetCost/ListPrice:       Prices per unit.
+*/
+type OrderItem struct {
+	ItemSequenceNumber      string       `json:"itemSequenceNumber"`
+	AmazonProductIdentifier string       `json:"amazonProductIdentifier,omitempty"`
+	VendorProductIdentifier string       `json:"vendorProductIdentifier,omitempty"`
+	OrderedQuantity         ItemQuantity `json:"orderedQuantity"`
+	IsBackOrderAllowed      bool         `json:"isBackOrderAllowed"`
+	NetCost                 *Money       `json:"netCost,omitempty"`
+	ListPrice               *Money       `json:"listPrice,omitempty"`
+}
+
+/*
+ItemQuantity is an amount in a unit of measure (Cases, Eaches).
+*/
+type ItemQuantity struct {
+	Amount        int    `json:"amount"`
+	UnitOfMeasure string `json:"unitOfMeasure"`
+	UnitSize      int    `json:"unitSize,omitempty"`
+}
+
+/*
+Money is a decimal amount with its ISO‑4217 currency code.
+*/
+type Money struct {
+	CurrencyCode string `json:"currencyCode"`
+	Amount       string `json:"amount"`
+}
+
+/*
+Pagination carries the token for the next page of results, if any.
+*/
+type Pagination struct {
+	NextToken string `json:"nextToken"`
+}
+
+/*
+DecodePurchaseOrders parses a getPurchaseOrders response body.
+
+Returns:
+  - The orders on this page.
+  - The pagination token for the next page ("" when this is the last page).
+  - An error if the body is not a valid response.
+*/
+func DecodePurchaseOrders(body []byte) ([]PurchaseOrder, string, error) {
+	var orders []PurchaseOrder
+	next, err := StreamPurchaseOrders(bytes.NewReader(body), func(o PurchaseOrder) error {
+		orders = append(orders, o)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return orders, next, nil
+}
+
+/*
+StreamPurchaseOrders decodes a getPurchaseOrders response from r one order at
+a time, calling fn for each as soon as it is decoded. Only a single order is
+held in memory, so responses of any size can be processed.
+
+Parameters:
+  - r:  The response body.
+  - fn: Called for every order in document order; a non-nil error stops decoding.
+
+Returns:
+  - The pagination token for the next page ("" when this is the last page).
+  - An error if the body is not a valid response or fn fails.
+*/
+func StreamPurchaseOrders(r io.Reader, fn func(PurchaseOrder) error) (string, error) {
+	dec := json.NewDecoder(r)
+	var next string
+	err := decodeObject(dec, func(key string) error {
+		if key != "payload" {
+			return skipValue(dec)
+		}
+		return decodeObject(dec, func(key string) error {
+			switch key {
+			case "orders":
+				return decodeArray(dec, func() error {
+					var o PurchaseOrder
+					if err := dec.Decode(&o); err != nil {
+						return err
+					}
+					return fn(o)
+				})
+			case "pagination":
+				var p *Pagination
+				if err := dec.Decode(&p); err != nil {
+					return err
+				}
+				if p != nil {
+					next = p.NextToken
+				}
+				return nil
+			default:
+				return skipValue(dec)
+			}
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid purchase orders response: %w", err)
+	}
+	return next, nil
+}
+
+// decodeObject reads a JSON object from dec, calling field for each key with
+// the decoder positioned at its value. A null object is accepted.
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(tok.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // closing '}'
+	return err
+}
+
+// decodeArray reads a JSON array from dec, calling elem with the decoder
+// positioned at each element. A null array is accepted.
+func decodeArray(dec *json.Decoder, elem func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+	for dec.More() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // closing ']'
+	return err
+}
+
+// skipValue consumes the next value, however deeply nested, without keeping it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
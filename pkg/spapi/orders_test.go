@@ -0,0 +1,53 @@
+package spapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const ordersPage = `{
+  "payload": {
+    "pagination": {"nextToken": "page2"},
+    "orders": [
+      {"purchaseOrderNumber": "PO1", "purchaseOrderState": "New", "orderDetails": {"purchaseOrderDate": "2024-01-02T00:00:00Z", "items": [{"itemSequenceNumber": "1"}]}},
+      {"purchaseOrderNumber": "PO2", "purchaseOrderState": "Acknowledged", "orderDetails": {"purchaseOrderDate": "2024-01-03T00:00:00Z"}}
+    ]
+  },
+  "errors": [{"code": "x", "details": {"nested": [1, [2, 3]]}}]
+}`
+
+// TestStreamPurchaseOrders verifies orders are delivered one by one along with the next token.
+func TestStreamPurchaseOrders(t *testing.T) {
+	var numbers []string
+	next, err := StreamPurchaseOrders(strings.NewReader(ordersPage), func(o PurchaseOrder) error {
+		numbers = append(numbers, o.PurchaseOrderNumber)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamPurchaseOrders() returned %v", err)
+	}
+	if next != "page2" {
+		t.Errorf("next token = %q; expected page2", next)
+	}
+	if strings.Join(numbers, ",") != "PO1,PO2" {
+		t.Errorf("orders = %v; expected [PO1 PO2]", numbers)
+	}
+}
+
+// TestStreamPurchaseOrdersStops verifies a callback error stops decoding and a truncated body fails.
+func TestStreamPurchaseOrdersStops(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	_, err := StreamPurchaseOrders(strings.NewReader(ordersPage), func(o PurchaseOrder) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("got err %v after %d calls; expected stop after 1", err, calls)
+	}
+
+	if _, err := StreamPurchaseOrders(strings.NewReader(ordersPage[:200]), func(PurchaseOrder) error { return nil }); err == nil {
+		t.Error("expected an error for a truncated response")
+	}
+}
@@ -0,0 +1,144 @@
+// pkg/spapi/reports.go
+package spapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+/*
+Report processing states returned by getReport.
+*/
+const (
+	ReportInQueue    = "IN_QUEUE"
+	ReportInProgress = "IN_PROGRESS"
+	ReportDone       = "DONE"
+	ReportCancelled  = "CANCELLED"
+	ReportFatal      = "FATAL"
+)
+
+/*
+ReportSpec describes a report to request (Reports API 2021-06-30 createReport).
+
+Fields:
+  - ReportType:     E.g. "GET_VENDOR_SALES_REPORT".
+  - MarketplaceIDs: Marketplaces to report on.
+  - DataStartTime:  Start of the reported period; nil lets Amazon choose.
+  - DataEndTime:    End of the reported period; nil lets Amazon choose.
+  - ReportOptions:  Report-specific options (e.g. reportPeriod, distributorView).
+*/
+type ReportSpec struct {
+	ReportType     string            `json:"reportType"`
+	MarketplaceIDs []string          `json:"marketplaceIds"`
+	DataStartTime  *time.Time        `json:"dataStartTime,omitempty"`
+	DataEndTime    *time.Time        `json:"dataEndTime,omitempty"`
+	ReportOptions  map[string]string `json:"reportOptions,omitempty"`
+}
+
+/*
+Report is the status of a requested report.
+*/
+type Report struct {
+	ReportID         string     `json:"reportId"`
+	ReportType       string     `json:"reportType"`
+	ProcessingStatus string     `json:"processingStatus"`
+	ReportDocumentID string     `json:"reportDocumentId,omitempty"`
+	DataStartTime    *time.Time `json:"dataStartTime,omitempty"`
+	DataEndTime      *time.Time `json:"dataEndTime,omitempty"`
+}
+
+/*
+ReportDocument locates the content of a finished report.
+
+Fields:
+  - ReportDocumentID:     Identifier of the document.
+  - URL:                  Presigned download URL (valid for five minutes).
+  - CompressionAlgorithm: "GZIP" when the content is compressed.
+  - EncryptionDetails:    Client-side encryption, for documents that still use it.
+*/
+type ReportDocument struct {
+	ReportDocumentID     string             `json:"reportDocumentId"`
+	URL                  string             `json:"url"`
+	CompressionAlgorithm string             `json:"compressionAlgorithm,omitempty"`
+	EncryptionDetails    *EncryptionDetails `json:"encryptionDetails,omitempty"`
+}
+
+/*
+CreateReport requests a report and returns its reportId.
+*/
+func (c *Client) CreateReport(ctx context.Context, spec ReportSpec) (string, error) {
+	var out struct {
+		ReportID string `json:"reportId"`
+	}
+	if err := c.call(ctx, "create_report", http.MethodPost, "/reports/2021-06-30/reports", nil, spec, &out); err != nil {
+		return "", err
+	}
+	if out.ReportID == "" {
+		return "", fmt.Errorf("createReport response has no reportId")
+	}
+	return out.ReportID, nil
+}
+
+/*
+GetReport returns the processing status of a report.
+*/
+func (c *Client) GetReport(ctx context.Context, reportID string) (Report, error) {
+	var out Report
+	err := c.call(ctx, "get_report", http.MethodGet, "/reports/2021-06-30/reports/"+url.PathEscape(reportID), nil, nil, &out)
+	return out, err
+}
+
+/*
+WaitForReport polls a report every interval until it is DONE.
+
+Returns:
+  - The finished report.
+  - An error if the report is CANCELLED or FATAL, polling fails, or ctx ends.
+*/
+func (c *Client) WaitForReport(ctx context.Context, reportID string, interval time.Duration) (Report, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := c.GetReport(ctx, reportID)
+		if err != nil {
+			return report, err
+		}
+		switch report.ProcessingStatus {
+		case ReportDone:
+			return report, nil
+		case ReportCancelled, ReportFatal:
+			return report, fmt.Errorf("report %s finished with status %s", reportID, report.ProcessingStatus)
+		}
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+GetReportDocument returns the download location of a report document.
+*/
+func (c *Client) GetReportDocument(ctx context.Context, documentID string) (ReportDocument, error) {
+	var out ReportDocument
+	err := c.call(ctx, "get_report_document", http.MethodGet, "/reports/2021-06-30/documents/"+url.PathEscape(documentID), nil, nil, &out)
+	return out, err
+}
+
+/*
+DownloadReportDocument fetches a report document and writes its plain content
+to w, decrypting and decompressing it as the document requires. Unencrypted
+documents are streamed; encrypted ones are buffered for decryption.
+
+Returns:
+  - The number of bytes written to w.
+  - An error if the download, decryption or decompression fails.
+*/
+func (c *Client) DownloadReportDocument(ctx context.Context, doc ReportDocument, w io.Writer) (int64, error) {
+	return c.download(ctx, "download_report_document", doc.URL, doc.EncryptionDetails, doc.CompressionAlgorithm, w)
+}
@@ -0,0 +1,70 @@
+package spapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReportWorkflow verifies create, poll, document lookup and a gzipped download.
+func TestReportWorkflow(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write([]byte(`{"reportData":[]}`))
+	zw.Close()
+
+	polls := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports/2021-06-30/reports":
+			var spec ReportSpec
+			json.NewDecoder(r.Body).Decode(&spec)
+			if spec.ReportType != "GET_VENDOR_SALES_REPORT" {
+				t.Errorf("reportType = %q", spec.ReportType)
+			}
+			w.Write([]byte(`{"reportId":"R1"}`))
+		case "/reports/2021-06-30/reports/R1":
+			polls++
+			status := ReportInProgress
+			if polls > 1 {
+				status = ReportDone
+			}
+			json.NewEncoder(w).Encode(Report{ReportID: "R1", ProcessingStatus: status, ReportDocumentID: "D1"})
+		case "/reports/2021-06-30/documents/D1":
+			json.NewEncoder(w).Encode(ReportDocument{ReportDocumentID: "D1", URL: srv.URL + "/download", CompressionAlgorithm: "GZIP"})
+		case "/download":
+			w.Write(zipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{BaseURL: srv.URL, Token: "t", HTTP: srv.Client()}
+	id, err := c.CreateReport(ctx, ReportSpec{ReportType: "GET_VENDOR_SALES_REPORT", MarketplaceIDs: []string{"ATVPDKIKX0DER"}})
+	if err != nil {
+		t.Fatalf("CreateReport() returned %v", err)
+	}
+	report, err := c.WaitForReport(ctx, id, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForReport() returned %v", err)
+	}
+	doc, err := c.GetReportDocument(ctx, report.ReportDocumentID)
+	if err != nil {
+		t.Fatalf("GetReportDocument() returned %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := c.DownloadReportDocument(ctx, doc, &out); err != nil {
+		t.Fatalf("DownloadReportDocument() returned %v", err)
+	}
+	if out.String() != `{"reportData":[]}` || polls != 2 {
+		t.Errorf("got %q after %d polls", out.String(), polls)
+	}
+}
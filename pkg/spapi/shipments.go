@@ -0,0 +1,154 @@
+// pkg/spapi/shipments.go
+package spapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+/*
+Transaction states returned by getTransaction.
+*/
+const (
+	TransactionProcessing = "Processing"
+	TransactionSuccess    = "Success"
+	TransactionFailure    = "Failure"
+)
+
+/*
+ShipmentConfirmation reports goods shipped against one or more purchase
+orders (Vendor Shipments API v1 submitShipmentConfirmations).
+
+Fields:
+  - ShipmentIdentifier:       Vendor's unique shipment ID (e.g. ASN number).
+  - ShipmentConfirmationType: "Original" or "Replace".
+  - ShipmentConfirmationDate: When the confirmation was issued.
+  - ShippedDate:              When the goods left the ship-from location.
+  - SellingParty:             Vendor party from the purchase order.
+  - ShipFromParty:            Warehouse the goods shipped from.
+  - ShipToParty:              Amazon fulfillment center from the purchase order.
+  - TransportationDetails:    Carrier and tracking reference, if known.
+  - ShippedItems:             Shipped lines.
+*/
+type ShipmentConfirmation struct {
+	ShipmentIdentifier       string                 `json:"shipmentIdentifier"`
+	ShipmentConfirmationType string                 `json:"shipmentConfirmationType"`
+	ShipmentConfirmationDate time.Time              `json:"shipmentConfirmationDate"`
+	ShippedDate              *time.Time             `json:"shippedDate,omitempty"`
+	SellingParty             Party                  `json:"sellingParty"`
+	ShipFromParty            Party                  `json:"shipFromParty"`
+	ShipToParty              Party                  `json:"shipToParty"`
+	TransportationDetails    *TransportationDetails `json:"transportationDetails,omitempty"`
+	ShippedItems             []ShippedItem          `json:"shippedItems"`
+}
+
+/*
+TransportationDetails identifies the carrier of a shipment.
+*/
+type TransportationDetails struct {
+	CarrierScac                    string `json:"carrierScac,omitempty"`
+	CarrierShipmentReferenceNumber string `json:"carrierShipmentReferenceNumber,omitempty"`
+}
+
+/*
+ShippedItem is one shipped purchase order line.
+*/
+type ShippedItem struct {
+	ItemSequenceNumber      string              `json:"itemSequenceNumber"`
+	AmazonProductIdentifier string              `json:"amazonProductIdentifier,omitempty"`
+	VendorProductIdentifier string              `json:"vendorProductIdentifier,omitempty"`
+	ShippedQuantity         ItemQuantity        `json:"shippedQuantity"`
+	ItemDetails             *ShippedItemDetails `json:"itemDetails,omitempty"`
+}
+
+/*
+ShippedItemDetails ties a shipped item to its purchase order.
+*/
+type ShippedItemDetails struct {
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+}
+
+/*
+TransactionStatus is the processing result of an asynchronous submission.
+*/
+type TransactionStatus struct {
+	TransactionID string     `json:"transactionId"`
+	Status        string     `json:"status"`
+	Errors        []APIError `json:"errors,omitempty"`
+}
+
+/*
+APIError is one error reported by SP‑API.
+*/
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+func (e APIError) Error() string {
+	if e.Details != "" {
+		return e.Code + ": " + e.Message + " (" + e.Details + ")"
+	}
+	return e.Code + ": " + e.Message
+}
+
+/*
+SubmitShipmentConfirmations submits shipment confirmations and returns the
+transaction ID used to check their processing with GetTransaction.
+*/
+func (c *Client) SubmitShipmentConfirmations(ctx context.Context, confirmations []ShipmentConfirmation) (string, error) {
+	in := map[string]interface{}{"shipmentConfirmations": confirmations}
+	var out struct {
+		Payload struct {
+			TransactionID string `json:"transactionId"`
+		} `json:"payload"`
+	}
+	if err := c.call(ctx, "submit_shipment_confirmations", http.MethodPost, "/vendor/shipping/v1/shipmentConfirmations", nil, in, &out); err != nil {
+		return "", err
+	}
+	if out.Payload.TransactionID == "" {
+		return "", fmt.Errorf("submitShipmentConfirmations response has no transactionId")
+	}
+	return out.Payload.TransactionID, nil
+}
+
+/*
+GetTransaction returns the processing status of a submission.
+*/
+func (c *Client) GetTransaction(ctx context.Context, transactionID string) (TransactionStatus, error) {
+	var out struct {
+		Payload struct {
+			TransactionStatus TransactionStatus `json:"transactionStatus"`
+		} `json:"payload"`
+	}
+	err := c.call(ctx, "get_transaction", http.MethodGet, "/vendor/transactions/v1/transactions/"+url.PathEscape(transactionID), nil, nil, &out)
+	return out.Payload.TransactionStatus, err
+}
+
+/*
+WaitForTransaction polls a transaction every interval until it is no longer
+Processing.
+
+Returns:
+  - The final status; check Status and Errors for the outcome.
+  - An error if polling fails or ctx ends.
+*/
+func (c *Client) WaitForTransaction(ctx context.Context, transactionID string, interval time.Duration) (TransactionStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.GetTransaction(ctx, transactionID)
+		if err != nil || status.Status != TransactionProcessing {
+			return status, err
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package spapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSubmitShipmentConfirmations verifies the request shape and transaction polling.
+func TestSubmitShipmentConfirmations(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != "app/1.0 (Language=Go)" {
+			t.Errorf("User-Agent = %q", ua)
+		}
+		switch r.URL.Path {
+		case "/vendor/shipping/v1/shipmentConfirmations":
+			var in struct {
+				ShipmentConfirmations []ShipmentConfirmation `json:"shipmentConfirmations"`
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			if len(in.ShipmentConfirmations) != 1 || in.ShipmentConfirmations[0].ShippedItems[0].ItemDetails.PurchaseOrderNumber != "2JK3S9VC" {
+				t.Errorf("unexpected request %+v", in)
+			}
+			w.Write([]byte(`{"payload":{"transactionId":"T1"}}`))
+		case "/vendor/transactions/v1/transactions/T1":
+			polls++
+			status := TransactionProcessing
+			if polls > 1 {
+				status = TransactionFailure
+			}
+			w.Write([]byte(`{"payload":{"transactionStatus":{"transactionId":"T1","status":"` + status + `","errors":[{"code":"InvalidInput","message":"bad ship-from"}]}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Token: "t", HTTP: srv.Client(), UserAgent: "app/1.0 (Language=Go)"}
+	id, err := c.SubmitShipmentConfirmations(context.Background(), []ShipmentConfirmation{{
+		ShipmentIdentifier:       "ASN1",
+		ShipmentConfirmationType: "Original",
+		ShippedItems: []ShippedItem{{
+			ItemSequenceNumber: "1",
+			ShippedQuantity:    ItemQuantity{Amount: 10, UnitOfMeasure: "Eaches"},
+			ItemDetails:        &ShippedItemDetails{PurchaseOrderNumber: "2JK3S9VC"},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("SubmitShipmentConfirmations() returned %v", err)
+	}
+	status, err := c.WaitForTransaction(context.Background(), id, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForTransaction() returned %v", err)
+	}
+	if status.Status != TransactionFailure || len(status.Errors) != 1 || status.Errors[0].Error() != "InvalidInput: bad ship-from" {
+		t.Errorf("got %+v after %d polls", status, polls)
+	}
+}
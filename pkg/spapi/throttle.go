@@ -0,0 +1,299 @@
+// pkg/spapi/throttle.go
+package spapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Throttle defaults, used for zero ThrottleSettings fields.
+*/
+const (
+	DefaultCooldownAfter = 3
+	DefaultCooldown      = time.Minute
+	DefaultAlertAfter    = 15 * time.Minute
+)
+
+// maxThrottleInterval bounds how far repeated throttling slows an operation down.
+const maxThrottleInterval = time.Minute
+
+/*
+ThrottleSettings configure a Throttle.
+
+Fields:
+  - Rate:          Requests per second of an operation until Amazon reports its
+    limit in x-amzn-RateLimit-Limit; 0 sends unpaced until then.
+  - CooldownAfter: Consecutive throttled responses (429 or 503) that pause an
+    operation for Cooldown; 0 means DefaultCooldownAfter.
+  - Cooldown:      Length of that pause; 0 means DefaultCooldown.
+  - AlertAfter:    How long an operation must stay throttled before OnAlert is
+    called (once per episode); 0 means DefaultAlertAfter.
+  - OnAlert:       Notified with the context of the request that crossed AlertAfter.
+*/
+type ThrottleSettings struct {
+	Rate          float64
+	CooldownAfter int
+	Cooldown      time.Duration
+	AlertAfter    time.Duration
+	OnAlert       func(ctx context.Context, a ThrottleAlert)
+}
+
+/*
+ThrottleAlert describes an operation that stayed throttled beyond AlertAfter.
+
+Fields:
+  - Operation: Method, host and normalized path, e.g. "GET sellingpartnerapi-na.amazon.com/vendor/orders/v1/purchaseOrders/{id}".
+  - Since:     First throttled response of the episode.
+  - Responses: Throttled responses since then.
+  - Rate:      Requests per second the operation is paced at now.
+*/
+type ThrottleAlert struct {
+	Operation string
+	Since     time.Time
+	Responses int
+	Rate      float64
+}
+
+/*
+Throttle is an http.RoundTripper that paces requests per SP‑API operation.
+The pace follows the x-amzn-RateLimit-Limit header Amazon returns with each
+response (requests per second); without it, ThrottleSettings.Rate applies.
+
+Every 429 or 503 response halves the pace of its operation and honours
+Retry-After; successful responses restore it gradually. After CooldownAfter
+consecutive throttled responses the operation pauses for Cooldown, and if it
+is still throttled AlertAfter after the first one, OnAlert is called.
+
+Pacing is logged at debug level of ModuleSPAPI and throttling episodes as
+warnings to Logger (nil discards them). The zero Throttle sends through
+http.DefaultTransport with the defaults.
+*/
+type Throttle struct {
+	Base   http.RoundTripper
+	Logger *utils.Logger
+
+	mu       sync.Mutex
+	settings ThrottleSettings
+	pacers   map[string]*pacer
+}
+
+/*
+pacer is the state of one operation.
+
+Fields:
+  - interval:  Time between requests; 0 is unpaced.
+  - limit:     Interval derived from the last x-amzn-RateLimit-Limit (0 if none seen).
+  - last:      Time slot of the latest request.
+  - hold:      No request before this time (Retry-After or cool-down).
+  - strikes:   Consecutive throttled responses.
+  - since:     First throttled response of the current episode (zero if none).
+  - responses: Throttled responses of the episode.
+  - alerted:   OnAlert was called for the episode.
+*/
+type pacer struct {
+	interval  time.Duration
+	limit     time.Duration
+	last      time.Time
+	hold      time.Time
+	strikes   int
+	since     time.Time
+	responses int
+	alerted   bool
+}
+
+/*
+Configure replaces the settings; operations keep their learned pace.
+*/
+func (t *Throttle) Configure(s ThrottleSettings) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settings = s
+}
+
+/*
+RoundTrip waits for req's turn, sends it through Base and adjusts the pace of
+its operation to the response.
+*/
+func (t *Throttle) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	op := operationKey(req)
+	if err := t.wait(req.Context(), op); err != nil {
+		return nil, err
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.observe(req.Context(), op, resp)
+	return resp, nil
+}
+
+// wait reserves the next slot of op and sleeps until it comes.
+func (t *Throttle) wait(ctx context.Context, op string) error {
+	t.mu.Lock()
+	p := t.pacer(op)
+	at := time.Now()
+	if next := p.last.Add(p.interval); next.After(at) {
+		at = next
+	}
+	if p.hold.After(at) {
+		at = p.hold
+	}
+	p.last = at
+	t.mu.Unlock()
+
+	if delay := time.Until(at); delay > 0 {
+		t.Logger.Debugf(utils.ModuleSPAPI, "Pacing: ", "%s waits %s", op, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// observe adjusts the pace of op to resp.
+func (t *Throttle) observe(ctx context.Context, op string, resp *http.Response) {
+	t.mu.Lock()
+	s := t.settings
+	p := t.pacer(op)
+	now := time.Now()
+	if limit, err := strconv.ParseFloat(resp.Header.Get("x-amzn-RateLimit-Limit"), 64); err == nil && limit > 0 {
+		p.limit = rateInterval(limit)
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		if !p.since.IsZero() && p.alerted {
+			t.Logger.PrintColoredContext(ctx, "SP‑API throttling ended: ", fmt.Sprintf("%s after %s", op, now.Sub(p.since).Round(time.Second)), "#32CD32")
+		}
+		p.strikes, p.since, p.responses, p.alerted = 0, time.Time{}, 0, false
+		// Recover a quarter of the slowdown per success, down to the pace Amazon reports.
+		floor := p.limit
+		if floor == 0 {
+			floor = rateInterval(s.Rate)
+		}
+		if p.interval > floor {
+			p.interval -= (p.interval - floor + 3) / 4
+		} else {
+			p.interval = floor
+		}
+		t.mu.Unlock()
+		return
+	}
+
+	p.strikes++
+	p.responses++
+	if p.since.IsZero() {
+		p.since = now
+	}
+	p.interval = max(2*p.interval, p.limit, 100*time.Millisecond)
+	if p.interval > maxThrottleInterval {
+		p.interval = maxThrottleInterval
+	}
+	if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now); now.Add(retryAfter).After(p.hold) {
+		p.hold = now.Add(retryAfter)
+	}
+	cooldownAfter, cooldown, alertAfter := s.CooldownAfter, s.Cooldown, s.AlertAfter
+	if cooldownAfter <= 0 {
+		cooldownAfter = DefaultCooldownAfter
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+	if alertAfter <= 0 {
+		alertAfter = DefaultAlertAfter
+	}
+	if p.strikes >= cooldownAfter {
+		p.strikes = 0
+		if now.Add(cooldown).After(p.hold) {
+			p.hold = now.Add(cooldown)
+		}
+		t.Logger.LogContext(ctx, utils.LevelWarn, "SP‑API throttled, cooling down: ", fmt.Sprintf("%s for %s after %d throttled responses", op, cooldown, cooldownAfter))
+	} else {
+		t.Logger.Debugf(utils.ModuleSPAPI, "Throttled: ", "%s (%d), pacing at %.3g req/s", op, resp.StatusCode, 1/p.interval.Seconds())
+	}
+	var alert *ThrottleAlert
+	if !p.alerted && now.Sub(p.since) >= alertAfter {
+		p.alerted = true
+		alert = &ThrottleAlert{Operation: op, Since: p.since, Responses: p.responses, Rate: 1 / p.interval.Seconds()}
+	}
+	t.mu.Unlock()
+
+	if alert != nil {
+		t.Logger.LogContext(ctx, utils.LevelError, "SP‑API throttling persists: ", fmt.Sprintf("%s since %s (%d throttled responses)", op, alert.Since.Format(time.RFC3339), alert.Responses))
+		if s.OnAlert != nil {
+			s.OnAlert(ctx, *alert)
+		}
+	}
+}
+
+// pacer returns the state of op, creating it; t.mu must be held.
+func (t *Throttle) pacer(op string) *pacer {
+	if t.pacers == nil {
+		t.pacers = map[string]*pacer{}
+	}
+	p, ok := t.pacers[op]
+	if !ok {
+		p = &pacer{interval: rateInterval(t.settings.Rate)}
+		t.pacers[op] = p
+	}
+	return p
+}
+
+// rateInterval converts requests per second to the time between requests.
+func rateInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+var (
+	dateSegment = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	digits      = regexp.MustCompile(`[0-9]`)
+)
+
+/*
+operationKey names the rate-limited operation of req: its method, host and
+path with identifiers (segments of four or more characters containing digits,
+other than dated API versions) replaced by {id}, so every purchase order
+lookup shares one pace.
+*/
+func operationKey(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, s := range segments {
+		if len(s) > 3 && digits.MatchString(s) && !dateSegment.MatchString(s) {
+			segments[i] = "{id}"
+		}
+	}
+	return req.Method + " " + req.URL.Host + strings.Join(segments, "/")
+}
@@ -0,0 +1,89 @@
+package spapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestThrottlePacesByRateLimitHeader verifies requests of an operation are
+// spaced by the rate Amazon reports in x-amzn-RateLimit-Limit.
+func TestThrottlePacesByRateLimitHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RateLimit-Limit", "20")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Throttle{}}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(srv.URL + "/vendor/orders/v1/purchaseOrders")
+		if err != nil {
+			t.Fatalf("Get() returned %v", err)
+		}
+		resp.Body.Close()
+	}
+	// The first response reports the limit; the three following requests wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 requests took %s; expected pacing at 20 req/s", elapsed)
+	}
+}
+
+// TestThrottleCoolsDownAndAlerts verifies repeated 429s pause the operation
+// and that persisting throttling is reported once.
+func TestThrottleCoolsDownAndAlerts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	var alerts []ThrottleAlert
+	throttle := &Throttle{}
+	throttle.Configure(ThrottleSettings{CooldownAfter: 2, Cooldown: 300 * time.Millisecond, AlertAfter: time.Nanosecond,
+		OnAlert: func(ctx context.Context, a ThrottleAlert) { alerts = append(alerts, a) }})
+	client := &http.Client{Transport: throttle}
+
+	var last time.Time
+	var gaps []time.Duration
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(srv.URL + "/vendor/orders/v1/purchaseOrders/PO123456")
+		if err != nil {
+			t.Fatalf("Get() returned %v", err)
+		}
+		resp.Body.Close()
+		if !last.IsZero() {
+			gaps = append(gaps, time.Since(last))
+		}
+		last = time.Now()
+	}
+	// The third request follows the second throttled response, after the cool-down.
+	if gaps[1] < 250*time.Millisecond {
+		t.Errorf("gap after 2 throttled responses = %s; expected the 300ms cool-down", gaps[1])
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts; expected 1", len(alerts))
+	}
+	if want := "GET " + srv.Listener.Addr().String() + "/vendor/orders/v1/purchaseOrders/{id}"; alerts[0].Operation != want {
+		t.Errorf("alert operation = %q; expected %q", alerts[0].Operation, want)
+	}
+}
+
+// TestOperationKey verifies identifiers collapse while API versions stay.
+func TestOperationKey(t *testing.T) {
+	for path, want := range map[string]string{
+		"/vendor/orders/v1/purchaseOrders/2JK3S9VC": "GET h/vendor/orders/v1/purchaseOrders/{id}",
+		"/reports/2021-06-30/documents/amzn1.doc.1": "GET h/reports/2021-06-30/documents/{id}",
+		"/auth/o2/token": "GET h/auth/o2/token",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "https://h"+path, nil)
+		if got := operationKey(req); got != want {
+			t.Errorf("operationKey(%s) = %q; expected %q", path, got, want)
+		}
+	}
+}
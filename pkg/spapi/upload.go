@@ -0,0 +1,219 @@
+// pkg/spapi/upload.go
+package spapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/audit"
+	"github.com/heinrichb/avcimporter/pkg/tracing"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+UploadDestination is a presigned location returned by the SP‑API Uploads API
+(createUploadDestinationForResource) or the Feeds API (createFeedDocument).
+
+Fields:
+  - ID:                The uploadDestinationId or feedDocumentId to reference later.
+  - URL:               Presigned URL the document is PUT to.
+  - Headers:           Headers that must accompany the PUT (e.g. server-side encryption).
+  - EncryptionDetails: Client-side encryption to apply first, for APIs that still require it.
+*/
+type UploadDestination struct {
+	ID                string             `json:"uploadDestinationId,omitempty"`
+	URL               string             `json:"url"`
+	Headers           map[string]string  `json:"headers,omitempty"`
+	EncryptionDetails *EncryptionDetails `json:"encryptionDetails,omitempty"`
+}
+
+/*
+EncryptionDetails describes client-side AES encryption of an uploaded document.
+
+Fields:
+  - Standard:             Always "AES" (AES-256-CBC with PKCS#7 padding).
+  - InitializationVector: Base64-encoded IV.
+  - Key:                  Base64-encoded 256-bit key.
+*/
+type EncryptionDetails struct {
+	Standard             string `json:"standard"`
+	InitializationVector string `json:"initializationVector"`
+	Key                  string `json:"key"`
+}
+
+/*
+ContentMD5 returns the base64-encoded MD5 digest of data, as required by the
+contentMD5 parameter of createUploadDestinationForResource.
+*/
+func ContentMD5(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+/*
+CreateUploadDestination asks the Uploads API for a presigned destination for
+one document.
+
+Parameters:
+  - ctx:            Context bounding the request.
+  - resource:       Resource the document is for (e.g. "vendor/invoices").
+  - marketplaceIDs: Marketplaces the upload applies to.
+  - contentType:    MIME type of the document (e.g. "application/pdf").
+  - data:           The document, used to compute its MD5 digest.
+
+Returns:
+  - The destination to pass to Upload.
+  - An error if the request fails or returns a non-2xx status.
+*/
+func (c *Client) CreateUploadDestination(ctx context.Context, resource string, marketplaceIDs []string, contentType string, data []byte) (UploadDestination, error) {
+	q := url.Values{
+		"marketplaceIds": {strings.Join(marketplaceIDs, ",")},
+		"contentMD5":     {ContentMD5(data)},
+		"contentType":    {contentType},
+	}
+	var out struct {
+		Payload UploadDestination `json:"payload"`
+	}
+	path := "/uploads/2020-11-01/uploadDestinations/" + strings.TrimPrefix(resource, "/")
+	if err := c.call(ctx, "create_upload_destination", http.MethodPost, path, q, nil, &out); err != nil {
+		return UploadDestination{}, err
+	}
+	if out.Payload.URL == "" {
+		return UploadDestination{}, fmt.Errorf("upload destination response has no url")
+	}
+	return out.Payload, nil
+}
+
+/*
+Upload PUTs a document to a presigned destination, encrypting it first when
+the destination carries EncryptionDetails and sending every header the
+destination requires alongside the content type. Presigned URLs carry their
+own authorization, so no access token is sent.
+
+Parameters:
+  - ctx:         Context bounding the request.
+  - dest:        Destination from CreateUploadDestination or createFeedDocument.
+  - contentType: MIME type of the document; must match the one the destination was created for.
+  - data:        The document.
+
+Returns:
+  - An error if encryption, the request or the upload fails.
+*/
+func (c *Client) Upload(ctx context.Context, dest UploadDestination, contentType string, data []byte) (err error) {
+	ctx, span := tracing.Start(ctx, "spapi.upload", "bytes", len(data))
+	defer func() { span.End(err) }()
+
+	// The audit trail hashes the document itself, not its encrypted form.
+	event := audit.Event{
+		Action: audit.SPAPIUpload,
+		Target: redactURL(dest.URL),
+		SHA256: audit.Sum(data),
+		Bytes:  int64(len(data)),
+		Fields: map[string]string{"destinationId": dest.ID, "contentType": contentType},
+	}
+	if dest.EncryptionDetails != nil {
+		if data, err = Encrypt(*dest.EncryptionDetails, data); err != nil {
+			return err
+		}
+	}
+
+	err = utils.Retry(ctx, c.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, dest.URL, bytes.NewReader(data))
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range dest.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := c.do(req, "upload")
+		if err != nil {
+			return err
+		}
+		span.SetAttr("http.status_code", resp.StatusCode)
+		return resp.Body.Close()
+	})
+	if err == nil {
+		c.recordAudit(event)
+	}
+	return err
+}
+
+// redactURL drops the query of a presigned URL, which carries its credentials.
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		u.RawQuery = ""
+		return u.String()
+	}
+	return ""
+}
+
+/*
+Encrypt applies the client-side encryption described by details to data.
+*/
+func Encrypt(details EncryptionDetails, data []byte) ([]byte, error) {
+	block, iv, err := cipherFor(details)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	out := make([]byte, len(data)+pad)
+	copy(out, data)
+	for i := len(data); i < len(out); i++ {
+		out[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out, nil
+}
+
+/*
+Decrypt reverses Encrypt, e.g. for documents downloaded with EncryptionDetails.
+*/
+func Decrypt(details EncryptionDetails, data []byte) ([]byte, error) {
+	block, iv, err := cipherFor(details)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted document length %d is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, fmt.Errorf("invalid padding in decrypted document")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// cipherFor decodes the key and IV of details.
+func cipherFor(details EncryptionDetails) (cipher.Block, []byte, error) {
+	if details.Standard != "" && details.Standard != "AES" {
+		return nil, nil, fmt.Errorf("unsupported encryption standard %q", details.Standard)
+	}
+	key, err := base64.StdEncoding.DecodeString(details.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(details.InitializationVector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid initialization vector: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, nil, fmt.Errorf("initialization vector must be %d bytes, got %d", aes.BlockSize, len(iv))
+	}
+	return block, iv, nil
+}
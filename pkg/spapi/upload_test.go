@@ -0,0 +1,50 @@
+package spapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestUploadEncryptsAndSendsHeaders verifies required headers are sent and the body is encrypted.
+func TestUploadEncryptsAndSendsHeaders(t *testing.T) {
+	details := EncryptionDetails{
+		Standard:             "AES",
+		Key:                  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+		InitializationVector: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 16)),
+	}
+	doc := []byte("%PDF-1.4 invoice")
+
+	var got []byte
+	var sse, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		sse = r.Header.Get("x-amz-server-side-encryption")
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	dest := UploadDestination{
+		URL:               srv.URL + "/doc?X-Amz-Signature=abc",
+		Headers:           map[string]string{"x-amz-server-side-encryption": "AES256"},
+		EncryptionDetails: &details,
+	}
+	c := &Client{HTTP: srv.Client()}
+	if err := c.Upload(context.Background(), dest, "application/pdf", doc); err != nil {
+		t.Fatalf("Upload() returned %v", err)
+	}
+	if sse != "AES256" || contentType != "application/pdf" {
+		t.Errorf("headers = %q, %q; expected AES256, application/pdf", sse, contentType)
+	}
+	plain, err := Decrypt(details, got)
+	if err != nil {
+		t.Fatalf("Decrypt() returned %v", err)
+	}
+	if !bytes.Equal(plain, doc) {
+		t.Errorf("uploaded document decrypts to %q; expected %q", plain, doc)
+	}
+}
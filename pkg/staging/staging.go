@@ -0,0 +1,368 @@
+// pkg/staging/staging.go
+package staging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Run is the staging area of one import run. Files the run writes go below it
+instead of the storage directory, state files (checkpoints, dedupe keys, ...)
+are edited through staged copies, and steps with effects outside the machine
+(acknowledgments, exports, remote deletions) are queued. Commit then publishes
+everything at once; Discard throws it away, leaving storage and the partners
+as they were before the run.
+
+The area is a directory "<root>/<run id>" holding "files" (mirroring the
+storage directory) and "state". A run interrupted before Commit or Discard
+leaves it behind; it can be deleted.
+*/
+type Run struct {
+	mu       sync.Mutex
+	dir      string
+	target   string
+	states   []state
+	commits  []step
+	discards []func() error
+	perms    *utils.Permissions
+}
+
+// step is a queued commit action.
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// state is a staged copy of a JSON state file and the content it started from.
+type state struct {
+	path   string
+	staged string
+	base   map[string]json.RawMessage
+}
+
+/*
+Begin creates the staging area of a new run below root for files that belong
+in target (storage.savePath). Staged and published files are written with perms
+(nil uses utils.DefaultPermissions).
+*/
+func Begin(root, target string, perms *utils.Permissions) (*Run, error) {
+	id := time.Now().UTC().Format("20060102T150405.000000000")
+	dir := filepath.Join(root, fmt.Sprintf("%s-%d", id, os.Getpid()))
+	if err := perms.MkdirAll(filepath.Join(dir, "files")); err != nil {
+		return nil, fmt.Errorf("failed to create staging area: %w", err)
+	}
+	if err := perms.MkdirAll(filepath.Join(dir, "state")); err != nil {
+		return nil, fmt.Errorf("failed to create staging area: %w", err)
+	}
+	return &Run{dir: dir, target: filepath.Clean(target), perms: perms}, nil
+}
+
+/*
+Dir returns the run's staging directory.
+*/
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+/*
+Path returns where a file destined for p is staged. Paths outside the storage
+directory are returned unchanged.
+*/
+func (r *Run) Path(p string) string {
+	return rebase(p, r.target, filepath.Join(r.dir, "files"))
+}
+
+/*
+Final returns where the staged file p ends up once the run is committed.
+Paths outside the staging area are returned unchanged.
+*/
+func (r *Run) Final(p string) string {
+	return rebase(p, filepath.Join(r.dir, "files"), r.target)
+}
+
+// rebase moves p from below dir to below to, or returns it unchanged.
+func rebase(p, dir, to string) string {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || !filepath.IsLocal(rel) {
+		return p
+	}
+	return filepath.Join(to, rel)
+}
+
+/*
+Stage copies the JSON state file at path into the staging area and returns the
+copy for the run to use. The file must hold one JSON object; on Commit, the
+keys the run added, changed or removed in the copy are applied to the file as
+it is then, so concurrent changes to other keys survive.
+*/
+func (r *Run) Stage(path string) (string, error) {
+	base, err := readObject(path)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	staged := filepath.Join(r.dir, "state", fmt.Sprintf("%d-%s", len(r.states), filepath.Base(path)))
+	if len(base) > 0 {
+		if err := writeObject(staged, base, r.perms); err != nil {
+			return "", err
+		}
+	}
+	r.states = append(r.states, state{path: path, staged: staged, base: base})
+	return staged, nil
+}
+
+/*
+Unstaged returns the state file the staged copy at path (see Stage) stands
+for, e.g. to update it from a commit step once the staged copies are applied.
+Other paths are returned unchanged.
+*/
+func (r *Run) Unstaged(path string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.states {
+		if s.staged == path {
+			return s.path
+		}
+	}
+	return path
+}
+
+/*
+OnCommit queues fn to run on Commit, after the files are in place. Steps run
+in the order they were queued.
+*/
+func (r *Run) OnCommit(name string, fn func(ctx context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, step{name: name, fn: fn})
+}
+
+/*
+OnDiscard queues fn to run on Discard, e.g. to release a claim the run made.
+*/
+func (r *Run) OnDiscard(fn func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discards = append(r.discards, fn)
+}
+
+/*
+Commit moves the staged files into the storage directory, applies the staged
+state and runs the queued steps. A failing step does not stop the others. The
+staging area is removed unless something failed.
+
+Returns:
+  - The final paths of the files moved.
+  - The errors of the moves, state updates and steps that failed.
+*/
+func (r *Run) Commit(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	moved, err := r.moveFiles()
+	if err != nil {
+		return moved, err
+	}
+	var errs []error
+	for _, s := range r.states {
+		if err := s.apply(r.perms); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range r.commits {
+		if err := s.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, os.RemoveAll(r.dir))
+	}
+	return moved, errors.Join(errs...)
+}
+
+/*
+Discard runs the queued discard steps and removes the staging area; nothing
+the run staged is published.
+*/
+func (r *Run) Discard() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, fn := range r.discards {
+		errs = append(errs, fn())
+	}
+	errs = append(errs, os.RemoveAll(r.dir))
+	return errors.Join(errs...)
+}
+
+// moveFiles moves every staged file to its final path, replacing what is there.
+func (r *Run) moveFiles() ([]string, error) {
+	var moved []string
+	root := filepath.Join(r.dir, "files")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		final := r.Final(p)
+		if err := r.perms.MkdirAll(filepath.Dir(final)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(final), err)
+		}
+		if err := move(p, final, r.perms); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", final, err)
+		}
+		moved = append(moved, final)
+		return nil
+	})
+	return moved, err
+}
+
+// move renames src to dst, copying with perms when they are on different file
+// systems.
+func move(src, dst string, perms *utils.Permissions) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".staged-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := perms.Apply(tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// apply merges the keys the run changed into the state file.
+func (s state) apply(perms *utils.Permissions) error {
+	staged, err := readObject(s.staged)
+	if err != nil {
+		return err
+	}
+	current, err := readObject(s.path)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for k, v := range staged {
+		if !sameJSON(v, s.base[k]) {
+			current[k] = v
+			changed = true
+		}
+	}
+	for k := range s.base {
+		if _, ok := staged[k]; !ok {
+			delete(current, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return writeObject(s.path, current, perms)
+}
+
+// sameJSON reports whether a and b encode the same value, ignoring layout.
+func sameJSON(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// readObject loads a JSON object file; a missing file is an empty object.
+func readObject(path string) (map[string]json.RawMessage, error) {
+	all := make(map[string]json.RawMessage)
+	data, err := codec.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return all, nil
+}
+
+// writeObject replaces a JSON object file via a temporary file and rename,
+// with perms.
+func writeObject(path string, all map[string]json.RawMessage, perms *utils.Permissions) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err == nil {
+		data, err = codec.Seal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := perms.MkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := perms.Apply(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// runKey is the context key for the Run of the current import.
+type runKey struct{}
+
+/*
+WithRun returns a context carrying r, so flows stage what they write.
+*/
+func WithRun(ctx context.Context, r *Run) context.Context {
+	return context.WithValue(ctx, runKey{}, r)
+}
+
+/*
+FromContext returns the Run in ctx, or nil if the import is not staged.
+*/
+func FromContext(ctx context.Context) *Run {
+	r, _ := ctx.Value(runKey{}).(*Run)
+	return r
+}
@@ -0,0 +1,114 @@
+package staging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCommit verifies staged files land in storage, state changes are merged
+// over concurrent ones and queued steps run afterwards.
+func TestCommit(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "out")
+	cps := filepath.Join(root, "checkpoints.json")
+	writeJSON(t, cps, map[string]string{"edi": "100", "api": "old", "gone": "x"})
+
+	r, err := Begin(filepath.Join(root, "staging"), target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged := r.Path(filepath.Join(target, "edi", "850.x12"))
+	if err := os.MkdirAll(filepath.Dir(staged), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged, []byte("ST*850"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Path(filepath.Join(root, "elsewhere")); got != filepath.Join(root, "elsewhere") {
+		t.Errorf("Path() outside storage = %s; expected it unchanged", got)
+	}
+
+	stagedCps, err := r.Stage(cps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, stagedCps, map[string]string{"edi": "101", "api": "old"})
+	// Another process advances a different key while the run is staged.
+	writeJSON(t, cps, map[string]string{"edi": "100", "api": "new", "gone": "x"})
+
+	var hookPath string
+	r.OnCommit("export", func(ctx context.Context) error {
+		if _, err := os.Stat(r.Final(staged)); err != nil {
+			t.Errorf("step ran before the file was published: %v", err)
+		}
+		hookPath = r.Final(staged)
+		return nil
+	})
+	r.OnDiscard(func() error { t.Error("discard step ran on Commit"); return nil })
+
+	moved, err := r.Commit(context.Background())
+	if err != nil {
+		t.Fatalf("Commit() returned %v", err)
+	}
+	want := filepath.Join(target, "edi", "850.x12")
+	if len(moved) != 1 || moved[0] != want || hookPath != want {
+		t.Errorf("moved %v, step saw %s; expected %s", moved, hookPath, want)
+	}
+	var got map[string]string
+	data, _ := os.ReadFile(cps)
+	json.Unmarshal(data, &got)
+	if len(got) != 2 || got["edi"] != "101" || got["api"] != "new" {
+		t.Errorf("checkpoints = %v; expected edi advanced, api kept and gone removed", got)
+	}
+	if _, err := os.Stat(r.Dir()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("staging area left behind: %v", err)
+	}
+}
+
+// TestDiscard verifies nothing staged is published and discard steps run.
+func TestDiscard(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "out")
+	r, err := Begin(filepath.Join(root, "staging"), target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(r.Path(filepath.Join(target, "order.json")), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stagedCps, err := r.Stage(filepath.Join(root, "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, stagedCps, map[string]string{"api": "new"})
+	released := false
+	r.OnDiscard(func() error { released = true; return nil })
+	r.OnCommit("ack", func(ctx context.Context) error { t.Error("commit step ran on Discard"); return nil })
+
+	if err := r.Discard(); err != nil {
+		t.Fatalf("Discard() returned %v", err)
+	}
+	if !released {
+		t.Error("discard step did not run")
+	}
+	for _, p := range []string{target, filepath.Join(root, "checkpoints.json"), r.Dir()} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists after Discard", p)
+		}
+	}
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,186 @@
+// pkg/storage/decompress.go
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// Compression formats recognised by Decompress.
+const (
+	FormatNone = ""
+	FormatGzip = "gzip"
+	FormatZip  = "zip"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+/*
+DetectCompression reports whether the file at path is gzip- or zip-compressed,
+judging by its leading bytes rather than its name (partners do not always use
+the .gz/.zip extensions).
+
+Returns:
+  - FormatGzip, FormatZip or FormatNone.
+  - An error if the file cannot be read.
+*/
+func DetectCompression(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FormatNone, err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatNone, err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return FormatGzip, nil
+	case bytes.HasPrefix(head, zipMagic):
+		return FormatZip, nil
+	}
+	return FormatNone, nil
+}
+
+/*
+Decompress replaces a compressed inbound file with its content. The original
+is moved into archiveDir and the content is written next to where it was: a
+gzip file becomes one file named without its .gz suffix, a zip file becomes
+one file per entry (directories inside the archive are flattened). Files that
+are not compressed are left alone.
+
+Parameters:
+  - path:       The downloaded file.
+  - archiveDir: Directory receiving the original (storage.archivePath).
+  - perms:      Mode and ownership of the extracted files; nil uses utils.DefaultPermissions.
+
+Returns:
+  - The paths to process: the extracted files, or path itself if it was not compressed.
+  - An error if the file cannot be read, archived or extracted. The original is moved back when extraction fails.
+*/
+func Decompress(path, archiveDir string, perms *utils.Permissions) ([]string, error) {
+	format, err := DetectCompression(path)
+	if err != nil || format == FormatNone {
+		return []string{path}, err
+	}
+	if err := perms.MkdirAll(archiveDir); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	archived := archivePath(archiveDir, filepath.Base(path))
+	if err := os.Rename(path, archived); err != nil {
+		return nil, fmt.Errorf("failed to archive %s: %w", path, err)
+	}
+
+	var files []string
+	if format == FormatGzip {
+		files, err = gunzipFile(archived, strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".gzip"), perms)
+	} else {
+		files, err = unzipFile(archived, filepath.Dir(path), perms)
+	}
+	if err != nil {
+		for _, f := range files {
+			os.Remove(f)
+		}
+		if rerr := os.Rename(archived, path); rerr != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w (original left at %s)", path, err, archived)
+		}
+		return nil, fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	return files, nil
+}
+
+// archivePath returns a path in dir for name that does not overwrite an
+// earlier original of the same name.
+func archivePath(dir, name string) string {
+	p := filepath.Join(dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return p
+	}
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+time.Now().UTC().Format("20060102T150405.000000000Z")+ext)
+}
+
+// gunzipFile writes the content of the gzip file src to dst.
+func gunzipFile(src, dst string, perms *utils.Permissions) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if err := writeAtomic(dst, zr, perms); err != nil {
+		return nil, err
+	}
+	return []string{dst}, nil
+}
+
+// unzipFile writes every file entry of the zip archive src into dir.
+func unzipFile(src, dir string, perms *utils.Permissions) ([]string, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var files []string
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		// Only the base name is used, so entries cannot escape dir.
+		name := filepath.Base(filepath.FromSlash(entry.Name))
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return files, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		dst := filepath.Join(dir, name)
+		err = writeAtomic(dst, rc, perms)
+		rc.Close()
+		if err != nil {
+			return files, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		files = append(files, dst)
+	}
+	return files, nil
+}
+
+// writeAtomic copies r to path through a temporary file, with perms.
+func writeAtomic(path string, r io.Reader, perms *utils.Permissions) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := perms.Apply(tmp.Name()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
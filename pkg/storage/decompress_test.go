@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDecompressGzip verifies a gzip file is replaced by its content and archived.
+func TestDecompressGzip(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("ISA*00~"))
+	zw.Close()
+	src := filepath.Join(dir, "po.edi.gz")
+	os.WriteFile(src, buf.Bytes(), 0o644)
+
+	files, err := Decompress(src, filepath.Join(dir, "archive"), nil)
+	if err != nil {
+		t.Fatalf("Decompress() returned %v", err)
+	}
+	want := filepath.Join(dir, "po.edi")
+	if len(files) != 1 || files[0] != want {
+		t.Fatalf("files = %v; expected [%s]", files, want)
+	}
+	if data, _ := os.ReadFile(want); string(data) != "ISA*00~" {
+		t.Errorf("content = %q; expected ISA*00~", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive", "po.edi.gz")); err != nil {
+		t.Errorf("original not archived: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("original still present: %v", err)
+	}
+}
+
+// TestDecompressZip verifies every entry is extracted without leaving the directory.
+func TestDecompressZip(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.csv", "../../b.csv", "sub/"} {
+		w, _ := zw.Create(name)
+		if name != "sub/" {
+			w.Write([]byte(name))
+		}
+	}
+	zw.Close()
+	// No .zip extension: detection goes by content.
+	src := filepath.Join(dir, "batch")
+	os.WriteFile(src, buf.Bytes(), 0o644)
+
+	files, err := Decompress(src, filepath.Join(dir, "archive"), nil)
+	if err != nil {
+		t.Fatalf("Decompress() returned %v", err)
+	}
+	want := []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv")}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("files = %v; expected %v", files, want)
+	}
+}
+
+// TestDecompressPlain verifies uncompressed files are passed through untouched.
+func TestDecompressPlain(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "po.edi")
+	os.WriteFile(src, []byte("ISA*00~"), 0o644)
+
+	files, err := Decompress(src, filepath.Join(dir, "archive"), nil)
+	if err != nil || len(files) != 1 || files[0] != src {
+		t.Errorf("Decompress() = %v, %v; expected [%s]", files, err, src)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive")); !os.IsNotExist(err) {
+		t.Error("archive directory created for a plain file")
+	}
+}
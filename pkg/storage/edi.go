@@ -0,0 +1,52 @@
+// pkg/storage/edi.go
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+/*
+SaveTransactionSet writes a standalone interchange holding one transaction set
+(as returned by x12.Document.Split) into dir under the name rendered by namer
+for the "edi" type, compressed if the namer says so.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - doc:   Interchange with exactly one functional group and transaction set.
+
+Returns:
+  - The path of the written file.
+  - An error if doc holds no transaction set or writing fails.
+*/
+func SaveTransactionSet(dir string, namer *Namer, doc *x12.Document) (string, error) {
+	groups := doc.Groups()
+	if len(groups) == 0 || len(groups[0].Sets) == 0 {
+		return "", fmt.Errorf("interchange holds no transaction set")
+	}
+	group, set := groups[0], groups[0].Sets[0]
+	data := NameData{
+		SetID:         set.ID(),
+		GroupControl:  group.ControlNumber(),
+		ControlNumber: set.ControlNumber(),
+		Hash:          ContentHash(doc.Bytes()),
+	}
+	if isa, ok := doc.Find("ISA"); ok {
+		data.Partner = strings.TrimSpace(isa.Element(6))
+	}
+	if beg, ok := doc.Find("BEG"); ok {
+		data.PONumber = beg.Element(3)
+	}
+	name, err := namer.Name(TypeTransactionSet, data)
+	if err != nil {
+		return "", err
+	}
+	content, err := namer.encode(TypeTransactionSet, doc.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return save(dir, name, content, namer.perms)
+}
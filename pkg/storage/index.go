@@ -0,0 +1,146 @@
+// pkg/storage/index.go
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+IndexEntry records one processed inbound file.
+
+Fields:
+  - SHA256:      Hex SHA‑256 of the file content.
+  - Source:      File name when it was processed.
+  - Host:        Machine that processed it.
+  - ProcessedAt: When processing started.
+  - Outputs:     Files written from it.
+*/
+type IndexEntry struct {
+	SHA256      string    `json:"sha256"`
+	Source      string    `json:"source"`
+	Host        string    `json:"host"`
+	ProcessedAt time.Time `json:"processedAt"`
+	Outputs     []string  `json:"outputs,omitempty"`
+}
+
+/*
+Index is a sidecar index of processed inbound files keyed by content hash, so
+a file delivered or fetched again (under any name) is recognized and not
+imported twice. Each entry is a small JSON file named after the hash; it is
+created exclusively, so machines sharing the directory cannot both claim the
+same content.
+*/
+type Index struct {
+	dir   string
+	perms *utils.Permissions
+}
+
+/*
+OpenIndex returns the index kept in dir (storage.inboundIndex), writing its
+entries with perms (nil uses utils.DefaultPermissions).
+*/
+func OpenIndex(dir string, perms *utils.Permissions) *Index {
+	return &Index{dir: dir, perms: perms}
+}
+
+/*
+FileSHA256 returns the hex SHA‑256 of data, the key of an IndexEntry.
+*/
+func FileSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Claim records that this machine processes the content with the given hash.
+
+Returns:
+  - true and nil if the claim was made; the caller processes the file and then calls Complete, or Release on failure.
+  - false and the existing entry if the content was processed (or is being processed) already.
+*/
+func (x *Index) Claim(entry IndexEntry) (bool, IndexEntry, error) {
+	if err := x.perms.MkdirAll(x.dir); err != nil {
+		return false, IndexEntry{}, fmt.Errorf("failed to create index dir: %w", err)
+	}
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+	if entry.ProcessedAt.IsZero() {
+		entry.ProcessedAt = time.Now().UTC()
+	}
+	f, err := os.OpenFile(x.path(entry.SHA256), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		existing, err := x.Lookup(entry.SHA256)
+		return false, existing, err
+	}
+	if err != nil {
+		return false, IndexEntry{}, fmt.Errorf("failed to claim %s: %w", entry.Source, err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		return false, IndexEntry{}, fmt.Errorf("failed to write index entry: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, IndexEntry{}, fmt.Errorf("failed to write index entry: %w", err)
+	}
+	return true, entry, x.perms.Apply(f.Name())
+}
+
+/*
+Complete rewrites a claimed entry with the files written from it.
+*/
+func (x *Index) Complete(entry IndexEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := x.path(entry.SHA256)
+	tmp := path + ".tmp"
+	if err := x.perms.WriteFile(tmp, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write index entry: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+/*
+Release removes a claim after processing failed, so the file is retried.
+*/
+func (x *Index) Release(sha string) error {
+	if err := os.Remove(x.path(sha)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+/*
+Lookup returns the entry of a hash. An entry that is still being written
+reads as an entry with only its hash set.
+*/
+func (x *Index) Lookup(sha string) (IndexEntry, error) {
+	entry := IndexEntry{SHA256: sha}
+	data, err := os.ReadFile(x.path(sha))
+	if err != nil {
+		return entry, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return entry, fmt.Errorf("invalid index entry %s: %w", x.path(sha), err)
+		}
+	}
+	return entry, nil
+}
+
+// path returns the entry file of a hash.
+func (x *Index) path(sha string) string {
+	return filepath.Join(x.dir, sha+".json")
+}
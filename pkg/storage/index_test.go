@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestIndexClaim verifies the same content can only be claimed once, even
+// through a second Index on the same directory, and that a release allows a retry.
+func TestIndexClaim(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "processed")
+	sha := FileSHA256([]byte("ISA*00*..."))
+	a, b := OpenIndex(dir, nil), OpenIndex(dir, nil)
+
+	ok, _, err := a.Claim(IndexEntry{SHA256: sha, Source: "in_1.edi", Host: "host-a"})
+	if err != nil || !ok {
+		t.Fatalf("Claim() = %v, %v; expected the first claim to succeed", ok, err)
+	}
+	if err := a.Complete(IndexEntry{SHA256: sha, Source: "in_1.edi", Host: "host-a", Outputs: []string{"out.edi"}}); err != nil {
+		t.Fatalf("Complete() returned %v", err)
+	}
+
+	ok, existing, err := b.Claim(IndexEntry{SHA256: sha, Source: "renamed.edi", Host: "host-b"})
+	if err != nil || ok {
+		t.Fatalf("Claim() of the same content = %v, %v; expected it to be rejected", ok, err)
+	}
+	if existing.Host != "host-a" || existing.Source != "in_1.edi" || len(existing.Outputs) != 1 {
+		t.Errorf("existing entry = %+v; expected host-a's entry", existing)
+	}
+
+	if err := a.Release(sha); err != nil {
+		t.Fatalf("Release() returned %v", err)
+	}
+	if ok, _, err := b.Claim(IndexEntry{SHA256: sha, Source: "renamed.edi"}); err != nil || !ok {
+		t.Errorf("Claim() after Release = %v, %v; expected a retry to be allowed", ok, err)
+	}
+}
@@ -0,0 +1,72 @@
+// pkg/storage/janitor.go
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tempPattern matches the hidden files os.CreateTemp creates for atomic
+// writes, e.g. ".checkpoints-123456789" or ".order-987654321".
+var tempPattern = regexp.MustCompile(`^\.[A-Za-z0-9_]+-\d+$`)
+
+/*
+IsTemp reports whether name is a file written only while a write or download
+is in progress: a ".part" download, a ".tmp" file, or a hidden os.CreateTemp file.
+*/
+func IsTemp(name string) bool {
+	return strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".tmp") || tempPattern.MatchString(name)
+}
+
+/*
+CleanTemp removes temporary files (see IsTemp) left in dir by interrupted
+writes and downloads. Only files not modified for olderThan are removed, so
+writes still in progress are left alone.
+
+Parameters:
+  - dir:       The directory to clean; a missing directory is skipped.
+  - recursive: Whether subdirectories are cleaned too.
+  - olderThan: Minimum age of a removed file.
+
+Returns:
+  - The removed files.
+  - An error if dir cannot be read; files that cannot be removed are skipped.
+*/
+func CleanTemp(dir string, recursive bool, olderThan time.Duration) ([]string, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var removed []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !IsTemp(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if os.Remove(path) == nil {
+			removed = append(removed, path)
+		}
+		return nil
+	})
+	return removed, err
+}
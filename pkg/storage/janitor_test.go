@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestCleanTemp verifies only old temporary files are removed, in
+// subdirectories only when asked.
+func TestCleanTemp(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(name string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("PO_1.edi.part", old)
+	write("index/abc.json.tmp", old)
+	write(".checkpoints-12345", old)
+	write(".extract-999", time.Now())
+	write("PO_2.edi", old)
+	write(".gitkeep", old)
+
+	removed, err := CleanTemp(dir, false, time.Hour)
+	if err != nil {
+		t.Fatalf("CleanTemp() returned %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("non-recursive CleanTemp() removed %v; expected 2 files", removed)
+	}
+	removed, _ = CleanTemp(dir, true, time.Hour)
+	if len(removed) != 1 || filepath.Base(removed[0]) != "abc.json.tmp" {
+		t.Errorf("recursive CleanTemp() removed %v; expected abc.json.tmp", removed)
+	}
+
+	var left []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if !d.IsDir() {
+			left = append(left, d.Name())
+		}
+		return nil
+	})
+	sort.Strings(left)
+	if want := []string{".extract-999", ".gitkeep", "PO_2.edi"}; len(left) != 3 || left[0] != want[0] || left[1] != want[1] || left[2] != want[2] {
+		t.Errorf("left %v; expected %v", left, want)
+	}
+
+	if removed, err := CleanTemp(filepath.Join(dir, "missing"), true, time.Hour); err != nil || len(removed) != 0 {
+		t.Errorf("CleanTemp() of a missing dir = %v, %v", removed, err)
+	}
+}
@@ -0,0 +1,43 @@
+// pkg/storage/manifest.go
+package storage
+
+import (
+	"path/filepath"
+
+	"github.com/heinrichb/avcimporter/pkg/output"
+)
+
+/*
+SaveManifest writes a run's integrity manifest, stamped with SchemaVersion,
+into dir under the name rendered by namer for the "manifest" type. Artifact paths inside dir are
+stored relative to it (with forward slashes), so the batch can be verified
+wherever the directory is copied.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - m:     The manifest; its FinishedAt names the file.
+
+Returns:
+  - The path of the written manifest.
+  - An error if the name cannot be rendered or the file cannot be written.
+*/
+func SaveManifest(dir string, namer *Namer, m *output.Manifest) (string, error) {
+	name, err := namer.Name(TypeManifest, NameData{Flow: m.Flow, Time: m.FinishedAt})
+	if err != nil {
+		return "", err
+	}
+	out := *m
+	out.Files = make([]output.Artifact, len(m.Files))
+	for i, a := range m.Files {
+		if rel, err := filepath.Rel(dir, a.Path); err == nil && filepath.IsLocal(rel) {
+			a.Path = filepath.ToSlash(rel)
+		}
+		out.Files[i] = a
+	}
+	data, err := encodeVersioned(out)
+	if err != nil {
+		return "", err
+	}
+	return save(dir, name, data, namer.perms)
+}
@@ -0,0 +1,257 @@
+// pkg/storage/naming.go
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+Document types that have a configurable file name.
+*/
+const (
+	TypeOrder          = "order"
+	TypeTransactionSet = "edi"
+	TypeAck            = "ack"
+	TypeReport         = "report"
+	TypeDataKiosk      = "datakiosk"
+	TypeManifest       = "manifest"
+	TypeSummary        = "summary"
+	TypeDocument       = "document"
+)
+
+/*
+DefaultFileNames are the templates used for document types without a
+configured template. They reproduce the historical names.
+*/
+var DefaultFileNames = map[string]string{
+	TypeOrder:          "{{.Base}}_{{.PONumber}}.{{.Ext}}",
+	TypeTransactionSet: "{{.Base}}_{{.SetID}}_{{.GroupControl}}_{{.ControlNumber}}.{{.Ext}}",
+	TypeAck:            "997_{{.Source}}",
+	TypeReport:         "{{.Base}}_{{.ReportType}}_{{.Timestamp}}.{{.Ext}}",
+	TypeDataKiosk:      "{{.Base}}_{{.Query}}_{{.Timestamp}}.{{.Ext}}",
+	TypeManifest:       "manifests/{{.Base}}_{{.Flow}}_{{.Timestamp}}.{{.Ext}}",
+	TypeSummary:        "summaries/{{.Base}}_summary_{{.Time.Format \"2006-01-02\"}}.{{.Ext}}",
+	TypeDocument:       "{{.Base}}_{{.PONumber}}_{{.Document}}.{{.Ext}}",
+}
+
+/*
+NameData is the data available to file name templates.
+
+Fields:
+  - Type:          Document type ("order", "edi", "ack", "report", "datakiosk", "manifest", "summary", "document").
+  - Base:          storage.fileName.
+  - PONumber:      Purchase order number, if the document has one.
+  - SetID:         X12 transaction set ID (e.g. "850").
+  - GroupControl:  X12 functional group control number (GS06).
+  - ControlNumber: X12 transaction set control number (ST02).
+  - Partner:       Trading partner ID.
+  - ReportType:    SP‑API report type (e.g. "GET_VENDOR_SALES_REPORT").
+  - Query:         Name of a Data Kiosk query (dataKiosk.queries[].name).
+  - Flow:          Flow that produced a manifest ("edi", "api", ...).
+  - Source:        Name of the file the document was derived from.
+  - Document:      Printout of an order ("po", or "packing_slip" with the warehouse appended when the order is split).
+  - Hash:          ContentHash of the written content ("order" and "edi" only), so a rerun on the same input writes the same name.
+  - Ext:           Default extension for the type, without the dot.
+  - Timestamp:     Time formatted as 20060102T150405 in the configured time zone.
+  - Time:          The time itself, for custom layouts: {{.Time.Format "2006-01-02"}}.
+*/
+type NameData struct {
+	Type          string
+	Base          string
+	PONumber      string
+	SetID         string
+	GroupControl  string
+	ControlNumber string
+	Partner       string
+	ReportType    string
+	Query         string
+	Flow          string
+	Source        string
+	Document      string
+	Hash          string
+	Ext           string
+	Timestamp     string
+	Time          time.Time
+}
+
+/*
+Namer renders file names from per-type templates.
+*/
+type Namer struct {
+	base      string
+	location  *time.Location
+	templates map[string]*template.Template
+	now       func() time.Time
+	codec     codec.Codec
+	perms     *utils.Permissions
+}
+
+/*
+NewNamer compiles the file name templates.
+
+Parameters:
+  - base:      Base file name available as {{.Base}} (storage.fileName).
+  - templates: Templates keyed by document type (storage.fileNames); types
+    without an entry use DefaultFileNames.
+  - loc:       Time zone for timestamps (the configured timezone); nil means UTC.
+
+Returns:
+  - The Namer.
+  - An error naming the first template that is invalid.
+*/
+func NewNamer(base string, templates map[string]string, loc *time.Location) (*Namer, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	n := &Namer{base: base, location: loc, templates: map[string]*template.Template{}, now: time.Now}
+
+	merged := make(map[string]string, len(DefaultFileNames))
+	for typ, text := range DefaultFileNames {
+		merged[typ] = text
+	}
+	for typ, text := range templates {
+		if _, ok := DefaultFileNames[typ]; !ok {
+			return nil, fmt.Errorf("unknown document type %q in file names (known: %s)", typ, knownTypes())
+		}
+		merged[typ] = text
+	}
+	for typ, text := range merged {
+		t, err := template.New(typ).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file name template for %s: %w", typ, err)
+		}
+		n.templates[typ] = t
+		// Render once so unknown fields are reported now rather than mid-run.
+		if _, err := n.Name(typ, NameData{PONumber: "PO", SetID: "850", GroupControl: "1", ControlNumber: "0001", Partner: "AMAZON", ReportType: "REPORT", Query: "query", Flow: "flow", Source: "file", Document: "po", Hash: "0123456789abcdef"}); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+/*
+Location returns the time zone used for timestamps.
+*/
+func (n *Namer) Location() *time.Location {
+	return n.location
+}
+
+/*
+SetCompression makes orders and EDI transaction sets be stored compressed with
+c (storage.compression); their names get the codec's extension appended. A nil
+codec stores them as they are.
+*/
+func (n *Namer) SetCompression(c codec.Codec) {
+	n.codec = c
+}
+
+/*
+SetPermissions makes the files saved under names of n be written with p
+(storage.permissions). A nil p uses utils.DefaultPermissions.
+*/
+func (n *Namer) SetPermissions(p *utils.Permissions) {
+	n.perms = p
+}
+
+/*
+Name renders the file name for a document of the given type. Type, Base, Ext,
+Time and Timestamp are filled in when not set in data. The result may contain
+sub-directories but must stay inside the storage directory.
+*/
+func (n *Namer) Name(typ string, data NameData) (string, error) {
+	t, ok := n.templates[typ]
+	if !ok {
+		return "", fmt.Errorf("unknown document type %q", typ)
+	}
+	data.Type = typ
+	if data.Base == "" {
+		data.Base = n.base
+	}
+	if data.Ext == "" {
+		data.Ext = defaultExt(typ)
+	}
+	if data.Time.IsZero() {
+		data.Time = n.now()
+	}
+	data.Time = data.Time.In(n.location)
+	if data.Timestamp == "" {
+		data.Timestamp = data.Time.Format("20060102T150405")
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s file name: %w", typ, err)
+	}
+	name := filepath.FromSlash(strings.TrimSpace(b.String()))
+	if name == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%s file name %q must be a relative path inside the storage directory", typ, name)
+	}
+	if n.compresses(typ) {
+		name += n.codec.Ext()
+	}
+	return name, nil
+}
+
+// compresses reports whether documents of typ are stored compressed.
+func (n *Namer) compresses(typ string) bool {
+	return n.codec != nil && (typ == TypeOrder || typ == TypeTransactionSet)
+}
+
+/*
+encode prepares the content of a document of typ for storage: compressed if
+the type is stored compressed, then encrypted if storage.encryption is set.
+*/
+func (n *Namer) encode(typ string, data []byte) ([]byte, error) {
+	if n.compresses(typ) {
+		var err error
+		if data, err = codec.Encode(n.codec, data); err != nil {
+			return nil, err
+		}
+	}
+	return codec.Seal(data)
+}
+
+/*
+ContentHash returns the first 16 hex digits of the SHA‑256 of data, used as
+{{.Hash}} in file names.
+*/
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// defaultExt returns the extension written for a document type.
+func defaultExt(typ string) string {
+	switch typ {
+	case TypeOrder, TypeReport, TypeManifest:
+		return "json"
+	case TypeDataKiosk:
+		return "jsonl"
+	case TypeSummary:
+		return "xlsx"
+	case TypeDocument:
+		return "pdf"
+	default:
+		return "edi"
+	}
+}
+
+// knownTypes lists the configurable document types.
+func knownTypes() string {
+	types := make([]string, 0, len(DefaultFileNames))
+	for typ := range DefaultFileNames {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
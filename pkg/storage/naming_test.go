@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestNamerTemplates verifies defaults, custom templates and time zone handling.
+func TestNamerTemplates(t *testing.T) {
+	n, err := NewNamer("orders", map[string]string{
+		TypeTransactionSet: "{{.Partner}}/{{.Type}}_{{.PONumber}}_{{.Timestamp}}.{{.Ext}}",
+	}, newYork(t))
+	if err != nil {
+		t.Fatalf("NewNamer() returned %v", err)
+	}
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	if got, _ := n.Name(TypeOrder, NameData{PONumber: "PO1"}); got != "orders_PO1.json" {
+		t.Errorf("order name = %q; expected orders_PO1.json", got)
+	}
+	got, err := n.Name(TypeTransactionSet, NameData{Partner: "AMAZON", PONumber: "PO1", Time: at})
+	if err != nil {
+		t.Fatalf("Name() returned %v", err)
+	}
+	if want := filepath.Join("AMAZON", "edi_PO1_20240102T100405.edi"); got != want {
+		t.Errorf("edi name = %q; expected %q", got, want)
+	}
+}
+
+// TestNamerRejectsInvalid verifies bad templates and escaping paths are refused.
+func TestNamerRejectsInvalid(t *testing.T) {
+	if _, err := NewNamer("b", map[string]string{TypeOrder: "{{.Nope}}"}, nil); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+	if _, err := NewNamer("b", map[string]string{"costinv": "x"}, nil); err == nil {
+		t.Error("expected an error for an unknown document type")
+	}
+	n, _ := NewNamer("b", map[string]string{TypeOrder: "../{{.PONumber}}.json"}, nil)
+	if n != nil {
+		t.Error("expected a template escaping the storage directory to be refused")
+	}
+}
+
+// newYork loads America/New_York or skips when no zone database is available.
+func newYork(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	return loc
+}
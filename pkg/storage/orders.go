@@ -0,0 +1,152 @@
+// pkg/storage/orders.go
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+SaveOrder writes a purchase order as indented JSON, stamped with
+SchemaVersion, into dir under the name rendered by namer for the "order"
+type, compressed if the namer says so.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - order: The order to store.
+
+Returns:
+  - The path of the written file.
+  - An error if the name cannot be rendered or writing fails.
+*/
+func SaveOrder(dir string, namer *Namer, order spapi.PurchaseOrder) (string, error) {
+	data, err := encodeVersioned(order)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode order %s: %w", order.PurchaseOrderNumber, err)
+	}
+	name, err := namer.Name(TypeOrder, NameData{PONumber: order.PurchaseOrderNumber, Hash: ContentHash(data)})
+	if err != nil {
+		return "", err
+	}
+	if data, err = namer.encode(TypeOrder, data); err != nil {
+		return "", err
+	}
+	return save(dir, name, data, namer.perms)
+}
+
+/*
+StoredOrder reads the order previously written by SaveOrder for poNumber, so
+a re-fetched version can be compared with it before it is overwritten. With
+{{.Hash}} in the order template every version has its own file and none is
+found. A plain copy stored before storage.compression was set is found too.
+
+Returns:
+  - The stored order and true, or false if there is no stored copy under the current name.
+  - An error if the name cannot be rendered or the file cannot be read.
+*/
+func StoredOrder(dir string, namer *Namer, poNumber string) (spapi.PurchaseOrder, bool, error) {
+	name, err := namer.Name(TypeOrder, NameData{PONumber: poNumber})
+	if err != nil {
+		return spapi.PurchaseOrder{}, false, err
+	}
+	for _, path := range []string{filepath.Join(dir, name), filepath.Join(dir, codec.TrimExt(name))} {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		order, err := ReadOrder(path)
+		return order, err == nil, err
+	}
+	return spapi.PurchaseOrder{}, false, nil
+}
+
+/*
+LoadOrders reads every stored purchase order below dir, sorted by PO date
+(newest first). JSON files that are not orders are skipped, so orders are
+found whatever file name template and compression stored them. Encrypted
+files fail the listing when no key is configured, and so do orders written by
+a newer build (ErrNewerSchema).
+*/
+func LoadOrders(dir string) ([]spapi.PurchaseOrder, error) {
+	var orders []spapi.PurchaseOrder
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(codec.TrimExt(path)), ".json") {
+			return nil
+		}
+		order, err := ReadOrder(path)
+		if errors.Is(err, codec.ErrNoKey) || errors.Is(err, ErrNewerSchema) {
+			return err
+		}
+		if err != nil || order.PurchaseOrderNumber == "" {
+			return nil
+		}
+		orders = append(orders, order)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list orders in %s: %w", dir, err)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].OrderDetails.PurchaseOrderDate.After(orders[j].OrderDetails.PurchaseOrderDate)
+	})
+	return orders, nil
+}
+
+/*
+LoadOrder reads the stored purchase order with the given PO number.
+*/
+func LoadOrder(dir, poNumber string) (spapi.PurchaseOrder, error) {
+	poNumber = strings.TrimSpace(poNumber)
+	orders, err := LoadOrders(dir)
+	if err != nil {
+		return spapi.PurchaseOrder{}, err
+	}
+	for _, o := range orders {
+		if o.PurchaseOrderNumber == poNumber {
+			return o, nil
+		}
+	}
+	return spapi.PurchaseOrder{}, fmt.Errorf("order %s not found in %s", poNumber, dir)
+}
+
+// save writes data to name below dir with perms, creating sub-directories as
+// needed.
+func save(dir, name string, data interface{}, perms *utils.Permissions) (string, error) {
+	path := filepath.Join(dir, name)
+	if err := perms.SaveToFile(filepath.Dir(path), filepath.Base(path), data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+/*
+ReadOrder decodes one stored order file, decrypting and decompressing it if
+needed and upgrading it from an older schema version in memory (see Migrate).
+*/
+func ReadOrder(path string) (spapi.PurchaseOrder, error) {
+	var order spapi.PurchaseOrder
+	data, err := codec.ReadFile(path)
+	if err != nil {
+		return order, err
+	}
+	if data, _, err = upgrade(TypeOrder, data); err != nil {
+		return order, fmt.Errorf("invalid order file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &order); err != nil {
+		return order, fmt.Errorf("invalid order file %s: %w", path, err)
+	}
+	return order, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// TestCompressedOrders verifies orders are stored compressed under the
+// codec's extension and read back by LoadOrder and StoredOrder, next to a
+// plain order stored before compression was enabled.
+func TestCompressedOrders(t *testing.T) {
+	dir := t.TempDir()
+	n, err := NewNamer("orders", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveOrder(dir, n, spapi.PurchaseOrder{PurchaseOrderNumber: "OLD"}); err != nil {
+		t.Fatal(err)
+	}
+	zstd, _ := codec.Lookup("zstd")
+	n.SetCompression(zstd)
+	path, err := SaveOrder(dir, n, spapi.PurchaseOrder{PurchaseOrderNumber: "NEW"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "orders_NEW.json.zst" {
+		t.Errorf("SaveOrder() wrote %s; expected orders_NEW.json.zst", path)
+	}
+	if raw, _ := os.ReadFile(path); codec.Detect(raw) != zstd {
+		t.Errorf("%s is not zstd-compressed", path)
+	}
+
+	for _, po := range []string{"OLD", "NEW"} {
+		if o, err := LoadOrder(dir, po); err != nil || o.PurchaseOrderNumber != po {
+			t.Errorf("LoadOrder(%s) = %+v, %v", po, o, err)
+		}
+		if o, ok, err := StoredOrder(dir, n, po); !ok || err != nil || o.PurchaseOrderNumber != po {
+			t.Errorf("StoredOrder(%s) = %+v, %v, %v", po, o, ok, err)
+		}
+	}
+}
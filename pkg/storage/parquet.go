@@ -0,0 +1,205 @@
+// pkg/storage/parquet.go
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/money"
+	"github.com/heinrichb/avcimporter/pkg/parquet"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+OrderColumns is the schema of the normalized orders dataset: one row per
+purchase order version. Columns are only ever appended, so queries written
+against an older schema keep working.
+*/
+var OrderColumns = []parquet.Column{
+	{Name: "po_number", Type: parquet.String},
+	{Name: "purchase_order_date", Type: parquet.Timestamp},
+	{Name: "purchase_order_state", Type: parquet.String},
+	{Name: "purchase_order_type", Type: parquet.String, Optional: true},
+	{Name: "deal_code", Type: parquet.String, Optional: true},
+	{Name: "payment_method", Type: parquet.String, Optional: true},
+	{Name: "buying_party_id", Type: parquet.String, Optional: true},
+	{Name: "selling_party_id", Type: parquet.String, Optional: true},
+	{Name: "ship_to_party_id", Type: parquet.String, Optional: true},
+	{Name: "bill_to_party_id", Type: parquet.String, Optional: true},
+	{Name: "ship_window_start", Type: parquet.Timestamp, Optional: true},
+	{Name: "ship_window_end", Type: parquet.Timestamp, Optional: true},
+	{Name: "delivery_window_start", Type: parquet.Timestamp, Optional: true},
+	{Name: "delivery_window_end", Type: parquet.Timestamp, Optional: true},
+	{Name: "changed_at", Type: parquet.Timestamp, Optional: true},
+	{Name: "state_changed_at", Type: parquet.Timestamp, Optional: true},
+	{Name: "item_count", Type: parquet.Int32},
+	{Name: "imported_at", Type: parquet.Timestamp},
+}
+
+/*
+LineItemColumns is the schema of the normalized line items dataset: one row
+per line of a purchase order version, joined to the orders on po_number and
+imported_at.
+*/
+var LineItemColumns = []parquet.Column{
+	{Name: "po_number", Type: parquet.String},
+	{Name: "purchase_order_date", Type: parquet.Timestamp},
+	{Name: "item_sequence_number", Type: parquet.String},
+	{Name: "asin", Type: parquet.String, Optional: true},
+	{Name: "vendor_sku", Type: parquet.String, Optional: true},
+	{Name: "ordered_quantity", Type: parquet.Int32},
+	{Name: "unit_of_measure", Type: parquet.String},
+	{Name: "unit_size", Type: parquet.Int32, Optional: true},
+	{Name: "back_order_allowed", Type: parquet.Boolean},
+	{Name: "net_cost", Type: parquet.Double, Optional: true},
+	{Name: "net_cost_currency", Type: parquet.String, Optional: true},
+	{Name: "list_price", Type: parquet.Double, Optional: true},
+	{Name: "list_price_currency", Type: parquet.String, Optional: true},
+	{Name: "imported_at", Type: parquet.Timestamp},
+}
+
+/*
+SaveOrdersParquet writes orders as normalized Parquet datasets below
+dir/parquet: orders/ and line_items/, each partitioned Hive-style by the UTC
+purchase order date (order_date=YYYY-MM-DD). Every call adds a new part file
+to each partition it touches, named after at and the content, so a re-fetched
+order appears once per import; take the row with the latest imported_at.
+
+Parameters:
+  - dir:    Storage directory (storage.savePath).
+  - orders: The orders to write.
+  - at:     Import time, stored as imported_at.
+  - perms:  Mode and ownership of the files; nil uses utils.DefaultPermissions.
+
+Returns:
+  - The paths of the written files.
+  - An error if an order cannot be encoded or a file cannot be stored.
+*/
+func SaveOrdersParquet(dir string, orders []spapi.PurchaseOrder, at time.Time, perms *utils.Permissions) ([]string, error) {
+	type partition struct {
+		orders, items *parquet.Writer
+	}
+	partitions := map[string]*partition{}
+	for _, o := range orders {
+		d := o.OrderDetails
+		day := d.PurchaseOrderDate.UTC().Format("2006-01-02")
+		p := partitions[day]
+		if p == nil {
+			p = &partition{orders: parquet.NewWriter(OrderColumns), items: parquet.NewWriter(LineItemColumns)}
+			partitions[day] = p
+		}
+
+		windows := map[string]interface{}{}
+		for _, w := range []struct{ name, value string }{{"ship", d.ShipWindow}, {"delivery", d.DeliveryWindow}} {
+			start, end, _ := strings.Cut(w.value, "--")
+			windows[w.name+"Start"] = parquetTime(start)
+			windows[w.name+"End"] = parquetTime(end)
+		}
+		err := p.orders.Add(o.PurchaseOrderNumber, d.PurchaseOrderDate, o.PurchaseOrderState,
+			optional(d.PurchaseOrderType), optional(d.DealCode), optional(d.PaymentMethod),
+			partyValue(d.BuyingParty), partyValue(d.SellingParty), partyValue(d.ShipToParty), partyValue(d.BillToParty),
+			windows["shipStart"], windows["shipEnd"], windows["deliveryStart"], windows["deliveryEnd"],
+			timeValue(d.PurchaseOrderChangedDate), timeValue(d.PurchaseOrderStateChangedDate), len(d.Items), at)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode order %s: %w", o.PurchaseOrderNumber, err)
+		}
+
+		for _, it := range d.Items {
+			var unitSize interface{}
+			if it.OrderedQuantity.UnitSize > 0 {
+				unitSize = it.OrderedQuantity.UnitSize
+			}
+			netCost, netCurrency := moneyValues(it.NetCost)
+			listPrice, listCurrency := moneyValues(it.ListPrice)
+			err := p.items.Add(o.PurchaseOrderNumber, d.PurchaseOrderDate, it.ItemSequenceNumber,
+				optional(it.AmazonProductIdentifier), optional(it.VendorProductIdentifier),
+				it.OrderedQuantity.Amount, it.OrderedQuantity.UnitOfMeasure, unitSize, it.IsBackOrderAllowed,
+				netCost, netCurrency, listPrice, listCurrency, at)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode order %s item %s: %w", o.PurchaseOrderNumber, it.ItemSequenceNumber, err)
+			}
+		}
+	}
+
+	days := make([]string, 0, len(partitions))
+	for day := range partitions {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	var paths []string
+	for _, day := range days {
+		p := partitions[day]
+		for _, set := range []struct {
+			name string
+			w    *parquet.Writer
+		}{{"orders", p.orders}, {"line_items", p.items}} {
+			if set.w.Rows() == 0 {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := set.w.Write(&buf); err != nil {
+				return paths, err
+			}
+			name := fmt.Sprintf("part-%s-%s.parquet", at.UTC().Format("20060102T150405"), ContentHash(buf.Bytes()))
+			path := filepath.Join(dir, "parquet", set.name, "order_date="+day, name)
+			if err := perms.MkdirAll(filepath.Dir(path)); err != nil {
+				return paths, fmt.Errorf("failed to create directory for %s: %w", path, err)
+			}
+			if err := writeAtomic(path, &buf, perms); err != nil {
+				return paths, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// optional returns s, or nil for a null if s is empty.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// partyValue returns the ID of p, or nil if there is none.
+func partyValue(p *spapi.Party) interface{} {
+	if p == nil || p.PartyID == "" {
+		return nil
+	}
+	return p.PartyID
+}
+
+// timeValue returns *t, or nil if t is nil.
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// parquetTime parses an RFC 3339 time, or returns nil if s is not one.
+func parquetTime(s string) interface{} {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// moneyValues returns the amount and currency of m, or nils if m is unset or not a number.
+func moneyValues(m *spapi.Money) (interface{}, interface{}) {
+	if m == nil {
+		return nil, nil
+	}
+	amount, err := money.Parse(m.Amount, 0)
+	if err != nil {
+		return nil, nil
+	}
+	return amount, optional(m.CurrencyCode)
+}
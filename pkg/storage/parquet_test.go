@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// TestSaveOrdersParquet verifies orders and their lines land in the partition
+// of their UTC order date, and that unparsable values do not fail the write.
+func TestSaveOrdersParquet(t *testing.T) {
+	dir := t.TempDir()
+	order := func(po string, date time.Time) spapi.PurchaseOrder {
+		return spapi.PurchaseOrder{PurchaseOrderNumber: po, PurchaseOrderState: "New", OrderDetails: spapi.OrderDetails{
+			PurchaseOrderDate: date,
+			ShipWindow:        "2024-05-03T07:00:00Z--not a time",
+			Items: []spapi.OrderItem{
+				{ItemSequenceNumber: "1", OrderedQuantity: spapi.ItemQuantity{Amount: 2, UnitOfMeasure: "Cases", UnitSize: 6}, NetCost: &spapi.Money{CurrencyCode: "USD", Amount: "10.50"}},
+				{ItemSequenceNumber: "2", OrderedQuantity: spapi.ItemQuantity{Amount: 1, UnitOfMeasure: "Eaches"}, ListPrice: &spapi.Money{Amount: "n/a"}},
+			},
+		}}
+	}
+	orders := []spapi.PurchaseOrder{
+		order("PO1", time.Date(2024, 5, 1, 23, 0, 0, 0, time.FixedZone("PDT", -7*3600))),
+		order("PO2", time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)),
+		{PurchaseOrderNumber: "PO3", OrderDetails: spapi.OrderDetails{PurchaseOrderDate: time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC)}},
+	}
+	at := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+
+	paths, err := SaveOrdersParquet(dir, orders, at, nil)
+	if err != nil {
+		t.Fatalf("SaveOrdersParquet() returned %v", err)
+	}
+	var rel []string
+	for _, p := range paths {
+		r, _ := filepath.Rel(dir, filepath.Dir(p))
+		rel = append(rel, r)
+		data, err := os.ReadFile(p)
+		if err != nil || !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+			t.Errorf("%s is not a parquet file (%v)", p, err)
+		}
+	}
+	// PO1 was ordered on 2024-05-02 in UTC, like PO3.
+	expected := []string{
+		"parquet/orders/order_date=2024-05-01",
+		"parquet/line_items/order_date=2024-05-01",
+		"parquet/orders/order_date=2024-05-02",
+		"parquet/line_items/order_date=2024-05-02",
+	}
+	if len(rel) != len(expected) {
+		t.Fatalf("written partitions = %v; expected %v", rel, expected)
+	}
+	for i := range expected {
+		if rel[i] != filepath.FromSlash(expected[i]) {
+			t.Errorf("file %d written to %s; expected %s", i, rel[i], expected[i])
+		}
+	}
+	if base := filepath.Base(paths[0]); filepath.Ext(base) != ".parquet" || base[:21] != "part-20240502T090000-" {
+		t.Errorf("file name = %s; expected part-20240502T090000-<hash>.parquet", base)
+	}
+}
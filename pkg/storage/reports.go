@@ -0,0 +1,115 @@
+// pkg/storage/reports.go
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+SaveReport streams a report into dir under the name rendered by namer for the
+"report" type. The content is written to a temporary file first and renamed
+into place, so a failed download never leaves a partial report behind.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - data:  Name data; ReportType and Time should be set.
+  - write: Writes the report content.
+
+Returns:
+  - The path of the written file.
+  - An error if the name cannot be rendered, write fails or the file cannot be stored.
+*/
+func SaveReport(dir string, namer *Namer, data NameData, write func(io.Writer) error) (string, error) {
+	return saveStream(dir, namer, TypeReport, data, write)
+}
+
+/*
+SaveQueryResult streams a Data Kiosk result document (JSONL) into dir under
+the name rendered by namer for the "datakiosk" type, replacing the file only
+once the download completed.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - data:  Name data; Query and Time should be set.
+  - write: Writes the document content.
+
+Returns:
+  - The path of the written file.
+  - An error if the name cannot be rendered, write fails or the file cannot be stored.
+*/
+func SaveQueryResult(dir string, namer *Namer, data NameData, write func(io.Writer) error) (string, error) {
+	return saveStream(dir, namer, TypeDataKiosk, data, write)
+}
+
+/*
+SaveSummary writes a daily order summary workbook into dir under the name
+rendered by namer for the "summary" type, replacing an earlier workbook of the
+same day only once the new one is complete.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - data:  Name data; Time should be the start of the summarized day.
+  - write: Writes the workbook.
+
+Returns:
+  - The path of the written file.
+  - An error if the name cannot be rendered, write fails or the file cannot be stored.
+*/
+func SaveSummary(dir string, namer *Namer, data NameData, write func(io.Writer) error) (string, error) {
+	return saveStream(dir, namer, TypeSummary, data, write)
+}
+
+/*
+SaveDocument writes a rendered order printout (PDF) into dir under the name
+rendered by namer for the "document" type.
+
+Parameters:
+  - dir:   Storage directory (storage.savePath).
+  - namer: File name templates (storage.fileNames).
+  - data:  Name data; PONumber and Document should be set.
+  - write: Writes the document.
+
+Returns:
+  - The path of the written file.
+  - An error if the name cannot be rendered, write fails or the file cannot be stored.
+*/
+func SaveDocument(dir string, namer *Namer, data NameData, write func(io.Writer) error) (string, error) {
+	return saveStream(dir, namer, TypeDocument, data, write)
+}
+
+// saveStream writes a downloaded document of type typ through a temporary file.
+func saveStream(dir string, namer *Namer, typ string, data NameData, write func(io.Writer) error) (string, error) {
+	name, err := namer.Name(typ, data)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := namer.perms.MkdirAll(filepath.Dir(path)); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+typ+"-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := namer.perms.Apply(tmp.Name()); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,229 @@
+// pkg/storage/schema.go
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+/*
+SchemaVersion is the version of the stored order and manifest format written
+by this build, kept in each file as "schemaVersion". Files without it are
+version 0. Raise it together with a new entry in migrations whenever the
+stored format changes.
+*/
+const SchemaVersion = 1
+
+// ErrNewerSchema marks files written by a newer build than this one.
+var ErrNewerSchema = errors.New("written by a newer avcimporter")
+
+/*
+migrations upgrade a stored artifact one version at a time: migrations[v]
+turns the top-level fields of a version v document of the given type (TypeOrder
+or TypeManifest) into version v+1.
+*/
+var migrations = []func(typ string, doc map[string]json.RawMessage) error{
+	// 0 -> 1: the version is added; the fields are unchanged.
+	func(string, map[string]json.RawMessage) error { return nil },
+}
+
+// encodeVersioned encodes the object v as indented JSON whose first field is
+// "schemaVersion": SchemaVersion.
+func encodeVersioned(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return nil, fmt.Errorf("cannot version a %T", v)
+	}
+	head := fmt.Sprintf(`{"schemaVersion":%d`, SchemaVersion)
+	if data = data[1:]; len(data) > 1 {
+		head += ","
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, append([]byte(head), data...), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// schemaVersion returns the version of a decoded artifact.
+func schemaVersion(doc map[string]json.RawMessage) (int, error) {
+	raw, ok := doc["schemaVersion"]
+	if !ok {
+		return 0, nil
+	}
+	var v int
+	if err := json.Unmarshal(raw, &v); err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid schemaVersion %s", raw)
+	}
+	return v, nil
+}
+
+// artifactType recognizes the stored artifacts that carry a schema version:
+// orders and manifests. Other JSON files yield "".
+func artifactType(doc map[string]json.RawMessage) string {
+	if _, ok := doc["purchaseOrderNumber"]; ok {
+		return TypeOrder
+	}
+	_, flow := doc["flow"]
+	_, files := doc["files"]
+	if flow && files {
+		return TypeManifest
+	}
+	return ""
+}
+
+/*
+upgrade brings a stored artifact of type typ to SchemaVersion.
+
+Returns:
+  - The upgraded document (data itself when it is current).
+  - The version it had.
+  - An error if data is not a JSON object, was written by a newer build or a migration fails.
+*/
+func upgrade(typ string, data []byte) ([]byte, int, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+	from, err := schemaVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from > SchemaVersion {
+		return nil, from, fmt.Errorf("%w (schema version %d, this build reads up to %d)", ErrNewerSchema, from, SchemaVersion)
+	}
+	if from == SchemaVersion {
+		return data, from, nil
+	}
+	for v := from; v < SchemaVersion; v++ {
+		if err := migrations[v](typ, doc); err != nil {
+			return nil, from, fmt.Errorf("migration to schema version %d failed: %w", v+1, err)
+		}
+	}
+	delete(doc, "schemaVersion")
+	out, err := encodeVersioned(doc)
+	return out, from, err
+}
+
+/*
+MigratedFile describes a stored artifact found by Migrate below
+SchemaVersion.
+
+Fields:
+  - Path: The file.
+  - Type: TypeOrder or TypeManifest.
+  - From: The version it had.
+*/
+type MigratedFile struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	From int    `json:"from"`
+}
+
+/*
+Migrate upgrades every stored order and manifest below dir to SchemaVersion
+in place, keeping its compression and encryption. Other files are left
+alone. Each file is replaced atomically, so an interrupted migration can
+simply be run again.
+
+Parameters:
+  - dir:    Storage directory (storage.savePath).
+  - dryRun: Only report the files that would be upgraded.
+  - perms:  Mode and ownership of the rewritten files; nil uses utils.DefaultPermissions.
+
+Returns:
+  - The files upgraded (or due for upgrade with dryRun).
+  - An error joining the files that could not be read or upgraded; the others are still migrated.
+*/
+func Migrate(dir string, dryRun bool, perms *utils.Permissions) ([]MigratedFile, error) {
+	var migrated []MigratedFile
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(codec.TrimExt(path)), ".json") {
+			return nil
+		}
+		f, err := migrateFile(path, dryRun, perms)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		} else if f != nil {
+			migrated = append(migrated, *f)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("failed to list %s: %w", dir, err))
+	}
+	return migrated, errors.Join(errs...)
+}
+
+// migrateFile upgrades one file, returning nil if it is current or not a
+// versioned artifact.
+func migrateFile(path string, dryRun bool, perms *utils.Permissions) (*MigratedFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := codec.Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(data, &doc) != nil {
+		return nil, nil
+	}
+	typ := artifactType(doc)
+	if typ == "" {
+		return nil, nil
+	}
+	out, from, err := upgrade(typ, data)
+	if err != nil || from == SchemaVersion {
+		return nil, err
+	}
+	if !dryRun {
+		if err := rewrite(path, raw, out, perms); err != nil {
+			return nil, err
+		}
+	}
+	return &MigratedFile{Path: path, Type: typ, From: from}, nil
+}
+
+// rewrite replaces the file at path, whose current content is raw, with
+// data, compressed and encrypted like raw.
+func rewrite(path string, raw, data []byte, perms *utils.Permissions) error {
+	plain, err := codec.Open(raw)
+	if err != nil {
+		return err
+	}
+	if data, err = codec.Encode(codec.Detect(plain), data); err != nil {
+		return err
+	}
+	if codec.Encrypted(raw) {
+		if data, err = codec.Seal(data); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := perms.WriteFile(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
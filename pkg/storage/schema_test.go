@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/codec"
+	"github.com/heinrichb/avcimporter/pkg/spapi"
+)
+
+// TestMigrations verifies every schema version below SchemaVersion has a
+// migration.
+func TestMigrations(t *testing.T) {
+	if len(migrations) != SchemaVersion {
+		t.Fatalf("%d migrations for schema version %d", len(migrations), SchemaVersion)
+	}
+}
+
+// TestSaveOrderVersion verifies stored orders start with the schema version.
+func TestSaveOrderVersion(t *testing.T) {
+	dir := t.TempDir()
+	n, err := NewNamer("orders", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := SaveOrder(dir, n, spapi.PurchaseOrder{PurchaseOrderNumber: "PO1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(data, []byte("{\n  \"schemaVersion\": 1,\n  \"purchaseOrderNumber\": \"PO1\"")) {
+		t.Errorf("SaveOrder() wrote %s", data)
+	}
+}
+
+// TestMigrate verifies unversioned orders are readable before and after
+// Migrate, which upgrades them in place keeping their compression, leaves
+// other JSON alone and is a no-op the second time. Files of a newer build are
+// refused.
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	gzip, _ := codec.Lookup("gzip")
+	old := []byte(`{"purchaseOrderNumber": "OLD", "orderDetails": {"purchaseOrderDate": "2024-01-02T00:00:00Z"}}`)
+	zipped, err := codec.Encode(gzip, []byte(`{"purchaseOrderNumber": "ZIP"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"orders_OLD.json":        old,
+		"orders_ZIP.json.gz":     zipped,
+		"reports/report.json":    []byte(`{"reportId": "1"}`),
+		"manifests/run_api.json": []byte(`{"flow": "api", "files": []}`),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o, err := LoadOrder(dir, "OLD"); err != nil || o.PurchaseOrderNumber != "OLD" {
+		t.Fatalf("LoadOrder(OLD) before Migrate = %+v, %v", o, err)
+	}
+
+	due, err := Migrate(dir, true, nil)
+	if err != nil || len(due) != 3 {
+		t.Fatalf("Migrate(dry run) = %+v, %v; expected 3 files", due, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "orders_OLD.json")); !bytes.Equal(data, old) {
+		t.Errorf("dry run rewrote orders_OLD.json: %s", data)
+	}
+
+	migrated, err := Migrate(dir, false, nil)
+	if err != nil || len(migrated) != 3 {
+		t.Fatalf("Migrate() = %+v, %v; expected 3 files", migrated, err)
+	}
+	for _, name := range []string{"orders_OLD.json", "orders_ZIP.json.gz", "manifests/run_api.json"} {
+		raw, _ := os.ReadFile(filepath.Join(dir, name))
+		if strings.HasSuffix(name, ".gz") && codec.Detect(raw) != gzip {
+			t.Errorf("%s is no longer gzip-compressed", name)
+		}
+		data, _ := codec.Decode(raw)
+		if !bytes.Contains(data, []byte(`"schemaVersion": 1`)) {
+			t.Errorf("%s was not upgraded: %s", name, data)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "reports/report.json")); !bytes.Equal(data, files["reports/report.json"]) {
+		t.Errorf("Migrate() rewrote a report: %s", data)
+	}
+	for _, po := range []string{"OLD", "ZIP"} {
+		if o, err := LoadOrder(dir, po); err != nil || o.PurchaseOrderNumber != po {
+			t.Errorf("LoadOrder(%s) after Migrate = %+v, %v", po, o, err)
+		}
+	}
+	if again, err := Migrate(dir, false, nil); err != nil || len(again) != 0 {
+		t.Errorf("second Migrate() = %+v, %v; expected nothing to do", again, err)
+	}
+
+	newer := filepath.Join(dir, "orders_NEW.json")
+	os.WriteFile(newer, []byte(`{"schemaVersion": 99, "purchaseOrderNumber": "NEW"}`), 0o644)
+	if _, err := LoadOrders(dir); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("LoadOrders() with a newer file returned %v; expected ErrNewerSchema", err)
+	}
+}
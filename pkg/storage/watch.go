@@ -0,0 +1,135 @@
+// pkg/storage/watch.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/heinrichb/avcimporter/pkg/utils"
+)
+
+// minWatchTick bounds how often WatchStable re-checks pending files.
+const minWatchTick = 50 * time.Millisecond
+
+// watchedFile is the last seen state of a file in a watched directory.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+	handed  bool
+}
+
+/*
+WatchStable calls ready for every regular file in dir once its size and
+modification time have stayed the same for stableFor, so files still being
+written by a partner's tooling are not picked up half-way. Files already in
+dir when watching starts are handled the same way.
+
+Hidden files (starting with ".") and subdirectories are ignored, so writers
+that upload to a dotfile and rename it when done work as expected. Each
+version of a file is handed to ready once; ready is expected to move or remove
+it, and a file it leaves in place is only offered again after it changes.
+
+fsnotify events make new files show up quickly; the directory is also listed
+on every check because network filesystems such as NFS do not report changes
+made by other machines.
+
+Parameters:
+  - ctx:       Watching stops when ctx is cancelled.
+  - dir:       The directory to watch.
+  - stableFor: How long a file must stay unchanged.
+  - ready:     Called, one file at a time, with the path of each stable file.
+  - logger:    Receives watcher errors; nil discards them.
+
+Returns:
+  - An error if the watcher cannot be started, nil once ctx is cancelled.
+*/
+func WatchStable(ctx context.Context, dir string, stableFor time.Duration, ready func(path string), logger *utils.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create inbound watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	files := map[string]*watchedFile{}
+	observe := func(path string, info os.FileInfo, now time.Time) {
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") || IsTemp(info.Name()) {
+			delete(files, path)
+			return
+		}
+		f, ok := files[path]
+		if ok && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+			return
+		}
+		files[path] = &watchedFile{size: info.Size(), modTime: info.ModTime(), since: now}
+	}
+	sweep := func() {
+		now := time.Now()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		present := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			present[path] = true
+			observe(path, info, now)
+		}
+		for path := range files {
+			if !present[path] {
+				delete(files, path)
+			}
+		}
+	}
+
+	tick := max(stableFor/4, minWatchTick)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	sweep()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if info, err := os.Stat(ev.Name); err == nil {
+				observe(filepath.Clean(ev.Name), info, time.Now())
+			} else {
+				delete(files, filepath.Clean(ev.Name))
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			// The next sweep catches whatever the event queue lost.
+			logger.Log(utils.LevelError, "Inbound watcher error: ", err.Error())
+		case <-ticker.C:
+			sweep()
+			now := time.Now()
+			for path, f := range files {
+				if f.handed || now.Sub(f.since) < stableFor {
+					continue
+				}
+				f.handed = true
+				ready(path)
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
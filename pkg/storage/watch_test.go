@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatchStable verifies existing and new files are handed over once each,
+// only after they stop changing, and that hidden files are ignored.
+func TestWatchStable(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.edi")
+	if err := os.WriteFile(existing, []byte("ISA"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchStable(ctx, dir, 300*time.Millisecond, func(path string) { got <- path }, nil)
+	}()
+
+	if p := <-got; p != existing {
+		t.Fatalf("first ready file = %s; expected %s", p, existing)
+	}
+
+	growing := filepath.Join(dir, "growing.edi")
+	if err := os.WriteFile(filepath.Join(dir, ".partial"), []byte("ISA"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		f, err := os.OpenFile(growing, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("ST*850~")
+		f.Close()
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case p := <-got:
+		if p != growing {
+			t.Fatalf("second ready file = %s; expected %s", p, growing)
+		}
+		if time.Since(start) < 600*time.Millisecond {
+			t.Errorf("%s was handed over after %s; expected it to wait until writes stopped", p, time.Since(start))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the new file was never handed over")
+	}
+
+	select {
+	case p := <-got:
+		t.Errorf("unexpected ready file %s", p)
+	case <-time.After(700 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchStable() returned %v", err)
+	}
+}
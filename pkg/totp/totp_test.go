@@ -0,0 +1,30 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCode verifies the SHA-1 test vectors of RFC 6238, truncated to six digits.
+func TestCode(t *testing.T) {
+	key, err := DecodeSecret("GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ") // "12345678901234567890"
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		if got := Code(key, time.Unix(tc.unix, 0)); got != tc.want {
+			t.Errorf("Code(%d) = %s; expected %s", tc.unix, got, tc.want)
+		}
+	}
+	if _, err := DecodeSecret("not base32!"); err == nil {
+		t.Error("DecodeSecret() accepted an invalid secret")
+	}
+}
@@ -0,0 +1,233 @@
+// pkg/tracing/otlp.go
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batching limits for the exporter.
+const (
+	maxBatchSize  = 512
+	flushInterval = 5 * time.Second
+)
+
+/*
+Exporter batches finished spans and sends them to an OpenTelemetry collector
+using OTLP over HTTP with JSON encoding (POST {endpoint}/v1/traces).
+*/
+type Exporter struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	flushCh chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	onError func(error)
+}
+
+/*
+Setup installs a global OTLP exporter and starts its background flusher.
+
+Parameters:
+  - endpoint:    Collector base URL (e.g. "http://localhost:4318").
+  - serviceName: Reported as the service.name resource attribute.
+  - headers:     Extra HTTP headers (e.g. authentication for a SaaS backend).
+  - onError:     Called when an export fails; may be nil.
+
+Returns:
+  - A shutdown function that flushes remaining spans and uninstalls the exporter.
+*/
+func Setup(endpoint, serviceName string, headers map[string]string, onError func(error)) func(ctx context.Context) error {
+	e := &Exporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		headers:     headers,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		flushCh:     make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		onError:     onError,
+	}
+	exporterMu.Lock()
+	exporter = e
+	exporterMu.Unlock()
+
+	go e.loop()
+
+	return func(ctx context.Context) error {
+		exporterMu.Lock()
+		if exporter == e {
+			exporter = nil
+		}
+		exporterMu.Unlock()
+		close(e.stop)
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return e.flush(ctx)
+	}
+}
+
+// enqueue buffers a finished span, triggering a flush when the batch is full.
+func (e *Exporter) enqueue(s *Span) {
+	e.mu.Lock()
+	e.pending = append(e.pending, s)
+	full := len(e.pending) >= maxBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// loop flushes pending spans periodically until stopped.
+func (e *Exporter) loop() {
+	defer close(e.done)
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-t.C:
+		case <-e.flushCh:
+		}
+		if err := e.flush(context.Background()); err != nil && e.onError != nil {
+			e.onError(err)
+		}
+	}
+}
+
+// flush sends every pending span in a single export request.
+func (e *Exporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("span export rejected (%d): %s", resp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+// OTLP JSON payload types (opentelemetry-proto, JSON mapping).
+type (
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+)
+
+// encode converts spans to an OTLP ExportTraceServiceRequest.
+func (e *Exporter) encode(batch []*Span) map[string]interface{} {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		s.mu.Lock()
+		out := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: 1}, // STATUS_CODE_OK
+		}
+		for k, v := range s.attrs {
+			out.Attributes = append(out.Attributes, otlpKeyValue{Key: k, Value: otlpValue(v)})
+		}
+		if s.err != nil {
+			out.Status = otlpStatus{Code: 2, Message: s.err.Error()} // STATUS_CODE_ERROR
+		}
+		s.mu.Unlock()
+		spans = append(spans, out)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpKeyValue{{Key: "service.name", Value: otlpValue(e.serviceName)}},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/heinrichb/avcimporter"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpValue maps a Go value to an OTLP AnyValue.
+func otlpValue(v interface{}) map[string]interface{} {
+	switch x := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": x}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(x)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": x}
+	case string:
+		return map[string]interface{}{"stringValue": x}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(x)}
+	}
+}
@@ -0,0 +1,114 @@
+// pkg/tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Span is a timed operation within a run. Spans nest through the context passed
+to Start. A nil *Span is valid and ignores every call, so instrumented code
+does not need to check whether tracing is enabled.
+*/
+type Span struct {
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	start    time.Time
+	end      time.Time
+	mu       sync.Mutex
+	attrs    map[string]interface{}
+	err      error
+	ended    bool
+}
+
+// spanKey is the context key under which the active span is stored.
+type spanKey struct{}
+
+// exporterMu guards the globally installed exporter.
+var (
+	exporterMu sync.RWMutex
+	exporter   *Exporter
+)
+
+/*
+Start begins a span named name as a child of the span in ctx (or as a new
+trace root) and returns a context carrying it. When no exporter is installed
+it returns ctx unchanged and a nil span.
+
+Parameters:
+  - ctx:   Parent context.
+  - name:  Span name (e.g. "sftp.download").
+  - attrs: Optional key/value pairs, e.g. Start(ctx, "x", "file", name).
+*/
+func Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	exporterMu.RLock()
+	enabled := exporter != nil
+	exporterMu.RUnlock()
+	if !enabled {
+		return ctx, nil
+	}
+
+	s := &Span{name: name, spanID: randomHex(8), start: time.Now(), attrs: map[string]interface{}{}}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs[fmt.Sprint(attrs[i])] = attrs[i+1]
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+/*
+SetAttr records an attribute on the span.
+*/
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+/*
+End finishes the span, marking it failed when err is non-nil, and hands it to
+the exporter. Calling End more than once has no effect.
+*/
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+
+	exporterMu.RLock()
+	e := exporter
+	exporterMu.RUnlock()
+	if e != nil {
+		e.enqueue(s)
+	}
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,11 @@
+// pkg/utils/console_other.go
+//go:build !windows
+
+package utils
+
+import "os"
+
+// enableVirtualTerminal is a no-op outside Windows, where terminals interpret ANSI escapes natively.
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
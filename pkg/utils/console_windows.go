@@ -0,0 +1,27 @@
+// pkg/utils/console_windows.go
+//go:build windows
+
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+enableVirtualTerminal turns on ANSI escape processing for the console attached
+to f. Windows 10+ consoles support it but leave it off by default; older
+consoles reject the mode, in which case colors must stay disabled.
+*/
+func enableVirtualTerminal(f *os.File) bool {
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
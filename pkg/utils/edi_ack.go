@@ -3,38 +3,18 @@ package utils
 
 import (
 	"fmt"
-	"regexp"
 	"time"
-)
-
-var (
-	// Capture:
-	// 1: ISA sender ID
-	// 2: ISA receiver ID
-	// 3: Date (YYMMDD)
-	// 4: Time (HHMM)
-	// 5: Interchange control number (e.g. 900000009)
-	isaRe = regexp.MustCompile(
-		`ISA\*00\*.+\*00\*.+\*ZZ\*([^\*]+)\*ZZ\*([^\*]+)\*` +
-			`([0-9]{6})\*([0-9]{4})\*U\*00400\*([0-9]+)\*`)
 
-	// Capture:
-	// 1: GS sender ID
-	// 2: GS receiver ID
-	// 3: GS date (YYYYMMDD)
-	// 4: GS time (HHMM)
-	// 5: GS control number
-	gsRe = regexp.MustCompile(
-		`GS\*PO\*([^\*]+)\*([^\*]+)\*([0-9]{8})\*([0-9]{4})\*([0-9]+)\*X`)
-
-	// Capture the ST control number from ST*850*0001
-	stRe = regexp.MustCompile(`ST\*850\*([0-9]+)`)
+	"github.com/heinrichb/avcimporter/pkg/x12"
 )
 
 /*
-Generate997 builds a minimal 997 Functional Acknowledgment.
+Generate997 builds a 997 Functional Acknowledgment.
 It echoes back the ISA/GS/ST control numbers from the inbound X12 and
-uses senderID as your GS sender ID.
+uses senderID as your GS sender ID. ISA and GS dates are the current time in
+the time zone of the options (UTC for Generate997). The 997 is written with Amazon's default
+delimiters and version 00400 and marked as test data (ISA15 "T"); use
+Generate997With to change them.
 
 Parameters:
   - in:       raw contents of the 850 file
@@ -45,51 +25,124 @@ Returns:
   - an error if any required segment cannot be parsed
 */
 func Generate997(in, senderID string) (string, error) {
-	// parse ISA
-	m := isaRe.FindStringSubmatch(in)
-	if len(m) < 6 {
-		return "", fmt.Errorf("invalid ISA segment: expected 5 captures, got %d", len(m)-1)
-	}
-	origInterchangeCtrl := m[5]
+	return Generate997With(in, senderID, x12.DefaultOptions, nil)
+}
 
-	// parse GS
-	m2 := gsRe.FindStringSubmatch(in)
-	if len(m2) < 6 {
-		return "", fmt.Errorf("invalid GS segment: expected 5 captures, got %d", len(m2)-1)
-	}
-	origDate := m2[3]
-	origTime := m2[4]
-	origGroupCtrl := m2[5]
+/*
+SetValidator checks one inbound transaction set beyond its envelope and
+returns AK5 error codes (X12 element 718, e.g. x12.SetSegmentsInError) for
+problems it finds. Returning any code rejects the set.
+*/
+type SetValidator func(set x12.TransactionSet) []string
 
-	// parse ST
-	m3 := stRe.FindStringSubmatch(in)
-	if len(m3) < 2 {
-		return "", fmt.Errorf("invalid ST segment: could not find control number")
-	}
-	origSetCtrl := m3[1]
+/*
+Generate997With builds a 997 Functional Acknowledgment like Generate997,
+writing it with the given delimiters, version and usage indicator. The
+inbound document may use any delimiters and version; they are read from its
+ISA segment. Its usage indicator is not copied: ISA15 is opts.Usage ("T" if
+empty), so a production inbound cannot make a test setup answer live.
 
-	// build timestamps for new ISA
-	now := time.Now()
-	isaDate := now.Format("060102") // YYMMDD
-	isaTime := now.Format("1504")   // HHMM
+Each functional group in the interchange is acknowledged by its own 997
+transaction set with an AK2/AK5 loop per transaction set. A set is rejected
+(AK5*R) when its ST/SE envelope is broken or validate returns error codes; a
+group is rejected (AK9*R) when its GS/GE envelope is broken or none of its
+sets are accepted, and partially accepted (AK9*P) when only some are.
 
-	// ISA header: swap roles if needed; use senderID
-	isa := fmt.Sprintf(
-		"ISA*00*          *00*          *ZZ*AMAZON         *ZZ*%s*%s*%s*U*00400*%s*0*T*>~\n",
-		senderID, isaDate, isaTime, origInterchangeCtrl,
-	)
-	// GS header
-	gs := fmt.Sprintf(
-		"GS*FA*AMAZON*%s*%s*%s*%s*X*004010~\n",
-		senderID, origDate, origTime, origGroupCtrl,
+Parameters:
+  - in:       raw contents of the inbound file
+  - senderID: your Amazon‑assigned GS ID; empty uses the inbound GS receiver code
+  - opts:     outbound delimiters, interchange version and usage
+  - validate: optional content checks per transaction set; may be nil
+
+Returns:
+  - a string containing the 997 EDI document
+  - an error if any required segment cannot be parsed or opts are invalid
+*/
+func Generate997With(in, senderID string, opts x12.Options, validate SetValidator) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	doc, err := x12.Parse([]byte(in))
+	if err != nil {
+		return "", fmt.Errorf("invalid ISA segment: %w", err)
+	}
+	isa, _ := doc.Find("ISA")
+	groups := doc.Groups()
+	if len(groups) == 0 || groups[0].ControlNumber() == "" {
+		return "", fmt.Errorf("invalid GS segment: could not find control number")
+	}
+	lead := groups[0]
+	if senderID == "" {
+		senderID = lead.Header.Element(3)
+	}
+
+	// The acknowledgment travels the other way, so sender and receiver swap.
+	now := opts.In(time.Now())
+	segments := []x12.Segment{
+		opts.ISA(x12.ISAHeader{
+			SenderQualifier:   isa.Element(7),
+			SenderID:          isa.Element(8),
+			ReceiverQualifier: isa.Element(5),
+			ReceiverID:        isa.Element(6),
+			Time:              now,
+			ControlNumber:     isa.Element(13),
+		}),
+		{ID: "GS", Elements: []string{"FA", senderID, lead.Header.Element(2), now.Format("20060102"), now.Format("1504"), lead.ControlNumber(), "X", opts.GroupVersion()}},
+	}
+	for i, g := range groups {
+		segments = append(segments, ackGroup(g, fmt.Sprintf("%04d", i+1), validate)...)
+	}
+	segments = append(segments,
+		x12.Segment{ID: "GE", Elements: []string{fmt.Sprint(len(groups)), lead.ControlNumber()}},
+		x12.Segment{ID: "IEA", Elements: []string{"1", isa.Element(13)}},
 	)
-	// ST, AK1, AK9, SE, GE, IEA
-	st := fmt.Sprintf("ST*997*%s~\n", origSetCtrl)
-	ak1 := fmt.Sprintf("AK1*PO*%s~\n", origGroupCtrl)
-	ak9 := "AK9*A*1*1*1~\n"
-	se := fmt.Sprintf("SE*6*%s~\n", origSetCtrl)
-	ge := fmt.Sprintf("GE*1*%s~\n", origGroupCtrl)
-	iea := fmt.Sprintf("IEA*1*%s~", origInterchangeCtrl)
+	return string(opts.Write(segments)), nil
+}
 
-	return isa + gs + st + ak1 + ak9 + se + ge + iea, nil
+/*
+ackGroup builds the 997 transaction set (ST through SE) acknowledging one
+functional group.
+*/
+func ackGroup(g x12.Group, control string, validate SetValidator) []x12.Segment {
+	segments := []x12.Segment{
+		{ID: "ST", Elements: []string{"997", control}},
+		{ID: "AK1", Elements: []string{g.Header.Element(1), g.ControlNumber()}},
+	}
+	accepted := 0
+	for _, set := range g.Sets {
+		codes := set.Errors()
+		if validate != nil && set.Trailer != nil {
+			codes = append(codes, validate(set)...)
+		}
+		ak5 := []string{"A"}
+		if len(codes) > 0 {
+			// AK5 carries at most five error codes.
+			if len(codes) > 5 {
+				codes = codes[:5]
+			}
+			ak5 = append([]string{"R"}, codes...)
+		} else {
+			accepted++
+		}
+		segments = append(segments,
+			x12.Segment{ID: "AK2", Elements: []string{set.ID(), set.ControlNumber()}},
+			x12.Segment{ID: "AK5", Elements: ak5},
+		)
+	}
+
+	groupCodes := g.Errors()
+	status := "A"
+	switch {
+	case len(groupCodes) > 0 || accepted == 0:
+		status = "R"
+	case accepted < len(g.Sets):
+		status = "P"
+	}
+	if len(groupCodes) > 5 {
+		groupCodes = groupCodes[:5]
+	}
+	ak9 := append([]string{status, fmt.Sprint(g.DeclaredSets()), fmt.Sprint(len(g.Sets)), fmt.Sprint(accepted)}, groupCodes...)
+	segments = append(segments, x12.Segment{ID: "AK9", Elements: ak9})
+	segments = append(segments, x12.Segment{ID: "SE", Elements: []string{fmt.Sprint(len(segments) + 1), control}})
+	return segments
 }
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/heinrichb/avcimporter/pkg/x12"
+)
+
+const ackISA = "ISA*00*          *00*          *ZZ*AMAZON         *ZZ*VENDOR         *240102*1200*U*00401*000000101*0*P*>~\n"
+
+const ack850 = "ST*850*0001~\n" +
+	"BEG*00*SA*PO12345**20240102~\n" +
+	"PO1*1*10*EA*9.99**UP*012345678905~\n" +
+	"CTT*1~\n" +
+	"SE*5*0001~\n"
+
+const ack850Second = "ST*850*0002~\n" +
+	"BEG*00*SA*PO12346**20240102~\n" +
+	"CTT*0~\n" +
+	"SE*4*0002~\n"
+
+// ackInterchange wraps groups in the ISA/IEA envelope of ackISA.
+func ackInterchange(groups ...string) string {
+	return ackISA + strings.Join(groups, "") + fmt.Sprintf("IEA*%d*000000101~\n", len(groups))
+}
+
+// ackSegments returns the segments of the 997 transaction sets in out, from
+// the first ST to the GE, each written as "ID*element*element".
+func ackSegments(t *testing.T, out string) []string {
+	t.Helper()
+	doc, err := x12.Parse([]byte(out))
+	if err != nil {
+		t.Fatalf("Parse() of the 997 returned %v", err)
+	}
+	var segments []string
+	inSets := false
+	for _, s := range doc.Segments {
+		if s.ID == "ST" {
+			inSets = true
+		}
+		if inSets {
+			segments = append(segments, strings.Join(append([]string{s.ID}, s.Elements...), "*"))
+		}
+		if s.ID == "GE" {
+			break
+		}
+	}
+	return segments
+}
+
+// TestGenerate997WithRejections verifies the AK5 and AK9 segments written for
+// broken envelopes and validator rejections, including the cap at five error
+// codes and interchanges with more than one functional group.
+func TestGenerate997WithRejections(t *testing.T) {
+	rejectAll := func(codes ...string) SetValidator {
+		return func(x12.TransactionSet) []string { return codes }
+	}
+	tests := []struct {
+		name     string
+		in       string
+		validate SetValidator
+		expected []string
+	}{
+		{
+			name: "accepted",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + ack850 + "GE*1*101~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*A", "AK9*A*1*1*1", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "set control number mismatch",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + strings.Replace(ack850, "SE*5*0001", "SE*5*0009", 1) + "GE*1*101~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*R*3", "AK9*R*1*1*0", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "set segment count wrong",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + strings.Replace(ack850, "SE*5*0001", "SE*4*0001", 1) + "GE*1*101~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*R*4", "AK9*R*1*1*0", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "set trailer missing",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + strings.Replace(ack850, "SE*5*0001~\n", "", 1) + "GE*1*101~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*R*2", "AK9*R*1*1*0", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name:     "rejected by the validator",
+			in:       ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + ack850 + "GE*1*101~\n"),
+			validate: rejectAll(x12.SetSegmentsInError),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*R*5", "AK9*R*1*1*0", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name:     "at most five AK5 error codes",
+			in:       ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + strings.Replace(ack850, "SE*5*0001", "SE*4*0009", 1) + "GE*1*101~\n"),
+			validate: rejectAll(x12.SetSegmentsInError, x12.SetNotSupported, x12.SetSegmentsInError, x12.SetNotSupported),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*R*3*4*5*1*5", "AK9*R*1*1*0", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "partially accepted group",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + ack850 + strings.Replace(ack850Second, "SE*4*0002", "SE*3*0002", 1) + "GE*2*101~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*A", "AK2*850*0002", "AK5*R*4", "AK9*P*2*2*1", "SE*8*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "group trailer broken",
+			in:   ackInterchange("GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" + ack850 + "GE*2*102~\n"),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*A", "AK9*R*2*1*1*4*5", "SE*6*0001",
+				"GE*1*101",
+			},
+		},
+		{
+			name: "more than one group",
+			in: ackInterchange(
+				"GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n"+ack850+"GE*1*101~\n",
+				"GS*PO*AMAZON*VENDOR*20240102*1200*102*X*004010~\n"+strings.Replace(ack850Second, "SE*4*0002", "SE*4*0003", 1)+"GE*1*102~\n",
+			),
+			expected: []string{
+				"ST*997*0001", "AK1*PO*101", "AK2*850*0001", "AK5*A", "AK9*A*1*1*1", "SE*6*0001",
+				"ST*997*0002", "AK1*PO*102", "AK2*850*0002", "AK5*R*3", "AK9*R*1*1*0", "SE*6*0002",
+				"GE*2*101",
+			},
+		},
+	}
+	for _, tt := range tests {
+		out, err := Generate997With(tt.in, "VENDOR", x12.DefaultOptions, tt.validate)
+		if err != nil {
+			t.Errorf("%s: Generate997With() returned %v", tt.name, err)
+			continue
+		}
+		if got := ackSegments(t, out); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: Generate997With() = %q; expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -2,26 +2,46 @@
 package utils
 
 import (
-	"fmt"
-	"os"
+	"errors"
 )
 
 /*
-HandleError prints an error message and optionally exits the application.
+HandleError prepares err for the caller to report, instead of printing it and
+exiting the application.
 
 Parameters:
   - err: The error object to handle.
   - exit: A boolean indicating if the program should terminate.
 
+Returns:
+  - nil if err is nil.
+  - err, marked so IsFatal reports true when exit is set.
+
 Usage:
-    utils.HandleError(err, true)  // Exits program
-    utils.HandleError(err, false) // Prints error, continues execution
+
+	return utils.HandleError(err, true)  // Caller exits, IsFatal(err) is true
+	return utils.HandleError(err, false) // Caller reports err and continues
 */
-func HandleError(err error, exit bool) {
-	if err != nil {
-		fmt.Println("[Error]:", err.Error())
-		if exit {
-			os.Exit(1)
-		}
+func HandleError(err error, exit bool) error {
+	if err == nil || !exit {
+		return err
 	}
+	return fatalError{err: err}
+}
+
+// fatalError marks an error HandleError was asked to exit on.
+type fatalError struct {
+	err error
+}
+
+func (e fatalError) Error() string { return e.err.Error() }
+func (e fatalError) Unwrap() error { return e.err }
+
+/*
+IsFatal reports whether err was marked by HandleError as one the application
+should exit on.
+*/
+func IsFatal(err error) bool {
+	var f fatalError
+	return errors.As(err, &f)
 }
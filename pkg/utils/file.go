@@ -10,8 +10,8 @@ import (
 )
 
 /*
-SaveToFile writes the provided data to the specified file path.
-It automatically creates the directory if it does not exist.
+SaveToFile writes the provided data to the specified file path with
+DefaultPermissions. It automatically creates the directory if it does not exist.
 
 Parameters:
   - path: The directory where the file will be saved.
@@ -22,7 +22,16 @@ Returns:
   - error: An error object if the save fails, otherwise nil.
 */
 func SaveToFile(path string, filename string, data interface{}) error {
-	CreateDirectoryIfNotExist(path)
+	var p *Permissions
+	return p.SaveToFile(path, filename, data)
+}
+
+/*
+SaveToFile writes data like the package-level SaveToFile, creating the
+directory and the file with the modes and ownership of p.
+*/
+func (p *Permissions) SaveToFile(path string, filename string, data interface{}) error {
+	p.CreateDirectoryIfNotExist(path)
 
 	fullPath := filepath.Join(path, filename)
 
@@ -41,8 +50,7 @@ func SaveToFile(path string, filename string, data interface{}) error {
 		}
 	}
 
-	// Use os.WriteFile instead of ioutil.WriteFile
-	if err := os.WriteFile(fullPath, output, 0644); err != nil {
+	if err := p.WriteFile(fullPath, output); err != nil {
 		return fmt.Errorf("failed to write to file %s: %w", fullPath, err)
 	}
 
@@ -74,7 +82,8 @@ func LoadFromFile(path string) ([]byte, error) {
 }
 
 /*
-CreateDirectoryIfNotExist checks if a directory exists, and creates it if it doesn't.
+CreateDirectoryIfNotExist checks if a directory exists, and creates it with
+DefaultPermissions if it doesn't.
 
 Parameters:
   - path: The directory path.
@@ -83,8 +92,17 @@ Returns:
   - error: An error object if the directory cannot be created, otherwise nil.
 */
 func CreateDirectoryIfNotExist(path string) error {
+	var p *Permissions
+	return p.CreateDirectoryIfNotExist(path)
+}
+
+/*
+CreateDirectoryIfNotExist creates path like the package-level function, with
+the directory mode and ownership of p.
+*/
+func (p *Permissions) CreateDirectoryIfNotExist(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		if err := os.MkdirAll(path, 0755); err != nil {
+		if err := p.MkdirAll(path); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", path, err)
 		}
 	}
@@ -0,0 +1,85 @@
+// pkg/utils/logsink.go
+package utils
+
+import (
+	"time"
+)
+
+/*
+LogRecord is a log message as passed to log sinks.
+
+Fields:
+  - Time:    When the message was logged.
+  - Level:   Severity; LevelInfo for PrintColored, as given to Log otherwise.
+  - Module:  Log module of debug messages (e.g. "sftp"); empty otherwise.
+  - Flow:    Flow label from WithLogPrefix, if any.
+  - Message: The message text, without colors or flow prefix.
+*/
+type LogRecord struct {
+	Time    time.Time
+	Level   Level
+	Module  string
+	Flow    string
+	Message string
+}
+
+/*
+LogSink receives log messages in addition to the log output, e.g. to forward
+them to a log aggregation service. Log is called while the message is being
+logged, so it must not block and must not log itself.
+*/
+type LogSink interface {
+	Log(r LogRecord)
+}
+
+// logSink is an installed sink with the most detailed level it receives.
+type logSink struct {
+	sink  LogSink
+	level Level
+}
+
+/*
+AddLogSink installs s for every message of l at or below level, independently
+of the levels of the log output.
+*/
+func (l *Logger) AddLogSink(s LogSink, level Level) {
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	l.sinks = append(l.sinks, logSink{sink: s, level: level})
+}
+
+/*
+RemoveLogSinks uninstalls every sink added with AddLogSink.
+*/
+func (l *Logger) RemoveLogSinks() {
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	l.sinks = nil
+}
+
+// sinksWant reports whether any sink receives messages of level.
+func (l *Logger) sinksWant(level Level) bool {
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	for _, s := range l.sinks {
+		if level <= s.level {
+			return true
+		}
+	}
+	return false
+}
+
+// emit passes r to every sink receiving its level.
+func (l *Logger) emit(r LogRecord) {
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	if len(l.sinks) == 0 {
+		return
+	}
+	r.Time = time.Now()
+	for _, s := range l.sinks {
+		if r.Level <= s.level {
+			s.sink.Log(r)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// pkg/utils/perm.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+Permissions are the mode and ownership given to the files and directories the
+importer writes (storage.permissions). The methods of a nil *Permissions use
+DefaultPermissions.
+
+Fields:
+  - FileMode: Mode of written files.
+  - DirMode:  Mode of created directories.
+  - UID:      Owner set on both, or -1 to keep the process user.
+  - GID:      Group set on both, or -1 to keep the process group.
+*/
+type Permissions struct {
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	UID      int
+	GID      int
+}
+
+// DefaultPermissions returns the historical modes, without changing ownership.
+func DefaultPermissions() Permissions {
+	return Permissions{FileMode: 0o644, DirMode: 0o755, UID: -1, GID: -1}
+}
+
+// get returns *p, or DefaultPermissions for a nil p.
+func (p *Permissions) get() Permissions {
+	if p == nil {
+		return DefaultPermissions()
+	}
+	return *p
+}
+
+/*
+MkdirAll creates dir and any missing parents with the directory mode and
+ownership of p. Directories that already exist are left alone. The mode is
+set explicitly, so the umask does not strip group write access.
+*/
+func (p *Permissions) MkdirAll(dir string) error {
+	perms := p.get()
+	// Find the directories that will be created, outermost first.
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, perms.DirMode); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := apply(d, perms.DirMode, perms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Apply gives the file at path the file mode and ownership of p, e.g. a
+temporary file (created with mode 0600) before it is renamed into place.
+*/
+func (p *Permissions) Apply(path string) error {
+	perms := p.get()
+	return apply(path, perms.FileMode, perms)
+}
+
+/*
+WriteFile writes data to path with the file mode and ownership of p.
+*/
+func (p *Permissions) WriteFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, p.get().FileMode); err != nil {
+		return err
+	}
+	return p.Apply(path)
+}
+
+/*
+ParseFileMode parses an octal mode such as "0664" or "2775"; the setuid,
+setgid and sticky bits (4000, 2000, 1000) are supported.
+*/
+func ParseFileMode(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q: expected octal such as 0644", s)
+	}
+	mode := os.FileMode(n & 0o777)
+	for bit, flag := range map[uint64]os.FileMode{0o4000: os.ModeSetuid, 0o2000: os.ModeSetgid, 0o1000: os.ModeSticky} {
+		if n&bit != 0 {
+			mode |= flag
+		}
+	}
+	return mode, nil
+}
+
+// modeBits are the parts of a mode apply sets.
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// apply sets mode and, if configured, the owner of path. The mode is only
+// changed if it differs, so files of other users sharing the directory can
+// still be replaced in place.
+func apply(path string, mode os.FileMode, p Permissions) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&modeBits != mode&modeBits {
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", path, err)
+		}
+	}
+	if p.UID == -1 && p.GID == -1 {
+		return nil
+	}
+	if err := os.Chown(path, p.UID, p.GID); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", path, err)
+	}
+	return nil
+}
@@ -2,11 +2,13 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 /*
@@ -15,6 +17,185 @@ Example: #FFFFFF, #000000
 */
 var hexColorPattern = regexp.MustCompile(`^#?([A-Fa-f0-9]{6})$`)
 
+/*
+Logger prints human-readable messages: colored when its output is a terminal,
+preceded by the flow prefix of their context, filtered by level and passed to
+the installed log sinks. Writes are serialized, so lines from flows running in
+parallel never interleave, and a progress line drawn by NewProgress is cleared
+before a message is printed.
+
+A nil *Logger discards everything, so packages that accept a logger print
+nothing unless the application passes its own.
+*/
+type Logger struct {
+	// mu serializes every write of a log line or progress redraw and guards
+	// the output settings. progressWidth is the length of the progress line
+	// currently shown on out (0 when none), which a log line overwrites before
+	// it is printed; the next redraw shows it again below.
+	mu            sync.Mutex
+	out           *os.File
+	color         bool
+	flowPrefixes  bool
+	progressWidth int
+
+	levelMu      sync.RWMutex
+	level        Level
+	moduleLevels map[string]Level
+
+	sinksMu sync.RWMutex
+	sinks   []logSink
+
+	transfersMu sync.Mutex
+	transfers   map[*Progress]struct{}
+}
+
+/*
+NewLogger returns a Logger writing to out at LevelInfo, with flow prefixes
+and with colors as detected for out (see detectColor).
+*/
+func NewLogger(out *os.File) *Logger {
+	return &Logger{
+		out:          out,
+		color:        detectColor(out),
+		flowPrefixes: true,
+		level:        LevelInfo,
+		moduleLevels: map[string]Level{},
+		transfers:    map[*Progress]struct{}{},
+	}
+}
+
+// writeLine writes one complete line to w under l.mu.
+func (l *Logger) writeLine(w io.Writer, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.progressWidth > 0 && w == io.Writer(l.out) {
+		line = "\r" + strings.Repeat(" ", l.progressWidth) + "\r" + line
+		l.progressWidth = 0
+	}
+	io.WriteString(w, line)
+}
+
+/*
+SetFlowPrefixes enables or disables the per-flow prefixes of PrintColoredContext.
+*/
+func (l *Logger) SetFlowPrefixes(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flowPrefixes = enabled
+}
+
+// logPrefixKey is the context key of the flow prefix.
+type logPrefixKey struct{}
+
+/*
+WithLogPrefix returns ctx carrying a flow label (e.g. "EDI" or "SP-API NA")
+that PrintColoredContext puts in brackets before each message, so the output
+of flows running side by side can be told apart.
+*/
+func WithLogPrefix(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, logPrefixKey{}, label)
+}
+
+/*
+LogPrefix returns the "[label] " prefix of ctx, or "" when it has no label or
+prefixes are disabled.
+*/
+func (l *Logger) LogPrefix(ctx context.Context) string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	enabled := l.flowPrefixes
+	l.mu.Unlock()
+	if label := logLabel(ctx); enabled && label != "" {
+		return "[" + label + "] "
+	}
+	return ""
+}
+
+// logLabel returns the flow label of ctx, if any.
+func logLabel(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	label, _ := ctx.Value(logPrefixKey{}).(string)
+	return label
+}
+
+/*
+detectColor decides whether ANSI escapes are written to out, applying the
+usual conventions in order of precedence:
+  - NO_COLOR set to any non-empty value disables color (https://no-color.org).
+  - FORCE_COLOR enables color, unless it is "0" or "false".
+  - Otherwise color is used only when out is a terminal, so output captured by
+    cron, CI or a pipe stays free of escape sequences.
+
+On Windows the console must also accept virtual-terminal processing; consoles
+that predate it would otherwise print the escapes literally.
+*/
+func detectColor(out *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		if force == "0" || strings.EqualFold(force, "false") {
+			return false
+		}
+		if out != nil {
+			enableVirtualTerminal(out)
+		}
+		return true
+	}
+	return out != nil && IsTerminal(out) && enableVirtualTerminal(out)
+}
+
+/*
+SetOutput redirects the messages of l to f (e.g. os.Stderr) and re-runs color
+detection for it. Call it before SetColorEnabled so an explicit override is
+not lost.
+*/
+func (l *Logger) SetOutput(f *os.File) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = f
+	l.color = detectColor(f)
+	l.progressWidth = 0
+}
+
+/*
+Output returns the file human-readable messages are written to, or nil for a
+nil Logger.
+*/
+func (l *Logger) Output() *os.File {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out
+}
+
+/*
+SetColorEnabled overrides the detected color setting (e.g. for --no-color).
+*/
+func (l *Logger) SetColorEnabled(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.color = enabled
+}
+
+/*
+ColorEnabled reports whether colored output is currently enabled.
+*/
+func (l *Logger) ColorEnabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.color
+}
+
 /*
 hexToANSI converts a hex color code to an ANSI escape code for 24-bit "true color".
 Example: #FF5733 → "\033[38;2;255;87;51m"
@@ -36,7 +217,8 @@ func hexToANSI(hex string) string {
 }
 
 /*
-FprintColored writes a colored line to the provided writer.
+FprintColored writes a colored line to the provided writer. It keeps no
+state; use a Logger for output that honours color detection and levels.
 
 Parameters:
   - w: The io.Writer where output is written.
@@ -48,19 +230,92 @@ Usage:
 	FprintColored(os.Stdout, "Loaded config from: ", configPath, "#FF5733")
 */
 func FprintColored(w io.Writer, prefix, secondary, hexColor string) {
-	ansiColor := hexToANSI(hexColor)
-	resetColor := "\033[0m"
+	io.WriteString(w, colorLine(true, prefix, secondary, hexColor))
+}
+
+// colorLine renders prefix in hexColor (when color is set) followed by
+// secondary and a newline.
+func colorLine(color bool, prefix, secondary, hexColor string) string {
+	if !color {
+		return prefix + secondary + "\n"
+	}
+	return hexToANSI(hexColor) + prefix + "\033[0m" + secondary + "\n"
+}
 
-	if secondary != "" {
-		fmt.Fprintf(w, "%s%s%s%s\n", ansiColor, prefix, resetColor, secondary)
-	} else {
-		fmt.Fprintln(w, ansiColor+prefix+resetColor)
+/*
+FprintColored writes a line to w like the package-level FprintColored, with
+colors only when l has them enabled, serialized with the other output of l.
+*/
+func (l *Logger) FprintColored(w io.Writer, prefix, secondary, hexColor string) {
+	if l == nil {
+		return
 	}
+	l.writeLine(w, colorLine(l.ColorEnabled(), prefix, secondary, hexColor))
+}
+
+/*
+PrintOutput writes a line to the log output regardless of levels and without
+passing it to the sinks, e.g. for a sink reporting its own failures.
+*/
+func (l *Logger) PrintOutput(prefix, secondary, hexColor string) {
+	l.FprintColored(l.Output(), prefix, secondary, hexColor)
+}
+
+/*
+Colorize returns text wrapped in the ANSI color for hexColor, or text unchanged
+when color output is disabled.
+
+Usage:
+
+	fmt.Println(logger.Colorize("100.0%", "#32CD32"))
+*/
+func (l *Logger) Colorize(text, hexColor string) string {
+	if !l.ColorEnabled() {
+		return text
+	}
+	return hexToANSI(hexColor) + text + "\033[0m"
+}
+
+/*
+Style is a reusable hex color with fmt-style helpers, colored when its
+Logger has colors enabled.
+
+Usage:
+
+	warn := logger.Style("#FFFF00")
+	fmt.Println(warn.Sprintf("%d files skipped", n))
+*/
+type Style struct {
+	logger *Logger
+	color  string
+}
+
+/*
+Style returns the Style of hexColor for output of l.
+*/
+func (l *Logger) Style(hexColor string) Style {
+	return Style{logger: l, color: hexColor}
+}
+
+/*
+Sprint formats its arguments like fmt.Sprint and colors the result.
+*/
+func (s Style) Sprint(a ...interface{}) string {
+	return s.logger.Colorize(fmt.Sprint(a...), s.color)
+}
+
+/*
+Sprintf formats its arguments like fmt.Sprintf and colors the result.
+*/
+func (s Style) Sprintf(format string, a ...interface{}) string {
+	return s.logger.Colorize(fmt.Sprintf(format, a...), s.color)
 }
 
 /*
 PrintColored is the main exported function for this utility.
-It dynamically determines how to print colored output based on the types of arguments passed.
+It dynamically determines how to print colored output based on the types of
+arguments passed, and writes to os.Stdout without keeping any state. Use
+Logger.PrintColored for output that honours levels, sinks and color detection.
 
 Usage:
  1. To print a single string:
@@ -69,29 +324,103 @@ Usage:
     PrintColored("Prefix: ", "Secondary", "#FF5733")
 */
 func PrintColored(args ...interface{}) {
+	if prefix, secondary, hexColor, ok := coloredArgs(args); ok {
+		FprintColored(os.Stdout, prefix, secondary, hexColor)
+	}
+}
+
+// coloredArgs reads the prefix, secondary string and hex color of PrintColored.
+func coloredArgs(args []interface{}) (prefix, secondary, hexColor string, ok bool) {
 	if len(args) == 0 {
-		return
+		return "", "", "", false
 	}
 
 	// Otherwise, assume the first argument is a string.
-	prefix, ok := args[0].(string)
+	prefix, ok = args[0].(string)
 	if !ok {
-		return
+		return "", "", "", false
 	}
 
-	secondary := ""
 	if len(args) >= 2 {
 		if sec, ok := args[1].(string); ok {
 			secondary = sec
 		}
 	}
 
-	hexColor := "#FFFFFF" // Default to white
+	hexColor = "#FFFFFF" // Default to white
 	if len(args) > 2 {
 		if colorStr, ok := args[2].(string); ok {
 			hexColor = colorStr
 		}
 	}
+	return prefix, secondary, hexColor, true
+}
+
+/*
+PrintColored prints an info message like the package-level PrintColored to
+the output of l, if info messages are enabled, and passes it to the sinks of
+l. The color only changes how the message is displayed; use Log for errors
+and warnings.
+*/
+func (l *Logger) PrintColored(args ...interface{}) {
+	if prefix, secondary, hexColor, ok := coloredArgs(args); ok {
+		l.print(context.Background(), LevelInfo, prefix, secondary, hexColor)
+	}
+}
+
+/*
+PrintColoredContext prints like PrintColored(prefix, secondary, hexColor),
+preceded by the flow prefix of ctx (see WithLogPrefix).
+
+Usage:
+
+	logger.PrintColoredContext(ctx, "Downloaded: ", name, "#32CD32") // [EDI] Downloaded: PO_1.edi
+*/
+func (l *Logger) PrintColoredContext(ctx context.Context, prefix, secondary, hexColor string) {
+	l.print(ctx, LevelInfo, prefix, secondary, hexColor)
+}
+
+/*
+Log prints a message of level in the color of the level (red for errors,
+yellow for warnings, green otherwise), if the level is enabled, and passes it
+to the sinks of l with that level.
 
-	FprintColored(os.Stdout, prefix, secondary, hexColor)
+Usage:
+
+	logger.Log(utils.LevelError, "Failed to load config: ", err.Error())
+*/
+func (l *Logger) Log(level Level, prefix, detail string) {
+	l.print(context.Background(), level, prefix, detail, levelColor(level))
+}
+
+/*
+LogContext prints like Log, preceded by the flow prefix of ctx (see
+WithLogPrefix), e.g. a retry reported by Retry.
+*/
+func (l *Logger) LogContext(ctx context.Context, level Level, prefix, detail string) {
+	l.print(ctx, level, prefix, detail, levelColor(level))
+}
+
+// print passes a message of level to the sinks of l and prints it in hexColor,
+// after the flow prefix of ctx, when the level is enabled.
+func (l *Logger) print(ctx context.Context, level Level, prefix, secondary, hexColor string) {
+	if l == nil {
+		return
+	}
+	l.emit(LogRecord{Level: level, Flow: logLabel(ctx), Message: prefix + secondary})
+	if !l.Enabled("", level) {
+		return
+	}
+	l.FprintColored(l.Output(), l.LogPrefix(ctx)+prefix, secondary, hexColor)
+}
+
+// levelColor returns the color messages of level are printed in.
+func levelColor(level Level) string {
+	switch level {
+	case LevelError:
+		return "#FF0000"
+	case LevelWarn:
+		return "#FFFF00"
+	}
+	return "#32CD32"
 }
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestDetectColor verifies the precedence of NO_COLOR over FORCE_COLOR over
+// terminal detection, and that SetColorEnabled overrides the result.
+func TestDetectColor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the null device is no console on Windows")
+	}
+	// IsTerminal accepts any character device, so the null device stands in
+	// for a terminal; a regular file is captured output.
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tty.Close()
+	file, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	unset := "<unset>"
+	tests := []struct {
+		name    string
+		noColor string
+		force   string
+		out     *os.File
+		want    bool
+	}{
+		{"terminal", unset, unset, tty, true},
+		{"file", unset, unset, file, false},
+		{"NO_COLOR on terminal", "1", unset, tty, false},
+		{"empty NO_COLOR", "", unset, tty, true},
+		{"FORCE_COLOR on file", unset, "1", file, true},
+		{"empty FORCE_COLOR", unset, "", file, true},
+		{"FORCE_COLOR=0 on terminal", unset, "0", tty, false},
+		{"FORCE_COLOR=false", unset, "FALSE", tty, false},
+		{"NO_COLOR beats FORCE_COLOR", "1", "1", file, false},
+	}
+	for _, tt := range tests {
+		for name, value := range map[string]string{"NO_COLOR": tt.noColor, "FORCE_COLOR": tt.force} {
+			t.Setenv(name, value)
+			if value == unset {
+				os.Unsetenv(name)
+			}
+		}
+		if got := NewLogger(tt.out).ColorEnabled(); got != tt.want {
+			t.Errorf("%s: ColorEnabled() = %v; expected %v", tt.name, got, tt.want)
+		}
+	}
+
+	os.Unsetenv("NO_COLOR")
+	t.Setenv("FORCE_COLOR", "1")
+	logger := NewLogger(file)
+	logger.SetColorEnabled(false)
+	if logger.ColorEnabled() {
+		t.Error("ColorEnabled() = true after SetColorEnabled(false)")
+	}
+	logger.SetOutput(file)
+	if !logger.ColorEnabled() {
+		t.Error("ColorEnabled() = false after SetOutput() re-ran detection")
+	}
+}
+
+// recordingSink collects the records passed to it.
+type recordingSink struct {
+	records []LogRecord
+}
+
+func (s *recordingSink) Log(r LogRecord) {
+	s.records = append(s.records, r)
+}
+
+// TestLogLevels verifies that the level of a message is the one given to Log,
+// not told by its color, both for the sinks and for the log output.
+func TestLogLevels(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	logger := NewLogger(file)
+	logger.SetColorEnabled(false)
+	logger.SetLogLevel(LevelWarn)
+	sink := &recordingSink{}
+	logger.AddLogSink(sink, LevelDebug)
+
+	logger.Log(LevelError, "Failed: ", "boom")
+	logger.PrintColored("Red: ", "display only", "#FF0000")
+	logger.LogContext(WithLogPrefix(context.Background(), "EDI"), LevelWarn, "Retrying: ", "once")
+	logger.PrintColoredContext(context.Background(), "Yellow: ", "display only", "#FFFF00")
+
+	expected := []LogRecord{
+		{Level: LevelError, Message: "Failed: boom"},
+		{Level: LevelInfo, Message: "Red: display only"},
+		{Level: LevelWarn, Flow: "EDI", Message: "Retrying: once"},
+		{Level: LevelInfo, Message: "Yellow: display only"},
+	}
+	if len(sink.records) != len(expected) {
+		t.Fatalf("sink received %d records; expected %d", len(sink.records), len(expected))
+	}
+	for i, r := range sink.records {
+		r.Time = time.Time{}
+		if r != expected[i] {
+			t.Errorf("record %d = %+v; expected %+v", i, r, expected[i])
+		}
+	}
+
+	out, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "Failed: boom\n[EDI] Retrying: once\n"; got != want {
+		t.Errorf("output = %q; expected %q", got, want)
+	}
+}
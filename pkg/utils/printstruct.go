@@ -6,7 +6,8 @@ import (
 )
 
 /*
-PrintNonEmptyFields dynamically traverses a struct and prints its non-empty string fields.
+PrintNonEmptyFields dynamically traverses a struct and prints its non-empty string fields
+to the output of l.
 
 Parameters:
   - prefix: A string to prepend to the field name, used to represent nested struct hierarchy (e.g., "Parent.Child.").
@@ -28,7 +29,7 @@ Example:
 	      }
 	  }
 
-	Calling PrintNonEmptyFields("", configInstance) will output something like:
+	Calling logger.PrintNonEmptyFields("", configInstance) will output something like:
 
 	  URL: http://example.com
 	  Nested.Title: Example Title
@@ -36,9 +37,9 @@ Example:
 Notes:
   - This function relies on the reflect package and assumes that the input is a struct or a pointer to a struct.
   - Only string fields are checked for non-emptiness; other types are ignored.
-  - Colored output is now handled by the PrintColored utility from this package.
+  - Lines are written with FprintColored, regardless of log levels.
 */
-func PrintNonEmptyFields(prefix string, v interface{}) {
+func (l *Logger) PrintNonEmptyFields(prefix string, v interface{}) {
 	val := reflect.ValueOf(v)
 
 	// Handle pointers by obtaining the element value.
@@ -56,9 +57,9 @@ func PrintNonEmptyFields(prefix string, v interface{}) {
 
 		// If the field is a nested struct, recursively print its non-empty fields.
 		if field.Kind() == reflect.Struct {
-			PrintNonEmptyFields(prefix+fieldName+".", field.Interface())
+			l.PrintNonEmptyFields(prefix+fieldName+".", field.Interface())
 		} else if field.Kind() == reflect.String && field.String() != "" {
-			PrintColored(prefix+fieldName+": ", field.String(), "#FFFF00")
+			l.FprintColored(l.Output(), prefix+fieldName+": ", field.String(), "#FFFF00")
 		}
 	}
 }
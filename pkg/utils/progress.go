@@ -0,0 +1,280 @@
+// pkg/utils/progress.go
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressRedraw limits how often the progress line is redrawn.
+const progressRedraw = 100 * time.Millisecond
+
+// progressBarWidth is the number of cells in each bar.
+const progressBarWidth = 20
+
+/*
+Progress tracks a batch of file transfers. While it runs it is listed by
+Transfers of its Logger; when progress display is enabled it also renders a
+single, continuously redrawn line showing the current file's progress and the
+overall progress of the batch, with throughput and ETA.
+
+Usage:
+
+	p := logger.NewProgress(ctx, true, totalBytes, len(files))
+	for each file: io.Copy(dst, p.Reader(name, size, src))
+	p.Finish()
+*/
+type Progress struct {
+	logger     *Logger
+	w          io.Writer
+	label      string
+	mu         sync.Mutex
+	total      int64
+	done       int64
+	files      int
+	fileIndex  int
+	fileName   string
+	fileSize   int64
+	fileDone   int64
+	start      time.Time
+	fileStart  time.Time
+	lastDrawn  time.Time
+	lineLength int
+}
+
+/*
+NewProgress returns a Progress for a batch of files totalling totalBytes,
+listed by Transfers under the flow label of ctx (see WithLogPrefix) until
+Finish. With draw it draws to the output of l if that is a terminal. All
+methods are safe to call on a nil *Progress; a nil Logger neither lists nor
+draws the batch.
+*/
+func (l *Logger) NewProgress(ctx context.Context, draw bool, totalBytes int64, files int) *Progress {
+	p := &Progress{logger: l, label: logLabel(ctx), total: totalBytes, files: files, start: time.Now()}
+	if l == nil {
+		return p
+	}
+	if w := l.Output(); draw && w != nil && IsTerminal(w) {
+		p.w = w
+	}
+	l.transfersMu.Lock()
+	l.transfers[p] = struct{}{}
+	l.transfersMu.Unlock()
+	return p
+}
+
+/*
+Reader starts tracking a new file and returns r wrapped so every read advances
+the progress display.
+*/
+func (p *Progress) Reader(name string, size int64, r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	p.mu.Lock()
+	p.fileIndex++
+	p.fileName = name
+	p.fileSize = size
+	p.fileDone = 0
+	p.fileStart = time.Now()
+	p.mu.Unlock()
+	return &progressReader{p: p, r: r}
+}
+
+/*
+Finish removes the batch from Transfers and, when drawing, draws the final
+state and moves the cursor to a new line.
+*/
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	if p.logger == nil {
+		return
+	}
+	p.logger.transfersMu.Lock()
+	delete(p.logger.transfers, p)
+	p.logger.transfersMu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return
+	}
+	p.draw()
+	p.logger.mu.Lock()
+	defer p.logger.mu.Unlock()
+	fmt.Fprintln(p.w)
+	p.logger.progressWidth = 0
+}
+
+// add records n transferred bytes and redraws if due.
+func (p *Progress) add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += int64(n)
+	p.fileDone += int64(n)
+	if p.w != nil && time.Since(p.lastDrawn) >= progressRedraw {
+		p.draw()
+	}
+}
+
+// draw renders the progress line; p.mu must be held. Log lines printed since
+// the last redraw have cleared it, so it then starts afresh below them.
+func (p *Progress) draw() {
+	p.lastDrawn = time.Now()
+	fileRate := rate(p.fileDone, p.fileStart)
+	totalRate := rate(p.done, p.start)
+
+	line := fmt.Sprintf("[%d/%d] %s %s %s/s ETA %s | total %s ETA %s",
+		p.fileIndex, p.files, truncateName(p.fileName, 24),
+		bar(p.fileDone, p.fileSize), formatBytes(int64(fileRate)), eta(p.fileSize-p.fileDone, fileRate),
+		bar(p.done, p.total), eta(p.total-p.done, totalRate))
+
+	l := p.logger
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pad := ""
+	if l.progressWidth > 0 && len(line) < p.lineLength {
+		pad = strings.Repeat(" ", p.lineLength-len(line))
+	}
+	p.lineLength = len(line)
+	fmt.Fprint(p.w, "\r"+line+pad)
+	if p.w == io.Writer(l.out) {
+		l.progressWidth = len(line) + len(pad)
+	}
+}
+
+/*
+Transfer is a snapshot of a batch of file transfers in progress.
+
+Fields:
+  - Label:       Flow label of the batch (e.g. "EDI"), if any.
+  - File:        Name of the file being transferred.
+  - FileIndex:   Position of that file in the batch, from 1.
+  - Files:       Files in the batch.
+  - FileDone:    Bytes of the file transferred so far.
+  - FileSize:    Size of the file.
+  - Done:        Bytes of the batch transferred so far.
+  - Total:       Size of the batch.
+  - StartedAt:   When the batch started.
+  - BytesPerSec: Average throughput of the batch.
+*/
+type Transfer struct {
+	Label       string    `json:"label,omitempty"`
+	File        string    `json:"file"`
+	FileIndex   int       `json:"fileIndex"`
+	Files       int       `json:"files"`
+	FileDone    int64     `json:"fileDone"`
+	FileSize    int64     `json:"fileSize"`
+	Done        int64     `json:"done"`
+	Total       int64     `json:"total"`
+	StartedAt   time.Time `json:"startedAt"`
+	BytesPerSec float64   `json:"bytesPerSec"`
+}
+
+/*
+Transfers returns a snapshot of every batch of l in progress, oldest first.
+*/
+func (l *Logger) Transfers() []Transfer {
+	if l == nil {
+		return []Transfer{}
+	}
+	l.transfersMu.Lock()
+	defer l.transfersMu.Unlock()
+	out := make([]Transfer, 0, len(l.transfers))
+	for p := range l.transfers {
+		p.mu.Lock()
+		out = append(out, Transfer{
+			Label: p.label, File: p.fileName, FileIndex: p.fileIndex, Files: p.files,
+			FileDone: p.fileDone, FileSize: p.fileSize, Done: p.done, Total: p.total,
+			StartedAt: p.start, BytesPerSec: rate(p.done, p.start),
+		})
+		p.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+/*
+String renders t like the progress line, e.g.
+"[2/5] 850_0001.edi [====      ]  40% | total [==        ]  20% 1.2 MiB/s ETA 3s".
+*/
+func (t Transfer) String() string {
+	return fmt.Sprintf("[%d/%d] %s %s | total %s %s/s ETA %s",
+		t.FileIndex, t.Files, truncateName(t.File, 24), bar(t.FileDone, t.FileSize),
+		bar(t.Done, t.Total), formatBytes(int64(t.BytesPerSec)), eta(t.Total-t.Done, t.BytesPerSec))
+}
+
+// progressReader forwards reads to r and reports them to p.
+type progressReader struct {
+	p *Progress
+	r io.Reader
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	if n > 0 {
+		pr.p.add(n)
+	}
+	return n, err
+}
+
+// bar renders a fixed-width bar followed by the percentage complete.
+func bar(done, total int64) string {
+	pct := 1.0
+	if total > 0 {
+		pct = float64(done) / float64(total)
+	}
+	if pct > 1 {
+		pct = 1
+	}
+	filled := int(pct * progressBarWidth)
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), pct*100)
+}
+
+// rate returns bytes per second since start.
+func rate(done int64, start time.Time) float64 {
+	elapsed := time.Since(start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(done) / elapsed
+}
+
+// eta formats the estimated time to transfer the remaining bytes.
+func eta(remaining int64, bytesPerSec float64) string {
+	if remaining <= 0 {
+		return "0s"
+	}
+	if bytesPerSec <= 0 {
+		return "--"
+	}
+	return (time.Duration(float64(remaining)/bytesPerSec) * time.Second).Round(time.Second).String()
+}
+
+// formatBytes renders n using binary units (KiB, MiB, ...).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// truncateName shortens name to at most max characters for display.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return fmt.Sprintf("%-*s", max, name)
+	}
+	return name[:max-3] + "..."
+}
@@ -0,0 +1,346 @@
+// pkg/utils/retry.go
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+Error classes understood by ClassifyError and RetryOn.
+*/
+const (
+	ClassNetwork   = "network"   // connection refused/reset, timeouts, unexpected EOF
+	ClassThrottle  = "throttle"  // HTTP 429
+	ClassServer    = "server"    // HTTP 5xx and 408
+	ClassClient    = "client"    // other HTTP 4xx
+	ClassCanceled  = "canceled"  // context canceled or deadline exceeded
+	ClassPermanent = "permanent" // marked with Permanent
+	ClassOther     = "other"     // anything else
+)
+
+/*
+RetryPolicy controls how Retry repeats a failing operation.
+
+Fields:
+  - Name:        Operation or service name used in log output.
+  - MaxAttempts: Total number of attempts (values below 1 are treated as 1).
+  - BaseDelay:   Delay before the first retry; doubled on every following retry.
+  - MaxDelay:    Upper bound for the delay between retries (0 means unbounded).
+  - Jitter:      Fraction of each delay that is randomized (0.2 waits between 80% and 100% of it), so callers failing together do not retry together.
+  - Retryable:   Decides whether an error is worth another attempt; nil uses IsRetryable.
+  - Breaker:     Optional circuit breaker shared by every caller of the same service.
+  - Logger:      Receives a warning before each retry; nil discards them.
+*/
+type RetryPolicy struct {
+	Name        string
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	Retryable   func(error) bool
+	Breaker     *CircuitBreaker
+	Logger      *Logger
+}
+
+/*
+Retry runs fn until it succeeds, returns an error the policy does not retry,
+or the attempts are used up, waiting with exponential backoff in between.
+When the policy has a circuit breaker, every attempt first asks it for
+permission and reports its outcome to it; an open breaker fails immediately
+with an error wrapping ErrCircuitOpen. Errors the policy does not retry (such
+as a rejected request) are not held against the service and are not counted
+as a success either.
+
+Parameters:
+  - ctx:    Bounds the whole operation, including the waits between attempts.
+  - policy: Attempts, delays, error classifier and breaker.
+  - fn:     The operation; it receives ctx and must be safe to repeat.
+
+Returns:
+  - nil on success, otherwise the last error (or ctx.Err() if ctx ends while waiting).
+*/
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if policy.Breaker != nil {
+			if err := policy.Breaker.Allow(); err != nil {
+				return err
+			}
+		}
+		err = fn(ctx)
+		if policy.Breaker != nil {
+			switch {
+			case err == nil:
+				policy.Breaker.Record(false)
+			case retryable(err):
+				policy.Breaker.Record(true)
+			default:
+				policy.Breaker.Release()
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts || !retryable(err) {
+			break
+		}
+		policy.Logger.LogContext(ctx, LevelWarn, "Retrying after error: ", fmt.Sprintf("%s (attempt %d/%d): %v", policy.Name, attempt, attempts, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(policy.backoff(attempt, rand.Float64)):
+		}
+	}
+	return err
+}
+
+/*
+backoff returns the wait before retry n (1 for the first): BaseDelay doubled
+n-1 times and capped at MaxDelay, of which the Jitter fraction is scaled by
+random, a number in [0, 1).
+*/
+func (p RetryPolicy) backoff(n int, random func() float64) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= time.Duration(float64(delay) * jitter * random())
+	}
+	return delay
+}
+
+/*
+StatusError reports an HTTP response with an unexpected status code.
+ClassifyError uses the code to tell throttling and server errors (worth
+retrying) from client errors (not).
+*/
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// permanentError marks an error that must not be retried.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+/*
+Permanent marks err so that Retry returns it without further attempts.
+*/
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+/*
+ClassifyError sorts err into one of the Class* constants.
+*/
+func ClassifyError(err error) string {
+	var perm *permanentError
+	var status *StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &perm):
+		return ClassPermanent
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ClassCanceled
+	case errors.As(err, &status):
+		switch {
+		case status.StatusCode == 429:
+			return ClassThrottle
+		case status.StatusCode >= 500, status.StatusCode == 408:
+			return ClassServer
+		case status.StatusCode >= 400:
+			return ClassClient
+		}
+		return ClassOther
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+/*
+IsRetryable is the default classifier: every error is retried except client
+errors (HTTP 4xx other than 408 and 429), cancellation and errors marked
+Permanent.
+*/
+func IsRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ClassClient, ClassCanceled, ClassPermanent:
+		return false
+	}
+	return err != nil
+}
+
+/*
+RetryOn returns a classifier that retries only errors of the given classes
+(e.g. "network", "throttle", "server"). Cancellation and Permanent errors are
+never retried.
+*/
+func RetryOn(classes ...string) func(error) bool {
+	set := make(map[string]bool, len(classes))
+	for _, c := range classes {
+		set[c] = true
+	}
+	return func(err error) bool {
+		class := ClassifyError(err)
+		return err != nil && class != ClassCanceled && class != ClassPermanent && set[class]
+	}
+}
+
+/*
+ErrCircuitOpen is returned (wrapped) while a circuit breaker rejects calls.
+*/
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+/*
+CircuitBreaker stops calls to a failing service for a cool-down period.
+After Threshold consecutive failures it opens and rejects calls; once
+Cooldown has passed it lets a single probe through, closing again if the
+probe succeeds and reopening if it fails.
+
+Fields:
+  - Name:      Service name used in errors.
+  - Threshold: Consecutive failures that open the breaker (0 disables it).
+  - Cooldown:  How long the breaker stays open before probing.
+*/
+type CircuitBreaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+/*
+Allow reports whether a call may proceed.
+
+Returns:
+  - nil if the breaker is closed or lets a probe through, otherwise an error wrapping ErrCircuitOpen.
+*/
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Threshold <= 0 || b.failures < b.Threshold {
+		return nil
+	}
+	if wait := b.Cooldown - time.Since(b.openedAt); wait > 0 {
+		return fmt.Errorf("%s: %w (retry in %s)", b.Name, ErrCircuitOpen, wait.Round(time.Second))
+	}
+	if b.probing {
+		return fmt.Errorf("%s: %w (probe in progress)", b.Name, ErrCircuitOpen)
+	}
+	b.probing = true
+	return nil
+}
+
+/*
+Record reports the outcome of a call that Allow let through.
+*/
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.Threshold > 0 && b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+/*
+Release ends a call that Allow let through without judging the service, for
+outcomes that say nothing about its health. A probe in progress is given up,
+so the next call probes again.
+*/
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+/*
+State returns "closed", "open" or "half-open".
+*/
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.Threshold <= 0 || b.failures < b.Threshold:
+		return "closed"
+	case time.Since(b.openedAt) < b.Cooldown:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+/*
+Breakers holds circuit breakers by service name, so every caller of a service
+shares one. The zero value is ready to use; the program owning it decides how
+widely it is shared.
+*/
+type Breakers struct {
+	mu sync.Mutex
+	m  map[string]*CircuitBreaker
+}
+
+/*
+Get returns the circuit breaker for a service, creating it on first use.
+Later calls update its threshold and cool-down (so reloaded settings apply)
+without resetting its state.
+*/
+func (bs *Breakers) Get(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.m[name]
+	if !ok {
+		if bs.m == nil {
+			bs.m = map[string]*CircuitBreaker{}
+		}
+		b = &CircuitBreaker{Name: name}
+		bs.m[name] = b
+	}
+	b.mu.Lock()
+	b.Threshold, b.Cooldown = threshold, cooldown
+	b.mu.Unlock()
+	return b
+}
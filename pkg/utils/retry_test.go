@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestBackoff verifies that delays double up to MaxDelay and that jitter only
+// ever shortens a delay, by at most the Jitter fraction.
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		n      int
+		random float64
+		want   time.Duration
+	}{
+		{"first", RetryPolicy{BaseDelay: time.Second}, 1, 0.5, time.Second},
+		{"doubled", RetryPolicy{BaseDelay: time.Second}, 4, 0.5, 8 * time.Second},
+		{"capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 4, 0.5, 5 * time.Second},
+		{"capped late", RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 200, 0.5, 5 * time.Second},
+		{"jitter low", RetryPolicy{BaseDelay: 10 * time.Second, Jitter: 0.2}, 1, 0, 10 * time.Second},
+		{"jitter high", RetryPolicy{BaseDelay: 10 * time.Second, Jitter: 0.2}, 1, 0.999999, 8 * time.Second},
+		{"jitter capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second, Jitter: 0.5}, 5, 0.5, 3 * time.Second},
+		{"jitter clamped", RetryPolicy{BaseDelay: time.Second, Jitter: 3}, 1, 0.5, 500 * time.Millisecond},
+		{"negative jitter", RetryPolicy{BaseDelay: time.Second, Jitter: -1}, 1, 0.5, time.Second},
+	}
+	for _, tt := range tests {
+		got := tt.policy.backoff(tt.n, func() float64 { return tt.random })
+		if diff := got - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("%s: backoff(%d) = %v; expected %v", tt.name, tt.n, got, tt.want)
+		}
+	}
+
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 30 * time.Second, Jitter: 0.3}
+	for n := 1; n <= 8; n++ {
+		full := p.backoff(n, func() float64 { return 0 })
+		for i := 0; i < 100; i++ {
+			if got := p.backoff(n, rand.Float64); got > full || got < full-time.Duration(float64(full)*p.Jitter) {
+				t.Fatalf("backoff(%d) = %v; expected between 70%% and 100%% of %v", n, got, full)
+			}
+		}
+	}
+}
+
+// TestClassifyError verifies the class of each kind of error and which of
+// them IsRetryable and RetryOn retry.
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		class     string
+		retryable bool
+	}{
+		{"throttle", &StatusError{Op: "get", StatusCode: 429}, ClassThrottle, true},
+		{"server", &StatusError{Op: "get", StatusCode: 503}, ClassServer, true},
+		{"timeout status", &StatusError{Op: "get", StatusCode: 408}, ClassServer, true},
+		{"client", &StatusError{Op: "get", StatusCode: 400}, ClassClient, false},
+		{"wrapped client", fmt.Errorf("submit: %w", &StatusError{Op: "post", StatusCode: 404}), ClassClient, false},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), ClassNetwork, true},
+		{"eof", io.ErrUnexpectedEOF, ClassNetwork, true},
+		{"canceled", context.Canceled, ClassCanceled, false},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ClassCanceled, false},
+		{"permanent", Permanent(errors.New("bad file")), ClassPermanent, false},
+		{"permanent server", Permanent(&StatusError{Op: "get", StatusCode: 503}), ClassPermanent, false},
+		{"other", errors.New("boom"), ClassOther, true},
+	}
+	network := RetryOn(ClassNetwork, ClassPermanent, ClassCanceled)
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.class {
+			t.Errorf("%s: ClassifyError() = %q; expected %q", tt.name, got, tt.class)
+		}
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("%s: IsRetryable() = %v; expected %v", tt.name, got, tt.retryable)
+		}
+		if got, want := network(tt.err), tt.class == ClassNetwork; got != want {
+			t.Errorf("%s: RetryOn(network)() = %v; expected %v", tt.name, got, want)
+		}
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+	inner := errors.New("inner")
+	if !errors.Is(Permanent(inner), inner) {
+		t.Error("Permanent() does not wrap its error")
+	}
+}
+
+// TestRetry verifies the number of attempts for retried, permanent and
+// successful operations.
+func TestRetry(t *testing.T) {
+	server := &StatusError{Op: "get", StatusCode: 500}
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		wantErr  bool
+	}{
+		{"success", []error{nil}, 1, false},
+		{"recovers", []error{server, server, nil}, 3, false},
+		{"exhausted", []error{server, server, server, server}, 3, true},
+		{"permanent", []error{Permanent(errors.New("bad")), nil}, 1, true},
+		{"client", []error{&StatusError{Op: "get", StatusCode: 400}, nil}, 1, true},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := Retry(context.Background(), RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, func(ctx context.Context) error {
+			calls++
+			return tt.errs[calls-1]
+		})
+		if calls != tt.attempts || (err != nil) != tt.wantErr {
+			t.Errorf("%s: Retry() made %d attempts and returned %v; expected %d attempts", tt.name, calls, err, tt.attempts)
+		}
+	}
+}
+
+// TestCircuitBreaker walks a breaker from closed to open, through a failed
+// and a successful half-open probe, back to closed, and checks that client
+// errors neither open it nor close it.
+func TestCircuitBreaker(t *testing.T) {
+	b := &CircuitBreaker{Name: "spapi", Threshold: 2, Cooldown: 20 * time.Millisecond}
+	server := &StatusError{Op: "get", StatusCode: 503}
+	client := &StatusError{Op: "get", StatusCode: 400}
+	call := func(err error) error {
+		return Retry(context.Background(), RetryPolicy{Breaker: b}, func(ctx context.Context) error { return err })
+	}
+
+	steps := []struct {
+		name    string
+		wait    time.Duration
+		err     error
+		wantErr error
+		state   string
+	}{
+		{"client error", 0, client, client, "closed"},
+		{"client error", 0, client, client, "closed"},
+		{"first failure", 0, server, server, "closed"},
+		{"client error between failures", 0, client, client, "closed"},
+		{"second failure", 0, server, server, "open"},
+		{"rejected", 0, nil, ErrCircuitOpen, "open"},
+		{"failed probe", 30 * time.Millisecond, server, server, "open"},
+		{"rejected again", 0, nil, ErrCircuitOpen, "open"},
+		{"client error probe", 30 * time.Millisecond, client, client, "half-open"},
+		{"successful probe", 0, nil, nil, "closed"},
+		{"closed", 0, nil, nil, "closed"},
+	}
+	for _, s := range steps {
+		time.Sleep(s.wait)
+		if s.wait > 0 && b.State() != "half-open" {
+			t.Fatalf("%s: State() = %q before the probe; expected half-open", s.name, b.State())
+		}
+		err := call(s.err)
+		if s.wantErr == nil && err != nil || s.wantErr != nil && !errors.Is(err, s.wantErr) {
+			t.Errorf("%s: Retry() = %v; expected %v", s.name, err, s.wantErr)
+		}
+		if got := b.State(); got != s.state {
+			t.Errorf("%s: State() = %q; expected %q", s.name, got, s.state)
+		}
+	}
+
+	b = &CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond}
+	b.Record(true)
+	time.Sleep(5 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v; expected a probe", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second Allow() during a probe = %v; expected ErrCircuitOpen", err)
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow() after Release() = %v; expected another probe", err)
+	}
+}
@@ -0,0 +1,112 @@
+// pkg/utils/sftpprompt.go
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/totp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+/*
+SFTPPrompt answers the keyboard-interactive questions of an SFTP server that
+match Pattern.
+
+Fields:
+  - Pattern: Questions answered (nil matches every question).
+  - Answer:  Returns the answer to a question; echo tells whether the server wants it echoed.
+*/
+type SFTPPrompt struct {
+	Pattern *regexp.Regexp
+	Answer  func(question string, echo bool) (string, error)
+}
+
+/*
+SFTPPromptChallenge answers each question of a keyboard-interactive login with
+the first prompt whose Pattern matches it. A question no prompt matches fails
+the login. Questions are logged to logger at debug level of ModuleSFTP.
+*/
+func SFTPPromptChallenge(prompts []SFTPPrompt, logger *Logger) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, q := range questions {
+			logger.Debugf(ModuleSFTP, "Keyboard-interactive prompt: ", "%q", q)
+			p, ok := matchPrompt(prompts, q)
+			if !ok {
+				return nil, fmt.Errorf("no edi.keyboardInteractive answer for server prompt %q", strings.TrimSpace(q))
+			}
+			a, err := p.Answer(q, i < len(echos) && echos[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to answer server prompt %q: %w", strings.TrimSpace(q), err)
+			}
+			answers[i] = a
+		}
+		return answers, nil
+	}
+}
+
+// matchPrompt returns the first prompt handling question q.
+func matchPrompt(prompts []SFTPPrompt, q string) (SFTPPrompt, bool) {
+	for _, p := range prompts {
+		if p.Pattern == nil || p.Pattern.MatchString(q) {
+			return p, true
+		}
+	}
+	return SFTPPrompt{}, false
+}
+
+/*
+EnvAnswer answers with the value of the environment variable name, read at
+login time so a wrapper can export a fresh code before each run.
+*/
+func EnvAnswer(name string) func(string, bool) (string, error) {
+	return func(string, bool) (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	}
+}
+
+/*
+TOTPAnswer answers with the current time-based one-time code of a base32
+secret (RFC 6238, as enrolled in an authenticator app).
+*/
+func TOTPAnswer(secret string) (func(string, bool) (string, error), error) {
+	key, err := totp.DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return func(string, bool) (string, error) {
+		return totp.Code(key, time.Now()), nil
+	}, nil
+}
+
+/*
+TerminalAnswer asks the question on the terminal in and out (e.g. os.Stdin and
+os.Stderr), without echo unless the server requests it. It fails when in is
+not a terminal, e.g. in the daemon.
+*/
+func TerminalAnswer(in *os.File, out io.Writer) func(string, bool) (string, error) {
+	return func(q string, echo bool) (string, error) {
+		if !IsTerminal(in) {
+			return "", fmt.Errorf("%s is not a terminal", in.Name())
+		}
+		fmt.Fprint(out, q)
+		if echo {
+			line, err := bufio.NewReader(in).ReadString('\n')
+			return strings.TrimRight(line, "\r\n"), err
+		}
+		a, err := term.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(out)
+		return string(a), err
+	}
+}
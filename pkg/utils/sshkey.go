@@ -0,0 +1,52 @@
+// pkg/utils/sshkey.go
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+DecodeSSHKey returns the private key given inline in the config: either the
+PEM text itself ("-----BEGIN ...") or its base64 encoding, which survives
+environment variables and secret stores that do not keep line breaks.
+*/
+func DecodeSSHKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "-----BEGIN") {
+		return []byte(s + "\n"), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("expected a PEM key or its base64 encoding: %w", err)
+	}
+	return key, nil
+}
+
+/*
+ParseSSHKey parses an RSA, ECDSA or Ed25519 private key in OpenSSH, PKCS#1,
+PKCS#8 or SEC 1 format, decrypting it with passphrase if it is encrypted.
+
+Returns:
+  - The signer of the key.
+  - An error if the key is invalid, or encrypted and the passphrase is missing or wrong.
+*/
+func ParseSSHKey(key, passphrase []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("key is encrypted; set edi.privateKeyPassphrase")
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,32 @@
+// pkg/utils/stream.go
+package utils
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+)
+
+/*
+WriteStream runs write against a buffered (and optionally gzip-compressed)
+writer on top of dst and flushes everything to dst before returning. It backs
+sftpx.Upload and can equally target a local file.
+*/
+func WriteStream(dst io.Writer, gzipped bool, write func(w io.Writer) error) error {
+	buf := bufio.NewWriterSize(dst, 256*1024)
+	var w io.Writer = buf
+	var zw *gzip.Writer
+	if gzipped {
+		zw = gzip.NewWriter(buf)
+		w = zw
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestWriteStream verifies that plain and gzip streams arrive complete,
+// including writes larger than the buffer, and that a failing write is
+// returned.
+func TestWriteStream(t *testing.T) {
+	want := strings.Repeat("0123456789abcdef", 64*1024) // 1 MiB, four buffers
+	write := func(w io.Writer) error {
+		for i := 0; i < len(want); i += 1000 {
+			end := min(i+1000, len(want))
+			if _, err := io.WriteString(w, want[i:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var plain bytes.Buffer
+	if err := WriteStream(&plain, false, write); err != nil || plain.String() != want {
+		t.Errorf("WriteStream() plain = %d bytes, %v; expected %d bytes", plain.Len(), err, len(want))
+	}
+
+	var zipped bytes.Buffer
+	if err := WriteStream(&zipped, true, write); err != nil {
+		t.Fatalf("WriteStream() gzip returned %v", err)
+	}
+	if zipped.Len() >= len(want) {
+		t.Errorf("gzip output is %d bytes; expected it compressed", zipped.Len())
+	}
+	zr, err := gzip.NewReader(&zipped)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() returned %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil || string(got) != want {
+		t.Errorf("gzip round trip = %d bytes, %v; expected %d bytes", len(got), err, len(want))
+	}
+
+	boom := errors.New("boom")
+	if err := WriteStream(io.Discard, true, func(io.Writer) error { return boom }); !errors.Is(err, boom) {
+		t.Errorf("WriteStream() = %v; expected the write error", err)
+	}
+}
@@ -0,0 +1,18 @@
+// pkg/utils/terminal.go
+package utils
+
+import (
+	"os"
+)
+
+/*
+IsTerminal reports whether f is attached to an interactive terminal
+(a character device) rather than a file, pipe or socket.
+*/
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,177 @@
+// pkg/webhook/webhook.go
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSignatureHeader carries the signature of a callback unless configured otherwise.
+const DefaultSignatureHeader = "X-Signature-256"
+
+// Errors returned by Verifier.Verify.
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrBadSignature     = errors.New("signature does not match")
+	ErrBadTimestamp     = errors.New("missing or invalid timestamp")
+	ErrExpired          = errors.New("timestamp outside the tolerance")
+	ErrReplayed         = errors.New("signature already used")
+)
+
+/*
+Sign returns the signature of a callback body: "sha256=" followed by the
+hex-encoded HMAC-SHA256 of the body under secret. With a timestamp the signed
+payload is "<timestamp>.<body>", so a captured request cannot be replayed
+later with another timestamp.
+*/
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	if timestamp != "" {
+		mac.Write([]byte(timestamp + "."))
+	}
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Verifier checks the signatures of inbound callbacks.
+
+Fields:
+  - Secret:          Shared HMAC secret.
+  - SignatureHeader: Header carrying the signature (default: X-Signature-256);
+    "sha256=<hex>" or the bare hex digest.
+  - TimestampHeader: Header carrying the Unix time the sender signed at; empty
+    signs the body alone and accepts any age.
+  - Tolerance:       How far the timestamp may be from now (0 means any).
+*/
+type Verifier struct {
+	Secret          string
+	SignatureHeader string
+	TimestampHeader string
+	Tolerance       time.Duration
+}
+
+/*
+Signature returns the signature of a request in the form Sign produces, or
+"" if it has none.
+*/
+func (v Verifier) Signature(h http.Header) string {
+	name := v.SignatureHeader
+	if name == "" {
+		name = DefaultSignatureHeader
+	}
+	sig := strings.ToLower(strings.TrimSpace(h.Get(name)))
+	if sig != "" && !strings.HasPrefix(sig, "sha256=") {
+		sig = "sha256=" + sig
+	}
+	return sig
+}
+
+/*
+Verify checks the signature of a request whose body has been read.
+
+Parameters:
+  - h:    The request headers.
+  - body: The request body.
+  - now:  Current time, for the timestamp check.
+
+Returns:
+  - nil if the signature matches, otherwise one of the Err values.
+*/
+func (v Verifier) Verify(h http.Header, body []byte, now time.Time) error {
+	got := v.Signature(h)
+	if got == "" {
+		return ErrMissingSignature
+	}
+
+	var ts string
+	if v.TimestampHeader != "" {
+		ts = strings.TrimSpace(h.Get(v.TimestampHeader))
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return ErrBadTimestamp
+		}
+		if age := now.Sub(time.Unix(sec, 0)); v.Tolerance > 0 && (age > v.Tolerance || age < -v.Tolerance) {
+			return ErrExpired
+		}
+	}
+	if !hmac.Equal([]byte(got), []byte(Sign(v.Secret, ts, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+/*
+Replays remembers the signatures of accepted callbacks, so a captured request
+is not accepted a second time while its timestamp is still within the
+tolerance. Entries older than the window passed to Claim are forgotten. The
+zero value is ready to use; it only lives in memory, so use Restore to carry
+the signatures over a restart.
+*/
+type Replays struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+/*
+Claim records sig as used at now unless it already is.
+
+Parameters:
+  - sig:    The request's signature (see Verifier.Signature).
+  - now:    Current time.
+  - window: How long signatures are remembered; use twice the timestamp
+    tolerance, as timestamps may lie that far on either side of now.
+
+Returns:
+  - ErrReplayed if sig was claimed within window, otherwise nil.
+*/
+func (r *Replays) Claim(sig string, now time.Time, window time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for s, at := range r.seen {
+		if now.Sub(at) > window {
+			delete(r.seen, s)
+		}
+	}
+	if _, ok := r.seen[sig]; ok {
+		return ErrReplayed
+	}
+	if r.seen == nil {
+		r.seen = map[string]time.Time{}
+	}
+	r.seen[sig] = now
+	return nil
+}
+
+/*
+Restore records sig as claimed at at, e.g. the signature of a callback
+accepted before a restart, so Claim rejects it until it is older than the
+window.
+*/
+func (r *Replays) Restore(sig string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = map[string]time.Time{}
+	}
+	if prev, ok := r.seen[sig]; !ok || at.After(prev) {
+		r.seen[sig] = at
+	}
+}
+
+/*
+Release forgets sig again, for a request that was claimed but then not
+accepted, so the sender may retry it unchanged.
+*/
+func (r *Replays) Release(sig string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.seen, sig)
+}
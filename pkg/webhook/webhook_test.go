@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVerify verifies body-only and timestamped signatures, bare hex digests
+// and the rejection of tampered, stale and unsigned requests.
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`[{"shipmentId":"S1"}]`)
+	ts := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+
+	plain := Verifier{Secret: "s3cret"}
+	stamped := Verifier{Secret: "s3cret", SignatureHeader: "X-WMS-Signature", TimestampHeader: "X-WMS-Timestamp", Tolerance: 5 * time.Minute}
+	header := func(pairs ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+	for _, tt := range []struct {
+		name string
+		v    Verifier
+		h    http.Header
+		body []byte
+		want error
+	}{
+		{"body only", plain, header("X-Signature-256", Sign("s3cret", "", body)), body, nil},
+		{"bare hex", plain, header("X-Signature-256", strings.TrimPrefix(Sign("s3cret", "", body), "sha256=")), body, nil},
+		{"missing", plain, header(), body, ErrMissingSignature},
+		{"tampered", plain, header("X-Signature-256", Sign("s3cret", "", body)), []byte(`[{"shipmentId":"S2"}]`), ErrBadSignature},
+		{"wrong secret", plain, header("X-Signature-256", Sign("other", "", body)), body, ErrBadSignature},
+		{"timestamped", stamped, header("X-WMS-Signature", Sign("s3cret", ts, body), "X-WMS-Timestamp", ts), body, nil},
+		{"no timestamp", stamped, header("X-WMS-Signature", Sign("s3cret", "", body)), body, ErrBadTimestamp},
+		{"stale", stamped, header("X-WMS-Signature", Sign("s3cret", stale, body), "X-WMS-Timestamp", stale), body, ErrExpired},
+		{"replayed timestamp", stamped, header("X-WMS-Signature", Sign("s3cret", stale, body), "X-WMS-Timestamp", ts), body, ErrBadSignature},
+	} {
+		if err := tt.v.Verify(tt.h, tt.body, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() = %v; expected %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestReplays verifies that a signature is accepted once within the window,
+// again once released or after the window, and that old entries expire.
+func TestReplays(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	window := 10 * time.Minute
+	var r Replays
+	steps := []struct {
+		name    string
+		sig     string
+		at      time.Time
+		release bool
+		want    error
+	}{
+		{"first", "sha256=aa", now, false, nil},
+		{"replayed", "sha256=aa", now.Add(time.Minute), false, ErrReplayed},
+		{"other", "sha256=bb", now.Add(time.Minute), true, nil},
+		{"released", "sha256=bb", now.Add(2 * time.Minute), false, nil},
+		{"late replay", "sha256=aa", now.Add(window - time.Second), false, ErrReplayed},
+		{"expired", "sha256=aa", now.Add(window + time.Second), false, nil},
+	}
+	for _, s := range steps {
+		if err := r.Claim(s.sig, s.at, window); !errors.Is(err, s.want) {
+			t.Errorf("%s: Claim() = %v; expected %v", s.name, err, s.want)
+		}
+		if s.release {
+			r.Release(s.sig)
+		}
+	}
+	if n := len(r.seen); n != 2 {
+		t.Errorf("remembered %d signatures; expected 2", n)
+	}
+
+	// Signatures restored after a restart are rejected until they expire.
+	var restarted Replays
+	restarted.Restore("sha256=cc", now)
+	restarted.Restore("sha256=dd", now.Add(-window-time.Second))
+	if err := restarted.Claim("sha256=cc", now.Add(time.Minute), window); !errors.Is(err, ErrReplayed) {
+		t.Errorf("restored: Claim() = %v; expected %v", err, ErrReplayed)
+	}
+	if err := restarted.Claim("sha256=dd", now.Add(time.Minute), window); err != nil {
+		t.Errorf("restored and expired: Claim() = %v; expected nil", err)
+	}
+	upper := Verifier{}.Signature(http.Header{"X-Signature-256": {"AB12"}})
+	if upper != "sha256=ab12" {
+		t.Errorf("Signature() = %q; expected sha256=ab12", upper)
+	}
+}
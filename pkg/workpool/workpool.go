@@ -0,0 +1,125 @@
+// pkg/workpool/workpool.go
+package workpool
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// ErrStopped is returned by Submit once a task has failed.
+var ErrStopped = errors.New("work pool stopped after a failed task")
+
+// task is one queued unit of work.
+type task func(ctx context.Context) error
+
+/*
+Pool runs tasks on a fixed number of workers, each with a small queue, so a
+producer submitting faster than the workers keep up is held back instead of
+buffering without bound. Tasks submitted under the same key run on the same
+worker, one after another in submission order; tasks under different keys
+may run in parallel. Once a task fails the pool stops: later submissions are
+refused and tasks still queued are dropped, while tasks already running
+finish.
+*/
+type Pool struct {
+	ctx    context.Context
+	queues []chan task
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	errs   []error
+	closed bool
+}
+
+/*
+New starts a Pool.
+
+Parameters:
+  - ctx:     Passed to every task; once it is cancelled queued tasks are dropped.
+  - workers: Number of workers (values below 1 are treated as 1).
+*/
+func New(ctx context.Context, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{ctx: ctx, queues: make([]chan task, workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan task, 1)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// work runs the tasks of one queue until it is closed.
+func (p *Pool) work(queue chan task) {
+	defer p.wg.Done()
+	for fn := range queue {
+		if p.stopped() {
+			continue
+		}
+		if err := fn(p.ctx); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// stopped reports whether a task failed or the context ended.
+func (p *Pool) stopped() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.errs) > 0 || p.ctx.Err() != nil
+}
+
+/*
+Submit queues fn on the worker of key, blocking while that worker's queue is
+full.
+
+Returns:
+  - ErrStopped if a task has failed, or ctx.Err() once the context ends; fn is then not run.
+*/
+func (p *Pool) Submit(key string, fn func(ctx context.Context) error) error {
+	if p.stopped() {
+		if err := p.ctx.Err(); err != nil {
+			return err
+		}
+		return ErrStopped
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	select {
+	case p.queues[h.Sum32()%uint32(len(p.queues))] <- fn:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+/*
+Wait stops accepting tasks and waits for every queued task to finish. It must
+be called once, after the last Submit.
+
+Returns:
+  - The errors of every failed task joined, or ctx.Err() if the context ended
+    first, or nil.
+*/
+func (p *Pool) Wait() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.errs) == 0 {
+		return p.ctx.Err()
+	}
+	return errors.Join(p.errs...)
+}
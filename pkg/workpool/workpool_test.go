@@ -0,0 +1,75 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPoolKeysInOrder verifies that tasks run in parallel across keys, never
+// beyond the worker count, and in submission order within a key.
+func TestPoolKeysInOrder(t *testing.T) {
+	p := New(context.Background(), 4)
+	var running, peak int32
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	for i := 0; i < 40; i++ {
+		key, i := fmt.Sprintf("PO%d", i%5), i
+		err := p.Submit(key, func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			mu.Lock()
+			seen[key] = append(seen[key], i)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if peak > 4 {
+		t.Errorf("%d tasks ran at once; expected at most 4", peak)
+	}
+	for key, order := range seen {
+		for j := 1; j < len(order); j++ {
+			if order[j] < order[j-1] {
+				t.Errorf("%s ran out of order: %v", key, order)
+				break
+			}
+		}
+	}
+}
+
+// TestPoolStopsAfterFailure verifies that a failed task is returned by Wait
+// and that later submissions are refused.
+func TestPoolStopsAfterFailure(t *testing.T) {
+	p := New(context.Background(), 1)
+	boom := errors.New("boom")
+	if err := p.Submit("a", func(ctx context.Context) error { return boom }); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for err := p.Submit("a", func(ctx context.Context) error { return nil }); !errors.Is(err, ErrStopped); err = p.Submit("a", func(ctx context.Context) error { return nil }) {
+		if time.Now().After(deadline) {
+			t.Fatal("Submit() kept accepting tasks after a failure")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := p.Wait(); !errors.Is(err, boom) {
+		t.Errorf("Wait() = %v; expected %v", err, boom)
+	}
+}
@@ -0,0 +1,145 @@
+// pkg/x12/dictionary.go
+package x12
+
+/*
+SegmentInfo describes a segment for display purposes.
+
+Fields:
+  - Name: Segment name as given in the X12 standard.
+  - Elements: Element names indexed by position (Elements[0] is element 01).
+*/
+type SegmentInfo struct {
+	Name     string
+	Elements []string
+}
+
+// transactionSets names the transaction sets the importer exchanges with Amazon.
+var transactionSets = map[string]string{
+	"810": "Invoice",
+	"846": "Inventory Inquiry/Advice",
+	"850": "Purchase Order",
+	"855": "Purchase Order Acknowledgment",
+	"856": "Ship Notice/Manifest",
+	"864": "Text Message",
+	"997": "Functional Acknowledgment",
+}
+
+// segments covers the envelope and the segments used by 850/855/856/810/997.
+var segments = map[string]SegmentInfo{
+	"ISA": {"Interchange Control Header", []string{
+		"Authorization Qualifier", "Authorization Information", "Security Qualifier", "Security Information",
+		"Sender ID Qualifier", "Sender ID", "Receiver ID Qualifier", "Receiver ID",
+		"Interchange Date", "Interchange Time", "Repetition Separator", "Version",
+		"Control Number", "Acknowledgment Requested", "Usage Indicator", "Component Separator"}},
+	"IEA": {"Interchange Control Trailer", []string{"Number of Groups", "Control Number"}},
+	"GS": {"Functional Group Header", []string{
+		"Functional Identifier", "Sender Code", "Receiver Code", "Date", "Time",
+		"Control Number", "Agency Code", "Version"}},
+	"GE": {"Functional Group Trailer", []string{"Number of Transaction Sets", "Control Number"}},
+	"ST": {"Transaction Set Header", []string{"Transaction Set ID", "Control Number"}},
+	"SE": {"Transaction Set Trailer", []string{"Number of Segments", "Control Number"}},
+
+	"BEG": {"Beginning Segment for Purchase Order", []string{
+		"Purpose Code", "PO Type Code", "PO Number", "Release Number", "PO Date"}},
+	"BAK": {"Beginning Segment for PO Acknowledgment", []string{
+		"Purpose Code", "Acknowledgment Type", "PO Number", "PO Date", "Release Number",
+		"Request Reference", "Contract Number", "Reference ID", "Acknowledgment Date"}},
+	"BSN": {"Beginning Segment for Ship Notice", []string{
+		"Purpose Code", "Shipment ID", "Date", "Time", "Hierarchical Structure Code"}},
+	"BIG": {"Beginning Segment for Invoice", []string{
+		"Invoice Date", "Invoice Number", "PO Date", "PO Number"}},
+
+	"CUR": {"Currency", []string{"Entity ID Code", "Currency Code"}},
+	"REF": {"Reference Identification", []string{"Qualifier", "Reference ID", "Description"}},
+	"DTM": {"Date/Time Reference", []string{"Qualifier", "Date", "Time"}},
+	"FOB": {"F.O.B. Related Instructions", []string{"Shipment Method of Payment", "Location Qualifier", "Description"}},
+	"ITD": {"Terms of Sale", []string{
+		"Terms Type", "Terms Basis Date", "Discount Percent", "Discount Due Date",
+		"Discount Days Due", "Net Due Date", "Net Days"}},
+	"SAC": {"Service, Promotion, Allowance, or Charge", []string{
+		"Indicator", "Code", "Agency Qualifier", "Agency Code", "Amount"}},
+	"TXI": {"Tax Information", []string{"Tax Type", "Amount", "Percent"}},
+	"PER": {"Administrative Communications Contact", []string{
+		"Contact Function", "Name", "Communication Qualifier", "Communication Number"}},
+	"N9":  {"Reference Identification", []string{"Qualifier", "Reference ID", "Description"}},
+	"MSG": {"Message Text", []string{"Free-Form Message"}},
+	"N1":  {"Name", []string{"Entity ID Code", "Name", "ID Code Qualifier", "ID Code"}},
+	"N2":  {"Additional Name", []string{"Name", "Name"}},
+	"N3":  {"Address", []string{"Address", "Address"}},
+	"N4":  {"Geographic Location", []string{"City", "State/Province", "Postal Code", "Country Code"}},
+
+	"PO1": {"Baseline Item Data", []string{
+		"Line Number", "Quantity Ordered", "Unit of Measure", "Unit Price", "Price Basis",
+		"Product ID Qualifier", "Product ID", "Product ID Qualifier", "Product ID"}},
+	"ACK": {"Line Item Acknowledgment", []string{
+		"Line Item Status", "Quantity", "Unit of Measure", "Date Qualifier", "Date",
+		"Request Reference", "Product ID Qualifier", "Product ID"}},
+	"PID": {"Product/Item Description", []string{"Description Type", "Characteristic Code", "Agency", "Product Code", "Description"}},
+	"CTP": {"Pricing Information", []string{"Class of Trade", "Price Qualifier", "Unit Price"}},
+	"CTT": {"Transaction Totals", []string{"Number of Line Items", "Hash Total"}},
+	"AMT": {"Monetary Amount", []string{"Qualifier", "Amount"}},
+
+	"HL": {"Hierarchical Level", []string{"Hierarchical ID", "Parent ID", "Level Code", "Child Code"}},
+	"TD1": {"Carrier Details (Quantity and Weight)", []string{
+		"Packaging Code", "Lading Quantity", "Commodity Code Qualifier", "Commodity Code",
+		"Lading Description", "Weight Qualifier", "Weight", "Unit of Measure"}},
+	"TD3": {"Carrier Details (Equipment)", []string{"Equipment Code", "Equipment Initial", "Equipment Number"}},
+	"TD5": {"Carrier Details (Routing Sequence)", []string{
+		"Routing Sequence Code", "ID Code Qualifier", "ID Code", "Transportation Method", "Routing"}},
+	"MAN": {"Marks and Numbers", []string{"Qualifier", "Marks and Numbers"}},
+	"PRF": {"Purchase Order Reference", []string{"PO Number", "Release Number", "Change Order Sequence", "PO Date"}},
+	"LIN": {"Item Identification", []string{
+		"Line Number", "Product ID Qualifier", "Product ID", "Product ID Qualifier", "Product ID"}},
+	"SN1": {"Item Detail (Shipment)", []string{
+		"Line Number", "Units Shipped", "Unit of Measure", "Quantity Shipped to Date",
+		"Quantity Ordered", "Unit of Measure"}},
+	"SLN": {"Subline Item Detail", []string{"Line Number", "Parent Line Number", "Relationship Code", "Quantity", "Unit of Measure"}},
+	"PKG": {"Marking, Packaging, Loading", []string{"Description Type", "Characteristic Code", "Agency", "Packaging Code", "Description"}},
+
+	"IT1": {"Baseline Item Data (Invoice)", []string{
+		"Line Number", "Quantity Invoiced", "Unit of Measure", "Unit Price", "Price Basis",
+		"Product ID Qualifier", "Product ID", "Product ID Qualifier", "Product ID"}},
+	"TDS": {"Total Monetary Value Summary", []string{"Total Invoice Amount", "Amount Subject to Discount"}},
+	"CAD": {"Carrier Detail", []string{
+		"Transportation Method", "Equipment Initial", "Equipment Number", "Carrier SCAC", "Routing"}},
+	"ISS": {"Invoice Shipment Summary", []string{"Units Shipped", "Unit of Measure", "Weight", "Unit of Measure"}},
+
+	"AK1": {"Functional Group Response Header", []string{"Functional Identifier", "Group Control Number", "Version"}},
+	"AK2": {"Transaction Set Response Header", []string{"Transaction Set ID", "Control Number", "Version"}},
+	"AK3": {"Data Segment Note", []string{"Segment ID", "Segment Position", "Loop ID", "Error Code"}},
+	"AK4": {"Data Element Note", []string{"Element Position", "Reference Number", "Error Code", "Bad Data"}},
+	"AK5": {"Transaction Set Response Trailer", []string{"Acknowledgment Code", "Error Code"}},
+	"AK9": {"Functional Group Response Trailer", []string{
+		"Acknowledgment Code", "Sets Included", "Sets Received", "Sets Accepted", "Error Code"}},
+}
+
+/*
+LookupSegment returns the dictionary entry for a segment ID.
+
+Returns:
+  - The segment description and true if the segment is known.
+*/
+func LookupSegment(id string) (SegmentInfo, bool) {
+	info, ok := segments[id]
+	return info, ok
+}
+
+/*
+ElementName returns the name of element pos (1-based) of segment id, or ""
+when unknown.
+*/
+func ElementName(id string, pos int) string {
+	info, ok := segments[id]
+	if !ok || pos < 1 || pos > len(info.Elements) {
+		return ""
+	}
+	return info.Elements[pos-1]
+}
+
+/*
+TransactionSetName returns the name of an X12 transaction set ID such as
+"850", or "" when unknown.
+*/
+func TransactionSetName(id string) string {
+	return transactionSets[id]
+}
@@ -0,0 +1,130 @@
+// pkg/x12/envelope.go
+package x12
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Issue is a structural problem found in a document.
+
+Fields:
+  - Segment: Index of the segment the issue refers to.
+  - Message: Human-readable description.
+*/
+type Issue struct {
+	Segment int    `json:"segment"`
+	Message string `json:"message"`
+}
+
+/*
+ValidateEnvelopes checks that every ISA/IEA, GS/GE and ST/SE pair is present
+and that their control numbers and counts agree:
+  - IEA01 equals the number of functional groups and IEA02 equals ISA13.
+  - GE01 equals the number of transaction sets and GE02 equals GS06.
+  - SE01 equals the number of segments from ST to SE and SE02 equals ST02.
+
+Returns:
+  - Every mismatch found, in document order (empty when the envelopes are sound).
+*/
+func (d *Document) ValidateEnvelopes() []Issue {
+	var issues []Issue
+	add := func(seg int, format string, args ...interface{}) {
+		issues = append(issues, Issue{Segment: seg, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var isa, gs, st *Segment
+	groups, sets := 0, 0
+	for i := range d.Segments {
+		seg := &d.Segments[i]
+		switch seg.ID {
+		case "ISA":
+			if isa != nil {
+				add(seg.Index, "ISA without closing IEA for interchange %s", isa.Element(13))
+			}
+			isa, groups = seg, 0
+		case "GS":
+			if isa == nil {
+				add(seg.Index, "GS outside of an interchange")
+			}
+			if gs != nil {
+				add(seg.Index, "GS without closing GE for group %s", gs.Element(6))
+			}
+			gs, sets = seg, 0
+			groups++
+		case "ST":
+			if gs == nil {
+				add(seg.Index, "ST outside of a functional group")
+			}
+			if st != nil {
+				add(seg.Index, "ST without closing SE for transaction set %s", st.Element(2))
+			}
+			st = seg
+			sets++
+		case "SE":
+			if st == nil {
+				add(seg.Index, "SE without matching ST")
+				continue
+			}
+			if got, want := seg.Element(2), st.Element(2); got != want {
+				add(seg.Index, "SE02 control number %s does not match ST02 %s", got, want)
+			}
+			if want := seg.Index - st.Index + 1; !countMatches(seg.Element(1), want) {
+				add(seg.Index, "SE01 segment count %s does not match actual count %d", seg.Element(1), want)
+			}
+			st = nil
+		case "GE":
+			if gs == nil {
+				add(seg.Index, "GE without matching GS")
+				continue
+			}
+			if st != nil {
+				add(seg.Index, "GE before SE closed transaction set %s", st.Element(2))
+				st = nil
+			}
+			if got, want := seg.Element(2), gs.Element(6); got != want {
+				add(seg.Index, "GE02 control number %s does not match GS06 %s", got, want)
+			}
+			if !countMatches(seg.Element(1), sets) {
+				add(seg.Index, "GE01 transaction set count %s does not match actual count %d", seg.Element(1), sets)
+			}
+			gs = nil
+		case "IEA":
+			if isa == nil {
+				add(seg.Index, "IEA without matching ISA")
+				continue
+			}
+			if gs != nil {
+				add(seg.Index, "IEA before GE closed group %s", gs.Element(6))
+				gs = nil
+			}
+			if got, want := seg.Element(2), isa.Element(13); got != want {
+				add(seg.Index, "IEA02 control number %s does not match ISA13 %s", got, want)
+			}
+			if !countMatches(seg.Element(1), groups) {
+				add(seg.Index, "IEA01 group count %s does not match actual count %d", seg.Element(1), groups)
+			}
+			isa = nil
+		}
+	}
+
+	last := len(d.Segments) - 1
+	if st != nil {
+		add(last, "transaction set %s is missing its SE trailer", st.Element(2))
+	}
+	if gs != nil {
+		add(last, "functional group %s is missing its GE trailer", gs.Element(6))
+	}
+	if isa != nil {
+		add(last, "interchange %s is missing its IEA trailer", isa.Element(13))
+	}
+	return issues
+}
+
+// countMatches compares a numeric count element with the expected count.
+func countMatches(value string, want int) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	return err == nil && n == want
+}
@@ -0,0 +1,211 @@
+// pkg/x12/groups.go
+package x12
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+Transaction set syntax error codes (X12 element 718) reported in AK5.
+*/
+const (
+	SetNotSupported       = "1"
+	SetTrailerMissing     = "2"
+	SetControlMismatch    = "3"
+	SetSegmentCountWrong  = "4"
+	SetSegmentsInError    = "5"
+	SetControlNumberEmpty = "7"
+)
+
+/*
+Functional group syntax error codes (X12 element 716) reported in AK9.
+*/
+const (
+	GroupNotSupported        = "1"
+	GroupVersionNotSupported = "2"
+	GroupTrailerMissing      = "3"
+	GroupControlMismatch     = "4"
+	GroupSetCountWrong       = "5"
+)
+
+/*
+TransactionSet is one ST/SE envelope within a functional group.
+
+Fields:
+  - Header:   The ST segment.
+  - Trailer:  The SE segment, or nil if the set is not closed.
+  - Segments: All segments of the set, from ST to SE inclusive.
+*/
+type TransactionSet struct {
+	Header   Segment
+	Trailer  *Segment
+	Segments []Segment
+}
+
+/*
+ID returns the transaction set identifier (ST01), e.g. "850".
+*/
+func (t TransactionSet) ID() string { return t.Header.Element(1) }
+
+/*
+ControlNumber returns the transaction set control number (ST02).
+*/
+func (t TransactionSet) ControlNumber() string { return t.Header.Element(2) }
+
+/*
+Errors checks the ST/SE envelope of the set.
+
+Returns:
+  - AK5 error codes (SetTrailerMissing, SetControlMismatch, ...), empty when sound.
+*/
+func (t TransactionSet) Errors() []string {
+	var codes []string
+	if t.ControlNumber() == "" {
+		codes = append(codes, SetControlNumberEmpty)
+	}
+	if t.Trailer == nil {
+		return append(codes, SetTrailerMissing)
+	}
+	if t.Trailer.Element(2) != t.ControlNumber() {
+		codes = append(codes, SetControlMismatch)
+	}
+	if !countMatches(t.Trailer.Element(1), len(t.Segments)) {
+		codes = append(codes, SetSegmentCountWrong)
+	}
+	return codes
+}
+
+/*
+Group is one GS/GE functional group.
+
+Fields:
+  - Header:  The GS segment.
+  - Trailer: The GE segment, or nil if the group is not closed.
+  - Sets:    The transaction sets of the group in document order.
+*/
+type Group struct {
+	Header  Segment
+	Trailer *Segment
+	Sets    []TransactionSet
+}
+
+/*
+ControlNumber returns the group control number (GS06).
+*/
+func (g Group) ControlNumber() string { return g.Header.Element(6) }
+
+/*
+Errors checks the GS/GE envelope of the group.
+
+Returns:
+  - AK9 error codes (GroupTrailerMissing, GroupControlMismatch, ...), empty when sound.
+*/
+func (g Group) Errors() []string {
+	if g.Trailer == nil {
+		return []string{GroupTrailerMissing}
+	}
+	var codes []string
+	if g.Trailer.Element(2) != g.ControlNumber() {
+		codes = append(codes, GroupControlMismatch)
+	}
+	if !countMatches(g.Trailer.Element(1), len(g.Sets)) {
+		codes = append(codes, GroupSetCountWrong)
+	}
+	return codes
+}
+
+/*
+DeclaredSets returns the number of transaction sets the group claims to hold
+(GE01), falling back to the actual count when the trailer is missing or invalid.
+*/
+func (g Group) DeclaredSets() int {
+	if g.Trailer != nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(g.Trailer.Element(1))); err == nil {
+			return n
+		}
+	}
+	return len(g.Sets)
+}
+
+/*
+Groups splits the document into functional groups and transaction sets.
+Segments outside any group (ISA, IEA) are not included. Unclosed sets and
+groups are returned with a nil Trailer.
+*/
+func (d *Document) Groups() []Group {
+	var groups []Group
+	var group *Group
+	var set *TransactionSet
+	closeSet := func() {
+		if set != nil && group != nil {
+			group.Sets = append(group.Sets, *set)
+		}
+		set = nil
+	}
+	closeGroup := func() {
+		closeSet()
+		if group != nil {
+			groups = append(groups, *group)
+		}
+		group = nil
+	}
+
+	for i := range d.Segments {
+		seg := d.Segments[i]
+		switch seg.ID {
+		case "GS":
+			closeGroup()
+			group = &Group{Header: seg}
+		case "GE":
+			closeSet()
+			if group != nil {
+				group.Trailer = &d.Segments[i]
+			}
+			closeGroup()
+		case "IEA":
+			closeGroup()
+		case "ST":
+			closeSet()
+			set = &TransactionSet{Header: seg, Segments: []Segment{seg}}
+		default:
+			if set == nil {
+				continue
+			}
+			set.Segments = append(set.Segments, seg)
+			if seg.ID == "SE" {
+				set.Trailer = &d.Segments[i]
+				closeSet()
+			}
+		}
+	}
+	closeGroup()
+	return groups
+}
+
+/*
+Split returns one standalone interchange per transaction set. Each keeps the
+original ISA and GS headers and gets GE and IEA trailers counting exactly one
+set, so it can be stored, replayed or acknowledged on its own. Sets are
+returned in document order.
+*/
+func (d *Document) Split() []*Document {
+	isa, _ := d.Find("ISA")
+	var out []*Document
+	for _, g := range d.Groups() {
+		for _, set := range g.Sets {
+			segs := make([]Segment, 0, len(set.Segments)+4)
+			segs = append(segs, isa, g.Header)
+			segs = append(segs, set.Segments...)
+			segs = append(segs,
+				Segment{ID: "GE", Elements: []string{"1", g.ControlNumber()}},
+				Segment{ID: "IEA", Elements: []string{"1", isa.Element(13)}},
+			)
+			for i := range segs {
+				segs[i].Index = i
+			}
+			out = append(out, &Document{Delimiters: d.Delimiters, LineBreak: d.LineBreak, Segments: segs})
+		}
+	}
+	return out
+}
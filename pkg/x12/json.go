@@ -0,0 +1,146 @@
+// pkg/x12/json.go
+package x12
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+jsonDocument is the canonical JSON form of a Document. Element values are kept
+verbatim (including ISA padding and composite separators) so that converting
+to JSON and back reproduces the original bytes.
+*/
+type jsonDocument struct {
+	Delimiters jsonDelimiters `json:"delimiters"`
+	LineBreak  string         `json:"lineBreak"`
+	Segments   []jsonSegment  `json:"segments"`
+}
+
+type jsonDelimiters struct {
+	Element    string `json:"element"`
+	Repetition string `json:"repetition"`
+	Component  string `json:"component"`
+	Segment    string `json:"segment"`
+}
+
+type jsonSegment struct {
+	ID       string   `json:"id"`
+	Elements []string `json:"elements"`
+}
+
+/*
+ToJSON converts a document into its canonical, indented JSON form.
+*/
+func ToJSON(doc *Document) ([]byte, error) {
+	out := jsonDocument{
+		Delimiters: jsonDelimiters{
+			Element:    delimiterString(doc.Delimiters.Element),
+			Repetition: delimiterString(doc.Delimiters.Repetition),
+			Component:  delimiterString(doc.Delimiters.Component),
+			Segment:    delimiterString(doc.Delimiters.Segment),
+		},
+		LineBreak: doc.LineBreak,
+		Segments:  make([]jsonSegment, 0, len(doc.Segments)),
+	}
+	for _, seg := range doc.Segments {
+		elements := seg.Elements
+		if elements == nil {
+			elements = []string{}
+		}
+		out.Segments = append(out.Segments, jsonSegment{ID: seg.ID, Elements: elements})
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+/*
+FromJSON reads a document in the canonical JSON form produced by ToJSON.
+
+Returns:
+  - The document.
+  - An error if the JSON is malformed, a delimiter is not a single character
+    or a value contains one of the delimiters.
+*/
+func FromJSON(data []byte) (*Document, error) {
+	var in jsonDocument
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		return nil, fmt.Errorf("failed to decode EDI JSON: %w", err)
+	}
+
+	var doc Document
+	fields := []struct {
+		name     string
+		value    string
+		dst      *byte
+		optional bool
+	}{
+		{"element", in.Delimiters.Element, &doc.Delimiters.Element, false},
+		{"repetition", in.Delimiters.Repetition, &doc.Delimiters.Repetition, true},
+		{"component", in.Delimiters.Component, &doc.Delimiters.Component, true},
+		{"segment", in.Delimiters.Segment, &doc.Delimiters.Segment, false},
+	}
+	for _, f := range fields {
+		if f.value == "" && f.optional {
+			continue
+		}
+		if len(f.value) != 1 {
+			return nil, fmt.Errorf("%s delimiter must be a single character, got %q", f.name, f.value)
+		}
+		*f.dst = f.value[0]
+	}
+	if strings.Trim(in.LineBreak, "\r\n") != "" {
+		return nil, fmt.Errorf("lineBreak may only contain \\r and \\n, got %q", in.LineBreak)
+	}
+	doc.LineBreak = in.LineBreak
+
+	forbidden := string([]byte{doc.Delimiters.Element, doc.Delimiters.Segment})
+	for i, seg := range in.Segments {
+		if seg.ID == "" {
+			return nil, fmt.Errorf("segment %d has no id", i+1)
+		}
+		for _, v := range append([]string{seg.ID}, seg.Elements...) {
+			if strings.ContainsAny(v, forbidden) {
+				return nil, fmt.Errorf("segment %d (%s) contains a delimiter in value %q", i+1, seg.ID, v)
+			}
+		}
+		doc.Segments = append(doc.Segments, Segment{Index: i, ID: seg.ID, Elements: seg.Elements})
+	}
+	return &doc, nil
+}
+
+/*
+Bytes serializes the document using its delimiters, writing LineBreak after
+every segment terminator.
+*/
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	for _, seg := range d.Segments {
+		b.WriteString(seg.ID)
+		for _, el := range seg.Elements {
+			b.WriteByte(d.Delimiters.Element)
+			b.WriteString(el)
+		}
+		b.WriteByte(d.Delimiters.Segment)
+		b.WriteString(d.LineBreak)
+	}
+	return b.Bytes()
+}
+
+// delimiterString renders an optional delimiter, using "" when unset.
+func delimiterString(c byte) string {
+	if c == 0 {
+		return ""
+	}
+	return string(c)
+}
@@ -0,0 +1,181 @@
+// pkg/x12/options.go
+package x12
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Options control how outbound interchanges are written.
+
+Fields:
+  - Delimiters: Separators to write. Repetition is only written for 00402 and later.
+  - Version:    Interchange control version (ISA12), e.g. "00400", "00401" or "00501".
+  - Location:   Time zone for ISA and GS dates and times; nil means UTC.
+  - Decimal:    Decimal separator of amounts written by templates ('.' or ','); 0 means '.'.
+  - Usage:      ISA15 of interchanges whose header sets none, "P" or "T"; empty means "T".
+*/
+type Options struct {
+	Delimiters Delimiters
+	Version    string
+	Location   *time.Location
+	Decimal    byte
+	Usage      string
+}
+
+/*
+DefaultOptions write Amazon's defaults: '*', '^', '>', '~', version 00400 and UTC,
+marking interchanges as test data.
+*/
+var DefaultOptions = Options{Delimiters: DefaultDelimiters, Version: "00400"}
+
+/*
+Validate checks that the version is a five-digit ISA12 value, that the
+delimiters are set, distinct and not alphanumeric, and that the usage is "P"
+or "T" if set.
+*/
+func (o Options) Validate() error {
+	if len(o.Version) != 5 || strings.Trim(o.Version, "0123456789") != "" {
+		return fmt.Errorf("invalid X12 version %q: expected five digits such as 00401", o.Version)
+	}
+	if o.Usage != "" && o.Usage != "P" && o.Usage != "T" {
+		return fmt.Errorf("invalid usage indicator %q: expected \"P\" or \"T\"", o.Usage)
+	}
+	d := o.Delimiters
+	seen := map[byte]string{}
+	for _, f := range []struct {
+		name string
+		c    byte
+	}{{"element", d.Element}, {"component", d.Component}, {"segment", d.Segment}, {"repetition", d.Repetition}} {
+		if f.c == 0 {
+			if f.name == "repetition" && !o.UsesRepetition() {
+				continue
+			}
+			return fmt.Errorf("%s separator is not set", f.name)
+		}
+		if isAlnum(f.c) || f.c == ' ' {
+			return fmt.Errorf("%s separator %q must not be a letter, digit or space", f.name, f.c)
+		}
+		if other, ok := seen[f.c]; ok {
+			return fmt.Errorf("%s and %s separators are both %q", other, f.name, f.c)
+		}
+		seen[f.c] = f.name
+	}
+	switch o.Decimal {
+	case 0, '.', ',':
+	default:
+		return fmt.Errorf("decimal separator %q must be '.' or ','", o.Decimal)
+	}
+	if name, ok := seen[o.Decimal]; ok {
+		return fmt.Errorf("decimal separator %q is also the %s separator", o.Decimal, name)
+	}
+	return nil
+}
+
+/*
+UsesRepetition reports whether ISA11 carries a repetition separator, which is
+the case from version 00402 on. Earlier versions write 'U' instead.
+*/
+func (o Options) UsesRepetition() bool {
+	return o.Version >= "00402"
+}
+
+/*
+GroupVersion returns the GS08 version matching the interchange version, e.g.
+"004010" for 00400/00401 and "005010" for 00501.
+*/
+func (o Options) GroupVersion() string {
+	switch o.Version {
+	case "00400", "00401":
+		return "004010"
+	default:
+		return o.Version + "0"
+	}
+}
+
+/*
+In converts t to the configured time zone.
+*/
+func (o Options) In(t time.Time) time.Time {
+	if o.Location == nil {
+		return t.UTC()
+	}
+	return t.In(o.Location)
+}
+
+/*
+ISAHeader holds the variable parts of an ISA segment.
+
+Fields:
+  - SenderQualifier / SenderID:     ISA05/ISA06.
+  - ReceiverQualifier / ReceiverID: ISA07/ISA08.
+  - Time:                           Interchange date and time (ISA09/ISA10), written in o.Location.
+  - ControlNumber:                  ISA13, zero-padded to nine digits.
+  - Usage:                          ISA15, "P" (production) or "T" (test); empty uses o.Usage.
+*/
+type ISAHeader struct {
+	SenderQualifier   string
+	SenderID          string
+	ReceiverQualifier string
+	ReceiverID        string
+	Time              time.Time
+	ControlNumber     string
+	Usage             string
+}
+
+/*
+ISA builds a fixed-width ISA segment from h. Identifiers are padded to their
+fixed lengths so receivers can locate the delimiters by position. Without a
+usage in h or o the interchange is marked as test data, never as production.
+*/
+func (o Options) ISA(h ISAHeader) Segment {
+	repetition := "U"
+	if o.UsesRepetition() {
+		repetition = string(o.Delimiters.Repetition)
+	}
+	usage := h.Usage
+	if usage == "" {
+		usage = o.Usage
+	}
+	if usage == "" {
+		usage = "T"
+	}
+	at := o.In(h.Time)
+	return Segment{ID: "ISA", Elements: []string{
+		"00", pad("", 10), "00", pad("", 10),
+		pad(h.SenderQualifier, 2), pad(h.SenderID, 15),
+		pad(h.ReceiverQualifier, 2), pad(h.ReceiverID, 15),
+		at.Format("060102"), at.Format("1504"),
+		repetition, o.Version,
+		fmt.Sprintf("%09s", strings.TrimSpace(h.ControlNumber)),
+		"0", usage, string(o.Delimiters.Component),
+	}}
+}
+
+/*
+Write serializes segments with the configured delimiters, one segment per
+line, numbering them in order.
+*/
+func (o Options) Write(segments []Segment) []byte {
+	if len(segments) == 0 {
+		return nil
+	}
+	doc := Document{Delimiters: o.Delimiters, LineBreak: "\n"}
+	for i, seg := range segments {
+		seg.Index = i
+		doc.Segments = append(doc.Segments, seg)
+	}
+	out := doc.Bytes()
+	return out[:len(out)-len(doc.LineBreak)]
+}
+
+// pad left-aligns s in a field of width n, truncating longer values.
+func pad(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
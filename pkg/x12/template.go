@@ -0,0 +1,202 @@
+// pkg/x12/template.go
+package x12
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/heinrichb/avcimporter/pkg/money"
+)
+
+/*
+Envelope holds the header values Template.Build wraps a transaction set in.
+
+Fields:
+  - ISA:          Interchange header; its ControlNumber is also used for IEA02.
+  - FunctionalID: GS01, e.g. "PR" for an 855 or "SH" for an 856.
+  - SenderCode:   GS02 application sender's code.
+  - ReceiverCode: GS03 application receiver's code.
+  - GroupControl: GS06/GE02.
+  - SetID:        ST01, e.g. "855".
+  - SetControl:   ST02/SE02 (default: "0001").
+*/
+type Envelope struct {
+	ISA          ISAHeader
+	FunctionalID string
+	SenderCode   string
+	ReceiverCode string
+	GroupControl string
+	SetID        string
+	SetControl   string
+}
+
+/*
+Template builds a transaction set from a text/template. Each non-blank output
+line is one segment written in a fixed notation: elements separated by '*' and
+components by '>'. Build rewrites the segments with the outbound delimiters and
+adds the ISA/GS/ST header and SE/GE/IEA trailer, so templates contain only the
+body (BAK, REF, N1 loops, ...). Lines starting with '#' are comments, and
+empty trailing elements are dropped.
+
+Besides the standard text/template functions, templates can use:
+  - el:           Removes notation characters from a value, e.g. {{el .Order.PurchaseOrderNumber}}.
+  - upper:        Upper-cases a value.
+  - date:         Formats a time in the outbound time zone, e.g. {{date "20060102" .Order.OrderDetails.PurchaseOrderDate}}.
+  - amount:       Writes an amount with the partner's decimal separator, optionally with fixed decimals, e.g. {{amount $item.NetCost.Amount 2}}.
+  - now:          Current time in the outbound time zone.
+  - add:          Adds integers, e.g. {{add $i 2}} to number HL loops after a header level.
+  - isaControl:   Interchange control number (ISA13).
+  - groupControl: Group control number (GS06).
+  - setControl:   Transaction set control number (ST02).
+  - setID:        Transaction set identifier (ST01).
+*/
+type Template struct {
+	name string
+	text string
+}
+
+/*
+ParseTemplate checks the template text and returns a Template.
+
+Returns:
+  - The template, or an error if text is not a valid text/template.
+*/
+func ParseTemplate(name, text string) (*Template, error) {
+	t := &Template{name: name, text: text}
+	if _, err := t.parse(Options{}, Envelope{}, time.Time{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parse compiles the template with functions bound to one build.
+func (t *Template) parse(opts Options, env Envelope, now time.Time) (*template.Template, error) {
+	clean := strings.NewReplacer("*", "", ">", "", "~", "", "\n", " ", "\r", "")
+	funcs := template.FuncMap{
+		"el":           func(v interface{}) string { return clean.Replace(fmt.Sprint(v)) },
+		"upper":        func(v interface{}) string { return strings.ToUpper(fmt.Sprint(v)) },
+		"date":         func(layout string, t time.Time) string { return opts.In(t).Format(layout) },
+		"amount":       func(v interface{}, places ...int) (string, error) { return formatAmount(v, places, opts.Decimal) },
+		"now":          func() time.Time { return now },
+		"add":          func(a, b int) int { return a + b },
+		"isaControl":   func() string { return fmt.Sprintf("%09s", strings.TrimSpace(env.ISA.ControlNumber)) },
+		"groupControl": func() string { return env.GroupControl },
+		"setControl":   func() string { return env.SetControl },
+		"setID":        func() string { return env.SetID },
+	}
+	parsed, err := template.New(t.name).Funcs(funcs).Option("missingkey=error").Parse(t.text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EDI template %s: %w", t.name, err)
+	}
+	return parsed, nil
+}
+
+/*
+Build renders the template for data and returns the complete interchange.
+
+Parameters:
+  - opts: Outbound delimiters, version and time zone.
+  - env:  Envelope identifiers and control numbers.
+  - data: The value the template is executed with.
+
+Returns:
+  - The X12 interchange.
+  - An error if opts are invalid, the template fails or renders an envelope segment itself.
+*/
+func (t *Template) Build(opts Options, env Envelope, data interface{}) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if env.SetControl == "" {
+		env.SetControl = "0001"
+	}
+	if env.ISA.Time.IsZero() {
+		env.ISA.Time = time.Now()
+	}
+	now := opts.In(env.ISA.Time)
+	parsed, err := t.parse(opts, env, now)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := parsed.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render EDI template %s: %w", t.name, err)
+	}
+
+	body, err := t.segments(out.String(), opts.Delimiters.Component)
+	if err != nil {
+		return nil, err
+	}
+	segments := []Segment{
+		opts.ISA(env.ISA),
+		{ID: "GS", Elements: []string{env.FunctionalID, env.SenderCode, env.ReceiverCode, now.Format("20060102"), now.Format("1504"), env.GroupControl, "X", opts.GroupVersion()}},
+		{ID: "ST", Elements: []string{env.SetID, env.SetControl}},
+	}
+	segments = append(segments, body...)
+	segments = append(segments,
+		Segment{ID: "SE", Elements: []string{fmt.Sprint(len(body) + 2), env.SetControl}},
+		Segment{ID: "GE", Elements: []string{"1", env.GroupControl}},
+		Segment{ID: "IEA", Elements: []string{"1", fmt.Sprintf("%09s", strings.TrimSpace(env.ISA.ControlNumber))}},
+	)
+	return opts.Write(segments), nil
+}
+
+// segments turns the rendered template notation into segments.
+func (t *Template) segments(rendered string, component byte) ([]Segment, error) {
+	var segments []Segment
+	for i, line := range strings.Split(rendered, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(strings.TrimSuffix(line, "~"), "*")
+		id := strings.TrimSpace(fields[0])
+		switch id {
+		case "ISA", "GS", "ST", "SE", "GE", "IEA":
+			return nil, fmt.Errorf("EDI template %s, rendered line %d: %s is added automatically", t.name, i+1, id)
+		case "":
+			return nil, fmt.Errorf("EDI template %s, rendered line %d: missing segment ID", t.name, i+1)
+		}
+		elements := fields[1:]
+		for len(elements) > 0 && elements[len(elements)-1] == "" {
+			elements = elements[:len(elements)-1]
+		}
+		for j, e := range elements {
+			elements[j] = strings.ReplaceAll(e, ">", string(component))
+		}
+		segments = append(segments, Segment{ID: id, Elements: elements})
+	}
+	return segments, nil
+}
+
+// formatAmount implements the amount template function.
+func formatAmount(v interface{}, places []int, decimal byte) (string, error) {
+	var f float64
+	switch x := v.(type) {
+	case string:
+		parsed, err := money.Parse(x, 0)
+		if err != nil {
+			return "", err
+		}
+		f = parsed
+	case float64:
+		f = x
+	case *float64:
+		if x == nil {
+			return "", nil
+		}
+		f = *x
+	case int:
+		f = float64(x)
+	default:
+		return "", fmt.Errorf("amount: unsupported value %v", v)
+	}
+	p := -1
+	if len(places) > 0 {
+		p = places[0]
+	}
+	return money.Format(f, p, decimal), nil
+}
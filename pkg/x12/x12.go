@@ -0,0 +1,194 @@
+// pkg/x12/x12.go
+package x12
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// isaLength is the fixed length of an ISA segment including its terminator.
+const isaLength = 106
+
+/*
+Delimiters are the separator characters of an interchange. X12 declares them
+in the fixed-width ISA segment rather than fixing them globally.
+
+Fields:
+  - Element:    Separates elements (ISA position 4, usually '*').
+  - Repetition: Separates repeated elements (ISA11 from version 00402 on, e.g. '^').
+  - Component:  Separates components of a composite element (ISA16, e.g. '>' or ':').
+  - Segment:    Terminates segments (character after ISA16, usually '~').
+*/
+type Delimiters struct {
+	Element    byte
+	Repetition byte
+	Component  byte
+	Segment    byte
+}
+
+/*
+DefaultDelimiters are the separators Amazon uses: '*', '^', '>' and '~'.
+*/
+var DefaultDelimiters = Delimiters{Element: '*', Repetition: '^', Component: '>', Segment: '~'}
+
+/*
+Segment is one parsed segment.
+
+Fields:
+  - Index:    Zero-based position in the document.
+  - ID:       Segment identifier (e.g. "ISA", "BEG").
+  - Elements: Element values; Elements[0] is ID01 (the ID itself is not included).
+*/
+type Segment struct {
+	Index    int
+	ID       string
+	Elements []string
+}
+
+/*
+Element returns the element at the given 1-based position (e.g. 3 for BEG03),
+or "" when the segment is shorter.
+*/
+func (s Segment) Element(pos int) string {
+	if pos < 1 || pos > len(s.Elements) {
+		return ""
+	}
+	return s.Elements[pos-1]
+}
+
+/*
+Document is a parsed X12 interchange.
+
+Fields:
+  - Delimiters: Separators used by the interchange.
+  - LineBreak:  Line break written after each segment terminator ("", "\n" or "\r\n").
+  - Segments:   Segments in document order.
+*/
+type Document struct {
+	Delimiters Delimiters
+	LineBreak  string
+	Segments   []Segment
+}
+
+/*
+DetectDelimiters reads the separators from the ISA segment at the start of data.
+Leading whitespace and a UTF‑8 byte order mark are ignored.
+
+Returns:
+  - The detected delimiters.
+  - An error if data does not start with a complete ISA segment.
+*/
+func DetectDelimiters(data []byte) (Delimiters, error) {
+	data = trimLeading(data)
+	if len(data) < isaLength || !bytes.HasPrefix(data, []byte("ISA")) {
+		return Delimiters{}, fmt.Errorf("data does not start with a complete ISA segment")
+	}
+	d := Delimiters{
+		Element:   data[3],
+		Component: data[104],
+		Segment:   data[105],
+	}
+	// ISA11 is the repetition separator from 00402 on; before that it is the
+	// standards identifier 'U'.
+	if rep := data[82]; rep != 'U' && !isAlnum(rep) {
+		d.Repetition = rep
+	}
+	// The 16 ISA elements must all be separated by the same character.
+	if n := bytes.Count(data[:isaLength], []byte{d.Element}); n != 16 {
+		return Delimiters{}, fmt.Errorf("malformed ISA segment: expected 16 element separators %q, found %d", d.Element, n)
+	}
+	if d.Segment == d.Element || d.Component == d.Element {
+		return Delimiters{}, fmt.Errorf("malformed ISA segment: separators are not distinct")
+	}
+	return d, nil
+}
+
+/*
+Parse splits an X12 interchange into segments and elements using the
+delimiters declared in its ISA segment. Line breaks after segment terminators
+are ignored.
+*/
+func Parse(data []byte) (*Document, error) {
+	delims, err := DetectDelimiters(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWith(data, delims), nil
+}
+
+/*
+ParseWith splits data into segments using the given delimiters. The line
+break following the first segment terminator, if any, is recorded so the
+document can be written back in the same layout.
+*/
+func ParseWith(data []byte, delims Delimiters) *Document {
+	data = trimLeading(data)
+	doc := &Document{Delimiters: delims, LineBreak: detectLineBreak(data, delims.Segment)}
+	for _, raw := range strings.Split(string(data), string(delims.Segment)) {
+		raw = strings.Trim(raw, "\r\n")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		parts := strings.Split(raw, string(delims.Element))
+		doc.Segments = append(doc.Segments, Segment{
+			Index:    len(doc.Segments),
+			ID:       strings.TrimSpace(parts[0]),
+			Elements: parts[1:],
+		})
+	}
+	return doc
+}
+
+/*
+Find returns the first segment with the given ID, or false.
+*/
+func (d *Document) Find(id string) (Segment, bool) {
+	for _, s := range d.Segments {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Segment{}, false
+}
+
+/*
+Components splits a composite element value on the component separator.
+*/
+func (d *Document) Components(value string) []string {
+	if d.Delimiters.Component == 0 {
+		return []string{value}
+	}
+	return strings.Split(value, string(d.Delimiters.Component))
+}
+
+// detectLineBreak returns the line break following the first segment terminator.
+func detectLineBreak(data []byte, terminator byte) string {
+	if terminator == '\n' || terminator == '\r' {
+		return ""
+	}
+	i := bytes.IndexByte(data, terminator)
+	if i < 0 {
+		return ""
+	}
+	rest := data[i+1:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("\r\n")):
+		return "\r\n"
+	case bytes.HasPrefix(rest, []byte("\n")):
+		return "\n"
+	default:
+		return ""
+	}
+}
+
+// trimLeading drops a UTF-8 BOM and leading whitespace.
+func trimLeading(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	return bytes.TrimLeft(data, " \t\r\n")
+}
+
+// isAlnum reports whether b is an ASCII letter or digit.
+func isAlnum(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
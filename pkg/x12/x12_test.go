@@ -0,0 +1,212 @@
+package x12
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample850 = "ISA*00*          *00*          *ZZ*AMAZON         *ZZ*VENDOR         *240102*1200*U*00401*000000101*0*P*>~\n" +
+	"GS*PO*AMAZON*VENDOR*20240102*1200*101*X*004010~\n" +
+	"ST*850*0001~\n" +
+	"BEG*00*SA*PO12345**20240102~\n" +
+	"PO1*1*10*EA*9.99**UP*012345678905~\n" +
+	"CTT*1~\n" +
+	"SE*5*0001~\n" +
+	"GE*1*101~\n" +
+	"IEA*1*000000101~\n"
+
+// TestDetectDelimiters verifies separators are read from the ISA segment.
+func TestDetectDelimiters(t *testing.T) {
+	custom := strings.NewReplacer("*", "|", "~", "\n", ">", ":").Replace(sample850)
+	d, err := DetectDelimiters([]byte(custom))
+	if err != nil {
+		t.Fatalf("DetectDelimiters() returned %v", err)
+	}
+	want := Delimiters{Element: '|', Component: ':', Segment: '\n'}
+	if d != want {
+		t.Errorf("DetectDelimiters() = %+v; expected %+v", d, want)
+	}
+
+	if _, err := DetectDelimiters([]byte("GS*PO~")); err == nil {
+		t.Error("expected an error for data without an ISA segment")
+	}
+}
+
+// TestParseAndValidate verifies segments are split and sound envelopes pass.
+func TestParseAndValidate(t *testing.T) {
+	doc, err := Parse([]byte(sample850))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	if len(doc.Segments) != 9 {
+		t.Fatalf("expected 9 segments, got %d", len(doc.Segments))
+	}
+	beg, ok := doc.Find("BEG")
+	if !ok || beg.Element(3) != "PO12345" {
+		t.Errorf("BEG03 = %q; expected PO12345", beg.Element(3))
+	}
+	if issues := doc.ValidateEnvelopes(); len(issues) != 0 {
+		t.Errorf("expected no envelope issues, got %+v", issues)
+	}
+}
+
+// TestValidateEnvelopesMismatch verifies count and control number mismatches are reported.
+func TestValidateEnvelopesMismatch(t *testing.T) {
+	bad := strings.Replace(sample850, "SE*5*0001", "SE*4*0002", 1)
+	doc, err := Parse([]byte(bad))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	issues := doc.ValidateEnvelopes()
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", issues)
+	}
+	for _, issue := range issues {
+		if issue.Segment != 6 {
+			t.Errorf("issue %q refers to segment %d; expected 6", issue.Message, issue.Segment)
+		}
+	}
+}
+
+// TestJSONRoundTrip verifies converting to JSON and back reproduces the input.
+func TestJSONRoundTrip(t *testing.T) {
+	for _, input := range []string{sample850, strings.ReplaceAll(sample850, "\n", "")} {
+		doc, err := Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("Parse() returned %v", err)
+		}
+		data, err := ToJSON(doc)
+		if err != nil {
+			t.Fatalf("ToJSON() returned %v", err)
+		}
+		back, err := FromJSON(data)
+		if err != nil {
+			t.Fatalf("FromJSON() returned %v", err)
+		}
+		if got := string(back.Bytes()); got != input {
+			t.Errorf("round trip changed the document:\n got: %q\nwant: %q", got, input)
+		}
+	}
+}
+
+// TestOptionsISA verifies written interchanges declare their delimiters in a fixed-width ISA.
+func TestOptionsISA(t *testing.T) {
+	opts := Options{Delimiters: Delimiters{Element: '|', Repetition: '^', Component: ':', Segment: '\n'}, Version: "00501"}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Validate() returned %v", err)
+	}
+	isa := opts.ISA(ISAHeader{SenderQualifier: "ZZ", SenderID: "VENDOR", ReceiverQualifier: "ZZ", ReceiverID: "AMAZON", ControlNumber: "42"})
+	data := opts.Write([]Segment{isa, {ID: "IEA", Elements: []string{"0", "000000042"}}})
+	d, err := DetectDelimiters(data)
+	if err != nil {
+		t.Fatalf("DetectDelimiters() returned %v for %q", err, data)
+	}
+	if d != opts.Delimiters {
+		t.Errorf("DetectDelimiters() = %+v; expected %+v", d, opts.Delimiters)
+	}
+	if opts.GroupVersion() != "005010" {
+		t.Errorf("GroupVersion() = %q; expected 005010", opts.GroupVersion())
+	}
+
+	for _, tt := range []struct {
+		options, header, want string
+	}{
+		{"", "", "T"},
+		{"P", "", "P"},
+		{"T", "P", "P"},
+		{"P", "T", "T"},
+	} {
+		opts.Usage = tt.options
+		if got := opts.ISA(ISAHeader{Usage: tt.header}).Elements[14]; got != tt.want {
+			t.Errorf("ISA15 with Options.Usage %q and ISAHeader.Usage %q = %q; expected %q", tt.options, tt.header, got, tt.want)
+		}
+	}
+	opts.Usage = "X"
+	if err := opts.Validate(); err == nil {
+		t.Error("Validate() accepted usage indicator X")
+	}
+}
+
+// TestGroupsErrors verifies sets are split per group and envelope errors map to 997 codes.
+func TestGroupsErrors(t *testing.T) {
+	bad := strings.Replace(sample850, "SE*5*0001", "SE*5*0002", 1)
+	bad = strings.Replace(bad, "GE*1*101", "GE*2*101", 1)
+	doc, err := Parse([]byte(bad))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	groups := doc.Groups()
+	if len(groups) != 1 || len(groups[0].Sets) != 1 {
+		t.Fatalf("expected 1 group with 1 set, got %+v", groups)
+	}
+	if got := groups[0].Sets[0].Errors(); len(got) != 1 || got[0] != SetControlMismatch {
+		t.Errorf("set Errors() = %v; expected [%s]", got, SetControlMismatch)
+	}
+	if got := groups[0].Errors(); len(got) != 1 || got[0] != GroupSetCountWrong {
+		t.Errorf("group Errors() = %v; expected [%s]", got, GroupSetCountWrong)
+	}
+}
+
+// TestSplit verifies each transaction set becomes a valid standalone interchange.
+func TestSplit(t *testing.T) {
+	two := strings.Replace(sample850, "GE*1*101~\n",
+		"ST*850*0002~\nBEG*00*SA*PO67890**20240102~\nSE*3*0002~\nGE*2*101~\n", 1)
+	doc, err := Parse([]byte(two))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	parts := doc.Split()
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 interchanges, got %d", len(parts))
+	}
+	for i, part := range parts {
+		if issues := part.ValidateEnvelopes(); len(issues) != 0 {
+			t.Errorf("part %d has envelope issues: %+v", i, issues)
+		}
+	}
+	if beg, _ := parts[1].Find("BEG"); beg.Element(3) != "PO67890" {
+		t.Errorf("second part BEG03 = %q; expected PO67890", beg.Element(3))
+	}
+}
+
+// TestTemplateBuild verifies templates render the body inside a valid envelope with outbound delimiters.
+func TestTemplateBuild(t *testing.T) {
+	tmpl, err := ParseTemplate("855", "BAK*00*AC*{{el .PO}}*{{date \"20060102\" now}}\n"+
+		"# partner-specific reference\n"+
+		"{{range .Refs}}REF*{{.}}*X>Y*\n{{end}}"+
+		"AMT*1*{{amount .Cost 2}}\n")
+	if err != nil {
+		t.Fatalf("ParseTemplate() returned %v", err)
+	}
+	opts := Options{Delimiters: Delimiters{Element: '|', Repetition: '^', Component: ':', Segment: '~'}, Version: "00401", Decimal: ','}
+	env := Envelope{
+		ISA:          ISAHeader{SenderQualifier: "ZZ", SenderID: "VENDOR", ReceiverQualifier: "ZZ", ReceiverID: "AMAZON", ControlNumber: "7"},
+		FunctionalID: "PR", SenderCode: "VENDOR", ReceiverCode: "AMAZON", GroupControl: "7", SetID: "855",
+	}
+	data := map[string]interface{}{"PO": "PO*1", "Refs": []string{"IA", "VR"}, "Cost": "1234.5"}
+	out, err := tmpl.Build(opts, env, data)
+	if err != nil {
+		t.Fatalf("Build() returned %v", err)
+	}
+	doc, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse() returned %v for %q", err, out)
+	}
+	if issues := doc.ValidateEnvelopes(); len(issues) != 0 {
+		t.Errorf("envelope issues: %+v\n%s", issues, out)
+	}
+	if bak, _ := doc.Find("BAK"); bak.Element(3) != "PO1" {
+		t.Errorf("BAK03 = %q; expected PO1", bak.Element(3))
+	}
+	if ref, _ := doc.Find("REF"); len(ref.Elements) != 2 || ref.Element(2) != "X:Y" {
+		t.Errorf("REF = %v; expected [IA X:Y]", ref.Elements)
+	}
+	if amt, _ := doc.Find("AMT"); amt.Element(2) != "1234,50" {
+		t.Errorf("AMT02 = %q; expected 1234,50 with a comma decimal separator", amt.Element(2))
+	}
+
+	bad, _ := ParseTemplate("bad", "ST*855*0001\n")
+	if _, err := bad.Build(opts, env, nil); err == nil {
+		t.Error("expected an error for a template writing its own ST")
+	}
+}
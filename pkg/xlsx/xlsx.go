@@ -0,0 +1,367 @@
+// pkg/xlsx/xlsx.go
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+Workbook is an Excel (Office Open XML) workbook built in memory and written
+in one go. It supports what reports need and nothing more: several sheets, a
+bold header row that stays visible when scrolling and has filter buttons, and
+text, number, boolean and date cells.
+*/
+type Workbook struct {
+	sheets []*Sheet
+}
+
+/*
+Sheet is one worksheet of a Workbook.
+*/
+type Sheet struct {
+	name   string
+	header []string
+	rows   [][]interface{}
+}
+
+/*
+New returns an empty workbook.
+*/
+func New() *Workbook {
+	return &Workbook{}
+}
+
+/*
+AddSheet appends a sheet with the given header row. Excel limits sheet names
+to 31 characters without []:*?/\ ; longer names are cut and those characters
+replaced, and a name already used gets a number appended.
+*/
+func (w *Workbook) AddSheet(name string, header ...string) *Sheet {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+	base := truncate(name, 31)
+	name = base
+	for i := 2; w.hasSheet(name); i++ {
+		suffix := " " + strconv.Itoa(i)
+		name = truncate(base, 31-len(suffix)) + suffix
+	}
+	s := &Sheet{name: name, header: header}
+	w.sheets = append(w.sheets, s)
+	return s
+}
+
+// hasSheet reports whether a sheet is named name (Excel ignores case).
+func (w *Workbook) hasSheet(name string) bool {
+	for _, s := range w.sheets {
+		if strings.EqualFold(s.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+AddRow appends a row. Values may be strings, integers, floats, bools,
+time.Time (written as a date in the time's own zone), pointers to those or nil
+for an empty cell; anything else is written as text with fmt.
+*/
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.rows = append(s.rows, values)
+}
+
+/*
+Write encodes the workbook as an .xlsx file to out. A workbook without sheets
+gets an empty one, since Excel refuses to open a file with none.
+*/
+func (w *Workbook) Write(out io.Writer) error {
+	sheets := w.sheets
+	if len(sheets) == 0 {
+		sheets = []*Sheet{{name: "Sheet1"}}
+	}
+	type part struct{ name, body string }
+	z := zip.NewWriter(out)
+	files := []part{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbookXML(sheets)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", stylesXML},
+	}
+	for i, s := range sheets {
+		files = append(files, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), s.xml()})
+	}
+	for _, f := range files {
+		fw, err := z.Create(f.name)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	return z.Close()
+}
+
+// Style indexes into the cellXfs of stylesXML.
+const (
+	styleDefault = 0
+	styleHeader  = 1
+	styleDate    = 2
+)
+
+// xml renders the worksheet part.
+func (s *Sheet) xml() string {
+	widths := make([]int, len(s.header))
+	grow := func(col, n int) {
+		for len(widths) <= col {
+			widths = append(widths, 0)
+		}
+		if n > widths[col] {
+			widths[col] = n
+		}
+	}
+
+	var rows strings.Builder
+	row := 1
+	if len(s.header) > 0 {
+		rows.WriteString(`<row r="1">`)
+		for i, h := range s.header {
+			rows.WriteString(cell(i, row, h, styleHeader))
+			grow(i, utf8.RuneCountInString(h)+2)
+		}
+		rows.WriteString(`</row>`)
+		row++
+	}
+	for _, values := range s.rows {
+		fmt.Fprintf(&rows, `<row r="%d">`, row)
+		for i, v := range values {
+			c, n := value(i, row, v)
+			rows.WriteString(c)
+			grow(i, n)
+		}
+		rows.WriteString(`</row>`)
+		row++
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	if len(widths) > 0 {
+		b.WriteString(`<cols>`)
+		for i, w := range widths {
+			if w > 60 {
+				w = 60
+			}
+			if w < 8 {
+				w = 8
+			}
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, w+1)
+		}
+		b.WriteString(`</cols>`)
+	}
+	b.WriteString(`<sheetData>`)
+	b.WriteString(rows.String())
+	b.WriteString(`</sheetData>`)
+	if len(s.header) > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, ColumnName(len(s.header)-1), row-1)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+/*
+value renders one cell of a data row.
+
+Returns:
+  - The <c> element ("" for an empty cell).
+  - The approximate display width, for sizing the column.
+*/
+func value(col, row int, v interface{}) (string, int) {
+	ref := ColumnName(col) + strconv.Itoa(row)
+	switch x := v.(type) {
+	case nil:
+		return "", 0
+	case string:
+		return cell(col, row, x, styleDefault), utf8.RuneCountInString(x)
+	case *string:
+		if x == nil {
+			return "", 0
+		}
+		return value(col, row, *x)
+	case bool:
+		b := "0"
+		if x {
+			b = "1"
+		}
+		return fmt.Sprintf(`<c r="%s" t="b"><v>%s</v></c>`, ref, b), 5
+	case int:
+		return number(ref, strconv.Itoa(x))
+	case int64:
+		return number(ref, strconv.FormatInt(x, 10))
+	case float64:
+		return number(ref, strconv.FormatFloat(x, 'f', -1, 64))
+	case *float64:
+		if x == nil {
+			return "", 0
+		}
+		return value(col, row, *x)
+	case time.Time:
+		if x.IsZero() {
+			return "", 0
+		}
+		serial := strconv.FormatFloat(Serial(x), 'f', -1, 64)
+		return fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, styleDate, serial), 16
+	case *time.Time:
+		if x == nil {
+			return "", 0
+		}
+		return value(col, row, *x)
+	default:
+		s := fmt.Sprint(x)
+		return cell(col, row, s, styleDefault), utf8.RuneCountInString(s)
+	}
+}
+
+// number renders a numeric cell.
+func number(ref, v string) (string, int) {
+	return fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, v), len(v)
+}
+
+// cell renders a text cell as an inline string.
+func cell(col, row int, s string, style int) string {
+	if s == "" && style == styleDefault {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"`, ColumnName(col), row)
+	if style != styleDefault {
+		fmt.Fprintf(&b, ` s="%d"`, style)
+	}
+	b.WriteString(`><is><t`)
+	if strings.TrimSpace(s) != s {
+		b.WriteString(` xml:space="preserve"`)
+	}
+	b.WriteString(`>`)
+	xml.EscapeText(&b, []byte(s))
+	b.WriteString(`</t></is></c>`)
+	return b.String()
+}
+
+/*
+ColumnName returns the letters of a 0-based column index: A, B, ... Z, AA, AB ...
+*/
+func ColumnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+/*
+Serial converts t to an Excel date serial number: days since 1899-12-30 with
+the time of day as the fraction, taken from t's wall clock in its own zone.
+*/
+func Serial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func contentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func workbookXML(sheets []*Sheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, s := range sheets {
+		b.WriteString(`<sheet name="`)
+		xml.EscapeText(&b, []byte(s.name))
+		fmt.Fprintf(&b, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+	b.WriteString(`</sheets>`)
+	// Filter ranges must be defined names for Excel to keep the filter buttons.
+	var names strings.Builder
+	for i, s := range sheets {
+		if len(s.header) == 0 {
+			continue
+		}
+		fmt.Fprintf(&names, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'`, i)
+		xml.EscapeText(&names, []byte(strings.ReplaceAll(s.name, "'", "''")))
+		fmt.Fprintf(&names, `'!$A$1:$%s$%d</definedName>`, ColumnName(len(s.header)-1), len(s.rows)+1)
+	}
+	if names.Len() > 0 {
+		b.WriteString(`<definedNames>` + names.String() + `</definedNames>`)
+	}
+	b.WriteString(`</workbook>`)
+	return b.String()
+}
+
+func workbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// stylesXML defines the cell formats: default, bold header and date (numFmt 164).
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
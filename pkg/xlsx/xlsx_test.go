@@ -0,0 +1,70 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteWorkbook verifies the package parts, the sheet names and the
+// encoding of text, number and date cells.
+func TestWriteWorkbook(t *testing.T) {
+	w := New()
+	orders := w.AddSheet("Orders", "PO number", "Units", "Date")
+	orders.AddRow("PO<1> & co", 12, time.Date(2024, 5, 2, 12, 0, 0, 0, time.FixedZone("PDT", -7*3600)))
+	orders.AddRow(" padded", 2.5, nil)
+	w.AddSheet("orders")
+	w.AddSheet("Line items: all/any [2024]")
+
+	var buf bytes.Buffer
+	if err := w.Write(&buf); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip file: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range z.File {
+		r, _ := f.Open()
+		b, _ := io.ReadAll(r)
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet3.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook has no part %s", name)
+		}
+	}
+
+	for _, expected := range []string{`name="Orders"`, `name="orders 2"`, `name="Line items_ all_any _2024_"`} {
+		if !strings.Contains(parts["xl/workbook.xml"], expected) {
+			t.Errorf("workbook.xml does not contain %s", expected)
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, expected := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t>PO number</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t>PO&lt;1&gt; &amp; co</t></is></c>`,
+		`<c r="B2"><v>12</v></c>`,
+		`<c r="C2" s="2"><v>45414.5</v></c>`,
+		`<t xml:space="preserve"> padded</t>`,
+		`<c r="B3"><v>2.5</v></c>`,
+		`<autoFilter ref="A1:C3"/>`,
+	} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("sheet1.xml does not contain %s", expected)
+		}
+	}
+}
+
+// TestColumnName verifies column letters around the single/double letter boundary.
+func TestColumnName(t *testing.T) {
+	for col, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := ColumnName(col); got != expected {
+			t.Errorf("ColumnName(%d) = %q; expected %q", col, got, expected)
+		}
+	}
+}
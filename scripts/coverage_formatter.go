@@ -39,6 +39,20 @@ const (
 	HexLowCov       = "#FF4500" // OrangeRed
 )
 
+// console colors the formatted output when stdout is a terminal.
+var console = utils.NewLogger(os.Stdout)
+
+// Styles built from the hex colors above.
+var (
+	dirStyle     = console.Style(HexDirColor)
+	fileStyle    = console.Style(HexFileColor)
+	lineNumStyle = console.Style(HexLineNumColor)
+	funcStyle    = console.Style(HexFuncColor)
+	colorHighCov = console.Style(HexHighCov)
+	colorMidCov  = console.Style(HexMidCov)
+	colorLowCov  = console.Style(HexLowCov)
+)
+
 // inputReader is our source for input; it defaults to os.Stdin but can be overridden in tests.
 var inputReader io.Reader = os.Stdin
 
@@ -80,8 +94,8 @@ func styleCoverageLine(line string) string {
 		spacingBeforeCoverage := matches[5]
 		coverageString := matches[6]
 		coloredFilePath := formatPathAndFile(fullPath)
-		coloredLineNumber := utils.Colorize(lineNumber, HexLineNumColor)
-		coloredFunction := utils.Colorize(funcName, HexFuncColor)
+		coloredLineNumber := lineNumStyle.Sprint(lineNumber)
+		coloredFunction := funcStyle.Sprint(funcName)
 		coloredCoverage := colorizeCoverage(coverageString)
 		return fmt.Sprintf("%s:%s:%s%s%s%s",
 			coloredFilePath,
@@ -100,9 +114,9 @@ func formatPathAndFile(fullPath string) string {
 	dir := filepath.Dir(fullPath)
 	file := filepath.Base(fullPath)
 	if dir == "." || dir == "" {
-		return utils.Colorize(file, HexFileColor)
+		return fileStyle.Sprint(file)
 	}
-	return utils.Colorize(dir+"/", HexDirColor) + utils.Colorize(file, HexFileColor)
+	return dirStyle.Sprintf("%s/", dir) + fileStyle.Sprint(file)
 }
 
 // colorizeCoverageInLine replaces all coverage percentages in a line with their colored versions.
@@ -121,10 +135,10 @@ func colorizeCoverage(coverageStr string) string {
 	}
 	switch {
 	case coverageValue >= HighCoverageThreshold:
-		return utils.Colorize(coverageStr, HexHighCov)
+		return colorHighCov.Sprint(coverageStr)
 	case coverageValue >= MediumCoverageThreshold:
-		return utils.Colorize(coverageStr, HexMidCov)
+		return colorMidCov.Sprint(coverageStr)
 	default:
-		return utils.Colorize(coverageStr, HexLowCov)
+		return colorLowCov.Sprint(coverageStr)
 	}
 }